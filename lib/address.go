@@ -23,6 +23,10 @@ const (
 // Address represents a blockchain address derived from a public key hash
 type Address [32]byte
 
+// BurnAddress is the well-known address of burn outputs. No public key hashes to all
+// zeros, so nothing sent here can ever be spent.
+var BurnAddress Address
+
 // String returns the checksummed string representation of an address
 // Format: [BOM][HEX-WITH-EIP55-CASE][LUHN-CHAR]
 func (a Address) String() string {
@@ -58,9 +62,26 @@ func DeriveAddress(publicKey *mldsa87.PublicKey) Address {
 	return Address(hash)
 }
 
-// ParseAddress converts a string to an Address with full validation
-// Validates BOM prefix, optional EIP-55 checksum (if mixed case), and mandatory Luhn checksum
+// ParseAddress converts a string to an Address with full validation. Accepts the bech32m
+// form, or the hex form: validates BOM prefix, optional EIP-55 checksum (if mixed case),
+// and mandatory Luhn checksum
 func ParseAddress(addrStr string) (Address, AddressType, error) {
+	if looksBech32m(addrStr) {
+		addr, addrType, err := parseBech32mAddress(addrStr)
+		if err == nil {
+			return addr, addrType, nil
+		}
+		// Chain IDs made of hex digits can make a hex address look like bech32m
+		if hexAddr, hexType, hexErr := parseHexAddress(addrStr); hexErr == nil {
+			return hexAddr, hexType, nil
+		}
+		return addr, 0, err
+	}
+	return parseHexAddress(addrStr)
+}
+
+// parseHexAddress parses the hex + Luhn address form
+func parseHexAddress(addrStr string) (Address, AddressType, error) {
 	var addr Address
 
 	if len(addrStr) < 3 { // Minimum: BOM + some hex + Luhn
@@ -116,6 +137,20 @@ func isValidAddressType(t AddressType) bool {
 		t == AddressTypeExchange || t == AddressTypeNFT
 }
 
+// MarshalText encodes an address type as its prefix letter
+func (t AddressType) MarshalText() ([]byte, error) {
+	return []byte{byte(t)}, nil
+}
+
+// UnmarshalText decodes an address type from its prefix letter
+func (t *AddressType) UnmarshalText(data []byte) error {
+	if len(data) != 1 || !isValidAddressType(AddressType(data[0])) {
+		return fmt.Errorf("invalid address type: %q", data)
+	}
+	*t = AddressType(data[0])
+	return nil
+}
+
 // applyEIP55Checksum applies EIP-55 style checksum to a hex string
 // Characters are uppercased if the corresponding bit in the hash is 1
 func applyEIP55Checksum(hexStr string) string {
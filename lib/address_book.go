@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AddressBookPrefix is the key prefix for address book entries
+const AddressBookPrefix = "abook:" // abook:{label} -> AddressBookEntry
+
+// labelRegex restricts labels to simple identifiers so they can't be confused with addresses
+var labelRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// AddressBookEntry maps a human-readable label to an address
+type AddressBookEntry struct {
+	Label       string  `json:"label"`
+	Address     Address `json:"address"`
+	Note        string  `json:"note,omitempty"`
+	RequireMemo bool    `json:"require_memo,omitempty"` // Incoming payments must carry a memo (destination tag)
+	Created     int64   `json:"created"`
+}
+
+// ToJSON returns the entry with the address in its string form for API responses
+func (e *AddressBookEntry) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"label":        e.Label,
+		"address":      e.Address.Display(),
+		"note":         e.Note,
+		"require_memo": e.RequireMemo,
+		"created":      e.Created,
+	}
+}
+
+// AddressBook is an on-node, persisted mapping of labels to addresses
+type AddressBook struct {
+	db      *BoltDBAdapter
+	mutex   sync.RWMutex
+	entries map[string]*AddressBookEntry // label -> entry
+}
+
+// NewAddressBook opens (or creates) the address book database
+func NewAddressBook(dbPath string) (*AddressBook, error) {
+	db, err := NewBoltDBAdapter(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open address book database: %w", err)
+	}
+
+	ab := &AddressBook{
+		db:      db,
+		entries: make(map[string]*AddressBookEntry),
+	}
+
+	if err := ab.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return ab, nil
+}
+
+// load reads all entries from the database into memory
+func (ab *AddressBook) load() error {
+	iter, err := ab.db.Iterator([]byte(AddressBookPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to iterate address book: %w", err)
+	}
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
+		var entry AddressBookEntry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return fmt.Errorf("failed to unmarshal address book entry: %w", err)
+		}
+		ab.entries[entry.Label] = &entry
+	}
+
+	return nil
+}
+
+// ValidateLabel checks that a label is usable
+func ValidateLabel(label string) error {
+	if !labelRegex.MatchString(label) {
+		return fmt.Errorf("label must be 1-64 characters of letters, digits, '_', '.', or '-'")
+	}
+	if ValidateAddress(label) {
+		return fmt.Errorf("label cannot be a valid address")
+	}
+	return nil
+}
+
+// Set adds or replaces an entry. Replacing keeps the entry's memo requirement.
+func (ab *AddressBook) Set(label string, addr Address, note string) (*AddressBookEntry, error) {
+	if err := ValidateLabel(label); err != nil {
+		return nil, err
+	}
+
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+
+	entry := &AddressBookEntry{
+		Label:   label,
+		Address: addr,
+		Note:    note,
+		Created: time.Now().Unix(),
+	}
+	if existing, ok := ab.entries[label]; ok {
+		entry.Created = existing.Created
+		entry.RequireMemo = existing.RequireMemo
+	}
+	return entry, ab.storeLocked(entry)
+}
+
+// SetRequireMemo sets whether payments to a label's address must carry a memo
+func (ab *AddressBook) SetRequireMemo(label string, required bool) (*AddressBookEntry, error) {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+
+	existing, ok := ab.entries[label]
+	if !ok {
+		return nil, fmt.Errorf("label %s not found", label)
+	}
+	entry := *existing
+	entry.RequireMemo = required
+	return &entry, ab.storeLocked(&entry)
+}
+
+// storeLocked persists an entry and makes it current (caller holds ab.mutex)
+func (ab *AddressBook) storeLocked(entry *AddressBookEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal address book entry: %w", err)
+	}
+	if err := ab.db.Set([]byte(AddressBookPrefix+entry.Label), data); err != nil {
+		return fmt.Errorf("failed to store address book entry: %w", err)
+	}
+
+	ab.entries[entry.Label] = entry
+	return nil
+}
+
+// Delete removes an entry by label
+func (ab *AddressBook) Delete(label string) error {
+	ab.mutex.Lock()
+	defer ab.mutex.Unlock()
+
+	if _, ok := ab.entries[label]; !ok {
+		return fmt.Errorf("label %s not found", label)
+	}
+	if err := ab.db.Delete([]byte(AddressBookPrefix + label)); err != nil {
+		return fmt.Errorf("failed to delete address book entry: %w", err)
+	}
+
+	delete(ab.entries, label)
+	return nil
+}
+
+// Get returns the entry for a label
+func (ab *AddressBook) Get(label string) (*AddressBookEntry, bool) {
+	ab.mutex.RLock()
+	defer ab.mutex.RUnlock()
+	entry, ok := ab.entries[label]
+	return entry, ok
+}
+
+// List returns all entries sorted by label
+func (ab *AddressBook) List() []*AddressBookEntry {
+	ab.mutex.RLock()
+	defer ab.mutex.RUnlock()
+
+	entries := make([]*AddressBookEntry, 0, len(ab.entries))
+	for _, entry := range ab.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Label < entries[j].Label })
+	return entries
+}
+
+// LabelFor returns the first label (alphabetically) pointing at addr, or "" if none
+func (ab *AddressBook) LabelFor(addr Address) string {
+	for _, entry := range ab.List() {
+		if entry.Address == addr {
+			return entry.Label
+		}
+	}
+	return ""
+}
+
+// MemoRequired returns the addresses whose entries require a memo on incoming
+// payments, each with its first label
+func (ab *AddressBook) MemoRequired() map[Address]string {
+	required := make(map[Address]string)
+	for _, entry := range ab.List() {
+		if _, ok := required[entry.Address]; !ok && entry.RequireMemo {
+			required[entry.Address] = entry.Label
+		}
+	}
+	return required
+}
+
+// Resolve parses s as an address, falling back to an address book label
+func (ab *AddressBook) Resolve(s string) (Address, error) {
+	addr, _, err := ParseAPIAddress(s)
+	if err == nil {
+		return addr, nil
+	}
+
+	if entry, ok := ab.Get(s); ok {
+		return entry.Address, nil
+	}
+
+	return Address{}, fmt.Errorf("not a valid address or known label: %w", err)
+}
+
+// Close closes the address book database
+func (ab *AddressBook) Close() error {
+	return ab.db.Close()
+}
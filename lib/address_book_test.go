@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAddressBookPersistence(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "addressbook.db")
+
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	addr := kp.Address()
+
+	ab, err := NewAddressBook(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open address book: %v", err)
+	}
+	if _, err := ab.Set("exchange-hot", addr, "hot wallet"); err != nil {
+		t.Fatalf("Failed to set label: %v", err)
+	}
+	ab.Close()
+
+	// Reopen and verify the entry survived
+	ab, err = NewAddressBook(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen address book: %v", err)
+	}
+	defer ab.Close()
+
+	resolved, err := ab.Resolve("exchange-hot")
+	if err != nil {
+		t.Fatalf("Failed to resolve label: %v", err)
+	}
+	if resolved != addr {
+		t.Fatal("Resolved address does not match")
+	}
+
+	// Raw addresses resolve to themselves
+	resolved, err = ab.Resolve(addr.String())
+	if err != nil || resolved != addr {
+		t.Fatalf("Raw address should resolve to itself: %v", err)
+	}
+
+	if label := ab.LabelFor(addr); label != "exchange-hot" {
+		t.Fatalf("Expected label exchange-hot, got %q", label)
+	}
+
+	if err := ab.Delete("exchange-hot"); err != nil {
+		t.Fatalf("Failed to delete label: %v", err)
+	}
+	if _, err := ab.Resolve("exchange-hot"); err == nil {
+		t.Fatal("Deleted label should not resolve")
+	}
+}
+
+func TestAddressBookRejectsBadLabels(t *testing.T) {
+	ab, err := NewAddressBook(filepath.Join(t.TempDir(), "addressbook.db"))
+	if err != nil {
+		t.Fatalf("Failed to open address book: %v", err)
+	}
+	defer ab.Close()
+
+	kp, _ := GenerateKeyPair()
+	addr := kp.Address()
+
+	for _, label := range []string{"", "has space", addr.String()} {
+		if _, err := ab.Set(label, addr, ""); err == nil {
+			t.Errorf("Expected label %q to be rejected", label)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// Outputs record the address type they were sent to. Liquidity (L) and exchange (X)
+// addresses belong to the pool and swap subsystems, so only their transactions may pay
+// them; regular sends to those prefixes are rejected rather than stranding funds.
+// Wallet (S) and NFT (N) addresses can receive from any transaction.
+
+// addressTypeTxTypes lists the transaction types allowed to pay each restricted type
+var addressTypeTxTypes = map[AddressType][]TxType{
+	AddressTypeLiquidity: {TxTypeCreatePool, TxTypeAddLiquidity, TxTypeRemoveLiquidity, TxTypeSwap},
+	AddressTypeExchange:  {TxTypeOffer, TxTypeAcceptOffer, TxTypeCancelOffer, TxTypeMatchOffers},
+}
+
+// CheckRecipientType returns an error if a transaction of txType may not pay an address
+// of addrType
+func CheckRecipientType(addrType AddressType, txType TxType) error {
+	if !isValidAddressType(addrType) {
+		return fmt.Errorf("invalid address type: %q", byte(addrType))
+	}
+	allowed, restricted := addressTypeTxTypes[addrType]
+	if !restricted {
+		return nil
+	}
+	for _, t := range allowed {
+		if t == txType {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s transactions cannot pay %c addresses", txType.String(), addrType)
+}
+
+// validateOutputAddressTypes checks every output's address type against the transaction type
+func validateOutputAddressTypes(tx *Transaction) error {
+	for i, output := range tx.Outputs {
+		if err := CheckRecipientType(output.Type(), tx.TxType); err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return nil
+}
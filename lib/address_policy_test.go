@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOutputAddressTypes(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+
+	// Wallet outputs serialize exactly as before, so existing transaction hashes hold
+	plain := CreateShadowOutput(kp.Address(), 100)
+	data, _ := json.Marshal(plain)
+	if strings.Contains(string(data), "address_type") {
+		t.Errorf("Wallet output should not record an address type: %s", data)
+	}
+
+	pool := CreateShadowOutput(kp.Address(), 100)
+	pool.AddressType = AddressTypeLiquidity
+	data, _ = json.Marshal(pool)
+	var decoded TxOutput
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Type() != AddressTypeLiquidity {
+		t.Fatalf("Address type should round-trip as its prefix, got %s (%v)", data, err)
+	}
+	if !strings.HasPrefix(decoded.AddressString(), "L") {
+		t.Errorf("Expected L-prefixed address, got %s", decoded.AddressString())
+	}
+
+	send := &Transaction{TxType: TxTypeSend, Outputs: []*TxOutput{plain, pool}}
+	if err := validateOutputAddressTypes(send); err == nil {
+		t.Error("Send paying a liquidity address should be rejected")
+	}
+	swap := &Transaction{TxType: TxTypeSwap, Outputs: []*TxOutput{plain, pool}}
+	if err := validateOutputAddressTypes(swap); err != nil {
+		t.Errorf("Swap may pay a liquidity address: %v", err)
+	}
+
+	if err := CheckRecipientType(AddressTypeExchange, TxTypeSend); err == nil {
+		t.Error("Send paying an exchange address should be rejected")
+	}
+	if err := CheckRecipientType(AddressTypeNFT, TxTypeSend); err != nil {
+		t.Errorf("NFT addresses accept regular sends: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package lib
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// The admin endpoints change a running node without a restart: flush the mempool,
+// rebroadcast, connect or disconnect peers, rotate the API key, and write a snapshot or a
+// backup. They are protected like every other write endpoint.
+// Changes last until the process exits; a rotated API key must also be put in the config
+// file or SHADOWY_API_KEY to survive a restart.
+
+const (
+	SnapshotDir      = "snapshots"    // Under the data directory
+	snapshotAttempts = 3              // Exports retried when a block lands mid-export
+	maxAPIKeyGrace   = 24 * time.Hour // Longest an old key is accepted after a rotation
+)
+
+// apiKeyring holds the API key and, for a grace period after a rotation, the one it
+// replaced. The zero value has no key.
+type apiKeyring struct {
+	mu            sync.RWMutex
+	current       string
+	previous      string // Still accepted until previousUntil
+	previousUntil time.Time
+}
+
+// Enabled reports whether an API key is configured
+func (k *apiKeyring) Enabled() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current != ""
+}
+
+// Valid reports whether key is the current key, or the previous one within its grace period
+func (k *apiKeyring) Valid(key string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if key == "" || k.current == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(k.current)) == 1 {
+		return true
+	}
+	return k.previous != "" && time.Now().Before(k.previousUntil) &&
+		subtle.ConstantTimeCompare([]byte(key), []byte(k.previous)) == 1
+}
+
+// Rotate makes key the API key, accepting the old one for grace longer
+func (k *apiKeyring) Rotate(key string, grace time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.previous, k.previousUntil = "", time.Time{}
+	if grace > 0 && k.current != "" {
+		k.previous, k.previousUntil = k.current, time.Now().Add(grace)
+	}
+	k.current = key
+}
+
+// handleAdminMempoolFlush empties the mempool (POST {"keep_local": bool})
+func (n *P2PBlockchainNode) handleAdminMempoolFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		KeepLocal bool `json:"keep_local"` // Keep transactions submitted through this node
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flushed := n.Mempool.Flush(req.KeepLocal)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flushed":   flushed,
+		"remaining": n.Mempool.Count(),
+	})
+}
+
+// handleAdminRebroadcast re-announces the mempool and rebroadcasts every pending local
+// transaction, re-adding those that were evicted
+func (n *P2PBlockchainNode) handleAdminRebroadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	local := 0
+	if n.WalletTxs != nil {
+		if err := n.WalletTxs.RebroadcastNow(); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to rebroadcast local transactions: %v", err), http.StatusInternalServerError)
+			return
+		}
+		local = len(n.WalletTxs.List(WalletTxPending))
+	}
+
+	announced, err := n.Mempool.AnnounceAll()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to announce mempool: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announced":       announced,
+		"local_pending":   local,
+		"connected_peers": len(n.P2P.GetPeers()),
+	})
+}
+
+// handleAdminPeerConnect dials a peer (POST {"multiaddr": "/ip4/.../p2p/<id>"})
+func (n *P2PBlockchainNode) handleAdminPeerConnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Multiaddr string `json:"multiaddr"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Multiaddr == "" {
+		http.Error(w, "Invalid request body: multiaddr is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := n.P2P.ConnectToPeer(req.Multiaddr); err != nil {
+		status := http.StatusBadGateway
+		if !strings.Contains(err.Error(), "failed to connect") {
+			status = http.StatusBadRequest // Not a dialable multiaddr with a peer ID
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "connected",
+		"connected_peers": len(n.P2P.GetPeers()),
+	})
+}
+
+// handleAdminPeerDisconnect drops a peer (POST {"peer": "<multiaddr or peer ID>"})
+func (n *P2PBlockchainNode) handleAdminPeerDisconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Peer      string `json:"peer"`
+		Multiaddr string `json:"multiaddr"` // Accepted in place of peer
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Peer == "" {
+		req.Peer = req.Multiaddr
+	}
+
+	id, err := parsePeerRef(req.Peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := n.P2P.DisconnectPeer(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "disconnected",
+		"peer_id":         id.String(),
+		"connected_peers": len(n.P2P.GetPeers()),
+	})
+}
+
+// parsePeerRef returns the peer ID of a multiaddr ending in /p2p/<id>, or of a bare peer ID
+func parsePeerRef(ref string) (peer.ID, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("peer is required")
+	}
+	if strings.HasPrefix(ref, "/") {
+		maddr, err := multiaddr.NewMultiaddr(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid multiaddr: %w", err)
+		}
+		info, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			return "", fmt.Errorf("multiaddr has no peer ID: %w", err)
+		}
+		return info.ID, nil
+	}
+	id, err := peer.Decode(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer ID: %w", err)
+	}
+	return id, nil
+}
+
+// handleAdminRotateAPIKey replaces the API key (POST {"api_key": "...", "grace_seconds": N}).
+// Without api_key a random one is generated. The new key is returned once; the old key
+// keeps working for grace_seconds.
+func (n *P2PBlockchainNode) handleAdminRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		APIKey       string `json:"api_key"`
+		GraceSeconds int    `json:"grace_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	grace := time.Duration(req.GraceSeconds) * time.Second
+	if grace < 0 || grace > maxAPIKeyGrace {
+		http.Error(w, fmt.Sprintf("grace_seconds must be between 0 and %d", int(maxAPIKeyGrace.Seconds())), http.StatusBadRequest)
+		return
+	}
+
+	key := strings.TrimSpace(req.APIKey)
+	if key == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate API key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		key = hex.EncodeToString(raw)
+	} else if len(key) < 16 {
+		http.Error(w, "api_key must be at least 16 characters", http.StatusBadRequest)
+		return
+	}
+
+	n.apiKeys.Rotate(key, grace)
+	fmt.Printf("[Node] 🔑 API key rotated (previous key accepted for %s)\n", grace)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_key":       key,
+		"grace_seconds": req.GraceSeconds,
+		"persisted":     false, // Put it in the config file or SHADOWY_API_KEY to keep it across restarts
+	})
+}
+
+// handleAdminSnapshot writes an archive of the chain to the tip, with the UTXO set, under
+// the data directory's snapshots folder
+func (n *P2PBlockchainNode) handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !n.snapshotLock.TryLock() {
+		http.Error(w, "A snapshot is already being written", http.StatusConflict)
+		return
+	}
+	defer n.snapshotLock.Unlock()
+
+	dir := n.dataPath(SnapshotDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create snapshot directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var path string
+	var summary *ArchiveSummary
+	err := ErrArchiveTipMoved
+	for attempt := 0; attempt < snapshotAttempts && errors.Is(err, ErrArchiveTipMoved); attempt++ {
+		tip := n.Chain.GetHeight() - 1
+		path = filepath.Join(dir, fmt.Sprintf("snapshot-%d.gz", tip))
+		summary, err = n.Chain.ExportArchive(path, n.Chain.GetUTXOStore().TxPruneHorizon(), tip, true)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Snapshot failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Printf("[Node] 📦 Wrote snapshot of blocks %d-%d to %s\n", summary.Header.From, summary.Header.To, path)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":       path,
+		"from":       summary.Header.From,
+		"to":         summary.Header.To,
+		"blocks":     summary.Blocks,
+		"utxos":      summary.UTXOs,
+		"state_hash": summary.Header.StateHash,
+		"sha256":     summary.Checksum,
+	})
+}
+
+// handleAdminBackupNow takes a backup of the wallet and a chain checkpoint right away
+func (n *P2PBlockchainNode) handleAdminBackupNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := n.Backups.RunNow()
+	if errors.Is(err, ErrBackupRunning) {
+		http.Error(w, "A backup is already running", http.StatusConflict)
+		return
+	}
+	if err != nil && result == nil {
+		http.Error(w, fmt.Sprintf("Backup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// A failed upload still leaves the local backup, which the response describes
+	response := map[string]interface{}{"backup": result}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(response)
+}
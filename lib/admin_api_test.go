@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminAPI(t *testing.T) {
+	n := &P2PBlockchainNode{apiKeys: apiKeyring{current: "old-key-0123456789"}, idempotency: NewIdempotencyCache(IdempotencyWindow)}
+	handler := n.requireAuth(func(w http.ResponseWriter, r *http.Request) {})
+	status := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/mempool/flush", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// Without an API key or signers admin endpoints only answer local callers
+	open := &P2PBlockchainNode{idempotency: NewIdempotencyCache(IdempotencyWindow)}
+	from := func(wrap func(http.HandlerFunc) http.HandlerFunc, remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/mempool/flush", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		wrap(func(w http.ResponseWriter, r *http.Request) {})(rec, req)
+		return rec.Code
+	}
+	if from(open.requireAdmin, "203.0.113.7:40000") != http.StatusForbidden {
+		t.Error("Expected a remote admin call to be refused without auth")
+	}
+	if from(open.requireAdmin, "127.0.0.1:40000") != http.StatusOK || from(open.requireAdmin, "[::1]:40000") != http.StatusOK {
+		t.Error("Expected a local admin call to be served without auth")
+	}
+	if from(open.requireAuth, "203.0.113.7:40000") != http.StatusOK {
+		t.Error("Expected other write endpoints to stay open without auth")
+	}
+	if from(n.requireAdmin, "203.0.113.7:40000") != http.StatusUnauthorized {
+		t.Error("Expected a remote admin call without the key to be refused")
+	}
+
+	// A rotation with a grace period keeps the old key working until it ends
+	rec := httptest.NewRecorder()
+	n.handleAdminRotateAPIKey(rec, httptest.NewRequest(http.MethodPost, "/api/admin/api_key/rotate",
+		strings.NewReader(`{"api_key": "new-key-0123456789", "grace_seconds": 60}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the rotation to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if status("new-key-0123456789") != http.StatusOK || status("old-key-0123456789") != http.StatusOK {
+		t.Error("Expected both keys to be accepted during the grace period")
+	}
+	n.apiKeys.previousUntil = time.Now().Add(-time.Second)
+	if status("old-key-0123456789") != http.StatusUnauthorized {
+		t.Error("Expected the old key to be refused after the grace period")
+	}
+
+	// Without a grace period the old key stops working at once
+	n.apiKeys.Rotate("newer-key-0123456789", 0)
+	if status("new-key-0123456789") != http.StatusUnauthorized || status("newer-key-0123456789") != http.StatusOK {
+		t.Error("Expected only the newest key to be accepted")
+	}
+
+	// Flushing keeps the node's own transactions when asked
+	kp, _ := GenerateKeyPair()
+	n.Mempool = &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	for i, local := range []bool{true, false, false} {
+		tx := NewTxBuilder(TxTypeSend).AddInput("admin-test-funding", uint32(i)).AddOutput(kp.Address(), 1, "").Build()
+		txID, _ := tx.ID()
+		n.Mempool.entries[txID] = &MempoolEntry{Tx: tx, Local: local}
+		n.Mempool.relay.markSeen(txID)
+	}
+	rec = httptest.NewRecorder()
+	n.handleAdminMempoolFlush(rec, httptest.NewRequest(http.MethodPost, "/api/admin/mempool/flush", strings.NewReader(`{"keep_local": true}`)))
+	if rec.Code != http.StatusOK || n.Mempool.Count() != 1 {
+		t.Fatalf("Expected only the local transaction to remain, got %d with status %d", n.Mempool.Count(), rec.Code)
+	}
+	if n.Mempool.Flush(false) != 1 || n.Mempool.Count() != 0 {
+		t.Error("Expected a full flush to empty the mempool")
+	}
+
+	if _, err := parsePeerRef("/ip4/127.0.0.1/tcp/4001"); err == nil {
+		t.Error("Expected a multiaddr without a peer ID to be refused")
+	}
+}
@@ -0,0 +1,636 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An airdrop pays a list of recipients from the node wallet. The list is split into
+// chunks, each paid by one send transaction sized well under the network's transaction
+// limit, and chunks are submitted as wallet outputs allow: inputs a pending transaction
+// already spends are left alone, so when the wallet holds few outputs the next chunks
+// wait for the change of earlier ones to confirm. Progress is saved to disk, so a
+// restarted node resumes where it stopped: submitted chunks are checked against the
+// chain, put back in the mempool if they fell out, and rebuilt only once one of their
+// inputs is spent elsewhere, when they can no longer confirm and so can't pay twice.
+
+const (
+	DefaultAirdropsPath       = "airdrops.json" // Airdrop progress
+	MaxAirdropRecipients      = 100000          // Recipients one airdrop may pay
+	MaxAirdropChunkRecipients = 250             // Outputs per airdrop transaction
+	MaxAirdropUploadBytes     = 16 << 20        // Largest recipient list accepted
+	AirdropCheckInterval      = 10 * time.Second
+)
+
+// Airdrop and chunk status
+const (
+	AirdropRunning   = "running"   // Chunks left to pay
+	AirdropCompleted = "completed" // Every chunk confirmed
+
+	AirdropChunkPending   = "pending"   // Not submitted yet
+	AirdropChunkSubmitted = "submitted" // In the mempool, waiting for a block
+	AirdropChunkConfirmed = "confirmed"
+)
+
+// AirdropRecipient is one payment of an airdrop
+type AirdropRecipient struct {
+	Address string `json:"address"` // Canonical form, with its address type
+	Amount  uint64 `json:"amount"`
+}
+
+// AirdropChunk is the set of recipients paid by one transaction
+type AirdropChunk struct {
+	First           int          `json:"first"` // Recipients[First : First+Count]
+	Count           int          `json:"count"`
+	Status          string       `json:"status"`
+	TxID            string       `json:"tx_id,omitempty"`
+	Tx              *Transaction `json:"transaction,omitempty"` // Kept to resubmit it
+	ConfirmedHeight uint64       `json:"confirmed_height,omitempty"`
+	Attempts        int          `json:"attempts"` // Transactions built for it
+}
+
+// Airdrop is a distribution of a token to a list of recipients
+type Airdrop struct {
+	ID         string              `json:"id"`
+	TokenID    string              `json:"token_id"`
+	Memo       string              `json:"memo,omitempty"`
+	Fee        uint64              `json:"fee,omitempty"` // Fixed fee per transaction, 0 = estimate
+	CreatedAt  int64               `json:"created_at"`
+	Status     string              `json:"status"`
+	LastError  string              `json:"last_error,omitempty"` // Why the next chunk is waiting
+	Recipients []*AirdropRecipient `json:"recipients"`
+	Chunks     []*AirdropChunk     `json:"chunks"`
+}
+
+// AirdropSummary is an airdrop's progress without its recipients
+type AirdropSummary struct {
+	ID         string `json:"id"`
+	TokenID    string `json:"token_id"`
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"created_at"`
+	Recipients int    `json:"recipients"`
+	Total      uint64 `json:"total"`
+	Paid       int    `json:"paid"`      // Recipients in confirmed chunks
+	Submitted  int    `json:"submitted"` // Recipients in chunks waiting for a block
+	Chunks     int    `json:"chunks"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+// AirdropRecipientStatus is the delivery status of one recipient
+type AirdropRecipientStatus struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+	Status  string `json:"status"`
+	TxID    string `json:"tx_id,omitempty"`
+	Height  uint64 `json:"height,omitempty"`
+}
+
+// Summary reports the airdrop's progress
+func (a *Airdrop) Summary() *AirdropSummary {
+	s := &AirdropSummary{
+		ID:         a.ID,
+		TokenID:    a.TokenID,
+		Status:     a.Status,
+		CreatedAt:  a.CreatedAt,
+		Recipients: len(a.Recipients),
+		Chunks:     len(a.Chunks),
+		LastError:  a.LastError,
+	}
+	for _, r := range a.Recipients {
+		s.Total += r.Amount
+	}
+	for _, chunk := range a.Chunks {
+		switch chunk.Status {
+		case AirdropChunkConfirmed:
+			s.Paid += chunk.Count
+		case AirdropChunkSubmitted:
+			s.Submitted += chunk.Count
+		}
+	}
+	return s
+}
+
+// RecipientStatus lists every recipient with the status of the chunk paying it
+func (a *Airdrop) RecipientStatus() []*AirdropRecipientStatus {
+	statuses := make([]*AirdropRecipientStatus, 0, len(a.Recipients))
+	for _, chunk := range a.Chunks {
+		for _, r := range a.Recipients[chunk.First : chunk.First+chunk.Count] {
+			status := &AirdropRecipientStatus{Address: r.Address, Amount: r.Amount, Status: chunk.Status, TxID: chunk.TxID, Height: chunk.ConfirmedHeight}
+			if addr, addrType, err := ParseAddress(r.Address); err == nil {
+				status.Address = addr.DisplayWithType(addrType)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// AirdropRow is a requested payment, before its address is resolved
+type AirdropRow struct {
+	Address string `json:"address"` // Address or address book label
+	Amount  uint64 `json:"amount"`
+}
+
+// ParseAirdropCSV reads address,amount rows. A first row whose amount isn't a number is
+// taken as a header; blank lines are skipped.
+func ParseAirdropCSV(r io.Reader) ([]AirdropRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []AirdropRow
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("line %d: expected address,amount, got %d fields", line, len(record))
+		}
+		amount, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[1])
+		}
+		rows = append(rows, AirdropRow{Address: strings.TrimSpace(record[0]), Amount: amount})
+		if len(rows) > MaxAirdropRecipients {
+			return nil, fmt.Errorf("at most %d recipients per airdrop", MaxAirdropRecipients)
+		}
+	}
+	return rows, nil
+}
+
+// AirdropManager runs airdrops from the node wallet
+type AirdropManager struct {
+	chain   *Blockchain
+	mempool *Mempool
+	wallet  *NodeWallet
+	path    string
+
+	mu       sync.Mutex
+	airdrops map[string]*Airdrop
+	running  sync.Mutex // Held by a processing pass
+
+	submit func(tx *Transaction) error // Adds a transaction to the mempool (replaced in tests)
+	wake   chan struct{}               // Starts a pass before the next tick
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewAirdropManager loads airdrops from path and starts paying the unfinished ones
+func NewAirdropManager(chain *Blockchain, mempool *Mempool, wallet *NodeWallet, path string) (*AirdropManager, error) {
+	m, err := newAirdropManager(chain, mempool, wallet, path)
+	if err != nil {
+		return nil, err
+	}
+	go m.loop()
+
+	running := 0
+	for _, a := range m.airdrops {
+		if a.Status == AirdropRunning {
+			running++
+		}
+	}
+	if running > 0 {
+		fmt.Printf("[Airdrop] Resuming %d unfinished airdrops\n", running)
+	}
+	return m, nil
+}
+
+// newAirdropManager loads airdrops without starting the loop
+func newAirdropManager(chain *Blockchain, mempool *Mempool, wallet *NodeWallet, path string) (*AirdropManager, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &AirdropManager{
+		chain:    chain,
+		mempool:  mempool,
+		wallet:   wallet,
+		path:     path,
+		airdrops: make(map[string]*Airdrop),
+		submit:   mempool.AddTransaction,
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		cancel()
+		return nil, fmt.Errorf("failed to read airdrops: %w", err)
+	}
+	if err == nil {
+		var airdrops []*Airdrop
+		if err := json.Unmarshal(data, &airdrops); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse airdrops: %w", err)
+		}
+		for _, a := range airdrops {
+			m.airdrops[a.ID] = a
+		}
+	}
+	return m, nil
+}
+
+// Create starts an airdrop of tokenID to recipients, which must already be resolved
+// and checked. The wallet must hold enough of the token for all of them.
+func (m *AirdropManager) Create(tokenID string, recipients []*AirdropRecipient, memo string, fee uint64) (*Airdrop, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	if len(recipients) > MaxAirdropRecipients {
+		return nil, fmt.Errorf("at most %d recipients per airdrop", MaxAirdropRecipients)
+	}
+
+	var total uint64
+	for i, r := range recipients {
+		if r.Amount == 0 {
+			return nil, fmt.Errorf("recipient %d has zero amount", i+1)
+		}
+		if total+r.Amount < total {
+			return nil, fmt.Errorf("total amount overflows")
+		}
+		total += r.Amount
+	}
+	if balance := m.spendable(tokenID); balance < total {
+		return nil, fmt.Errorf("insufficient balance of %s: have %d, airdrop needs %d", tokenID, balance, total)
+	}
+
+	outputs := make([]*TxOutput, len(recipients))
+	for i, r := range recipients {
+		output, err := m.output(tokenID, r)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i+1, err)
+		}
+		outputs[i] = output
+	}
+
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate airdrop ID: %w", err)
+	}
+	a := &Airdrop{
+		ID:         hex.EncodeToString(raw),
+		TokenID:    tokenID,
+		Memo:       memo,
+		Fee:        fee,
+		CreatedAt:  time.Now().Unix(),
+		Status:     AirdropRunning,
+		Recipients: recipients,
+		Chunks:     chunkOutputs(outputs, ActiveGenesis().BlockSizeLimits().MaxTxBytes/2),
+	}
+
+	m.mu.Lock()
+	m.airdrops[a.ID] = a
+	err := m.saveLocked()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[Airdrop] 🪂 Started airdrop %s: %d recipients in %d transactions\n", a.ID, len(recipients), len(a.Chunks))
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return m.Get(a.ID), nil
+}
+
+// chunkOutputs splits outputs into chunks of at most MaxAirdropChunkRecipients outputs
+// and maxBytes of encoded outputs, leaving the rest of a transaction for its inputs
+func chunkOutputs(outputs []*TxOutput, maxBytes int) []*AirdropChunk {
+	var chunks []*AirdropChunk
+	chunk := &AirdropChunk{Status: AirdropChunkPending}
+	size := 0
+	for i, output := range outputs {
+		data, _ := json.Marshal(output)
+		if chunk.Count > 0 && (chunk.Count >= MaxAirdropChunkRecipients || size+len(data) > maxBytes) {
+			chunks = append(chunks, chunk)
+			chunk = &AirdropChunk{First: i, Status: AirdropChunkPending}
+			size = 0
+		}
+		chunk.Count++
+		size += len(data)
+	}
+	if chunk.Count > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// output returns the output paying a recipient
+func (m *AirdropManager) output(tokenID string, r *AirdropRecipient) (*TxOutput, error) {
+	addr, addrType, err := ParseAddress(r.Address)
+	if err != nil {
+		return nil, err
+	}
+	var output *TxOutput
+	if tokenID == GetGenesisToken().TokenID {
+		output = CreateShadowOutput(addr, r.Amount)
+	} else if _, exists := m.chain.TokenRegistry().GetToken(tokenID); exists {
+		output = m.chain.TokenRegistry().NewTokenOutput(addr, r.Amount, tokenID, "custom", nil)
+	} else {
+		return nil, fmt.Errorf("unknown token %s", tokenID)
+	}
+	if addrType != AddressTypeWallet {
+		output.AddressType = addrType
+	}
+	return output, nil
+}
+
+// spendable returns the wallet's confirmed, unspent balance of tokenID
+func (m *AirdropManager) spendable(tokenID string) uint64 {
+	var balance uint64
+	for _, utxo := range m.availableUTXOs() {
+		if utxo.Output.TokenID == tokenID {
+			balance += utxo.Output.Amount
+		}
+	}
+	return balance
+}
+
+// availableUTXOs returns the wallet's unspent outputs no pending transaction spends
+func (m *AirdropManager) availableUTXOs() []*UTXO {
+	utxos, err := m.chain.GetUTXOStore().GetUTXOsByAddress(m.wallet.Address)
+	if err != nil {
+		return nil
+	}
+	pending := m.mempool.PendingSpends()
+	available := make([]*UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !utxo.IsSpent && utxo.Output.Vesting == nil && !pending[fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)] {
+			available = append(available, utxo)
+		}
+	}
+	return available
+}
+
+// Get returns a copy of an airdrop, or nil
+func (m *AirdropManager) Get(id string) *Airdrop {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.airdrops[id]
+	if !ok {
+		return nil
+	}
+	copied := *a
+	copied.Chunks = make([]*AirdropChunk, len(a.Chunks))
+	for i, chunk := range a.Chunks {
+		c := *chunk
+		copied.Chunks[i] = &c
+	}
+	return &copied
+}
+
+// List returns every airdrop's progress, newest first
+func (m *AirdropManager) List() []*AirdropSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summaries := make([]*AirdropSummary, 0, len(m.airdrops))
+	for _, a := range m.airdrops {
+		summaries = append(summaries, a.Summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].CreatedAt != summaries[j].CreatedAt {
+			return summaries[i].CreatedAt > summaries[j].CreatedAt
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+	return summaries
+}
+
+// loop advances running airdrops
+func (m *AirdropManager) loop() {
+	ticker := time.NewTicker(AirdropCheckInterval)
+	defer ticker.Stop()
+
+	m.process()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.process()
+		case <-m.wake:
+			m.process()
+		}
+	}
+}
+
+// process checks submitted chunks and submits pending ones, oldest airdrop first
+func (m *AirdropManager) process() {
+	if !m.running.TryLock() {
+		return
+	}
+	defer m.running.Unlock()
+
+	m.mu.Lock()
+	var running []*Airdrop
+	for _, a := range m.airdrops {
+		if a.Status == AirdropRunning {
+			running = append(running, a)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(running, func(i, j int) bool { return running[i].CreatedAt < running[j].CreatedAt })
+
+	// Chunks are mutated only here, under running; mu guards readers
+	for _, a := range running {
+		m.checkSubmitted(a)
+	}
+	available := m.availableUTXOs()
+	for _, a := range running {
+		available = m.submitPending(a, available)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.saveLocked(); err != nil {
+		fmt.Printf("[Airdrop] ⚠️  %v\n", err)
+	}
+}
+
+// checkSubmitted marks confirmed chunks, resubmits chunks that fell out of the mempool
+// and returns chunks that can no longer confirm to pending
+func (m *AirdropManager) checkSubmitted(a *Airdrop) {
+	store := m.chain.GetUTXOStore()
+	for _, chunk := range a.Chunks {
+		if chunk.Status != AirdropChunkSubmitted {
+			continue
+		}
+		if status, err := m.chain.TxChainStatus(chunk.TxID); err == nil && status != nil && status.Status != TxStatusOrphaned {
+			m.mu.Lock()
+			chunk.Status, chunk.ConfirmedHeight, chunk.Tx = AirdropChunkConfirmed, status.Height, nil
+			m.mu.Unlock()
+			continue
+		}
+		if m.mempool.HasTransaction(chunk.TxID) {
+			continue
+		}
+
+		// Out of the mempool and not in a block: resubmit it while its inputs are
+		// unspent, rebuild it once one is spent by another transaction
+		conflicted := false
+		for _, input := range chunk.Tx.Inputs {
+			if utxo, err := store.GetUTXO(input.PrevTxID, input.OutputIndex); err == nil && (utxo == nil || utxo.IsSpent) {
+				conflicted = true
+				break
+			}
+		}
+		if conflicted {
+			fmt.Printf("[Airdrop] Chunk %s of airdrop %s lost its inputs; rebuilding it\n", chunk.TxID[:16], a.ID)
+			m.mu.Lock()
+			chunk.Status, chunk.TxID, chunk.Tx = AirdropChunkPending, "", nil
+			m.mu.Unlock()
+			continue
+		}
+		if err := m.submit(chunk.Tx); err != nil {
+			m.setError(a, fmt.Sprintf("failed to resubmit %s: %v", chunk.TxID[:16], err))
+		}
+	}
+
+	done := true
+	for _, chunk := range a.Chunks {
+		done = done && chunk.Status == AirdropChunkConfirmed
+	}
+	if done {
+		m.mu.Lock()
+		a.Status, a.LastError = AirdropCompleted, ""
+		m.mu.Unlock()
+		fmt.Printf("[Airdrop] ✅ Airdrop %s completed: %d recipients paid\n", a.ID, len(a.Recipients))
+	}
+}
+
+// submitPending builds, signs and submits pending chunks from available outputs until
+// they run out, returning the outputs left
+func (m *AirdropManager) submitPending(a *Airdrop, available []*UTXO) []*UTXO {
+	limits := ActiveGenesis().BlockSizeLimits()
+	for _, chunk := range a.Chunks {
+		if chunk.Status != AirdropChunkPending {
+			continue
+		}
+
+		outputs := make([]*TxOutput, 0, chunk.Count)
+		for _, r := range a.Recipients[chunk.First : chunk.First+chunk.Count] {
+			output, err := m.output(a.TokenID, r)
+			if err != nil {
+				m.setError(a, err.Error())
+				return available
+			}
+			outputs = append(outputs, output)
+		}
+
+		// Build once to learn the weight, then again if the estimate pays too little for it
+		fee := a.Fee
+		var built *BuiltTransaction
+		var err error
+		for attempt := 0; attempt < 2; attempt++ {
+			built, err = BuildSendTransaction(m.chain.TokenRegistry(), available, outputs, m.wallet.Address, fee, m.mempool.MinRelayFee())
+			if err != nil {
+				break
+			}
+			if a.Memo != "" {
+				built.Transaction.Data = []byte(a.Memo)
+			}
+			minFee := limits.MinFee(TxWeight(built.Transaction) + WeightPerInput)
+			if a.Fee > 0 || built.Fee >= minFee {
+				break
+			}
+			fee = minFee
+		}
+		if err != nil {
+			// Wait for the change of submitted chunks, or for the wallet to be topped up
+			m.setError(a, fmt.Sprintf("waiting for funds: %v", err))
+			return available
+		}
+
+		tx := built.Transaction
+		if err := m.wallet.SignTransaction(tx); err != nil {
+			m.setError(a, fmt.Sprintf("failed to sign: %v", err))
+			return available
+		}
+		txID, _ := tx.ID()
+		if err := m.submit(tx); err != nil {
+			m.setError(a, fmt.Sprintf("failed to submit: %v", err))
+			return available
+		}
+
+		m.mu.Lock()
+		chunk.Status, chunk.TxID, chunk.Tx = AirdropChunkSubmitted, txID, tx
+		chunk.Attempts++
+		a.LastError = ""
+		m.mu.Unlock()
+		fmt.Printf("[Airdrop] Submitted %d payments of airdrop %s in %s\n", chunk.Count, a.ID, txID[:16])
+
+		spent := make(map[string]bool, len(built.Inputs))
+		for _, utxo := range built.Inputs {
+			spent[fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)] = true
+		}
+		left := available[:0:0]
+		for _, utxo := range available {
+			if !spent[fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)] {
+				left = append(left, utxo)
+			}
+		}
+		available = left
+	}
+	return available
+}
+
+// setError records why an airdrop isn't progressing
+func (m *AirdropManager) setError(a *Airdrop, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a.LastError != reason {
+		fmt.Printf("[Airdrop] Airdrop %s: %s\n", a.ID, reason)
+	}
+	a.LastError = reason
+}
+
+// saveLocked writes every airdrop to disk. Must be called with mu held.
+func (m *AirdropManager) saveLocked() error {
+	airdrops := make([]*Airdrop, 0, len(m.airdrops))
+	for _, a := range m.airdrops {
+		airdrops = append(airdrops, a)
+	}
+	sort.Slice(airdrops, func(i, j int) bool { return airdrops[i].CreatedAt < airdrops[j].CreatedAt })
+
+	data, err := json.Marshal(airdrops)
+	if err != nil {
+		return fmt.Errorf("failed to marshal airdrops: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write airdrops: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to write airdrops: %w", err)
+	}
+	return nil
+}
+
+// Close stops the airdrop loop and saves progress
+func (m *AirdropManager) Close() error {
+	m.cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.saveLocked()
+}
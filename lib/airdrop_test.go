@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAirdrop(t *testing.T) {
+	dir := t.TempDir()
+	bc, err := NewBlockchain(filepath.Join(dir, "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	wallet := &NodeWallet{KeyPair: kp, Address: kp.Address()}
+	store := bc.GetUTXOStore()
+	for _, funding := range []string{"airdrop-funding-a", "airdrop-funding-b", "airdrop-funding-c"} {
+		if err := store.AddUTXO(&UTXO{TxID: funding, Output: CreateShadowOutput(wallet.Address, 1000000)}); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+
+	// Recipients come from a CSV with a header row
+	var csv strings.Builder
+	csv.WriteString("address,amount\n")
+	for i := 0; i < MaxAirdropChunkRecipients+50; i++ {
+		recipient, _ := GenerateKeyPair()
+		fmt.Fprintf(&csv, "%s,%d\n", recipient.Address().String(), 100+i)
+	}
+	rows, err := ParseAirdropCSV(strings.NewReader(csv.String()))
+	if err != nil || len(rows) != MaxAirdropChunkRecipients+50 {
+		t.Fatalf("Expected %d rows, got %d (%v)", MaxAirdropChunkRecipients+50, len(rows), err)
+	}
+	if _, err := ParseAirdropCSV(strings.NewReader("address,amount\nabc,12\nabc,twelve\n")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected a bad amount on line 3 to be refused, got %v", err)
+	}
+	var recipients []*AirdropRecipient
+	for _, row := range rows {
+		addr, addrType, _ := ParseAddress(row.Address)
+		recipients = append(recipients, &AirdropRecipient{Address: addr.StringWithType(addrType), Amount: row.Amount})
+	}
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	path := filepath.Join(dir, DefaultAirdropsPath)
+	m, err := newAirdropManager(bc, mp, wallet, path)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	var submitted []*Transaction
+	m.submit = func(tx *Transaction) error {
+		txID, _ := tx.ID()
+		mp.entries[txID] = &MempoolEntry{Tx: tx}
+		submitted = append(submitted, tx)
+		return nil
+	}
+
+	if _, err := m.Create(GetGenesisToken().TokenID, []*AirdropRecipient{{Address: recipients[0].Address, Amount: 5000000}}, "", 0); err == nil {
+		t.Error("Expected an airdrop larger than the balance to be refused")
+	}
+	airdrop, err := m.Create(GetGenesisToken().TokenID, recipients, "launch", 0)
+	if err != nil {
+		t.Fatalf("Failed to create airdrop: %v", err)
+	}
+	if len(airdrop.Chunks) != 2 || airdrop.Chunks[1].First != MaxAirdropChunkRecipients {
+		t.Fatalf("Expected 2 chunks split at %d, got %+v", MaxAirdropChunkRecipients, airdrop.Chunks)
+	}
+
+	// Each chunk is paid by its own signed transaction carrying the memo
+	m.process()
+	if len(submitted) != 2 {
+		t.Fatalf("Expected both chunks submitted, got %d", len(submitted))
+	}
+	for _, tx := range submitted {
+		if string(tx.Data) != "launch" || len(tx.Signature) == 0 {
+			t.Errorf("Expected a signed transaction with the memo, got data %q", tx.Data)
+		}
+	}
+	first, _ := submitted[0].ID()
+	second, _ := submitted[1].ID()
+
+	// The first chunk confirms; the second falls out of the mempool after its input is
+	// spent elsewhere, so it is rebuilt from the remaining output
+	if err := store.StoreTransaction(submitted[0], 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	if err := bc.AddBlock(bc.ProposeBlock([]string{first}, "airdrop-test-proposer", nil), nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	delete(mp.entries, first)
+	delete(mp.entries, second)
+	if err := store.SpendUTXO(submitted[1].Inputs[0].PrevTxID, submitted[1].Inputs[0].OutputIndex, 1); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	m.process()
+	if len(submitted) != 3 {
+		t.Fatalf("Expected the second chunk rebuilt, got %d submissions", len(submitted))
+	}
+	if submitted[2].Inputs[0].PrevTxID == submitted[1].Inputs[0].PrevTxID {
+		t.Error("Expected the rebuilt chunk to spend a different output")
+	}
+
+	// Progress survives a restart
+	m, err = newAirdropManager(bc, mp, wallet, path)
+	if err != nil {
+		t.Fatalf("Failed to reload manager: %v", err)
+	}
+	reloaded := m.Get(airdrop.ID)
+	if reloaded == nil {
+		t.Fatal("Airdrop lost on reload")
+	}
+	if chunk := reloaded.Chunks[0]; chunk.Status != AirdropChunkConfirmed || chunk.TxID != first || chunk.ConfirmedHeight != 1 {
+		t.Errorf("Expected chunk 0 confirmed at 1, got %s %s at %d", chunk.Status, chunk.TxID, chunk.ConfirmedHeight)
+	}
+	third, _ := submitted[2].ID()
+	if chunk := reloaded.Chunks[1]; chunk.Status != AirdropChunkSubmitted || chunk.TxID != third || chunk.Attempts != 2 {
+		t.Errorf("Expected chunk 1 resubmitted as %s, got %s %s after %d attempts", third, chunk.Status, chunk.TxID, chunk.Attempts)
+	}
+
+	summary := reloaded.Summary()
+	if summary.Status != AirdropRunning || summary.Paid != MaxAirdropChunkRecipients || summary.Submitted != 50 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	statuses := reloaded.RecipientStatus()
+	if len(statuses) != len(recipients) || statuses[0].Status != AirdropChunkConfirmed || statuses[len(statuses)-1].TxID != third {
+		t.Errorf("Unexpected recipient statuses: first %+v, last %+v", statuses[0], statuses[len(statuses)-1])
+	}
+}
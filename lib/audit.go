@@ -0,0 +1,300 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxAuditIssues caps the discrepancies listed per check (the count is always exact)
+const MaxAuditIssues = 50
+
+// Audit check names
+const (
+	AuditBlockLinks   = "block_links"
+	AuditSpentIndex   = "spent_index"
+	AuditBalanceIndex = "balance_index"
+	AuditTokenSupply  = "token_supply"
+	AuditPools        = "pool_reserves"
+)
+
+// AuditCheck is the result of one invariant check
+type AuditCheck struct {
+	Name       string   `json:"name"`
+	Passed     bool     `json:"passed"`
+	IssueCount int      `json:"issue_count"`
+	Issues     []string `json:"issues,omitempty"` // First MaxAuditIssues discrepancies
+}
+
+// AuditReport is the result of a full chain consistency audit
+type AuditReport struct {
+	Height     uint64        `json:"height"`
+	Passed     bool          `json:"passed"`
+	Checks     []*AuditCheck `json:"checks"`
+	DurationMs int64         `json:"duration_ms"`
+	AuditedAt  int64         `json:"audited_at"`
+}
+
+// issue records a discrepancy
+func (c *AuditCheck) issue(format string, args ...interface{}) {
+	c.IssueCount++
+	if len(c.Issues) < MaxAuditIssues {
+		c.Issues = append(c.Issues, fmt.Sprintf(format, args...))
+	}
+}
+
+// utxoAudit is what the audit needs from one pass over the UTXO set
+type utxoAudit struct {
+	unspentByToken map[string]uint64
+	spent          map[string]bool // outpoint -> IsSpent
+}
+
+// scanUTXOs collects per-token unspent totals and spent flags in one pass
+func (store *UTXOStore) scanUTXOs() (*utxoAudit, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	result := &utxoAudit{
+		unspentByToken: make(map[string]uint64),
+		spent:          make(map[string]bool),
+	}
+
+	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil || utxo.Output == nil {
+			continue
+		}
+		result.spent[strings.TrimPrefix(string(iterator.Key()), UTXOPrefix)] = utxo.IsSpent
+		if !utxo.IsSpent {
+			result.unspentByToken[utxo.Output.TokenID] += utxo.Output.Amount
+		}
+	}
+	return result, nil
+}
+
+// spentIndexOutpoints returns the outpoints recorded in the spent index
+func (store *UTXOStore) spentIndexOutpoints() ([]string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(SpentPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var outpoints []string
+	for ; iterator.Valid(); iterator.Next() {
+		outpoints = append(outpoints, strings.TrimPrefix(string(iterator.Key()), SpentPrefix))
+	}
+	return outpoints, nil
+}
+
+// Audit verifies chain invariants and reports every discrepancy it finds:
+// block hashes and links, the spent index, the balance index, per-token supply
+// conservation, and liquidity pool accounting. It only reads state.
+func (bc *Blockchain) Audit() (*AuditReport, error) {
+	started := time.Now()
+
+	bc.chainLock.RLock()
+	blocks := make([]*Block, len(bc.blocks))
+	copy(blocks, bc.blocks)
+	bc.chainLock.RUnlock()
+
+	report := &AuditReport{AuditedAt: started.Unix()}
+	if len(blocks) > 0 {
+		report.Height = blocks[len(blocks)-1].Index
+	}
+
+	// Every block sits at its index, hashes correctly, and links to its parent
+	links := &AuditCheck{Name: AuditBlockLinks}
+	var issuedShadow uint64
+	shadowID := GetGenesisToken().TokenID
+	for i, block := range blocks {
+		if block.Index != uint64(i) {
+			links.issue("block at position %d has index %d", i, block.Index)
+		}
+		if hash := bc.calculateBlockHash(block); block.Hash != hash {
+			links.issue("block %d hash %s does not match contents (%s)", block.Index, block.Hash, hash)
+		}
+		if i > 0 && block.PreviousHash != blocks[i-1].Hash {
+			links.issue("block %d does not link to block %d", block.Index, blocks[i-1].Index)
+		}
+		if block.Coinbase != nil {
+			for _, output := range block.Coinbase.Outputs {
+				if output.TokenID == shadowID {
+					issuedShadow += output.Amount
+				}
+			}
+		}
+	}
+	report.Checks = append(report.Checks, links)
+
+	utxos, err := bc.utxoStore.scanUTXOs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Spent outputs are in the spent index and unspent ones are not
+	spentCheck := &AuditCheck{Name: AuditSpentIndex}
+	spentIndexed, err := bc.utxoStore.spentIndexOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	inSpentIndex := make(map[string]bool, len(spentIndexed))
+	for _, outpoint := range spentIndexed {
+		inSpentIndex[outpoint] = true
+		isSpent, exists := utxos.spent[outpoint]
+		if !exists {
+			spentCheck.issue("spent index references missing UTXO %s", outpoint)
+		} else if !isSpent {
+			spentCheck.issue("spent index references unspent UTXO %s", outpoint)
+		}
+	}
+	for outpoint, isSpent := range utxos.spent {
+		if isSpent && !inSpentIndex[outpoint] {
+			spentCheck.issue("spent UTXO %s missing from spent index", outpoint)
+		}
+	}
+	report.Checks = append(report.Checks, spentCheck)
+
+	// Address and balance indices agree with the UTXO set
+	balanceCheck := &AuditCheck{Name: AuditBalanceIndex}
+	mismatches, err := bc.utxoStore.indexMismatches()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mismatches {
+		balanceCheck.issue("%s", m)
+	}
+	report.Checks = append(report.Checks, balanceCheck)
+
+	// Tokens are conserved: unspent + pool reserves + open offers = minted - melted - burned
+	reserves := make(map[string]uint64)
+	for _, pool := range bc.poolRegistry.GetAllPools() {
+		reserves[pool.TokenA] += pool.ReserveA
+		reserves[pool.TokenB] += pool.ReserveB
+	}
+
+	supplyCheck := &AuditCheck{Name: AuditTokenSupply}
+	var lockedInTokens uint64
+	for _, token := range bc.tokenRegistry.ListTokens() {
+		if token.IsBaseToken() {
+			continue
+		}
+		if released := token.CalculateMeltValue(token.TotalMelted); token.LockedShadow > released {
+			lockedInTokens += token.LockedShadow - released
+		}
+		if token.TotalMelted+token.TotalBurned > token.Issued() {
+			supplyCheck.issue("%s melted %d and burned %d of %d issued supply", token.Ticker, token.TotalMelted, token.TotalBurned, token.Issued())
+			continue
+		}
+		offers, _ := bc.utxoStore.OfferLocked(token.TokenID)
+		outstanding := token.Issued() - token.TotalMelted - token.TotalBurned
+		accounted := utxos.unspentByToken[token.TokenID] + reserves[token.TokenID] + offers
+		if accounted != outstanding {
+			supplyCheck.issue("%s (%s): unspent %d + pools %d + offers %d = %d, minted - melted - burned = %d",
+				token.Ticker, token.TokenID, utxos.unspentByToken[token.TokenID], reserves[token.TokenID],
+				offers, accounted, outstanding)
+		}
+	}
+	for tokenID, amount := range utxos.unspentByToken {
+		if tokenID == shadowID {
+			continue
+		}
+		if _, exists := bc.tokenRegistry.GetToken(tokenID); !exists {
+			supplyCheck.issue("%d unspent units of unregistered token %s", amount, tokenID)
+		}
+	}
+
+	// SHADOW can't be created outside coinbases; fees make the difference, so only excess is an error
+	shadowOffers, _ := bc.utxoStore.OfferLocked(shadowID)
+	accountedShadow := utxos.unspentByToken[shadowID] + reserves[shadowID] + shadowOffers + lockedInTokens
+	if accountedShadow > issuedShadow {
+		supplyCheck.issue("SHADOW: unspent %d + pools %d + offers %d + token backing %d = %d exceeds %d issued by coinbases",
+			utxos.unspentByToken[shadowID], reserves[shadowID], shadowOffers, lockedInTokens, accountedShadow, issuedShadow)
+	}
+	report.Checks = append(report.Checks, supplyCheck)
+
+	// Pool invariants and LP token accounting
+	poolCheck := &AuditCheck{Name: AuditPools}
+	for _, pool := range bc.poolRegistry.GetAllPools() {
+		id := pool.PoolID
+		if len(id) > 16 {
+			id = id[:16]
+		}
+		if pool.LPTokenSupply > 0 && (pool.ReserveA == 0 || pool.ReserveB == 0) {
+			poolCheck.issue("pool %s has LP supply %d but an empty reserve (%d/%d)", id, pool.LPTokenSupply, pool.ReserveA, pool.ReserveB)
+		}
+		if k := CalculateK(pool.ReserveA, pool.ReserveB); pool.K != k {
+			poolCheck.issue("pool %s has k %d, reserves give %d", id, pool.K, k)
+		}
+		lpToken, exists := bc.tokenRegistry.GetToken(pool.LPTokenID)
+		if !exists {
+			poolCheck.issue("pool %s LP token %s is not registered", id, pool.LPTokenID)
+			continue
+		}
+		if lpToken.TotalSupply-lpToken.TotalMelted != pool.LPTokenSupply {
+			poolCheck.issue("pool %s LP supply %d, LP token outstanding %d", id, pool.LPTokenSupply, lpToken.TotalSupply-lpToken.TotalMelted)
+		}
+	}
+	report.Checks = append(report.Checks, poolCheck)
+
+	report.Passed = true
+	for _, check := range report.Checks {
+		check.Passed = check.IssueCount == 0
+		report.Passed = report.Passed && check.Passed
+	}
+	report.DurationMs = time.Since(started).Milliseconds()
+	return report, nil
+}
+
+// Print writes a human-readable audit summary
+func (r *AuditReport) Print() {
+	fmt.Printf("[Audit] Chain height %d, audited in %dms\n", r.Height, r.DurationMs)
+	for _, check := range r.Checks {
+		if check.Passed {
+			fmt.Printf("[Audit] ✅ %s\n", check.Name)
+			continue
+		}
+		fmt.Printf("[Audit] ❌ %s: %d issues\n", check.Name, check.IssueCount)
+		for _, issue := range check.Issues {
+			fmt.Printf("         - %s\n", issue)
+		}
+		if check.IssueCount > len(check.Issues) {
+			fmt.Printf("         ... and %d more\n", check.IssueCount-len(check.Issues))
+		}
+	}
+	if r.Passed {
+		fmt.Printf("[Audit] ✅ All invariants hold\n")
+	} else {
+		fmt.Printf("[Audit] ❌ Discrepancies found\n")
+	}
+}
+
+// RunAudit opens the local chain, audits it, and prints the report. Returns an error
+// if the audit could not run or found discrepancies.
+func RunAudit() error {
+	chain, err := NewBlockchain(DataPath("blockchain"))
+	if err != nil {
+		return fmt.Errorf("failed to open blockchain: %w", err)
+	}
+	defer chain.Close()
+
+	report, err := chain.Audit()
+	if err != nil {
+		return fmt.Errorf("audit failed: %w", err)
+	}
+	report.Print()
+	if !report.Passed {
+		return fmt.Errorf("audit found discrepancies")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// auditCheck returns the named check from a report
+func auditCheck(t *testing.T, report *AuditReport, name string) *AuditCheck {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("Audit report has no %s check", name)
+	return nil
+}
+
+func TestAuditDetectsDrift(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	for i := 0; i < 3; i++ {
+		prev := bc.GetLatestBlock()
+		block := &Block{
+			Index:     prev.Index + 1,
+			Timestamp: prev.Timestamp + 1,
+			Coinbase: &Transaction{
+				Version:   1,
+				TxType:    TxTypeCoinbase,
+				Outputs:   []*TxOutput{CreateShadowOutput(kp.Address(), 100)},
+				Timestamp: int64(i),
+			},
+			PreviousHash: prev.Hash,
+			Proposer:     "audit-test-proposer",
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		if err := bc.AddBlock(block, nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	report, err := bc.Audit()
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !report.Passed || report.Height != 3 {
+		t.Fatalf("Expected clean audit at height 3, got %+v", report)
+	}
+
+	// A spent flag without a spent index entry, and a tampered block
+	store := bc.GetUTXOStore()
+	coinbaseID, _ := bc.GetBlock(1).Coinbase.ID()
+	utxo, _ := store.GetUTXO(coinbaseID, 0)
+	utxo.IsSpent = true
+	data, _ := json.Marshal(utxo)
+	if err := store.db.Set([]byte(UTXOPrefix+coinbaseID+":0"), data); err != nil {
+		t.Fatalf("Failed to corrupt UTXO: %v", err)
+	}
+	store.ClearCache()
+	bc.GetBlock(2).Proposer = "tampered"
+
+	report, err = bc.Audit()
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if report.Passed {
+		t.Fatal("Expected audit to report discrepancies")
+	}
+	if c := auditCheck(t, report, AuditBlockLinks); c.Passed {
+		t.Fatal("Expected tampered block to be reported")
+	}
+	if c := auditCheck(t, report, AuditSpentIndex); c.IssueCount != 1 {
+		t.Fatalf("Expected 1 spent index issue, got %d: %v", c.IssueCount, c.Issues)
+	}
+	if c := auditCheck(t, report, AuditBalanceIndex); c.Passed {
+		t.Fatal("Expected stale balance index to be reported")
+	}
+}
@@ -0,0 +1,295 @@
+package lib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backups bundle what can't be rebuilt from the network: the wallet file, which holds the
+// key that signs this node's blocks, the node's wallet bookkeeping (issued receive
+// addresses, tracked transactions, pool state) and a checkpoint of the chain it was
+// taken at, so a restored node can check it rejoined the same chain. Blocks and the UTXO
+// set are left to chain archives (see ExportArchive), which are much larger. Backups are
+// gzipped tarballs written to a directory, where only the newest few are kept, and
+// optionally uploaded to an S3-compatible bucket, whose lifecycle rules handle retention.
+
+const (
+	DefaultBackupDir    = "backups" // Under the data directory
+	DefaultBackupKeep   = 7         // Backups kept in the backup directory
+	backupCheckpoint    = "checkpoint.json"
+	backupPrefix        = "backup-"
+	backupSuffix        = ".tar.gz"
+	backupUploadTimeout = 5 * time.Minute
+)
+
+// ErrBackupRunning is returned when a backup is requested while one is being taken
+var ErrBackupRunning = errors.New("a backup is already running")
+
+// BackupConfig sets when and where backups are taken
+type BackupConfig struct {
+	Interval time.Duration // Between scheduled backups, 0 = only on request
+	Dir      string        // Where backups are written
+	Keep     int           // Newest backups kept in Dir
+	S3       *S3Target     // Also upload each backup here, nil = local only
+	DataDir  string        // Where the node's files are read from, "" = the working directory
+}
+
+// BackupCheckpoint records the chain a backup was taken at
+type BackupCheckpoint struct {
+	ChainID     string `json:"chain_id"`
+	GenesisHash string `json:"genesis_hash"`
+	Height      uint64 `json:"height"`
+	BlockHash   string `json:"block_hash"`
+	StateHash   string `json:"state_hash,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// BackupResult describes a backup that was taken
+type BackupResult struct {
+	Path       string           `json:"path"`
+	Files      []string         `json:"files"`
+	Bytes      int64            `json:"bytes"`
+	SHA256     string           `json:"sha256"`
+	Checkpoint BackupCheckpoint `json:"checkpoint"`
+	UploadedTo string           `json:"uploaded_to,omitempty"`
+	Removed    []string         `json:"removed,omitempty"` // Old backups deleted by retention
+}
+
+// BackupScheduler takes backups on an interval and on request
+type BackupScheduler struct {
+	chain  *Blockchain
+	wallet *NodeWallet // nil on read-only nodes
+	config BackupConfig
+	files  []string // Node files backed up when present, relative to the data directory
+
+	running sync.Mutex // Held while a backup is taken
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewBackupScheduler creates a scheduler; Start begins the interval backups
+func NewBackupScheduler(chain *Blockchain, wallet *NodeWallet, config BackupConfig) *BackupScheduler {
+	if config.Keep < 1 {
+		config.Keep = DefaultBackupKeep
+	}
+	return &BackupScheduler{
+		chain:  chain,
+		wallet: wallet,
+		config: config,
+		files:  []string{DefaultReceiveAddressesPath, DefaultWalletTxsPath, DefaultPoolStatePath},
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start takes a backup every interval until Close. Without an interval, backups are only
+// taken on request.
+func (bs *BackupScheduler) Start() {
+	if bs.config.Interval <= 0 {
+		return
+	}
+
+	fmt.Printf("[Backup] Scheduled backups every %v to %s\n", bs.config.Interval, bs.config.Dir)
+	go func() {
+		ticker := time.NewTicker(bs.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bs.stop:
+				return
+			case <-ticker.C:
+				if _, err := bs.RunNow(); err != nil && !errors.Is(err, ErrBackupRunning) {
+					fmt.Printf("[Backup] ❌ Scheduled backup failed: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the scheduled backups
+func (bs *BackupScheduler) Close() {
+	bs.once.Do(func() { close(bs.stop) })
+}
+
+// RunNow takes a backup, applies retention and uploads it if a bucket is configured. It
+// returns ErrBackupRunning if another backup is being taken.
+func (bs *BackupScheduler) RunNow() (*BackupResult, error) {
+	if !bs.running.TryLock() {
+		return nil, ErrBackupRunning
+	}
+	defer bs.running.Unlock()
+
+	result, err := bs.write()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[Backup] 💾 Wrote %s (%d files, height %d)\n", result.Path, len(result.Files), result.Checkpoint.Height)
+
+	// The new backup is written before old ones go, so there's always at least one
+	result.Removed, err = pruneBackups(bs.config.Dir, bs.config.Keep)
+	if err != nil {
+		fmt.Printf("[Backup] ⚠️  Failed to remove old backups: %v\n", err)
+	}
+
+	if bs.config.S3 != nil {
+		uploaded, err := bs.config.S3.Upload(result.Path, backupUploadTimeout)
+		if err != nil {
+			return result, fmt.Errorf("backup written to %s but upload failed: %w", result.Path, err)
+		}
+		result.UploadedTo = uploaded
+		fmt.Printf("[Backup] ☁️  Uploaded %s\n", uploaded)
+	}
+	return result, nil
+}
+
+// checkpoint describes the chain tip
+func (bs *BackupScheduler) checkpoint() BackupCheckpoint {
+	cp := BackupCheckpoint{ChainID: ActiveGenesis().ChainID, CreatedAt: time.Now().Unix()}
+	if genesis := bs.chain.GetBlock(0); genesis != nil {
+		cp.GenesisHash = genesis.Hash
+	}
+	if tip := bs.chain.GetLatestBlock(); tip != nil {
+		cp.Height, cp.BlockHash = tip.Index, tip.Hash
+		cp.StateHash, _ = bs.chain.GetUTXOStore().StateHash(tip.Index)
+	}
+	return cp
+}
+
+// write creates the backup tarball in the backup directory
+func (bs *BackupScheduler) write() (*BackupResult, error) {
+	if err := os.MkdirAll(bs.config.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	result := &BackupResult{Checkpoint: bs.checkpoint()}
+	name := fmt.Sprintf("%s%s-%d%s", backupPrefix, time.Unix(result.Checkpoint.CreatedAt, 0).UTC().Format("20060102-150405"),
+		result.Checkpoint.Height, backupSuffix)
+	result.Path = filepath.Join(bs.config.Dir, name)
+
+	// Written under a temporary name so retention and uploads never see a partial backup
+	tmp := result.Path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, hash))
+	tw := tar.NewWriter(gz)
+	err = bs.writeEntries(tw, result)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := os.Rename(tmp, result.Path); err != nil {
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+	if info, err := os.Stat(result.Path); err == nil {
+		result.Bytes = info.Size()
+	}
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return result, nil
+}
+
+// writeEntries adds the checkpoint, the wallet file and the node files that exist
+func (bs *BackupScheduler) writeEntries(tw *tar.Writer, result *BackupResult) error {
+	checkpoint, err := json.MarshalIndent(result.Checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := addTarFile(tw, backupCheckpoint, checkpoint); err != nil {
+		return err
+	}
+	result.Files = append(result.Files, backupCheckpoint)
+
+	if bs.wallet != nil && bs.wallet.Path != "" {
+		data, err := os.ReadFile(bs.wallet.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read wallet: %w", err)
+		}
+		name := "wallet/" + filepath.Base(bs.wallet.Path)
+		if err := addTarFile(tw, name, data); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, name)
+	}
+
+	for _, name := range bs.files {
+		data, err := os.ReadFile(dataPathIn(bs.config.DataDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if err := addTarFile(tw, name, data); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, name)
+	}
+	return nil
+}
+
+// addTarFile writes one file into a tarball
+func addTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// pruneBackups deletes all but the newest keep backups in dir. Backup names start with
+// their UTC time, so name order is age order.
+func pruneBackups(dir string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= keep {
+		return nil, nil
+	}
+	sort.Strings(backups)
+
+	var removed []string
+	for _, name := range backups[:len(backups)-keep] {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// S3Target uploads backups to an S3-compatible bucket (AWS S3, MinIO, R2, ...) with
+// AWS Signature Version 4. The URL is path style, https://host/bucket[/prefix], which
+// every S3-compatible service accepts.
+type S3Target struct {
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3Target checks the bucket URL and credentials
+func NewS3Target(rawURL, region, accessKey, secretKey string) (*S3Target, error) {
+	endpoint, err := url.Parse(strings.TrimRight(rawURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URL: %w", err)
+	}
+	if endpoint.Scheme != "https" && endpoint.Scheme != "http" {
+		return nil, fmt.Errorf("S3 URL must be http or https, got %q", endpoint.Scheme)
+	}
+	if endpoint.Host == "" || strings.Trim(endpoint.Path, "/") == "" {
+		return nil, fmt.Errorf("S3 URL must name a host and bucket: https://host/bucket[/prefix]")
+	}
+	if endpoint.RawQuery != "" || endpoint.Fragment != "" {
+		return nil, fmt.Errorf("S3 URL must not have a query or fragment")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 uploads need SHADOWY_BACKUP_S3_ACCESS_KEY and SHADOWY_BACKUP_S3_SECRET_KEY")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Target{
+		endpoint:  endpoint,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: backupUploadTimeout},
+	}, nil
+}
+
+// Upload PUTs the file at path into the bucket under its base name and returns the
+// object URL
+func (s *S3Target) Upload(path string, timeout time.Duration) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	object := *s.endpoint
+	object.Path = s.endpoint.Path + "/" + filepath.Base(path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, object.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	s.sign(req, data, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return object.String(), nil
+}
+
+// sign adds the AWS Signature Version 4 headers for a request with this payload
+func (s *S3Target) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256.Sum256(payload)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	const signedHeaders = "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // No query
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + hex.EncodeToString(payloadHash[:]),
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupScheduler(t *testing.T) {
+	dir := t.TempDir()
+	bc, err := NewBlockchain(filepath.Join(dir, "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	walletPath := filepath.Join(dir, "wallet", "default.json")
+	os.MkdirAll(filepath.Dir(walletPath), 0700)
+	if err := os.WriteFile(walletPath, []byte(`{"address": "backup-test"}`), 0600); err != nil {
+		t.Fatalf("Failed to write wallet: %v", err)
+	}
+
+	// The bucket receives each backup, signed
+	var uploaded []byte
+	var authorization string
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/node-backups/prod/backup-") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer bucket.Close()
+	target, err := NewS3Target(bucket.URL+"/node-backups/prod", "", "AKIDEXAMPLE", "secret")
+	if err != nil {
+		t.Fatalf("Failed to configure bucket: %v", err)
+	}
+	if target.client.Timeout != backupUploadTimeout {
+		t.Errorf("Expected uploads bounded by %s, got %s", backupUploadTimeout, target.client.Timeout)
+	}
+
+	backupDir := filepath.Join(dir, "backups")
+	os.MkdirAll(backupDir, 0700)
+	for _, old := range []string{"backup-20200101-000000-1.tar.gz", "backup-20200102-000000-2.tar.gz", "notes.txt"} {
+		os.WriteFile(filepath.Join(backupDir, old), []byte("old"), 0600)
+	}
+
+	bs := NewBackupScheduler(bc, &NodeWallet{Path: walletPath}, BackupConfig{Dir: backupDir, Keep: 2, S3: target})
+	result, err := bs.RunNow()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// Retention keeps the newest two backups and leaves other files alone
+	for name, want := range map[string]bool{
+		"backup-20200101-000000-1.tar.gz": false,
+		"backup-20200102-000000-2.tar.gz": true,
+		"notes.txt":                       true,
+		filepath.Base(result.Path):        true,
+	} {
+		if _, err := os.Stat(filepath.Join(backupDir, name)); (err == nil) != want {
+			t.Errorf("Expected %s present=%v", name, want)
+		}
+	}
+	if len(result.Removed) != 1 {
+		t.Errorf("Expected one old backup removed, got %v", result.Removed)
+	}
+
+	// The tarball holds the checkpoint and the wallet
+	file, err := os.Open(result.Path)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Backup is not gzipped: %v", err)
+	}
+	contents := make(map[string]string)
+	for tr := tar.NewReader(gz); ; {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Backup is not a tarball: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		contents[header.Name] = string(data)
+	}
+	if contents["wallet/default.json"] != `{"address": "backup-test"}` {
+		t.Errorf("Expected the wallet in the backup, got %v", contents)
+	}
+	if genesis := bc.GetBlock(0); genesis == nil || !strings.Contains(contents[backupCheckpoint], genesis.Hash) {
+		t.Errorf("Expected the checkpoint to name the genesis block, got %s", contents[backupCheckpoint])
+	}
+
+	data, _ := os.ReadFile(result.Path)
+	if string(uploaded) != string(data) || result.UploadedTo == "" {
+		t.Error("Expected the backup uploaded to the bucket")
+	}
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"+time.Now().UTC().Format("20060102")+"/us-east-1/s3/aws4_request") {
+		t.Errorf("Unexpected authorization %q", authorization)
+	}
+
+	// One backup at a time
+	bs.running.Lock()
+	if _, err := bs.RunNow(); err != ErrBackupRunning {
+		t.Errorf("Expected ErrBackupRunning, got %v", err)
+	}
+	bs.running.Unlock()
+
+	if _, err := NewS3Target("https://s3.example.com", "", "a", "b"); err == nil {
+		t.Error("Expected a bucket URL without a bucket to be refused")
+	}
+}
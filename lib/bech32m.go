@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Bech32m (BIP-350) is an alternative address encoding that is easier to transcribe and
+// understood by common tooling. The human-readable part is the address type letter
+// followed by the network: "sshadow1..." is a wallet address on the built-in network,
+// "lshadow1..." a liquidity address. Custom networks use their chain ID (lowercase
+// letters and digits only). String() stays the hex format, which storage indices are
+// keyed by; Display() follows the configured display format for API output.
+
+const (
+	AddressFormatHex     = "hex"     // S + hex + Luhn (default)
+	AddressFormatBech32m = "bech32m" // e.g. sshadow1...
+
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32mConst  = 0x2bc830a3
+	maxChainHRP   = 20
+)
+
+// Address format settings, set once at startup by SetAddressFormat
+var (
+	addressDisplayFormat = AddressFormatHex
+	acceptHexAddresses   = true
+)
+
+// SetAddressFormat chooses how addresses are displayed in API output, and whether API
+// input may still use the hex format. Hex can only be refused once display is bech32m.
+func SetAddressFormat(display string, acceptHex bool) error {
+	switch display {
+	case "", AddressFormatHex:
+		addressDisplayFormat, acceptHexAddresses = AddressFormatHex, true
+	case AddressFormatBech32m:
+		addressDisplayFormat, acceptHexAddresses = AddressFormatBech32m, acceptHex
+	default:
+		return fmt.Errorf("unknown address format %q (use %s or %s)", display, AddressFormatHex, AddressFormatBech32m)
+	}
+	return nil
+}
+
+// Display returns the wallet address in the configured display format
+func (a Address) Display() string {
+	return a.DisplayWithType(AddressTypeWallet)
+}
+
+// DisplayWithType returns the address in the configured display format with a type
+func (a Address) DisplayWithType(addrType AddressType) string {
+	if addressDisplayFormat == AddressFormatBech32m {
+		return a.Bech32mString(addrType)
+	}
+	return a.StringWithType(addrType)
+}
+
+// chainHRP returns the network part of the human-readable part
+func chainHRP() string {
+	chainID := ActiveGenesis().ChainID
+	if chainID == DefaultChainID {
+		return "shadow"
+	}
+	var hrp strings.Builder
+	for _, c := range strings.ToLower(chainID) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			hrp.WriteRune(c)
+		}
+		if hrp.Len() == maxChainHRP {
+			break
+		}
+	}
+	return hrp.String()
+}
+
+// AddressHRP returns the bech32m human-readable part for an address type on this network
+func AddressHRP(addrType AddressType) string {
+	return string(unicode.ToLower(rune(addrType))) + chainHRP()
+}
+
+// Bech32mString returns the bech32m encoding of the address with a type
+func (a Address) Bech32mString(addrType AddressType) string {
+	hrp := AddressHRP(addrType)
+	data, _ := convertBits(a[:], 8, 5, true)
+	combined := append(data, bech32mChecksum(hrp, data)...)
+
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte('1')
+	for _, d := range combined {
+		result.WriteByte(bech32Charset[d])
+	}
+	return result.String()
+}
+
+// parseBech32mAddress decodes a bech32m address for this network
+func parseBech32mAddress(addrStr string) (Address, AddressType, error) {
+	var addr Address
+
+	if strings.ToLower(addrStr) != addrStr && strings.ToUpper(addrStr) != addrStr {
+		return addr, 0, errors.New("bech32m address must not mix case")
+	}
+	addrStr = strings.ToLower(addrStr)
+
+	sep := strings.LastIndexByte(addrStr, '1')
+	if sep < 2 || sep+7 > len(addrStr) {
+		return addr, 0, errors.New("invalid bech32m address")
+	}
+	hrp := addrStr[:sep]
+	addrType := AddressType(unicode.ToUpper(rune(hrp[0])))
+	if !isValidAddressType(addrType) {
+		return addr, 0, fmt.Errorf("invalid address type prefix: %c", hrp[0])
+	}
+	if hrp[1:] != chainHRP() {
+		return addr, 0, fmt.Errorf("address is for network %q, not %q", hrp[1:], chainHRP())
+	}
+
+	data := make([]byte, 0, len(addrStr)-sep-1)
+	for _, c := range addrStr[sep+1:] {
+		d := strings.IndexRune(bech32Charset, c)
+		if d < 0 {
+			return addr, 0, fmt.Errorf("invalid bech32m character %q", c)
+		}
+		data = append(data, byte(d))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), data...)) != bech32mConst {
+		return addr, 0, errors.New("invalid bech32m checksum")
+	}
+
+	bytes, err := convertBits(data[:len(data)-6], 5, 8, false)
+	if err != nil {
+		return addr, 0, err
+	}
+	if len(bytes) != 32 {
+		return addr, 0, fmt.Errorf("address must be 32 bytes, got %d", len(bytes))
+	}
+	copy(addr[:], bytes)
+	return addr, addrType, nil
+}
+
+// looksBech32m reports whether a string is in bech32m rather than hex form: hex addresses
+// start with an uppercase type letter followed by hex digits, bech32m ones by the network
+func looksBech32m(addrStr string) bool {
+	lower := strings.ToLower(addrStr)
+	return len(lower) > 1 && strings.HasPrefix(lower[1:], chainHRP()+"1")
+}
+
+// ParseAPIAddress parses an address given to the API: bech32m always, hex while the
+// migration window is open (see SetAddressFormat)
+func ParseAPIAddress(addrStr string) (Address, AddressType, error) {
+	if !acceptHexAddresses && !looksBech32m(addrStr) {
+		return Address{}, 0, errors.New("hex addresses are no longer accepted, use the bech32m form")
+	}
+	return ParseAddress(addrStr)
+}
+
+// bech32Polymod computes the BCH checksum over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand expands the human-readable part for checksumming
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// bech32mChecksum returns the six checksum values for hrp and data
+func bech32mChecksum(hrp string, data []byte) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ bech32mConst
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// convertBits regroups bits, e.g. bytes into 5-bit values and back
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<to - 1
+	result := make([]byte, 0, len(data)*int(from)/int(to)+1)
+	for _, b := range data {
+		acc = acc<<from | uint(b)
+		bits += from
+		for bits >= to {
+			bits -= to
+			result = append(result, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errors.New("invalid bech32m padding")
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBech32mAddresses(t *testing.T) {
+	// BIP-350 test vector: "a1lqfn3a" is a valid bech32m string with empty data
+	data := []byte{}
+	for _, c := range "lqfn3a" {
+		data = append(data, byte(strings.IndexRune(bech32Charset, c)))
+	}
+	if bech32Polymod(append(bech32HRPExpand("a"), data...)) != bech32mConst {
+		t.Fatal("BIP-350 test vector failed checksum")
+	}
+
+	kp, _ := GenerateKeyPair()
+	encoded := kp.Address().Bech32mString(AddressTypeLiquidity)
+	if !strings.HasPrefix(encoded, "lshadow1") {
+		t.Fatalf("Expected lshadow1 prefix, got %s", encoded)
+	}
+	addr, addrType, err := ParseAddress(encoded)
+	if err != nil || addr != kp.Address() || addrType != AddressTypeLiquidity {
+		t.Fatalf("Round trip failed: %v", err)
+	}
+	if _, _, err := ParseAddress(strings.ToUpper(encoded)); err != nil {
+		t.Errorf("Uppercase bech32m should parse: %v", err)
+	}
+
+	// A single mistyped character is caught by the checksum
+	typo := []byte(encoded)
+	if typo[20] == 'q' {
+		typo[20] = 'p'
+	} else {
+		typo[20] = 'q'
+	}
+	if _, _, err := ParseAddress(string(typo)); err == nil {
+		t.Error("Mistyped address should be rejected")
+	}
+
+	// The hex form still parses to the same address
+	if addr, _, err := ParseAddress(kp.Address().String()); err != nil || addr != kp.Address() {
+		t.Errorf("Hex address should still parse: %v", err)
+	}
+
+	// Closing the migration window refuses hex input but not internal parsing
+	if err := SetAddressFormat(AddressFormatBech32m, false); err != nil {
+		t.Fatal(err)
+	}
+	defer SetAddressFormat(AddressFormatHex, true)
+	if !strings.HasPrefix(kp.Address().Display(), "sshadow1") {
+		t.Errorf("Display should follow the bech32m setting, got %s", kp.Address().Display())
+	}
+	if _, _, err := ParseAPIAddress(kp.Address().String()); err == nil {
+		t.Error("Hex API input should be refused once the migration window is closed")
+	}
+	if _, _, err := ParseAPIAddress(kp.Address().Display()); err != nil {
+		t.Errorf("Bech32m API input should be accepted: %v", err)
+	}
+}
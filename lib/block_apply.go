@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Blocks are applied all or nothing. Before a block is applied every transaction in it is
+// validated in full: its structure and signatures as at mempool admission, its spends
+// against the UTXO set, and that it pays out no more of a token than its inputs hold. A
+// block spending an output that doesn't exist or is already spent, or carrying any other
+// invalid transaction, is rejected instead of applied with the transaction skipped.
+// Applying then runs in a UTXO write batch (or a savepoint of the sync batch) with the
+// token and pool registries snapshotted: if any transaction fails, every change the
+// block made is dropped, the block is rejected with ErrBlockInvalid and never persisted,
+// so nodes can't end up with different state from the same chain.
+//
+// A block listing a transaction the node has no body for can't be checked, so it isn't
+// added either. That isn't proof the block is invalid, so it fails with ErrTxNotFound
+// rather than ErrBlockInvalid; sync ships the bodies with the blocks.
+
+// ErrTxNotFound is returned for a block listing a transaction this node has no body for
+var ErrTxNotFound = errors.New("transaction not found")
+
+// ValidateBlockTransactions rejects a block listing a transaction twice or one that can't
+// be found, spending an output that doesn't exist, is already spent, or is spent by an
+// earlier transaction in the block, or carrying a transaction that fails
+// ValidateTransaction or pays out more than its inputs hold. The coinbase and settlements
+// are taken from the block; other transactions are looked up in the mempool, then storage.
+func (bc *Blockchain) ValidateBlockTransactions(block *Block, mempool *Mempool) error {
+	var coinbaseID string
+	if block.Coinbase != nil {
+		coinbaseID, _ = block.Coinbase.ID()
+	}
+	settlements := make(map[string]*Transaction, len(block.Settlements))
+	for _, settlement := range block.Settlements {
+		settlementID, _ := settlement.ID()
+		settlements[settlementID] = settlement
+	}
+
+	listed := make(map[string]*Transaction, len(block.Transactions))
+	created := make(map[string]*TxOutput)
+	spent := make(map[string]string) // Outpoint -> spending transaction
+	lookup := func(txID string, index uint32) *TxOutput {
+		if output, ok := created[fmt.Sprintf("%s:%d", txID, index)]; ok {
+			return output
+		}
+		if utxo, err := bc.utxoStore.GetUTXO(txID, index); err == nil && utxo != nil {
+			return utxo.Output
+		}
+		return nil
+	}
+	offer := func(offerTxID string) *Transaction {
+		if tx := listed[offerTxID]; tx != nil {
+			return tx
+		}
+		tx, _ := bc.utxoStore.GetTransaction(offerTxID)
+		return tx
+	}
+
+	for _, txID := range block.Transactions {
+		if _, ok := listed[txID]; ok {
+			return fmt.Errorf("transaction %s is included twice", txID)
+		}
+		if txID == coinbaseID {
+			listed[txID] = block.Coinbase // Checked by ValidateCoinbase, spendable from the next block
+			continue
+		}
+
+		tx := settlements[txID]
+		if tx == nil && mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx == nil {
+			return fmt.Errorf("%w: %s", ErrTxNotFound, txID)
+		}
+
+		for _, input := range tx.Inputs {
+			outpoint := fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)
+			if earlier, ok := spent[outpoint]; ok {
+				return fmt.Errorf("transaction %s spends %s, already spent by %s in this block", txID, outpoint, earlier)
+			}
+			spent[outpoint] = txID
+			if created[outpoint] != nil {
+				continue
+			}
+			utxo, err := bc.utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex)
+			if err != nil || utxo == nil {
+				return fmt.Errorf("transaction %s spends %s, which doesn't exist", txID, outpoint)
+			}
+			if utxo.IsSpent {
+				return fmt.Errorf("transaction %s spends %s, which is already spent", txID, outpoint)
+			}
+		}
+
+		// Node-built transactions are checked by ValidateCoinbase and ValidateSettlements
+		if !tx.TxType.NodeOnly() {
+			if err := ValidateTransaction(tx); err != nil {
+				return fmt.Errorf("transaction %s: %w", txID, err)
+			}
+			if err := checkInputValues(tx, lookup, offer); err != nil {
+				return fmt.Errorf("transaction %s: %w", txID, err)
+			}
+		}
+
+		listed[txID] = tx
+		for i, output := range tx.Outputs {
+			created[fmt.Sprintf("%s:%d", txID, i)] = output
+		}
+	}
+	return nil
+}
+
+// blockTxMempool holds transaction bodies shipped with blocks (in an archive, or by a
+// syncing peer) while the blocks are added
+func blockTxMempool(txs []*Transaction) *Mempool {
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	for _, tx := range txs {
+		if txID, err := tx.ID(); err == nil {
+			mp.entries[txID] = &MempoolEntry{Tx: tx}
+		}
+	}
+	return mp
+}
+
+// storedBlockTxs returns the stored bodies of the blocks' transactions. Settlements
+// travel in the blocks and pruned bodies can't be served, so those are left out.
+func (bc *Blockchain) storedBlockTxs(blocks []*Block) []*Transaction {
+	var txs []*Transaction
+	for _, block := range blocks {
+		settled := make(map[string]bool, len(block.Settlements))
+		for _, settlement := range block.Settlements {
+			settlementID, _ := settlement.ID()
+			settled[settlementID] = true
+		}
+		for _, txID := range block.Transactions {
+			if settled[txID] {
+				continue
+			}
+			if tx, err := bc.utxoStore.GetTransaction(txID); err == nil && tx != nil {
+				txs = append(txs, tx)
+			}
+		}
+	}
+	return txs
+}
+
+// applyBlock applies a block's state changes, or none of them if any fail (caller holds
+// chainLock)
+func (bc *Blockchain) applyBlock(block *Block, mempool *Mempool) error {
+	restoreTokens := bc.tokenRegistry.snapshot()
+	restorePools := bc.poolRegistry.snapshot()
+	rollback := func() {
+		restoreTokens()
+		restorePools()
+	}
+
+	// Sync batches blocks and commits itself; a savepoint keeps the blocks before this one
+	ownBatch := !bc.utxoStore.InBatch()
+	if ownBatch {
+		if err := bc.utxoStore.BeginBatch(); err != nil {
+			return fmt.Errorf("failed to start UTXO batch: %w", err)
+		}
+	} else if err := bc.utxoStore.Savepoint(); err != nil {
+		return fmt.Errorf("failed to start UTXO savepoint: %w", err)
+	}
+
+	if err := bc.applyBlockState(block, mempool); err != nil {
+		if ownBatch {
+			bc.utxoStore.DiscardBatch()
+		} else {
+			bc.utxoStore.RollbackToSavepoint()
+		}
+		rollback()
+		return err
+	}
+
+	if !ownBatch {
+		bc.utxoStore.ReleaseSavepoint()
+		return nil
+	}
+	if err := bc.utxoStore.CommitBatch(); err != nil {
+		rollback()
+		return fmt.Errorf("failed to commit UTXO batch: %w", err)
+	}
+	return nil
+}
+
+// snapshot records the registry's tokens, burns and collateral positions and returns a
+// function restoring them
+func (tr *TokenRegistry) snapshot() func() {
+	tokens := make(map[string]TokenInfo, len(tr.Tokens))
+	for id, token := range tr.Tokens {
+		tokens[id] = *token
+	}
+	burns := make(map[string][]*BurnRecord, len(tr.Burns))
+	for id, records := range tr.Burns {
+		burns[id] = append([]*BurnRecord(nil), records...)
+	}
+	positions := make(map[string]CollateralPosition, len(tr.Positions))
+	for id, position := range tr.Positions {
+		positions[id] = *position
+	}
+
+	return func() {
+		for id, token := range tr.Tokens {
+			saved, existed := tokens[id]
+			if !existed {
+				delete(tr.Tokens, id)
+				continue
+			}
+			*token = saved // Restore in place; callers may hold the pointer
+		}
+		for id, saved := range tokens {
+			if _, exists := tr.Tokens[id]; !exists {
+				restored := saved
+				tr.Tokens[id] = &restored
+			}
+		}
+		tr.Burns = burns
+		tr.Positions = make(map[string]*CollateralPosition, len(positions))
+		for id, saved := range positions {
+			restored := saved
+			tr.Positions[id] = &restored
+		}
+	}
+}
+
+// snapshot records the registry's pools and returns a function restoring them. Pools are
+// replaced rather than changed in place, so keeping the pointers is enough.
+func (pr *PoolRegistry) snapshot() func() {
+	pr.mutex.RLock()
+	pools := make(map[string]*LiquidityPool, len(pr.pools))
+	for id, pool := range pr.pools {
+		pools[id] = pool
+	}
+	pr.mutex.RUnlock()
+
+	return func() {
+		pr.mutex.Lock()
+		defer pr.mutex.Unlock()
+		pr.pools = pools
+	}
+}
@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockAppliedAllOrNothing(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	for i := uint32(0); i < 2; i++ {
+		if err := store.AddUTXO(&UTXO{TxID: "apply-test-funding", OutputIndex: i, Output: CreateShadowOutput(kp.Address(), 1000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	send := func(prevTxID string, index uint32, amount uint64) *Transaction {
+		tx := NewTxBuilder(TxTypeSend).AddInput(prevTxID, index).AddOutput(kp.Address(), amount, "").Build()
+		tx.Sign(kp)
+		return tx
+	}
+	data, _ := json.Marshal(AddLiquidityData{PoolID: strings.Repeat("f", 64), AmountA: 10, AmountB: 10})
+	noPool := NewTxBuilder(TxTypeAddLiquidity).AddInput("apply-test-funding", 1).
+		AddOutput(kp.Address(), 900, "").SetData(data).Build()
+	noPool.Sign(kp)
+
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	block := func(txs ...*Transaction) *Block {
+		ids := []string{}
+		for _, tx := range txs {
+			id, _ := tx.ID()
+			mempool.entries[id] = &MempoolEntry{Tx: tx}
+			ids = append(ids, id)
+		}
+		return bc.ProposeBlock(ids, "apply-test-proposer", nil)
+	}
+	height := bc.GetHeight()
+	reject := func(name string, b *Block) {
+		if err := bc.AddBlock(b, mempool); !errors.Is(err, ErrBlockInvalid) {
+			t.Fatalf("%s: expected the block to be rejected as invalid, got %v", name, err)
+		}
+		if bc.GetHeight() != height {
+			t.Fatalf("%s: rejected block was added", name)
+		}
+	}
+
+	reject("missing input", block(send("apply-test-missing", 0, 10)))
+	reject("double spend", block(send("apply-test-funding", 0, 10), send("apply-test-funding", 0, 20)))
+	reject("outputs over inputs", block(send("apply-test-funding", 0, 1001)))
+	unsigned := NewTxBuilder(TxTypeSend).AddInput("apply-test-funding", 0).AddOutput(kp.Address(), 10, "").Build()
+	reject("unsigned", block(unsigned))
+
+	// A transaction this node has no body for can't be checked, but doesn't prove the block invalid
+	missing := bc.ProposeBlock([]string{strings.Repeat("e", 64)}, "apply-test-proposer", nil)
+	if err := bc.AddBlock(missing, mempool); !errors.Is(err, ErrTxNotFound) || errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected a block with an unknown transaction refused as not found, got %v", err)
+	}
+
+	// The send applies before the liquidity add fails; none of it may stay
+	good := send("apply-test-funding", 0, 900)
+	goodID, _ := good.ID()
+	reject("failing transaction", block(good, noPool))
+	if utxo, _ := store.GetUTXO(goodID, 0); utxo != nil {
+		t.Fatal("Expected the send's output to be rolled back")
+	}
+	if utxo, _ := store.GetUTXO("apply-test-funding", 0); utxo == nil || utxo.IsSpent {
+		t.Fatal("Expected the send's input to be unspent again")
+	}
+	if tx, _ := store.GetTransaction(goodID); tx != nil {
+		t.Fatal("Expected the send not to be stored")
+	}
+
+	// In a sync batch the blocks before the failing one are kept
+	first := block(good)
+	noPoolID, _ := noPool.ID()
+	second := &Block{Index: first.Index + 1, Timestamp: first.Timestamp + 1, PreviousHash: first.Hash,
+		Transactions: []string{noPoolID}, Proposer: "apply-test-proposer"}
+	second.Hash = bc.calculateBlockHash(second)
+	for _, tx := range []*Transaction{good, noPool} {
+		if err := store.StoreTransaction(tx, 0); err != nil {
+			t.Fatalf("Failed to store transaction: %v", err)
+		}
+	}
+	if err := bc.AddBlocksBatch([]*Block{first, second}, nil); !errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected the second block to be rejected, got %v", err)
+	}
+	if bc.GetHeight() != height+1 {
+		t.Fatalf("Expected only the first block to be added, height %d", bc.GetHeight())
+	}
+	if utxo, _ := store.GetUTXO(goodID, 0); utxo == nil {
+		t.Fatal("Expected the first block's send to be committed")
+	}
+	if utxo, _ := store.GetUTXO("apply-test-funding", 1); utxo == nil || utxo.IsSpent {
+		t.Fatal("Expected the failed block's input to be unspent")
+	}
+}
+
+func TestBlockRollbackRestoresPositions(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store, registry := bc.GetUTXOStore(), bc.tokenRegistry
+
+	kp, _ := GenerateKeyPair()
+	owner := &NodeWallet{KeyPair: kp, Address: kp.Address()}
+	funding := func(txID string) []*UTXO {
+		utxo := &UTXO{TxID: txID, Output: CreateShadowOutput(owner.Address, 1_000_000)}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+		return []*UTXO{utxo}
+	}
+	sign := func(tx *Transaction) string {
+		if err := owner.SignTransaction(tx); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		txID, _ := tx.ID()
+		return txID
+	}
+
+	terms := CollateralTerms{Peg: PriceScale, Ratio: 150, LiquidationRatio: 120}
+	create, _ := CreateCollateralTokenTransaction(registry, owner.Address, funding("rollback-create"), "ROLLBACK", "Rollback", 100, 0, terms)
+	tokenID := sign(create)
+	if err := store.ProcessTokenTransaction(create, registry, nil, 0); err != nil {
+		t.Fatalf("Failed to apply token: %v", err)
+	}
+	token, _ := registry.GetToken(tokenID)
+	open, _ := CreateCollateralIssueTransaction(token, owner.Address, funding("rollback-open"), "", 75, 50)
+	positionID := sign(open)
+	if err := store.ProcessTokenTransaction(open, registry, nil, 0); err != nil {
+		t.Fatalf("Failed to open position: %v", err)
+	}
+	tokenUTXO := &UTXO{TxID: positionID, OutputIndex: 0, Output: open.Outputs[0]}
+	if err := store.AddUTXO(tokenUTXO); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+
+	// The block melts against the position and opens another before a transaction fails
+	position, _ := registry.GetPosition(positionID)
+	melt, _ := CreateCollateralMeltTransaction(registry, position, append([]*UTXO{tokenUTXO}, funding("rollback-melt")...), 20, 30, owner.Address)
+	sign(melt)
+	second, _ := CreateCollateralIssueTransaction(token, owner.Address, funding("rollback-second"), "", 30, 20)
+	secondID := sign(second)
+	data, _ := json.Marshal(AddLiquidityData{PoolID: strings.Repeat("f", 64), AmountA: 10, AmountB: 10})
+	noPool := NewTxBuilder(TxTypeAddLiquidity).AddInput("rollback-fail", 0).AddOutput(owner.Address, 900, "").SetData(data).Build()
+	sign(noPool)
+	funding("rollback-fail")
+
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	ids := []string{}
+	for _, tx := range []*Transaction{melt, second, noPool} {
+		id, _ := tx.ID()
+		mempool.entries[id] = &MempoolEntry{Tx: tx}
+		ids = append(ids, id)
+	}
+	if err := bc.applyBlock(bc.ProposeBlock(ids, "rollback-test-proposer", nil), mempool); err == nil {
+		t.Fatal("Expected the block to fail")
+	}
+
+	if position, _ := registry.GetPosition(positionID); position == nil || position.Collateral != 75 || position.Debt != 50 {
+		t.Errorf("Expected the position restored to 75 against 50, got %+v", position)
+	}
+	if _, exists := registry.GetPosition(secondID); exists {
+		t.Error("Expected the position opened in the block to be dropped")
+	}
+	if token.Unissued != 50 || token.LockedShadow != 75 {
+		t.Errorf("Expected the token restored to 50 issued against 75, got %d unissued and %d locked", token.Unissued, token.LockedShadow)
+	}
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlocksByHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	nextBlock := func(prev *Block, proposer string) *Block {
+		block := &Block{
+			Index:        prev.Index + 1,
+			Timestamp:    prev.Timestamp + 1,
+			PreviousHash: prev.Hash,
+			Proposer:     proposer,
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		return block
+	}
+
+	genesis := bc.GetLatestBlock()
+	first := nextBlock(genesis, "block-hash-test-proposer")
+	if err := bc.AddBlock(first, nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	for _, block := range []*Block{genesis, first} {
+		if got := bc.GetBlockByHash(block.Hash); got == nil || got.Index != block.Index {
+			t.Fatalf("Expected block %d by hash, got %v", block.Index, got)
+		}
+	}
+	if bc.GetBlockByHash("unknown") != nil {
+		t.Fatal("Expected no block for an unknown hash")
+	}
+	if err := bc.AddVote(first.Hash, "vote"); err != nil {
+		t.Fatalf("Failed to add vote by hash: %v", err)
+	}
+
+	// Consensus tracks a conflicting commit and its descendants as a fork
+	ce := &ConsensusEngine{
+		chain:      bc,
+		now:        time.Now,
+		forkBlocks: make(map[string]*ForkBlock),
+	}
+	rival := nextBlock(genesis, "block-hash-test-rival")
+	ce.handleBlockCommit(rival, "")
+	ce.handleBlockCommit(rival, "")
+	ce.handleBlockVote(&BlockVote{BlockHash: rival.Hash, BlockIndex: rival.Index, Vote: true})
+	ce.handleBlockCommit(nextBlock(rival, "block-hash-test-rival"), "")
+
+	forks := ce.ForkBlocks()
+	if len(forks) != 2 {
+		t.Fatalf("Expected 2 fork blocks, got %d", len(forks))
+	}
+	if forks[0].Block.Hash != rival.Hash || forks[0].Conflict != first.Hash {
+		t.Errorf("Expected the rival to conflict with block %s, got %+v", first.Hash[:16], forks[0])
+	}
+	if forks[0].Commits != 2 || forks[0].YesVotes != 1 {
+		t.Errorf("Expected 2 commits and 1 vote for the rival, got %d and %d", forks[0].Commits, forks[0].YesVotes)
+	}
+	if bc.GetHeight() != 2 {
+		t.Fatalf("Fork blocks must not change the chain, height %d", bc.GetHeight())
+	}
+
+	// The index is rebuilt when the chain is loaded, and the store agrees
+	if err := bc.Close(); err != nil {
+		t.Fatalf("Failed to close chain: %v", err)
+	}
+	bc, err = NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen chain: %v", err)
+	}
+	defer bc.Close()
+	if got := bc.GetBlockByHash(first.Hash); got == nil || got.Index != 1 {
+		t.Fatalf("Expected block 1 by hash after reload, got %v", got)
+	}
+	if got, err := bc.store.GetBlockByHash(first.Hash); err != nil || got == nil || got.Hash != first.Hash {
+		t.Fatalf("Expected the store to serve block 1 by hash, got %v (%v)", got, err)
+	}
+	if got, _ := bc.store.GetBlockByHash(rival.Hash); got != nil {
+		t.Fatal("Expected the store not to know the fork block")
+	}
+}
@@ -0,0 +1,173 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Blocks and transactions are limited in size so one oversized transaction can't stall
+// gossip or make a block too slow to apply. A transaction's size is the length of its
+// JSON encoding, the form it is gossiped and stored in, and a block's size is the sum
+// of its transactions' sizes, including the coinbase and settlements. Transactions over
+// the limit are refused by the mempool, proposers pack blocks up to the block limit, and
+// validators reject blocks over either limit.
+
+const (
+	DefaultMaxBlockBytes = 4 * 1024 * 1024 // Roughly 400 single-input transactions
+	DefaultMaxTxBytes    = 256 * 1024      // Well under the 1 MB gossip message limit
+
+	DefaultBlockStatsCount = 100
+	MaxBlockStatsCount     = 1000
+)
+
+// BlockLimits are the network's size and weight limits. Zero limits use the defaults.
+type BlockLimits struct {
+	MaxBlockBytes    int    `json:"max_block_bytes"`               // Total size of a block's transactions
+	MaxTxBytes       int    `json:"max_tx_bytes"`                  // Size of a single transaction
+	MaxBlockWeight   int    `json:"max_block_weight,omitempty"`    // Total weight of a block's transactions
+	MinFeePerKWeight uint64 `json:"min_fee_per_kweight,omitempty"` // Fee every transaction pays per 1000 weight, base units; 0 = none
+	DustThreshold    uint64 `json:"dust_threshold,omitempty"`      // Smallest SHADOW output a transaction may create, base units; 0 = none
+}
+
+// BlockSizeLimits returns the network's size and weight limits
+func (g *ChainGenesis) BlockSizeLimits() BlockLimits {
+	limits := BlockLimits{MaxBlockBytes: DefaultMaxBlockBytes, MaxTxBytes: DefaultMaxTxBytes, MaxBlockWeight: DefaultMaxBlockWeight}
+	if g.BlockLimits != nil {
+		if g.BlockLimits.MaxBlockBytes > 0 {
+			limits.MaxBlockBytes = g.BlockLimits.MaxBlockBytes
+		}
+		if g.BlockLimits.MaxTxBytes > 0 {
+			limits.MaxTxBytes = g.BlockLimits.MaxTxBytes
+		}
+		if g.BlockLimits.MaxBlockWeight > 0 {
+			limits.MaxBlockWeight = g.BlockLimits.MaxBlockWeight
+		}
+		limits.MinFeePerKWeight = g.BlockLimits.MinFeePerKWeight
+		limits.DustThreshold = g.BlockLimits.DustThreshold
+	}
+	return limits
+}
+
+// TxSize returns the size of a transaction as counted against the limits
+func TxSize(tx *Transaction) int {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// CheckTxSize rejects a transaction over the network's transaction size limit
+func CheckTxSize(tx *Transaction) error {
+	maxTxBytes := ActiveGenesis().BlockSizeLimits().MaxTxBytes
+	if size := TxSize(tx); size > maxTxBytes {
+		return fmt.Errorf("transaction is %d bytes, limit is %d", size, maxTxBytes)
+	}
+	return nil
+}
+
+// blockTxs returns the transactions of a block that can be found, in block order. The
+// coinbase and settlements come from the block, the rest from the mempool or storage;
+// unknown ones are skipped here and rejected by ValidateBlockTransactions.
+func (bc *Blockchain) blockTxs(block *Block, mempool *Mempool) []*Transaction {
+	embedded := make(map[string]*Transaction, len(block.Settlements)+1)
+	if block.Coinbase != nil {
+		coinbaseID, _ := block.Coinbase.ID()
+		embedded[coinbaseID] = block.Coinbase
+	}
+	for _, settlement := range block.Settlements {
+		settlementID, _ := settlement.ID()
+		embedded[settlementID] = settlement
+	}
+
+	txs := make([]*Transaction, 0, len(block.Transactions))
+	for _, txID := range block.Transactions {
+		tx := embedded[txID]
+		if tx == nil && mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx != nil {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// blockTxSizes returns the size of each transaction in a block that can be found
+func (bc *Blockchain) blockTxSizes(block *Block, mempool *Mempool) []int {
+	txs := bc.blockTxs(block, mempool)
+	sizes := make([]int, len(txs))
+	for i, tx := range txs {
+		sizes[i] = TxSize(tx)
+	}
+	return sizes
+}
+
+// ValidateBlockSize rejects a block over the network's block or transaction size limit
+func (bc *Blockchain) ValidateBlockSize(block *Block, mempool *Mempool) error {
+	limits := ActiveGenesis().BlockSizeLimits()
+	total := 0
+	for i, size := range bc.blockTxSizes(block, mempool) {
+		if size > limits.MaxTxBytes {
+			return fmt.Errorf("transaction %d is %d bytes, limit is %d", i, size, limits.MaxTxBytes)
+		}
+		total += size
+	}
+	if total > limits.MaxBlockBytes {
+		return fmt.Errorf("block is %d bytes, limit is %d", total, limits.MaxBlockBytes)
+	}
+	return nil
+}
+
+// BlockSizeStat is the size of one block
+type BlockSizeStat struct {
+	Height      uint64  `json:"height"`
+	TxCount     int     `json:"tx_count"`
+	Bytes       int     `json:"bytes"`
+	Weight      int     `json:"weight"`
+	Utilization float64 `json:"utilization"` // Percent of the block size limit
+}
+
+// BlockSizeStats summarizes the size of recent blocks against the limits
+type BlockSizeStats struct {
+	MaxBlockBytes      int             `json:"max_block_bytes"`
+	MaxTxBytes         int             `json:"max_tx_bytes"`
+	MaxBlockWeight     int             `json:"max_block_weight"`
+	AverageBytes       int             `json:"average_bytes"`
+	AverageUtilization float64         `json:"average_utilization"`
+	PeakUtilization    float64         `json:"peak_utilization"`
+	Blocks             []BlockSizeStat `json:"blocks"` // Newest first
+}
+
+// BlockSizeStats measures the last count blocks
+func (bc *Blockchain) BlockSizeStats(count int) *BlockSizeStats {
+	limits := ActiveGenesis().BlockSizeLimits()
+	stats := &BlockSizeStats{MaxBlockBytes: limits.MaxBlockBytes, MaxTxBytes: limits.MaxTxBytes, MaxBlockWeight: limits.MaxBlockWeight, Blocks: []BlockSizeStat{}}
+
+	totalBytes := 0
+	height := bc.GetHeight()
+	for i := uint64(0); i < uint64(count) && i < height; i++ {
+		block := bc.GetBlock(height - 1 - i)
+		if block == nil {
+			continue
+		}
+		stat := BlockSizeStat{Height: block.Index, TxCount: len(block.Transactions)}
+		for _, tx := range bc.blockTxs(block, nil) {
+			stat.Bytes += TxSize(tx)
+			stat.Weight += TxWeight(tx)
+		}
+		stat.Utilization = float64(stat.Bytes) * 100 / float64(limits.MaxBlockBytes)
+		stats.Blocks = append(stats.Blocks, stat)
+
+		totalBytes += stat.Bytes
+		stats.PeakUtilization = max(stats.PeakUtilization, stat.Utilization)
+	}
+	if len(stats.Blocks) > 0 {
+		stats.AverageBytes = totalBytes / len(stats.Blocks)
+		stats.AverageUtilization = float64(totalBytes) * 100 / float64(limits.MaxBlockBytes) / float64(len(stats.Blocks))
+	}
+	return stats
+}
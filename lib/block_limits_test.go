@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockSizeLimits(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	coinbase := newCoinbase(kp.Address(), 5000)
+	coinbaseSize := TxSize(coinbase)
+
+	genesis := DefaultChainGenesis()
+	genesis.BlockLimits = &BlockLimits{MaxBlockBytes: coinbaseSize + 10, MaxTxBytes: coinbaseSize}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	big := NewTxBuilder(TxTypeSend).AddOutput(kp.Address(), 1, "SHADOW").SetData([]byte(strings.Repeat("x", coinbaseSize))).Build()
+	if err := CheckTxSize(big); err == nil {
+		t.Error("Expected a transaction over max_tx_bytes to be rejected")
+	}
+	if err := CheckTxSize(coinbase); err != nil {
+		t.Errorf("Expected a transaction at the limit to pass, got %v", err)
+	}
+
+	coinbaseID, _ := coinbase.ID()
+	if err := bc.AddBlock(bc.ProposeBlock([]string{coinbaseID}, "size-test-proposer", coinbase), nil); err != nil {
+		t.Fatalf("Failed to add block within the limits: %v", err)
+	}
+
+	// The coinbase fits on its own, but not with a settlement as well
+	coinbase = newCoinbase(kp.Address(), 5000)
+	coinbaseID, _ = coinbase.ID()
+	extra := NewTxBuilder(TxTypeMatchOffers).AddOutput(kp.Address(), 1, "SHADOW").Build()
+	extraID, _ := extra.ID()
+	block := bc.ProposeBlock([]string{coinbaseID, extraID}, "size-test-proposer", coinbase)
+	block.Settlements = []*Transaction{extra}
+	if err := bc.ValidateBlockSize(block, nil); err == nil || !strings.Contains(err.Error(), "block is") {
+		t.Errorf("Expected a block over max_block_bytes to be rejected, got %v", err)
+	}
+
+	stats := bc.BlockSizeStats(10)
+	if len(stats.Blocks) != 2 || stats.Blocks[0].Height != 1 || stats.Blocks[0].Bytes != coinbaseSize {
+		t.Fatalf("Expected the newest block first at %d bytes, got %+v", coinbaseSize, stats.Blocks)
+	}
+	if stats.MaxBlockBytes != coinbaseSize+10 || stats.PeakUtilization <= 90 {
+		t.Errorf("Expected a nearly full block against the configured limit, got %+v", stats)
+	}
+}
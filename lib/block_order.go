@@ -0,0 +1,175 @@
+package lib
+
+import (
+	"container/heap"
+	"fmt"
+	"math/bits"
+)
+
+// A block's transactions are listed in one canonical order, so the same selection always
+// makes the same block and anyone can rebuild and audit it: the coinbase first, then the
+// settlements, whose order the order book fixes, then everything else by SHADOW fee per
+// weight, highest first, ties broken by the lower transaction ID. A transaction spending
+// an output of another in the same block, or accepting or cancelling an offer made in
+// it, comes after it, whatever their fees: of the transactions whose parents are already
+// listed, the best goes next. Proposers order
+// their templates this way and validators reject blocks that aren't in this order.
+
+// orderedTx is a transaction waiting for its place in the canonical order
+type orderedTx struct {
+	tx       *Transaction
+	txID     string
+	fee      uint64
+	weight   int
+	waiting  int      // Parents in the block not yet placed
+	children []string // Transactions in the block spending its outputs or referencing it
+}
+
+// betterFeeRate reports whether a goes before b: higher fee per weight, then lower ID
+func (a *orderedTx) betterFeeRate(b *orderedTx) bool {
+	// a.fee/a.weight vs b.fee/b.weight, exactly, in 128 bits
+	leftHi, leftLo := bits.Mul64(a.fee, uint64(b.weight))
+	rightHi, rightLo := bits.Mul64(b.fee, uint64(a.weight))
+	if leftHi != rightHi {
+		return leftHi > rightHi
+	}
+	if leftLo != rightLo {
+		return leftLo > rightLo
+	}
+	return a.txID < b.txID
+}
+
+// readyTxs holds transactions whose parents are placed, best first
+type readyTxs []*orderedTx
+
+func (q readyTxs) Len() int           { return len(q) }
+func (q readyTxs) Less(i, j int) bool { return q[i].betterFeeRate(q[j]) }
+func (q readyTxs) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *readyTxs) Push(x any)        { *q = append(*q, x.(*orderedTx)) }
+func (q *readyTxs) Pop() any {
+	old := *q
+	next := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return next
+}
+
+// CanonicalTxOrder returns txs in canonical block order. confirmed resolves outputs of
+// the UTXO set; outputs of txs themselves are resolved from txs, whatever their order.
+func CanonicalTxOrder(txs []*Transaction, confirmed func(txID string, index uint32) *TxOutput) []*Transaction {
+	nodes := make(map[string]*orderedTx, len(txs))
+	order := make([]*orderedTx, 0, len(txs))
+	for _, tx := range txs {
+		txID, err := tx.ID()
+		if err != nil || nodes[txID] != nil {
+			continue
+		}
+		node := &orderedTx{tx: tx, txID: txID, weight: TxWeight(tx)}
+		nodes[txID] = node
+		order = append(order, node)
+	}
+
+	lookup := func(txID string, index uint32) *TxOutput {
+		if parent, ok := nodes[txID]; ok {
+			if int(index) < len(parent.tx.Outputs) {
+				return parent.tx.Outputs[index]
+			}
+			return nil
+		}
+		return confirmed(txID, index)
+	}
+	for _, node := range order {
+		node.fee = paidFee(node.tx, lookup)
+		for _, dep := range txDependencies(node.tx) {
+			if parent, ok := nodes[dep]; ok && parent != node {
+				parent.children = append(parent.children, node.txID)
+				node.waiting++
+			}
+		}
+	}
+
+	ready := &readyTxs{}
+	for _, node := range order {
+		if node.waiting == 0 {
+			heap.Push(ready, node)
+		}
+	}
+	ordered := make([]*Transaction, 0, len(order))
+	for ready.Len() > 0 {
+		node := heap.Pop(ready).(*orderedTx)
+		ordered = append(ordered, node.tx)
+		for _, childID := range node.children {
+			child := nodes[childID]
+			if child.waiting--; child.waiting == 0 {
+				heap.Push(ready, child)
+			}
+		}
+	}
+
+	// A dependency cycle can't come from real hashes; keep what's left in fee order
+	if len(ordered) < len(order) {
+		var rest readyTxs
+		for _, node := range order {
+			if node.waiting > 0 {
+				rest = append(rest, node)
+			}
+		}
+		heap.Init(&rest)
+		for rest.Len() > 0 {
+			ordered = append(ordered, heap.Pop(&rest).(*orderedTx).tx)
+		}
+	}
+	return ordered
+}
+
+// ValidateTxOrder rejects a block whose transactions aren't in canonical order. Blocks
+// below the rule's activation height keep whatever order they were built with. Like the
+// coinbase check, it is skipped when some of the block's transactions are unknown, since
+// their fees and dependencies can't be known.
+func (bc *Blockchain) ValidateTxOrder(block *Block, mempool *Mempool) error {
+	if block.Index < ActiveGenesis().RuleActivationHeights().TxOrder {
+		return nil
+	}
+	offset := 0
+	if block.Coinbase != nil {
+		coinbaseID, err := block.Coinbase.ID()
+		if err != nil {
+			return fmt.Errorf("coinbase: %w", err)
+		}
+		for i, txID := range block.Transactions {
+			if txID == coinbaseID && i > 0 {
+				return fmt.Errorf("coinbase %s is not listed first", coinbaseID[:16])
+			}
+		}
+		if len(block.Transactions) > 0 && block.Transactions[0] == coinbaseID {
+			offset = 1
+		}
+	}
+	offset += len(block.Settlements) // Their place is checked by ValidateSettlements
+	if offset >= len(block.Transactions) {
+		return nil
+	}
+
+	listed := block.Transactions[offset:]
+	txs := make([]*Transaction, 0, len(listed))
+	for _, txID := range listed {
+		var tx *Transaction
+		if mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx == nil {
+			return nil
+		}
+		txs = append(txs, tx)
+	}
+
+	for i, tx := range CanonicalTxOrder(txs, utxoLookup(bc.utxoStore)) {
+		if txID, _ := tx.ID(); listed[i] != txID {
+			return fmt.Errorf("transactions are not in canonical order: expected %s at position %d, got %s",
+				txID[:16], offset+i, listed[i][:16])
+		}
+	}
+	return nil
+}
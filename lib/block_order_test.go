@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalTxOrder(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	for i := uint32(0); i < 3; i++ {
+		if err := store.AddUTXO(&UTXO{TxID: "order-test-funding", OutputIndex: i, Output: CreateShadowOutput(kp.Address(), 10_000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	spend := func(prevTxID string, index uint32, amount uint64) (*Transaction, string) {
+		tx := NewTxBuilder(TxTypeSend).AddInput(prevTxID, index).AddOutput(kp.Address(), amount, "").Build()
+		tx.Sign(kp)
+		txID, _ := tx.ID()
+		return tx, txID
+	}
+	parent, parentID := spend("order-test-funding", 0, 9_990) // Pays 10
+	child, childID := spend(parentID, 0, 4_990)               // Pays 5000 once its parent is in
+	rich, richID := spend("order-test-funding", 1, 9_000)     // Pays 1000
+	twin, twinID := spend("order-test-funding", 2, 9_990)     // Pays 10 at the parent's weight
+
+	// Best rate first, the child as soon as its parent is listed, equal rates by ID
+	want := []string{richID, parentID, childID, twinID}
+	if twinID < parentID {
+		want = []string{richID, twinID, parentID, childID}
+	}
+	var got []string
+	for _, tx := range CanonicalTxOrder([]*Transaction{child, twin, parent, rich}, utxoLookup(store)) {
+		txID, _ := tx.ID()
+		got = append(got, txID)
+	}
+	for i, txID := range want {
+		if i >= len(got) || got[i] != txID {
+			t.Fatalf("Expected %s at %d, got %v", txID[:16], i, got)
+		}
+	}
+
+	// Validators reject any other order
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	for _, tx := range []*Transaction{parent, child, rich, twin} {
+		txID, _ := tx.ID()
+		mempool.entries[txID] = &MempoolEntry{Tx: tx}
+	}
+	height := bc.GetHeight()
+	reordered := []string{got[1], got[0], got[2], got[3]}
+	if err := bc.ValidateTxOrder(bc.ProposeBlock(reordered, "order-test-proposer", nil), mempool); err != nil {
+		t.Fatalf("Expected the built-in network not to check order before its activation height, got %v", err)
+	}
+	genesis := DefaultChainGenesis()
+	genesis.Activations = &RuleActivations{TxOrder: height}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+	if err := bc.AddBlock(bc.ProposeBlock(reordered, "order-test-proposer", nil), mempool); !errors.Is(err, ErrBlockInvalid) ||
+		!strings.Contains(err.Error(), "canonical order") {
+		t.Fatalf("Expected a block out of canonical order to be rejected, got %v", err)
+	}
+	if err := bc.AddBlock(bc.ProposeBlock(got, "order-test-proposer", nil), mempool); err != nil {
+		t.Fatalf("Failed to add block in canonical order: %v", err)
+	}
+	if bc.GetHeight() != height+1 {
+		t.Fatalf("Expected the block to be added, height %d", bc.GetHeight())
+	}
+}
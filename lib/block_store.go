@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
 // BlockStore manages persistent storage for blockchain blocks
@@ -11,6 +12,9 @@ type BlockStore struct {
 	db    *BoltDBAdapter
 	mu    sync.RWMutex
 	cache map[uint64]*Block // In-memory cache for recent blocks
+
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
 }
 
 // Database key prefixes
@@ -87,9 +91,11 @@ func (bs *BlockStore) GetBlock(height uint64) (*Block, error) {
 	if block, exists := bs.cache[height]; exists {
 		fmt.Printf("[BlockStore] GetBlock(%d): Found in cache\n", height)
 		bs.mu.RUnlock()
+		bs.cacheHits.Add(1)
 		return block, nil
 	}
 	bs.mu.RUnlock()
+	bs.cacheMisses.Add(1)
 
 	fmt.Printf("[BlockStore] GetBlock(%d): Not in cache, calling db.Get()...\n", height)
 	key := []byte(fmt.Sprintf("%s%d", blockPrefix, height))
@@ -116,7 +122,32 @@ func (bs *BlockStore) GetBlock(height uint64) (*Block, error) {
 	return &block, nil
 }
 
-// GetBlockByHash retrieves a block by its hash
+// CacheStats returns block cache hit and miss counts
+func (bs *BlockStore) CacheStats() (uint64, uint64) {
+	return bs.cacheHits.Load(), bs.cacheMisses.Load()
+}
+
+// DBStats returns on-disk statistics for the block database
+func (bs *BlockStore) DBStats() (*DBStats, error) {
+	return bs.db.Stats()
+}
+
+// Compact compacts the block database file
+func (bs *BlockStore) Compact() (int64, int64, error) {
+	return bs.db.Compact()
+}
+
+// BeginBatch buffers block writes in memory until CommitBatch (used during sync)
+func (bs *BlockStore) BeginBatch() error {
+	return bs.db.BeginBatch()
+}
+
+// CommitBatch writes all buffered blocks in one database transaction
+func (bs *BlockStore) CommitBatch() error {
+	return bs.db.CommitBatch()
+}
+
+// GetBlockByHash retrieves a block by its hash, or nil if no stored block has it
 func (bs *BlockStore) GetBlockByHash(hash string) (*Block, error) {
 	key := []byte(fmt.Sprintf("%s%s", blockHashPrefix, hash))
 	data, err := bs.db.Get(key)
@@ -133,7 +164,15 @@ func (bs *BlockStore) GetBlockByHash(hash string) (*Block, error) {
 		return nil, err
 	}
 
-	return bs.GetBlock(height)
+	block, err := bs.GetBlock(height)
+	if err != nil || block == nil {
+		return block, err
+	}
+	// The mapping outlives a block overwritten at the same height; don't serve the wrong one
+	if block.Hash != hash {
+		return nil, nil
+	}
+	return block, nil
 }
 
 // GetLatestHeight returns the latest block height stored
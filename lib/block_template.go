@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A transaction may spend outputs of another that is still pending. Block templates are
+// built from packages: a transaction together with the pending ancestors it needs. A
+// package is ranked by its combined fee per weight, so a child paying a high fee pulls a
+// low-fee parent into the block (child pays for parent), and parents always come before
+// their children. Transactions spending outputs that are neither confirmed nor pending
+// are orphans and are left out, along with everything that depends on them. An
+// acceptance or cancellation counts the pending offer it closes as a parent. Members of
+// a package submitted together (see SubmitPackage) are taken as one unit: all of them or
+// none.
+
+// templateTx is a candidate transaction and its place in the dependency graph
+type templateTx struct {
+	tx        *Transaction
+	txID      string
+	size      int
+	weight    int
+	fee       uint64   // SHADOW fee, counting inputs from pending parents
+	parents   []string // Pending transactions it spends outputs of or references
+	ancestors []string // All pending ancestors, parents first
+	pkgFee    uint64   // Fee of the transaction and its ancestors
+	pkgSize   int      // Size of the transaction and its ancestors
+	pkgWeight int      // Weight of the transaction and its ancestors
+}
+
+// BlockTemplate is the ordered selection of transactions for a block
+type BlockTemplate struct {
+	Transactions []*Transaction
+	TxIDs        []string
+	Fees         uint64 // SHADOW fees paid by the selected transactions
+	Bytes        int    // Their combined size
+	Weight       int    // Their combined weight
+	Orphans      int    // Candidates left out for spending outputs that don't exist
+}
+
+// selectBlockTransactions picks candidates for a block of at most maxBytes and
+// maxWeight, highest package fee per weight first, and lists them in canonical order.
+// confirmed resolves outputs of the UTXO set; spent outputs must not be returned.
+// packages maps submitted package members to their package ID (nil: none).
+func selectBlockTransactions(candidates []*Transaction, confirmed func(txID string, index uint32) *TxOutput,
+	packages map[string]string, maxBytes, maxWeight int) *BlockTemplate {
+	nodes := make(map[string]*templateTx, len(candidates))
+	order := make([]*templateTx, 0, len(candidates))
+	for _, tx := range candidates {
+		txID, err := tx.ID()
+		if err != nil || nodes[txID] != nil {
+			continue
+		}
+		node := &templateTx{tx: tx, txID: txID, size: TxSize(tx), weight: TxWeight(tx)}
+		nodes[txID] = node
+		order = append(order, node)
+	}
+
+	// Resolve inputs against confirmed outputs, then pending ones
+	lookup := func(txID string, index uint32) *TxOutput {
+		if output := confirmed(txID, index); output != nil {
+			return output
+		}
+		if parent, ok := nodes[txID]; ok && int(index) < len(parent.tx.Outputs) {
+			return parent.tx.Outputs[index]
+		}
+		return nil
+	}
+
+	template := &BlockTemplate{}
+	orphans := make(map[string]bool)
+	for _, node := range order {
+		for _, dep := range txDependencies(node.tx) {
+			if _, pending := nodes[dep]; pending && dep != node.txID {
+				node.parents = append(node.parents, dep)
+			}
+		}
+		for _, input := range node.tx.Inputs {
+			if lookup(input.PrevTxID, input.OutputIndex) == nil {
+				orphans[node.txID] = true
+			}
+		}
+		node.fee = paidFee(node.tx, lookup)
+	}
+
+	// Orphans taint their descendants; ancestors are gathered for everything else
+	var visit func(node *templateTx, path map[string]bool) bool
+	done := make(map[string]bool)
+	visit = func(node *templateTx, path map[string]bool) bool {
+		if done[node.txID] {
+			return !orphans[node.txID]
+		}
+		if path[node.txID] {
+			orphans[node.txID] = true // A cycle can't come from real hashes; drop it
+			return false
+		}
+		path[node.txID] = true
+		defer delete(path, node.txID)
+
+		added := make(map[string]bool)
+		for _, parentID := range node.parents {
+			parent := nodes[parentID]
+			if !visit(parent, path) {
+				orphans[node.txID] = true
+			}
+			for _, id := range append(append([]string(nil), parent.ancestors...), parentID) {
+				if !added[id] {
+					added[id] = true
+					node.ancestors = append(node.ancestors, id)
+				}
+			}
+		}
+		done[node.txID] = true
+		if orphans[node.txID] {
+			return false
+		}
+
+		node.pkgFee, node.pkgSize, node.pkgWeight = node.fee, node.size, node.weight
+		for _, id := range node.ancestors {
+			node.pkgFee += nodes[id].fee
+			node.pkgSize += nodes[id].size
+			node.pkgWeight += nodes[id].weight
+		}
+		return true
+	}
+	for _, node := range order {
+		visit(node, make(map[string]bool))
+	}
+	holdPackagesTogether(order, nodes, orphans, packages)
+
+	ranked := make([]*templateTx, 0, len(order))
+	for _, node := range order {
+		if orphans[node.txID] {
+			template.Orphans++
+			continue
+		}
+		ranked = append(ranked, node)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		// a.pkgFee/a.pkgWeight > b.pkgFee/b.pkgWeight, without division
+		left, right := float64(a.pkgFee)*float64(b.pkgWeight), float64(b.pkgFee)*float64(a.pkgWeight)
+		if left != right {
+			return left > right
+		}
+		return a.txID < b.txID
+	})
+
+	// Take each package whole if it fits: its unselected ancestors, then itself
+	selected := make(map[string]bool)
+	for _, node := range ranked {
+		if selected[node.txID] {
+			continue
+		}
+		pkg := make([]*templateTx, 0, len(node.ancestors)+1)
+		size, weight := 0, 0
+		for _, id := range node.ancestors {
+			if !selected[id] {
+				pkg = append(pkg, nodes[id])
+				size += nodes[id].size
+				weight += nodes[id].weight
+			}
+		}
+		pkg = append(pkg, node)
+		size += node.size
+		weight += node.weight
+		if template.Bytes+size > maxBytes || template.Weight+weight > maxWeight {
+			continue
+		}
+		for _, member := range pkg {
+			selected[member.txID] = true
+			template.Transactions = append(template.Transactions, member.tx)
+			template.TxIDs = append(template.TxIDs, member.txID)
+			template.Fees += member.fee
+		}
+		template.Bytes += size
+		template.Weight += weight
+	}
+
+	// List the selection in canonical order, which validators check
+	if len(template.Transactions) > 1 {
+		template.Transactions = CanonicalTxOrder(template.Transactions, confirmed)
+		for i, tx := range template.Transactions {
+			template.TxIDs[i], _ = tx.ID()
+		}
+	}
+
+	if template.Orphans > 0 {
+		fmt.Printf("[Consensus] Left out %d orphan transactions spending unknown outputs\n", template.Orphans)
+	}
+	return template
+}
+
+// holdPackagesTogether makes every member of a submitted package carry the rest of the
+// package and all of its ancestors, so taking any member takes the whole package. A
+// package with members missing or orphaned is left to the usual dependency rules.
+func holdPackagesTogether(order []*templateTx, nodes map[string]*templateTx, orphans map[string]bool, packages map[string]string) {
+	if len(packages) == 0 {
+		return
+	}
+	members := make(map[string][]*templateTx)
+	for _, node := range order {
+		if packageID, ok := packages[node.txID]; ok {
+			members[packageID] = append(members[packageID], node)
+		}
+	}
+
+	whole := make(map[string]int)
+	for _, packageID := range packages {
+		whole[packageID]++
+	}
+	for packageID, pkg := range members {
+		if len(pkg) < 2 || len(pkg) != whole[packageID] {
+			continue
+		}
+		complete := true
+		for _, node := range pkg {
+			complete = complete && !orphans[node.txID]
+		}
+		if !complete {
+			continue
+		}
+
+		// Everything the package needs, ancestors first
+		var unit []string
+		added := make(map[string]bool)
+		for _, node := range pkg {
+			for _, id := range append(append([]string(nil), node.ancestors...), node.txID) {
+				if !added[id] {
+					added[id] = true
+					unit = append(unit, id)
+				}
+			}
+		}
+		for _, node := range pkg {
+			node.ancestors = node.ancestors[:0]
+			node.pkgFee, node.pkgSize, node.pkgWeight = node.fee, node.size, node.weight
+			for _, id := range unit {
+				if id == node.txID {
+					continue
+				}
+				node.ancestors = append(node.ancestors, id)
+				node.pkgFee += nodes[id].fee
+				node.pkgSize += nodes[id].size
+				node.pkgWeight += nodes[id].weight
+			}
+		}
+	}
+}
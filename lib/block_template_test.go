@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectBlockTransactions(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	confirmed := map[string]*TxOutput{
+		"funding-a:0": CreateShadowOutput(kp.Address(), 10_000),
+		"funding-b:0": CreateShadowOutput(kp.Address(), 7_000),
+	}
+	lookup := func(txID string, index uint32) *TxOutput {
+		if index != 0 {
+			return nil
+		}
+		return confirmed[txID+":0"]
+	}
+	spend := func(prevTxID string, amount uint64) (*Transaction, string) {
+		tx := NewTxBuilder(TxTypeSend).AddInput(prevTxID, 0).AddCustomOutput(CreateShadowOutput(kp.Address(), amount)).Build()
+		txID, _ := tx.ID()
+		return tx, txID
+	}
+
+	parent, parentID := spend("funding-a", 9_990)           // Pays 10
+	child, childID := spend(parentID, 8_990)                // Pays 1000 for itself and its parent
+	independent, independentID := spend("funding-b", 6_900) // Pays 100
+	orphan, orphanID := spend("missing", 100)
+	orphanChild, _ := spend(orphanID, 50)
+
+	// Listed in canonical order: the independent transaction pays a better rate than the
+	// parent, and the child still follows its parent. Orphans and their children are left out.
+	template := selectBlockTransactions([]*Transaction{child, orphanChild, independent, parent, orphan}, lookup, nil, 1<<20, 1<<30)
+	want := []string{independentID, parentID, childID}
+	if len(template.TxIDs) != len(want) {
+		t.Fatalf("Expected %d transactions, got %d", len(want), len(template.TxIDs))
+	}
+	for i, txID := range want {
+		if template.TxIDs[i] != txID {
+			t.Fatalf("Expected transaction %d to be %s, got %s", i, txID[:16], template.TxIDs[i][:16])
+		}
+	}
+	if template.Orphans != 2 || template.Fees != 1_110 {
+		t.Errorf("Expected 2 orphans and 1110 in fees, got %d and %d", template.Orphans, template.Fees)
+	}
+
+	// A package that doesn't fit is skipped whole, never split from its parent
+	template = selectBlockTransactions([]*Transaction{child, independent, parent}, lookup, nil, TxSize(independent), 1<<30)
+	if len(template.TxIDs) != 1 || template.TxIDs[0] != independentID {
+		t.Errorf("Expected only the independent transaction to fit, got %d", len(template.TxIDs))
+	}
+}
+
+func TestPurgeKeepsPendingChildren(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "funding", Output: CreateShadowOutput(kp.Address(), 1000)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	parent := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddCustomOutput(CreateShadowOutput(kp.Address(), 990)).Build()
+	parentID, _ := parent.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddCustomOutput(CreateShadowOutput(kp.Address(), 900)).Build()
+	childID, _ := child.ID()
+
+	mp := &Mempool{entries: map[string]*MempoolEntry{parentID: {Tx: parent}, childID: {Tx: child}}, relay: newTxRelay()}
+	mp.PurgeInvalidTransactions(store, nil)
+	if mp.Count() != 2 {
+		t.Fatalf("Expected a child of a pending parent to stay, %d left", mp.Count())
+	}
+
+	// Once the parent's input is spent elsewhere, the child goes with it
+	if err := store.SpendUTXO("funding", 0, 1); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	mp.PurgeInvalidTransactions(store, nil)
+	if mp.Count() != 0 {
+		t.Errorf("Expected the parent and child purged, %d left", mp.Count())
+	}
+}
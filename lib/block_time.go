@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Block timestamps are set by proposers, so a proposer with a bad clock could stamp a
+// block far in the future (stalling the proof window every node waits from the last
+// block) or far in the past. Validators accept a block only if its timestamp is no
+// earlier than the median time past (MTP) - the median timestamp of the 11 blocks before
+// it, which one proposer can't move - and no more than MaxBlockTimeDrift ahead of their
+// own clock. Proposers whose clock is behind the MTP stamp the MTP instead. Rules that
+// depend on chain time compare against the MTP, never a single block's timestamp; offer
+// expiry, timelocks and the TWAP oracle count blocks, so they aren't affected.
+
+const (
+	MedianTimeSpan    = 11              // Blocks whose median timestamp is the median time past
+	MaxBlockTimeDrift = 2 * time.Minute // How far ahead of a validator's clock a block may be stamped
+)
+
+// ErrBlockTime is returned for a block stamped before the median time past or too far
+// ahead of the local clock
+var ErrBlockTime = errors.New("invalid block timestamp")
+
+// MedianTimePast returns the median timestamp of the MedianTimeSpan blocks ending at
+// height (fewer near genesis)
+func (bc *Blockchain) MedianTimePast(height uint64) int64 {
+	var timestamps []int64
+	for i := uint64(0); i < MedianTimeSpan && i <= height; i++ {
+		if block := bc.GetBlock(height - i); block != nil {
+			timestamps = append(timestamps, block.Timestamp)
+		}
+	}
+	if len(timestamps) == 0 {
+		return 0
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// TipMedianTimePast returns the median time past of the chain tip, the earliest
+// timestamp the next block may carry
+func (bc *Blockchain) TipMedianTimePast() int64 {
+	height := bc.GetHeight()
+	if height == 0 {
+		return 0
+	}
+	return bc.MedianTimePast(height - 1)
+}
+
+// ValidateBlockTime rejects a block stamped before the median time past of the blocks
+// before it, or more than MaxBlockTimeDrift after now. Blocks below the median time
+// rule's activation height are only held to the drift limit.
+func (bc *Blockchain) ValidateBlockTime(block *Block, now time.Time) error {
+	if block.Index == 0 {
+		return nil // Genesis carries the network's genesis time
+	}
+	if block.Index >= ActiveGenesis().RuleActivationHeights().MedianTime {
+		if mtp := bc.MedianTimePast(block.Index - 1); block.Timestamp < mtp {
+			return fmt.Errorf("%w: block %d stamped %d, before the median time past %d", ErrBlockTime, block.Index, block.Timestamp, mtp)
+		}
+	}
+	if ahead := time.Unix(block.Timestamp, 0).Sub(now); ahead > MaxBlockTimeDrift {
+		return fmt.Errorf("%w: block %d stamped %s ahead of the local clock (at most %s)", ErrBlockTime, block.Index, ahead.Round(time.Second), MaxBlockTimeDrift)
+	}
+	return nil
+}
+
+// NextBlockTime returns the timestamp of a block proposed at now: now, or the tip's
+// median time past if the local clock is behind it
+func (bc *Blockchain) NextBlockTime(now time.Time) int64 {
+	if mtp := bc.TipMedianTimePast(); now.Unix() < mtp {
+		return mtp
+	}
+	return now.Unix()
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlockTimeValidation(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	next := func(timestamp int64) *Block {
+		prev := bc.GetLatestBlock()
+		block := &Block{Index: prev.Index + 1, Timestamp: timestamp, PreviousHash: prev.Hash, Proposer: "time-test-proposer"}
+		block.Hash = bc.calculateBlockHash(block)
+		return block
+	}
+	genesisTime := ActiveGenesis().GenesisTime
+	if mtp := bc.TipMedianTimePast(); mtp != genesisTime {
+		t.Fatalf("Expected the genesis time as the first median time past, got %d", mtp)
+	}
+
+	// The built-in network only checks the median time past from its activation height
+	if err := bc.ValidateBlockTime(next(genesisTime-1), time.Now()); err != nil {
+		t.Fatalf("Expected the median time past unchecked before its activation height, got %v", err)
+	}
+	genesis := DefaultChainGenesis()
+	genesis.Activations = &RuleActivations{}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	// A clock running behind the chain can't stamp a block before its parent's time
+	if err := bc.AddBlock(next(genesisTime-1), nil); !errors.Is(err, ErrBlockTime) || !errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected a block before the genesis time rejected, got %v", err)
+	}
+
+	// Eleven blocks, one of them far out of line; the median ignores it
+	for i, offset := range []int64{10, 20, 30, 40, 5000, 60, 70, 80, 90, 100, 110} {
+		if err := bc.AddBlock(next(genesisTime+offset), nil); err != nil {
+			t.Fatalf("Failed to add block %d: %v", i+1, err)
+		}
+	}
+	mtp := bc.TipMedianTimePast()
+	if mtp != genesisTime+70 {
+		t.Fatalf("Expected the median of the last 11 blocks (genesis+70), got genesis+%d", mtp-genesisTime)
+	}
+
+	// Earlier than the tip but not the median is fine; earlier than the median isn't
+	if err := bc.AddBlock(next(mtp-1), nil); !errors.Is(err, ErrBlockTime) {
+		t.Fatalf("Expected a block before the median time past rejected, got %v", err)
+	}
+	if err := bc.AddBlock(next(mtp), nil); err != nil {
+		t.Fatalf("Expected a block at the median time past accepted, got %v", err)
+	}
+
+	// Blocks from the future are held to the drift allowed over the local clock
+	now := time.Now()
+	if err := bc.ValidateBlockTime(next(now.Add(MaxBlockTimeDrift+time.Minute).Unix()), now); !errors.Is(err, ErrBlockTime) {
+		t.Errorf("Expected a block far ahead of the clock rejected, got %v", err)
+	}
+	if err := bc.ValidateBlockTime(next(now.Add(MaxBlockTimeDrift-time.Second).Unix()), now); err != nil {
+		t.Errorf("Expected a block within the drift accepted, got %v", err)
+	}
+
+	// Proposers behind the chain's time stamp the median time past instead
+	behind := time.Unix(genesisTime, 0)
+	if got := bc.NextBlockTime(behind); got != bc.TipMedianTimePast() {
+		t.Errorf("Expected a slow clock to stamp the median time past, got %d", got)
+	}
+	if got := bc.NextBlockTime(now); got != now.Unix() {
+		t.Errorf("Expected a correct clock to stamp now, got %d", got)
+	}
+	if block := bc.ProposeBlock(nil, "time-test-proposer", nil); bc.ValidateBlockTime(block, time.Now()) != nil {
+		t.Error("Expected a proposed block to carry a valid timestamp")
+	}
+
+	params := bc.ChainParams(RelayPolicy{})
+	if params.MedianTimePast != bc.TipMedianTimePast() || params.MaxBlockTimeDrift != int(MaxBlockTimeDrift/time.Second) {
+		t.Errorf("Unexpected time parameters: median %d, drift %d", params.MedianTimePast, params.MaxBlockTimeDrift)
+	}
+}
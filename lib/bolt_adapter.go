@@ -3,14 +3,52 @@ package lib
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
+// compactTxMaxSize bounds the size of each write transaction during compaction
+const compactTxMaxSize = 64 * 1024 * 1024
+
 // BoltDBAdapter wraps BoltDB to implement a simple KV interface
 type BoltDBAdapter struct {
 	db         *bolt.DB
 	bucketName []byte
+	path       string
+
+	// mu guards db against being swapped out by Compact; normal operations take
+	// the read side, compaction takes the write side
+	mu             sync.RWMutex
+	openIterators  atomic.Int32
+	lastCompaction time.Time
+
+	// batch buffers writes in memory between BeginBatch and CommitBatch
+	batchMu sync.RWMutex
+	batch   map[string]batchOp
+
+	// savepoint holds what the batch had buffered, before Savepoint, for each key written
+	// since; nil values mark keys that weren't buffered
+	savepoint map[string]*batchOp
+}
+
+// batchOp is a buffered write; deleted marks a buffered delete
+type batchOp struct {
+	value   []byte
+	deleted bool
+}
+
+// DBStats describes the on-disk state of a BoltDB database
+type DBStats struct {
+	Path           string         `json:"path"`
+	SizeBytes      int64          `json:"size_bytes"`
+	KeyCounts      map[string]int `json:"key_counts"` // Key prefix (up to first ':') -> count
+	TotalKeys      int            `json:"total_keys"`
+	LastCompaction int64          `json:"last_compaction"` // Unix timestamp, 0 = never
 }
 
 // NewBoltDBAdapter creates a new BoltDB adapter
@@ -41,11 +79,19 @@ func NewBoltDBAdapter(dbPath string) (*BoltDBAdapter, error) {
 	return &BoltDBAdapter{
 		db:         db,
 		bucketName: bucketName,
+		path:       dbPath,
 	}, nil
 }
 
 // Get retrieves a value by key
 func (b *BoltDBAdapter) Get(key []byte) ([]byte, error) {
+	if value, ok := b.getBuffered(key); ok {
+		return value, nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	var value []byte
 	err := b.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.bucketName)
@@ -69,6 +115,13 @@ func (b *BoltDBAdapter) Get(key []byte) ([]byte, error) {
 
 // Set stores a key-value pair
 func (b *BoltDBAdapter) Set(key, value []byte) error {
+	if b.buffer(key, batchOp{value: append([]byte(nil), value...)}) {
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	return b.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(b.bucketName)
 		if bucket == nil {
@@ -78,8 +131,192 @@ func (b *BoltDBAdapter) Set(key, value []byte) error {
 	})
 }
 
+// Delete removes a key (no error if the key does not exist)
+func (b *BoltDBAdapter) Delete(key []byte) error {
+	if b.buffer(key, batchOp{deleted: true}) {
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// DeleteBatch removes many keys in a single write transaction
+func (b *BoltDBAdapter) DeleteBatch(keys [][]byte) error {
+	if b.InBatch() {
+		for _, key := range keys {
+			b.buffer(key, batchOp{deleted: true})
+		}
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BeginBatch starts buffering writes in memory until CommitBatch or DiscardBatch.
+// Get sees buffered writes; iterators only see committed data.
+func (b *BoltDBAdapter) BeginBatch() error {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	if b.batch != nil {
+		return fmt.Errorf("write batch already in progress")
+	}
+	b.batch = make(map[string]batchOp)
+	return nil
+}
+
+// InBatch reports whether writes are currently being buffered
+func (b *BoltDBAdapter) InBatch() bool {
+	b.batchMu.RLock()
+	defer b.batchMu.RUnlock()
+	return b.batch != nil
+}
+
+// CommitBatch writes all buffered operations in a single transaction and ends the batch
+func (b *BoltDBAdapter) CommitBatch() error {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	if b.batch == nil {
+		return fmt.Errorf("no write batch in progress")
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		for key, op := range b.batch {
+			if op.deleted {
+				if err := bucket.Delete([]byte(key)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := bucket.Put([]byte(key), op.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	b.batch = nil
+	b.savepoint = nil
+	return err
+}
+
+// DiscardBatch drops all buffered operations and ends the batch
+func (b *BoltDBAdapter) DiscardBatch() {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+	b.batch = nil
+	b.savepoint = nil
+}
+
+// Savepoint marks the batch's current state so RollbackToSavepoint can drop the writes
+// buffered after it
+func (b *BoltDBAdapter) Savepoint() error {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	if b.batch == nil {
+		return fmt.Errorf("no write batch in progress")
+	}
+	b.savepoint = make(map[string]*batchOp)
+	return nil
+}
+
+// RollbackToSavepoint drops the writes buffered since Savepoint; the batch stays open
+func (b *BoltDBAdapter) RollbackToSavepoint() {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	for key, previous := range b.savepoint {
+		if previous == nil {
+			delete(b.batch, key)
+		} else {
+			b.batch[key] = *previous
+		}
+	}
+	b.savepoint = nil
+}
+
+// ReleaseSavepoint keeps the writes buffered since Savepoint
+func (b *BoltDBAdapter) ReleaseSavepoint() {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+	b.savepoint = nil
+}
+
+// buffer records op in the active batch; returns false if no batch is active
+func (b *BoltDBAdapter) buffer(key []byte, op batchOp) bool {
+	b.batchMu.Lock()
+	defer b.batchMu.Unlock()
+
+	if b.batch == nil {
+		return false
+	}
+	if b.savepoint != nil {
+		if _, recorded := b.savepoint[string(key)]; !recorded {
+			var previous *batchOp
+			if op, ok := b.batch[string(key)]; ok {
+				previous = &op
+			}
+			b.savepoint[string(key)] = previous
+		}
+	}
+	b.batch[string(key)] = op
+	return true
+}
+
+// getBuffered returns a buffered value (nil if deleted); ok is false if key isn't buffered
+func (b *BoltDBAdapter) getBuffered(key []byte) ([]byte, bool) {
+	b.batchMu.RLock()
+	defer b.batchMu.RUnlock()
+
+	op, ok := b.batch[string(key)]
+	if !ok {
+		return nil, false
+	}
+	if op.deleted {
+		return nil, true
+	}
+	value := make([]byte, len(op.value))
+	copy(value, op.value)
+	return value, true
+}
+
 // Iterator creates an iterator for a given prefix
 func (b *BoltDBAdapter) Iterator(start, end []byte) (Iterator, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	tx, err := b.db.Begin(false)
 	if err != nil {
 		return nil, err
@@ -93,30 +330,200 @@ func (b *BoltDBAdapter) Iterator(start, end []byte) (Iterator, error) {
 
 	cursor := bucket.Cursor()
 
+	// Iterators hold a read transaction open, so compaction must wait for them
+	b.openIterators.Add(1)
+
 	return &BoltIterator{
-		tx:     tx,
-		cursor: cursor,
-		start:  start,
-		end:    end,
-		first:  true,
+		tx:      tx,
+		cursor:  cursor,
+		start:   start,
+		end:     end,
+		first:   true,
+		adapter: b,
 	}, nil
 }
 
+// Snapshot opens a read transaction: a view of the committed data that later writes
+// don't change. Buffered batch writes aren't part of it. Must be closed.
+func (b *BoltDBAdapter) Snapshot() (*BoltSnapshot, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	bucket := tx.Bucket(b.bucketName)
+	if bucket == nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("bucket not found")
+	}
+
+	// Like iterators, snapshots hold a read transaction open
+	b.openIterators.Add(1)
+	return &BoltSnapshot{tx: tx, bucket: bucket, adapter: b}, nil
+}
+
+// Compact rewrites the database into a fresh file, dropping free pages, and swaps it in place.
+// Returns the file size before and after. Fails fast if iterators are open.
+func (b *BoltDBAdapter) Compact() (int64, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if open := b.openIterators.Load(); open > 0 {
+		return 0, 0, fmt.Errorf("database busy: %d open iterators", open)
+	}
+
+	sizeBefore := fileSize(b.path)
+	tmpPath := b.path + ".compact"
+	os.Remove(tmpPath)
+
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open compaction target: %w", err)
+	}
+
+	if err := bolt.Compact(dst, b.db, compactTxMaxSize); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("compaction failed: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("failed to close compacted database: %w", err)
+	}
+
+	// Swap the compacted file in
+	if err := b.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("failed to close database for swap: %w", err)
+	}
+	renameErr := os.Rename(tmpPath, b.path)
+
+	// Reopen whichever file is now at the path (original if rename failed)
+	db, err := bolt.Open(b.path, 0600, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to reopen database after compaction: %w", err)
+	}
+	b.db = db
+
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("failed to replace database with compacted copy: %w", renameErr)
+	}
+
+	b.lastCompaction = time.Now()
+	return sizeBefore, fileSize(b.path), nil
+}
+
+// Stats returns file size, key counts per prefix, and last compaction time
+func (b *BoltDBAdapter) Stats() (*DBStats, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := &DBStats{
+		Path:      b.path,
+		SizeBytes: fileSize(b.path),
+		KeyCounts: make(map[string]int),
+	}
+	if !b.lastCompaction.IsZero() {
+		stats.LastCompaction = b.lastCompaction.Unix()
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucketName)
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			prefix := string(k)
+			if idx := strings.IndexByte(prefix, ':'); idx >= 0 {
+				prefix = prefix[:idx+1]
+			}
+			stats.KeyCounts[prefix]++
+			stats.TotalKeys++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// fileSize returns the size of a file, or 0 if it can't be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
 // Close closes the database
 func (b *BoltDBAdapter) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	return b.db.Close()
 }
 
+// BoltSnapshot is a read transaction over the database
+type BoltSnapshot struct {
+	tx      *bolt.Tx
+	bucket  *bolt.Bucket
+	adapter *BoltDBAdapter
+	closed  bool
+}
+
+// Get retrieves a value by key as of the snapshot
+func (s *BoltSnapshot) Get(key []byte) ([]byte, error) {
+	val := s.bucket.Get(key)
+	if val == nil {
+		return nil, nil
+	}
+	// Copy so the value outlives the transaction
+	return append([]byte(nil), val...), nil
+}
+
+// Iterator creates an iterator over the snapshot; it is closed with the snapshot
+func (s *BoltSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	return &BoltIterator{
+		tx:       s.tx,
+		cursor:   s.bucket.Cursor(),
+		start:    start,
+		end:      end,
+		first:    true,
+		adapter:  s.adapter,
+		snapshot: true,
+	}, nil
+}
+
+// Close ends the snapshot's read transaction
+func (s *BoltSnapshot) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	err := s.tx.Rollback()
+	s.adapter.openIterators.Add(-1)
+	return err
+}
+
 // BoltIterator wraps BoltDB cursor to match our Iterator interface
 type BoltIterator struct {
-	tx     *bolt.Tx
-	cursor *bolt.Cursor
-	start  []byte
-	end    []byte
-	key    []byte
-	value  []byte
-	first  bool
-	valid  bool
+	tx       *bolt.Tx
+	cursor   *bolt.Cursor
+	start    []byte
+	end      []byte
+	key      []byte
+	value    []byte
+	first    bool
+	valid    bool
+	adapter  *BoltDBAdapter
+	closed   bool
+	snapshot bool // Reads a snapshot's transaction, which the snapshot closes
 }
 
 // Valid returns true if the iterator is positioned at a valid key
@@ -181,5 +588,15 @@ func (bi *BoltIterator) Value() []byte {
 
 // Close closes the iterator and transaction
 func (bi *BoltIterator) Close() error {
-	return bi.tx.Rollback()
+	if bi.closed {
+		return nil
+	}
+	bi.closed = true
+	if bi.snapshot {
+		return nil
+	}
+
+	err := bi.tx.Rollback()
+	bi.adapter.openIterators.Add(-1)
+	return err
 }
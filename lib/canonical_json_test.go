@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	value := map[string]interface{}{
+		"zeta":  uint64(18446744073709551615),
+		"alpha": []interface{}{"<a&b>", "line\nbreak\x01", true, nil},
+		"Beta":  map[string]int{"y": -1, "x": 0},
+	}
+	got, err := CanonicalJSON(value)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	want := `{"Beta":{"x":0,"y":-1},"alpha":["<a&b>","line\nbreak\u0001",true,null],"zeta":18446744073709551615}`
+	if string(got) != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	if _, err := CanonicalJSON(map[string]float64{"fee": 0.5}); err == nil {
+		t.Error("Fractional numbers should be rejected")
+	}
+}
+
+func TestTransactionHashVersions(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	tx := NewTxBuilder(TxTypeSend).
+		AddInput("a", 0).
+		AddOutput(kp.Address(), 1000, "SHADOW").
+		SetData([]byte("memo <&>")).
+		Build()
+	if tx.Version != CanonicalTxVersion {
+		t.Fatalf("Builder should produce version %d, got %d", CanonicalTxVersion, tx.Version)
+	}
+
+	// Version 2 hashes the canonical encoding
+	hash, _ := tx.Hash()
+	canonical, _ := CanonicalJSON(&Transaction{
+		TxType: tx.TxType, Version: tx.Version, Timestamp: tx.Timestamp,
+		Inputs: tx.Inputs, Outputs: tx.Outputs, Data: tx.Data,
+	})
+	if expected := blake2b.Sum256(canonical); string(hash) != string(expected[:]) {
+		t.Error("Version 2 hash should cover the canonical encoding")
+	}
+
+	// Version 1 keeps hashing json.Marshal output, so stored transactions keep their IDs
+	tx.Version = LegacyTxVersion
+	legacyHash, _ := tx.Hash()
+	legacy, _ := json.Marshal(&Transaction{
+		TxType: tx.TxType, Version: tx.Version, Timestamp: tx.Timestamp,
+		Inputs: tx.Inputs, Outputs: tx.Outputs, Data: tx.Data,
+	})
+	if expected := blake2b.Sum256(legacy); string(legacyHash) != string(expected[:]) {
+		t.Error("Version 1 hash changed")
+	}
+
+	// Both versions sign and verify
+	for _, version := range []uint32{LegacyTxVersion, CanonicalTxVersion} {
+		tx.Version = version
+		if err := tx.Sign(kp); err != nil {
+			t.Fatalf("Failed to sign version %d: %v", version, err)
+		}
+		if err := ValidateTransaction(tx); err != nil {
+			t.Errorf("Version %d should validate: %v", version, err)
+		}
+	}
+
+	tx.Version = MaxTxVersion + 1
+	if err := ValidateTransaction(tx); err == nil {
+		t.Error("Unknown versions should be rejected")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,28 +12,41 @@ import (
 
 // Block represents a single block in the blockchain
 type Block struct {
-	Index         uint64        `json:"index"`
-	Timestamp     int64         `json:"timestamp"`
-	Transactions  []string      `json:"transactions"` // Transaction IDs
-	Coinbase      *Transaction  `json:"coinbase"`     // Coinbase transaction for block reward
-	PreviousHash  string        `json:"previous_hash"`
-	Hash          string        `json:"hash"`
-	Proposer      string        `json:"proposer"`                 // Node that proposed this block
-	Votes         []string      `json:"votes"`                    // Signatures from nodes that approved
-	WinningProof  *ProofOfSpace `json:"winning_proof"`            // Proof of space that won this block
-	WinnerAddress *Address      `json:"winner_address,omitempty"` // Address to receive block reward
+	Index         uint64         `json:"index"`
+	Timestamp     int64          `json:"timestamp"`
+	Transactions  []string       `json:"transactions"`          // Transaction IDs
+	Coinbase      *Transaction   `json:"coinbase"`              // Coinbase transaction for block reward
+	Settlements   []*Transaction `json:"settlements,omitempty"` // Offer matches made by the proposer, listed after the coinbase
+	PreviousHash  string         `json:"previous_hash"`
+	Hash          string         `json:"hash"`
+	Proposer      string         `json:"proposer"`                 // Node that proposed this block
+	Votes         []string       `json:"votes"`                    // Signatures from nodes that approved
+	WinningProof  *ProofOfSpace  `json:"winning_proof"`            // Proof of space that won this block
+	WinnerAddress *Address       `json:"winner_address,omitempty"` // Address to receive block reward
 }
 
 // Blockchain represents the chain of blocks
 type Blockchain struct {
 	blocks            []*Block
+	hashIndex         map[string]uint64 // Block hash -> height, for every block in blocks
 	store             *BlockStore
 	utxoStore         *UTXOStore
+	tokenRegistry     *TokenRegistry
 	poolRegistry      *PoolRegistry
 	chainLock         sync.RWMutex
-	proofPruningDepth int // Keep proofs for last N blocks, 0 = keep all
+	proofPruningDepth int            // Keep proofs for last N blocks, 0 = keep all
+	utxoPruneDepth    int            // Delete spent UTXOs older than N blocks, 0 = keep all
+	txPruneDepth      int            // Delete spent transaction bodies older than N blocks, 0 = keep all
+	stopMaintenance   chan struct{}  // Closed on shutdown to stop background DB maintenance
+	stopOnce          sync.Once      // Closes stopMaintenance once, however often Close is called
+	maintenance       sync.WaitGroup // Background DB maintenance still running, waited for by Close
+	evidence          *EvidenceStore // Where blocks rejected from peers are recorded (nil: nowhere)
 }
 
+// MinUTXOPruneDepth is the smallest allowed UTXO prune depth; spent records newer
+// than this are always kept so a reorg can restore them
+const MinUTXOPruneDepth = 100
+
 // NewBlockchain creates a new blockchain with a genesis block
 func NewBlockchain(storePath string) (*Blockchain, error) {
 	blockStorePath := storePath + ".db"
@@ -54,14 +68,30 @@ func NewBlockchain(storePath string) (*Blockchain, error) {
 	}
 	fmt.Printf("[Chain] UTXO store opened successfully\n")
 
-	// Create pool registry
-	poolRegistry := NewPoolRegistry()
+	// Upgrade stores written by older nodes, and refuse ones written by newer nodes
+	if err := store.Migrate(); err != nil {
+		store.Close()
+		utxoStore.Close()
+		return nil, err
+	}
+	if err := utxoStore.Migrate(); err != nil {
+		store.Close()
+		utxoStore.Close()
+		return nil, err
+	}
+
+	// Create token and pool registries, persisted alongside the UTXO set
+	tokenRegistry := NewPersistentTokenRegistry(utxoStore)
+	poolRegistry := NewPersistentPoolRegistry(utxoStore, tokenRegistry)
 
 	bc := &Blockchain{
-		blocks:       make([]*Block, 0),
-		store:        store,
-		utxoStore:    utxoStore,
-		poolRegistry: poolRegistry,
+		blocks:          make([]*Block, 0),
+		hashIndex:       make(map[string]uint64),
+		store:           store,
+		utxoStore:       utxoStore,
+		tokenRegistry:   tokenRegistry,
+		poolRegistry:    poolRegistry,
+		stopMaintenance: make(chan struct{}),
 	}
 
 	// Try to load existing chain from storage
@@ -91,43 +121,92 @@ func NewBlockchain(storePath string) (*Blockchain, error) {
 			if block == nil {
 				return nil, fmt.Errorf("missing block %d in storage", i)
 			}
-			bc.blocks = append(bc.blocks, block)
+			bc.appendBlockLocked(block)
 		}
 		fmt.Printf("[Chain] Loaded %d blocks from storage, latest hash: %s\n",
 			len(bc.blocks), bc.blocks[len(bc.blocks)-1].Hash[:16])
 
-		// Rebuild token registry from blockchain
-		fmt.Printf("[Chain] Rebuilding token registry from blockchain...\n")
-		if err := bc.rebuildTokenRegistry(); err != nil {
-			fmt.Printf("[Chain] Warning: Failed to rebuild token registry: %v\n", err)
+		// Refuse to run a data dir created for a different network
+		if expected := ActiveGenesis().Block(bc); bc.blocks[0].Hash != expected.Hash {
+			return nil, fmt.Errorf("stored genesis %s does not match chain %s genesis %s (wrong genesis file or data dir?)",
+				bc.blocks[0].Hash[:16], ActiveGenesis().ChainID, expected.Hash[:16])
+		}
+
+		// Load tokens from storage; databases from before tokens were persisted are scanned once
+		tokensLoaded := false
+		if utxoStore.HasTokenIndex() {
+			if err := tokenRegistry.Load(); err != nil {
+				fmt.Printf("[Chain] Warning: Failed to load tokens: %v\n", err)
+			} else {
+				tokensLoaded = true
+			}
+		}
+		if !tokensLoaded {
+			fmt.Printf("[Chain] Rebuilding token registry from blockchain...\n")
+			if err := bc.rebuildTokenRegistry(); err != nil {
+				fmt.Printf("[Chain] Warning: Failed to rebuild token registry: %v\n", err)
+			}
+		}
+
+		// Load pools from storage; databases from before pools were persisted are scanned once
+		loaded := false
+		if utxoStore.HasPoolIndex() {
+			if err := poolRegistry.Load(); err != nil {
+				fmt.Printf("[Chain] Warning: Failed to load pools: %v\n", err)
+			} else {
+				loaded = true
+			}
+		}
+		if !loaded {
+			fmt.Printf("[Chain] Rebuilding pool registry from blockchain...\n")
+			if err := bc.rebuildPoolRegistry(); err != nil {
+				fmt.Printf("[Chain] Warning: Failed to rebuild pool registry: %v\n", err)
+			}
 		}
 
-		// Rebuild pool registry from blockchain
-		fmt.Printf("[Chain] Rebuilding pool registry from blockchain...\n")
-		if err := bc.rebuildPoolRegistry(); err != nil {
-			fmt.Printf("[Chain] Warning: Failed to rebuild pool registry: %v\n", err)
+		// Persist the rebuilt tokens, LP tokens restored with the pools included
+		if !tokensLoaded {
+			if err := tokenRegistry.persistAll(); err != nil {
+				return nil, err
+			}
+			if err := utxoStore.markTokenIndex(); err != nil {
+				return nil, err
+			}
 		}
 	} else {
-		// Create new genesis block
-		genesis := &Block{
-			Index:        0,
-			Timestamp:    1704067200, // Fixed: Jan 1, 2024 00:00:00 UTC
-			Transactions: []string{},
-			PreviousHash: "0",
-			Proposer:     "genesis",
-			Votes:        []string{},
-		}
-		genesis.Hash = bc.calculateBlockHash(genesis)
-		bc.blocks = append(bc.blocks, genesis)
+		// Create new genesis block from the active network genesis
+		genesis := ActiveGenesis().Block(bc)
+		if genesis.Coinbase != nil {
+			if err := bc.applyBlockState(genesis, nil); err != nil {
+				return nil, fmt.Errorf("failed to apply genesis allocations: %w", err)
+			}
+		}
+		bc.appendBlockLocked(genesis)
 
 		// Save genesis to storage
 		if err := store.SaveBlock(genesis); err != nil {
 			return nil, fmt.Errorf("failed to save genesis block: %w", err)
 		}
 
+		// No pools or tokens yet; every one from here on is persisted as it is created
+		if err := utxoStore.markPoolIndex(); err != nil {
+			return nil, err
+		}
+		if err := utxoStore.markTokenIndex(); err != nil {
+			return nil, err
+		}
+
 		fmt.Printf("[Chain] Created new blockchain with genesis block: %s\n", genesis.Hash)
 	}
 
+	// Index balances for databases created before the current balance index version
+	if !utxoStore.HasBalanceIndex() {
+		fmt.Printf("[Chain] Building balance index...\n")
+		if err := utxoStore.RebuildBalanceIndex(); err != nil {
+			fmt.Printf("[Chain] Warning: Failed to build balance index: %v\n", err)
+		}
+	}
+
 	return bc, nil
 }
 
@@ -167,6 +246,25 @@ func (bc *Blockchain) GetBlock(index uint64) *Block {
 	return bc.blocks[index]
 }
 
+// GetBlockByHash returns the block with the given hash, or nil if it isn't in the chain
+func (bc *Blockchain) GetBlockByHash(hash string) *Block {
+	bc.chainLock.RLock()
+	defer bc.chainLock.RUnlock()
+
+	index, ok := bc.hashIndex[hash]
+	if !ok {
+		return nil
+	}
+	return bc.blocks[index]
+}
+
+// appendBlockLocked extends the chain by block and indexes its hash
+// Must be called with chainLock held (or before the chain is shared)
+func (bc *Blockchain) appendBlockLocked(block *Block) {
+	bc.hashIndex[block.Hash] = uint64(len(bc.blocks))
+	bc.blocks = append(bc.blocks, block)
+}
+
 // GetHeight returns the current blockchain height
 func (bc *Blockchain) GetHeight() uint64 {
 	bc.chainLock.RLock()
@@ -187,6 +285,59 @@ func (bc *Blockchain) SetProofPruningDepth(depth int) {
 	}
 }
 
+// SetEvidenceStore sets where blocks rejected from peers are recorded
+func (bc *Blockchain) SetEvidenceStore(evidence *EvidenceStore) {
+	bc.chainLock.Lock()
+	defer bc.chainLock.Unlock()
+	bc.evidence = evidence
+}
+
+// Evidence returns where rejected blocks and transactions are recorded, or nil
+func (bc *Blockchain) Evidence() *EvidenceStore {
+	bc.chainLock.RLock()
+	defer bc.chainLock.RUnlock()
+	return bc.evidence
+}
+
+// SetUTXOPruneDepth configures spent UTXO pruning (0 = disabled)
+func (bc *Blockchain) SetUTXOPruneDepth(depth int) {
+	bc.chainLock.Lock()
+	defer bc.chainLock.Unlock()
+	if depth > 0 && depth < MinUTXOPruneDepth {
+		fmt.Printf("[Chain] UTXO prune depth %d below reorg safety margin, using %d\n", depth, MinUTXOPruneDepth)
+		depth = MinUTXOPruneDepth
+	}
+	bc.utxoPruneDepth = depth
+	if depth == 0 {
+		fmt.Printf("[Chain] UTXO pruning disabled (keeping all spent UTXOs)\n")
+	} else {
+		fmt.Printf("[Chain] UTXO pruning enabled: deleting spent UTXOs older than %d blocks\n", depth)
+	}
+}
+
+// PruneSpentUTXOs deletes spent UTXO records older than utxoPruneDepth
+func (bc *Blockchain) PruneSpentUTXOs() error {
+	bc.chainLock.RLock()
+	depth := bc.utxoPruneDepth
+	currentHeight := uint64(len(bc.blocks))
+	bc.chainLock.RUnlock()
+
+	if depth == 0 || currentHeight <= uint64(depth) {
+		return nil
+	}
+
+	pruned, err := bc.utxoStore.PruneSpentUTXOs(currentHeight - uint64(depth))
+	if err != nil {
+		return err
+	}
+
+	if pruned > 0 {
+		fmt.Printf("[Chain] Pruned %d spent UTXOs (kept last %d blocks)\n", pruned, depth)
+	}
+
+	return nil
+}
+
 // PruneOldProofs removes proofs from blocks older than proofPruningDepth
 func (bc *Blockchain) PruneOldProofs() error {
 	bc.chainLock.Lock()
@@ -227,11 +378,16 @@ func (bc *Blockchain) PruneOldProofs() error {
 
 // ProposeBlock creates a new block proposal
 func (bc *Blockchain) ProposeBlock(txIDs []string, proposer string, coinbase *Transaction) *Block {
+	return bc.proposeBlockAt(txIDs, proposer, coinbase, bc.NextBlockTime(time.Now()))
+}
+
+// proposeBlockAt creates a new block proposal stamped timestamp
+func (bc *Blockchain) proposeBlockAt(txIDs []string, proposer string, coinbase *Transaction, timestamp int64) *Block {
 	latest := bc.GetLatestBlock()
 
 	block := &Block{
 		Index:        latest.Index + 1,
-		Timestamp:    time.Now().Unix(),
+		Timestamp:    timestamp,
 		Transactions: txIDs,
 		Coinbase:     coinbase,
 		PreviousHash: latest.Hash,
@@ -269,16 +425,152 @@ func (bc *Blockchain) ValidateBlock(block *Block) error {
 	return nil
 }
 
+// ValidateTransactionExpiry rejects a block that includes a transaction past its TTL.
+// Transactions are looked up in the mempool, then storage; unknown ones are skipped
+// here and rejected by ValidateBlockTransactions.
+func (bc *Blockchain) ValidateTransactionExpiry(block *Block, mempool *Mempool) error {
+	for _, txID := range block.Transactions {
+		var tx *Transaction
+		if mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx != nil && tx.ExpiredAt(block.Index) {
+			return fmt.Errorf("transaction %s expired at block %d, included in block %d",
+				txID, tx.MempoolTTL, block.Index)
+		}
+	}
+	return nil
+}
+
+// ErrBlockInvalid wraps the reason AddBlock rejected a block
+var ErrBlockInvalid = errors.New("block validation failed")
+
 // AddBlock adds a validated block to the chain
 func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 	// Validate first
 	if err := bc.ValidateBlock(block); err != nil {
-		return fmt.Errorf("block validation failed: %w", err)
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockTime(block, time.Now()); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockSize(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockWeight(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockDust(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockTransactions(block, mempool); err != nil {
+		if errors.Is(err, ErrTxNotFound) {
+			return err // Can't be checked here, which doesn't make it invalid
+		}
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateTxOrder(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateTransactionExpiry(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateVestingSpends(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateTokenVersions(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateTokenTickers(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateTokenMints(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateCoinbase(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidatePoolCreations(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateBlockSignatures(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateInputSignatures(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateSpendConditions(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateHTLCSpends(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateCollateral(block, mempool); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
+	}
+	if err := bc.ValidateSettlements(block); err != nil {
+		return fmt.Errorf("%w: %w", ErrBlockInvalid, err)
 	}
 
 	bc.chainLock.Lock()
 	defer bc.chainLock.Unlock()
 
+	// Commit the block's UTXO changes in one database transaction so snapshots see the
+	// state before or after it, never part of it, and nothing if a transaction fails
+	if err := bc.applyBlock(block, mempool); err != nil {
+		return err
+	}
+
+	// Persist to storage
+	if err := bc.store.SaveBlock(block); err != nil {
+		return fmt.Errorf("failed to persist block: %w", err)
+	}
+
+	bc.appendBlockLocked(block)
+	fmt.Printf("🟢 [BLOCK ADDED] Height: %d | TxCount: %d | Hash: %s | Proposer: %s\n",
+		block.Index, len(block.Transactions), block.Hash[:16], block.Proposer[:16])
+
+	// Purge mempool transactions with now-spent inputs
+	if mempool != nil {
+		mempool.PurgeInvalidTransactions(bc.utxoStore, block)
+	}
+
+	// Prune old proofs every 100 blocks to avoid overhead
+	if bc.proofPruningDepth > 0 && block.Index%100 == 0 {
+		go func() {
+			if err := bc.PruneOldProofs(); err != nil {
+				fmt.Printf("[Chain] Warning: Proof pruning failed: %v\n", err)
+			}
+		}()
+	}
+
+	// Prune spent UTXOs on the same cadence
+	if bc.utxoPruneDepth > 0 && block.Index%100 == 0 {
+		go func() {
+			if err := bc.PruneSpentUTXOs(); err != nil {
+				fmt.Printf("[Chain] Warning: UTXO pruning failed: %v\n", err)
+			}
+		}()
+	}
+
+	// And transaction bodies
+	if bc.txPruneDepth > 0 && block.Index%100 == 0 {
+		go func() {
+			if err := bc.PruneTransactions(); err != nil {
+				fmt.Printf("[Chain] Warning: Transaction pruning failed: %v\n", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// applyBlockState applies a block's transactions to the UTXO store and the token and
+// pool registries (caller holds chainLock)
+func (bc *Blockchain) applyBlockState(block *Block, mempool *Mempool) error {
 	// Process coinbase transaction if present
 	if block.Coinbase != nil {
 		if err := bc.utxoStore.StoreTransaction(block.Coinbase, int64(block.Index)); err != nil {
@@ -287,6 +579,9 @@ func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 
 		// Create UTXOs for coinbase outputs
 		coinbaseID, _ := block.Coinbase.ID()
+		if err := bc.utxoStore.recordTxConfirmation(coinbaseID, block.Index, block.Hash); err != nil {
+			return err
+		}
 		for i, output := range block.Coinbase.Outputs {
 			utxo := &UTXO{
 				TxID:        coinbaseID,
@@ -303,12 +598,26 @@ func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 		// fmt.Printf("[Chain] Processed coinbase tx for block %d: %s\n", block.Index, coinbaseID[:16])
 	}
 
+	// Settlements travel in the block; look them up there before the mempool
+	settlements := make(map[string]*Transaction, len(block.Settlements))
+	for _, settlement := range block.Settlements {
+		settlementID, _ := settlement.ID()
+		settlements[settlementID] = settlement
+	}
+
 	// Process regular transactions from mempool
-	tokenRegistry := GetGlobalTokenRegistry()
+	var coinbaseID string
+	if block.Coinbase != nil {
+		coinbaseID, _ = block.Coinbase.ID()
+	}
+	tokenRegistry := bc.tokenRegistry
 	for _, txID := range block.Transactions {
+		if txID == coinbaseID {
+			continue // Applied above
+		}
 		// Get transaction from mempool first, then try storage
-		var tx *Transaction
-		if mempool != nil {
+		tx := settlements[txID]
+		if tx == nil && mempool != nil {
 			tx, _ = mempool.GetTransaction(txID)
 		}
 		if tx == nil {
@@ -316,30 +625,37 @@ func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 			tx, _ = bc.utxoStore.GetTransaction(txID)
 		}
 		if tx == nil {
-			fmt.Printf("[Chain] Warning: Transaction %s not found in mempool or storage, skipping\n", txID[:16])
-			continue
+			return fmt.Errorf("%w: %s", ErrTxNotFound, txID)
 		}
 
 		// Store transaction at this block height
 		if err := bc.utxoStore.StoreTransaction(tx, int64(block.Index)); err != nil {
-			fmt.Printf("[Chain] Warning: Failed to store transaction %s: %v\n", txID[:16], err)
-			continue
+			return fmt.Errorf("failed to store transaction %s: %w", txID, err)
+		}
+		if err := bc.utxoStore.recordTxConfirmation(txID, block.Index, block.Hash); err != nil {
+			fmt.Printf("[Chain] Warning: %v for %s\n", err, txID[:16])
 		}
 
 		// Handle token-specific operations FIRST (updates tx.Outputs[].TokenID from PENDING to actual)
 		if err := bc.utxoStore.ProcessTokenTransaction(tx, tokenRegistry, bc.poolRegistry, int64(block.Index)); err != nil {
-			fmt.Printf("[Chain] Warning: Failed to process token transaction %s: %v\n", txID[:16], err)
+			return fmt.Errorf("%w: transaction %s: %w", ErrBlockInvalid, txID, err)
 		}
 
+		// Convert a token-denominated fee while its inputs are still unspent
+		bc.applyTokenFee(tx, txID, block)
+
 		// Spend inputs (mark UTXOs as spent)
 		for _, input := range tx.Inputs {
-			if err := bc.utxoStore.SpendUTXO(input.PrevTxID, input.OutputIndex); err != nil {
-				fmt.Printf("[Chain] Warning: Failed to spend UTXO %s:%d: %v\n", input.PrevTxID[:16], input.OutputIndex, err)
+			if err := bc.utxoStore.SpendUTXO(input.PrevTxID, input.OutputIndex, block.Index); err != nil {
+				return fmt.Errorf("transaction %s: failed to spend %s:%d: %w", txID, input.PrevTxID, input.OutputIndex, err)
 			}
 		}
 
 		// Create new UTXOs from outputs (after ProcessTokenTransaction fixed the TokenID)
 		for i, output := range tx.Outputs {
+			if output.IsBurn() {
+				continue // Recorded by the token registry, never spendable
+			}
 			utxo := &UTXO{
 				TxID:        txID,
 				OutputIndex: uint32(i),
@@ -348,7 +664,7 @@ func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 				IsSpent:     false,
 			}
 			if err := bc.utxoStore.AddUTXO(utxo); err != nil {
-				fmt.Printf("[Chain] Warning: Failed to add UTXO: %v\n", err)
+				return fmt.Errorf("transaction %s: failed to add output %d: %w", txID, i, err)
 			}
 		}
 
@@ -356,35 +672,44 @@ func (bc *Blockchain) AddBlock(block *Block, mempool *Mempool) error {
 		// fmt.Printf("[Chain] Applied transaction %s (type: %s)\n", txID[:16], tx.TxType.String())
 	}
 
-	// Persist to storage
-	if err := bc.store.SaveBlock(block); err != nil {
-		return fmt.Errorf("failed to persist block: %w", err)
-	}
+	return bc.utxoStore.setAppliedHeight(block.Index)
+}
 
-	bc.blocks = append(bc.blocks, block)
-	fmt.Printf("🟢 [BLOCK ADDED] Height: %d | TxCount: %d | Hash: %s | Proposer: %s\n",
-		block.Index, len(block.Transactions), block.Hash[:16], block.Proposer[:16])
+// AddBlocksBatch adds consecutive blocks with their UTXO and block writes buffered
+// and committed in one database transaction per store. Used by sync, where per-block
+// commits dominate apply time. Blocks added before an error are still committed.
+// mempool supplies transaction bodies that aren't stored yet (nil for none).
+func (bc *Blockchain) AddBlocksBatch(blocks []*Block, mempool *Mempool) error {
+	if err := bc.utxoStore.BeginBatch(); err != nil {
+		return fmt.Errorf("failed to start UTXO batch: %w", err)
+	}
+	if err := bc.store.BeginBatch(); err != nil {
+		bc.utxoStore.DiscardBatch()
+		return fmt.Errorf("failed to start block batch: %w", err)
+	}
 
-	// Purge mempool transactions with now-spent inputs
-	if mempool != nil {
-		mempool.PurgeInvalidTransactions(bc.utxoStore)
+	var addErr error
+	for _, block := range blocks {
+		if err := bc.AddBlock(block, mempool); err != nil {
+			addErr = fmt.Errorf("failed to add block %d: %w", block.Index, err)
+			break
+		}
 	}
 
-	// Prune old proofs every 100 blocks to avoid overhead
-	if bc.proofPruningDepth > 0 && block.Index%100 == 0 {
-		go func() {
-			if err := bc.PruneOldProofs(); err != nil {
-				fmt.Printf("[Chain] Warning: Proof pruning failed: %v\n", err)
-			}
-		}()
+	// Commit UTXOs before blocks so a crash in between replays the blocks on restart
+	if err := bc.utxoStore.CommitBatch(); err != nil {
+		return fmt.Errorf("failed to commit UTXO batch: %w", err)
+	}
+	if err := bc.store.CommitBatch(); err != nil {
+		return fmt.Errorf("failed to commit block batch: %w", err)
 	}
 
-	return nil
+	return addErr
 }
 
-// rebuildTokenRegistry scans all blocks and rebuilds the token registry from mint transactions
+// rebuildTokenRegistry scans all blocks and rebuilds the token registry from mint and burn transactions
 func (bc *Blockchain) rebuildTokenRegistry() error {
-	tokenRegistry := GetGlobalTokenRegistry()
+	tokenRegistry := bc.tokenRegistry
 	tokenCount := 0
 
 	// Scan all blocks for mint transactions
@@ -396,6 +721,14 @@ func (bc *Blockchain) rebuildTokenRegistry() error {
 				continue
 			}
 
+			// Burns are only recorded in the registry, so replay them too
+			if tx.TxType == TxTypeBurn || tx.TxType == TxTypeCreatePool {
+				if err := tokenRegistry.RecordBurns(tx, block.Index); err != nil {
+					fmt.Printf("[Chain] Warning: Failed to restore burn %s: %v\n", txID[:16], err)
+				}
+				continue
+			}
+
 			// Only process mint transactions
 			if tx.TxType == TxTypeMintToken {
 				// Extract token metadata
@@ -426,6 +759,7 @@ func (bc *Blockchain) rebuildTokenRegistry() error {
 
 				// Set token ID to transaction ID
 				tokenInfo.SetTokenID(txID)
+				tokenInfo.MintVersion = mintData.MintVersion
 
 				// Register the token
 				if err := tokenRegistry.RegisterToken(tokenInfo); err != nil {
@@ -443,7 +777,9 @@ func (bc *Blockchain) rebuildTokenRegistry() error {
 	return nil
 }
 
-// rebuildPoolRegistry scans all blocks and rebuilds the pool registry from create pool transactions
+// rebuildPoolRegistry scans all blocks and rebuilds the pool registry from create pool
+// transactions, persisting what it finds. Pools are restored at their creation reserves;
+// --reindex replays liquidity changes and swaps as well.
 func (bc *Blockchain) rebuildPoolRegistry() error {
 	poolCount := 0
 
@@ -498,7 +834,7 @@ func (bc *Blockchain) rebuildPoolRegistry() error {
 				}
 
 				// Get token info for LP token ticker generation
-				tokenRegistry := GetGlobalTokenRegistry()
+				tokenRegistry := bc.tokenRegistry
 				tokenA, existsA := tokenRegistry.GetToken(poolData.TokenA)
 				tokenB, existsB := tokenRegistry.GetToken(poolData.TokenB)
 
@@ -545,7 +881,10 @@ func (bc *Blockchain) rebuildPoolRegistry() error {
 	}
 
 	fmt.Printf("[Chain] Pool registry rebuilt: %d pools restored\n", poolCount)
-	return nil
+	if poolCount > 0 {
+		fmt.Printf("[Chain] Warning: Restored pools have their creation reserves; run with --reindex to replay later liquidity changes and swaps\n")
+	}
+	return bc.utxoStore.markPoolIndex()
 }
 
 // AddVote adds a vote signature to a block
@@ -553,21 +892,20 @@ func (bc *Blockchain) AddVote(blockHash string, vote string) error {
 	bc.chainLock.Lock()
 	defer bc.chainLock.Unlock()
 
-	// Find the block by hash
-	for _, block := range bc.blocks {
-		if block.Hash == blockHash {
-			// Check if vote already exists
-			for _, v := range block.Votes {
-				if v == vote {
-					return fmt.Errorf("vote already exists")
-				}
-			}
-			block.Votes = append(block.Votes, vote)
-			return nil
-		}
+	index, ok := bc.hashIndex[blockHash]
+	if !ok {
+		return fmt.Errorf("block not found")
 	}
+	block := bc.blocks[index]
 
-	return fmt.Errorf("block not found")
+	// Check if vote already exists
+	for _, v := range block.Votes {
+		if v == vote {
+			return fmt.Errorf("vote already exists")
+		}
+	}
+	block.Votes = append(block.Votes, vote)
+	return nil
 }
 
 // GetBlocks returns all blocks (for debugging/API)
@@ -618,6 +956,11 @@ func (bc *Blockchain) GetUTXOStore() *UTXOStore {
 	return bc.utxoStore
 }
 
+// TokenRegistry returns the tokens known to this chain's state
+func (bc *Blockchain) TokenRegistry() *TokenRegistry {
+	return bc.tokenRegistry
+}
+
 // GetPoolRegistry returns the pool registry for this blockchain
 func (bc *Blockchain) GetPoolRegistry() *PoolRegistry {
 	return bc.poolRegistry
@@ -625,6 +968,12 @@ func (bc *Blockchain) GetPoolRegistry() *PoolRegistry {
 
 // Close closes the blockchain and its storage
 func (bc *Blockchain) Close() error {
+	bc.stopOnce.Do(func() {
+		if bc.stopMaintenance != nil {
+			close(bc.stopMaintenance)
+		}
+	})
+	bc.maintenance.Wait() // A compaction in progress finishes before the stores close
 	if bc.utxoStore != nil {
 		bc.utxoStore.Close()
 	}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChainArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src, err := NewBlockchain(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer src.Close()
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+
+	addBlock := func(coinbase *Transaction, txIDs []string) {
+		prev := src.GetLatestBlock()
+		block := &Block{
+			Index:        prev.Index + 1,
+			Timestamp:    prev.Timestamp + 1,
+			Transactions: txIDs,
+			Coinbase:     coinbase,
+			PreviousHash: prev.Hash,
+			Proposer:     "archive-test-proposer",
+		}
+		block.Hash = src.calculateBlockHash(block)
+		if err := src.AddBlock(block, nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	// Alice mines 100, then sends 60 to bob
+	coinbase := &Transaction{
+		Version: 1,
+		TxType:  TxTypeCoinbase,
+		Outputs: []*TxOutput{CreateShadowOutput(alice.Address(), 100)},
+	}
+	addBlock(coinbase, nil)
+	coinbaseID, _ := coinbase.ID()
+
+	send := &Transaction{
+		Version:   1,
+		TxType:    TxTypeSend,
+		Inputs:    []*TxInput{{PrevTxID: coinbaseID, OutputIndex: 0}},
+		Outputs:   []*TxOutput{CreateShadowOutput(bob.Address(), 60), CreateShadowOutput(alice.Address(), 40)},
+		Timestamp: 1,
+	}
+	send.Sign(alice)
+	if err := src.GetUTXOStore().StoreTransaction(send, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	sendID, _ := send.ID()
+	addBlock(nil, []string{sendID})
+
+	// The UTXO set is only exported at the tip
+	archive := filepath.Join(dir, "chain.bak")
+	if _, err := src.ExportArchive(archive, 0, 1, true); err == nil {
+		t.Fatal("Expected a UTXO export below the tip to be refused")
+	}
+	summary, err := src.ExportArchive(archive, 0, 2, true)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if summary.Blocks != 3 || summary.UTXOs != 2 {
+		t.Fatalf("Expected 3 blocks and 2 UTXOs, got %d and %d", summary.Blocks, summary.UTXOs)
+	}
+
+	// A fresh node replays the archive to the same state
+	dst, err := NewBlockchain(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer dst.Close()
+
+	imported, err := dst.ImportArchive(archive)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.Applied != 2 || dst.GetHeight() != 3 || dst.GetLatestBlock().Hash != src.GetLatestBlock().Hash {
+		t.Fatalf("Expected 2 blocks applied up to the source tip, got %d at height %d", imported.Applied, dst.GetHeight())
+	}
+	if bal, _ := dst.GetUTXOStore().GetIndexedBalance(bob.Address(), GetGenesisToken().TokenID); bal != 60 {
+		t.Fatalf("Expected bob balance 60 after import, got %d", bal)
+	}
+
+	// Importing again is a no-op
+	if again, err := dst.ImportArchive(archive); err != nil || again.Applied != 0 {
+		t.Fatalf("Expected a repeated import to apply nothing, got %v", err)
+	}
+
+	// A damaged archive is refused before anything is applied
+	damaged := filepath.Join(dir, "damaged.bak")
+	rewriteArchive(t, archive, damaged, func(s string) string {
+		return strings.Replace(s, "archive-test-proposer", "archive-test-imposter", 1)
+	})
+	if _, err := VerifyArchive(damaged); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+
+	truncated := filepath.Join(dir, "truncated.bak")
+	rewriteArchive(t, archive, truncated, func(s string) string {
+		return s[:strings.Index(s, `{"type":"end"`)]
+	})
+	if _, err := VerifyArchive(truncated); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("Expected a truncated archive to be refused, got %v", err)
+	}
+}
+
+// rewriteArchive copies an archive's contents through edit
+func rewriteArchive(t *testing.T, from, to string, edit func(string) string) {
+	t.Helper()
+	in, err := os.Open(from)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	out, err := os.Create(to)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer out.Close()
+	w := gzip.NewWriter(out)
+	w.Write([]byte(edit(string(data))))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
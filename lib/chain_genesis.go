@@ -0,0 +1,277 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultChainID identifies the network described by DefaultChainGenesis
+const DefaultChainID = "shadowy-testnet-1"
+
+var tickerPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// ChainGenesis defines a Shadowy network. Every node on a network must load the same
+// genesis; nodes with different genesis files produce different genesis blocks and
+// cannot sync with each other.
+type ChainGenesis struct {
+	ChainID              string              `json:"chain_id"`
+	GenesisTime          int64               `json:"genesis_time"`           // Unix seconds, genesis block timestamp
+	BlockIntervalSeconds int                 `json:"block_interval_seconds"` // Target time between blocks
+	RewardSchedule       RewardSchedule      `json:"reward_schedule"`
+	GenesisToken         GenesisTokenParams  `json:"genesis_token"`
+	Allocations          []GenesisAllocation `json:"allocations,omitempty"`  // Paid out by the genesis block coinbase
+	PoolRules            *PoolRules          `json:"pool_rules,omitempty"`   // Pool creation limits, DefaultPoolRules when unset
+	BlockLimits          *BlockLimits        `json:"block_limits,omitempty"` // Block and transaction size limits, defaults when unset
+	Consensus            *ConsensusTiming    `json:"consensus,omitempty"`    // Proof window and vote thresholds, defaults when unset
+	Validators           []ValidatorStake    `json:"validators,omitempty"`   // Stake-weighted block votes, one vote per peer when unset
+	Activations          *RuleActivations    `json:"activations,omitempty"`  // Heights later rules apply from, see RuleActivationHeights
+
+	// Unix seconds until which unsigned consensus messages are accepted; unset uses
+	// DefaultUnsignedConsensusUntil on the built-in network and never on custom ones
+	UnsignedConsensusUntil int64 `json:"unsigned_consensus_until,omitempty"`
+}
+
+// RewardSchedule is the block reward: InitialReward halving every HalvingInterval blocks
+type RewardSchedule struct {
+	InitialReward   uint64 `json:"initial_reward"`   // Base units paid per block before any halving
+	HalvingInterval uint64 `json:"halving_interval"` // Blocks between halvings, 0 = never halve
+}
+
+// GenesisTokenParams defines the base token
+type GenesisTokenParams struct {
+	Ticker      string `json:"ticker"`
+	Desc        string `json:"desc"`
+	MaxMint     uint64 `json:"max_mint"`     // Whole tokens
+	MaxDecimals uint8  `json:"max_decimals"` // Total supply is MaxMint * 10^MaxDecimals base units
+}
+
+// GenesisAllocation pays an address at genesis
+type GenesisAllocation struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"` // Base units
+}
+
+// DefaultChainGenesis returns the built-in network parameters
+func DefaultChainGenesis() *ChainGenesis {
+	return &ChainGenesis{
+		ChainID:              DefaultChainID,
+		GenesisTime:          1704067200, // Jan 1, 2024 00:00:00 UTC
+		BlockIntervalSeconds: 60,
+		RewardSchedule: RewardSchedule{
+			InitialReward:   InitialBlockReward,
+			HalvingInterval: HalvingInterval,
+		},
+		GenesisToken: GenesisTokenParams{
+			Ticker:      "SHADOW",
+			Desc:        "Base token for Shadow Network",
+			MaxMint:     MaxSupply,
+			MaxDecimals: 8,
+		},
+	}
+}
+
+var (
+	activeGenesis     = DefaultChainGenesis()
+	activeGenesisLock sync.RWMutex
+)
+
+// ActiveGenesis returns the genesis this node runs with
+func ActiveGenesis() *ChainGenesis {
+	activeGenesisLock.RLock()
+	defer activeGenesisLock.RUnlock()
+	return activeGenesis
+}
+
+// SetActiveGenesis switches the node to a different network. Must be called before the
+// token registry and blockchain are created.
+func SetActiveGenesis(g *ChainGenesis) {
+	activeGenesisLock.Lock()
+	defer activeGenesisLock.Unlock()
+	activeGenesis = g
+}
+
+// LoadChainGenesis reads and validates a genesis file
+func LoadChainGenesis(path string) (*ChainGenesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	// Start from the defaults so a file only needs the fields it changes
+	g := DefaultChainGenesis()
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis file: %w", err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %w", err)
+	}
+	return g, nil
+}
+
+// Validate checks the genesis parameters
+func (g *ChainGenesis) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("chain_id cannot be empty")
+	}
+	if g.GenesisTime <= 0 {
+		return fmt.Errorf("genesis_time must be a positive unix timestamp")
+	}
+	if err := g.validateConsensusTiming(); err != nil {
+		return err
+	}
+
+	params := g.GenesisToken
+	if len(params.Ticker) < 3 || len(params.Ticker) > 32 || !tickerPattern.MatchString(params.Ticker) {
+		return fmt.Errorf("genesis token ticker must be 3-32 characters of A-Z, a-z, 0-9")
+	}
+	if params.MaxMint == 0 || params.MaxMint > MaxSupply {
+		return fmt.Errorf("genesis token max_mint must be 1 to %d, got %d", MaxSupply, params.MaxMint)
+	}
+	if params.MaxDecimals > 8 {
+		return fmt.Errorf("genesis token max_decimals cannot exceed 8, got %d", params.MaxDecimals)
+	}
+	token := g.TokenInfo()
+
+	// Bonded stake is never paid out but comes out of the supply like an allocation
+	allocated, err := g.validateValidators()
+	if err != nil {
+		return err
+	}
+	for i, alloc := range g.Allocations {
+		if _, _, err := ParseAddress(alloc.Address); err != nil {
+			return fmt.Errorf("allocation %d has invalid address: %w", i, err)
+		}
+		if alloc.Amount == 0 {
+			return fmt.Errorf("allocation %d has zero amount", i)
+		}
+		if allocated+alloc.Amount < allocated {
+			return fmt.Errorf("allocations overflow")
+		}
+		allocated += alloc.Amount
+	}
+	if allocated > token.TotalSupply {
+		return fmt.Errorf("allocations and validator stake (%d) exceed total supply (%d)", allocated, token.TotalSupply)
+	}
+
+	if limits := g.BlockSizeLimits(); limits.MaxTxBytes > limits.MaxBlockBytes {
+		return fmt.Errorf("block_limits max_tx_bytes (%d) exceeds max_block_bytes (%d)", limits.MaxTxBytes, limits.MaxBlockBytes)
+	}
+	if limits := g.BlockSizeLimits(); limits.MaxTxBytes > limits.MaxBlockWeight {
+		return fmt.Errorf("block_limits max_tx_bytes (%d) exceeds max_block_weight (%d)", limits.MaxTxBytes, limits.MaxBlockWeight)
+	}
+	return nil
+}
+
+// BlockInterval returns the target time between blocks
+func (g *ChainGenesis) BlockInterval() time.Duration {
+	return time.Duration(g.BlockIntervalSeconds) * time.Second
+}
+
+// BlockReward returns the coinbase reward at a height
+func (g *ChainGenesis) BlockReward(blockHeight uint64) uint64 {
+	if g.RewardSchedule.HalvingInterval == 0 {
+		return g.RewardSchedule.InitialReward
+	}
+
+	// After 64 halvings the reward is zero
+	halvings := blockHeight / g.RewardSchedule.HalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return g.RewardSchedule.InitialReward >> halvings
+}
+
+// TokenInfo builds the base token described by the genesis
+func (g *ChainGenesis) TokenInfo() *TokenInfo {
+	var genesisAddr Address // Zero address for system-created tokens
+	params := g.GenesisToken
+
+	totalSupply := params.MaxMint
+	for i := uint8(0); i < params.MaxDecimals; i++ {
+		totalSupply *= 10
+	}
+
+	return &TokenInfo{
+		TokenID:        genesisTokenID(params.Ticker, g.GenesisTime, params.MaxMint, params.MaxDecimals),
+		Ticker:         params.Ticker,
+		Desc:           params.Desc,
+		MaxMint:        params.MaxMint,
+		MaxDecimals:    params.MaxDecimals,
+		TotalSupply:    totalSupply,
+		LockedShadow:   0, // Base token doesn't lock SHADOW
+		TotalMelted:    0,
+		MintVersion:    0,
+		CreatorAddress: genesisAddr,
+		CreationTime:   g.GenesisTime,
+	}
+}
+
+// Fingerprint is a hash of every genesis parameter
+func (g *ChainGenesis) Fingerprint() string {
+	data, _ := json.Marshal(g)
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// IsDefault reports whether this is the built-in network
+func (g *ChainGenesis) IsDefault() bool {
+	return g.Fingerprint() == DefaultChainGenesis().Fingerprint()
+}
+
+// Topic scopes a pubsub topic to this network so custom networks never share a gossip
+// mesh. The built-in network keeps its original topic names, so nodes that predate
+// chain IDs still hear it.
+func (g *ChainGenesis) Topic(base string) string {
+	if g.IsDefault() {
+		return base
+	}
+	return base + "/" + g.ChainID
+}
+
+// AcceptsChainID reports whether a gossip message stamped with chainID belongs to this
+// network. Nodes that predate chain IDs send none, which only the built-in network accepts.
+func (g *ChainGenesis) AcceptsChainID(chainID string) bool {
+	return chainID == g.ChainID || (chainID == "" && g.IsDefault())
+}
+
+// Block builds the genesis block. Allocations are paid by its coinbase. The block hash
+// doesn't cover the coinbase, so custom networks put the genesis fingerprint in the
+// proposer field; the built-in network keeps its original genesis hash.
+func (g *ChainGenesis) Block(bc *Blockchain) *Block {
+	proposer := "genesis"
+	if !g.IsDefault() {
+		proposer = "genesis:" + g.Fingerprint()
+	}
+
+	genesis := &Block{
+		Index:        0,
+		Timestamp:    g.GenesisTime,
+		Transactions: []string{},
+		PreviousHash: "0",
+		Proposer:     proposer,
+		Votes:        []string{},
+	}
+
+	if len(g.Allocations) > 0 {
+		coinbase := &Transaction{
+			Version:   1,
+			TxType:    TxTypeCoinbase,
+			Timestamp: g.GenesisTime,
+			TokenID:   g.TokenInfo().TokenID,
+		}
+		for _, alloc := range g.Allocations {
+			addr, _, _ := ParseAddress(alloc.Address) // Checked by Validate
+			coinbase.Outputs = append(coinbase.Outputs, CreateShadowOutput(addr, alloc.Amount))
+		}
+		genesis.Coinbase = coinbase
+	}
+
+	genesis.Hash = bc.calculateBlockHash(genesis)
+	return genesis
+}
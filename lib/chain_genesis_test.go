@@ -0,0 +1,243 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultGenesisUnchanged(t *testing.T) {
+	genesis := DefaultChainGenesis()
+	if err := genesis.Validate(); err != nil {
+		t.Fatalf("Default genesis is invalid: %v", err)
+	}
+	if !genesis.IsDefault() {
+		t.Error("Default genesis should report IsDefault")
+	}
+
+	const shadowID = "7abf97c6d93541347a766a339fde7fc175a77ef8953b33953f5c7dc022993132"
+	if id := genesis.TokenInfo().TokenID; id != shadowID {
+		t.Errorf("Expected SHADOW token ID %s, got %s", shadowID, id)
+	}
+	if topic := genesis.Topic(ConsensusTopic); topic != ConsensusTopic {
+		t.Errorf("Default network should keep topic %s, got %s", ConsensusTopic, topic)
+	}
+	if !genesis.AcceptsChainID("") || !genesis.AcceptsChainID(DefaultChainID) || genesis.AcceptsChainID("other-net") {
+		t.Error("Default network should accept its own and legacy (empty) chain IDs only")
+	}
+	if reward := genesis.BlockReward(HalvingInterval); reward != InitialBlockReward/2 {
+		t.Errorf("Expected halved reward %d, got %d", InitialBlockReward/2, reward)
+	}
+}
+
+func TestCustomGenesis(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	data := `{
+		"chain_id": "team-devnet",
+		"block_interval_seconds": 10,
+		"reward_schedule": {"initial_reward": 5000, "halving_interval": 0},
+		"genesis_token": {"ticker": "DEV", "desc": "Devnet token", "max_mint": 1000000, "max_decimals": 2},
+		"allocations": [{"address": "` + alice.Address().String() + `", "amount": 12345}]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write genesis: %v", err)
+	}
+
+	genesis, err := LoadChainGenesis(path)
+	if err != nil {
+		t.Fatalf("Failed to load genesis: %v", err)
+	}
+	if genesis.GenesisTime != DefaultChainGenesis().GenesisTime {
+		t.Errorf("Unset fields should keep defaults, got genesis_time %d", genesis.GenesisTime)
+	}
+	if genesis.BlockReward(1_000_000) != 5000 {
+		t.Errorf("Reward should never halve with halving_interval 0")
+	}
+
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	token := GetGenesisToken()
+	if token.Ticker != "DEV" || token.TotalSupply != 100_000_000 {
+		t.Errorf("Expected DEV with supply 100000000, got %s with %d", token.Ticker, token.TotalSupply)
+	}
+
+	chainPath := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(chainPath)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	if bc.GetBlock(0).Hash == DefaultChainGenesis().Block(bc).Hash {
+		t.Error("Custom genesis should produce a different genesis hash")
+	}
+	balance, _ := bc.utxoStore.GetIndexedBalance(alice.Address(), token.TokenID)
+	if balance != 12345 {
+		t.Errorf("Expected allocation of 12345, got %d", balance)
+	}
+	bc.Close()
+
+	// The data dir now belongs to team-devnet
+	SetActiveGenesis(DefaultChainGenesis())
+	if bc, err := NewBlockchain(chainPath); err == nil {
+		bc.Close()
+		t.Error("Opening a custom network's data dir with the default genesis should fail")
+	}
+}
+
+func TestGenesisValidation(t *testing.T) {
+	cases := map[string]func(g *ChainGenesis){
+		"empty chain id":      func(g *ChainGenesis) { g.ChainID = "" },
+		"zero interval":       func(g *ChainGenesis) { g.BlockIntervalSeconds = 0 },
+		"bad ticker":          func(g *ChainGenesis) { g.GenesisToken.Ticker = "X!" },
+		"too many decimals":   func(g *ChainGenesis) { g.GenesisToken.MaxDecimals = 9 },
+		"bad allocation addr": func(g *ChainGenesis) { g.Allocations = []GenesisAllocation{{Address: "nope", Amount: 1}} },
+		"interval too long":   func(g *ChainGenesis) { g.BlockIntervalSeconds = MaxBlockIntervalSeconds + 1 },
+		"proof window":        func(g *ChainGenesis) { g.Consensus = &ConsensusTiming{ProofWindowSeconds: 60} },
+		"minority votes":      func(g *ChainGenesis) { g.Consensus = &ConsensusTiming{VoteThreshold: 0.4} },
+		"unreachable quorum":  func(g *ChainGenesis) { g.Consensus = &ConsensusTiming{QuorumThreshold: 1} },
+	}
+	for name, mutate := range cases {
+		genesis := DefaultChainGenesis()
+		mutate(genesis)
+		if err := genesis.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestConsensusTiming(t *testing.T) {
+	params := DefaultChainGenesis().ConsensusRules()
+	if params.BlockInterval != BlockInterval || params.ProofWindow != ProofWindow {
+		t.Errorf("Expected default timing %v/%v, got %v/%v", BlockInterval, ProofWindow, params.BlockInterval, params.ProofWindow)
+	}
+	if params.RequiredVotes(4) != 3 || params.RequiredVotes(5) != 3 {
+		t.Errorf("Expected a simple majority of nodes to be required")
+	}
+
+	// A fast testnet scales the proof window with its interval
+	fast := DefaultChainGenesis()
+	fast.BlockIntervalSeconds = 2
+	if err := fast.Validate(); err != nil {
+		t.Fatalf("2-second blocks should be valid: %v", err)
+	}
+	if window := fast.ConsensusRules().ProofWindow; window >= 2*time.Second || window <= 0 {
+		t.Errorf("Expected the proof window to fit in the interval, got %v", window)
+	}
+
+	strict := DefaultChainGenesis()
+	strict.BlockIntervalSeconds = 600
+	strict.Consensus = &ConsensusTiming{ProofWindowSeconds: 540, VoteThreshold: 0.66, QuorumThreshold: 0.75}
+	if err := strict.Validate(); err != nil {
+		t.Fatalf("Strict timing should be valid: %v", err)
+	}
+	params = strict.ConsensusRules()
+	if params.ProofWindow != 540*time.Second || params.RequiredVotes(4) != 4 {
+		t.Errorf("Expected the configured timing, got %+v", params)
+	}
+	if params.Approved(2, 3, 4) || !params.Approved(4, 4, 4) || params.Approved(2, 4, 4) {
+		t.Error("Expected approval to need the quorum and more than 66% yes votes")
+	}
+	if DefaultChainGenesis().Fingerprint() == strict.Fingerprint() {
+		t.Error("Consensus timing should change the genesis fingerprint")
+	}
+}
+
+func TestSignatureBoundToChainID(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+	tx := NewTxBuilder(TxTypeSend).
+		AddInput("prev", 0).
+		AddOutput(alice.Address(), 100, "").
+		Build()
+	if err := tx.Sign(alice); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	id, _ := tx.ID()
+
+	verify := func() bool {
+		hash, err := tx.SigningHash()
+		if err != nil {
+			t.Fatalf("Failed to hash: %v", err)
+		}
+		return VerifySignature(hash, tx.Signature, alice.PublicKey)
+	}
+	if !verify() {
+		t.Fatal("Signature should verify on the network it was made for")
+	}
+
+	other := DefaultChainGenesis()
+	other.ChainID = "replay-net"
+	SetActiveGenesis(other)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	if verify() {
+		t.Error("Signature should not verify on a different chain ID")
+	}
+	if otherID, _ := tx.ID(); otherID != id {
+		t.Error("Transaction ID should not depend on the chain ID")
+	}
+}
+
+func TestCustomGenesisNamespacesGossip(t *testing.T) {
+	genesis := DefaultChainGenesis()
+	genesis.ChainID = "topic-net"
+	if topic := genesis.Topic(ConsensusTopic); topic != ConsensusTopic+"/topic-net" {
+		t.Errorf("Custom network topic should be namespaced by chain ID, got %s", topic)
+	}
+	if genesis.AcceptsChainID("") || genesis.AcceptsChainID(DefaultChainID) || !genesis.AcceptsChainID("topic-net") {
+		t.Error("Custom network should only accept its own chain ID")
+	}
+}
+
+func TestLegacySignedBlockStillValidates(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	for i := uint32(0); i < 2; i++ {
+		if err := store.AddUTXO(&UTXO{TxID: "legacy-test-funding", OutputIndex: i, Output: CreateShadowOutput(kp.Address(), 1000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	// Signed the way nodes did before chain binding: over the bare transaction hash
+	signedOverHash := func(index uint32, version uint32) *Transaction {
+		tx := NewTxBuilder(TxTypeSend).AddInput("legacy-test-funding", index).AddOutput(kp.Address(), 900, "").Build()
+		tx.Version = version
+		hash, _ := tx.Hash()
+		signature, err := NewLocalSigner(kp).Sign(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		tx.PublicKey, _ = PublicKeyToBytes(kp.PublicKey)
+		tx.Signature = signature
+		return tx
+	}
+
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	propose := func(tx *Transaction) (*Block, string) {
+		id, _ := tx.ID()
+		mempool.entries[id] = &MempoolEntry{Tx: tx}
+		return bc.ProposeBlock([]string{id}, "legacy-test-proposer", nil), id
+	}
+
+	legacy, legacyID := propose(signedOverHash(0, LegacyTxVersion))
+	if err := bc.AddBlock(legacy, mempool); err != nil {
+		t.Fatalf("Expected a block with a version 1 transaction signed before the upgrade to apply: %v", err)
+	}
+	if utxo, _ := store.GetUTXO(legacyID, 0); utxo == nil {
+		t.Fatal("Expected the legacy transaction's output to be created")
+	}
+
+	// Chain-bound versions must sign the chain-bound hash
+	unbound, _ := propose(signedOverHash(1, ChainBoundTxVersion))
+	if err := bc.AddBlock(unbound, mempool); !errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected a version 2 transaction signed without the chain ID to be rejected, got %v", err)
+	}
+}
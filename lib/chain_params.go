@@ -0,0 +1,123 @@
+package lib
+
+import "time"
+
+// Client libraries read the chain parameters from /api/chain/params instead of
+// hard-coding them, so one library works against mainnet, test networks with their own
+// genesis, and nodes that support newer transaction versions. Features name optional
+// node capabilities a client can check for before relying on them.
+
+// NodeFeatures are the optional capabilities this node supports
+var NodeFeatures = []string{
+	"canonical_tx_json",      // Version 2 transactions hash canonical JSON
+	"token_fees",             // Fees paid in a token with a SHADOW pool
+	"vesting_outputs",        // Outputs locked until a height
+	"output_conditions",      // Descriptor spend conditions (multisig, timelocks, hashlocks)
+	"htlc",                   // HTLC lock, claim and refund for cross-chain atomic swaps
+	"memos",                  // Send memos, searchable with /api/tx/search
+	"tx_simulate",            // POST /api/tx/simulate
+	"tx_build",               // POST /api/tx/build for external signers
+	"replace_by_fee",         // Higher-fee double-spends replace pending transactions
+	"idempotency_keys",       // Idempotency-Key on write requests
+	"batch_balances",         // POST /api/balances
+	"events_ws",              // Node events over /api/ws
+	"tx_confirmation_status", // pending/confirmed/finalized/orphaned in /api/tx
+}
+
+// TxTypeParams describes a transaction type the chain accepts
+type TxTypeParams struct {
+	Type     TxType   `json:"type"`
+	Name     string   `json:"name"`
+	Versions []uint32 `json:"versions"`            // Transaction versions accepted
+	Weight   int      `json:"weight"`              // Fixed weight on top of size, inputs and outputs
+	NodeOnly bool     `json:"node_only,omitempty"` // Created by block proposers, not clients
+}
+
+// FeeParams are the fees a transaction should pay and the smallest outputs it may create
+type FeeParams struct {
+	MinFee             uint64 `json:"min_fee"`              // Smallest fee node-built transactions pay, base units
+	FeePerInput        uint64 `json:"fee_per_input"`        // Estimated fee per input, base units
+	MinFeeRate         uint64 `json:"min_fee_rate"`         // Network minimum fee per 1000 weight, 0 = none
+	DustThreshold      uint64 `json:"dust_threshold"`       // Network minimum SHADOW output, 0 = none
+	MinRelayFee        uint64 `json:"min_relay_fee"`        // This node's relay floor, 0 = none
+	MinRelayFeeRate    uint64 `json:"min_relay_fee_rate"`   // This node's relay floor per 1000 weight, 0 = none
+	RelayDustThreshold uint64 `json:"relay_dust_threshold"` // This node's minimum SHADOW output, 0 = the network's
+	PoolCreationFee    uint64 `json:"pool_creation_fee"`    // SHADOW burned by a pool creation, base units
+}
+
+// ChainParams describes the network a node runs
+type ChainParams struct {
+	ChainID              string           `json:"chain_id"`
+	GenesisHash          string           `json:"genesis_hash"`
+	GenesisFingerprint   string           `json:"genesis_fingerprint"` // Hash of every genesis parameter
+	GenesisTime          int64            `json:"genesis_time"`
+	BlockIntervalSeconds int              `json:"block_interval_seconds"`
+	Height               uint64           `json:"height"`
+	MedianTimePast       int64            `json:"median_time_past"`             // Earliest timestamp the next block may carry
+	MaxBlockTimeDrift    int              `json:"max_block_time_drift_seconds"` // How far ahead of a validator's clock a block may be stamped
+	RewardSchedule       RewardSchedule   `json:"reward_schedule"`
+	CurrentReward        uint64           `json:"current_reward"` // Reward of the next block, base units
+	Token                *TokenInfo       `json:"token"`
+	Fees                 FeeParams        `json:"fees"`
+	BlockLimits          BlockLimits      `json:"block_limits"`
+	PoolRules            PoolRules        `json:"pool_rules"`
+	FinalityDepth        uint64           `json:"finality_depth"` // Confirmations before a transaction is final
+	TxVersion            uint32           `json:"tx_version"`     // Version clients should build
+	TxTypes              []TxTypeParams   `json:"tx_types"`
+	Features             []string         `json:"features"`
+	Validators           []ValidatorStake `json:"validators,omitempty"` // Stake-weighted voters, none = one vote per peer
+}
+
+// ChainParams returns the parameters of the chain's network and the node's relay policy
+func (bc *Blockchain) ChainParams(relay RelayPolicy) *ChainParams {
+	genesis := ActiveGenesis()
+	height := bc.GetHeight()
+	limits := genesis.BlockSizeLimits()
+
+	params := &ChainParams{
+		ChainID:              genesis.ChainID,
+		GenesisFingerprint:   genesis.Fingerprint(),
+		GenesisTime:          genesis.GenesisTime,
+		BlockIntervalSeconds: genesis.BlockIntervalSeconds,
+		Height:               height,
+		MedianTimePast:       bc.TipMedianTimePast(),
+		MaxBlockTimeDrift:    int(MaxBlockTimeDrift / time.Second),
+		RewardSchedule:       genesis.RewardSchedule,
+		CurrentReward:        genesis.BlockReward(height),
+		Token:                genesis.TokenInfo(),
+		Fees: FeeParams{
+			MinFee:             MinBuildFee,
+			FeePerInput:        BuildFeePerInput,
+			MinFeeRate:         limits.MinFeePerKWeight,
+			DustThreshold:      limits.DustThreshold,
+			MinRelayFee:        relay.MinFee,
+			MinRelayFeeRate:    relay.MinFeeRate,
+			RelayDustThreshold: relay.DustThreshold,
+			PoolCreationFee:    genesis.PoolCreationRules().CreationFee,
+		},
+		BlockLimits:   limits,
+		PoolRules:     genesis.PoolCreationRules(),
+		FinalityDepth: FinalityDepth,
+		TxVersion:     CanonicalTxVersion,
+		Features:      NodeFeatures,
+		Validators:    genesis.Validators,
+	}
+	if block := bc.GetBlock(0); block != nil {
+		params.GenesisHash = block.Hash
+	}
+
+	var versions []uint32
+	for v := uint32(LegacyTxVersion); v <= MaxTxVersion; v++ {
+		versions = append(versions, v)
+	}
+	for t := TxTypeCoinbase; t <= MaxTxType; t++ {
+		params.TxTypes = append(params.TxTypes, TxTypeParams{
+			Type:     t,
+			Name:     t.String(),
+			Versions: versions,
+			Weight:   TxTypeWeight(t),
+			NodeOnly: t.NodeOnly(),
+		})
+	}
+	return params
+}
@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestChainParams(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	params := bc.ChainParams(RelayPolicy{MinFee: 2000})
+	if params.ChainID != ActiveGenesis().ChainID || params.GenesisHash != bc.GetBlock(0).Hash {
+		t.Fatalf("Unexpected network identity: %s %s", params.ChainID, params.GenesisHash)
+	}
+	if params.Fees.MinFee != MinBuildFee || params.Fees.MinRelayFee != 2000 || params.CurrentReward != ActiveGenesis().BlockReward(1) {
+		t.Fatalf("Unexpected fees or reward: %+v %d", params.Fees, params.CurrentReward)
+	}
+	if params.TxVersion != CanonicalTxVersion || len(params.TxTypes) != int(MaxTxType)+1 {
+		t.Fatalf("Expected version %d and %d types, got %d and %d", CanonicalTxVersion, MaxTxType+1, params.TxVersion, len(params.TxTypes))
+	}
+
+	// Every type lists the versions up to the newest one validation accepts
+	for _, tt := range params.TxTypes {
+		if tt.Name != tt.Type.String() || len(tt.Versions) == 0 || tt.Versions[len(tt.Versions)-1] != MaxTxVersion {
+			t.Fatalf("Unexpected type parameters: %+v", tt)
+		}
+		if tt.NodeOnly != (tt.Type == TxTypeCoinbase || tt.Type == TxTypeMatchOffers) {
+			t.Fatalf("Unexpected node_only for %s", tt.Name)
+		}
+	}
+}
@@ -50,3 +50,22 @@ func (bc *Blockchain) GetCurrentChallenge() [32]byte {
 
 	return GenerateChallenge(prevHash, height, time.Now().Unix())
 }
+
+// IsRecentChallenge reports whether challenge is the challenge for the next block in the
+// current or previous 10-second window, so proofs computed just before a window
+// boundary are still accepted
+func (bc *Blockchain) IsRecentChallenge(challenge [32]byte) bool {
+	bc.chainLock.RLock()
+	prevHash := ""
+	height := uint64(0)
+	if len(bc.blocks) > 0 {
+		lastBlock := bc.blocks[len(bc.blocks)-1]
+		prevHash = lastBlock.Hash
+		height = lastBlock.Index + 1
+	}
+	bc.chainLock.RUnlock()
+
+	now := time.Now().Unix()
+	return challenge == GenerateChallenge(prevHash, height, now) ||
+		challenge == GenerateChallenge(prevHash, height, now-10)
+}
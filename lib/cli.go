@@ -24,6 +24,50 @@ type CLIConfig struct {
 	MempoolMaxSizeMB      int      `mapstructure:"mempool_max_size_mb" json:"mempool_max_size_mb"`           // Maximum mempool size in MB (default: 300)
 	APIKey                string   `mapstructure:"api_key" json:"api_key"`                                   // Optional API key for write endpoints (env: SHADOWY_API_KEY)
 	ProofPruningDepth     int      `mapstructure:"proof_pruning_depth" json:"proof_pruning_depth"`           // Keep proofs for last N blocks, 0 = keep all (museum mode), default: 10000
+	UTXOPruneDepth        int      `mapstructure:"utxo_prune_depth" json:"utxo_prune_depth"`                 // Delete spent UTXOs older than N blocks, 0 = keep all (default), min 100
+	TxPruneDepth          int      `mapstructure:"tx_prune_depth" json:"tx_prune_depth"`                     // Delete spent transaction bodies older than N blocks, 0 = keep all (default), min 100
+	DBCompactionHours     int      `mapstructure:"db_compaction_hours" json:"db_compaction_hours"`           // Compact block/UTXO DBs every N hours, 0 = disabled, default: 24
+	UTXOCacheSize         int      `mapstructure:"utxo_cache_size" json:"utxo_cache_size"`                   // Max UTXOs kept in the in-memory LRU cache (default: 100000)
+	UTXOCacheMB           int      `mapstructure:"utxo_cache_mb" json:"utxo_cache_mb"`                       // Memory budget of the UTXO LRU cache in MB, 0 = entry count only (default)
+	MinRelayFee           uint64   `mapstructure:"min_relay_fee" json:"min_relay_fee"`                       // Minimum fee (base units) a tx must pay to be accepted and relayed, 0 = no floor
+	MinRelayFeeRate       uint64   `mapstructure:"min_relay_fee_rate" json:"min_relay_fee_rate"`             // Minimum fee (base units) per 1000 weight a tx must pay to be accepted and relayed, 0 = no floor
+	DustThreshold         uint64   `mapstructure:"dust_threshold" json:"dust_threshold"`                     // Smallest SHADOW output (base units) a relayed tx may create, 0 = the network's threshold
+	MinOutboundPeers      int      `mapstructure:"min_outbound_peers" json:"min_outbound_peers"`             // Re-bootstrap when outbound peers drop below this (default: 4)
+	TargetOutboundPeers   int      `mapstructure:"target_outbound_peers" json:"target_outbound_peers"`       // Dial peers learned by peer exchange until this many outbound (default: 8)
+	PeerExchange          bool     `mapstructure:"peer_exchange" json:"peer_exchange"`                       // Gossip and learn signed peer addresses (default: true)
+	MaxPeersPerSubnet     int      `mapstructure:"max_peers_per_subnet" json:"max_peers_per_subnet"`         // Outbound peers allowed per /16 subnet, 0 = unlimited (default: 2)
+	GenesisFile           string   `mapstructure:"genesis_file" json:"genesis_file"`                         // Chain genesis file for custom networks (empty = built-in network)
+	RewardAddress         string   `mapstructure:"reward_address" json:"reward_address"`                     // Wallet address paid block rewards and fees (empty = node wallet)
+	PoolOperator          bool     `mapstructure:"pool_operator" json:"pool_operator"`                       // Run a mining pool: accept partial proofs from farmers and split rewards
+	PoolPayoutBlocks      int      `mapstructure:"pool_payout_blocks" json:"pool_payout_blocks"`             // Pool operator: pay farmers every N blocks
+	PoolFeePercent        int      `mapstructure:"pool_fee_percent" json:"pool_fee_percent"`                 // Pool operator: percent of pool rewards kept as the operator fee
+	PoolURL               string   `mapstructure:"pool_url" json:"pool_url"`                                 // Farm for a pool: submit proofs to this pool operator API instead of solo farming
+	AcceptTokenFees       bool     `mapstructure:"accept_token_fees" json:"accept_token_fees"`               // Count fees paid in custom tokens at their SHADOW pool price (relay floor and block selection)
+	APISigners            []string `mapstructure:"api_signers" json:"api_signers"`                           // Addresses whose signed requests may use the node wallet's write endpoints (the node wallet's own key always may)
+	AddressFormat         string   `mapstructure:"address_format" json:"address_format"`                     // Address display format in API output: hex or bech32m (both are always parsed)
+	AcceptHexAddresses    bool     `mapstructure:"accept_hex_addresses" json:"accept_hex_addresses"`         // With bech32m display: keep accepting hex addresses in API input (turn off to end the migration window)
+	DataDir               string   `mapstructure:"datadir" json:"datadir"`                                   // Root directory for the blockchain, UTXO store, wallet and other node files (empty = working directory, wallet in ~/.sn)
+	PIDFile               string   `mapstructure:"pid_file" json:"pid_file"`                                 // Write the node's PID to this file while running (empty = none)
+	ReadyMaxLag           int      `mapstructure:"ready_max_lag" json:"ready_max_lag"`                       // /readyz fails while the chain is more than N blocks behind the best peer (default: 5)
+	Archive               bool     `mapstructure:"archive" json:"archive"`                                   // Keep every block proof, spent UTXO and transaction body (disables pruning) for full history
+	ReadOnly              bool     `mapstructure:"readonly" json:"readonly"`                                 // Serve chain queries only: no wallet, no farming, write endpoints return 403
+	RebroadcastBlocks     int      `mapstructure:"rebroadcast_blocks" json:"rebroadcast_blocks"`             // Rebroadcast unconfirmed local transactions every N blocks, 0 = never (default: 10)
+	UTXOInMemory          bool     `mapstructure:"utxo_in_memory" json:"utxo_in_memory"`                     // Load the full unspent set into memory (write-through) so validation and block application skip per-key DB reads
+	AddressGapLimit       int      `mapstructure:"address_gap_limit" json:"address_gap_limit"`               // Unused receive addresses /api/wallet/new_address hands out at most (default: 20)
+	ConsensusEngine       string   `mapstructure:"consensus_engine" json:"consensus_engine"`                 // Consensus runtime; only "gossip" is supported (default: gossip)
+	MemoIndex             bool     `mapstructure:"memo_index" json:"memo_index"`                             // Index send memos for /api/tx/search (default: false)
+	APIListenAddr         string   `mapstructure:"api_listen_addr" json:"api_listen_addr"`                   // API bind address, host or host:port (empty = all interfaces on api_port)
+	P2PListenAddrs        []string `mapstructure:"p2p_listen_addrs" json:"p2p_listen_addrs"`                 // P2P listen multiaddrs (empty = IPv4 and IPv6 on all interfaces on p2p_port)
+	LocalhostOnly         bool     `mapstructure:"localhost_only" json:"localhost_only"`                     // Bind the API and P2P to loopback where no listen address is given
+	BackupIntervalHours   int      `mapstructure:"backup_interval_hours" json:"backup_interval_hours"`       // Back up the wallet and chain checkpoint every N hours, 0 = only through /api/admin/backup/now
+	BackupDir             string   `mapstructure:"backup_dir" json:"backup_dir"`                             // Where backups are written (empty = backups under the data directory)
+	BackupKeep            int      `mapstructure:"backup_keep" json:"backup_keep"`                           // Newest backups kept in backup_dir (default: 7)
+	BackupS3URL           string   `mapstructure:"backup_s3_url" json:"backup_s3_url"`                       // Also upload backups to this S3-compatible bucket, https://host/bucket[/prefix] (empty = local only)
+	BackupS3Region        string   `mapstructure:"backup_s3_region" json:"backup_s3_region"`                 // Region S3 uploads are signed for (default: us-east-1)
+	FailoverRole          string   `mapstructure:"failover_role" json:"failover_role"`                       // Pair with another node sharing the wallet: primary or standby, only the lease holder proposes (empty = no pairing)
+	SyncMaxBlocks         int      `mapstructure:"sync_max_blocks" json:"sync_max_blocks"`                   // Blocks served to a syncing peer per response, longer ranges are paginated (default: 100)
+	SyncMaxSessions       int      `mapstructure:"sync_max_sessions" json:"sync_max_sessions"`               // Block and header requests from syncing peers served at once (default: 8)
+	SyncPeerBandwidthKB   int      `mapstructure:"sync_peer_bandwidth_kb" json:"sync_peer_bandwidth_kb"`     // KiB per second sent to each syncing peer, 0 = unlimited (default: 1024)
 
 	// Plot generation mode
 	PlotMode    bool   `mapstructure:"plot_mode" json:"plot_mode"`       // Generate plot file instead of running node
@@ -31,8 +75,33 @@ type CLIConfig struct {
 	PlotDir     string `mapstructure:"plot_dir" json:"plot_dir"`         // Output directory for plot file
 	PlotVerbose bool   `mapstructure:"plot_verbose" json:"plot_verbose"` // Verbose output during plotting
 
+	// Maintenance
+	Reindex   bool `mapstructure:"-" json:"-"` // Rebuild UTXO, token, and pool state from stored blocks on startup (one-shot, not saved to config)
+	AuditMode bool `mapstructure:"-" json:"-"` // Run the chain consistency audit and exit ("audit" subcommand)
+
+	// Wallet key export and import ("wallet" subcommand), run instead of the node
+	WalletMode bool     `mapstructure:"-" json:"-"`
+	WalletArgs []string `mapstructure:"-" json:"-"` // Arguments after "wallet"
+
+	// Chain backup export and import ("export"/"import" subcommands), run instead of the node
+	ArchiveMode bool     `mapstructure:"-" json:"-"`
+	ArchiveArgs []string `mapstructure:"-" json:"-"` // Subcommand and its arguments
+
+	// Offline read-only inspection of the data directory ("inspect" subcommand)
+	InspectMode bool     `mapstructure:"-" json:"-"`
+	InspectArgs []string `mapstructure:"-" json:"-"` // Arguments after "inspect"
+
 	// Wallet encryption
 	WalletPassword string `mapstructure:"wallet_password" json:"-"` // Wallet encryption passphrase (not saved to config, env: SHADOWY_WALLET_PASSWORD)
+
+	// Remote signer (keys held by an external HSM-like service)
+	RemoteSignerURL   string `mapstructure:"remote_signer_url" json:"remote_signer_url"`       // Base URL of remote signing service, empty = sign locally
+	RemoteSignerKeyID string `mapstructure:"remote_signer_key_id" json:"remote_signer_key_id"` // Key identifier on the remote signer
+	RemoteSignerToken string `mapstructure:"remote_signer_token" json:"-"`                     // Bearer token for remote signer (not saved to config, env: SHADOWY_REMOTE_SIGNER_TOKEN)
+
+	// Backup bucket credentials (not saved to config)
+	BackupS3AccessKey string `mapstructure:"-" json:"-"` // env: SHADOWY_BACKUP_S3_ACCESS_KEY
+	BackupS3SecretKey string `mapstructure:"-" json:"-"` // env: SHADOWY_BACKUP_S3_SECRET_KEY
 }
 
 // SeedNode represents a parsed seed node
@@ -75,6 +144,50 @@ func ParseCLI() (*CLIConfig, error) {
 	viper.SetDefault("mempool_max_size_mb", 300)
 	viper.SetDefault("api_key", "")                // No API key by default
 	viper.SetDefault("proof_pruning_depth", 10000) // Keep last 10k blocks of proofs by default
+	viper.SetDefault("utxo_prune_depth", 0)        // Keep all spent UTXOs by default
+	viper.SetDefault("tx_prune_depth", 0)          // Keep all transaction bodies by default
+	viper.SetDefault("db_compaction_hours", 24)    // Compact databases daily
+	viper.SetDefault("utxo_cache_size", DefaultUTXOCacheSize)
+	viper.SetDefault("utxo_cache_mb", 0) // Bound the UTXO cache by entry count only
+	viper.SetDefault("min_relay_fee", 0) // No relay fee floor by default
+	viper.SetDefault("min_outbound_peers", DefaultMinOutboundPeers)
+	viper.SetDefault("target_outbound_peers", DefaultTargetOutboundPeers)
+	viper.SetDefault("peer_exchange", true)
+	viper.SetDefault("max_peers_per_subnet", DefaultMaxPeersPerSubnet)
+	viper.SetDefault("genesis_file", "")
+	viper.SetDefault("reward_address", "")
+	viper.SetDefault("pool_operator", false)
+	viper.SetDefault("pool_payout_blocks", DefaultPoolPayoutBlocks)
+	viper.SetDefault("pool_fee_percent", DefaultPoolFeePercent)
+	viper.SetDefault("pool_url", "")
+	viper.SetDefault("accept_token_fees", false)
+	viper.SetDefault("api_signers", []string{})
+	viper.SetDefault("address_format", "hex")
+	viper.SetDefault("accept_hex_addresses", true)
+	viper.SetDefault("datadir", "")
+	viper.SetDefault("pid_file", "")
+	viper.SetDefault("ready_max_lag", DefaultReadyMaxLag)
+	viper.SetDefault("archive", false)
+	viper.SetDefault("readonly", false)
+	viper.SetDefault("rebroadcast_blocks", DefaultRebroadcastBlocks)
+	viper.SetDefault("utxo_in_memory", false)
+	viper.SetDefault("address_gap_limit", DefaultAddressGapLimit)
+	viper.SetDefault("consensus_engine", ConsensusEngineGossip)
+	viper.SetDefault("memo_index", false)
+	viper.SetDefault("api_listen_addr", "")          // All interfaces on api_port by default
+	viper.SetDefault("p2p_listen_addrs", []string{}) // IPv4 and IPv6 on p2p_port by default
+	viper.SetDefault("localhost_only", false)
+	viper.SetDefault("backup_interval_hours", 0) // Backups only on request by default
+	viper.SetDefault("backup_dir", "")
+	viper.SetDefault("backup_keep", DefaultBackupKeep)
+	viper.SetDefault("backup_s3_url", "")
+	viper.SetDefault("backup_s3_region", "us-east-1")
+	viper.SetDefault("failover_role", "") // No standby pairing by default
+	viper.SetDefault("sync_max_blocks", DefaultSyncMaxBlocksPerRequest)
+	viper.SetDefault("sync_max_sessions", DefaultSyncMaxSessions)
+	viper.SetDefault("sync_peer_bandwidth_kb", DefaultSyncPeerBandwidthKB)
+	viper.SetDefault("remote_signer_url", "") // Sign locally by default
+	viper.SetDefault("remote_signer_key_id", "")
 
 	// Define command line flags
 	quietFlag := flag.Bool("quiet", false, "Suppress verbose output")
@@ -86,6 +199,50 @@ func ParseCLI() (*CLIConfig, error) {
 	apiPortFlag := flag.Int("api-port", 8080, "API/HTTP listen port (default: 8080)")
 	apiKeyFlag := flag.String("api-key", "", "API key for write endpoints (or set SHADOWY_API_KEY env var)")
 	proofPruningDepthFlag := flag.Int("proof-pruning-depth", 10000, "Keep proofs for last N blocks (0 = museum mode, keep all)")
+	utxoPruneDepthFlag := flag.Int("utxo-prune-depth", 0, "Delete spent UTXOs older than N blocks (0 = keep all, minimum 100)")
+	txPruneDepthFlag := flag.Int("tx-prune-depth", 0, "Delete bodies of spent transactions older than N blocks (0 = keep all, minimum 100)")
+	dbCompactionHoursFlag := flag.Int("db-compaction-hours", 24, "Compact block and UTXO databases every N hours (0 = disabled)")
+	utxoCacheSizeFlag := flag.Int("utxo-cache-size", DefaultUTXOCacheSize, "Maximum number of UTXOs kept in the in-memory cache")
+	utxoCacheMBFlag := flag.Int("utxo-cache-mb", 0, "Memory budget of the UTXO cache in MB (0 = bounded by entry count only)")
+	minRelayFeeFlag := flag.Uint64("min-relay-fee", 0, "Minimum fee in base units for transactions to be relayed (0 = no floor)")
+	minRelayFeeRateFlag := flag.Uint64("min-relay-fee-rate", 0, "Minimum fee in base units per 1000 weight for transactions to be relayed (0 = no floor)")
+	dustThresholdFlag := flag.Uint64("dust-threshold", 0, "Smallest SHADOW output in base units a relayed transaction may create (0 = the network's threshold)")
+	minOutboundPeersFlag := flag.Int("min-outbound-peers", DefaultMinOutboundPeers, "Re-bootstrap from anchors and seeds when outbound peers drop below this")
+	targetOutboundPeersFlag := flag.Int("target-outbound-peers", DefaultTargetOutboundPeers, "Dial peers learned by peer exchange until this many outbound peers")
+	peerExchangeFlag := flag.Bool("peer-exchange", true, "Gossip and learn signed peer addresses")
+	maxPeersPerSubnetFlag := flag.Int("max-peers-per-subnet", DefaultMaxPeersPerSubnet, "Maximum outbound peers per /16 subnet (0 = unlimited)")
+	genesisFileFlag := flag.String("genesis", "", "Chain genesis JSON file (chain ID, allocations, rewards, block interval, genesis token)")
+	rewardAddressFlag := flag.String("reward-address", "", "Wallet address to receive mining rewards, e.g. a cold wallet (default: node wallet)")
+	poolOperatorFlag := flag.Bool("pool-operator", false, "Run as a mining pool operator (accept farmer partials, pay out proportionally)")
+	poolPayoutBlocksFlag := flag.Int("pool-payout-blocks", DefaultPoolPayoutBlocks, "Pool operator: pay farmers every N blocks")
+	poolFeePercentFlag := flag.Int("pool-fee-percent", DefaultPoolFeePercent, "Pool operator: percent of rewards kept as operator fee")
+	poolURLFlag := flag.String("pool-url", "", "Pool operator API URL to farm for, e.g. http://pool.example:8080 (empty = solo farming)")
+	acceptTokenFeesFlag := flag.Bool("accept-token-fees", false, "Accept transaction fees paid in custom tokens, priced through their SHADOW liquidity pool")
+	apiSignersFlag := flag.String("api-signers", "", "Comma-delimited addresses allowed to authorize write requests by signing them")
+	addressFormatFlag := flag.String("address-format", "hex", "Address display format in API output: hex or bech32m")
+	acceptHexAddressesFlag := flag.Bool("accept-hex-addresses", true, "With bech32m display, keep accepting hex addresses in API input")
+	dataDirFlag := flag.String("datadir", "", "Root directory for all node data: blockchain, UTXO store, wallet, address book, anchors (default: working directory, wallet in ~/.sn)")
+	pidFileFlag := flag.String("pidfile", "", "Write the node's PID to this file while running, for service managers")
+	readyMaxLagFlag := flag.Int("ready-max-lag", DefaultReadyMaxLag, "Blocks the chain may trail the best peer and still report ready on /readyz")
+	archiveFlag := flag.Bool("archive", false, "Archive mode: keep all block proofs, spent UTXOs and transaction bodies (overrides pruning settings)")
+	readOnlyFlag := flag.Bool("readonly", false, "Read-only mode: no wallet or farming, write API endpoints disabled (for public explorers)")
+	rebroadcastBlocksFlag := flag.Int("rebroadcast-blocks", DefaultRebroadcastBlocks, "Blocks between rebroadcasts of unconfirmed transactions this node submitted (0 = never)")
+	utxoInMemoryFlag := flag.Bool("utxo-in-memory", false, "Keep the full unspent UTXO set in memory for faster validation and block application (needs RAM for the whole set)")
+	addressGapLimitFlag := flag.Int("address-gap-limit", DefaultAddressGapLimit, "Most unused receive addresses /api/wallet/new_address hands out before refusing (gap limit)")
+	consensusEngineFlag := flag.String("consensus-engine", ConsensusEngineGossip, "Consensus runtime to run (only \"gossip\" is supported)")
+	memoIndexFlag := flag.Bool("memo-index", false, "Index transaction memos so payments can be found with /api/tx/search")
+	apiListenAddrFlag := flag.String("api-listen-addr", "", "API bind address as host or host:port, e.g. 127.0.0.1 or [::1]:8080 (default: all interfaces on --api-port)")
+	p2pListenAddrsFlag := flag.String("p2p-listen-addrs", "", "Comma-delimited P2P listen multiaddrs, e.g. /ip4/0.0.0.0/tcp/9000,/ip6/::/tcp/9000 (default: IPv4 and IPv6 on --p2p-port)")
+	backupIntervalHoursFlag := flag.Int("backup-interval-hours", 0, "Back up the wallet and a chain checkpoint every N hours (0 = only through /api/admin/backup/now)")
+	backupDirFlag := flag.String("backup-dir", "", "Directory backups are written to (default: backups under the data directory)")
+	backupKeepFlag := flag.Int("backup-keep", DefaultBackupKeep, "Newest backups kept in the backup directory")
+	backupS3URLFlag := flag.String("backup-s3-url", "", "Also upload backups to this S3-compatible bucket, https://host/bucket[/prefix] (keys from SHADOWY_BACKUP_S3_ACCESS_KEY and SHADOWY_BACKUP_S3_SECRET_KEY)")
+	backupS3RegionFlag := flag.String("backup-s3-region", "us-east-1", "Region S3 backup uploads are signed for")
+	failoverRoleFlag := flag.String("failover-role", "", "Run as the primary or standby of a validator pair sharing this wallet; only the node holding the lease proposes")
+	syncMaxBlocksFlag := flag.Int("sync-max-blocks", DefaultSyncMaxBlocksPerRequest, "Most blocks served to a syncing peer per response; longer ranges are paginated")
+	syncMaxSessionsFlag := flag.Int("sync-max-sessions", DefaultSyncMaxSessions, "Most block and header requests from syncing peers served at once")
+	syncPeerBandwidthKBFlag := flag.Int("sync-peer-bandwidth-kb", DefaultSyncPeerBandwidthKB, "KiB per second sent to each syncing peer (0 = unlimited)")
+	localhostOnlyFlag := flag.Bool("localhost-only", false, "Bind the API and P2P to loopback (127.0.0.1 and ::1) unless listen addresses are given")
 
 	// Plot generation flags
 	plotFlag := flag.Bool("plot", false, "Generate a new plot file for farming")
@@ -93,9 +250,16 @@ func ParseCLI() (*CLIConfig, error) {
 	plotDirFlag := flag.String("plot-dir", "./plots", "Output directory for generated plot file (default: ./plots)")
 	plotVerboseFlag := flag.Bool("plot-verbose", false, "Enable verbose output during plot generation")
 
+	// Maintenance flags
+	reindexFlag := flag.Bool("reindex", false, "Wipe and rebuild UTXO, token, and pool state from the stored blocks, then start normally")
+
 	// Wallet encryption flag
 	walletPasswordFlag := flag.String("wallet-password", "", "Wallet encryption passphrase (or set SHADOWY_WALLET_PASSWORD env var)")
 
+	// Remote signer flags
+	remoteSignerURLFlag := flag.String("remote-signer-url", "", "Base URL of a remote signing service (keys never leave the signer)")
+	remoteSignerKeyIDFlag := flag.String("remote-signer-key-id", "", "Key identifier to use on the remote signer")
+
 	// Parse command line
 	flag.Parse()
 
@@ -157,6 +321,190 @@ func ParseCLI() (*CLIConfig, error) {
 		viper.Set("proof_pruning_depth", *proofPruningDepthFlag)
 	}
 
+	if *utxoPruneDepthFlag != 0 {
+		viper.Set("utxo_prune_depth", *utxoPruneDepthFlag)
+	}
+
+	if *txPruneDepthFlag != 0 {
+		viper.Set("tx_prune_depth", *txPruneDepthFlag)
+	}
+
+	if *dbCompactionHoursFlag != 24 {
+		viper.Set("db_compaction_hours", *dbCompactionHoursFlag)
+	}
+
+	if *utxoCacheSizeFlag != DefaultUTXOCacheSize {
+		viper.Set("utxo_cache_size", *utxoCacheSizeFlag)
+	}
+
+	if *utxoCacheMBFlag != 0 {
+		viper.Set("utxo_cache_mb", *utxoCacheMBFlag)
+	}
+
+	if *minRelayFeeFlag != 0 {
+		viper.Set("min_relay_fee", *minRelayFeeFlag)
+	}
+
+	if *minRelayFeeRateFlag != 0 {
+		viper.Set("min_relay_fee_rate", *minRelayFeeRateFlag)
+	}
+
+	if *dustThresholdFlag != 0 {
+		viper.Set("dust_threshold", *dustThresholdFlag)
+	}
+
+	if *minOutboundPeersFlag != DefaultMinOutboundPeers {
+		viper.Set("min_outbound_peers", *minOutboundPeersFlag)
+	}
+
+	if *targetOutboundPeersFlag != DefaultTargetOutboundPeers {
+		viper.Set("target_outbound_peers", *targetOutboundPeersFlag)
+	}
+
+	if !*peerExchangeFlag {
+		viper.Set("peer_exchange", false)
+	}
+
+	if *maxPeersPerSubnetFlag != DefaultMaxPeersPerSubnet {
+		viper.Set("max_peers_per_subnet", *maxPeersPerSubnetFlag)
+	}
+
+	if *genesisFileFlag != "" {
+		viper.Set("genesis_file", *genesisFileFlag)
+	}
+
+	if *rewardAddressFlag != "" {
+		viper.Set("reward_address", *rewardAddressFlag)
+	}
+
+	if *poolOperatorFlag {
+		viper.Set("pool_operator", *poolOperatorFlag)
+	}
+
+	if *poolPayoutBlocksFlag != DefaultPoolPayoutBlocks {
+		viper.Set("pool_payout_blocks", *poolPayoutBlocksFlag)
+	}
+
+	if *poolFeePercentFlag != DefaultPoolFeePercent {
+		viper.Set("pool_fee_percent", *poolFeePercentFlag)
+	}
+
+	if *poolURLFlag != "" {
+		viper.Set("pool_url", *poolURLFlag)
+	}
+
+	if *acceptTokenFeesFlag {
+		viper.Set("accept_token_fees", *acceptTokenFeesFlag)
+	}
+
+	if *apiSignersFlag != "" {
+		viper.Set("api_signers", strings.Split(*apiSignersFlag, ","))
+	}
+
+	if *addressFormatFlag != "hex" {
+		viper.Set("address_format", *addressFormatFlag)
+	}
+
+	if !*acceptHexAddressesFlag {
+		viper.Set("accept_hex_addresses", *acceptHexAddressesFlag)
+	}
+
+	if *dataDirFlag != "" {
+		viper.Set("datadir", *dataDirFlag)
+	}
+
+	if *pidFileFlag != "" {
+		viper.Set("pid_file", *pidFileFlag)
+	}
+
+	if *readyMaxLagFlag != DefaultReadyMaxLag {
+		viper.Set("ready_max_lag", *readyMaxLagFlag)
+	}
+
+	if *archiveFlag {
+		viper.Set("archive", *archiveFlag)
+	}
+
+	if *readOnlyFlag {
+		viper.Set("readonly", *readOnlyFlag)
+	}
+
+	if *rebroadcastBlocksFlag != DefaultRebroadcastBlocks {
+		viper.Set("rebroadcast_blocks", *rebroadcastBlocksFlag)
+	}
+
+	if *utxoInMemoryFlag {
+		viper.Set("utxo_in_memory", *utxoInMemoryFlag)
+	}
+
+	if *addressGapLimitFlag != DefaultAddressGapLimit {
+		viper.Set("address_gap_limit", *addressGapLimitFlag)
+	}
+
+	if *consensusEngineFlag != ConsensusEngineGossip {
+		viper.Set("consensus_engine", *consensusEngineFlag)
+	}
+
+	if *memoIndexFlag {
+		viper.Set("memo_index", *memoIndexFlag)
+	}
+
+	if *apiListenAddrFlag != "" {
+		viper.Set("api_listen_addr", *apiListenAddrFlag)
+	}
+
+	if *p2pListenAddrsFlag != "" {
+		viper.Set("p2p_listen_addrs", strings.Split(*p2pListenAddrsFlag, ","))
+	}
+
+	if *localhostOnlyFlag {
+		viper.Set("localhost_only", *localhostOnlyFlag)
+	}
+
+	if *backupIntervalHoursFlag != 0 {
+		viper.Set("backup_interval_hours", *backupIntervalHoursFlag)
+	}
+
+	if *backupDirFlag != "" {
+		viper.Set("backup_dir", *backupDirFlag)
+	}
+
+	if *backupKeepFlag != DefaultBackupKeep {
+		viper.Set("backup_keep", *backupKeepFlag)
+	}
+
+	if *backupS3URLFlag != "" {
+		viper.Set("backup_s3_url", *backupS3URLFlag)
+	}
+
+	if *backupS3RegionFlag != "us-east-1" {
+		viper.Set("backup_s3_region", *backupS3RegionFlag)
+	}
+
+	if *failoverRoleFlag != "" {
+		viper.Set("failover_role", *failoverRoleFlag)
+	}
+
+	if *syncMaxBlocksFlag != DefaultSyncMaxBlocksPerRequest {
+		viper.Set("sync_max_blocks", *syncMaxBlocksFlag)
+	}
+
+	if *syncMaxSessionsFlag != DefaultSyncMaxSessions {
+		viper.Set("sync_max_sessions", *syncMaxSessionsFlag)
+	}
+
+	if *syncPeerBandwidthKBFlag != DefaultSyncPeerBandwidthKB {
+		viper.Set("sync_peer_bandwidth_kb", *syncPeerBandwidthKBFlag)
+	}
+
+	if *remoteSignerURLFlag != "" {
+		viper.Set("remote_signer_url", *remoteSignerURLFlag)
+	}
+
+	if *remoteSignerKeyIDFlag != "" {
+		viper.Set("remote_signer_key_id", *remoteSignerKeyIDFlag)
+	}
+
 	// Wallet password from flag or environment variable
 	walletPassword := *walletPasswordFlag
 	if walletPassword == "" {
@@ -181,12 +529,36 @@ func ParseCLI() (*CLIConfig, error) {
 	// Set wallet password (not persisted to config file)
 	config.WalletPassword = walletPassword
 
+	// Reindex and audit are one-shot maintenance actions (not persisted to config file)
+	config.Reindex = *reindexFlag
+	config.AuditMode = flag.Arg(0) == "audit"
+	if flag.Arg(0) == "wallet" {
+		config.WalletMode = true
+		config.WalletArgs = flag.Args()[1:]
+	}
+	if flag.Arg(0) == "export" || flag.Arg(0) == "import" {
+		config.ArchiveMode = true
+		config.ArchiveArgs = flag.Args()
+	}
+	if flag.Arg(0) == "inspect" {
+		config.InspectMode = true
+		config.InspectArgs = flag.Args()[1:]
+	}
+
+	// Remote signer token only comes from the environment
+	config.RemoteSignerToken = os.Getenv("SHADOWY_REMOTE_SIGNER_TOKEN")
+
+	// So do the backup bucket credentials
+	config.BackupS3AccessKey = os.Getenv("SHADOWY_BACKUP_S3_ACCESS_KEY")
+	config.BackupS3SecretKey = os.Getenv("SHADOWY_BACKUP_S3_SECRET_KEY")
+
 	return config, nil
 }
 
-// createDefaultConfig creates a default shadow.json configuration file
-func createDefaultConfig() error {
-	defaultConfig := &CLIConfig{
+// DefaultConfig returns the configuration written to a new shadow.json, for running a
+// node without flags or a config file (see NewNode)
+func DefaultConfig() *CLIConfig {
+	return &CLIConfig{
 		Quiet:                 false,
 		Seeds:                 []string{"/dns4/catgirlcasino.com/tcp/9000/p2p/bootstrap-node-id"},
 		Dirs:                  []string{"./plots"},
@@ -198,7 +570,58 @@ func createDefaultConfig() error {
 		MempoolMaxSizeMB:      300,
 		APIKey:                "",
 		ProofPruningDepth:     10000,
+		UTXOPruneDepth:        0,
+		TxPruneDepth:          0,
+		DBCompactionHours:     24,
+		UTXOCacheSize:         DefaultUTXOCacheSize,
+		UTXOCacheMB:           0,
+		MinRelayFee:           0,
+		MinRelayFeeRate:       0,
+		DustThreshold:         0,
+		MinOutboundPeers:      DefaultMinOutboundPeers,
+		TargetOutboundPeers:   DefaultTargetOutboundPeers,
+		PeerExchange:          true,
+		MaxPeersPerSubnet:     DefaultMaxPeersPerSubnet,
+		GenesisFile:           "",
+		RewardAddress:         "",
+		PoolOperator:          false,
+		PoolPayoutBlocks:      DefaultPoolPayoutBlocks,
+		PoolFeePercent:        DefaultPoolFeePercent,
+		PoolURL:               "",
+		AcceptTokenFees:       false,
+		APISigners:            []string{},
+		AddressFormat:         "hex",
+		AcceptHexAddresses:    true,
+		DataDir:               "",
+		PIDFile:               "",
+		ReadyMaxLag:           DefaultReadyMaxLag,
+		Archive:               false,
+		ReadOnly:              false,
+		RebroadcastBlocks:     DefaultRebroadcastBlocks,
+		UTXOInMemory:          false,
+		AddressGapLimit:       DefaultAddressGapLimit,
+		ConsensusEngine:       ConsensusEngineGossip,
+		MemoIndex:             false,
+		APIListenAddr:         "",
+		P2PListenAddrs:        []string{},
+		LocalhostOnly:         false,
+		BackupIntervalHours:   0,
+		BackupDir:             "",
+		BackupKeep:            DefaultBackupKeep,
+		BackupS3URL:           "",
+		BackupS3Region:        "us-east-1",
+		FailoverRole:          "",
+		SyncMaxBlocks:         DefaultSyncMaxBlocksPerRequest,
+		SyncMaxSessions:       DefaultSyncMaxSessions,
+		SyncPeerBandwidthKB:   DefaultSyncPeerBandwidthKB,
+		RemoteSignerURL:       "",
+		RemoteSignerKeyID:     "",
 	}
+}
+
+// createDefaultConfig creates a default shadow.json configuration file
+func createDefaultConfig() error {
+	defaultConfig := DefaultConfig()
 
 	// Set all config values in viper
 	viper.Set("quiet", defaultConfig.Quiet)
@@ -212,6 +635,52 @@ func createDefaultConfig() error {
 	viper.Set("mempool_max_size_mb", defaultConfig.MempoolMaxSizeMB)
 	viper.Set("api_key", defaultConfig.APIKey)
 	viper.Set("proof_pruning_depth", defaultConfig.ProofPruningDepth)
+	viper.Set("utxo_prune_depth", defaultConfig.UTXOPruneDepth)
+	viper.Set("tx_prune_depth", defaultConfig.TxPruneDepth)
+	viper.Set("db_compaction_hours", defaultConfig.DBCompactionHours)
+	viper.Set("utxo_cache_size", defaultConfig.UTXOCacheSize)
+	viper.Set("utxo_cache_mb", defaultConfig.UTXOCacheMB)
+	viper.Set("min_relay_fee", defaultConfig.MinRelayFee)
+	viper.Set("min_relay_fee_rate", defaultConfig.MinRelayFeeRate)
+	viper.Set("dust_threshold", defaultConfig.DustThreshold)
+	viper.Set("min_outbound_peers", defaultConfig.MinOutboundPeers)
+	viper.Set("target_outbound_peers", defaultConfig.TargetOutboundPeers)
+	viper.Set("peer_exchange", defaultConfig.PeerExchange)
+	viper.Set("max_peers_per_subnet", defaultConfig.MaxPeersPerSubnet)
+	viper.Set("genesis_file", defaultConfig.GenesisFile)
+	viper.Set("reward_address", defaultConfig.RewardAddress)
+	viper.Set("pool_operator", defaultConfig.PoolOperator)
+	viper.Set("pool_payout_blocks", defaultConfig.PoolPayoutBlocks)
+	viper.Set("pool_fee_percent", defaultConfig.PoolFeePercent)
+	viper.Set("pool_url", defaultConfig.PoolURL)
+	viper.Set("accept_token_fees", defaultConfig.AcceptTokenFees)
+	viper.Set("api_signers", defaultConfig.APISigners)
+	viper.Set("address_format", defaultConfig.AddressFormat)
+	viper.Set("accept_hex_addresses", defaultConfig.AcceptHexAddresses)
+	viper.Set("datadir", defaultConfig.DataDir)
+	viper.Set("pid_file", defaultConfig.PIDFile)
+	viper.Set("ready_max_lag", defaultConfig.ReadyMaxLag)
+	viper.Set("archive", defaultConfig.Archive)
+	viper.Set("readonly", defaultConfig.ReadOnly)
+	viper.Set("rebroadcast_blocks", defaultConfig.RebroadcastBlocks)
+	viper.Set("utxo_in_memory", defaultConfig.UTXOInMemory)
+	viper.Set("address_gap_limit", defaultConfig.AddressGapLimit)
+	viper.Set("consensus_engine", defaultConfig.ConsensusEngine)
+	viper.Set("memo_index", defaultConfig.MemoIndex)
+	viper.Set("api_listen_addr", defaultConfig.APIListenAddr)
+	viper.Set("p2p_listen_addrs", defaultConfig.P2PListenAddrs)
+	viper.Set("localhost_only", defaultConfig.LocalhostOnly)
+	viper.Set("backup_interval_hours", defaultConfig.BackupIntervalHours)
+	viper.Set("backup_dir", defaultConfig.BackupDir)
+	viper.Set("backup_keep", defaultConfig.BackupKeep)
+	viper.Set("backup_s3_url", defaultConfig.BackupS3URL)
+	viper.Set("backup_s3_region", defaultConfig.BackupS3Region)
+	viper.Set("failover_role", defaultConfig.FailoverRole)
+	viper.Set("sync_max_blocks", defaultConfig.SyncMaxBlocks)
+	viper.Set("sync_max_sessions", defaultConfig.SyncMaxSessions)
+	viper.Set("sync_peer_bandwidth_kb", defaultConfig.SyncPeerBandwidthKB)
+	viper.Set("remote_signer_url", defaultConfig.RemoteSignerURL)
+	viper.Set("remote_signer_key_id", defaultConfig.RemoteSignerKeyID)
 
 	// Write config file
 	if err := viper.WriteConfigAs("shadow.json"); err != nil {
@@ -451,6 +920,12 @@ func PrintUsage() {
 	fmt.Fprintf(os.Stderr, "  %s --seeds=abc123...@192.168.1.100,def456...@node2.example.com:26657\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s --dirs=./plots,./proofs,/mnt/storage/farming\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s --quiet --seeds=abc123...@192.168.1.100 --dirs=./plots\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --reindex   (rebuild corrupted indices from stored blocks)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s audit       (check chain invariants and report discrepancies)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s wallet export-keystore backup.json   (encrypted, portable wallet export)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s export --to chain.bak --from 0 --to-height 5000 [--utxos]   (checksummed chain backup)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s inspect blocks --count 10   (read-only look at a stopped node's blocks, UTXOs, tokens, transactions)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s import chain.bak   (verify a chain backup and replay it onto this node's chain)\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\nConfiguration:\n")
 	fmt.Fprintf(os.Stderr, "  Config file: shadow.json (created automatically if missing)\n")
 	fmt.Fprintf(os.Stderr, "  Command line flags override config file values\n")
@@ -466,11 +941,14 @@ func PrintUsage() {
 
 // ValidateConfig performs additional validation on the parsed configuration
 func (config *CLIConfig) ValidateConfig() error {
-	// Validate seed nodes
-	if len(config.Seeds) > 0 {
-		_, err := config.GetSeedNodes()
-		if err != nil {
-			return fmt.Errorf("seed validation failed: %w", err)
+	// Validate seed nodes: libp2p multiaddrs with a peer ID, as dialed by the peer
+	// manager, or the legacy nodeid@ip_address[:port] format
+	for _, seed := range config.Seeds {
+		if len(seedAddrInfos([]string{seed})) == 1 {
+			continue
+		}
+		if _, err := ParseSeedNode(seed); err != nil {
+			return fmt.Errorf("seed validation failed: failed to parse seed %s: %w", seed, err)
 		}
 	}
 
@@ -481,6 +959,87 @@ func (config *CLIConfig) ValidateConfig() error {
 		}
 	}
 
+	// Validate reward address
+	if config.RewardAddress != "" {
+		if _, err := ParseRewardAddress(config.RewardAddress); err != nil {
+			return fmt.Errorf("reward_address validation failed: %w", err)
+		}
+	}
+
+	// Read-only nodes have no wallet to farm, sign or collect rewards with
+	if config.ReadOnly {
+		if config.PoolOperator || config.PoolURL != "" {
+			return fmt.Errorf("readonly nodes cannot run or join a mining pool")
+		}
+		if config.RemoteSignerURL != "" || config.RewardAddress != "" {
+			return fmt.Errorf("readonly nodes have no wallet; unset remote_signer_url and reward_address")
+		}
+	}
+
+	if config.ReadyMaxLag < 0 {
+		return fmt.Errorf("ready_max_lag must not be negative, got %d", config.ReadyMaxLag)
+	}
+	if config.RebroadcastBlocks < 0 {
+		return fmt.Errorf("rebroadcast_blocks must not be negative, got %d", config.RebroadcastBlocks)
+	}
+	if config.AddressGapLimit < 1 {
+		return fmt.Errorf("address_gap_limit must be at least 1, got %d", config.AddressGapLimit)
+	}
+	if err := ValidateConsensusEngine(config.ConsensusEngine); err != nil {
+		return err
+	}
+	if _, err := ResolveAPIListenAddr(config.APIListenAddr, config.APIPort, config.LocalhostOnly); err != nil {
+		return fmt.Errorf("api_listen_addr validation failed: %w", err)
+	}
+	if _, err := ResolveP2PListenAddrs(config.P2PListenAddrs, config.P2PPort, config.LocalhostOnly); err != nil {
+		return fmt.Errorf("p2p_listen_addrs validation failed: %w", err)
+	}
+	if config.BackupIntervalHours < 0 {
+		return fmt.Errorf("backup_interval_hours must not be negative, got %d", config.BackupIntervalHours)
+	}
+	if config.BackupKeep < 1 {
+		return fmt.Errorf("backup_keep must be at least 1, got %d", config.BackupKeep)
+	}
+	if config.BackupS3URL != "" {
+		if _, err := NewS3Target(config.BackupS3URL, config.BackupS3Region, config.BackupS3AccessKey, config.BackupS3SecretKey); err != nil {
+			return fmt.Errorf("backup_s3_url validation failed: %w", err)
+		}
+	}
+
+	if config.SyncMaxBlocks < 1 {
+		return fmt.Errorf("sync_max_blocks must be at least 1, got %d", config.SyncMaxBlocks)
+	}
+	if config.SyncMaxSessions < 1 {
+		return fmt.Errorf("sync_max_sessions must be at least 1, got %d", config.SyncMaxSessions)
+	}
+	if config.SyncPeerBandwidthKB < 0 {
+		return fmt.Errorf("sync_peer_bandwidth_kb must not be negative, got %d", config.SyncPeerBandwidthKB)
+	}
+
+	// A standby pair shares one wallet, which a read-only node doesn't have
+	if err := ValidateFailoverRole(config.FailoverRole); err != nil {
+		return err
+	}
+	if config.FailoverRole != "" && config.ReadOnly {
+		return fmt.Errorf("readonly nodes have no wallet to pair with; unset failover_role")
+	}
+
+	// Validate mining pool settings
+	if config.PoolOperator {
+		if config.PoolURL != "" {
+			return fmt.Errorf("pool_operator and pool_url are mutually exclusive")
+		}
+		if config.RewardAddress != "" {
+			return fmt.Errorf("pool operators are paid to the node wallet, which funds payouts; unset reward_address")
+		}
+		if config.PoolPayoutBlocks <= 0 {
+			return fmt.Errorf("pool_payout_blocks must be positive, got %d", config.PoolPayoutBlocks)
+		}
+		if config.PoolFeePercent < 0 || config.PoolFeePercent > 100 {
+			return fmt.Errorf("pool_fee_percent must be 0-100, got %d", config.PoolFeePercent)
+		}
+	}
+
 	return nil
 }
 
@@ -508,6 +1067,18 @@ func (config *CLIConfig) String() string {
 		parts = append(parts, fmt.Sprintf("blockchain_dir=%s", config.BlockchainDir))
 	}
 
+	if config.DataDir != "" {
+		parts = append(parts, fmt.Sprintf("datadir=%s", config.DataDir))
+	}
+
+	if config.Archive {
+		parts = append(parts, "archive=true")
+	}
+
+	if config.ReadOnly {
+		parts = append(parts, "readonly=true")
+	}
+
 	if len(parts) == 0 {
 		return "default configuration"
 	}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// DBStats is the node's database sizes and cache hit rates
+type DBStats struct {
+	Stores     map[string]*lib.StoreHealth `json:"stores"`
+	UTXOCache  lib.UTXOCacheStats          `json:"utxo_cache"`
+	UTXOMemory lib.UTXOMemoryStats         `json:"utxo_memory"`
+	SigCache   lib.SigCacheStats           `json:"sig_cache"`
+}
+
+// DBStats returns database file sizes, key counts, cache hit rates and last compaction
+func (c *Client) DBStats(ctx context.Context) (*DBStats, error) {
+	var stats DBStats
+	if err := c.do(ctx, http.MethodGet, "/api/admin/db/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Audit runs the chain consistency audit
+func (c *Client) Audit(ctx context.Context) (*lib.AuditReport, error) {
+	var report lib.AuditReport
+	if err := c.do(ctx, http.MethodGet, "/api/admin/audit", nil, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// EvidenceEntry returns one piece of rejection evidence with its payload
+func (c *Client) EvidenceEntry(ctx context.Context, id string) (*lib.Evidence, error) {
+	var evidence lib.Evidence
+	if err := c.do(ctx, http.MethodGet, "/api/admin/evidence", url.Values{"id": {id}}, nil, &evidence); err != nil {
+		return nil, err
+	}
+	return &evidence, nil
+}
+
+// Evidence returns the rejection evidence matching filter, newest first, with payloads
+// if withPayload
+func (c *Client) Evidence(ctx context.Context, filter lib.EvidenceFilter, withPayload bool) ([]*lib.Evidence, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"kind": filter.Kind, "source": filter.Source, "peer": filter.Peer, "hash": filter.Hash} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if filter.Since > 0 {
+		query.Set("since", strconv.FormatInt(filter.Since, 10))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if withPayload {
+		query.Set("payload", "true")
+	}
+	var result struct {
+		Evidence []*lib.Evidence `json:"evidence"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/evidence", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Evidence, nil
+}
+
+// FlushMempool drops pending transactions, except those submitted through the node if
+// keepLocal, and returns how many were dropped and remain
+func (c *Client) FlushMempool(ctx context.Context, keepLocal bool) (flushed, remaining int, err error) {
+	req := struct {
+		KeepLocal bool `json:"keep_local"`
+	}{keepLocal}
+	var result struct {
+		Flushed   int `json:"flushed"`
+		Remaining int `json:"remaining"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/mempool/flush", nil, req, &result); err != nil {
+		return 0, 0, err
+	}
+	return result.Flushed, result.Remaining, nil
+}
+
+// RebroadcastResult is a mempool announcement to every peer
+type RebroadcastResult struct {
+	Announced      int `json:"announced"`
+	LocalPending   int `json:"local_pending"`
+	ConnectedPeers int `json:"connected_peers"`
+}
+
+// Rebroadcast announces the whole mempool to every peer again
+func (c *Client) Rebroadcast(ctx context.Context) (*RebroadcastResult, error) {
+	var result RebroadcastResult
+	if err := c.do(ctx, http.MethodPost, "/api/admin/rebroadcast", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PeerChange is a peer connected or disconnected by hand
+type PeerChange struct {
+	Status         string `json:"status"`
+	PeerID         string `json:"peer_id,omitempty"`
+	ConnectedPeers int    `json:"connected_peers"`
+}
+
+// ConnectPeer dials a multiaddr that includes the peer ID
+func (c *Client) ConnectPeer(ctx context.Context, multiaddr string) (*PeerChange, error) {
+	req := struct {
+		Multiaddr string `json:"multiaddr"`
+	}{multiaddr}
+	var result PeerChange
+	if err := c.do(ctx, http.MethodPost, "/api/admin/peers/connect", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DisconnectPeer closes the connection to a peer, given by ID or multiaddr
+func (c *Client) DisconnectPeer(ctx context.Context, peer string) (*PeerChange, error) {
+	req := struct {
+		Peer string `json:"peer"`
+	}{peer}
+	var result PeerChange
+	if err := c.do(ctx, http.MethodPost, "/api/admin/peers/disconnect", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// APIKeyRotation is a new API key and how long the old one keeps working
+type APIKeyRotation struct {
+	APIKey       string `json:"api_key"`
+	GraceSeconds int    `json:"grace_seconds"`
+	Persisted    bool   `json:"persisted"` // False: put it in the config file to keep it across restarts
+}
+
+// RotateAPIKey replaces the node's API key with apiKey ("" to generate one), accepting
+// the old one for graceSeconds. Callers switch to a Client configured with the new key.
+func (c *Client) RotateAPIKey(ctx context.Context, apiKey string, graceSeconds int) (*APIKeyRotation, error) {
+	req := struct {
+		APIKey       string `json:"api_key,omitempty"`
+		GraceSeconds int    `json:"grace_seconds"`
+	}{apiKey, graceSeconds}
+	var result APIKeyRotation
+	if err := c.do(ctx, http.MethodPost, "/api/admin/api_key/rotate", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Snapshot is a chain archive written on the node
+type Snapshot struct {
+	Path      string `json:"path"`
+	From      uint64 `json:"from"`
+	To        uint64 `json:"to"`
+	Blocks    uint64 `json:"blocks"`
+	UTXOs     uint64 `json:"utxos"`
+	StateHash string `json:"state_hash"`
+	SHA256    string `json:"sha256"`
+}
+
+// Snapshot writes a chain archive with the UTXO set on the node
+func (c *Client) Snapshot(ctx context.Context) (*Snapshot, error) {
+	var result Snapshot
+	if err := c.do(ctx, http.MethodPost, "/api/admin/snapshot", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BackupNow backs up the wallet and a chain checkpoint right away. A backup taken
+// locally whose upload failed is returned with the upload's APIError.
+func (c *Client) BackupNow(ctx context.Context) (*lib.BackupResult, error) {
+	status, body, header, err := c.send(ctx, http.MethodPost, "/api/admin/backup/now", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK && status != http.StatusBadGateway {
+		return nil, newAPIError(status, body, header)
+	}
+	var result struct {
+		Backup *lib.BackupResult `json:"backup"`
+		Error  string            `json:"error"`
+	}
+	if err := decode(body, &result); err != nil {
+		return nil, err
+	}
+	if status == http.StatusBadGateway {
+		return result.Backup, newAPIError(status, body, header)
+	}
+	return result.Backup, nil
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// GenesisToken is the chain's native token
+type GenesisToken struct {
+	TokenID  string `json:"token_id"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// NodeStatus identifies the node and its view of the chain
+type NodeStatus struct {
+	NodeID     string `json:"node_id"`
+	ChainID    string `json:"chain_id"`
+	ReadOnly   bool   `json:"read_only"`
+	WalletInfo struct {
+		Address string `json:"address"`
+	} `json:"wallet_info"`
+	GenesisToken   GenesisToken `json:"genesis_token"`
+	ChainHeight    uint64       `json:"chain_height"`
+	Peers          []string     `json:"peers"`
+	PeerCount      int          `json:"peer_count"`
+	HTTPServerAddr string       `json:"http_server_addr"`
+	IsLeader       bool         `json:"is_leader"`
+}
+
+// Status returns the node's identity, wallet and chain height
+func (c *Client) Status(ctx context.Context) (*NodeStatus, error) {
+	var status NodeStatus
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// PeerList is the node's connected and known peers
+type PeerList struct {
+	Count           int            `json:"count"`
+	Peers           []string       `json:"peers"`
+	Outbound        int            `json:"outbound"`
+	OutboundSubnets map[string]int `json:"outbound_subnets"`
+	KnownPeers      int            `json:"known_peers"`
+	Details         []lib.PeerInfo `json:"details"` // Version, chain, height and services from each peer's handshake
+}
+
+// Peers returns the node's peers
+func (c *Client) Peers(ctx context.Context) (*PeerList, error) {
+	var peers PeerList
+	if err := c.do(ctx, http.MethodGet, "/api/peers", nil, nil, &peers); err != nil {
+		return nil, err
+	}
+	return &peers, nil
+}
+
+// Chain returns every block. Prefer Blocks for anything but a small test chain.
+func (c *Client) Chain(ctx context.Context) ([]*lib.Block, error) {
+	var result struct {
+		Blocks []*lib.Block `json:"blocks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/chain", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Blocks, nil
+}
+
+// Height returns the chain height
+func (c *Client) Height(ctx context.Context) (uint64, error) {
+	var result struct {
+		Height uint64 `json:"height"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/chain/height", nil, nil, &result); err != nil {
+		return 0, err
+	}
+	return result.Height, nil
+}
+
+// ChainParams returns the consensus parameters the node validates against
+func (c *Client) ChainParams(ctx context.Context) (*lib.ChainParams, error) {
+	var params lib.ChainParams
+	if err := c.do(ctx, http.MethodGet, "/api/chain/params", nil, nil, &params); err != nil {
+		return nil, err
+	}
+	return &params, nil
+}
+
+// StateHash is the UTXO set state hash after a block
+type StateHash struct {
+	Height    uint64 `json:"height"`
+	BlockHash string `json:"block_hash"`
+	StateHash string `json:"state_hash"`
+}
+
+// StateHash returns the UTXO set state hash at the tip
+func (c *Client) StateHash(ctx context.Context) (*StateHash, error) {
+	return c.stateHash(ctx, nil)
+}
+
+// StateHashAt returns the UTXO set state hash after the block at height
+func (c *Client) StateHashAt(ctx context.Context, height uint64) (*StateHash, error) {
+	return c.stateHash(ctx, url.Values{"height": {strconv.FormatUint(height, 10)}})
+}
+
+func (c *Client) stateHash(ctx context.Context, query url.Values) (*StateHash, error) {
+	var result StateHash
+	if err := c.do(ctx, http.MethodGet, "/api/chain/state_hash", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Block returns the block at height
+func (c *Client) Block(ctx context.Context, height uint64) (*lib.Block, error) {
+	var block lib.Block
+	if err := c.do(ctx, http.MethodGet, "/api/chain/block/"+strconv.FormatUint(height, 10), nil, nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// BlockByHash returns the block with hash
+func (c *Client) BlockByHash(ctx context.Context, hash string) (*lib.Block, error) {
+	var block lib.Block
+	if err := c.do(ctx, http.MethodGet, "/api/chain/block/hash/"+pathEscape(hash), nil, nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// BlockSummary is a block without its transactions
+type BlockSummary struct {
+	Index         uint64 `json:"index"`
+	Hash          string `json:"hash"`
+	PrevHash      string `json:"prev_hash"`
+	Timestamp     int64  `json:"timestamp"`
+	TxCount       int    `json:"tx_count"`
+	HasProof      bool   `json:"has_proof"`
+	Reward        uint64 `json:"reward,omitempty"`
+	ProofDistance uint64 `json:"proof_distance,omitempty"`
+}
+
+// BlockPage is a page of block summaries, newest first
+type BlockPage struct {
+	Height uint64         `json:"height"`
+	Blocks []BlockSummary `json:"blocks"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+	Count  int            `json:"count"`
+}
+
+// Blocks returns up to limit block summaries (the node caps it at 100), skipping the
+// offset newest
+func (c *Client) Blocks(ctx context.Context, limit, offset int) (*BlockPage, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	var page BlockPage
+	if err := c.do(ctx, http.MethodGet, "/api/blocks", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ConsensusStatus is the node's part in consensus
+type ConsensusStatus struct {
+	IsLeader      bool                `json:"is_leader"`
+	NodeID        string              `json:"node_id"`
+	Height        uint64              `json:"height"`
+	RewardAddress string              `json:"reward_address"`
+	ForkBlocks    []*lib.ForkBlock    `json:"fork_blocks"`
+	Engine        string              `json:"engine"`
+	Failover      *lib.FailoverStatus `json:"failover"`
+}
+
+// ConsensusStatus returns the node's consensus role and reward address
+func (c *Client) ConsensusStatus(ctx context.Context) (*ConsensusStatus, error) {
+	var status ConsensusStatus
+	if err := c.do(ctx, http.MethodGet, "/api/consensus/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// RewardAddress is where the node's block rewards go
+type RewardAddress struct {
+	RewardAddress string `json:"reward_address"`
+	IsNodeWallet  bool   `json:"is_node_wallet"`
+}
+
+// RewardAddress returns where the node's block rewards go
+func (c *Client) RewardAddress(ctx context.Context) (*RewardAddress, error) {
+	var result RewardAddress
+	if err := c.do(ctx, http.MethodGet, "/api/consensus/reward_address", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetRewardAddress sends the node's block rewards to address
+func (c *Client) SetRewardAddress(ctx context.Context, address string) (*RewardAddress, error) {
+	req := struct {
+		RewardAddress string `json:"reward_address"`
+	}{address}
+	var result RewardAddress
+	if err := c.do(ctx, http.MethodPost, "/api/consensus/reward_address", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SubmitPoolPartial submits a partial proof to a pool operator node and returns the
+// points it earned
+func (c *Client) SubmitPoolPartial(ctx context.Context, partial *lib.PoolPartial) (float64, error) {
+	var result struct {
+		Accepted bool    `json:"accepted"`
+		Points   float64 `json:"points"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/mining_pool/partial", nil, partial, &result); err != nil {
+		return 0, err
+	}
+	return result.Points, nil
+}
+
+// MiningPoolStats returns the pool's farmers and payouts, or this farmer's submissions.
+// The "mode" key is "operator", "farmer" or "solo"; the rest depends on it.
+func (c *Client) MiningPoolStats(ctx context.Context) (map[string]json.RawMessage, error) {
+	var stats map[string]json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/mining_pool/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// SyncStatus returns block sync progress, rate and ETA
+func (c *Client) SyncStatus(ctx context.Context) (*lib.SyncStatus, error) {
+	var status lib.SyncStatus
+	if err := c.do(ctx, http.MethodGet, "/api/sync/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SyncServing returns the limits on serving syncing peers and what has been served
+func (c *Client) SyncServing(ctx context.Context) (*lib.SyncServeStats, error) {
+	var stats lib.SyncServeStats
+	if err := c.do(ctx, http.MethodGet, "/api/sync/serving", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// SupplyStats returns circulating, burned, melted and locked SHADOW totals
+func (c *Client) SupplyStats(ctx context.Context) (*lib.SupplyStats, error) {
+	var stats lib.SupplyStats
+	if err := c.do(ctx, http.MethodGet, "/api/stats/supply", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// BlockStats returns the size of the count most recent blocks (0 for the node's
+// default) against the block size limits
+func (c *Client) BlockStats(ctx context.Context, count int) (*lib.BlockSizeStats, error) {
+	query := url.Values{}
+	if count > 0 {
+		query.Set("count", strconv.Itoa(count))
+	}
+	var stats lib.BlockSizeStats
+	if err := c.do(ctx, http.MethodGet, "/api/stats/blocks", query, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// RichListHolder is one holder on the rich list
+type RichListHolder struct {
+	Rank    int     `json:"rank"`
+	Address string  `json:"address"`
+	Label   string  `json:"label,omitempty"`
+	Balance uint64  `json:"balance"`
+	Percent float64 `json:"percent"` // Of the circulating supply
+}
+
+// RichList is the largest holders of a token
+type RichList struct {
+	TokenID     string           `json:"token_id"`
+	Circulating uint64           `json:"circulating"`
+	Holders     []RichListHolder `json:"holders"`
+	Count       int              `json:"count"`
+}
+
+// RichList returns the largest holders of tokenID ("" for SHADOW); limit 0 uses the
+// node's default
+func (c *Client) RichList(ctx context.Context, tokenID string, limit int) (*RichList, error) {
+	query := url.Values{}
+	if tokenID != "" {
+		query.Set("token", tokenID)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var result RichList
+	if err := c.do(ctx, http.MethodGet, "/api/stats/richlist", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Health reports whether the node's API is up
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/healthz", nil, nil, nil)
+}
+
+// Ready returns whether the node is ready for traffic and why not. A node that is not
+// ready answers 503 with its readiness, which is returned without an error or a retry.
+func (c *Client) Ready(ctx context.Context) (*lib.Readiness, error) {
+	status, body, header, err := c.attempt(ctx, http.MethodGet, "/readyz", nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK && status != http.StatusServiceUnavailable {
+		return nil, newAPIError(status, body, header)
+	}
+	var readiness lib.Readiness
+	if err := decode(body, &readiness); err != nil {
+		return nil, err
+	}
+	return &readiness, nil
+}
@@ -0,0 +1,302 @@
+// Package client is a typed Go client for a shadowy node's HTTP API. Requests and
+// responses use the node's own types from shadowy/lib (transactions, blocks, chain
+// parameters, stats) and typed envelopes for the rest, so integrators don't hand-roll
+// JSON against the node.
+//
+//	c, err := client.New(client.Config{URL: "http://localhost:8080", APIKey: key})
+//	status, err := c.Status(ctx)
+//	result, err := c.SubmitTransaction(ctx, tx)
+//
+// Requests the node turns away for load (429, 503) are retried after its Retry-After,
+// or with exponential backoff, as are reads failing in transit. Protected writes carry
+// an Idempotency-Key that stays the same across retries, so a retried send never builds
+// a second transaction. Node events stream over a WebSocket with Subscribe.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"shadowy/lib"
+)
+
+const (
+	DefaultTimeout      = 30 * time.Second       // Per attempt, when no HTTPClient is given
+	DefaultMaxRetries   = 3                      // Retries after the first attempt
+	DefaultRetryBackoff = 250 * time.Millisecond // Delay before the first retry, doubled after each
+	MaxRetryDelay       = 10 * time.Second       // Longest wait between attempts, Retry-After included
+	maxErrorBody        = 4096                   // Bytes of an error response kept in APIError
+)
+
+// Config configures a Client
+type Config struct {
+	URL          string        // Base URL of the node's API, e.g. http://localhost:8080
+	APIKey       string        // Sent as X-API-Key, optional
+	Signer       lib.Signer    // Signs every request with the signer's key (see lib.SignRequest), optional
+	HTTPClient   *http.Client  // Default: a client with DefaultTimeout
+	MaxRetries   int           // Default DefaultMaxRetries; negative disables retries
+	RetryBackoff time.Duration // Default DefaultRetryBackoff
+}
+
+// Client calls one node's HTTP API. It is safe for concurrent use.
+type Client struct {
+	base   *url.URL
+	config Config
+	http   *http.Client
+}
+
+// New creates a client for the node at config.URL
+func New(config Config) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(config.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid node URL %q: scheme must be http or https", config.URL)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	} else if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+	return &Client{base: base, config: config, http: config.HTTPClient}, nil
+}
+
+// APIError is a response the node answered with an error status
+type APIError struct {
+	StatusCode int
+	Message    string        // The node's error text
+	Body       []byte        // The raw response body, up to 4 KiB
+	RetryAfter time.Duration // From the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("node returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("node returned %d: %s", e.StatusCode, e.Message)
+}
+
+// StatusCode returns the HTTP status of an APIError in err's chain, or 0
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound reports whether err is the node answering 404
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// Get calls a GET endpoint and decodes its JSON response into out (if not nil). The
+// typed methods cover every endpoint; this reaches ones added after this client.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, out)
+}
+
+// Post calls a POST endpoint with in as its JSON body and decodes the response into out
+func (c *Client) Post(ctx context.Context, path string, in, out interface{}) error {
+	return c.do(ctx, http.MethodPost, path, nil, in, out)
+}
+
+// do sends a request and decodes a 2xx response into out; other statuses are an APIError
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	status, body, header, err := c.send(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return newAPIError(status, body, header)
+	}
+	return decode(body, out)
+}
+
+// send sends a request, retrying per the client's policy, and returns the final
+// response's status, body and headers whatever the status
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in interface{}) (int, []byte, http.Header, error) {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return 0, nil, nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	// One key for every attempt, so the node runs the write once
+	var idempotencyKey string
+	if method != http.MethodGet {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	delay := c.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		status, body, header, err := c.attempt(ctx, method, path, query, payload, idempotencyKey)
+		retry := attempt < c.config.MaxRetries && ctx.Err() == nil
+		if err != nil {
+			// A write that failed in transit may have run; only its key makes a retry safe
+			if !retry {
+				return 0, nil, nil, err
+			}
+		} else if !retry || !retryable(method, status, header) {
+			return status, body, header, nil
+		}
+
+		wait := delay
+		if after := retryAfter(header); after > 0 {
+			wait = after
+		}
+		if wait > MaxRetryDelay {
+			wait = MaxRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				return status, body, header, nil
+			}
+			return 0, nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// attempt sends one request
+func (c *Client) attempt(ctx context.Context, method, path string, query url.Values, payload []byte, idempotencyKey string) (int, []byte, http.Header, error) {
+	var bodyReader io.Reader
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, query), bodyReader)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(lib.IdempotencyHeader, idempotencyKey)
+	}
+	if c.config.APIKey != "" {
+		req.Header.Set("X-API-Key", c.config.APIKey)
+	}
+	if c.config.Signer != nil {
+		// Signed afresh each attempt: the node rejects a reused nonce
+		if err := lib.SignRequest(req, payload, c.config.Signer); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+	}
+	return resp.StatusCode, body, resp.Header, nil
+}
+
+// url joins path and query onto the base URL
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.base
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// retryable reports whether a response asks to be retried: the node was busy, an
+// earlier attempt of the same write is still running, or a proxy in front of the node
+// failed a read
+func retryable(method string, status int, header http.Header) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusConflict:
+		return method != http.MethodGet && header.Get("Retry-After") != "" // Not a conflicted transaction
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method == http.MethodGet // The node answers 502 itself for a partly failed backup
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header in seconds
+func retryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newAPIError describes an error response
+func newAPIError(status int, body []byte, header http.Header) *APIError {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	message := strings.TrimSpace(string(body))
+	var structured struct {
+		Error   interface{} `json:"error"`
+		Message string      `json:"message"`
+	}
+	if json.Unmarshal(body, &structured) == nil {
+		if structured.Message != "" {
+			message = structured.Message
+		} else if s, ok := structured.Error.(string); ok && s != "" {
+			message = s
+		}
+	}
+	return &APIError{StatusCode: status, Message: message, Body: body, RetryAfter: retryAfter(header)}
+}
+
+// decode unmarshals a JSON response body into out, if out is set
+func decode(body []byte, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// newIdempotencyKey returns a random key for one logical write
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(key)
+}
+
+// pathEscape escapes one path segment
+func pathEscape(segment string) string {
+	return url.PathEscape(segment)
+}
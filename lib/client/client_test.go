@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"shadowy/lib"
+)
+
+// newTestClient returns a client for handler with fast retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(Config{URL: server.URL, APIKey: "test-key", RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestNewRejectsBadURL(t *testing.T) {
+	for _, raw := range []string{"", "localhost:8080", "ftp://node", "://"} {
+		if _, err := New(Config{URL: raw}); err == nil {
+			t.Errorf("Expected %q rejected", raw)
+		}
+	}
+}
+
+func TestRetryKeepsIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(lib.IdempotencyHeader))
+		attempt := len(keys)
+		mu.Unlock()
+		if r.Header.Get("X-API-Key") != "test-key" {
+			t.Errorf("Expected the API key sent, got %q", r.Header.Get("X-API-Key"))
+		}
+		if attempt < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "tx_id": "abc"})
+	})
+
+	result, err := c.Send(context.Background(), SendRequest{ToAddress: "S-test", Amount: 1})
+	if err != nil {
+		t.Fatalf("Expected the send to succeed after retries, got %v", err)
+	}
+	if result.TxID != "abc" || len(keys) != 3 {
+		t.Fatalf("Expected tx abc after 3 attempts, got %q after %d", result.TxID, len(keys))
+	}
+	if keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Expected one idempotency key across attempts, got %v", keys)
+	}
+}
+
+func TestRetryWriteInProgress(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case r.URL.Path == "/api/tx/submit":
+			// A conflicted transaction is an answer, not a reason to retry
+			w.WriteHeader(http.StatusConflict)
+		case attempts == 1:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "tx_id": "abc"})
+		}
+	})
+
+	if _, err := c.Send(context.Background(), SendRequest{ToAddress: "S-test", Amount: 1}); err != nil || attempts != 2 {
+		t.Fatalf("Expected the send retried once, got %v after %d", err, attempts)
+	}
+	attempts = 0
+	if _, err := c.SubmitTransaction(context.Background(), &lib.Transaction{}); StatusCode(err) != http.StatusConflict || attempts != 1 {
+		t.Errorf("Expected one 409 attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": lib.NotSignableCode, "message": "not signable"})
+	})
+
+	_, err := c.BuildTransaction(context.Background(), BuildRequest{FromAddress: "S-test"})
+	if StatusCode(err) != http.StatusForbidden || attempts != 1 {
+		t.Fatalf("Expected one 403 attempt, got %v after %d", err, attempts)
+	}
+	if apiErr := err.(*APIError); apiErr.Message != "not signable" {
+		t.Errorf("Expected the node's message, got %q", apiErr.Message)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	})
+	_, err := c.GetTransaction(context.Background(), "missing")
+	if !IsNotFound(err) {
+		t.Fatalf("Expected a 404, got %v", err)
+	}
+	if err.Error() != "node returned 404: Transaction not found" {
+		t.Errorf("Unexpected error text %q", err.Error())
+	}
+}
+
+func TestGetTransactionStatuses(t *testing.T) {
+	status, body := 0, map[string]interface{}{}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	})
+	ctx := context.Background()
+
+	status, body = http.StatusConflict, map[string]interface{}{"tx_id": "a", "status": "conflicted", "conflict": map[string]interface{}{"tx_id": "a", "conflicting_tx_id": "b"}}
+	tx, err := c.GetTransaction(ctx, "a")
+	if err != nil || tx.Status != "conflicted" || tx.Conflict == nil {
+		t.Fatalf("Expected a conflicted status, got %+v (%v)", tx, err)
+	}
+
+	status, body = http.StatusAccepted, map[string]interface{}{"tx_id": "a", "status": lib.AdmissionVerifying}
+	if tx, err = c.GetTransaction(ctx, "a"); err != nil || tx.Status != lib.AdmissionVerifying {
+		t.Fatalf("Expected a verifying status, got %+v (%v)", tx, err)
+	}
+
+	status, body = http.StatusOK, map[string]interface{}{"tx_type": lib.TxTypeSend, "status": lib.TxStatusPending, "confirmations": 0}
+	if tx, err = c.GetTransaction(ctx, "a"); err != nil || tx.Transaction == nil || tx.Transaction.TxType != lib.TxTypeSend {
+		t.Fatalf("Expected the pending transaction, got %+v (%v)", tx, err)
+	}
+
+	status, body = http.StatusOK, map[string]interface{}{"tx_hash": "a", "status": lib.TxStatusConfirmed, "confirmed": true, "block_height": 7}
+	if tx, err = c.GetTransaction(ctx, "a"); err != nil || tx.Details == nil || tx.Details.BlockHeight != 7 {
+		t.Fatalf("Expected confirmed details, got %+v (%v)", tx, err)
+	}
+}
+
+func TestReadyNotRetried(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(lib.Readiness{Ready: false})
+	})
+	readiness, err := c.Ready(context.Background())
+	if err != nil || readiness.Ready || attempts != 1 {
+		t.Fatalf("Expected one not-ready answer, got %+v (%v) after %d", readiness, err, attempts)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ws" || r.URL.Query().Get("types") != lib.EventTxOrphaned {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(lib.NodeEvent{Type: lib.EventTxOrphaned, Time: 1, Data: lib.TxOrphaned{TxID: "a", Height: 5}})
+		conn.ReadMessage() // Until the client goes away
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := c.Subscribe(ctx, lib.EventTxOrphaned)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	select {
+	case event := <-sub.Events():
+		payload, err := event.Payload()
+		if err != nil {
+			t.Fatalf("Failed to decode payload: %v", err)
+		}
+		if orphaned, ok := payload.(*lib.TxOrphaned); !ok || orphaned.TxID != "a" || orphaned.Height != 5 {
+			t.Errorf("Unexpected payload %#v", payload)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for an event")
+	}
+
+	sub.Close()
+	for range sub.Events() {
+	}
+	if sub.Err() != nil {
+		t.Errorf("Expected no error after Close, got %v", sub.Err())
+	}
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// OfferRequest offers the node wallet's tokens in exchange for another token
+type OfferRequest struct {
+	HaveTokenID    string `json:"have_token_id"`
+	WantTokenID    string `json:"want_token_id"`
+	HaveAmount     uint64 `json:"have_amount"`
+	WantAmount     uint64 `json:"want_amount"`
+	ExpiresAtBlock uint64 `json:"expires_at_block"`
+	AutoMatch      bool   `json:"auto_match,omitempty"` // Block proposers may settle it against crossing offers
+}
+
+// OfferResult is a submitted swap offer
+type OfferResult struct {
+	TxID      string `json:"tx_id"`
+	Status    string `json:"status"`
+	ExpiresAt uint64 `json:"expires_at"`
+	AutoMatch bool   `json:"auto_match"`
+}
+
+// CreateOffer submits a swap offer
+func (c *Client) CreateOffer(ctx context.Context, req OfferRequest) (*OfferResult, error) {
+	var result OfferResult
+	if err := c.do(ctx, http.MethodPost, "/api/swap/offer", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// OfferTxResult is a submitted acceptance or cancellation of an offer
+type OfferTxResult struct {
+	TxID      string `json:"tx_id"`
+	Status    string `json:"status"`
+	OfferTxID string `json:"offer_tx_id"`
+}
+
+// AcceptOffer takes an open offer with the node wallet
+func (c *Client) AcceptOffer(ctx context.Context, offerTxID string) (*OfferTxResult, error) {
+	return c.offerTx(ctx, "/api/swap/accept", offerTxID)
+}
+
+// CancelOffer withdraws one of the node wallet's open offers
+func (c *Client) CancelOffer(ctx context.Context, offerTxID string) (*OfferTxResult, error) {
+	return c.offerTx(ctx, "/api/swap/cancel", offerTxID)
+}
+
+func (c *Client) offerTx(ctx context.Context, path, offerTxID string) (*OfferTxResult, error) {
+	req := struct {
+		OfferTxID string `json:"offer_tx_id"`
+	}{offerTxID}
+	var result OfferTxResult
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Offer is an open swap offer
+type Offer struct {
+	OfferTxID      string `json:"offer_tx_id"`
+	HaveTokenID    string `json:"have_token_id"`
+	WantTokenID    string `json:"want_token_id"`
+	HaveAmount     uint64 `json:"have_amount"`
+	WantAmount     uint64 `json:"want_amount"`
+	ExpiresAtBlock uint64 `json:"expires_at_block"`
+	OfferAddress   string `json:"offer_address"`
+	AutoMatch      bool   `json:"auto_match"`
+	BlockHeight    uint64 `json:"block_height"`
+}
+
+// OfferList is the open swap offers
+type OfferList struct {
+	Offers        []Offer `json:"offers"`
+	Count         int     `json:"count"`
+	CurrentHeight uint64  `json:"current_height"`
+}
+
+// ListOffers returns the open swap offers
+func (c *Client) ListOffers(ctx context.Context) (*OfferList, error) {
+	var result OfferList
+	if err := c.do(ctx, http.MethodGet, "/api/swap/list", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PoolRequest creates a liquidity pool funded from the node wallet
+type PoolRequest struct {
+	TokenA     string `json:"token_a"`
+	TokenB     string `json:"token_b"`
+	AmountA    uint64 `json:"amount_a"`
+	AmountB    uint64 `json:"amount_b"`
+	FeePercent uint64 `json:"fee_percent,omitempty"` // Basis points, default 30 (0.3%)
+}
+
+// PoolResult is a submitted pool creation
+type PoolResult struct {
+	TxID        string `json:"tx_id"`
+	Status      string `json:"status"`
+	PoolID      string `json:"pool_id"`
+	CreationFee uint64 `json:"creation_fee"` // SHADOW burned
+}
+
+// CreatePool submits a liquidity pool creation
+func (c *Client) CreatePool(ctx context.Context, req PoolRequest) (*PoolResult, error) {
+	var result PoolResult
+	if err := c.do(ctx, http.MethodPost, "/api/pool/create", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Pool is a liquidity pool with its reserves and rates
+type Pool struct {
+	PoolID        string  `json:"pool_id"`
+	TokenA        string  `json:"token_a"`
+	TokenATicker  string  `json:"token_a_ticker"`
+	TokenB        string  `json:"token_b"`
+	TokenBTicker  string  `json:"token_b_ticker"`
+	ReserveA      uint64  `json:"reserve_a"`
+	ReserveB      uint64  `json:"reserve_b"`
+	LPTokenID     string  `json:"lp_token_id"`
+	LPTokenTicker string  `json:"lp_token_ticker"`
+	LPTokenSupply uint64  `json:"lp_token_supply"`
+	FeePercent    uint64  `json:"fee_percent"` // Basis points
+	K             uint64  `json:"k"`
+	RateAToB      float64 `json:"rate_a_to_b"`
+	RateBToA      float64 `json:"rate_b_to_a"`
+	CreatedAt     uint64  `json:"created_at"`
+	FeesA         uint64  `json:"fees_a"`
+	FeesB         uint64  `json:"fees_b"`
+}
+
+// ListPools returns every liquidity pool
+func (c *Client) ListPools(ctx context.Context) ([]Pool, error) {
+	var result struct {
+		Pools []Pool `json:"pools"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/pool/list", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Pools, nil
+}
+
+// PoolPositions returns the liquidity address ("" for the node wallet) provides, in
+// poolID only if set
+func (c *Client) PoolPositions(ctx context.Context, address, poolID string) ([]*lib.LPPosition, error) {
+	query := url.Values{}
+	if address != "" {
+		query.Set("address", address)
+	}
+	if poolID != "" {
+		query.Set("pool_id", poolID)
+	}
+	var result struct {
+		Positions []*lib.LPPosition `json:"positions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/pool/position", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Positions, nil
+}
+
+// PoolTWAP returns a pool's average prices over the last window blocks (0 for the
+// node's default)
+func (c *Client) PoolTWAP(ctx context.Context, poolID string, window uint64) (*lib.TWAP, error) {
+	query := url.Values{"pool_id": {poolID}}
+	if window > 0 {
+		query.Set("window", strconv.FormatUint(window, 10))
+	}
+	var twap lib.TWAP
+	if err := c.do(ctx, http.MethodGet, "/api/pool/twap", query, nil, &twap); err != nil {
+		return nil, err
+	}
+	return &twap, nil
+}
+
+// PoolTxResult is a submitted pool transaction
+type PoolTxResult struct {
+	TxID   string `json:"tx_id"`
+	Status string `json:"status"`
+}
+
+// AddLiquidity deposits node wallet tokens into a pool for at least minLPTokens
+func (c *Client) AddLiquidity(ctx context.Context, poolID string, amountA, amountB, minLPTokens uint64) (*PoolTxResult, error) {
+	req := struct {
+		PoolID      string `json:"pool_id"`
+		AmountA     uint64 `json:"amount_a"`
+		AmountB     uint64 `json:"amount_b"`
+		MinLPTokens uint64 `json:"min_lp_tokens"`
+	}{poolID, amountA, amountB, minLPTokens}
+	return c.poolTx(ctx, "/api/pool/add_liquidity", req)
+}
+
+// RemoveLiquidity redeems node wallet LP tokens for at least the minimum amounts
+func (c *Client) RemoveLiquidity(ctx context.Context, poolID string, lpTokens, minAmountA, minAmountB uint64) (*PoolTxResult, error) {
+	req := struct {
+		PoolID     string `json:"pool_id"`
+		LPTokens   uint64 `json:"lp_tokens"`
+		MinAmountA uint64 `json:"min_amount_a"`
+		MinAmountB uint64 `json:"min_amount_b"`
+	}{poolID, lpTokens, minAmountA, minAmountB}
+	return c.poolTx(ctx, "/api/pool/remove_liquidity", req)
+}
+
+// Swap trades amountIn of tokenIn through a pool for at least minAmountOut
+func (c *Client) Swap(ctx context.Context, poolID, tokenIn string, amountIn, minAmountOut uint64) (*PoolTxResult, error) {
+	req := struct {
+		PoolID       string `json:"pool_id"`
+		TokenIn      string `json:"token_in"`
+		AmountIn     uint64 `json:"amount_in"`
+		MinAmountOut uint64 `json:"min_amount_out"`
+	}{poolID, tokenIn, amountIn, minAmountOut}
+	return c.poolTx(ctx, "/api/pool/swap", req)
+}
+
+func (c *Client) poolTx(ctx context.Context, path string, req interface{}) (*PoolTxResult, error) {
+	var result PoolTxResult
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
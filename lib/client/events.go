@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"shadowy/lib"
+)
+
+// eventBuffer is how many events a Subscription holds for a slow reader before it
+// stops reading from the node
+const eventBuffer = 64
+
+// Event is a node event from /api/ws, its payload left undecoded
+type Event struct {
+	Type string          `json:"type"` // lib.Event*
+	Time int64           `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Decode unmarshals the event's payload into v
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Payload decodes the event's payload into the lib type for its Type: *lib.TxConflict,
+// *lib.MemoMissing, *lib.TxOrphaned or *lib.HTLCEvent. Types this client doesn't know
+// are returned as their raw JSON.
+func (e Event) Payload() (interface{}, error) {
+	var payload interface{}
+	switch e.Type {
+	case lib.EventTxConflicted:
+		payload = &lib.TxConflict{}
+	case lib.EventMemoMissing:
+		payload = &lib.MemoMissing{}
+	case lib.EventTxOrphaned:
+		payload = &lib.TxOrphaned{}
+	case lib.EventHTLCLocked, lib.EventHTLCClaimed, lib.EventHTLCRefunded, lib.EventHTLCExpired:
+		payload = &lib.HTLCEvent{}
+	default:
+		return e.Data, nil
+	}
+	if err := e.Decode(payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Subscription streams node events, reconnecting when the connection drops. Events
+// published while it reconnects are missed; poll the API to catch up on what matters.
+type Subscription struct {
+	client *Client
+	types  []string
+	events chan Event
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	err  error
+}
+
+// Subscribe streams node events of the given types (every type if none) until ctx ends
+// or Close is called. The first connection is made before it returns.
+func (c *Client) Subscribe(ctx context.Context, types ...string) (*Subscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &Subscription{client: c, types: types, events: make(chan Event, eventBuffer), cancel: cancel}
+	conn, err := sub.dial(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	sub.conn = conn
+	go sub.run(ctx, conn)
+	return sub, nil
+}
+
+// Events returns the event stream, closed once the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns why the node ended the subscription for good: nil while it runs, and
+// after Close or the end of its context
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() error {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// run reads events, reconnecting with backoff, until ctx ends
+func (s *Subscription) run(ctx context.Context, conn *websocket.Conn) {
+	defer close(s.events)
+	delay := s.client.config.RetryBackoff
+	for {
+		if s.read(ctx, conn) {
+			delay = s.client.config.RetryBackoff // It was up; start the backoff over
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > MaxRetryDelay {
+				delay = MaxRetryDelay
+			}
+
+			var err error
+			if conn, err = s.dial(ctx); err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			// A node refusing the subscription outright won't change its mind
+			if code := StatusCode(err); code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusNotFound {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+	}
+}
+
+// read delivers events from conn until it fails, and reports whether any arrived
+func (s *Subscription) read(ctx context.Context, conn *websocket.Conn) bool {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// The node pings every lib.EventPingInterval; two missed pings is a dead connection
+	deadline := 2*lib.EventPingInterval + lib.EventWriteTimeout
+	conn.SetReadDeadline(time.Now().Add(deadline))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(deadline))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(lib.EventWriteTimeout))
+	})
+
+	received := false
+	for {
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			return received
+		}
+		received = true
+		conn.SetReadDeadline(time.Now().Add(deadline))
+		select {
+		case s.events <- event:
+		case <-ctx.Done():
+			return received
+		}
+	}
+}
+
+// dial opens the WebSocket with the client's API key and request signature
+func (s *Subscription) dial(ctx context.Context) (*websocket.Conn, error) {
+	query := url.Values{}
+	if len(s.types) > 0 {
+		query.Set("types", strings.Join(s.types, ","))
+	}
+	wsURL, err := url.Parse(s.client.url("/api/ws", query))
+	if err != nil {
+		return nil, err
+	}
+	if wsURL.Scheme == "https" {
+		wsURL.Scheme = "wss"
+	} else {
+		wsURL.Scheme = "ws"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wsURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.client.config.APIKey != "" {
+		req.Header.Set("X-API-Key", s.client.config.APIKey)
+	}
+	if s.client.config.Signer != nil {
+		if err := lib.SignRequest(req, nil, s.client.config.Signer); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: err.Error(), RetryAfter: retryAfter(resp.Header)}
+		}
+		return nil, fmt.Errorf("subscribing to events: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// Token is a registered token as the token list describes it
+type Token struct {
+	TokenID      string `json:"token_id"`
+	Ticker       string `json:"ticker"`
+	Description  string `json:"description"`
+	MaxMint      uint64 `json:"max_mint"`
+	MaxDecimals  uint8  `json:"max_decimals"`
+	TotalSupply  uint64 `json:"total_supply"`
+	Unissued     uint64 `json:"unissued"`
+	LockedShadow uint64 `json:"locked_shadow"`
+	TotalMelted  uint64 `json:"total_melted"`
+	Creator      string `json:"creator"`
+	IsShadow     bool   `json:"is_shadow"`
+	FullyMelted  bool   `json:"fully_melted"`
+}
+
+// Tokens returns every registered token
+func (c *Client) Tokens(ctx context.Context) ([]Token, error) {
+	var result struct {
+		Tokens []Token `json:"tokens"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tokens", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Tokens, nil
+}
+
+// MintDelegate is an address allowed to mint a token for its creator
+type MintDelegate struct {
+	Delegate     string `json:"delegate"`
+	PeriodBlocks uint64 `json:"period_blocks"`
+	PeriodLimit  uint64 `json:"period_limit"`
+	GrantedAt    uint64 `json:"granted_at"`
+	Period       uint64 `json:"period"`
+	PeriodMinted uint64 `json:"period_minted"`
+	TotalMinted  uint64 `json:"total_minted"`
+}
+
+// TokenInfo is a token's supply, delegates and collateral terms
+type TokenInfo struct {
+	Token
+	TotalBurned     uint64               `json:"total_burned"`
+	Issued          uint64               `json:"issued"`
+	MintDelegates   []MintDelegate       `json:"mint_delegates"`
+	Collateral      *lib.CollateralTerms `json:"collateral,omitempty"`
+	CreationTime    int64                `json:"creation_time"`
+	SupplyFormatted string               `json:"supply_formatted"`
+}
+
+// TokenInfo returns one token
+func (c *Client) TokenInfo(ctx context.Context, tokenID string) (*TokenInfo, error) {
+	var info TokenInfo
+	if err := c.do(ctx, http.MethodGet, "/api/token/info", url.Values{"token_id": {tokenID}}, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// MintRequest creates a token, or mints more of one (TokenID and Amount set)
+type MintRequest struct {
+	Ticker        string               `json:"ticker,omitempty"`
+	Description   string               `json:"description,omitempty"`
+	MaxMint       uint64               `json:"max_mint,omitempty"`
+	MaxDecimals   uint8                `json:"max_decimals,omitempty"`
+	InitialSupply uint64               `json:"initial_supply,omitempty"` // Mint only this much now, the rest later
+	TokenID       string               `json:"token_id,omitempty"`
+	Amount        uint64               `json:"amount,omitempty"`
+	Collateral    *lib.CollateralTerms `json:"collateral,omitempty"` // Issue against collateral positions
+}
+
+// TokenTxResult is a submitted token transaction
+type TokenTxResult struct {
+	Success      bool   `json:"success"`
+	TxID         string `json:"tx_id"`
+	TokenID      string `json:"token_id,omitempty"`
+	Delegate     string `json:"delegate,omitempty"`
+	MeltedAmount uint64 `json:"melted_amount,omitempty"`
+	BurnedAmount uint64 `json:"burned_amount,omitempty"`
+	Message      string `json:"message"`
+}
+
+// MintToken creates or mints a token from the node wallet
+func (c *Client) MintToken(ctx context.Context, req MintRequest) (*TokenTxResult, error) {
+	return c.tokenTx(ctx, "/api/token/mint", req)
+}
+
+// MeltToken melts amount (0 for all) of the node wallet's tokenID back into SHADOW
+func (c *Client) MeltToken(ctx context.Context, tokenID string, amount uint64) (*TokenTxResult, error) {
+	req := struct {
+		TokenID string `json:"token_id"`
+		Amount  uint64 `json:"amount"`
+	}{tokenID, amount}
+	return c.tokenTx(ctx, "/api/token/melt", req)
+}
+
+// BurnToken destroys amount of the node wallet's tokenID ("" for SHADOW)
+func (c *Client) BurnToken(ctx context.Context, tokenID string, amount uint64, memo string) (*TokenTxResult, error) {
+	req := struct {
+		TokenID string `json:"token_id,omitempty"`
+		Amount  uint64 `json:"amount"`
+		Memo    string `json:"memo,omitempty"`
+	}{tokenID, amount, memo}
+	return c.tokenTx(ctx, "/api/token/burn", req)
+}
+
+// DelegateRequest grants or revokes an address's right to mint a token
+type DelegateRequest struct {
+	TokenID      string `json:"token_id"`
+	Delegate     string `json:"delegate"`
+	PeriodBlocks uint64 `json:"period_blocks,omitempty"` // Limit period length
+	PeriodLimit  uint64 `json:"period_limit,omitempty"`  // Most the delegate may mint per period
+	Revoke       bool   `json:"revoke,omitempty"`
+}
+
+// DelegateMint grants or revokes a mint delegate of a token the node wallet created
+func (c *Client) DelegateMint(ctx context.Context, req DelegateRequest) (*TokenTxResult, error) {
+	return c.tokenTx(ctx, "/api/token/delegate", req)
+}
+
+func (c *Client) tokenTx(ctx context.Context, path string, req interface{}) (*TokenTxResult, error) {
+	var result TokenTxResult
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TokenBurns is a token's burn history
+type TokenBurns struct {
+	TokenID     string            `json:"token_id"`
+	Ticker      string            `json:"ticker"`
+	BurnAddress string            `json:"burn_address"`
+	TotalBurned uint64            `json:"total_burned"`
+	Count       int               `json:"count"`
+	Burns       []*lib.BurnRecord `json:"burns"`
+}
+
+// TokenBurns returns the burns of tokenID
+func (c *Client) TokenBurns(ctx context.Context, tokenID string) (*TokenBurns, error) {
+	var result TokenBurns
+	if err := c.do(ctx, http.MethodGet, "/api/token/burns", url.Values{"token_id": {tokenID}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TokenUTXOs returns up to limit unspent outputs of tokenID (0 for the node's default)
+func (c *Client) TokenUTXOs(ctx context.Context, tokenID string, limit int) ([]UTXO, error) {
+	query := url.Values{"token_id": {tokenID}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var result struct {
+		UTXOs []UTXO `json:"utxos"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/token/utxos", query, nil, &result); err != nil {
+		return nil, err
+	}
+	for i := range result.UTXOs {
+		result.UTXOs[i].TokenID = tokenID
+	}
+	return result.UTXOs, nil
+}
+
+// TokenTransactions returns up to count confirmed transactions that moved tokenID (0 for
+// the node's default), newest first, continuing after afterTxID if set
+func (c *Client) TokenTransactions(ctx context.Context, tokenID string, count int, afterTxID string) ([]lib.TokenTx, error) {
+	query := url.Values{"token_id": {tokenID}}
+	if count > 0 {
+		query.Set("count", strconv.Itoa(count))
+	}
+	if afterTxID != "" {
+		query.Set("after", afterTxID)
+	}
+	var result struct {
+		Transactions []lib.TokenTx `json:"transactions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/token/transactions", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Transactions, nil
+}
+
+// Position is a collateral position with its ratio at the current price
+type Position struct {
+	PositionID   string `json:"position_id"`
+	TokenID      string `json:"token_id"`
+	Ticker       string `json:"ticker"`
+	Owner        string `json:"owner"`
+	Collateral   uint64 `json:"collateral"` // SHADOW locked
+	Debt         uint64 `json:"debt"`       // Tokens issued and not melted back
+	Price        uint64 `json:"price"`
+	Ratio        uint64 `json:"ratio,omitempty"` // Percent, set while it owes tokens
+	Liquidatable bool   `json:"liquidatable"`
+	OpenedAt     uint64 `json:"opened_at"`
+	UpdatedAt    uint64 `json:"updated_at"`
+}
+
+// PositionFilter narrows a position list; empty fields match everything
+type PositionFilter struct {
+	TokenID      string
+	Owner        string
+	Liquidatable bool
+}
+
+// Positions returns the collateral positions matching filter
+func (c *Client) Positions(ctx context.Context, filter PositionFilter) ([]Position, error) {
+	query := url.Values{}
+	if filter.TokenID != "" {
+		query.Set("token_id", filter.TokenID)
+	}
+	if filter.Owner != "" {
+		query.Set("owner", filter.Owner)
+	}
+	if filter.Liquidatable {
+		query.Set("liquidatable", "true")
+	}
+	var result struct {
+		Positions []Position `json:"positions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/token/collateral", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Positions, nil
+}
+
+// PositionTxResult is a submitted collateral transaction
+type PositionTxResult struct {
+	Success    bool   `json:"success"`
+	TxID       string `json:"tx_id"`
+	PositionID string `json:"position_id"`
+	Message    string `json:"message"`
+}
+
+// CollateralIssue locks collateral SHADOW from the node wallet and issues tokens against
+// it, into positionID if set or a new position
+func (c *Client) CollateralIssue(ctx context.Context, tokenID, positionID string, collateral, issue uint64) (*PositionTxResult, error) {
+	req := struct {
+		TokenID    string `json:"token_id"`
+		PositionID string `json:"position_id,omitempty"`
+		Collateral uint64 `json:"collateral"`
+		Issue      uint64 `json:"issue"`
+	}{tokenID, positionID, collateral, issue}
+	return c.positionTx(ctx, "/api/token/collateral/issue", req)
+}
+
+// CollateralMelt melts repay tokens back into a position and withdraws SHADOW from it
+func (c *Client) CollateralMelt(ctx context.Context, positionID string, repay, withdraw uint64) (*PositionTxResult, error) {
+	req := struct {
+		PositionID string `json:"position_id"`
+		Repay      uint64 `json:"repay"`
+		Withdraw   uint64 `json:"withdraw"`
+	}{positionID, repay, withdraw}
+	return c.positionTx(ctx, "/api/token/collateral/melt", req)
+}
+
+// Liquidate repays an undercollateralized position from the node wallet and takes its
+// collateral
+func (c *Client) Liquidate(ctx context.Context, positionID string) (*PositionTxResult, error) {
+	req := struct {
+		PositionID string `json:"position_id"`
+	}{positionID}
+	return c.positionTx(ctx, "/api/token/collateral/liquidate", req)
+}
+
+func (c *Client) positionTx(ctx context.Context, path string, req interface{}) (*PositionTxResult, error) {
+	var result PositionTxResult
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Airdrops returns every airdrop the node has run
+func (c *Client) Airdrops(ctx context.Context) ([]*lib.AirdropSummary, error) {
+	var result struct {
+		Airdrops []*lib.AirdropSummary `json:"airdrops"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/token/airdrop", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Airdrops, nil
+}
+
+// AirdropDetails is one airdrop with the status of each recipient
+type AirdropDetails struct {
+	Airdrop    *lib.AirdropSummary           `json:"airdrop"`
+	Memo       string                        `json:"memo,omitempty"`
+	Recipients []*lib.AirdropRecipientStatus `json:"recipients"`
+}
+
+// Airdrop returns one airdrop
+func (c *Client) Airdrop(ctx context.Context, id string) (*AirdropDetails, error) {
+	var result AirdropDetails
+	if err := c.do(ctx, http.MethodGet, "/api/token/airdrop", url.Values{"id": {id}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateAirdrop pays recipients tokenID ("" for SHADOW) from the node wallet, batched into
+// as few transactions as fit
+func (c *Client) CreateAirdrop(ctx context.Context, tokenID string, recipients []lib.AirdropRow, memo string, fee uint64) (*lib.AirdropSummary, error) {
+	req := struct {
+		TokenID    string           `json:"token_id,omitempty"`
+		Recipients []lib.AirdropRow `json:"recipients"`
+		Memo       string           `json:"memo,omitempty"`
+		Fee        uint64           `json:"fee,omitempty"`
+	}{tokenID, recipients, memo, fee}
+	var summary lib.AirdropSummary
+	if err := c.do(ctx, http.MethodPost, "/api/token/airdrop", nil, req, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
@@ -0,0 +1,440 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// SubmitResult is a transaction the node accepted for verification
+type SubmitResult struct {
+	Status string `json:"status"` // "accepted"
+	TxID   string `json:"tx_id"`
+}
+
+// SubmitTransaction submits a signed transaction. The quick checks run before the
+// response; poll GetTransaction for the outcome of signature verification.
+func (c *Client) SubmitTransaction(ctx context.Context, tx *lib.Transaction) (*SubmitResult, error) {
+	var result SubmitResult
+	if err := c.do(ctx, http.MethodPost, "/api/tx/submit", nil, tx, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SubmitRawTransaction submits a signed transaction in its raw form, as produced by
+// lib.EncodeRawTransaction
+func (c *Client) SubmitRawTransaction(ctx context.Context, raw []byte) (*SubmitResult, error) {
+	req := struct {
+		Raw string `json:"raw"`
+	}{hex.EncodeToString(raw)}
+	var result SubmitResult
+	if err := c.do(ctx, http.MethodPost, "/api/tx/submit_raw", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SubmitPackage submits dependent signed transactions to be added all together or not
+// at all
+func (c *Client) SubmitPackage(ctx context.Context, txs []*lib.Transaction) (*lib.PackageResult, error) {
+	req := struct {
+		Transactions []*lib.Transaction `json:"transactions"`
+	}{txs}
+	var result lib.PackageResult
+	if err := c.do(ctx, http.MethodPost, "/api/tx/submit_package", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SimulateTransaction dry-runs a signed transaction against the node's current state
+func (c *Client) SimulateTransaction(ctx context.Context, tx *lib.Transaction) (*lib.TxSimulation, error) {
+	var result lib.TxSimulation
+	if err := c.do(ctx, http.MethodPost, "/api/tx/simulate", nil, tx, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BuildOutput is one payment of a built transaction
+type BuildOutput struct {
+	Address string `json:"address"`            // Address or address book label
+	Amount  uint64 `json:"amount"`             // Base units
+	TokenID string `json:"token_id,omitempty"` // Default SHADOW
+}
+
+// BuildRequest asks the node to select coins for an unsigned send
+type BuildRequest struct {
+	FromAddress   string        `json:"from_address"`
+	ChangeAddress string        `json:"change_address,omitempty"` // Default from_address
+	Outputs       []BuildOutput `json:"outputs"`
+	Fee           uint64        `json:"fee,omitempty"`         // 0 = estimate
+	Memo          string        `json:"memo,omitempty"`        // ASCII, up to 64 bytes
+	MempoolTTL    uint32        `json:"mempool_ttl,omitempty"` // Last block height that may include it
+}
+
+// BuildTransaction returns an unsigned send from any address for its owner to sign
+func (c *Client) BuildTransaction(ctx context.Context, req BuildRequest) (*lib.BuiltTransaction, error) {
+	var built lib.BuiltTransaction
+	if err := c.do(ctx, http.MethodPost, "/api/tx/build", nil, req, &built); err != nil {
+		return nil, err
+	}
+	return &built, nil
+}
+
+// SignInputsResult is a transaction with the node wallet's inputs signed
+type SignInputsResult struct {
+	Transaction  *lib.Transaction `json:"transaction"`
+	SignedInputs []int            `json:"signed_inputs"`
+	SigHash      string           `json:"sighash"`
+}
+
+// SignInputs has the node wallet sign the inputs of tx it owns under sigHash ("" for ALL)
+func (c *Client) SignInputs(ctx context.Context, tx *lib.Transaction, sigHash string) (*SignInputsResult, error) {
+	req := struct {
+		Transaction *lib.Transaction `json:"transaction"`
+		SigHash     string           `json:"sighash,omitempty"`
+	}{tx, sigHash}
+	var result SignInputsResult
+	if err := c.do(ctx, http.MethodPost, "/api/tx/sign-inputs", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendRequest pays from the node wallet
+type SendRequest struct {
+	ToAddress string               `json:"to_address"` // Address or address book label
+	Amount    uint64               `json:"amount"`
+	TokenID   string               `json:"token_id,omitempty"`  // Default SHADOW
+	Fee       uint64               `json:"fee,omitempty"`       // 0 = estimate
+	TokenFee  uint64               `json:"token_fee,omitempty"` // Fee paid in the sent custom token instead
+	Memo      string               `json:"memo,omitempty"`      // ASCII, up to 64 bytes
+	Vesting   *lib.VestingSchedule `json:"vesting,omitempty"`   // The recipient's output vests on this schedule
+}
+
+// SendResult is a transaction the node wallet built, signed and added to the mempool
+type SendResult struct {
+	Status string           `json:"status"`
+	TxID   string           `json:"tx_id"`
+	Tx     *lib.Transaction `json:"tx"`
+}
+
+// Send pays from the node wallet
+func (c *Client) Send(ctx context.Context, req SendRequest) (*SendResult, error) {
+	var result SendResult
+	if err := c.do(ctx, http.MethodPost, "/api/tx/send", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TransactionDetails describes a stored transaction and where it was confirmed
+type TransactionDetails struct {
+	TxHash            string          `json:"tx_hash"`
+	TxType            lib.TxType      `json:"tx_type"`
+	Version           uint32          `json:"version"`
+	LockTime          uint32          `json:"locktime"`
+	Timestamp         int64           `json:"timestamp"`
+	Inputs            []*lib.TxInput  `json:"inputs"`
+	Outputs           []*lib.TxOutput `json:"outputs"`
+	Data              []byte          `json:"data,omitempty"`
+	Status            string          `json:"status,omitempty"` // lib.TxStatusConfirmed, Finalized, Failed or Orphaned
+	Confirmed         bool            `json:"confirmed"`
+	Confirmations     uint64          `json:"confirmations"`
+	BlockHeight       uint64          `json:"block_height,omitempty"`
+	BlockHash         string          `json:"block_hash,omitempty"`
+	BlockTimestamp    int64           `json:"block_timestamp,omitempty"`
+	Failure           *lib.TxFailure  `json:"failure,omitempty"`
+	Events            []lib.TxEvent   `json:"events,omitempty"`
+	InMempool         bool            `json:"in_mempool,omitempty"`
+	OrphanedHeight    uint64          `json:"orphaned_height,omitempty"`
+	OrphanedBlockHash string          `json:"orphaned_block_hash,omitempty"`
+}
+
+// TxStatus is what the node knows of a transaction: pending, verifying, rejected,
+// conflicted, pruned or confirmed. Transaction is set while it is pending and Details
+// once it is confirmed.
+type TxStatus struct {
+	TxID              string          `json:"tx_id"`
+	Status            string          `json:"status"`
+	Reason            string          `json:"reason,omitempty"`   // Rejected
+	Conflict          *lib.TxConflict `json:"conflict,omitempty"` // Conflicted
+	Confirmations     uint64          `json:"confirmations"`
+	BlockHeight       uint64          `json:"block_height,omitempty"` // Pruned
+	BlockHash         string          `json:"block_hash,omitempty"`
+	OrphanedHeight    uint64          `json:"orphaned_height,omitempty"` // Pending again after a reorg
+	OrphanedBlockHash string          `json:"orphaned_block_hash,omitempty"`
+
+	Transaction *lib.Transaction    `json:"-"`
+	Details     *TransactionDetails `json:"-"`
+}
+
+// GetTransaction returns the status of a transaction submitted to or confirmed on the
+// node. A transaction the node has never seen is a 404 APIError.
+func (c *Client) GetTransaction(ctx context.Context, txID string) (*TxStatus, error) {
+	status, body, header, err := c.send(ctx, http.MethodGet, "/api/tx/"+pathEscape(txID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK, http.StatusAccepted, http.StatusUnprocessableEntity, http.StatusConflict, http.StatusGone:
+	default:
+		return nil, newAPIError(status, body, header)
+	}
+
+	var result TxStatus
+	if err := decode(body, &result); err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return &result, nil
+	}
+	if result.Status == lib.TxStatusPending {
+		result.TxID = txID
+		result.Transaction = &lib.Transaction{}
+		if err := decode(body, result.Transaction); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+	result.TxID = txID
+	result.Details = &TransactionDetails{}
+	if err := decode(body, result.Details); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TransactionDetails returns a confirmed transaction with its block, failure and events
+func (c *Client) TransactionDetails(ctx context.Context, txID string) (*TransactionDetails, error) {
+	var details TransactionDetails
+	if err := c.do(ctx, http.MethodGet, "/api/transaction/"+pathEscape(txID), nil, nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// MemoSearch is the transactions carrying one memo
+type MemoSearch struct {
+	Memo         string          `json:"memo"`
+	Count        int             `json:"count"`
+	Transactions []lib.MemoMatch `json:"transactions"` // Confirmed, newest first
+	Pending      []string        `json:"pending"`      // IDs in the mempool
+}
+
+// SearchMemo finds transactions whose memo is exactly memo; limit 0 uses the node's
+// default
+func (c *Client) SearchMemo(ctx context.Context, memo string, limit int) (*MemoSearch, error) {
+	query := url.Values{"memo": {memo}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var result MemoSearch
+	if err := c.do(ctx, http.MethodGet, "/api/tx/search", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RelayStats counts transaction relay between peers
+type RelayStats struct {
+	Announced    uint64 `json:"announced"`
+	Fetched      uint64 `json:"fetched"`
+	Served       uint64 `json:"served"`
+	BelowFee     uint64 `json:"below_fee"`
+	Replaced     uint64 `json:"replaced"`
+	Forwarded    uint64 `json:"forwarded"`
+	Pushed       uint64 `json:"pushed"`
+	TrackedPeers int    `json:"tracked_peers"`
+	InFlight     int    `json:"in_flight"`
+}
+
+// AdmissionStats counts the signature verification queue
+type AdmissionStats struct {
+	QueueLength   int    `json:"queue_length"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Queued        uint64 `json:"queued"`
+	Admitted      uint64 `json:"admitted"`
+	Rejected      uint64 `json:"rejected"`
+	Busy          uint64 `json:"busy"`
+}
+
+// Mempool is the node's pending transactions
+type Mempool struct {
+	Count        int                `json:"count"`
+	Transactions []*lib.Transaction `json:"transactions"`
+	TTLRemaining map[string]uint64  `json:"ttl_remaining"` // Blocks left for TTL-bound transactions
+	Relay        RelayStats         `json:"relay"`
+	Admission    AdmissionStats     `json:"admission"`
+}
+
+// Mempool returns the pending transactions
+func (c *Client) Mempool(ctx context.Context) (*Mempool, error) {
+	var result Mempool
+	if err := c.do(ctx, http.MethodGet, "/api/mempool", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MempoolStats is the mempool's size against its cap and what eviction dropped
+type MempoolStats struct {
+	Count           int     `json:"count"`
+	SizeBytes       int     `json:"size_bytes"`
+	MaxSizeBytes    int     `json:"max_size_bytes"`
+	Local           int     `json:"local"`
+	InGrace         int     `json:"in_grace"`
+	MinFeeRate      float64 `json:"min_fee_rate"`
+	Evicted         uint64  `json:"evicted"`
+	EvictedBytes    uint64  `json:"evicted_bytes"`
+	EvictedInGrace  uint64  `json:"evicted_in_grace"`
+	EvictedLocal    uint64  `json:"evicted_local"`
+	Refused         uint64  `json:"refused"`
+	LastEvictedRate float64 `json:"last_evicted_rate"`
+	LastEviction    int64   `json:"last_eviction,omitempty"`
+}
+
+// MempoolStats returns the mempool's size and eviction counts
+func (c *Client) MempoolStats(ctx context.Context) (*MempoolStats, error) {
+	var result MempoolStats
+	if err := c.do(ctx, http.MethodGet, "/api/mempool/stats", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelResult is a cancelled pending transaction
+type CancelResult struct {
+	Success       bool   `json:"success"`
+	NetworkWide   bool   `json:"network_wide"`             // Replaced by fee, not just dropped here
+	ReplacementID string `json:"replacement_id,omitempty"` // Set when network_wide
+	Fee           uint64 `json:"fee,omitempty"`
+	Message       string `json:"message"`
+}
+
+// CancelMempoolTx cancels a pending transaction signed by publicKey
+func (c *Client) CancelMempoolTx(ctx context.Context, txID string, publicKey []byte) (*CancelResult, error) {
+	req := struct {
+		TxID      string `json:"tx_id"`
+		PublicKey []byte `json:"public_key"`
+	}{txID, publicKey}
+	var result CancelResult
+	if err := c.do(ctx, http.MethodPost, "/api/mempool/cancel", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Condition is a parsed spend condition
+type Condition struct {
+	Descriptor string `json:"descriptor"` // Canonical form
+	Address    string `json:"address"`    // Address outputs locked by it pay
+}
+
+// ParseCondition parses a spend condition descriptor
+func (c *Client) ParseCondition(ctx context.Context, descriptor string) (*Condition, error) {
+	var result Condition
+	if err := c.do(ctx, http.MethodGet, "/api/condition", url.Values{"descriptor": {descriptor}}, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HTLCList is the HTLCs matching a query
+type HTLCList struct {
+	HTLCs []*lib.HTLC `json:"htlcs"`
+	Count int         `json:"count"`
+}
+
+// HTLC returns one HTLC by ID
+func (c *Client) HTLC(ctx context.Context, id string) (*lib.HTLC, error) {
+	var htlc lib.HTLC
+	if err := c.do(ctx, http.MethodGet, "/api/htlc", url.Values{"id": {id}}, nil, &htlc); err != nil {
+		return nil, err
+	}
+	return &htlc, nil
+}
+
+// HTLCs lists the HTLCs address can claim or refund ("" for all), optionally by status
+func (c *Client) HTLCs(ctx context.Context, address, status string) (*HTLCList, error) {
+	query := url.Values{}
+	if address != "" {
+		query.Set("address", address)
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+	var result HTLCList
+	if err := c.do(ctx, http.MethodGet, "/api/htlc", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HTLCLockRequest locks node wallet funds in an HTLC
+type HTLCLockRequest struct {
+	Recipient string `json:"recipient"`          // Claims with the secret
+	Refund    string `json:"refund,omitempty"`   // Default the node wallet
+	Hash      string `json:"hash,omitempty"`     // SHA-256 of the secret, hex; default: the node generates a secret
+	Timeout   uint64 `json:"timeout"`            // Height the refund becomes possible at
+	Amount    uint64 `json:"amount"`             // Base units
+	TokenID   string `json:"token_id,omitempty"` // Default SHADOW
+	Fee       uint64 `json:"fee,omitempty"`
+}
+
+// HTLCLockResult is a submitted HTLC lock
+type HTLCLockResult struct {
+	Status     string `json:"status"`
+	TxID       string `json:"tx_id"`
+	HTLCID     string `json:"htlc_id"`
+	Address    string `json:"address"`
+	Descriptor string `json:"descriptor"`
+	Hash       string `json:"hash"`
+	Timeout    uint64 `json:"timeout"`
+	Secret     string `json:"secret,omitempty"` // Generated secret; keep it to claim the other side
+}
+
+// LockHTLC locks node wallet funds in an HTLC
+func (c *Client) LockHTLC(ctx context.Context, req HTLCLockRequest) (*HTLCLockResult, error) {
+	var result HTLCLockResult
+	if err := c.do(ctx, http.MethodPost, "/api/htlc/lock", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HTLCSpendResult is a submitted HTLC claim or refund
+type HTLCSpendResult struct {
+	Status string `json:"status"`
+	TxID   string `json:"tx_id"`
+	HTLCID string `json:"htlc_id"`
+}
+
+// ClaimHTLC claims an HTLC paying the node wallet by revealing its secret (hex)
+func (c *Client) ClaimHTLC(ctx context.Context, htlcID, preimage string, fee uint64) (*HTLCSpendResult, error) {
+	return c.spendHTLC(ctx, "/api/htlc/claim", htlcID, preimage, fee)
+}
+
+// RefundHTLC refunds an HTLC to the node wallet after its timeout
+func (c *Client) RefundHTLC(ctx context.Context, htlcID string, fee uint64) (*HTLCSpendResult, error) {
+	return c.spendHTLC(ctx, "/api/htlc/refund", htlcID, "", fee)
+}
+
+func (c *Client) spendHTLC(ctx context.Context, path, htlcID, preimage string, fee uint64) (*HTLCSpendResult, error) {
+	req := struct {
+		HTLCID   string `json:"htlc_id"`
+		Preimage string `json:"preimage,omitempty"`
+		Fee      uint64 `json:"fee,omitempty"`
+	}{htlcID, preimage, fee}
+	var result HTLCSpendResult
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,368 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"shadowy/lib"
+)
+
+// TokenBalance is an address's balance of one token
+type TokenBalance struct {
+	TokenID  string `json:"token_id"`
+	Balance  uint64 `json:"balance"`
+	Name     string `json:"name"`
+	Ticker   string `json:"ticker"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// UTXO is an unspent output as the API lists it
+type UTXO struct {
+	TxID        string `json:"tx_id"`
+	OutputIndex uint32 `json:"output_index"`
+	Amount      uint64 `json:"amount"`
+	TokenID     string `json:"token_id,omitempty"`
+	Address     string `json:"address,omitempty"`
+	AddressType string `json:"address_type"`
+	BlockHeight uint64 `json:"block_height"`
+	IsSpent     bool   `json:"is_spent,omitempty"`
+}
+
+// Balance is an address's balances and unspent outputs
+type Balance struct {
+	Address  string         `json:"address"`
+	Height   uint64         `json:"height"`
+	Balances []TokenBalance `json:"balances"`
+	UTXOs    []UTXO         `json:"utxos"`
+	Count    int            `json:"count"`
+}
+
+// Balance returns the balances of address ("" for the node wallet) at the tip
+func (c *Client) Balance(ctx context.Context, address string) (*Balance, error) {
+	return c.balance(ctx, address, url.Values{})
+}
+
+// BalanceAt returns the balances of address as of the block at height
+func (c *Client) BalanceAt(ctx context.Context, address string, height uint64) (*Balance, error) {
+	return c.balance(ctx, address, url.Values{"height": {strconv.FormatUint(height, 10)}})
+}
+
+func (c *Client) balance(ctx context.Context, address string, query url.Values) (*Balance, error) {
+	if address != "" {
+		query.Set("address", address)
+	}
+	var result Balance
+	if err := c.do(ctx, http.MethodGet, "/api/balance", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TokenMeta is a token's ticker and decimals
+type TokenMeta struct {
+	Ticker   string `json:"ticker"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// Balances is the balances of many addresses at one height
+type Balances struct {
+	Height   uint64                `json:"height"`
+	Balances []lib.AddressBalances `json:"balances"`
+	Tokens   map[string]TokenMeta  `json:"tokens"` // Token ID -> ticker and decimals
+	Count    int                   `json:"count"`
+}
+
+// Balances returns the balances of addresses in one consistent read, limited to tokens
+// if any are given
+func (c *Client) Balances(ctx context.Context, addresses []string, tokens ...string) (*Balances, error) {
+	req := struct {
+		Addresses []string `json:"addresses"`
+		Tokens    []string `json:"tokens,omitempty"`
+	}{addresses, tokens}
+	var result Balances
+	if err := c.do(ctx, http.MethodPost, "/api/balances", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UTXOList is an address's outputs
+type UTXOList struct {
+	Address string `json:"address"`
+	UTXOs   []UTXO `json:"utxos"`
+	Count   int    `json:"count"`
+}
+
+// UTXOs returns the unspent outputs of address ("" for the node wallet), of tokenID
+// only if set
+func (c *Client) UTXOs(ctx context.Context, address, tokenID string) (*UTXOList, error) {
+	query := url.Values{}
+	if address != "" {
+		query.Set("address", address)
+	}
+	if tokenID != "" {
+		query.Set("token_id", tokenID)
+	}
+	var result UTXOList
+	if err := c.do(ctx, http.MethodGet, "/api/utxos", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// VestingTotals is the locked and vested amounts of one token
+type VestingTotals struct {
+	Locked uint64 `json:"locked"`
+	Vested uint64 `json:"vested"`
+}
+
+// VestingOutput is an output that vests on a schedule
+type VestingOutput struct {
+	TxID        string               `json:"tx_id"`
+	OutputIndex uint32               `json:"output_index"`
+	TokenID     string               `json:"token_id"`
+	Amount      uint64               `json:"amount"`
+	AddressType string               `json:"address_type"`
+	Schedule    *lib.VestingSchedule `json:"schedule"`
+	Vested      uint64               `json:"vested"`
+	Locked      uint64               `json:"locked"`
+}
+
+// Vesting is an address's vesting outputs
+type Vesting struct {
+	Address string                   `json:"address"`
+	Height  uint64                   `json:"height"`
+	Totals  map[string]VestingTotals `json:"totals"` // Token ID -> totals
+	Outputs []VestingOutput          `json:"outputs"`
+	Count   int                      `json:"count"`
+}
+
+// Vesting returns the vesting outputs of address ("" for the node wallet)
+func (c *Client) Vesting(ctx context.Context, address string) (*Vesting, error) {
+	query := url.Values{}
+	if address != "" {
+		query.Set("address", address)
+	}
+	var result Vesting
+	if err := c.do(ctx, http.MethodGet, "/api/vesting", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ClaimResult is a submitted vesting claim
+type ClaimResult struct {
+	Status  string `json:"status"`
+	TxID    string `json:"tx_id"`
+	Claimed uint64 `json:"claimed"`
+}
+
+// ClaimVesting moves the node wallet's vested tokenID ("" for SHADOW) to spendable outputs
+func (c *Client) ClaimVesting(ctx context.Context, tokenID string, fee uint64) (*ClaimResult, error) {
+	req := struct {
+		TokenID string `json:"token_id,omitempty"`
+		Fee     uint64 `json:"fee,omitempty"`
+	}{tokenID, fee}
+	var result ClaimResult
+	if err := c.do(ctx, http.MethodPost, "/api/vesting/claim", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddressTx is a confirmed transaction paying an address
+type AddressTx struct {
+	TxID        string            `json:"tx_id"`
+	BlockHeight uint64            `json:"block_height"`
+	Timestamp   int64             `json:"timestamp"`
+	Labels      map[string]string `json:"labels,omitempty"` // Output address -> address book label
+}
+
+// AddressTxs is the transactions paying an address
+type AddressTxs struct {
+	Address      string      `json:"address"`
+	Label        string      `json:"label,omitempty"`
+	Transactions []AddressTx `json:"transactions"`
+	Count        int         `json:"count"`
+}
+
+// Transactions returns the transactions with unspent outputs paying address ("" for the
+// node wallet)
+func (c *Client) Transactions(ctx context.Context, address string) (*AddressTxs, error) {
+	query := url.Values{}
+	if address != "" {
+		query.Set("address", address)
+	}
+	var result AddressTxs
+	if err := c.do(ctx, http.MethodGet, "/api/transactions", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WalletAddress returns the node wallet's address
+func (c *Client) WalletAddress(ctx context.Context) (string, error) {
+	var result struct {
+		Address string `json:"address"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/wallet/info", nil, nil, &result); err != nil {
+		return "", err
+	}
+	return result.Address, nil
+}
+
+// WalletPending returns the node wallet's submitted transactions, with status only if set
+func (c *Client) WalletPending(ctx context.Context, status lib.WalletTxStatus) ([]*lib.TrackedTx, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	var result struct {
+		Transactions []*lib.TrackedTx `json:"transactions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/wallet/pending", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Transactions, nil
+}
+
+// NewAddress is a receive address issued by the node wallet
+type NewAddress struct {
+	Address  string `json:"address"`
+	Index    uint32 `json:"index"`
+	Unused   int    `json:"unused"`
+	GapLimit int    `json:"gap_limit"`
+}
+
+// NewAddress issues the node wallet's next receive address
+func (c *Client) NewAddress(ctx context.Context) (*NewAddress, error) {
+	var result NewAddress
+	if err := c.do(ctx, http.MethodPost, "/api/wallet/new_address", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ReceiveAddresses is the node wallet's issued receive addresses
+type ReceiveAddresses struct {
+	Count     int                   `json:"count"`
+	Unused    int                   `json:"unused"`
+	GapLimit  int                   `json:"gap_limit"`
+	Addresses []*lib.ReceiveAddress `json:"addresses"`
+}
+
+// ReceiveAddresses returns the node wallet's issued receive addresses
+func (c *Client) ReceiveAddresses(ctx context.Context) (*ReceiveAddresses, error) {
+	var result ReceiveAddresses
+	if err := c.do(ctx, http.MethodGet, "/api/wallet/addresses", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WatchOnlyEntry is a watched address with its SHADOW balance and pending transactions
+type WatchOnlyEntry struct {
+	Address          string               `json:"address"`
+	PublicKey        string               `json:"public_key,omitempty"`
+	Label            string               `json:"label,omitempty"`
+	Created          int64                `json:"created"`
+	Signable         bool                 `json:"signable"`
+	Balance          uint64               `json:"balance"`
+	PendingCount     int                  `json:"pending_count"`
+	RequireMemo      bool                 `json:"require_memo"`
+	MissingMemoCount int                  `json:"missing_memo_count"`
+	Pending          []lib.WatchPendingTx `json:"pending,omitempty"` // Only from WatchOnlyAddress
+}
+
+// WatchOnly returns every watched address
+func (c *Client) WatchOnly(ctx context.Context) ([]WatchOnlyEntry, error) {
+	var result struct {
+		Entries []WatchOnlyEntry `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/wallet/watch", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// WatchOnlyAddress returns one watched address with its pending transactions
+func (c *Client) WatchOnlyAddress(ctx context.Context, address string) (*WatchOnlyEntry, error) {
+	var entry WatchOnlyEntry
+	if err := c.do(ctx, http.MethodGet, "/api/wallet/watch", url.Values{"address": {address}}, nil, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Watch imports an address, or the address of a hex public key, as watch-only
+func (c *Client) Watch(ctx context.Context, addressOrPublicKey, label string) (*WatchOnlyEntry, error) {
+	req := struct {
+		Address string `json:"address"`
+		Label   string `json:"label,omitempty"`
+	}{addressOrPublicKey, label}
+	var result struct {
+		Entry *WatchOnlyEntry `json:"entry"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/wallet/watch", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return result.Entry, nil
+}
+
+// Unwatch stops watching address
+func (c *Client) Unwatch(ctx context.Context, address string) error {
+	return c.do(ctx, http.MethodDelete, "/api/wallet/watch", url.Values{"address": {address}}, nil, nil)
+}
+
+// AddressBookEntry is a labelled address
+type AddressBookEntry struct {
+	Label       string `json:"label"`
+	Address     string `json:"address"`
+	Note        string `json:"note,omitempty"`
+	RequireMemo bool   `json:"require_memo"`
+	Created     int64  `json:"created"`
+}
+
+// AddressBook returns every labelled address
+func (c *Client) AddressBook(ctx context.Context) ([]AddressBookEntry, error) {
+	var result struct {
+		Entries []AddressBookEntry `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/addressbook", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// AddressBookEntry returns the address labelled label
+func (c *Client) AddressBookEntry(ctx context.Context, label string) (*AddressBookEntry, error) {
+	var entry AddressBookEntry
+	if err := c.do(ctx, http.MethodGet, "/api/addressbook", url.Values{"label": {label}}, nil, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SetAddressBookEntry adds or updates a label. requireMemo is left unchanged if nil.
+func (c *Client) SetAddressBookEntry(ctx context.Context, label, address, note string, requireMemo *bool) (*AddressBookEntry, error) {
+	req := struct {
+		Label       string `json:"label"`
+		Address     string `json:"address"`
+		Note        string `json:"note,omitempty"`
+		RequireMemo *bool  `json:"require_memo,omitempty"`
+	}{label, address, note, requireMemo}
+	var result struct {
+		Entry *AddressBookEntry `json:"entry"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/addressbook", nil, req, &result); err != nil {
+		return nil, err
+	}
+	return result.Entry, nil
+}
+
+// DeleteAddressBookEntry removes a label
+func (c *Client) DeleteAddressBookEntry(ctx context.Context, label string) error {
+	return c.do(ctx, http.MethodDelete, "/api/addressbook", url.Values{"label": {label}}, nil, nil)
+}
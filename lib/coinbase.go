@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// A block's coinbase pays the proof winner the block reward for its height plus the
+// SHADOW fees of the block's transactions. BuildCoinbase is the only place a coinbase is
+// built, and ValidateCoinbase holds every block to the same rule: it may claim less, but
+// never more. Settlements pay no fee, and token fees are converted and paid to the winner
+// when the block is applied, so neither is part of the coinbase.
+
+// CoinbaseAllowance is the most a coinbase at height may claim given the block's fees
+func CoinbaseAllowance(height, fees uint64) uint64 {
+	reward := calculateBlockReward(height)
+	if fees > ^uint64(0)-reward {
+		return ^uint64(0)
+	}
+	return reward + fees
+}
+
+// BuildCoinbase creates the coinbase of the block at height, paying the block reward and
+// fees to winner
+func BuildCoinbase(height, fees uint64, winner Address, timestamp int64) *Transaction {
+	return newCoinbaseAt(winner, CoinbaseAllowance(height, fees), timestamp)
+}
+
+// blockFees sums the SHADOW fees of a block's transactions, resolving inputs from the
+// UTXO set and from earlier transactions in the block. complete is false if a transaction
+// couldn't be found in the mempool or storage, so its fee is unknown.
+func (bc *Blockchain) blockFees(block *Block, mempool *Mempool) (fees uint64, complete bool) {
+	embedded := make(map[string]bool, len(block.Settlements)+1)
+	if block.Coinbase != nil {
+		coinbaseID, _ := block.Coinbase.ID()
+		embedded[coinbaseID] = true
+	}
+	for _, settlement := range block.Settlements {
+		settlementID, _ := settlement.ID()
+		embedded[settlementID] = true
+	}
+
+	created := make(map[string]*TxOutput)
+	confirmed := utxoLookup(bc.utxoStore)
+	lookup := func(txID string, index uint32) *TxOutput {
+		if output, ok := created[fmt.Sprintf("%s:%d", txID, index)]; ok {
+			return output
+		}
+		return confirmed(txID, index)
+	}
+
+	complete = true
+	for _, txID := range block.Transactions {
+		if embedded[txID] {
+			continue
+		}
+		var tx *Transaction
+		if mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx == nil {
+			complete = false
+			continue
+		}
+		fees += paidFee(tx, lookup)
+		for i, output := range tx.Outputs {
+			created[fmt.Sprintf("%s:%d", txID, i)] = output
+		}
+	}
+	return fees, complete
+}
+
+// ValidateCoinbase rejects a block whose coinbase isn't a plain SHADOW payment to the
+// proof winner, or claims more than the block reward plus the block's fees. When some of
+// the block's transactions are unknown their fees can't be counted, so only the shape of
+// the coinbase is checked, the same way other checks skip unknown transactions. Blocks
+// below the rule's activation height keep whatever coinbase they were built with.
+func (bc *Blockchain) ValidateCoinbase(block *Block, mempool *Mempool) error {
+	coinbase := block.Coinbase
+	if coinbase == nil || block.Index < ActiveGenesis().RuleActivationHeights().Coinbase {
+		return nil
+	}
+	if coinbase.TxType != TxTypeCoinbase {
+		return fmt.Errorf("coinbase has type %s", coinbase.TxType.String())
+	}
+	if len(coinbase.Inputs) > 0 {
+		return fmt.Errorf("coinbase spends %d inputs", len(coinbase.Inputs))
+	}
+
+	genesisTokenID := GetGenesisToken().TokenID
+	var claimed uint64
+	for i, output := range coinbase.Outputs {
+		if output.TokenID != genesisTokenID {
+			return fmt.Errorf("coinbase output %d pays token %s", i, output.TokenID)
+		}
+		if block.WinnerAddress != nil && output.Address != *block.WinnerAddress {
+			return fmt.Errorf("coinbase output %d pays %s, not the proof winner %s",
+				i, output.Address.String(), block.WinnerAddress.String())
+		}
+		if claimed+output.Amount < claimed {
+			return fmt.Errorf("coinbase outputs overflow")
+		}
+		claimed += output.Amount
+	}
+
+	fees, complete := bc.blockFees(block, mempool)
+	if !complete {
+		return nil
+	}
+	if allowed := CoinbaseAllowance(block.Index, fees); claimed > allowed {
+		return fmt.Errorf("coinbase claims %d, block reward plus %d in fees allows %d", claimed, fees, allowed)
+	}
+	return nil
+}
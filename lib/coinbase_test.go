@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateCoinbase(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	alice, _ := GenerateKeyPair()
+	winner, _ := GenerateKeyPair()
+	nextBlock := func(coinbase *Transaction, txIDs []string) *Block {
+		prev := bc.GetLatestBlock()
+		if coinbase != nil {
+			coinbaseID, _ := coinbase.ID()
+			txIDs = append([]string{coinbaseID}, txIDs...)
+		}
+		winnerAddr := winner.Address()
+		block := &Block{
+			Index:         prev.Index + 1,
+			Timestamp:     prev.Timestamp + 1,
+			Transactions:  txIDs,
+			Coinbase:      coinbase,
+			PreviousHash:  prev.Hash,
+			Proposer:      "coinbase-test-proposer",
+			WinnerAddress: &winnerAddr,
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		return block
+	}
+
+	// Fund alice, then have her pay a 10 satoshi fee
+	funding := &Transaction{Version: 1, TxType: TxTypeSend, Outputs: []*TxOutput{CreateShadowOutput(alice.Address(), 100)}}
+	if err := bc.AddBlock(nextBlock(BuildCoinbase(1, 0, winner.Address(), 1), nil), nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if err := bc.GetUTXOStore().AddUTXO(&UTXO{TxID: "coinbase-test-funding", Output: funding.Outputs[0], BlockHeight: 1}); err != nil {
+		t.Fatalf("Failed to fund: %v", err)
+	}
+	send := &Transaction{
+		Version:   1,
+		TxType:    TxTypeSend,
+		Inputs:    []*TxInput{{PrevTxID: "coinbase-test-funding", OutputIndex: 0}},
+		Outputs:   []*TxOutput{CreateShadowOutput(alice.Address(), 90)},
+		Timestamp: 1,
+	}
+	if err := bc.GetUTXOStore().StoreTransaction(send, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	sendID, _ := send.ID()
+
+	height := bc.GetHeight()
+	greedy := newCoinbaseAt(winner.Address(), CoinbaseAllowance(height, 10)+1, 2)
+	if err := bc.ValidateCoinbase(nextBlock(greedy, []string{sendID}), nil); err != nil {
+		t.Errorf("Expected the built-in network not to check the coinbase before its activation height: %v", err)
+	}
+	genesis := DefaultChainGenesis()
+	genesis.Activations = &RuleActivations{}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	if err := bc.ValidateCoinbase(nextBlock(BuildCoinbase(height, 10, winner.Address(), 2), []string{sendID}), nil); err != nil {
+		t.Fatalf("Reward plus fees rejected: %v", err)
+	}
+	if err := bc.ValidateCoinbase(nextBlock(greedy, []string{sendID}), nil); err == nil {
+		t.Error("Expected a coinbase claiming more than reward plus fees to be rejected")
+	}
+	if err := bc.ValidateCoinbase(nextBlock(BuildCoinbase(height, 10, alice.Address(), 2), []string{sendID}), nil); err == nil {
+		t.Error("Expected a coinbase not paying the proof winner to be rejected")
+	}
+	if err := bc.ValidateCoinbase(nextBlock(BuildCoinbase(height, 0, winner.Address(), 2), []string{sendID}), nil); err != nil {
+		t.Errorf("Claiming less than allowed should be accepted: %v", err)
+	}
+
+	// Fees of unknown transactions can't be counted, so the amount isn't checked
+	if err := bc.ValidateCoinbase(nextBlock(greedy, []string{sendID, "coinbase-test-unknown-transaction"}), nil); err != nil {
+		t.Errorf("Expected the amount to go unchecked with unknown transactions: %v", err)
+	}
+}
@@ -0,0 +1,748 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Collateralized tokens track a peg instead of locking SHADOW 1:1. Creating one (a mint
+// with collateral terms) only registers the token; its supply is issued later against
+// positions: a collateral_issue transaction locks SHADOW in a position and issues tokens
+// worth at most 100/Ratio of it, valuing them at their peg or their pool price if that
+// is higher. The position's owner melts the tokens back with collateral_melt, which
+// releases SHADOW, all of it once the debt is repaid. If the pool price of the token
+// rises until a position's SHADOW covers less than LiquidationRatio of its tokens, anyone
+// can liquidate it: they repay the whole debt and take the collateral.
+//
+// The pool price is the time-weighted average of the token's oldest SHADOW pool over
+// the CollateralOracleWindow blocks before the one being built, so it is the same for
+// every transaction in a block and moving it takes holding a price for many blocks.
+// Each position can be changed once per block. Released SHADOW is paid by the node as
+// an output after the transaction's own, the same way swap outputs are.
+
+const (
+	MinCollateralRatio     = 110 // Lowest issuance ratio, percent
+	MaxCollateralRatio     = 1000
+	CollateralOracleWindow = DefaultTWAPWindow // Blocks the liquidation price averages
+)
+
+// CollateralTerms are how a collateralized token is issued
+type CollateralTerms struct {
+	Peg              uint64 `json:"peg"`               // SHADOW per token base unit, times PriceScale
+	Ratio            uint64 `json:"ratio"`             // Percent of the tokens' value a position must lock to issue or withdraw
+	LiquidationRatio uint64 `json:"liquidation_ratio"` // Percent below which a position can be liquidated
+}
+
+// Validate checks the terms are usable
+func (t *CollateralTerms) Validate() error {
+	if t.Peg == 0 {
+		return fmt.Errorf("peg must be positive")
+	}
+	if t.Ratio < MinCollateralRatio || t.Ratio > MaxCollateralRatio {
+		return fmt.Errorf("collateral ratio must be %d-%d%%, got %d", MinCollateralRatio, MaxCollateralRatio, t.Ratio)
+	}
+	if t.LiquidationRatio < 100 || t.LiquidationRatio >= t.Ratio {
+		return fmt.Errorf("liquidation ratio must be at least 100%% and below the collateral ratio, got %d", t.LiquidationRatio)
+	}
+	return nil
+}
+
+// CollateralPosition is SHADOW locked against tokens issued from it
+type CollateralPosition struct {
+	ID         string  `json:"id"` // Transaction that opened it
+	TokenID    string  `json:"token_id"`
+	Owner      Address `json:"owner"`
+	Collateral uint64  `json:"collateral"` // SHADOW locked
+	Debt       uint64  `json:"debt"`       // Tokens issued and not melted back
+	OpenedAt   uint64  `json:"opened_at"`
+	UpdatedAt  uint64  `json:"updated_at"`
+}
+
+// CollateralRatio returns the position's SHADOW as a percent of its tokens' value at
+// price; the maximum when it owes nothing
+func (p *CollateralPosition) CollateralRatio(price uint64) uint64 {
+	value := mulDiv(p.Debt, price, PriceScale)
+	if value == 0 {
+		return ^uint64(0)
+	}
+	return mulDiv(p.Collateral, 100, value)
+}
+
+// CollateralIssueData is the Data of a collateral_issue transaction
+type CollateralIssueData struct {
+	TokenID    string `json:"token_id"`
+	PositionID string `json:"position_id,omitempty"` // Empty opens a position
+	Collateral uint64 `json:"collateral"`            // SHADOW to lock
+	Issue      uint64 `json:"issue"`                 // Tokens to issue
+}
+
+// CollateralMeltData is the Data of a collateral_melt transaction
+type CollateralMeltData struct {
+	PositionID string `json:"position_id"`
+	Repay      uint64 `json:"repay"`    // Tokens melted, spent by the transaction
+	Withdraw   uint64 `json:"withdraw"` // SHADOW released to the owner
+}
+
+// LiquidationData is the Data of a liquidate transaction
+type LiquidationData struct {
+	PositionID string `json:"position_id"`
+}
+
+// collateralLocked returns the SHADOW a transaction locks in a collateral position
+func collateralLocked(tx *Transaction) uint64 {
+	var data CollateralIssueData
+	if tx.TxType != TxTypeCollateralIssue || json.Unmarshal(tx.Data, &data) != nil {
+		return 0
+	}
+	return data.Collateral
+}
+
+// IsCollateralTx reports whether a transaction type changes a collateral position
+func IsCollateralTx(txType TxType) bool {
+	return txType == TxTypeCollateralIssue || txType == TxTypeCollateralMelt || txType == TxTypeLiquidate
+}
+
+// GetPosition returns a collateral position by ID
+func (tr *TokenRegistry) GetPosition(positionID string) (*CollateralPosition, bool) {
+	position, exists := tr.Positions[positionID]
+	return position, exists
+}
+
+// ListPositions returns the open positions match accepts, in ID order
+func (tr *TokenRegistry) ListPositions(match func(*CollateralPosition) bool) []*CollateralPosition {
+	var positions []*CollateralPosition
+	for _, position := range tr.Positions {
+		if match == nil || match(position) {
+			positions = append(positions, position)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].ID < positions[j].ID })
+	return positions
+}
+
+// oraclePool returns the token's oldest SHADOW pool, ties broken by pool ID, or nil
+func oraclePool(tokenID string, pools *PoolRegistry) *LiquidityPool {
+	genesisTokenID := GetGenesisToken().TokenID
+	var oldest *LiquidityPool
+	for _, pool := range pools.GetAllPools() {
+		if !(pool.TokenA == tokenID && pool.TokenB == genesisTokenID) && !(pool.TokenB == tokenID && pool.TokenA == genesisTokenID) {
+			continue
+		}
+		if oldest == nil || pool.CreatedAt < oldest.CreatedAt || (pool.CreatedAt == oldest.CreatedAt && pool.PoolID < oldest.PoolID) {
+			oldest = pool
+		}
+	}
+	return oldest
+}
+
+// CollateralPrice returns the SHADOW value of a base unit of a collateralized token in
+// the block at height, times PriceScale: the TWAP of its oracle pool over the
+// CollateralOracleWindow blocks before, or its peg if that is higher or there is no
+// price history
+func CollateralPrice(store *UTXOStore, pools *PoolRegistry, token *TokenInfo, height uint64) uint64 {
+	peg := token.Collateral.Peg
+	if store == nil || pools == nil || height < 2 {
+		return peg
+	}
+	pool := oraclePool(token.TokenID, pools)
+	if pool == nil {
+		return peg
+	}
+
+	tip := height - 1
+	from := pool.CreatedAt
+	if tip > CollateralOracleWindow && tip-CollateralOracleWindow > from {
+		from = tip - CollateralOracleWindow
+	}
+	if from >= tip {
+		return peg
+	}
+	observations, err := store.observationsAt(pool.PoolID, []uint64{from, tip})
+	if err != nil || observations[0] == nil || observations[1] == nil {
+		return peg
+	}
+	startA, startB := observations[0].cumulativeAt(from)
+	endA, endB := observations[1].cumulativeAt(tip)
+	average := (endB - startB) / (tip - from)
+	if pool.TokenA == token.TokenID {
+		average = (endA - startA) / (tip - from)
+	}
+	return max(average, peg)
+}
+
+// collateralEffect is what a collateral transaction does to its position and token
+type collateralEffect struct {
+	token     *TokenInfo
+	position  *CollateralPosition // After the transaction; nil once closed
+	id        string
+	issued    uint64 // Tokens
+	repaid    uint64
+	locked    uint64 // SHADOW
+	released  uint64
+	recipient Address // Receives the released SHADOW
+	event     TxEvent
+}
+
+// blockPositions tracks the positions and issuance earlier in a block
+type blockPositions struct {
+	touched map[string]bool   // Position ID -> changed
+	issued  map[string]uint64 // Token ID -> issued
+}
+
+func newBlockPositions() *blockPositions {
+	return &blockPositions{touched: make(map[string]bool), issued: make(map[string]uint64)}
+}
+
+// collateralEffect works out what a collateral transaction does in the block at height,
+// rejecting it if its signer may not, it breaks the token's terms or, with block set,
+// its position already changed earlier in the block. price values the token; lookup
+// resolves spent outputs.
+func (tr *TokenRegistry) collateralEffect(tx *Transaction, txID string, height uint64, price func(*TokenInfo) uint64,
+	lookup func(txID string, index uint32) *TxOutput, block *blockPositions) (*collateralEffect, error) {
+	signer, err := txSigner(tx)
+	if err != nil {
+		return nil, err
+	}
+	genesisTokenID := GetGenesisToken().TokenID
+
+	existing := func(positionID string) (*CollateralPosition, *TokenInfo, error) {
+		position, exists := tr.GetPosition(positionID)
+		if !exists {
+			return nil, nil, fmt.Errorf("position %s not found", positionID)
+		}
+		if block != nil && block.touched[positionID] {
+			return nil, nil, fmt.Errorf("position %s already changes in the block", positionID)
+		}
+		token, _ := tr.GetToken(position.TokenID)
+		after := *position
+		after.UpdatedAt = height
+		return &after, token, nil
+	}
+	// repaid checks the transaction melts exactly amount of the token and only spends and
+	// returns it and SHADOW
+	repaid := func(token *TokenInfo, amount uint64) error {
+		for _, input := range tx.Inputs {
+			spent := lookup(input.PrevTxID, input.OutputIndex)
+			if spent == nil {
+				return fmt.Errorf("input %s:%d not found", input.PrevTxID, input.OutputIndex)
+			}
+			if spent.TokenID != genesisTokenID && spent.TokenID != token.TokenID {
+				return fmt.Errorf("input %s:%d is neither SHADOW nor %s", input.PrevTxID, input.OutputIndex, token.Ticker)
+			}
+		}
+		for i, output := range tx.Outputs {
+			if output.TokenID != genesisTokenID && output.TokenID != token.TokenID {
+				return fmt.Errorf("output %d is neither SHADOW nor %s", i, token.Ticker)
+			}
+		}
+		if melted := tokenSurplus(tx, lookup, token.TokenID); melted != amount {
+			return fmt.Errorf("transaction melts %d %s, must melt %d", melted, token.Ticker, amount)
+		}
+		return nil
+	}
+
+	effect := &collateralEffect{}
+	switch tx.TxType {
+	case TxTypeCollateralIssue:
+		var data CollateralIssueData
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid collateral data: %w", err)
+		}
+		var position *CollateralPosition
+		var token *TokenInfo
+		if data.PositionID == "" {
+			token, _ = tr.GetToken(data.TokenID)
+			if token == nil || token.Collateral == nil {
+				return nil, fmt.Errorf("token %s is not a collateralized token", data.TokenID)
+			}
+			position = &CollateralPosition{ID: txID, TokenID: token.TokenID, Owner: signer, OpenedAt: height, UpdatedAt: height}
+		} else {
+			if position, token, err = existing(data.PositionID); err != nil {
+				return nil, err
+			}
+			if position.TokenID != data.TokenID {
+				return nil, fmt.Errorf("position %s holds %s, not %s", position.ID, position.TokenID, data.TokenID)
+			}
+			if position.Owner != signer {
+				return nil, fmt.Errorf("only the position's owner can add to it")
+			}
+		}
+		if unissued := token.Unissued - min(token.Unissued, block.issuedOf(token.TokenID)); data.Issue > unissued {
+			return nil, fmt.Errorf("issue of %d exceeds the %d %s left under the cap", data.Issue, unissued, token.Ticker)
+		}
+
+		// SHADOW inputs cover the collateral over the change; the only token output
+		// carries the issued amount
+		var shadowIn, change, issued uint64
+		for _, input := range tx.Inputs {
+			spent := lookup(input.PrevTxID, input.OutputIndex)
+			if spent == nil {
+				return nil, fmt.Errorf("input %s:%d not found", input.PrevTxID, input.OutputIndex)
+			}
+			if spent.TokenID != genesisTokenID {
+				return nil, fmt.Errorf("input %s:%d is not SHADOW", input.PrevTxID, input.OutputIndex)
+			}
+			shadowIn += spent.Amount
+		}
+		for i, output := range tx.Outputs {
+			switch output.TokenID {
+			case genesisTokenID:
+				change += output.Amount
+			case token.TokenID:
+				issued += output.Amount
+			default:
+				return nil, fmt.Errorf("output %d is neither SHADOW nor %s", i, token.Ticker)
+			}
+		}
+		if issued != data.Issue {
+			return nil, fmt.Errorf("outputs carry %d %s, issue is %d", issued, token.Ticker, data.Issue)
+		}
+		if shadowIn < change || shadowIn-change < data.Collateral {
+			return nil, fmt.Errorf("collateral of %d SHADOW but inputs cover %d over the change",
+				data.Collateral, shadowIn-min(shadowIn, change))
+		}
+
+		position.Collateral += data.Collateral
+		position.Debt += data.Issue
+		if data.Issue > 0 {
+			if ratio := position.CollateralRatio(price(token)); ratio < token.Collateral.Ratio {
+				return nil, fmt.Errorf("position would hold %d%% collateral, %s needs %d%%", ratio, token.Ticker, token.Collateral.Ratio)
+			}
+		}
+		effect.token, effect.position, effect.id = token, position, position.ID
+		effect.issued, effect.locked = data.Issue, data.Collateral
+		effect.event = TxEvent{Type: TxEventCollateralIssued, Address: signer.Display(), TokenID: token.TokenID,
+			PositionID: position.ID, Amount: data.Issue, AmountIn: data.Collateral}
+
+	case TxTypeCollateralMelt:
+		var data CollateralMeltData
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid collateral data: %w", err)
+		}
+		position, token, err := existing(data.PositionID)
+		if err != nil {
+			return nil, err
+		}
+		if position.Owner != signer {
+			return nil, fmt.Errorf("only the position's owner can melt against it")
+		}
+		if data.Repay > position.Debt || data.Withdraw > position.Collateral {
+			return nil, fmt.Errorf("position owes %d and holds %d SHADOW, can't repay %d and withdraw %d",
+				position.Debt, position.Collateral, data.Repay, data.Withdraw)
+		}
+		if err := repaid(token, data.Repay); err != nil {
+			return nil, err
+		}
+
+		position.Debt -= data.Repay
+		position.Collateral -= data.Withdraw
+		if data.Withdraw > 0 && position.Debt > 0 {
+			if ratio := position.CollateralRatio(price(token)); ratio < token.Collateral.Ratio {
+				return nil, fmt.Errorf("position would hold %d%% collateral, %s needs %d%%", ratio, token.Ticker, token.Collateral.Ratio)
+			}
+		}
+		effect.token, effect.position, effect.id = token, position, position.ID
+		if position.Debt == 0 && position.Collateral == 0 {
+			effect.position = nil
+		}
+		effect.repaid, effect.released, effect.recipient = data.Repay, data.Withdraw, position.Owner
+		effect.event = TxEvent{Type: TxEventCollateralMelted, Address: signer.Display(), TokenID: token.TokenID,
+			PositionID: position.ID, Amount: data.Repay, AmountOut: data.Withdraw}
+
+	case TxTypeLiquidate:
+		var data LiquidationData
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return nil, fmt.Errorf("invalid liquidation data: %w", err)
+		}
+		position, token, err := existing(data.PositionID)
+		if err != nil {
+			return nil, err
+		}
+		if ratio := position.CollateralRatio(price(token)); ratio >= token.Collateral.LiquidationRatio {
+			return nil, fmt.Errorf("position holds %d%% collateral, liquidation is below %d%%", ratio, token.Collateral.LiquidationRatio)
+		}
+		if err := repaid(token, position.Debt); err != nil {
+			return nil, err
+		}
+		effect.token, effect.id = token, position.ID
+		effect.repaid, effect.released, effect.recipient = position.Debt, position.Collateral, signer
+		effect.event = TxEvent{Type: TxEventPositionLiquidated, Address: signer.Display(), TokenID: token.TokenID,
+			PositionID: position.ID, Amount: position.Debt, AmountOut: position.Collateral}
+
+	default:
+		return nil, fmt.Errorf("not a collateral transaction")
+	}
+
+	effect.event.Height = height
+	if block != nil {
+		block.touched[effect.id] = true
+		block.issued[effect.token.TokenID] += effect.issued
+	}
+	return effect, nil
+}
+
+// issuedOf returns the tokens issued earlier in the block; none without a block
+func (bp *blockPositions) issuedOf(tokenID string) uint64 {
+	if bp == nil {
+		return 0
+	}
+	return bp.issued[tokenID]
+}
+
+// releaseOutput returns the SHADOW output the node pays a collateral transaction, or nil
+func (e *collateralEffect) releaseOutput() *TxOutput {
+	if e.released == 0 {
+		return nil
+	}
+	return CreateShadowOutput(e.recipient, e.released)
+}
+
+// applyCollateral records a collateral transaction's effect on its token and position
+func (tr *TokenRegistry) applyCollateral(effect *collateralEffect) error {
+	token := effect.token
+	token.Unissued = token.Unissued - effect.issued + effect.repaid
+	token.LockedShadow = token.LockedShadow + effect.locked - effect.released
+	if effect.position != nil {
+		tr.Positions[effect.id] = effect.position
+	} else {
+		delete(tr.Positions, effect.id)
+	}
+	return tr.persist(token.TokenID)
+}
+
+// applyCollateral applies a collateral transaction in the block at height: the position
+// and token change and any released SHADOW is paid out after the transaction's outputs.
+// Must run before the transaction's inputs are spent.
+func (store *UTXOStore) applyCollateral(tx *Transaction, txID string, tokenRegistry *TokenRegistry, poolRegistry *PoolRegistry, height uint64) ([]TxEvent, error) {
+	price := func(token *TokenInfo) uint64 { return CollateralPrice(store, poolRegistry, token, height) }
+	effect, err := tokenRegistry.collateralEffect(tx, txID, height, price, utxoLookup(store), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := tokenRegistry.applyCollateral(effect); err != nil {
+		return nil, err
+	}
+	if output := effect.releaseOutput(); output != nil {
+		if err := store.AddUTXO(&UTXO{TxID: txID, OutputIndex: uint32(len(tx.Outputs)), Output: output, BlockHeight: height}); err != nil {
+			return nil, fmt.Errorf("failed to create collateral release UTXO: %w", err)
+		}
+	}
+	fmt.Printf("[Collateral] %s position %s: issued %d, repaid %d, locked %d, released %d\n",
+		tx.TxType, effect.id[:min(16, len(effect.id))], effect.issued, effect.repaid, effect.locked, effect.released)
+	return []TxEvent{effect.event}, nil
+}
+
+// ValidateCollateral rejects a block with a collateral transaction its signer may not
+// make, that breaks its token's terms at the block's price, or that changes a position
+// changed earlier in the block
+func (bc *Blockchain) ValidateCollateral(block *Block, mempool *Mempool) error {
+	positions := newBlockPositions()
+	price := func(token *TokenInfo) uint64 {
+		return CollateralPrice(bc.utxoStore, bc.poolRegistry, token, block.Index)
+	}
+	return bc.checkBlockSpends(block, mempool, func(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+		if !IsCollateralTx(tx.TxType) {
+			return nil
+		}
+		txID, _ := tx.ID()
+		_, err := bc.tokenRegistry.collateralEffect(tx, txID, height, price, lookup, positions)
+		return err
+	})
+}
+
+// checkCollateral rejects a collateral transaction that can't be applied in the next
+// block, resolving inputs from the chain and pending transactions
+func (mp *Mempool) checkCollateral(tx *Transaction) error {
+	if !IsCollateralTx(tx.TxType) {
+		return nil
+	}
+	mp.txLock.RLock()
+	height, utxoStore, tokenRegistry, poolRegistry := mp.currentHeight+1, mp.utxoStore, mp.tokenRegistry, mp.poolRegistry
+	mp.txLock.RUnlock()
+
+	if utxoStore == nil || tokenRegistry == nil {
+		return nil
+	}
+	price := func(token *TokenInfo) uint64 { return CollateralPrice(utxoStore, poolRegistry, token, height) }
+	txID, _ := tx.ID()
+	_, err := tokenRegistry.collateralEffect(tx, txID, height, price, mp.pendingLookup(utxoStore), nil)
+	return err
+}
+
+// filterCollateral keeps the first transaction changing each position and drops
+// issuance past a token's cap, so a proposer doesn't build a block ValidateCollateral
+// rejects. Each transaction was already checked alone against the chain.
+func filterCollateral(candidates []*Transaction, registry *TokenRegistry) []*Transaction {
+	positions := newBlockPositions()
+	kept := make([]*Transaction, 0, len(candidates))
+	for _, tx := range candidates {
+		if IsCollateralTx(tx.TxType) && !positions.claim(tx, registry) {
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	return kept
+}
+
+// claim records the position a transaction changes and the tokens it issues, reporting
+// false if an earlier transaction changes the position or the issue passes the cap
+func (bp *blockPositions) claim(tx *Transaction, registry *TokenRegistry) bool {
+	txID, _ := tx.ID()
+	positionID, tokenID, issue := txID, "", uint64(0)
+	switch tx.TxType {
+	case TxTypeCollateralIssue:
+		var data CollateralIssueData
+		if json.Unmarshal(tx.Data, &data) != nil {
+			return false
+		}
+		tokenID, issue = data.TokenID, data.Issue
+		if data.PositionID != "" {
+			positionID = data.PositionID
+		}
+	case TxTypeCollateralMelt:
+		var data CollateralMeltData
+		if json.Unmarshal(tx.Data, &data) != nil {
+			return false
+		}
+		positionID = data.PositionID
+	case TxTypeLiquidate:
+		var data LiquidationData
+		if json.Unmarshal(tx.Data, &data) != nil {
+			return false
+		}
+		positionID = data.PositionID
+	}
+	if bp.touched[positionID] {
+		return false
+	}
+	if issue > 0 {
+		token, exists := registry.GetToken(tokenID)
+		if !exists || bp.issued[tokenID]+issue > token.Unissued {
+			return false
+		}
+		bp.issued[tokenID] += issue
+	}
+	bp.touched[positionID] = true
+	return true
+}
+
+// checkCollateralMintTerms checks the mint of a collateralized token has usable terms
+// and creates no tokens: its supply is issued against positions
+func checkCollateralMintTerms(tx *Transaction, mintData TokenMintData) error {
+	if err := mintData.Collateral.Validate(); err != nil {
+		return err
+	}
+	if mintData.InitialSupply != 0 {
+		return fmt.Errorf("a collateralized token has no initial supply")
+	}
+	genesisTokenID := GetGenesisToken().TokenID
+	for _, output := range tx.Outputs {
+		if output.TokenID != genesisTokenID {
+			return fmt.Errorf("collateralized token mint can only return SHADOW change")
+		}
+	}
+	return nil
+}
+
+// CreateCollateralTokenTransaction builds an unsigned mint registering a collateralized
+// token with no supply; it is issued against positions. SHADOW inputs pay the fee.
+func CreateCollateralTokenTransaction(registry *TokenRegistry, creator Address, shadowUTXOs []*UTXO,
+	ticker, desc string, maxMint uint64, maxDecimals uint8, terms CollateralTerms) (*Transaction, error) {
+	if err := terms.Validate(); err != nil {
+		return nil, err
+	}
+	if len(ticker) < 3 || len(ticker) > 32 {
+		return nil, fmt.Errorf("ticker must be 3-32 characters")
+	}
+	if len(desc) > 64 {
+		return nil, fmt.Errorf("desc must be 0-64 characters")
+	}
+	if maxMint == 0 || maxMint > 21_000_000 {
+		return nil, fmt.Errorf("max_mint must be 1 to 21,000,000")
+	}
+	if maxDecimals > 8 {
+		return nil, fmt.Errorf("max_decimals cannot exceed 8")
+	}
+	mintVersion, err := registry.NextMintVersion(ticker)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(TokenMintData{
+		Ticker:      ticker,
+		Desc:        desc,
+		MaxMint:     maxMint,
+		MaxDecimals: maxDecimals,
+		MintVersion: mintVersion,
+		Collateral:  &terms,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mint data: %w", err)
+	}
+
+	builder := NewTxBuilder(TxTypeMintToken)
+	fee := CalculateTxFee(TxTypeMintToken, 0, 1, 0)
+	have := addShadowInputs(builder, shadowUTXOs, fee)
+	if have < fee {
+		return nil, fmt.Errorf("insufficient SHADOW for the fee: have %d, need %d", have, fee)
+	}
+	if change := have - fee; change > 0 {
+		builder.AddCustomOutput(CreateShadowOutput(creator, change))
+	}
+	builder.SetData(data)
+	return builder.Build(), nil
+}
+
+// CreateCollateralIssueTransaction builds an unsigned collateral_issue locking collateral
+// SHADOW from shadowUTXOs and issuing issue tokens to owner, who must sign it. An empty
+// positionID opens a position.
+func CreateCollateralIssueTransaction(token *TokenInfo, owner Address, shadowUTXOs []*UTXO, positionID string, collateral, issue uint64) (*Transaction, error) {
+	if token.Collateral == nil {
+		return nil, fmt.Errorf("%s is not a collateralized token", token.Ticker)
+	}
+	if collateral == 0 && issue == 0 {
+		return nil, fmt.Errorf("nothing to lock or issue")
+	}
+	data, err := json.Marshal(CollateralIssueData{TokenID: token.TokenID, PositionID: positionID, Collateral: collateral, Issue: issue})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal collateral data: %w", err)
+	}
+
+	builder := NewTxBuilder(TxTypeCollateralIssue)
+	fee := CalculateTxFee(TxTypeCollateralIssue, 0, 2, 0)
+	have := addShadowInputs(builder, shadowUTXOs, collateral+fee)
+	if have < collateral+fee {
+		return nil, fmt.Errorf("insufficient SHADOW: have %d, need %d (collateral %d + fee %d)", have, collateral+fee, collateral, fee)
+	}
+	if issue > 0 {
+		builder.AddCustomOutput(&TxOutput{
+			Amount:       issue,
+			Address:      owner,
+			TokenID:      token.TokenID,
+			TokenType:    "custom",
+			MintVersion:  token.MintVersion,
+			ScriptPubKey: CreateP2PKHScript(owner),
+		})
+	}
+	if change := have - collateral - fee; change > 0 {
+		builder.AddCustomOutput(CreateShadowOutput(owner, change))
+	}
+	builder.SetData(data)
+	return builder.Build(), nil
+}
+
+// CreateCollateralMeltTransaction builds an unsigned collateral_melt of position melting
+// repay tokens and withdrawing withdraw SHADOW, which the node pays to the owner. utxos
+// hold the tokens and the SHADOW fee; change goes back to changeAddress, token change
+// stamped with the token's mint version in registry.
+func CreateCollateralMeltTransaction(registry *TokenRegistry, position *CollateralPosition, utxos []*UTXO, repay, withdraw uint64, changeAddress Address) (*Transaction, error) {
+	if repay == 0 && withdraw == 0 {
+		return nil, fmt.Errorf("nothing to repay or withdraw")
+	}
+	data, err := json.Marshal(CollateralMeltData{PositionID: position.ID, Repay: repay, Withdraw: withdraw})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal collateral data: %w", err)
+	}
+	return buildRepayment(registry, TxTypeCollateralMelt, position.TokenID, utxos, repay, changeAddress, data)
+}
+
+// CreateLiquidationTransaction builds an unsigned liquidate of position, repaying its
+// whole debt from utxos; the node pays its collateral to the signer. utxos also pay the
+// SHADOW fee; change goes back to changeAddress as for CreateCollateralMeltTransaction.
+func CreateLiquidationTransaction(registry *TokenRegistry, position *CollateralPosition, utxos []*UTXO, changeAddress Address) (*Transaction, error) {
+	data, err := json.Marshal(LiquidationData{PositionID: position.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal liquidation data: %w", err)
+	}
+	return buildRepayment(registry, TxTypeLiquidate, position.TokenID, utxos, position.Debt, changeAddress, data)
+}
+
+// buildRepayment builds a transaction melting repay of tokenID and paying its fee in SHADOW
+func buildRepayment(registry *TokenRegistry, txType TxType, tokenID string, utxos []*UTXO, repay uint64, changeAddress Address, data []byte) (*Transaction, error) {
+	genesisTokenID := GetGenesisToken().TokenID
+	fee := CalculateTxFee(txType, 0, 2, 0)
+	need := map[string]uint64{tokenID: repay, genesisTokenID: fee}
+
+	builder := NewTxBuilder(txType).SetRegistry(registry)
+	have := make(map[string]uint64)
+	for _, utxo := range utxos {
+		id := utxo.Output.TokenID
+		if utxo.IsSpent || utxo.Output.Vesting != nil || utxo.Output.Condition != "" || (id != tokenID && id != genesisTokenID) || have[id] >= need[id] {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		have[id] += utxo.Output.Amount
+	}
+	for id, n := range need {
+		if have[id] < n {
+			return nil, fmt.Errorf("insufficient balance of %s: have %d, need %d", id, have[id], n)
+		}
+	}
+	if change := have[tokenID] - repay; change > 0 {
+		builder.AddOutput(changeAddress, change, tokenID)
+	}
+	if change := have[genesisTokenID] - fee; change > 0 {
+		builder.AddCustomOutput(CreateShadowOutput(changeAddress, change))
+	}
+	builder.SetData(data)
+	return builder.Build(), nil
+}
+
+// addShadowInputs adds plain SHADOW outputs from utxos to builder until they cover need,
+// returning what they hold
+func addShadowInputs(builder *TxBuilder, utxos []*UTXO, need uint64) uint64 {
+	genesisTokenID := GetGenesisToken().TokenID
+	var have uint64
+	for _, utxo := range utxos {
+		if have >= need {
+			break
+		}
+		if utxo.IsSpent || utxo.Output.TokenID != genesisTokenID || utxo.Output.Vesting != nil || utxo.Output.Condition != "" {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		have += utxo.Output.Amount
+	}
+	return have
+}
+
+// validateCollateralTransaction validates the structure of collateral_issue,
+// collateral_melt and liquidate transactions: signed, with inputs and parseable data
+func validateCollateralTransaction(tx *Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("%s transaction must have inputs", tx.TxType)
+	}
+	if len(tx.Signature) == 0 {
+		return fmt.Errorf("%s transaction must be signed", tx.TxType)
+	}
+	switch tx.TxType {
+	case TxTypeCollateralIssue:
+		var data CollateralIssueData
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return fmt.Errorf("invalid collateral data: %w", err)
+		}
+		if data.TokenID == "" {
+			return fmt.Errorf("collateral issue must name a token")
+		}
+		if data.Collateral == 0 && data.Issue == 0 {
+			return fmt.Errorf("collateral issue must lock or issue something")
+		}
+		if data.PositionID == "" && data.Collateral == 0 {
+			return fmt.Errorf("a new position must lock collateral")
+		}
+	case TxTypeCollateralMelt:
+		var data CollateralMeltData
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return fmt.Errorf("invalid collateral data: %w", err)
+		}
+		if data.PositionID == "" || (data.Repay == 0 && data.Withdraw == 0) {
+			return fmt.Errorf("collateral melt must name a position and repay or withdraw something")
+		}
+	case TxTypeLiquidate:
+		var data LiquidationData
+		if err := json.Unmarshal(tx.Data, &data); err != nil || data.PositionID == "" {
+			return fmt.Errorf("liquidation must name a position")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCollateralPositions(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store, registry := bc.GetUTXOStore(), bc.tokenRegistry
+
+	wallet := func(funding string) (*NodeWallet, []*UTXO) {
+		kp, _ := GenerateKeyPair()
+		utxo := &UTXO{TxID: funding, Output: CreateShadowOutput(kp.Address(), 1_000_000)}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+		return &NodeWallet{KeyPair: kp, Address: kp.Address()}, []*UTXO{utxo}
+	}
+	owner, ownerUTXOs := wallet("owner-funding")
+	keeper, keeperUTXOs := wallet("keeper-funding")
+	sign := func(w *NodeWallet, tx *Transaction) string {
+		if err := w.SignTransaction(tx); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		txID, _ := tx.ID()
+		return txID
+	}
+	apply := func(w *NodeWallet, tx *Transaction, height int64) error {
+		sign(w, tx)
+		return store.ProcessTokenTransaction(tx, registry, nil, height)
+	}
+
+	// A token pegged at 1 SHADOW per unit, issued at 150% and liquidated below 120%
+	terms := CollateralTerms{Peg: PriceScale, Ratio: 150, LiquidationRatio: 120}
+	if bad := (CollateralTerms{Peg: PriceScale, Ratio: 150, LiquidationRatio: 150}); bad.Validate() == nil {
+		t.Error("Expected a liquidation ratio at the collateral ratio to be refused")
+	}
+	create, err := CreateCollateralTokenTransaction(registry, owner.Address, ownerUTXOs, "PEGGED", "Collateralized", 100, 0, terms)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	tokenID := sign(owner, create)
+	if err := store.ProcessTokenTransaction(create, registry, nil, 1); err != nil {
+		t.Fatalf("Failed to apply token: %v", err)
+	}
+	token, _ := registry.GetToken(tokenID)
+	if token.Issued() != 0 || token.Unissued != 100 || token.LockedShadow != 0 {
+		t.Fatalf("Expected nothing issued, got %d issued and %d locked", token.Issued(), token.LockedShadow)
+	}
+
+	// Issuing needs 150% collateral and stays under the cap
+	thin, _ := CreateCollateralIssueTransaction(token, owner.Address, ownerUTXOs, "", 140, 100)
+	if err := apply(owner, thin, 2); err == nil {
+		t.Fatal("Expected an undercollateralized issue to be rejected")
+	}
+	open, _ := CreateCollateralIssueTransaction(token, owner.Address, ownerUTXOs, "", 150, 100)
+	positionID := sign(owner, open)
+	if fee := paidFee(open, utxoLookup(store)); fee != CalculateTxFee(TxTypeCollateralIssue, 0, 2, 0) {
+		t.Error("Expected the locked collateral not to count as fee")
+	}
+	if err := store.ProcessTokenTransaction(open, registry, nil, 2); err != nil {
+		t.Fatalf("Failed to open position: %v", err)
+	}
+	position, exists := registry.GetPosition(positionID)
+	if !exists || position.Collateral != 150 || position.Debt != 100 || token.Unissued != 0 || token.LockedShadow != 150 {
+		t.Fatalf("Expected a position of 150 against 100, got %+v", position)
+	}
+	if token.Validate() != nil {
+		t.Errorf("Token invalid after issue: %v", token.Validate())
+	}
+	over, _ := CreateCollateralIssueTransaction(token, owner.Address, ownerUTXOs, positionID, 100, 1)
+	if err := apply(owner, over, 3); err == nil {
+		t.Error("Expected an issue over the cap to be rejected")
+	}
+
+	// The owner repays and withdraws while keeping 150%
+	tokenUTXO := &UTXO{TxID: positionID, OutputIndex: 0, Output: open.Outputs[0]}
+	if err := store.AddUTXO(tokenUTXO); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	holdings := append([]*UTXO{tokenUTXO}, ownerUTXOs...)
+	greedy, _ := CreateCollateralMeltTransaction(registry, position, holdings, 40, 70, owner.Address)
+	if err := apply(owner, greedy, 4); err == nil {
+		t.Fatal("Expected a withdrawal below 150% to be rejected")
+	}
+	melt, _ := CreateCollateralMeltTransaction(registry, position, holdings, 40, 60, owner.Address)
+	if err := apply(keeper, melt, 4); err == nil {
+		t.Fatal("Expected a melt signed by another address to be rejected")
+	}
+	melt, _ = CreateCollateralMeltTransaction(registry, position, holdings, 40, 60, owner.Address)
+	meltID := sign(owner, melt)
+	if err := store.ProcessTokenTransaction(melt, registry, nil, 4); err != nil {
+		t.Fatalf("Failed to melt: %v", err)
+	}
+	if position, _ = registry.GetPosition(positionID); position.Collateral != 90 || position.Debt != 60 {
+		t.Fatalf("Expected 90 against 60, got %+v", position)
+	}
+	if released, _ := store.GetUTXO(meltID, uint32(len(melt.Outputs))); released == nil || released.Output.Amount != 60 {
+		t.Errorf("Expected 60 SHADOW released to the owner, got %+v", released)
+	}
+	if token.Unissued != 40 || token.LockedShadow != 90 || token.Validate() != nil {
+		t.Errorf("Expected 60 issued against 90, got %d against %d", token.Issued(), token.LockedShadow)
+	}
+
+	// Once the price rises far enough anyone may repay the debt and take the collateral
+	keeperTokens := &UTXO{TxID: "keeper-tokens", Output: &TxOutput{Amount: 60, Address: keeper.Address, TokenID: tokenID,
+		TokenType: "custom", ScriptPubKey: CreateP2PKHScript(keeper.Address)}}
+	if err := store.AddUTXO(keeperTokens); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	keeperHoldings := append([]*UTXO{keeperTokens}, keeperUTXOs...)
+	early, _ := CreateLiquidationTransaction(registry, position, keeperHoldings, keeper.Address)
+	if err := apply(keeper, early, 5); err == nil {
+		t.Fatal("Expected a liquidation at 150% to be rejected")
+	}
+	token.Collateral.Peg = 2 * PriceScale // Now 75%
+	liquidate, _ := CreateLiquidationTransaction(registry, position, keeperHoldings, keeper.Address)
+	liquidateID := sign(keeper, liquidate)
+	if err := store.ProcessTokenTransaction(liquidate, registry, nil, 5); err != nil {
+		t.Fatalf("Failed to liquidate: %v", err)
+	}
+	if _, exists := registry.GetPosition(positionID); exists {
+		t.Error("Expected the liquidated position closed")
+	}
+	if released, _ := store.GetUTXO(liquidateID, uint32(len(liquidate.Outputs))); released == nil || released.Output.Address != keeper.Address || released.Output.Amount != 90 {
+		t.Errorf("Expected 90 SHADOW paid to the liquidator, got %+v", released)
+	}
+	if token.Unissued != 100 || token.LockedShadow != 0 {
+		t.Errorf("Expected all supply back unissued, got %d unissued and %d locked", token.Unissued, token.LockedShadow)
+	}
+}
@@ -3,11 +3,13 @@ package lib
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -15,16 +17,37 @@ import (
 const (
 	ConsensusTopic   = "shadowy-consensus"
 	ProofTopic       = "shadowy-proofs" // New topic for proof competition
-	BlockInterval    = 60 * time.Second // Propose new block every 60 seconds
-	ProofWindow      = 50 * time.Second // Time window to collect proofs before block proposal
-	MinVoteThreshold = 0.5              // Need >50% of nodes to vote yes
+	BlockInterval    = 60 * time.Second // Default time between blocks (see ChainGenesis)
+	ProofWindow      = 50 * time.Second // Default time to collect proofs before block proposal, scaled to the interval
+	MinVoteThreshold = 0.5              // Default: need >50% of votes to be yes (see ChainGenesis)
+	QuorumThreshold  = 0.5              // Default: need >50% of nodes to vote (see ChainGenesis)
 
-	// Block reward parameters (Bitcoin-style economics)
+	// ConsensusEngineGossip is the proposal/vote/commit engine in this file. It is the only
+	// consensus runtime: blocks, rewards, balances and the mempool all go through one
+	// Blockchain and UTXOStore whichever way a node is started.
+	ConsensusEngineGossip = "gossip"
+
+	// Default block reward parameters (Bitcoin-style economics, see ChainGenesis)
 	InitialBlockReward = 5_000_000_000 // 50 SHADOW initial reward
 	HalvingInterval    = 210_000       // Halve reward every 210,000 blocks
 	MaxSupply          = 21_000_000    // 21 million SHADOW total (before decimals)
 )
 
+// ValidateConsensusEngine checks the consensus_engine setting. The CometBFT ABCI runtime
+// has been retired: it kept its own UTXO store and paid rewards by its own rules, so a node
+// could end up with balances depending on which runtime had run.
+func ValidateConsensusEngine(engine string) error {
+	switch engine {
+	case "", ConsensusEngineGossip:
+		return nil
+	case "cometbft", "tendermint", "abci":
+		return fmt.Errorf("consensus_engine %q is no longer supported: the CometBFT runtime was removed, use %q",
+			engine, ConsensusEngineGossip)
+	default:
+		return fmt.Errorf("unknown consensus_engine %q (supported: %q)", engine, ConsensusEngineGossip)
+	}
+}
+
 // ConsensusMessage types
 type ConsensusMessageType string
 
@@ -50,7 +73,15 @@ type ConsensusMessage struct {
 	Vote            *BlockVote           `json:"vote,omitempty"`
 	Block           *Block               `json:"block,omitempty"`
 	ProofSubmission *ProofSubmission     `json:"proof_submission,omitempty"`
+	ChainID         string               `json:"chain_id"` // Messages from other networks are dropped
 	Timestamp       int64                `json:"timestamp"`
+
+	// Authentication (see consensus_auth.go)
+	Height    uint64 `json:"height"`               // Block height the payload is for
+	Round     uint64 `json:"round,omitempty"`      // Proposals: the proposer's attempt at this height
+	Sender    string `json:"sender,omitempty"`     // Node ID (libp2p peer ID) of the signer
+	PublicKey []byte `json:"public_key,omitempty"` // Sender's libp2p public key
+	Signature []byte `json:"signature,omitempty"`
 }
 
 // ConsensusEngine manages blockchain consensus
@@ -64,29 +95,101 @@ type ConsensusEngine struct {
 	proofSub      *pubsub.Subscription // Subscription to proof topic
 	host          host.Host
 	nodeID        string
-	rewardAddress Address // Address to receive block rewards
+	rewardAddress Address      // Address to receive block rewards
+	rewardLock    sync.RWMutex // Guards rewardAddress and poolClient, which can change at runtime
 	ctx           context.Context
 	cancel        context.CancelFunc
-	wallet        *NodeWallet // Wallet for signing proofs
+	wallet        *NodeWallet  // Wallet for signing proofs
+	plots         *PlotManager // Plots farmed with, nil = not farming
+	poolClient    *PoolClient  // Farm for a pool instead of solo, nil = solo
+
+	// Hot standby pairing and the standby announcements of other nodes (see failover.go)
+	failover      *FailoverCoordinator
+	failoverTopic *pubsub.Topic
+	failoverSub   *pubsub.Subscription
+
+	transport consensusTransport // Carries consensus messages (gossipsub, or simulated)
+	now       func() time.Time   // Clock for block and message timestamps
+
+	// Message authentication
+	key           crypto.PrivKey        // Node identity key consensus messages are signed with, nil = unsigned
+	unsignedUntil time.Time             // Unsigned messages from peers are accepted until then
+	replays       *consensusReplayGuard // Recent messages and proposal rounds, to drop replays
 
 	// Consensus state
 	isLeader        bool
+	leader          peer.ID // Current leader, possibly us; empty until the first election
 	leaderLock      sync.RWMutex
 	pendingProposal *Block
 	proposalVotes   map[string]bool // voter -> vote
+	proposalHeight  uint64          // Height of our latest proposal
+	proposalRound   uint64          // Times we proposed at proposalHeight, less one
 	voteLock        sync.RWMutex
 
 	// Proof competition state
 	bestProofForHeight map[uint64]*ProofSubmission // Track best proof per height
 	proofLock          sync.RWMutex
+
+	// Committed blocks from the network that conflict with our chain, by hash
+	forkBlocks map[string]*ForkBlock
+	forkLock   sync.Mutex
+}
+
+// consensusTransport carries consensus messages between engines
+type consensusTransport interface {
+	Publish(data []byte) error
+	PeerCount() int // Engines reachable, excluding ourselves
 }
 
-// NewConsensusEngine creates a new consensus engine
-func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pubsub.PubSub, wallet *NodeWallet, rewardAddr Address) (*ConsensusEngine, error) {
+// gossipTransport publishes on the consensus gossipsub topic
+type gossipTransport struct {
+	ctx   context.Context
+	topic *pubsub.Topic
+	host  host.Host
+}
+
+func (g *gossipTransport) Publish(data []byte) error {
+	return g.topic.Publish(g.ctx, data)
+}
+
+func (g *gossipTransport) PeerCount() int {
+	return len(g.host.Network().Peers())
+}
+
+// ParseRewardAddress parses a reward address, which must be a standard wallet address
+func ParseRewardAddress(addrStr string) (Address, error) {
+	addr, addrType, err := ParseAddress(addrStr)
+	if err != nil {
+		return addr, fmt.Errorf("invalid reward address: %w", err)
+	}
+	if addrType != AddressTypeWallet {
+		return addr, fmt.Errorf("reward address must be a wallet (%c) address, got %c", AddressTypeWallet, addrType)
+	}
+	return addr, nil
+}
+
+// RewardAddress returns the address proofs from this node claim rewards for
+func (ce *ConsensusEngine) RewardAddress() Address {
+	ce.rewardLock.RLock()
+	defer ce.rewardLock.RUnlock()
+	return ce.rewardAddress
+}
+
+// SetRewardAddress changes where this node's future block rewards are paid
+func (ce *ConsensusEngine) SetRewardAddress(addr Address) {
+	ce.rewardLock.Lock()
+	defer ce.rewardLock.Unlock()
+	ce.rewardAddress = addr
+	fmt.Printf("[Consensus] 💰 Rewards will be paid to %s\n", addr.String())
+}
+
+// NewConsensusEngine creates a new consensus engine. failoverRole pairs the node with a
+// standby or primary sharing its wallet (see failover.go); empty if it runs alone.
+func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pubsub.PubSub, wallet *NodeWallet, plots *PlotManager, rewardAddr Address, failoverRole string) (*ConsensusEngine, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Join consensus topic
-	topic, err := ps.Join(ConsensusTopic)
+	topic, err := ps.Join(ActiveGenesis().Topic(ConsensusTopic))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to join consensus topic: %w", err)
@@ -100,7 +203,7 @@ func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pu
 	}
 
 	// Join proof competition topic
-	proofTopic, err := ps.Join(ProofTopic)
+	proofTopic, err := ps.Join(ActiveGenesis().Topic(ProofTopic))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to join proof topic: %w", err)
@@ -113,6 +216,23 @@ func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pu
 		return nil, fmt.Errorf("failed to subscribe to proofs: %w", err)
 	}
 
+	// Join the standby heartbeat topic, which every node reads to leave standbys out of
+	// leader election
+	failoverTopic, err := ps.Join(ActiveGenesis().Topic(FailoverTopic))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to join failover topic: %w", err)
+	}
+	failoverSub, err := failoverTopic.Subscribe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to subscribe to failover: %w", err)
+	}
+	if failoverRole != "" && wallet == nil {
+		cancel()
+		return nil, fmt.Errorf("failover pairing needs the shared wallet")
+	}
+
 	ce := &ConsensusEngine{
 		chain:              chain,
 		mempool:            mempool,
@@ -125,12 +245,27 @@ func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pu
 		host:               h,
 		nodeID:             h.ID().String(),
 		wallet:             wallet,
+		plots:              plots,
 		ctx:                ctx,
 		cancel:             cancel,
 		isLeader:           false,
 		proposalVotes:      make(map[string]bool),
 		bestProofForHeight: make(map[uint64]*ProofSubmission),
+		forkBlocks:         make(map[string]*ForkBlock),
+		transport:          &gossipTransport{ctx: ctx, topic: topic, host: h},
+		now:                time.Now,
+		key:                h.Peerstore().PrivKey(h.ID()),
+		unsignedUntil:      ActiveGenesis().UnsignedConsensusDeadline(),
+		replays:            newConsensusReplayGuard(chain.GetUTXOStore()),
+		failoverTopic:      failoverTopic,
+		failoverSub:        failoverSub,
+	}
+	var signer Signer
+	if wallet != nil {
+		signer = wallet.GetSigner()
 	}
+	ce.failover = NewFailoverCoordinator(failoverRole, ce.nodeID, signer, chain.GetHeight,
+		func(data []byte) error { return failoverTopic.Publish(ctx, data) }, time.Now)
 
 	// Start listening for consensus messages
 	go ce.listenForMessages()
@@ -147,6 +282,13 @@ func NewConsensusEngine(chain *Blockchain, mempool *Mempool, h host.Host, ps *pu
 	// Start block proposal loop (if leader)
 	go ce.blockProposalLoop()
 
+	// Track standbys, and hold the lease if paired
+	go ce.listenForFailover()
+	if failoverRole != "" {
+		go ce.failoverLoop()
+		fmt.Printf("[Consensus] Running as failover %s: proposing only while holding the lease\n", failoverRole)
+	}
+
 	fmt.Printf("[Consensus] Started consensus engine, node ID: %s\n", ce.nodeID[:16])
 	fmt.Printf("[Consensus] Waiting 5 seconds for gossipsub mesh to form...\n")
 
@@ -174,9 +316,13 @@ func (ce *ConsensusEngine) leaderElection() {
 			// Include ourselves
 			allPeers := append([]peer.ID{ce.host.ID()}, peers...)
 
-			// Find lowest peer ID (simple deterministic leader)
+			// Find lowest peer ID (simple deterministic leader), passing over standbys
+			// that would never propose
 			var leader peer.ID
 			for _, p := range allPeers {
+				if (p == ce.host.ID() && !ce.failover.Active()) || ce.failover.IsStandby(p.String()) {
+					continue
+				}
 				if leader == "" || p.String() < leader.String() {
 					leader = p
 				}
@@ -185,6 +331,7 @@ func (ce *ConsensusEngine) leaderElection() {
 			wasLeader := ce.IsLeader()
 			ce.leaderLock.Lock()
 			ce.isLeader = (leader == ce.host.ID())
+			ce.leader = leader
 			ce.leaderLock.Unlock()
 
 			if ce.isLeader && !wasLeader {
@@ -203,9 +350,17 @@ func (ce *ConsensusEngine) IsLeader() bool {
 	return ce.isLeader
 }
 
+// Leader returns the peer currently elected leader, or "" before the first election
+func (ce *ConsensusEngine) Leader() peer.ID {
+	ce.leaderLock.RLock()
+	defer ce.leaderLock.RUnlock()
+	return ce.leader
+}
+
 // blockProposalLoop proposes new blocks periodically (if leader)
 func (ce *ConsensusEngine) blockProposalLoop() {
-	ticker := time.NewTicker(BlockInterval)
+	params := ActiveGenesis().ConsensusRules()
+	ticker := time.NewTicker(params.BlockInterval)
 	defer ticker.Stop()
 
 	for {
@@ -213,13 +368,32 @@ func (ce *ConsensusEngine) blockProposalLoop() {
 		case <-ce.ctx.Done():
 			return
 		case <-ticker.C:
-			if ce.IsLeader() {
+			if ce.IsLeader() && ce.waitForProofWindow(params.ProofWindow) && ce.failover.Active() {
 				ce.proposeBlock()
 			}
 		}
 	}
 }
 
+// waitForProofWindow waits until farmers have had the proof window since the last block
+// to submit proofs; false if the engine stopped meanwhile
+func (ce *ConsensusEngine) waitForProofWindow(window time.Duration) bool {
+	latest := ce.chain.GetLatestBlock()
+	if latest == nil {
+		return true
+	}
+	wait := time.Unix(latest.Timestamp, 0).Add(window).Sub(ce.now())
+	if wait <= 0 {
+		return true
+	}
+	select {
+	case <-ce.ctx.Done():
+		return false
+	case <-time.After(wait):
+		return true
+	}
+}
+
 // proposeBlock creates and proposes a new block
 func (ce *ConsensusEngine) proposeBlock() {
 	currentHeight := ce.chain.GetHeight() + 1
@@ -234,90 +408,146 @@ func (ce *ConsensusEngine) proposeBlock() {
 	fmt.Printf("[Consensus] 🏆 Using winning proof with distance %d from %s\n",
 		bestProof.Proof.Distance, bestProof.SubmitterID[:16])
 
-	// Get transactions from mempool
-	txs := ce.mempool.GetTransactions()
-	txIDs := []string{}
-	totalFees := uint64(0)
-
-	fmt.Printf("[Consensus] Mempool has %d transactions to include\n", len(txs))
-
-	// Calculate total fees from transactions
-	for _, tx := range txs {
-		txID, err := tx.ID()
-		if err != nil {
-			continue
-		}
-		txIDs = append(txIDs, txID)
-
-		// Calculate fee: inputs - outputs
-		var inputTotal, outputTotal uint64
-		for _, input := range tx.Inputs {
-			// Get the UTXO being spent
-			utxo, err := ce.chain.GetUTXOStore().GetUTXO(input.PrevTxID, input.OutputIndex)
-			if err == nil && utxo != nil {
-				inputTotal += utxo.Output.Amount
-			}
-		}
-		for _, output := range tx.Outputs {
-			outputTotal += output.Amount
-		}
-		if inputTotal > outputTotal {
-			totalFees += (inputTotal - outputTotal)
-		}
-	}
-
-	// Limit to first 100 transactions
-	if len(txIDs) > 100 {
-		txIDs = txIDs[:100]
-	}
-
-	// Create coinbase transaction - reward goes to proof WINNER not proposer!
-	// Calculate block reward with halving (Bitcoin-style)
-	blockHeight := ce.chain.GetHeight()
-	blockReward := calculateBlockReward(blockHeight)
-
-	coinbaseTx := NewTxBuilder(TxTypeCoinbase)
-	coinbaseTx.SetTimestamp(time.Now().Unix())
-	coinbaseTx.AddOutput(bestProof.RewardAddress, blockReward+totalFees, "SHADOW")
-	coinbase := coinbaseTx.Build()
-
-	coinbaseID, _ := coinbase.ID()
-	txIDs = append([]string{coinbaseID}, txIDs...) // Prepend coinbase
-
-	// Create block proposal (includes coinbase + winning proof)
-	block := ce.chain.ProposeBlock(txIDs, ce.nodeID, coinbase)
+	block, blockBytes := ce.assembleBlock(bestProof.RewardAddress)
 	block.WinningProof = bestProof.Proof
 	block.WinnerAddress = &bestProof.RewardAddress
 
-	// Store as pending proposal
+	// Store as pending proposal; proposing again at a height starts a new round
 	ce.voteLock.Lock()
 	ce.pendingProposal = block
 	ce.proposalVotes = make(map[string]bool)
 	// Vote for our own proposal
 	ce.proposalVotes[ce.nodeID] = true
+	if ce.proposalHeight == block.Index {
+		ce.proposalRound++
+	} else {
+		ce.proposalHeight, ce.proposalRound = block.Index, 0
+	}
+	round := ce.proposalRound
 	ce.voteLock.Unlock()
 
 	// Gossip proposal
 	proposal := &BlockProposal{
 		Block:     block,
 		Proposer:  ce.nodeID,
-		Timestamp: time.Now().Unix(),
+		Timestamp: ce.now().Unix(),
 	}
 
 	msg := ConsensusMessage{
 		Type:      MsgTypeBlockProposal,
 		Proposal:  proposal,
-		Timestamp: time.Now().Unix(),
+		Round:     round,
+		Timestamp: ce.now().Unix(),
 	}
 
 	ce.publishMessage(msg)
 
-	fmt.Printf("[Consensus] Proposed block %d with %d transactions\n", block.Index, len(txIDs))
+	fmt.Printf("[Consensus] Proposed block %d with %d transactions (%d bytes)\n", block.Index, len(block.Transactions), blockBytes)
+}
+
+// assembleBlock builds the next block from the mempool, paying the coinbase to
+// rewardAddress. Returns the block and its estimated size in bytes.
+func (ce *ConsensusEngine) assembleBlock(rewardAddress Address) (*Block, int) {
+	// Get transactions from mempool
+	txs := ce.mempool.GetTransactions()
+	candidates := []*Transaction{}
+
+	fmt.Printf("[Consensus] Mempool has %d transactions to include\n", len(txs))
+
+	nextHeight := ce.chain.GetHeight()
+	pending := ce.mempool.pendingLookup(ce.chain.GetUTXOStore())
+	pendingTx := make(map[string]*Transaction, len(txs))
+	for _, tx := range txs {
+		if txID, err := tx.ID(); err == nil {
+			pendingTx[txID] = tx
+		}
+	}
+	pendingOffer := func(txID string) *Transaction { return pendingTx[txID] }
+	for _, tx := range txs {
+		if tx.ExpiredAt(nextHeight) {
+			continue // Past its TTL, the mempool drops it on the next height update
+		}
+		if tx.TokenFee != nil && (!ce.mempool.AcceptsTokenFees() || ce.mempool.tokenFeeValue(tx) == 0) {
+			continue // Token fee not accepted here or has no pool price; leave it for another proposer
+		}
+		if ce.chain.closesSettledOffer(tx) {
+			continue // The offer's tokens were already paid out by a settlement
+		}
+		txID, _ := tx.ID()
+		if _, _, err := simulateTokenEffects(tx, txID, ce.chain.GetUTXOStore(), ce.chain.tokenRegistry, ce.chain.poolRegistry, nextHeight, pendingOffer); err != nil {
+			continue // Would fail against the current state and invalidate the block
+		}
+		if err := CheckWeightFee(tx, pending, ce.chain.poolRegistry); err != nil {
+			continue // Its token fee no longer covers the network minimum at the pool's price
+		}
+		candidates = append(candidates, tx)
+	}
+	candidates = filterTickerConflicts(candidates, ce.chain.tokenRegistry)
+	candidates = filterMintLimits(candidates, ce.chain.tokenRegistry, nextHeight)
+	candidates = filterCollateral(candidates, ce.chain.tokenRegistry)
+	candidates = filterMissingReferences(candidates, ce.chain.GetUTXOStore())
+
+	// Settle crossing auto-match offers, except those any candidate accepts or cancels
+	blockHeight := ce.chain.GetHeight()
+	settlements, err := ce.chain.MatchOrders(blockHeight, referencedOffers(candidates))
+	if err != nil {
+		fmt.Printf("[Consensus] ⚠️  Order matching failed: %v\n", err)
+		settlements = nil
+	}
+	settlementIDs := make([]string, 0, len(settlements))
+	for _, settlement := range settlements {
+		settlementID, _ := settlement.ID()
+		settlementIDs = append(settlementIDs, settlementID)
+	}
+
+	// Pack transaction packages, best fee per weight first and parents before children,
+	// into the space and weight the coinbase and settlements leave, skipping any that don't fit
+	limits := ActiveGenesis().BlockSizeLimits()
+	timestamp := ce.chain.NextBlockTime(ce.now())
+	largestCoinbase := newCoinbaseAt(rewardAddress, ^uint64(0), timestamp)
+	blockBytes, blockWeight := TxSize(largestCoinbase), TxWeight(largestCoinbase)
+	for _, settlement := range settlements {
+		blockBytes += TxSize(settlement)
+		blockWeight += TxWeight(settlement)
+	}
+	utxoStore := ce.chain.GetUTXOStore()
+	template := selectBlockTransactions(candidates, func(txID string, index uint32) *TxOutput {
+		if utxo, err := utxoStore.GetUTXO(txID, index); err == nil && utxo != nil && !utxo.IsSpent {
+			return utxo.Output
+		}
+		return nil
+	}, ce.mempool.Packages(), limits.MaxBlockBytes-blockBytes, limits.MaxBlockWeight-blockWeight)
+	blockBytes += template.Bytes
+	txIDs := template.TxIDs
+
+	// Create coinbase transaction - reward and SHADOW fees go to proof WINNER not proposer!
+	coinbase := BuildCoinbase(blockHeight, template.Fees, rewardAddress, timestamp)
+
+	coinbaseID, _ := coinbase.ID()
+	txIDs = append(append([]string{coinbaseID}, settlementIDs...), txIDs...) // Prepend coinbase and settlements
+
+	// Create block proposal (includes coinbase; the proposer attaches the winning proof)
+	block := ce.chain.proposeBlockAt(txIDs, ce.nodeID, coinbase, timestamp)
+	block.Settlements = settlements
+	return block, blockBytes
+}
+
+// newCoinbase creates a coinbase paying amount to the proof winner
+func newCoinbase(rewardAddress Address, amount uint64) *Transaction {
+	return newCoinbaseAt(rewardAddress, amount, time.Now().Unix())
+}
+
+// newCoinbaseAt creates a coinbase paying amount to the proof winner, stamped timestamp
+func newCoinbaseAt(rewardAddress Address, amount uint64, timestamp int64) *Transaction {
+	coinbaseTx := NewTxBuilder(TxTypeCoinbase)
+	coinbaseTx.SetTimestamp(timestamp)
+	coinbaseTx.AddOutput(rewardAddress, amount, "SHADOW")
+	return coinbaseTx.Build()
 }
 
 // listenForMessages processes incoming consensus messages
 func (ce *ConsensusEngine) listenForMessages() {
-	fmt.Printf("[Consensus] 👂 Listening for messages on topic: %s\n", ConsensusTopic)
+	fmt.Printf("[Consensus] 👂 Listening for messages on topic: %s\n", ActiveGenesis().Topic(ConsensusTopic))
 
 	for {
 		msg, err := ce.sub.Next(ce.ctx)
@@ -339,15 +569,28 @@ func (ce *ConsensusEngine) listenForMessages() {
 			continue
 		}
 
-		var consensusMsg ConsensusMessage
-		if err := json.Unmarshal(msg.Data, &consensusMsg); err != nil {
-			fmt.Printf("[Consensus] Failed to decode message: %v\n", err)
-			continue
-		}
+		ce.receiveMessage(msg.Data)
+	}
+}
 
-		fmt.Printf("[Consensus] Message type: %s\n", consensusMsg.Type)
-		ce.handleMessage(&consensusMsg)
+// receiveMessage decodes and handles a consensus message from another engine
+func (ce *ConsensusEngine) receiveMessage(data []byte) {
+	var consensusMsg ConsensusMessage
+	if err := json.Unmarshal(data, &consensusMsg); err != nil {
+		fmt.Printf("[Consensus] Failed to decode message: %v\n", err)
+		return
 	}
+	if !ActiveGenesis().AcceptsChainID(consensusMsg.ChainID) {
+		fmt.Printf("[Consensus] ⚠️  Dropping message for chain %q\n", consensusMsg.ChainID)
+		return
+	}
+	if err := ce.authenticateMessage(&consensusMsg); err != nil {
+		fmt.Printf("[Consensus] ⚠️  Dropping message: %v\n", err)
+		return
+	}
+
+	fmt.Printf("[Consensus] Message type: %s\n", consensusMsg.Type)
+	ce.handleMessage(&consensusMsg)
 }
 
 // handleMessage processes a consensus message
@@ -358,7 +601,12 @@ func (ce *ConsensusEngine) handleMessage(msg *ConsensusMessage) {
 	case MsgTypeBlockVote:
 		ce.handleBlockVote(msg.Vote)
 	case MsgTypeBlockCommit:
-		ce.handleBlockCommit(msg.Block)
+		// Only validators vote, so only their commits are taken on trust
+		if validators := ActiveGenesis().ValidatorSet(); validators != nil && validators.Stake(msg.Sender) == 0 {
+			fmt.Printf("[Consensus] Ignoring commit from %.16s: not a registered validator\n", msg.Sender)
+			return
+		}
+		ce.handleBlockCommit(msg.Block, msg.Sender)
 	}
 }
 
@@ -373,7 +621,70 @@ func (ce *ConsensusEngine) handleBlockProposal(proposal *BlockProposal) {
 
 	// Validate block
 	if err := ce.chain.ValidateBlock(block); err != nil {
-		fmt.Printf("[Consensus] Invalid block proposal: %v\n", err)
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateBlockTime(block, ce.now()); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateBlockWeight(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateBlockDust(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateBlockTransactions(block, ce.mempool); errors.Is(err, ErrTxNotFound) {
+		fmt.Printf("[Consensus] Not voting on block proposal %d: %v\n", block.Index, err)
+		return
+	} else if err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateTxOrder(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateTransactionExpiry(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateVestingSpends(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateTokenVersions(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateTokenTickers(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateTokenMints(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateCoinbase(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateInputSignatures(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateSpendConditions(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateHTLCSpends(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
+		return
+	}
+	if err := ce.chain.ValidateCollateral(block, ce.mempool); err != nil {
+		ce.rejectProposal(proposal, err)
 		return
 	}
 
@@ -383,10 +694,21 @@ func (ce *ConsensusEngine) handleBlockProposal(proposal *BlockProposal) {
 	ce.proposalVotes = make(map[string]bool)
 	ce.voteLock.Unlock()
 
+	// A standby leaves voting to the node holding the lease
+	if !ce.failover.Active() {
+		return
+	}
+
 	// Vote yes
 	ce.voteOnBlock(block, true)
 }
 
+// rejectProposal logs an invalid block proposal and records it as evidence
+func (ce *ConsensusEngine) rejectProposal(proposal *BlockProposal, err error) {
+	fmt.Printf("[Consensus] Invalid block proposal: %v\n", err)
+	ce.chain.Evidence().RecordBlock(EvidenceSourceProposal, proposal.Proposer, proposal.Block, err)
+}
+
 // voteOnBlock casts a vote on a block
 func (ce *ConsensusEngine) voteOnBlock(block *Block, approve bool) {
 	vote := &BlockVote{
@@ -394,7 +716,7 @@ func (ce *ConsensusEngine) voteOnBlock(block *Block, approve bool) {
 		BlockIndex: block.Index,
 		Voter:      ce.nodeID,
 		Vote:       approve,
-		Timestamp:  time.Now().Unix(),
+		Timestamp:  ce.now().Unix(),
 	}
 
 	// Record our own vote
@@ -406,7 +728,7 @@ func (ce *ConsensusEngine) voteOnBlock(block *Block, approve bool) {
 	msg := ConsensusMessage{
 		Type:      MsgTypeBlockVote,
 		Vote:      vote,
-		Timestamp: time.Now().Unix(),
+		Timestamp: ce.now().Unix(),
 	}
 
 	ce.publishMessage(msg)
@@ -422,14 +744,36 @@ func (ce *ConsensusEngine) handleBlockVote(vote *BlockVote) {
 	ce.voteLock.Lock()
 	defer ce.voteLock.Unlock()
 
-	// Check if we have a pending proposal matching this vote
+	// Check if we have a pending proposal matching this vote. Votes arriving after the
+	// block was committed are expected; votes for a fork are counted against it.
 	if ce.pendingProposal == nil || ce.pendingProposal.Hash != vote.BlockHash {
+		if ce.chain.GetBlockByHash(vote.BlockHash) == nil {
+			ce.recordForkVote(vote)
+		}
+		return
+	}
+
+	// With a validator set only validators' votes count, weighted by stake
+	validators := ActiveGenesis().ValidatorSet()
+	if validators != nil && validators.Stake(vote.Voter) == 0 {
+		fmt.Printf("[Consensus] Ignoring vote from %.16s: not a registered validator\n", vote.Voter)
 		return
 	}
 
 	// Record vote
 	ce.proposalVotes[vote.Voter] = vote.Vote
 
+	if validators != nil {
+		yesStake, castStake := validators.Tally(ce.proposalVotes)
+		fmt.Printf("[Consensus] Block %d stake: %d yes / %d voted / %d total\n",
+			vote.BlockIndex, yesStake, castStake, validators.TotalStake())
+		if validators.Approved(yesStake) {
+			fmt.Printf("[Consensus] ✓ Block %d approved by two thirds of stake! Committing...\n", ce.pendingProposal.Index)
+			ce.commitBlock(ce.pendingProposal)
+		}
+		return
+	}
+
 	yesVotes := 0
 	totalVotes := len(ce.proposalVotes)
 	for _, v := range ce.proposalVotes {
@@ -441,7 +785,7 @@ func (ce *ConsensusEngine) handleBlockVote(vote *BlockVote) {
 	fmt.Printf("[Consensus] Block %d votes: %d yes / %d total\n", vote.BlockIndex, yesVotes, totalVotes)
 
 	// Check if we have quorum (need majority + need at least 1 peer)
-	peerCount := len(ce.host.Network().Peers()) + 1 // +1 for ourselves
+	peerCount := ce.transport.PeerCount() + 1 // +1 for ourselves
 
 	// Single-node network: auto-commit if we voted yes
 	if peerCount == 1 {
@@ -452,24 +796,25 @@ func (ce *ConsensusEngine) handleBlockVote(vote *BlockVote) {
 		return
 	}
 
-	// Multi-node: require majority
-	requiredVotes := (peerCount / 2) + 1
+	// Multi-node: require the network's quorum and majority
+	params := ActiveGenesis().ConsensusRules()
+	requiredVotes := params.RequiredVotes(peerCount)
 	threshold := float64(yesVotes) / float64(totalVotes)
-	fmt.Printf("[Consensus] Quorum check: totalVotes=%d >= requiredVotes=%d ? %v, threshold=%.2f > 0.5 ? %v\n",
-		totalVotes, requiredVotes, totalVotes >= requiredVotes, threshold, threshold > MinVoteThreshold)
+	fmt.Printf("[Consensus] Quorum check: totalVotes=%d >= requiredVotes=%d ? %v, threshold=%.2f > %.2f ? %v\n",
+		totalVotes, requiredVotes, totalVotes >= requiredVotes, threshold, params.VoteThreshold, threshold > params.VoteThreshold)
 
-	if totalVotes >= requiredVotes && float64(yesVotes)/float64(totalVotes) > MinVoteThreshold {
+	if params.Approved(yesVotes, totalVotes, peerCount) {
 		fmt.Printf("[Consensus] ✓ Block %d approved! Committing...\n", ce.pendingProposal.Index)
 		ce.commitBlock(ce.pendingProposal)
 	}
 }
 
 // commitBlock adds the block to the chain and broadcasts commit
-func (ce *ConsensusEngine) commitBlock(block *Block) {
+func (ce *ConsensusEngine) commitBlock(block *Block) error {
 	// Add to chain
 	if err := ce.chain.AddBlock(block, ce.mempool); err != nil {
 		fmt.Printf("[Consensus] Failed to add block: %v\n", err)
-		return
+		return err
 	}
 
 	// Update mempool with new block height for expiration tracking
@@ -488,26 +833,51 @@ func (ce *ConsensusEngine) commitBlock(block *Block) {
 	msg := ConsensusMessage{
 		Type:      MsgTypeBlockCommit,
 		Block:     block,
-		Timestamp: time.Now().Unix(),
+		Timestamp: ce.now().Unix(),
 	}
 	ce.publishMessage(msg)
+	return nil
+}
+
+// MineBlock builds the next block from the mempool, paying the coinbase to
+// rewardAddress, and commits it without a proof of space or a proposal vote. Peers
+// apply it through the normal commit path. Only for private test networks (see
+// testharness), where no plots are farmed.
+func (ce *ConsensusEngine) MineBlock(rewardAddress Address) (*Block, error) {
+	ce.voteLock.Lock()
+	defer ce.voteLock.Unlock()
+
+	block, _ := ce.assembleBlock(rewardAddress)
+	block.WinnerAddress = &rewardAddress
+	if err := ce.commitBlock(block); err != nil {
+		return nil, err
+	}
+	return block, nil
 }
 
-// handleBlockCommit processes a block commit
-func (ce *ConsensusEngine) handleBlockCommit(block *Block) {
+// handleBlockCommit processes a block commit from sender
+func (ce *ConsensusEngine) handleBlockCommit(block *Block, sender string) {
 	if block == nil {
 		return
 	}
 
 	// Check if we already have this block
-	existing := ce.chain.GetBlock(block.Index)
-	if existing != nil && existing.Hash == block.Hash {
+	if ce.chain.GetBlockByHash(block.Hash) != nil {
+		return
+	}
+
+	// A block conflicting with one we committed can't be added; track it as a fork
+	if ce.isForkBlock(block) {
+		ce.recordFork(block)
 		return
 	}
 
 	// Add to our chain
 	if err := ce.chain.AddBlock(block, ce.mempool); err != nil {
 		fmt.Printf("[Consensus] Failed to add committed block: %v\n", err)
+		if errors.Is(err, ErrBlockInvalid) {
+			ce.chain.Evidence().RecordBlock(EvidenceSourceCommit, sender, block, err)
+		}
 		return
 	}
 
@@ -522,22 +892,27 @@ func (ce *ConsensusEngine) handleBlockCommit(block *Block) {
 	fmt.Printf("[Consensus] Committed block %d from network\n", block.Index)
 }
 
-// publishMessage publishes a consensus message
+// publishMessage signs and publishes a consensus message
 func (ce *ConsensusEngine) publishMessage(msg ConsensusMessage) {
+	if err := ce.signMessage(&msg); err != nil {
+		fmt.Printf("[Consensus] Failed to sign message: %v\n", err)
+		return
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		fmt.Printf("[Consensus] Failed to marshal message: %v\n", err)
 		return
 	}
 
-	if err := ce.topic.Publish(ce.ctx, data); err != nil {
+	if err := ce.transport.Publish(data); err != nil {
 		fmt.Printf("[Consensus] Failed to publish message: %v\n", err)
 	}
 }
 
 // farmingLoop continuously farms for proofs and submits them
 func (ce *ConsensusEngine) farmingLoop() {
-	ticker := time.NewTicker(2 * time.Second) // Check every 2 seconds
+	// Check every 2 seconds, more often when the proof window is short
+	ticker := time.NewTicker(min(2*time.Second, ActiveGenesis().ConsensusRules().ProofWindow/2))
 	defer ticker.Stop()
 
 	// Track last height change for stall detection
@@ -565,66 +940,86 @@ func (ce *ConsensusEngine) farmingLoop() {
 				lastHeightChangeTime = time.Now() // Reset to avoid spam
 			}
 
-			// Check if we already have plots loaded
-			if GetPlotCount() == 0 {
+			// Check if we already have plots loaded (read-only nodes have no wallet to sign with)
+			if ce.plots.Count() == 0 || ce.wallet == nil {
 				// No plots available, skip farming
 				continue
 			}
 
-			// Marshal private key for proof generation
-			privKeyBytes, err := ce.wallet.KeyPair.PrivateKey.MarshalBinary()
+			// Generate proof for this challenge (signed by the wallet's signer)
+			proof, err := ce.plots.GenerateProof(challenge, ce.wallet.GetSigner())
 			if err != nil {
-				fmt.Printf("[Farming] Failed to marshal private key: %v\n", err)
+				fmt.Printf("[Farming] Failed to generate proof: %v\n", err)
 				continue
 			}
 
-			// Generate proof for this challenge
-			proof, err := GenerateProofOfSpace(challenge, privKeyBytes)
-			if err != nil {
-				fmt.Printf("[Farming] Failed to generate proof: %v\n", err)
+			// Pool farmers hand every proof to the pool, which competes with the best one
+			ce.rewardLock.RLock()
+			poolClient := ce.poolClient
+			ce.rewardLock.RUnlock()
+			if poolClient != nil {
+				go poolClient.SubmitPartial(proof)
 				continue
 			}
 
-			// Check if this proof is better than what we've seen
-			ce.proofLock.RLock()
-			bestProof := ce.bestProofForHeight[currentHeight]
-			ce.proofLock.RUnlock()
-
-			isBetter := false
-			if bestProof == nil || proof.Distance < bestProof.Proof.Distance {
-				isBetter = true
+			if ce.SubmitProof(currentHeight, proof) {
+				fmt.Printf("[Farming] 🌾 Found proof with distance %d for height %d\n", proof.Distance, currentHeight)
 			}
+		}
+	}
+}
 
-			if isBetter {
-				fmt.Printf("[Farming] 🌾 Found proof with distance %d for height %d\n", proof.Distance, currentHeight)
+// SetPoolClient makes this node farm for a pool instead of solo
+func (ce *ConsensusEngine) SetPoolClient(pc *PoolClient) {
+	ce.rewardLock.Lock()
+	defer ce.rewardLock.Unlock()
+	ce.poolClient = pc
+}
 
-				// Submit our proof
-				submission := &ProofSubmission{
-					BlockHeight:   currentHeight,
-					Proof:         proof,
-					RewardAddress: ce.rewardAddress,
-					SubmitterID:   ce.nodeID,
-				}
+// PoolClient returns the pool this node farms for, or nil when farming solo
+func (ce *ConsensusEngine) PoolClient() *PoolClient {
+	ce.rewardLock.RLock()
+	defer ce.rewardLock.RUnlock()
+	return ce.poolClient
+}
 
-				// Track locally
-				ce.proofLock.Lock()
-				ce.bestProofForHeight[currentHeight] = submission
-				ce.proofLock.Unlock()
+// SubmitProof competes with a proof for a block at height, claiming the reward for this
+// node's reward address. If it beats the best proof seen, it is tracked locally and
+// gossiped; returns whether it did.
+func (ce *ConsensusEngine) SubmitProof(height uint64, proof *ProofOfSpace) bool {
+	submission := &ProofSubmission{
+		BlockHeight:   height,
+		Proof:         proof,
+		RewardAddress: ce.RewardAddress(),
+		SubmitterID:   ce.nodeID,
+	}
 
-				// Gossip to network
-				msg := ConsensusMessage{
-					Type:            MsgTypeProofSubmission,
-					ProofSubmission: submission,
-					Timestamp:       time.Now().Unix(),
-				}
+	// Track locally if it's better than what we've seen
+	ce.proofLock.Lock()
+	bestProof := ce.bestProofForHeight[height]
+	if bestProof != nil && proof.Distance >= bestProof.Proof.Distance {
+		ce.proofLock.Unlock()
+		return false
+	}
+	ce.bestProofForHeight[height] = submission
+	ce.proofLock.Unlock()
 
-				data, err := json.Marshal(msg)
-				if err == nil {
-					ce.proofTopic.Publish(ce.ctx, data)
-				}
-			}
-		}
+	// Gossip to network
+	msg := ConsensusMessage{
+		Type:            MsgTypeProofSubmission,
+		ProofSubmission: submission,
+		Timestamp:       time.Now().Unix(),
 	}
+	if err := ce.signMessage(&msg); err != nil {
+		fmt.Printf("[Farming] Failed to sign proof submission: %v\n", err)
+		return true
+	}
+
+	data, err := json.Marshal(msg)
+	if err == nil {
+		ce.proofTopic.Publish(ce.ctx, data)
+	}
+	return true
 }
 
 // listenForProofs listens for proof submissions from other nodes
@@ -651,6 +1046,13 @@ func (ce *ConsensusEngine) listenForProofs() {
 			fmt.Printf("[Farming] Failed to decode proof message: %v\n", err)
 			continue
 		}
+		if !ActiveGenesis().AcceptsChainID(consensusMsg.ChainID) {
+			continue
+		}
+		if err := ce.authenticateMessage(&consensusMsg); err != nil {
+			fmt.Printf("[Farming] ⚠️  Dropping proof message: %v\n", err)
+			continue
+		}
 
 		if consensusMsg.Type == MsgTypeProofSubmission {
 			ce.handleProofSubmission(consensusMsg.ProofSubmission)
@@ -701,26 +1103,14 @@ func (ce *ConsensusEngine) Close() error {
 	ce.cancel()
 	ce.sub.Cancel()
 	ce.proofSub.Cancel()
+	ce.failoverSub.Cancel()
 	ce.topic.Close()
+	ce.failoverTopic.Close()
 	return ce.proofTopic.Close()
 }
 
 // calculateBlockReward calculates the block reward with halving (Bitcoin-style)
-// Reward halves every 210,000 blocks until it reaches zero
+// using the active genesis reward schedule
 func calculateBlockReward(blockHeight uint64) uint64 {
-	// Calculate number of halvings that have occurred
-	halvings := blockHeight / HalvingInterval
-
-	// After 64 halvings (or ~13.44M blocks), reward becomes 0
-	if halvings >= 64 {
-		return 0
-	}
-
-	// Calculate reward: initial_reward / (2^halvings)
-	reward := uint64(InitialBlockReward)
-	for i := uint64(0); i < halvings; i++ {
-		reward = reward / 2
-	}
-
-	return reward
+	return ActiveGenesis().BlockReward(blockHeight)
 }
@@ -0,0 +1,353 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Consensus messages are signed with the sending node's libp2p identity key. Its peer ID
+// is the node ID proposals, votes and proof submissions name, so a peer can't propose,
+// vote or claim proofs as another node. Each message states the height it is for and,
+// for proposals, the proposer's round at that height; together with the timestamp they
+// are covered by the signature. Repeats of a message, messages older than
+// ConsensusMessageMaxAge and proposals from a round a proposer has already moved past
+// are dropped, so a captured message can't be replayed.
+//
+// So the built-in network can upgrade one node at a time, unsigned messages are accepted
+// until a fixed deadline (DefaultUnsignedConsensusUntil, or the genesis'
+// unsigned_consensus_until), not for a while after each start, so restarting a node
+// doesn't reopen the window. An unsigned message can't prove who sent it, so one naming a
+// node that has been seen signing is still dropped (the signers are persisted, so this
+// survives a restart), and it never advances a proposer's round. Networks with a
+// validator set weight votes by the signer's stake, so they never accept unsigned messages.
+//
+// Messages for heights more than ConsensusMaxHeightAhead past our tip are dropped: they
+// can't be acted on until we sync, and would otherwise pin a proposer's round far ahead.
+
+const (
+	DefaultUnsignedConsensusUntil = 1796083200       // Dec 1, 2026 00:00:00 UTC: built-in network stops accepting unsigned messages
+	ConsensusMessageMaxAge        = 10 * time.Minute // Messages timestamped earlier are dropped
+	ConsensusMaxHeightAhead       = 16               // Messages for heights further past our tip are dropped
+
+	// ConsensusSignerPrefix persists the nodes seen signing consensus messages
+	ConsensusSignerPrefix = "csigner:" // csigner:{node_id} -> ""
+
+	consensusMessageMaxSkew = 2 * time.Minute // Messages timestamped further ahead are dropped
+	consensusSignDomain     = "shadowy-consensus:"
+)
+
+// consensusReplayGuard remembers recent messages, each proposer's latest round and the
+// nodes seen signing
+type consensusReplayGuard struct {
+	mu       sync.Mutex
+	seen     map[string]int64         // Message digest -> timestamp, pruned past the max age
+	rounds   map[string]proposalRound // Proposer -> latest signed proposal seen
+	signers  map[string]bool          // Node IDs that have sent a signed message
+	unsigned map[string]bool          // Node IDs already logged sending unsigned messages
+	store    *UTXOStore               // Persists signers; nil keeps them in memory only
+}
+
+// proposalRound orders a proposer's proposals: by height, then by round
+type proposalRound struct {
+	height uint64
+	round  uint64
+}
+
+// newConsensusReplayGuard creates a guard, loading the signers persisted in store (nil
+// keeps them in memory only)
+func newConsensusReplayGuard(store *UTXOStore) *consensusReplayGuard {
+	g := &consensusReplayGuard{
+		seen:     make(map[string]int64),
+		rounds:   make(map[string]proposalRound),
+		signers:  make(map[string]bool),
+		unsigned: make(map[string]bool),
+		store:    store,
+	}
+	if store != nil {
+		signers, err := store.ConsensusSigners()
+		if err != nil {
+			fmt.Printf("[Consensus] ⚠️  Failed to load consensus signers: %v\n", err)
+		}
+		for _, nodeID := range signers {
+			g.signers[nodeID] = true
+		}
+	}
+	return g
+}
+
+// ConsensusSigners returns the node IDs seen signing consensus messages
+func (store *UTXOStore) ConsensusSigners() ([]string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(ConsensusSignerPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var signers []string
+	for ; iterator.Valid(); iterator.Next() {
+		signers = append(signers, strings.TrimPrefix(string(iterator.Key()), ConsensusSignerPrefix))
+	}
+	return signers, nil
+}
+
+// addConsensusSigner records that a node signs its consensus messages
+func (store *UTXOStore) addConsensusSigner(nodeID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(ConsensusSignerPrefix+nodeID), []byte("")); err != nil {
+		return fmt.Errorf("failed to store consensus signer: %w", err)
+	}
+	return nil
+}
+
+// UnsignedConsensusDeadline returns when the network stops accepting unsigned consensus
+// messages. The built-in network predates signing and accepts them until
+// DefaultUnsignedConsensusUntil; a custom network only if its genesis sets a time.
+func (g *ChainGenesis) UnsignedConsensusDeadline() time.Time {
+	if g.UnsignedConsensusUntil != 0 {
+		return time.Unix(g.UnsignedConsensusUntil, 0)
+	}
+	if g.IsDefault() {
+		return time.Unix(DefaultUnsignedConsensusUntil, 0)
+	}
+	return time.Time{}
+}
+
+// signingBytes returns what a message's signature covers: the domain and chain ID, then
+// the message without its signature in canonical JSON
+func (msg *ConsensusMessage) signingBytes() ([]byte, error) {
+	unsigned := *msg
+	unsigned.Signature = nil
+	data, err := CanonicalJSON(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode consensus message: %w", err)
+	}
+	return append([]byte(consensusSignDomain+msg.ChainID+":"), data...), nil
+}
+
+// messageHeight returns the block height a message's payload is for
+func (msg *ConsensusMessage) messageHeight() (uint64, bool) {
+	switch msg.Type {
+	case MsgTypeBlockProposal:
+		if msg.Proposal != nil && msg.Proposal.Block != nil {
+			return msg.Proposal.Block.Index, true
+		}
+	case MsgTypeBlockVote:
+		if msg.Vote != nil {
+			return msg.Vote.BlockIndex, true
+		}
+	case MsgTypeBlockCommit:
+		if msg.Block != nil {
+			return msg.Block.Index, true
+		}
+	case MsgTypeProofSubmission:
+		if msg.ProofSubmission != nil {
+			return msg.ProofSubmission.BlockHeight, true
+		}
+	}
+	return 0, false
+}
+
+// messageNodeID returns the node ID a message's payload claims to come from, or "" if it
+// names none (commits are relayed by whichever node committed)
+func (msg *ConsensusMessage) messageNodeID() string {
+	switch msg.Type {
+	case MsgTypeBlockProposal:
+		return msg.Proposal.Proposer
+	case MsgTypeBlockVote:
+		return msg.Vote.Voter
+	case MsgTypeProofSubmission:
+		return msg.ProofSubmission.SubmitterID
+	}
+	return ""
+}
+
+// signMessage stamps a message with the chain, height and sender and signs it with the
+// node key. Without a key the message is sent unsigned.
+func (ce *ConsensusEngine) signMessage(msg *ConsensusMessage) error {
+	msg.ChainID = ActiveGenesis().ChainID
+	msg.Height, _ = msg.messageHeight()
+	if ce.key == nil {
+		return nil
+	}
+
+	publicKey, err := crypto.MarshalPublicKey(ce.key.GetPublic())
+	if err != nil {
+		return fmt.Errorf("failed to encode node public key: %w", err)
+	}
+	msg.Sender, msg.PublicKey = ce.nodeID, publicKey
+	data, err := msg.signingBytes()
+	if err != nil {
+		return err
+	}
+	if msg.Signature, err = ce.key.Sign(data); err != nil {
+		return fmt.Errorf("failed to sign consensus message: %w", err)
+	}
+	return nil
+}
+
+// authenticateMessage checks a received message's signature, that its sender is the node
+// its payload names, and that it isn't stale or a replay
+func (ce *ConsensusEngine) authenticateMessage(msg *ConsensusMessage) error {
+	height, ok := msg.messageHeight()
+	if !ok {
+		return fmt.Errorf("%s message has no payload", msg.Type)
+	}
+	if len(msg.Signature) == 0 && msg.Height == 0 {
+		msg.Height = height // Nodes that predate signing don't state it, and nothing unsigned is covered anyway
+	}
+	if msg.Height != height {
+		return fmt.Errorf("%s message is for height %d but its payload for %d", msg.Type, msg.Height, height)
+	}
+	if ce.chain != nil {
+		if tip := ce.chain.GetHeight(); height > tip+ConsensusMaxHeightAhead {
+			return fmt.Errorf("%s message is for height %d, too far past our tip %d", msg.Type, height, tip)
+		}
+	}
+
+	now := ce.now()
+	stamped := time.Unix(msg.Timestamp, 0)
+	if now.Sub(stamped) > ConsensusMessageMaxAge {
+		return fmt.Errorf("%s message from %s is stale", msg.Type, stamped.Format(time.RFC3339))
+	}
+	if stamped.Sub(now) > consensusMessageMaxSkew {
+		return fmt.Errorf("%s message is timestamped in the future (%s)", msg.Type, stamped.Format(time.RFC3339))
+	}
+
+	if len(msg.Signature) == 0 {
+		if ActiveGenesis().ValidatorSet() != nil {
+			return fmt.Errorf("unsigned %s message on a network with validators", msg.Type)
+		}
+		if !now.Before(ce.unsignedUntil) {
+			return fmt.Errorf("unsigned %s message", msg.Type)
+		}
+		if nodeID := msg.messageNodeID(); nodeID != "" && (nodeID == ce.nodeID || ce.replays.hasSigned(nodeID)) {
+			return fmt.Errorf("unsigned %s message for node %.16s, which signs its messages", msg.Type, nodeID)
+		}
+		ce.replays.logUnsigned(msg.messageNodeID())
+	} else if err := verifyMessageSignature(msg); err != nil {
+		return err
+	}
+	if nodeID := msg.messageNodeID(); nodeID != "" && msg.Sender != "" && nodeID != msg.Sender {
+		return fmt.Errorf("%s message for node %.16s signed by %.16s", msg.Type, nodeID, msg.Sender)
+	}
+
+	data, err := msg.signingBytes()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	if err := ce.replays.check(msg, hex.EncodeToString(digest[:]), now); err != nil {
+		return err
+	}
+	if len(msg.Signature) > 0 {
+		ce.replays.markSigned(msg.Sender)
+	}
+	return nil
+}
+
+// verifyMessageSignature checks a message is signed by the key of the node it names as
+// its sender
+func verifyMessageSignature(msg *ConsensusMessage) error {
+	publicKey, err := crypto.UnmarshalPublicKey(msg.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid consensus message public key: %w", err)
+	}
+	sender, err := peer.Decode(msg.Sender)
+	if err != nil {
+		return fmt.Errorf("invalid consensus message sender: %w", err)
+	}
+	if !sender.MatchesPublicKey(publicKey) {
+		return fmt.Errorf("consensus message key does not belong to %.16s", msg.Sender)
+	}
+	data, err := msg.signingBytes()
+	if err != nil {
+		return err
+	}
+	valid, err := publicKey.Verify(data, msg.Signature)
+	if err != nil || !valid {
+		return fmt.Errorf("invalid consensus message signature from %.16s", msg.Sender)
+	}
+	return nil
+}
+
+// markSigned remembers, and persists, that a node signs its messages
+func (g *consensusReplayGuard) markSigned(nodeID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.signers[nodeID] {
+		return
+	}
+	g.signers[nodeID] = true
+	if g.store != nil {
+		if err := g.store.addConsensusSigner(nodeID); err != nil {
+			fmt.Printf("[Consensus] ⚠️  %v\n", err)
+		}
+	}
+}
+
+// logUnsigned notes, once per node, that unsigned messages naming it are being accepted
+func (g *consensusReplayGuard) logUnsigned(nodeID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.unsigned[nodeID] {
+		return
+	}
+	g.unsigned[nodeID] = true
+	if nodeID == "" {
+		fmt.Printf("[Consensus] ⚠️  Accepting unsigned commits during the upgrade grace period\n")
+	} else {
+		fmt.Printf("[Consensus] ⚠️  Accepting unsigned messages for node %.16s during the upgrade grace period\n", nodeID)
+	}
+}
+
+// hasSigned reports whether a node has been seen signing a message
+func (g *consensusReplayGuard) hasSigned(nodeID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.signers[nodeID]
+}
+
+// check records a message, refusing one seen before and a proposal from a round its
+// proposer has moved past. Only signed proposals advance the proposer's round, so a
+// forged unsigned one can't silence the real proposer.
+func (g *consensusReplayGuard) check(msg *ConsensusMessage, digest string, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cutoff := now.Add(-ConsensusMessageMaxAge).Unix()
+	for key, stamped := range g.seen {
+		if stamped < cutoff {
+			delete(g.seen, key)
+		}
+	}
+	if _, seen := g.seen[digest]; seen {
+		return fmt.Errorf("replayed %s message", msg.Type)
+	}
+
+	if msg.Type == MsgTypeBlockProposal {
+		proposer := msg.Proposal.Proposer
+		latest, known := g.rounds[proposer]
+		current := proposalRound{height: msg.Height, round: msg.Round}
+		if known && (current.height < latest.height || (current.height == latest.height && current.round < latest.round)) {
+			return fmt.Errorf("proposal for height %d round %d from %.16s is older than its round %d at height %d",
+				current.height, current.round, proposer, latest.round, latest.height)
+		}
+		if len(msg.Signature) > 0 {
+			g.rounds[proposer] = current
+		}
+	}
+
+	g.seen[digest] = msg.Timestamp
+	return nil
+}
@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newAuthEngine returns an engine that only signs and authenticates messages
+func newAuthEngine(t *testing.T, now time.Time) *ConsensusEngine {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+	return &ConsensusEngine{nodeID: id.String(), key: key, now: func() time.Time { return now }, replays: newConsensusReplayGuard(nil)}
+}
+
+func TestConsensusMessageAuthentication(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	alice, bob := newAuthEngine(t, now), newAuthEngine(t, now)
+
+	// Encode and decode like gossip does
+	send := func(from *ConsensusEngine, msg ConsensusMessage) *ConsensusMessage {
+		if err := from.signMessage(&msg); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		data, _ := json.Marshal(msg)
+		var received ConsensusMessage
+		json.Unmarshal(data, &received)
+		return &received
+	}
+	vote := func(voter string, index uint64) ConsensusMessage {
+		return ConsensusMessage{Type: MsgTypeBlockVote, Timestamp: now.Unix(),
+			Vote: &BlockVote{BlockHash: "auth-test", BlockIndex: index, Voter: voter, Vote: true}}
+	}
+
+	msg := send(alice, vote(alice.nodeID, 7))
+	if msg.Height != 7 || msg.Sender != alice.nodeID {
+		t.Fatalf("Expected height 7 from alice, got %d from %s", msg.Height, msg.Sender)
+	}
+	if err := bob.authenticateMessage(msg); err != nil {
+		t.Fatalf("Expected alice's vote to authenticate: %v", err)
+	}
+	if err := bob.authenticateMessage(msg); err == nil {
+		t.Fatal("Expected a replayed vote to be dropped")
+	}
+
+	// Alice can't vote as bob, or change what she signed
+	if err := bob.authenticateMessage(send(alice, vote(bob.nodeID, 8))); err == nil {
+		t.Fatal("Expected a vote naming another node to be dropped")
+	}
+	tampered := send(alice, vote(alice.nodeID, 9))
+	tampered.Vote.Vote = false
+	if err := bob.authenticateMessage(tampered); err == nil {
+		t.Fatal("Expected a tampered vote to be dropped")
+	}
+	moved := send(alice, vote(alice.nodeID, 10))
+	moved.Height = 11
+	if err := bob.authenticateMessage(moved); err == nil {
+		t.Fatal("Expected a height not matching the payload to be dropped")
+	}
+
+	// Old messages are dropped even if never seen
+	stale := vote(alice.nodeID, 12)
+	stale.Timestamp = now.Add(-ConsensusMessageMaxAge - time.Minute).Unix()
+	if err := bob.authenticateMessage(send(alice, stale)); err == nil {
+		t.Fatal("Expected a stale vote to be dropped")
+	}
+
+	// A proposal from a round the proposer has moved past is a replay
+	proposal := func(round uint64) ConsensusMessage {
+		block := &Block{Index: 13, Hash: "auth-test-round", Timestamp: now.Unix() + int64(round)}
+		return ConsensusMessage{Type: MsgTypeBlockProposal, Round: round, Timestamp: now.Unix(),
+			Proposal: &BlockProposal{Block: block, Proposer: alice.nodeID, Timestamp: block.Timestamp}}
+	}
+	first, second := send(alice, proposal(0)), send(alice, proposal(1))
+	if err := bob.authenticateMessage(second); err != nil {
+		t.Fatalf("Expected round 1 to authenticate: %v", err)
+	}
+	if err := bob.authenticateMessage(first); err == nil {
+		t.Fatal("Expected a proposal from an earlier round to be dropped")
+	}
+
+	// Commits name no node, so any signed relay of one is accepted
+	commit := ConsensusMessage{Type: MsgTypeBlockCommit, Timestamp: now.Unix(),
+		Block: &Block{Index: 13, Hash: "auth-test-commit", Timestamp: now.Unix()}}
+	if err := bob.authenticateMessage(send(alice, commit)); err != nil {
+		t.Fatalf("Expected a signed commit to authenticate: %v", err)
+	}
+
+	// Unsigned messages are accepted only before the upgrade deadline, and never for a node
+	// that has been seen signing
+	unsigned := vote("legacy-node", 14) // Sent by a node that predates signing: no chain ID or height
+	if err := bob.authenticateMessage(&unsigned); err == nil {
+		t.Fatal("Expected an unsigned vote to be dropped after the deadline")
+	}
+	bob.unsignedUntil = now.Add(time.Hour)
+	if err := bob.authenticateMessage(&unsigned); err != nil {
+		t.Fatalf("Expected an unsigned vote before the deadline: %v", err)
+	}
+	forged := vote(alice.nodeID, 15)
+	forged.ChainID, forged.Height = ActiveGenesis().ChainID, 15
+	if err := bob.authenticateMessage(&forged); err == nil {
+		t.Fatal("Expected an unsigned vote naming a node that signs to be dropped")
+	}
+	forged = vote(bob.nodeID, 15)
+	forged.ChainID, forged.Height = ActiveGenesis().ChainID, 15
+	if err := bob.authenticateMessage(&forged); err == nil {
+		t.Fatal("Expected an unsigned vote naming this node to be dropped")
+	}
+
+	// An unsigned proposal doesn't advance its proposer's round
+	legacy := func(round uint64) *ConsensusMessage {
+		block := &Block{Index: 16, Hash: "auth-test-legacy", Timestamp: now.Unix() + int64(round)}
+		return &ConsensusMessage{Type: MsgTypeBlockProposal, Round: round, Timestamp: now.Unix(),
+			Proposal: &BlockProposal{Block: block, Proposer: "legacy-node", Timestamp: block.Timestamp}}
+	}
+	if err := bob.authenticateMessage(legacy(1 << 40)); err != nil {
+		t.Fatalf("Expected an unsigned proposal before the deadline: %v", err)
+	}
+	if err := bob.authenticateMessage(legacy(0)); err != nil {
+		t.Fatalf("Expected a forged unsigned round not to silence the proposer: %v", err)
+	}
+}
+
+func TestUnsignedConsensusDeadline(t *testing.T) {
+	if got := DefaultChainGenesis().UnsignedConsensusDeadline(); got.Unix() != DefaultUnsignedConsensusUntil {
+		t.Errorf("Expected the built-in network's fixed deadline, got %v", got)
+	}
+	custom := DefaultChainGenesis()
+	custom.ChainID = "deadline-test"
+	if got := custom.UnsignedConsensusDeadline(); !got.IsZero() {
+		t.Errorf("Expected a custom network to never accept unsigned messages, got %v", got)
+	}
+	custom.UnsignedConsensusUntil = 1_800_000_000
+	if got := custom.UnsignedConsensusDeadline(); got.Unix() != 1_800_000_000 {
+		t.Errorf("Expected the genesis deadline, got %v", got)
+	}
+}
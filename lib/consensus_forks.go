@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A committed block that conflicts with our chain (a different block at a height we have
+// already committed, or one building on such a block) is a fork. Votes and commits name
+// blocks by hash, so forks are kept by hash too: repeated gossip of the same fork block is
+// recognised instead of failing AddBlock again, votes for it are counted, and a block
+// extending it is known to belong to it. Forks deeper than ForkTrackingDepth below our
+// tip are forgotten.
+
+// ForkTrackingDepth is how many blocks below the tip fork blocks are remembered
+const ForkTrackingDepth = 100
+
+// ForkBlock is a committed block seen from the network that isn't on our chain
+type ForkBlock struct {
+	Block    *Block `json:"block"`
+	Conflict string `json:"conflicts_with,omitempty"` // Hash of our block at the same height
+	Commits  int    `json:"commits"`                  // Times it was gossiped as committed
+	YesVotes int    `json:"yes_votes"`                // Approving votes seen for it
+	FirstAt  int64  `json:"first_seen"`
+}
+
+// isForkBlock reports whether a committed block we don't have conflicts with our chain
+func (ce *ConsensusEngine) isForkBlock(block *Block) bool {
+	if block.Index < ce.chain.GetHeight() {
+		return true
+	}
+	ce.forkLock.Lock()
+	defer ce.forkLock.Unlock()
+	_, extendsFork := ce.forkBlocks[block.PreviousHash]
+	return extendsFork
+}
+
+// recordFork remembers a fork block, or counts another commit of one already seen
+func (ce *ConsensusEngine) recordFork(block *Block) {
+	var conflict string
+	if ours := ce.chain.GetBlock(block.Index); ours != nil {
+		conflict = ours.Hash
+	}
+	height := ce.chain.GetHeight()
+
+	ce.forkLock.Lock()
+	defer ce.forkLock.Unlock()
+
+	if fork, ok := ce.forkBlocks[block.Hash]; ok {
+		fork.Commits++
+		return
+	}
+	for hash, fork := range ce.forkBlocks {
+		if fork.Block.Index+ForkTrackingDepth < height {
+			delete(ce.forkBlocks, hash)
+		}
+	}
+	ce.forkBlocks[block.Hash] = &ForkBlock{
+		Block:    block,
+		Conflict: conflict,
+		Commits:  1,
+		FirstAt:  ce.now().Unix(),
+	}
+	if conflict != "" {
+		fmt.Printf("[Consensus] ⚠️  Fork: block %d %s... committed by the network conflicts with ours %s...\n",
+			block.Index, block.Hash[:16], conflict[:16])
+	} else {
+		fmt.Printf("[Consensus] ⚠️  Fork: block %d %s... extends fork block %s...\n",
+			block.Index, block.Hash[:16], block.PreviousHash[:16])
+	}
+}
+
+// recordForkVote counts a vote for a fork block; returns false if the hash isn't one
+func (ce *ConsensusEngine) recordForkVote(vote *BlockVote) bool {
+	ce.forkLock.Lock()
+	defer ce.forkLock.Unlock()
+
+	fork, ok := ce.forkBlocks[vote.BlockHash]
+	if !ok {
+		return false
+	}
+	if vote.Vote {
+		fork.YesVotes++
+	}
+	return true
+}
+
+// GetForkBlock returns the fork block with the given hash, or nil
+func (ce *ConsensusEngine) GetForkBlock(hash string) *ForkBlock {
+	ce.forkLock.Lock()
+	defer ce.forkLock.Unlock()
+
+	fork, ok := ce.forkBlocks[hash]
+	if !ok {
+		return nil
+	}
+	copied := *fork
+	return &copied
+}
+
+// ForkBlocks returns the fork blocks being tracked, lowest first
+func (ce *ConsensusEngine) ForkBlocks() []*ForkBlock {
+	ce.forkLock.Lock()
+	defer ce.forkLock.Unlock()
+
+	forks := make([]*ForkBlock, 0, len(ce.forkBlocks))
+	for _, fork := range ce.forkBlocks {
+		copied := *fork
+		forks = append(forks, &copied)
+	}
+	sort.Slice(forks, func(i, j int) bool {
+		if forks[i].Block.Index != forks[j].Block.Index {
+			return forks[i].Block.Index < forks[j].Block.Index
+		}
+		return forks[i].Block.Hash < forks[j].Block.Hash
+	})
+	return forks
+}
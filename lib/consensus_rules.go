@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits on the consensus timing a genesis may choose. Below a second gossip can't keep
+// up; above an hour farmers go unpaid for too long.
+const (
+	MinBlockIntervalSeconds = 1
+	MaxBlockIntervalSeconds = 3600
+)
+
+// ConsensusTiming is the network's proof window and vote thresholds. Zero values use
+// the defaults: a proof window of 5/6 of the block interval and simple majorities.
+type ConsensusTiming struct {
+	ProofWindowSeconds int     `json:"proof_window_seconds"` // Time to collect proofs after a block before proposing the next
+	VoteThreshold      float64 `json:"vote_threshold"`       // Share of votes cast that must be yes, exclusive
+	QuorumThreshold    float64 `json:"quorum_threshold"`     // Share of nodes that must vote, exclusive
+}
+
+// ConsensusRules is the resolved consensus timing of a network
+type ConsensusRules struct {
+	BlockInterval   time.Duration
+	ProofWindow     time.Duration
+	VoteThreshold   float64
+	QuorumThreshold float64
+}
+
+// ConsensusRules returns the network's consensus timing and vote thresholds
+func (g *ChainGenesis) ConsensusRules() ConsensusRules {
+	interval := g.BlockInterval()
+	rules := ConsensusRules{
+		BlockInterval:   interval,
+		ProofWindow:     time.Duration(float64(interval) * float64(ProofWindow) / float64(BlockInterval)),
+		VoteThreshold:   MinVoteThreshold,
+		QuorumThreshold: QuorumThreshold,
+	}
+	if g.Consensus != nil {
+		if g.Consensus.ProofWindowSeconds > 0 {
+			rules.ProofWindow = time.Duration(g.Consensus.ProofWindowSeconds) * time.Second
+		}
+		if g.Consensus.VoteThreshold > 0 {
+			rules.VoteThreshold = g.Consensus.VoteThreshold
+		}
+		if g.Consensus.QuorumThreshold > 0 {
+			rules.QuorumThreshold = g.Consensus.QuorumThreshold
+		}
+	}
+	return rules
+}
+
+// validateConsensusTiming checks the consensus timing is workable
+func (g *ChainGenesis) validateConsensusTiming() error {
+	if g.BlockIntervalSeconds < MinBlockIntervalSeconds || g.BlockIntervalSeconds > MaxBlockIntervalSeconds {
+		return fmt.Errorf("block_interval_seconds must be %d to %d, got %d",
+			MinBlockIntervalSeconds, MaxBlockIntervalSeconds, g.BlockIntervalSeconds)
+	}
+	if g.Consensus == nil {
+		return nil
+	}
+
+	timing := g.Consensus
+	if timing.ProofWindowSeconds < 0 || timing.ProofWindowSeconds >= g.BlockIntervalSeconds {
+		return fmt.Errorf("consensus proof_window_seconds must be shorter than the block interval (%ds), got %d",
+			g.BlockIntervalSeconds, timing.ProofWindowSeconds)
+	}
+	// Below a half, two conflicting blocks could both be approved
+	if timing.VoteThreshold != 0 && (timing.VoteThreshold < 0.5 || timing.VoteThreshold >= 1) {
+		return fmt.Errorf("consensus vote_threshold must be at least 0.5 and below 1, got %g", timing.VoteThreshold)
+	}
+	if timing.QuorumThreshold != 0 && (timing.QuorumThreshold < 0.5 || timing.QuorumThreshold >= 1) {
+		return fmt.Errorf("consensus quorum_threshold must be at least 0.5 and below 1, got %g", timing.QuorumThreshold)
+	}
+	return nil
+}
+
+// RequiredVotes returns how many of peerCount nodes must vote for a block to commit
+func (p ConsensusRules) RequiredVotes(peerCount int) int {
+	return int(float64(peerCount)*p.QuorumThreshold) + 1
+}
+
+// Approved reports whether the votes cast among peerCount nodes commit a block
+func (p ConsensusRules) Approved(yesVotes, totalVotes, peerCount int) bool {
+	return totalVotes >= p.RequiredVotes(peerCount) && float64(yesVotes)/float64(totalVotes) > p.VoteThreshold
+}
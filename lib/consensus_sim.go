@@ -0,0 +1,328 @@
+package lib
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Consensus simulation runs engines' real proposal, vote and commit handlers against a
+// fake clock and a scripted network that drops, delays, duplicates and partitions
+// messages. Every choice is drawn from one seeded random source and events are handled
+// in a fixed order, so a seed reproduces a run exactly, liveness stalls and safety
+// violations included.
+
+// simEpoch is the fake clock's start; block timestamps, and so block hashes, depend on it.
+// It is after the default genesis time, which blocks can't be stamped before.
+var simEpoch = time.Unix(1_710_000_000, 0)
+
+// SimConfig scripts a consensus simulation
+type SimConfig struct {
+	Seed       int64
+	Nodes      int            // Engines in the network
+	Rounds     int            // Block intervals simulated; the leader proposes once per round
+	DropRate   float64        // Chance a message to one peer is lost
+	DupRate    float64        // Chance a delivered message arrives twice
+	MaxDelay   time.Duration  // Deliveries are delayed uniformly up to this
+	Partitions []SimPartition // Scheduled network splits
+	DataDir    string         // Where the engines' chains are stored
+}
+
+// SimPartition splits the network for rounds [From, To). Engines in one group reach
+// each other; engines in no group are isolated.
+type SimPartition struct {
+	From, To int
+	Groups   [][]int
+}
+
+// SimResult is the outcome of a simulation
+type SimResult struct {
+	Seed       int64
+	Chains     [][]string // Block hashes held by each engine, by index
+	Delivered  int        // Messages handed to an engine
+	Dropped    int        // Messages lost
+	Duplicated int        // Messages delivered twice
+	Violations []string   // Safety: engines holding different blocks, or reaching different state, at one index
+}
+
+// Heights returns the number of blocks each engine holds, genesis included
+func (r *SimResult) Heights() []int {
+	heights := make([]int, len(r.Chains))
+	for i, chain := range r.Chains {
+		heights[i] = len(chain)
+	}
+	return heights
+}
+
+// simEvent is a message in flight to one engine
+type simEvent struct {
+	at   time.Time
+	seq  int // Breaks ties between events due at the same time
+	to   int
+	data []byte
+}
+
+// simEventQueue orders events by delivery time, then by send order
+type simEventQueue []*simEvent
+
+func (q simEventQueue) Len() int { return len(q) }
+func (q simEventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q simEventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *simEventQueue) Push(x any)   { *q = append(*q, x.(*simEvent)) }
+func (q *simEventQueue) Pop() any {
+	old := *q
+	event := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return event
+}
+
+// consensusSim is the simulated network and clock shared by the engines
+type consensusSim struct {
+	cfg     SimConfig
+	rng     *rand.Rand
+	now     time.Time
+	round   int
+	seq     int
+	queue   simEventQueue
+	engines []*ConsensusEngine
+	result  *SimResult
+}
+
+// simTransport is one engine's connection to the simulated network
+type simTransport struct {
+	sim  *consensusSim
+	from int
+}
+
+func (t *simTransport) Publish(data []byte) error {
+	t.sim.send(t.from, data)
+	return nil
+}
+
+func (t *simTransport) PeerCount() int {
+	return len(t.sim.reachable(t.from))
+}
+
+// RunConsensusSim runs a simulation and checks that no two engines committed different
+// blocks at the same index
+func RunConsensusSim(cfg SimConfig) (*SimResult, error) {
+	if cfg.Nodes <= 0 {
+		return nil, fmt.Errorf("simulation needs at least one node")
+	}
+	sim := &consensusSim{
+		cfg:    cfg,
+		rng:    rand.New(rand.NewSource(cfg.Seed)),
+		now:    simEpoch,
+		result: &SimResult{Seed: cfg.Seed},
+	}
+	defer sim.close()
+
+	for i := 0; i < cfg.Nodes; i++ {
+		if err := sim.addEngine(i); err != nil {
+			return nil, err
+		}
+	}
+
+	interval := ActiveGenesis().BlockInterval()
+	for round := 0; round < cfg.Rounds; round++ {
+		sim.round = round
+		sim.now = simEpoch.Add(time.Duration(round) * interval)
+		for i := range sim.engines {
+			if sim.isLeader(i) {
+				sim.propose(i)
+			}
+		}
+
+		// Deliver everything due before the next round; replies are queued as they're sent
+		end := sim.now.Add(interval)
+		for sim.queue.Len() > 0 && sim.queue[0].at.Before(end) {
+			event := heap.Pop(&sim.queue).(*simEvent)
+			sim.now = event.at
+			sim.result.Delivered++
+			sim.engines[event.to].receiveMessage(event.data)
+		}
+	}
+
+	sim.checkSafety()
+	return sim.result, nil
+}
+
+// addEngine creates engine i with its own chain and an empty mempool
+func (s *consensusSim) addEngine(i int) error {
+	chain, err := NewBlockchain(filepath.Join(s.cfg.DataDir, fmt.Sprintf("engine%d", i)))
+	if err != nil {
+		return fmt.Errorf("failed to create chain for engine %d: %w", i, err)
+	}
+	// Engines sign like real nodes, with keys derived from the seed so runs replay
+	key, _, err := crypto.GenerateEd25519Key(rand.New(rand.NewSource(s.cfg.Seed + int64(i))))
+	if err != nil {
+		return fmt.Errorf("failed to create key for engine %d: %w", i, err)
+	}
+	nodeID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to derive node ID for engine %d: %w", i, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.engines = append(s.engines, &ConsensusEngine{
+		chain: chain,
+		mempool: &Mempool{
+			entries: make(map[string]*MempoolEntry),
+			relay:   newTxRelay(),
+			ctx:     ctx,
+			cancel:  cancel,
+		},
+		nodeID:             nodeID.String(),
+		rewardAddress:      Address{byte(i + 1)},
+		ctx:                ctx,
+		cancel:             cancel,
+		proposalVotes:      make(map[string]bool),
+		bestProofForHeight: make(map[uint64]*ProofSubmission),
+		forkBlocks:         make(map[string]*ForkBlock),
+		transport:          &simTransport{sim: s, from: i},
+		now:                func() time.Time { return s.now },
+		key:                key,
+		replays:            newConsensusReplayGuard(chain.GetUTXOStore()),
+	})
+	return nil
+}
+
+// close releases the engines' chains
+func (s *consensusSim) close() {
+	for _, ce := range s.engines {
+		ce.cancel()
+		ce.chain.Close()
+	}
+}
+
+// reachable returns the engines node can currently exchange messages with
+func (s *consensusSim) reachable(node int) []int {
+	group := make([]int, 0, len(s.engines))
+	for i := range s.engines {
+		group = append(group, i)
+	}
+	for _, partition := range s.cfg.Partitions {
+		if s.round < partition.From || s.round >= partition.To {
+			continue
+		}
+		group = nil
+		for _, g := range partition.Groups {
+			for _, member := range g {
+				if member == node {
+					group = g
+				}
+			}
+		}
+	}
+
+	var peers []int
+	for _, i := range group {
+		if i != node {
+			peers = append(peers, i)
+		}
+	}
+	return peers
+}
+
+// isLeader applies leaderElection's rule: the lowest node ID among reachable engines
+func (s *consensusSim) isLeader(node int) bool {
+	for _, peer := range s.reachable(node) {
+		if s.engines[peer].nodeID < s.engines[node].nodeID {
+			return false
+		}
+	}
+	return true
+}
+
+// propose hands the leader a winning proof for its next block and lets it propose
+func (s *consensusSim) propose(node int) {
+	ce := s.engines[node]
+	height := ce.chain.GetHeight() + 1
+	ce.proofLock.Lock()
+	ce.bestProofForHeight[height] = &ProofSubmission{
+		BlockHeight:   height,
+		Proof:         &ProofOfSpace{Distance: uint64(s.rng.Int63())},
+		RewardAddress: ce.rewardAddress,
+		SubmitterID:   ce.nodeID,
+	}
+	ce.proofLock.Unlock()
+	ce.proposeBlock()
+}
+
+// send queues a message to every reachable engine, applying the scripted faults
+func (s *consensusSim) send(from int, data []byte) {
+	for _, to := range s.reachable(from) {
+		if s.rng.Float64() < s.cfg.DropRate {
+			s.result.Dropped++
+			continue
+		}
+		copies := 1
+		if s.rng.Float64() < s.cfg.DupRate {
+			copies = 2
+			s.result.Duplicated++
+		}
+		for c := 0; c < copies; c++ {
+			var delay time.Duration
+			if s.cfg.MaxDelay > 0 {
+				delay = time.Duration(s.rng.Int63n(int64(s.cfg.MaxDelay) + 1))
+			}
+			s.seq++
+			heap.Push(&s.queue, &simEvent{at: s.now.Add(delay), seq: s.seq, to: to, data: data})
+		}
+	}
+}
+
+// stateHash returns the state hash engine node recorded after the block at index
+func (s *consensusSim) stateHash(node, index int) string {
+	stateHash, err := s.engines[node].chain.GetUTXOStore().StateHash(uint64(index))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return stateHash
+}
+
+// checkSafety records each engine's chain and any index where two engines disagree on
+// the block, or agree on the block but not on the state hash after it
+func (s *consensusSim) checkSafety() {
+	for _, ce := range s.engines {
+		var hashes []string
+		for _, block := range ce.chain.GetBlocks() {
+			hashes = append(hashes, block.Hash)
+		}
+		s.result.Chains = append(s.result.Chains, hashes)
+	}
+
+	for index := 0; ; index++ {
+		first, found := -1, false
+		for i, chain := range s.result.Chains {
+			if index >= len(chain) {
+				continue
+			}
+			found = true
+			if first < 0 {
+				first = i
+			} else if chain[index] != s.result.Chains[first][index] {
+				s.result.Violations = append(s.result.Violations,
+					fmt.Sprintf("block %d: engine %d has %s, engine %d has %s",
+						index, first, s.result.Chains[first][index][:16], i, chain[index][:16]))
+			} else if want, got := s.stateHash(first, index), s.stateHash(i, index); want != got {
+				s.result.Violations = append(s.result.Violations,
+					fmt.Sprintf("block %d: engine %d state %.16s, engine %d state %.16s",
+						index, first, want, i, got))
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
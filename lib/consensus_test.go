@@ -0,0 +1,35 @@
+package lib
+
+import "testing"
+
+func TestParseRewardAddress(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+
+	addr, err := ParseRewardAddress(kp.Address().String())
+	if err != nil {
+		t.Fatalf("Wallet address should be accepted: %v", err)
+	}
+	if addr != kp.Address() {
+		t.Error("Parsed reward address does not match")
+	}
+
+	if _, err := ParseRewardAddress(kp.Address().StringWithType(AddressTypeLiquidity)); err == nil {
+		t.Error("Liquidity pool address should be rejected")
+	}
+	if _, err := ParseRewardAddress("not-an-address"); err == nil {
+		t.Error("Garbage should be rejected")
+	}
+}
+
+func TestValidateConsensusEngine(t *testing.T) {
+	for _, engine := range []string{"", ConsensusEngineGossip} {
+		if err := ValidateConsensusEngine(engine); err != nil {
+			t.Errorf("Engine %q should be accepted: %v", engine, err)
+		}
+	}
+	for _, engine := range []string{"cometbft", "tendermint", "raft"} {
+		if err := ValidateConsensusEngine(engine); err == nil {
+			t.Errorf("Engine %q should be rejected", engine)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Service support for systemd and container orchestrators: a data dir that holds all of
+// the node's state, a PID file, and liveness (/healthz) separate from readiness
+// (/readyz), which only passes once the chain is within ready_max_lag blocks of peers.
+
+const (
+	DefaultReadyMaxLag = 5                // Blocks the chain may trail the best peer and still be ready
+	peerHeightTTL      = 15 * time.Second // How long /readyz reuses polled peer heights
+)
+
+// dataDir roots the state of commands run from the CLI, set once at startup by
+// SetDataDir. Empty keeps the original layout: chain and node files in the working
+// directory, wallet in ~/.sn. A running node roots its files in its own data dir (see
+// P2PBlockchainNode.dataPath), so nodes embedded in one process don't share one.
+var dataDir string
+
+// SetDataDir makes dir (created if missing) the root for the blockchain, UTXO store,
+// wallet, address book, anchors, pool state and tracked wallet transactions
+func SetDataDir(dir string) error {
+	absDir, err := resolveDataDir(dir)
+	if err != nil {
+		return err
+	}
+	dataDir = absDir
+	return nil
+}
+
+// resolveDataDir returns dir as an absolute path, creating it if missing. Empty stays empty.
+func resolveDataDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid datadir %s: %w", dir, err)
+	}
+	if err := os.MkdirAll(absDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create datadir %s: %w", absDir, err)
+	}
+	return absDir, nil
+}
+
+// DataPath returns where a node file lives: under the data dir if one is set, otherwise
+// relative to the working directory
+func DataPath(name string) string {
+	return dataPathIn(dataDir, name)
+}
+
+// dataPathIn returns where a node file lives under dir, or relative to the working
+// directory if dir is empty
+func dataPathIn(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// WritePIDFile records this process's PID at path. It refuses to overwrite the PID file
+// of another node that is still running; a stale file from a crash is replaced.
+func WritePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("another node is running with PID %d (%s)", pid, path)
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	return nil
+}
+
+// RemovePIDFile deletes the PID file if it still belongs to this process
+func RemovePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
+
+// processAlive reports whether a process with pid exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// Readiness reports whether the node is synced closely enough to serve traffic
+type Readiness struct {
+	Ready          bool   `json:"ready"`
+	Height         uint64 `json:"height"`                     // Local tip
+	BestPeerHeight uint64 `json:"best_peer_height,omitempty"` // Highest tip reported by a peer
+	Lag            uint64 `json:"lag"`                        // Blocks behind the best peer
+	MaxLag         uint64 `json:"max_lag"`
+	Reason         string `json:"reason,omitempty"` // Why the node is not ready
+}
+
+// checkReadiness decides readiness from the local tip and the best peer tip
+func checkReadiness(tip, bestPeer uint64, peerKnown, syncing bool, maxLag uint64) Readiness {
+	r := Readiness{Height: tip, BestPeerHeight: bestPeer, MaxLag: maxLag}
+	if bestPeer > tip {
+		r.Lag = bestPeer - tip
+	}
+	switch {
+	case syncing:
+		r.Reason = "block sync in progress"
+	case !peerKnown:
+		r.Reason = "no peer has reported its height"
+	case r.Lag > maxLag:
+		r.Reason = fmt.Sprintf("%d blocks behind the best peer (max %d)", r.Lag, maxLag)
+	default:
+		r.Ready = true
+	}
+	return r
+}
+
+// peerHeightCache holds the best peer height from the last poll
+type peerHeightCache struct {
+	height   uint64
+	known    bool
+	polledAt time.Time
+}
+
+// BestPeerHeight returns the highest tip reported by connected peers, polling them at
+// most every peerHeightTTL. known is false if no peer answered.
+func (c *BlockSyncClient) BestPeerHeight() (height uint64, known bool) {
+	c.peerHeightsMu.Lock()
+	defer c.peerHeightsMu.Unlock()
+
+	if time.Since(c.peerHeights.polledAt) < peerHeightTTL {
+		return c.peerHeights.height, c.peerHeights.known
+	}
+	c.peerHeights = peerHeightCache{polledAt: time.Now()}
+	for _, p := range c.host.Network().Peers() {
+		if h, err := c.GetPeerHeight(p); err == nil {
+			c.peerHeights.known = true
+			if h > c.peerHeights.height {
+				c.peerHeights.height = h
+			}
+		}
+	}
+	return c.peerHeights.height, c.peerHeights.known
+}
+
+// Readiness checks the local chain against the best peer
+func (n *P2PBlockchainNode) Readiness() Readiness {
+	bestPeer, known := n.Sync.BestPeerHeight()
+	return checkReadiness(n.Chain.GetHeight()-1, bestPeer, known, n.Sync.Status().Syncing, n.readyMaxLag)
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		tip, peer uint64
+		known     bool
+		syncing   bool
+		ready     bool
+	}{
+		{"caught up", 100, 100, true, false, true},
+		{"ahead of peers", 101, 100, true, false, true},
+		{"within lag", 95, 100, true, false, true},
+		{"too far behind", 94, 100, true, false, false},
+		{"syncing", 100, 100, true, true, false},
+		{"no peer heights", 100, 0, false, false, false},
+	}
+	for _, tt := range tests {
+		r := checkReadiness(tt.tip, tt.peer, tt.known, tt.syncing, 5)
+		if r.Ready != tt.ready {
+			t.Errorf("%s: expected ready=%v, got %+v", tt.name, tt.ready, r)
+		}
+		if !r.Ready && r.Reason == "" {
+			t.Errorf("%s: not ready without a reason", tt.name)
+		}
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shadowy.pid")
+
+	if err := WritePIDFile(path); err != nil {
+		t.Fatalf("Failed to write PID file: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("PID file holds %q", data)
+	}
+
+	// A running process's PID file is left alone
+	os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0644)
+	if err := WritePIDFile(path); err == nil {
+		t.Error("Should refuse the PID file of a running process")
+	}
+	RemovePIDFile(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Error("Should not remove another process's PID file")
+	}
+
+	// A stale one is replaced, and removed on shutdown
+	os.WriteFile(path, []byte("999999999"), 0644)
+	if err := WritePIDFile(path); err != nil {
+		t.Fatalf("Should replace a stale PID file: %v", err)
+	}
+	RemovePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("PID file should be removed")
+	}
+}
+
+func TestDataPath(t *testing.T) {
+	defer SetDataDir("")
+
+	if DataPath("blockchain") != "blockchain" {
+		t.Error("Without a data dir, files stay in the working directory")
+	}
+	dir := filepath.Join(t.TempDir(), "node")
+	if err := SetDataDir(dir); err != nil {
+		t.Fatalf("Failed to set data dir: %v", err)
+	}
+	if DataPath("blockchain") != filepath.Join(dir, "blockchain") {
+		t.Errorf("Got %s", DataPath("blockchain"))
+	}
+	if walletPath, _ := DefaultWalletPath(); walletPath != filepath.Join(dir, "wallet", "default.json") {
+		t.Errorf("Wallet should live in the data dir, got %s", walletPath)
+	}
+
+	// A node keeps its own data dir whatever the process-wide one is
+	node := &P2PBlockchainNode{dataDir: filepath.Join(t.TempDir(), "other")}
+	if got := node.dataPath(SnapshotDir); got != filepath.Join(node.dataDir, SnapshotDir) {
+		t.Errorf("Expected the node's snapshots under its own data dir, got %s", got)
+	}
+}
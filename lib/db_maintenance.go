@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+// CompactionRetryDelay is how long to wait before retrying a compaction that found the DB busy
+const CompactionRetryDelay = 30 * time.Second
+
+// StoreHealth combines on-disk stats with cache effectiveness for one store
+type StoreHealth struct {
+	*DBStats
+	CacheHits    uint64  `json:"cache_hits"`
+	CacheMisses  uint64  `json:"cache_misses"`
+	CacheHitRate float64 `json:"cache_hit_rate"`
+}
+
+// newStoreHealth builds a StoreHealth from stats and cache counters
+func newStoreHealth(stats *DBStats, hits, misses uint64) *StoreHealth {
+	health := &StoreHealth{
+		DBStats:     stats,
+		CacheHits:   hits,
+		CacheMisses: misses,
+	}
+	if hits+misses > 0 {
+		health.CacheHitRate = float64(hits) / float64(hits+misses)
+	}
+	return health
+}
+
+// DBHealth returns statistics for the block and UTXO stores
+func (bc *Blockchain) DBHealth() (map[string]*StoreHealth, error) {
+	blockStats, err := bc.store.DBStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block store stats: %w", err)
+	}
+	utxoStats, err := bc.utxoStore.DBStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UTXO store stats: %w", err)
+	}
+
+	blockHits, blockMisses := bc.store.CacheStats()
+	utxoHits, utxoMisses := bc.utxoStore.CacheStats()
+
+	return map[string]*StoreHealth{
+		"blocks": newStoreHealth(blockStats, blockHits, blockMisses),
+		"utxos":  newStoreHealth(utxoStats, utxoHits, utxoMisses),
+	}, nil
+}
+
+// CompactStores compacts the block and UTXO databases
+func (bc *Blockchain) CompactStores() error {
+	before, after, err := bc.store.Compact()
+	if err != nil {
+		return fmt.Errorf("block store: %w", err)
+	}
+	fmt.Printf("[DB] Compacted block store: %d -> %d bytes\n", before, after)
+
+	before, after, err = bc.utxoStore.Compact()
+	if err != nil {
+		return fmt.Errorf("UTXO store: %w", err)
+	}
+	fmt.Printf("[DB] Compacted UTXO store: %d -> %d bytes\n", before, after)
+
+	return nil
+}
+
+// StartCompactionScheduler compacts the stores every interval until the chain is closed
+func (bc *Blockchain) StartCompactionScheduler(interval time.Duration) {
+	if interval <= 0 {
+		fmt.Printf("[DB] Scheduled compaction disabled\n")
+		return
+	}
+
+	fmt.Printf("[DB] Scheduled compaction every %v\n", interval)
+	stop := bc.stopMaintenance
+	bc.maintenance.Add(1)
+	go func() {
+		defer bc.maintenance.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Retry a few times if the DB is busy with long-running iterators
+				for attempt := 0; attempt < 3; attempt++ {
+					err := bc.CompactStores()
+					if err == nil {
+						break
+					}
+					fmt.Printf("[DB] Warning: compaction failed: %v\n", err)
+					select {
+					case <-stop:
+						return
+					case <-time.After(CompactionRetryDelay):
+					}
+				}
+			}
+		}
+	}()
+}
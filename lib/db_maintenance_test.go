@@ -0,0 +1,20 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompactionSchedulerStopsOnClose(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	bc.StartCompactionScheduler(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	// The scheduler reads the stop channel while Close closes it; closing twice is harmless
+	bc.Close()
+	bc.Close()
+}
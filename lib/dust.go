@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Outputs worth less than the fee to spend them are never spent, so they only grow the
+// UTXO set every node keeps. A network can set a dust threshold in its block limits:
+// the mempool refuses transactions creating a SHADOW output below it and validators
+// reject blocks holding one. A node can refuse more as relay policy (dust_threshold).
+// Custom token amounts have no SHADOW value to compare, so only SHADOW outputs are dust.
+
+// ErrDustOutput is returned for a transaction creating an output below a dust threshold
+var ErrDustOutput = errors.New("dust output")
+
+// IsDust reports whether an output is a SHADOW output below threshold
+func IsDust(output *TxOutput, threshold uint64) bool {
+	return output.Amount < threshold && output.TokenID == GetGenesisToken().TokenID
+}
+
+// CheckDust rejects a transaction creating an output below threshold. Node-made
+// transactions are exempt; a coinbase late in the reward schedule may pay less.
+func CheckDust(tx *Transaction, threshold uint64) error {
+	if threshold == 0 || tx.TxType == TxTypeCoinbase || tx.TxType == TxTypeMatchOffers {
+		return nil
+	}
+	for i, output := range tx.Outputs {
+		if IsDust(output, threshold) {
+			return fmt.Errorf("%w: output %d pays %d, threshold is %d", ErrDustOutput, i, output.Amount, threshold)
+		}
+	}
+	return nil
+}
+
+// CheckTxDust rejects a transaction creating an output below the network's dust threshold
+func CheckTxDust(tx *Transaction) error {
+	return CheckDust(tx, ActiveGenesis().BlockSizeLimits().DustThreshold)
+}
+
+// ValidateBlockDust rejects a block holding a transaction that creates dust
+func (bc *Blockchain) ValidateBlockDust(block *Block, mempool *Mempool) error {
+	threshold := ActiveGenesis().BlockSizeLimits().DustThreshold
+	if threshold == 0 {
+		return nil
+	}
+	for _, tx := range bc.blockTxs(block, mempool) {
+		if err := CheckDust(tx, threshold); err != nil {
+			txID, _ := tx.ID()
+			return fmt.Errorf("transaction %s: %w", txID, err)
+		}
+	}
+	return nil
+}
+
+// checkDust rejects a transaction creating an output below the network's threshold or
+// this node's, whichever is higher
+func (mp *Mempool) checkDust(tx *Transaction) error {
+	mp.txLock.RLock()
+	threshold := mp.relayDust
+	mp.txLock.RUnlock()
+
+	if network := ActiveGenesis().BlockSizeLimits().DustThreshold; network > threshold {
+		threshold = network
+	}
+	return CheckDust(tx, threshold)
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDustThreshold(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	for i := uint32(0); i < 2; i++ {
+		if err := store.AddUTXO(&UTXO{TxID: "dust-test-funding", OutputIndex: i, Output: CreateShadowOutput(kp.Address(), 1_000_000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	send := func(index uint32, amounts ...uint64) *Transaction {
+		builder := NewTxBuilder(TxTypeSend).AddInput("dust-test-funding", index)
+		for _, amount := range amounts {
+			builder.AddOutput(kp.Address(), amount, "")
+		}
+		tx := builder.Build()
+		tx.Sign(kp)
+		return tx
+	}
+
+	genesis := DefaultChainGenesis()
+	genesis.BlockLimits = &BlockLimits{DustThreshold: 500}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	dusty := send(0, 499, 900_000)
+	if err := CheckTxDust(dusty); !errors.Is(err, ErrDustOutput) || !strings.Contains(err.Error(), "output 0") {
+		t.Errorf("Expected the 499 output rejected as dust, got %v", err)
+	}
+	if err := CheckTxDust(send(0, 500, 900_000)); err != nil {
+		t.Errorf("Expected an output at the threshold to pass, got %v", err)
+	}
+	token := NewTxBuilder(TxTypeSend).AddCustomOutput(CreateTokenOutput(kp.Address(), 1, "f6e5d4c3b2a1", "custom", nil)).Build()
+	coinbase := NewTxBuilder(TxTypeCoinbase).AddOutput(kp.Address(), 1, "").Build()
+	if CheckTxDust(token) != nil || CheckTxDust(coinbase) != nil {
+		t.Error("Expected token outputs and the coinbase to be exempt")
+	}
+
+	// A node's relay threshold adds to the network's, never lowers it
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	mempool.SetRelayPolicy(RelayPolicy{DustThreshold: 100}, store)
+	if err := mempool.checkDust(dusty); err == nil {
+		t.Error("Expected the mempool to refuse dust below the network threshold")
+	}
+	mempool.SetRelayPolicy(RelayPolicy{DustThreshold: 1000}, store)
+	if err := mempool.checkDust(send(0, 800, 900_000)); !errors.Is(err, ErrDustOutput) {
+		t.Errorf("Expected the mempool to refuse dust below its own threshold, got %v", err)
+	}
+
+	block := func(txs ...*Transaction) *Block {
+		ids := []string{}
+		for _, tx := range txs {
+			id, _ := tx.ID()
+			mempool.entries[id] = &MempoolEntry{Tx: tx}
+			ids = append(ids, id)
+		}
+		return bc.ProposeBlock(ids, "dust-test-proposer", nil)
+	}
+	if err := bc.AddBlock(block(dusty), mempool); !errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected a block with a dust output to be rejected, got %v", err)
+	}
+	if err := bc.AddBlock(block(send(1, 800, 900_000)), mempool); err != nil {
+		t.Fatalf("Expected outputs over the network threshold to be valid, got %v", err)
+	}
+
+	// Built sends give change too small for an output to the fee
+	utxos := []*UTXO{{TxID: "a", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 100_000+MinBuildFee+499)}}
+	built, err := BuildSendTransaction(nil, utxos, []*TxOutput{CreateShadowOutput(kp.Address(), 100_000)}, kp.Address(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	if len(built.Transaction.Outputs) != 1 || built.Fee != MinBuildFee+499 {
+		t.Errorf("Expected the 499 change paid as fee, got %d outputs and fee %d", len(built.Transaction.Outputs), built.Fee)
+	}
+}
+
+func TestRelayFeeRate(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to create UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "rate-test-funding", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 1_000_000)}); err != nil {
+		t.Fatalf("Failed to add funding: %v", err)
+	}
+	send := func(fee uint64) *Transaction {
+		return NewTxBuilder(TxTypeSend).AddInput("rate-test-funding", 0).AddOutput(kp.Address(), 1_000_000-fee, "").Build()
+	}
+
+	// Two base units per weight unit, while the flat floor alone would pass both
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	mempool.SetRelayPolicy(RelayPolicy{MinFee: 10, MinFeeRate: 2000}, store)
+	cheap := send(100)
+	if err := mempool.meetsRelayFee(cheap); err == nil || !strings.Contains(err.Error(), "for weight") {
+		t.Errorf("Expected a fee under the relay rate to be rejected, got %v", err)
+	}
+	if err := mempool.meetsRelayFee(send(2 * uint64(TxWeight(cheap)))); err != nil {
+		t.Errorf("Expected a fee at the relay rate to pass, got %v", err)
+	}
+	if policy := mempool.RelayPolicy(); policy.MinFee != 10 || policy.MinFeeRate != 2000 {
+		t.Errorf("Unexpected relay policy %+v", policy)
+	}
+}
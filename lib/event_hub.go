@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// Node events are pushed to API clients over the WebSocket at /api/ws, so wallets can
+// react to what happens to their transactions without polling. Publishing never blocks:
+// a subscriber that falls behind loses events rather than stalling the node.
+
+// Event types
+const (
+	EventTxConflicted = "tx_conflicted" // A pending transaction lost a double-spend (TxConflict)
+	EventMemoMissing  = "memo_missing"  // A payment to a memo-required address has no memo (MemoMissing)
+	EventTxOrphaned   = "tx_orphaned"   // A reorg took a confirmed transaction out of the chain (TxOrphaned)
+	EventHTLCLocked   = "htlc_locked"   // An HTLC was locked in a block (HTLCEvent)
+	EventHTLCClaimed  = "htlc_claimed"  // An HTLC was claimed, revealing its preimage (HTLCEvent)
+	EventHTLCRefunded = "htlc_refunded" // An HTLC was refunded (HTLCEvent)
+	EventHTLCExpired  = "htlc_expired"  // A locked HTLC reached its timeout and can be refunded (HTLCEvent)
+)
+
+const (
+	EventPingInterval = 30 * time.Second // Keepalive pings to WebSocket subscribers
+	EventWriteTimeout = 10 * time.Second // A subscriber not reading for this long is dropped
+
+	eventSubscriberBuffer = 64 // Events queued per subscriber before dropping
+)
+
+// NodeEvent is one event sent to subscribers
+type NodeEvent struct {
+	Type string      `json:"type"`
+	Time int64       `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// eventSubscriber is one subscription
+type eventSubscriber struct {
+	ch    chan NodeEvent
+	types map[string]bool // Empty receives every type
+}
+
+// EventHub fans node events out to subscribers
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	closed      bool
+}
+
+// NewEventHub creates an event hub with no subscribers
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[*eventSubscriber]struct{})}
+}
+
+// Subscribe returns a channel receiving events of the given types (all if none) and a
+// function ending the subscription. The channel is closed when the subscription ends or
+// the hub is closed.
+func (h *EventHub) Subscribe(types []string) (<-chan NodeEvent, func()) {
+	sub := &eventSubscriber{ch: make(chan NodeEvent, eventSubscriberBuffer), types: make(map[string]bool)}
+	for _, eventType := range types {
+		sub.types[eventType] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	h.subscribers[sub] = struct{}{}
+
+	return sub.ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[sub]; ok {
+			delete(h.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Publish sends an event to every subscriber of its type. A nil hub drops it.
+func (h *EventHub) Publish(eventType string, data interface{}) {
+	if h == nil {
+		return
+	}
+	event := NodeEvent{Type: eventType, Time: time.Now().Unix(), Data: data}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subscribers {
+		if len(sub.types) > 0 && !sub.types[eventType] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default: // Subscriber is behind; it misses this one
+		}
+	}
+}
+
+// Close ends every subscription
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		close(sub.ch)
+	}
+	h.subscribers = make(map[*eventSubscriber]struct{})
+}
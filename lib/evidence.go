@@ -0,0 +1,284 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Blocks and transactions this node rejects from the network are kept as evidence: why,
+// from which peer, when, and the payload itself, so operators can investigate an attack
+// or attach the data to a bug report. Entries are appended one JSON object per line to
+// a file rotated by size, like the request audit log. The same rejection repeated (a
+// block retried from one peer, say) is recorded once.
+
+const (
+	DefaultEvidencePath = "evidence.log" // Under the data directory
+	MaxEvidenceBytes    = 50 << 20       // Size at which the log is rotated
+	EvidenceBackups     = 3              // Rotated logs kept (evidence.log.1 is the newest)
+	MaxEvidencePayload  = 1 << 20        // Larger payloads are recorded by size only
+	evidenceDedupe      = 1024           // Recent rejections remembered to skip repeats
+	DefaultEvidenceList = 100
+	MaxEvidenceList     = 1000
+)
+
+// What was rejected
+const (
+	EvidenceTransaction = "transaction"
+	EvidenceBlock       = "block"
+)
+
+// Where a rejected payload came from
+const (
+	EvidenceSourceGossip   = "gossip"   // Transaction relay
+	EvidenceSourceProposal = "proposal" // Consensus block proposal
+	EvidenceSourceCommit   = "commit"   // Committed block announced by a peer
+	EvidenceSourceSync     = "sync"     // Block downloaded while syncing
+)
+
+// Evidence is one rejected block or transaction
+type Evidence struct {
+	ID          string          `json:"id"`
+	Timestamp   int64           `json:"timestamp"`
+	Kind        string          `json:"kind"`   // Evidence{Transaction,Block}
+	Source      string          `json:"source"` // EvidenceSource*
+	Peer        string          `json:"peer,omitempty"`
+	Hash        string          `json:"hash"`             // Transaction ID or block hash
+	Height      uint64          `json:"height,omitempty"` // Blocks only
+	Reason      string          `json:"reason"`
+	PayloadSize int             `json:"payload_size"`
+	Payload     json.RawMessage `json:"payload,omitempty"` // Omitted over MaxEvidencePayload
+}
+
+// EvidenceFilter selects entries from the evidence log; zero fields match anything
+type EvidenceFilter struct {
+	Kind   string
+	Source string
+	Peer   string
+	Hash   string
+	Since  int64 // Unix seconds
+	Limit  int
+}
+
+func (f *EvidenceFilter) matches(e *Evidence) bool {
+	return (f.Kind == "" || e.Kind == f.Kind) &&
+		(f.Source == "" || e.Source == f.Source) &&
+		(f.Peer == "" || e.Peer == f.Peer) &&
+		(f.Hash == "" || e.Hash == f.Hash) &&
+		e.Timestamp >= f.Since
+}
+
+// EvidenceStore appends rejected payloads to a size-rotated file. A nil store records
+// nothing.
+type EvidenceStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	recent map[string]bool // Keys of the last evidenceDedupe entries
+	order  []string        // Ring of those keys, oldest at next
+	next   int
+}
+
+// OpenEvidenceStore opens (or creates) the evidence log at path for appending
+func OpenEvidenceStore(path string) (*EvidenceStore, error) {
+	s := &EvidenceStore{path: path, recent: make(map[string]bool), order: make([]string, evidenceDedupe)}
+	if err := s.openLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// openLocked opens the current file. Must be called with mu held (or before sharing).
+func (s *EvidenceStore) openLocked() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open evidence log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat evidence log: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// RecordTransaction records a transaction rejected for reason
+func (s *EvidenceStore) RecordTransaction(source, peer string, tx *Transaction, reason error) {
+	if s == nil {
+		return
+	}
+	txID, _ := tx.ID()
+	s.record(&Evidence{Kind: EvidenceTransaction, Source: source, Peer: peer, Hash: txID, Reason: reason.Error()}, tx)
+}
+
+// RecordBlock records a block rejected for reason
+func (s *EvidenceStore) RecordBlock(source, peer string, block *Block, reason error) {
+	if s == nil {
+		return
+	}
+	s.record(&Evidence{Kind: EvidenceBlock, Source: source, Peer: peer, Hash: block.Hash, Height: block.Index, Reason: reason.Error()}, block)
+}
+
+// record fills in the entry and appends it unless it repeats a recent one. Failures are
+// logged: losing evidence must not affect validation.
+func (s *EvidenceStore) record(entry *Evidence, payload interface{}) {
+	key := entry.Kind + "|" + entry.Hash + "|" + entry.Peer + "|" + entry.Reason
+	if raw, err := json.Marshal(payload); err == nil {
+		entry.PayloadSize = len(raw)
+		if len(raw) <= MaxEvidencePayload {
+			entry.Payload = raw
+		}
+	}
+	entry.Timestamp = time.Now().Unix()
+	id := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, time.Now().UnixNano())))
+	entry.ID = hex.EncodeToString(id[:8])
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recent[key] || s.file == nil {
+		return
+	}
+	if old := s.order[s.next]; old != "" {
+		delete(s.recent, old)
+	}
+	s.order[s.next] = key
+	s.next = (s.next + 1) % len(s.order)
+	s.recent[key] = true
+
+	if s.size > 0 && s.size+int64(len(line)) > MaxEvidenceBytes {
+		if err := s.rotateLocked(); err != nil {
+			fmt.Printf("[Evidence] ⚠️  %v\n", err)
+			return
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		fmt.Printf("[Evidence] ⚠️  Failed to record %s %.16s: %v\n", entry.Kind, entry.Hash, err)
+	}
+}
+
+// rotateLocked shifts evidence.log.N up by one, dropping the oldest, and starts a new
+// file. Must be called with mu held.
+func (s *EvidenceStore) rotateLocked() error {
+	s.file.Close()
+	s.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", s.path, EvidenceBackups))
+	for i := EvidenceBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate evidence log: %w", err)
+	}
+	return s.openLocked()
+}
+
+// Query returns the entries matching filter, newest first, at most filter.Limit
+// (DefaultEvidenceList if zero). withPayload keeps the payloads.
+func (s *EvidenceStore) Query(filter EvidenceFilter, withPayload bool) ([]*Evidence, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEvidenceList
+	}
+	limit = min(limit, MaxEvidenceList)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Newest file first; within a file lines are oldest first
+	var found []*Evidence
+	for i := 0; i <= EvidenceBackups && len(found) < limit; i++ {
+		path := s.path
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", s.path, i)
+		}
+		entries, err := readEvidence(path, &filter)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(entries) - 1; j >= 0 && len(found) < limit; j-- {
+			if !withPayload {
+				entries[j].Payload = nil
+			}
+			found = append(found, entries[j])
+		}
+	}
+	return found, nil
+}
+
+// Get returns the entry with id, payload included, or nil
+func (s *EvidenceStore) Get(id string) (*Evidence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i <= EvidenceBackups; i++ {
+		path := s.path
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", s.path, i)
+		}
+		entries, err := readEvidence(path, &EvidenceFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.ID == id {
+				return entry, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// readEvidence returns the entries in the file at path matching filter; none if it
+// doesn't exist. Lines that don't parse, such as one cut short by a crash, are skipped.
+func readEvidence(path string, filter *EvidenceFilter) ([]*Evidence, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open evidence log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*Evidence
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 2*MaxEvidencePayload+64<<10)
+	for scanner.Scan() {
+		var entry Evidence
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || !filter.matches(&entry) {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read evidence log: %w", err)
+	}
+	return entries, nil
+}
+
+// Close closes the file
+func (s *EvidenceStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvidenceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultEvidencePath)
+	store, err := OpenEvidenceStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open evidence store: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	tx := NewTxBuilder(TxTypeSend).AddInput("evidence-funding", 0).AddOutput(kp.Address(), 100, GetGenesisToken().TokenID).Build()
+	txID, _ := tx.ID()
+	block := &Block{Index: 7, Hash: "evidence-test-block", PreviousHash: "evidence-test-parent"}
+
+	// A repeated rejection is recorded once; the same payload from another peer again
+	store.RecordTransaction(EvidenceSourceGossip, "peer-a", tx, errors.New("transaction is not signed"))
+	store.RecordTransaction(EvidenceSourceGossip, "peer-a", tx, errors.New("transaction is not signed"))
+	store.RecordTransaction(EvidenceSourceGossip, "peer-b", tx, errors.New("transaction is not signed"))
+	store.RecordBlock(EvidenceSourceSync, "peer-a", block, errors.New("block validation failed: bad coinbase"))
+	store.Close()
+
+	// Entries survive a restart and come back newest first, without payloads unless asked
+	store, err = OpenEvidenceStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen evidence store: %v", err)
+	}
+	defer store.Close()
+	all, err := store.Query(EvidenceFilter{}, false)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(all) != 3 || all[0].Kind != EvidenceBlock || all[0].Height != 7 || all[0].Payload != nil {
+		t.Fatalf("Expected 3 entries, the block first without payload, got %d", len(all))
+	}
+	fromA, _ := store.Query(EvidenceFilter{Peer: "peer-a", Kind: EvidenceTransaction}, true)
+	if len(fromA) != 1 || fromA[0].Hash != txID || fromA[0].Reason != "transaction is not signed" || fromA[0].Source != EvidenceSourceGossip {
+		t.Fatalf("Expected peer-a's transaction, got %+v", fromA)
+	}
+	var recorded Transaction
+	if err := json.Unmarshal(fromA[0].Payload, &recorded); err != nil {
+		t.Fatalf("Payload isn't the transaction: %v", err)
+	}
+	if recordedID, _ := recorded.ID(); recordedID != txID || fromA[0].PayloadSize != len(fromA[0].Payload) {
+		t.Errorf("Expected the payload to hash to %s, got %s", txID, recordedID)
+	}
+	if limited, _ := store.Query(EvidenceFilter{Limit: 2}, false); len(limited) != 2 {
+		t.Errorf("Expected the limit to apply, got %d", len(limited))
+	}
+
+	entry, err := store.Get(all[0].ID)
+	if err != nil || entry == nil || entry.Hash != block.Hash || entry.Payload == nil {
+		t.Errorf("Expected the block entry with its payload, got %+v (%v)", entry, err)
+	}
+	if missing, _ := store.Get("no-such-entry"); missing != nil {
+		t.Error("Expected an unknown ID to be not found")
+	}
+
+	var none *EvidenceStore
+	none.RecordBlock(EvidenceSourceCommit, "peer-a", block, errors.New("ignored")) // A nil store records nothing
+}
@@ -0,0 +1,389 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A validator can run a hot standby: a second node with the same wallet that follows the
+// chain and only proposes while the primary is down. The pair share a lease. The node
+// holding it proposes and gossips a heartbeat every FailoverHeartbeatInterval, signed
+// with the wallet key, which is how each node recognizes its partner. The other node
+// neither proposes nor votes while those heartbeats keep arriving, and takes the lease
+// under a higher term once none has for FailoverLeaseDuration. If both hold it (after
+// the pair could not hear each other), the higher term keeps it, then the primary. A
+// standby holding the lease hands it back once the primary is heartbeating again and
+// has caught up to its height.
+//
+// Every node reads the heartbeats: peers announcing they are on standby are left out of
+// leader election, so a passive standby is never elected and the chain never waits on
+// it. The lease only holds while the pair can reach each other; partitioned from each
+// other but not from the network, both will propose.
+
+const (
+	FailoverTopic             = "shadowy-failover"
+	FailoverHeartbeatInterval = 5 * time.Second
+	FailoverLeaseDuration     = 3 * FailoverHeartbeatInterval // Partner silent this long has lost the lease
+
+	FailoverRolePrimary = "primary"
+	FailoverRoleStandby = "standby"
+
+	failoverSignDomain = "shadowy-failover:"
+)
+
+// ValidateFailoverRole checks the failover_role setting
+func ValidateFailoverRole(role string) error {
+	switch role {
+	case "", FailoverRolePrimary, FailoverRoleStandby:
+		return nil
+	default:
+		return fmt.Errorf("failover_role must be %q or %q, got %q", FailoverRolePrimary, FailoverRoleStandby, role)
+	}
+}
+
+// FailoverHeartbeat announces a paired node's role and whether it holds the lease
+type FailoverHeartbeat struct {
+	ChainID   string  `json:"chain_id"`
+	NodeID    string  `json:"node_id"` // libp2p peer ID of the sender
+	Address   Address `json:"address"` // Wallet the pair shares
+	Role      string  `json:"role"`
+	Active    bool    `json:"active"` // Sender holds the lease and proposes
+	Term      uint64  `json:"term"`   // Raised by each takeover
+	Height    uint64  `json:"height"`
+	Timestamp int64   `json:"timestamp"`
+	PublicKey []byte  `json:"public_key"`
+	Signature []byte  `json:"signature,omitempty"`
+}
+
+// signingHash returns what a heartbeat's signature covers
+func (hb *FailoverHeartbeat) signingHash() ([]byte, error) {
+	unsigned := *hb
+	unsigned.Signature = nil
+	data, err := CanonicalJSON(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode heartbeat: %w", err)
+	}
+	hash := sha256.Sum256(append([]byte(failoverSignDomain+hb.ChainID+":"), data...))
+	return hash[:], nil
+}
+
+// verify checks a heartbeat is signed by the key of the wallet it names
+func (hb *FailoverHeartbeat) verify() error {
+	publicKey, err := PublicKeyFromBytes(hb.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid heartbeat public key: %w", err)
+	}
+	if DeriveAddress(publicKey) != hb.Address {
+		return fmt.Errorf("heartbeat key does not belong to %s", hb.Address.String())
+	}
+	hash, err := hb.signingHash()
+	if err != nil {
+		return err
+	}
+	if !VerifySignature(hash, hb.Signature, publicKey) {
+		return fmt.Errorf("invalid heartbeat signature from %.16s", hb.NodeID)
+	}
+	return nil
+}
+
+// FailoverStatus is a paired node's view of the lease
+type FailoverStatus struct {
+	Role    string           `json:"role"`
+	Active  bool             `json:"active"` // This node holds the lease
+	Term    uint64           `json:"term"`
+	Partner *FailoverPartner `json:"partner,omitempty"` // nil until heard from
+}
+
+// FailoverPartner is the other node of the pair as last heard from
+type FailoverPartner struct {
+	NodeID   string `json:"node_id"`
+	Role     string `json:"role"`
+	Active   bool   `json:"active"` // Holds a live lease
+	Term     uint64 `json:"term"`
+	Height   uint64 `json:"height"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// FailoverCoordinator holds this node's side of the lease and tracks which peers are
+// on standby. A nil coordinator, or one without a role, always proposes.
+type FailoverCoordinator struct {
+	role    string // FailoverRole*, "" = not paired
+	nodeID  string
+	signer  Signer
+	address Address
+	height  func() uint64
+	publish func([]byte) error
+	now     func() time.Time
+
+	mu        sync.Mutex
+	started   time.Time
+	active    bool
+	term      uint64
+	partner   *FailoverHeartbeat // Latest heartbeat from the other node of the pair
+	partnerAt time.Time
+	lastSeen  map[string]int64     // Node ID -> timestamp of its latest heartbeat, to drop replays
+	standbys  map[string]time.Time // Nodes announcing standby -> when heard
+}
+
+// NewFailoverCoordinator creates a coordinator for a node with role (empty if unpaired)
+// and the wallet signer it shares with its partner
+func NewFailoverCoordinator(role, nodeID string, signer Signer, height func() uint64, publish func([]byte) error, now func() time.Time) *FailoverCoordinator {
+	c := &FailoverCoordinator{
+		role:     role,
+		nodeID:   nodeID,
+		signer:   signer,
+		height:   height,
+		publish:  publish,
+		now:      now,
+		started:  now(),
+		lastSeen: make(map[string]int64),
+		standbys: make(map[string]time.Time),
+	}
+	if signer != nil {
+		c.address = DeriveAddress(signer.PublicKey())
+	}
+	return c
+}
+
+// Active reports whether this node may propose and vote
+func (c *FailoverCoordinator) Active() bool {
+	if c == nil || c.role == "" {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// IsStandby reports whether a node announced within the lease that it is on standby
+func (c *FailoverCoordinator) IsStandby(nodeID string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	heard, ok := c.standbys[nodeID]
+	return ok && c.now().Sub(heard) < FailoverLeaseDuration
+}
+
+// Tick heartbeats and, without a live partner holding the lease, takes it. Called every
+// FailoverHeartbeatInterval on paired nodes.
+func (c *FailoverCoordinator) Tick() {
+	if c == nil || c.role == "" {
+		return
+	}
+	c.mu.Lock()
+	now := c.now()
+	if !c.active && c.shouldClaimLocked(now) {
+		if c.partner != nil && c.partner.Term > c.term {
+			c.term = c.partner.Term
+		}
+		c.term++
+		c.active = true
+		fmt.Printf("[Failover] 👑 Took the lease as %s (term %d): proposing\n", c.role, c.term)
+	}
+	c.mu.Unlock()
+	c.heartbeat()
+}
+
+// shouldClaimLocked decides whether a passive node takes the lease. Must be called with
+// mu held.
+func (c *FailoverCoordinator) shouldClaimLocked(now time.Time) bool {
+	// After a start, listen for a partner already holding the lease. The standby waits a
+	// beat longer, so when both start together the primary takes it.
+	wait := FailoverLeaseDuration
+	if c.role == FailoverRoleStandby {
+		wait += FailoverHeartbeatInterval
+	}
+	if now.Sub(c.started) < wait {
+		return false
+	}
+	if c.partner == nil || now.Sub(c.partnerAt) >= FailoverLeaseDuration {
+		return true // Partner down
+	}
+	if c.partner.Active {
+		return false
+	}
+	// Both passive: the primary takes it (after a handback, say), or the lower node ID
+	// of two nodes configured alike
+	if c.role != c.partner.Role {
+		return c.role == FailoverRolePrimary
+	}
+	return c.nodeID < c.partner.NodeID
+}
+
+// outranksLocked reports whether this node keeps the lease when the partner claims it
+// too. Must be called with mu held.
+func (c *FailoverCoordinator) outranksLocked(hb *FailoverHeartbeat) bool {
+	if c.term != hb.Term {
+		return c.term > hb.Term
+	}
+	if c.role != hb.Role {
+		return c.role == FailoverRolePrimary
+	}
+	return c.nodeID < hb.NodeID
+}
+
+// heartbeat signs and publishes this node's state
+func (c *FailoverCoordinator) heartbeat() {
+	c.mu.Lock()
+	hb := &FailoverHeartbeat{
+		ChainID:   ActiveGenesis().ChainID,
+		NodeID:    c.nodeID,
+		Address:   c.address,
+		Role:      c.role,
+		Active:    c.active,
+		Term:      c.term,
+		Height:    c.height(),
+		Timestamp: c.now().Unix(),
+	}
+	c.mu.Unlock()
+
+	var err error
+	if hb.PublicKey, err = PublicKeyToBytes(c.signer.PublicKey()); err != nil {
+		fmt.Printf("[Failover] ⚠️  %v\n", err)
+		return
+	}
+	hash, err := hb.signingHash()
+	if err == nil {
+		hb.Signature, err = c.signer.Sign(hash)
+	}
+	if err != nil {
+		fmt.Printf("[Failover] ⚠️  Failed to sign heartbeat: %v\n", err)
+		return
+	}
+	data, err := json.Marshal(hb)
+	if err == nil {
+		err = c.publish(data)
+	}
+	if err != nil {
+		fmt.Printf("[Failover] ⚠️  Failed to publish heartbeat: %v\n", err)
+	}
+}
+
+// Receive handles a heartbeat gossiped by the node from
+func (c *FailoverCoordinator) Receive(data []byte, from string) {
+	if c == nil {
+		return
+	}
+	var hb FailoverHeartbeat
+	if err := json.Unmarshal(data, &hb); err != nil || hb.ChainID != ActiveGenesis().ChainID {
+		return
+	}
+	if hb.NodeID != from || ValidateFailoverRole(hb.Role) != nil || hb.Role == "" {
+		return
+	}
+	if err := hb.verify(); err != nil {
+		fmt.Printf("[Failover] ⚠️  Dropping heartbeat: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	if age := now.Sub(time.Unix(hb.Timestamp, 0)); age > FailoverLeaseDuration || age < -consensusMessageMaxSkew {
+		c.mu.Unlock()
+		return
+	}
+	if hb.Timestamp <= c.lastSeen[hb.NodeID] {
+		c.mu.Unlock()
+		return // Replayed, or older than one already handled
+	}
+	c.lastSeen[hb.NodeID] = hb.Timestamp
+	if hb.Active {
+		delete(c.standbys, hb.NodeID)
+	} else {
+		c.standbys[hb.NodeID] = now
+	}
+	for nodeID, heard := range c.standbys {
+		if now.Sub(heard) >= FailoverLeaseDuration {
+			delete(c.standbys, nodeID)
+			delete(c.lastSeen, nodeID)
+		}
+	}
+
+	if c.role == "" || hb.Address != c.address || hb.NodeID == c.nodeID {
+		c.mu.Unlock()
+		return
+	}
+	if c.partner == nil || c.partner.NodeID != hb.NodeID {
+		fmt.Printf("[Failover] 🤝 Paired with %s %.16s\n", hb.Role, hb.NodeID)
+	}
+	c.partner, c.partnerAt = &hb, now
+
+	stepDown := false
+	switch {
+	case hb.Active && c.active && !c.outranksLocked(&hb):
+		fmt.Printf("[Failover] ⚠️  %s %.16s holds the lease at term %d: stepping down\n", hb.Role, hb.NodeID, hb.Term)
+		stepDown = true
+	case c.active && c.role == FailoverRoleStandby && hb.Role == FailoverRolePrimary && !hb.Active && hb.Height >= c.height():
+		fmt.Printf("[Failover] Primary %.16s is back at height %d: handing back the lease\n", hb.NodeID, hb.Height)
+		stepDown = true
+	}
+	if stepDown {
+		c.active = false
+	}
+	if hb.Term > c.term && !c.active {
+		c.term = hb.Term
+	}
+	c.mu.Unlock()
+
+	// Tell the partner straight away rather than at the next tick
+	if stepDown {
+		c.heartbeat()
+	}
+}
+
+// Status returns this node's view of the lease, or nil if it isn't paired
+func (c *FailoverCoordinator) Status() *FailoverStatus {
+	if c == nil || c.role == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := &FailoverStatus{Role: c.role, Active: c.active, Term: c.term}
+	if c.partner != nil {
+		status.Partner = &FailoverPartner{
+			NodeID:   c.partner.NodeID,
+			Role:     c.partner.Role,
+			Active:   c.partner.Active && c.now().Sub(c.partnerAt) < FailoverLeaseDuration,
+			Term:     c.partner.Term,
+			Height:   c.partner.Height,
+			LastSeen: c.partnerAt.Unix(),
+		}
+	}
+	return status
+}
+
+// listenForFailover hands heartbeats from other nodes to the coordinator
+func (ce *ConsensusEngine) listenForFailover() {
+	for {
+		msg, err := ce.failoverSub.Next(ce.ctx)
+		if err != nil {
+			if ce.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if msg.GetFrom() == ce.host.ID() {
+			continue
+		}
+		ce.failover.Receive(msg.Data, msg.GetFrom().String())
+	}
+}
+
+// failoverLoop heartbeats and keeps the lease on a paired node
+func (ce *ConsensusEngine) failoverLoop() {
+	ticker := time.NewTicker(FailoverHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ce.ctx.Done():
+			return
+		case <-ticker.C:
+			ce.failover.Tick()
+		}
+	}
+}
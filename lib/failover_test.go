@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailoverLease(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	signer := NewLocalSigner(kp)
+	now := time.Unix(1_700_000_000, 0)
+	clock := func() time.Time { return now }
+
+	// Heartbeats reach every other node that is up
+	nodes := map[string]*FailoverCoordinator{}
+	up := map[string]bool{}
+	heights := map[string]uint64{}
+	start := func(nodeID, role string, s Signer) {
+		nodes[nodeID] = NewFailoverCoordinator(role, nodeID, s, func() uint64 { return heights[nodeID] },
+			func(data []byte) error {
+				for id, c := range nodes {
+					if id != nodeID && up[id] {
+						c.Receive(data, nodeID)
+					}
+				}
+				return nil
+			}, clock)
+		up[nodeID] = true
+	}
+	start("node-a", FailoverRolePrimary, signer)
+	start("node-b", FailoverRoleStandby, signer)
+	start("observer", "", nil)
+
+	// Never both proposing, whatever happens
+	run := func(ticks int) {
+		for i := 0; i < ticks; i++ {
+			now = now.Add(FailoverHeartbeatInterval)
+			for _, id := range []string{"node-a", "node-b"} {
+				if up[id] {
+					nodes[id].Tick()
+				}
+			}
+			if up["node-a"] && up["node-b"] && nodes["node-a"].Active() && nodes["node-b"].Active() {
+				t.Fatalf("Both nodes hold the lease at %s", now)
+			}
+		}
+	}
+
+	// Started together, the primary takes the lease and the standby is left out of election
+	run(5)
+	if !nodes["node-a"].Active() || nodes["node-b"].Active() {
+		t.Fatal("Expected the primary to hold the lease")
+	}
+	if !nodes["observer"].IsStandby("node-b") || nodes["observer"].IsStandby("node-a") || !nodes["observer"].Active() {
+		t.Error("Expected other nodes to see only the standby as standing by")
+	}
+
+	// The primary goes down: once the lease lapses the standby takes over under a new term
+	up["node-a"] = false
+	run(2)
+	if nodes["node-b"].Active() {
+		t.Fatal("Expected the standby to wait out the lease")
+	}
+	run(3)
+	status := nodes["node-b"].Status()
+	if !status.Active || status.Term != 2 || nodes["observer"].IsStandby("node-b") {
+		t.Fatalf("Expected the standby to take over at term 2, got %+v", status)
+	}
+
+	// The primary restarts behind the chain: it follows until caught up, then gets the lease back
+	heights["node-b"] = 10
+	start("node-a", FailoverRolePrimary, signer)
+	run(5)
+	if nodes["node-a"].Active() || !nodes["node-b"].Active() {
+		t.Fatal("Expected the standby to keep the lease while the primary catches up")
+	}
+	heights["node-a"] = 10
+	run(5)
+	if !nodes["node-a"].Active() || nodes["node-b"].Active() || nodes["node-a"].Status().Term != 3 {
+		t.Fatalf("Expected the lease handed back to the primary, got %+v", nodes["node-a"].Status())
+	}
+
+	// Split apart, both take the lease; rejoined, the higher term keeps it
+	up["node-b"] = false
+	run(4)
+	up["node-b"] = true
+	up["node-a"] = false
+	run(5)
+	up["node-a"] = true
+	if !nodes["node-a"].Active() || !nodes["node-b"].Active() {
+		t.Fatal("Expected both to hold the lease while split")
+	}
+	run(1)
+	if nodes["node-a"].Active() || !nodes["node-b"].Active() {
+		t.Fatalf("Expected the higher term to keep the lease, got %+v and %+v", nodes["node-a"].Status(), nodes["node-b"].Status())
+	}
+
+	// Heartbeats signed by another wallet, or naming another sender, pair with nothing
+	otherKey, _ := GenerateKeyPair()
+	start("node-x", FailoverRolePrimary, NewLocalSigner(otherKey))
+	nodes["node-x"].Tick()
+	if status := nodes["node-b"].Status(); status.Partner == nil || status.Partner.NodeID != "node-a" {
+		t.Errorf("Expected node-b still paired with node-a, got %+v", status.Partner)
+	}
+	before := nodes["node-a"].Status().Partner.LastSeen
+	now = now.Add(time.Second)
+	nodes["node-x"].publish = func(data []byte) error { nodes["node-a"].Receive(data, "node-b"); return nil }
+	nodes["node-x"].Tick()
+	if nodes["node-a"].Status().Partner.LastSeen != before {
+		t.Error("Expected a heartbeat relayed under another node ID to be dropped")
+	}
+}
@@ -13,13 +13,18 @@ import (
 	"github.com/lpreimesberger/plotlib/pkg/storageproof"
 )
 
-// Global plot manager
+// Global plot manager, used by the CLI; running nodes each load their own
 var (
-	globalPlotCollection *storageproof.PlotCollection
-	plotMutex            sync.RWMutex
-	farmingDebugMode     = true // Global flag for loud/slow debug checks
+	globalPlots      = &PlotManager{}
+	farmingDebugMode = true // Global flag for loud/slow debug checks
 )
 
+// PlotManager holds the plots a node farms with
+type PlotManager struct {
+	mu         sync.RWMutex
+	collection *storageproof.PlotCollection
+}
+
 // ProofOfSpace represents a complete mining proof with both plot and miner signatures
 type ProofOfSpace struct {
 	// Challenge data
@@ -36,10 +41,24 @@ type ProofOfSpace struct {
 	MinerSignature []byte `json:"miner_signature"`  // Our signature over the plot proof
 }
 
-// InitializePlotManager loads plots from the specified directory
+// InitializePlotManager loads plots from the specified directory into the global plot manager
 func InitializePlotManager(plotDir string) error {
-	plotMutex.Lock()
-	defer plotMutex.Unlock()
+	return globalPlots.Load(plotDir)
+}
+
+// LoadPlotManager returns a plot manager holding the plots in plotDir
+func LoadPlotManager(plotDir string) (*PlotManager, error) {
+	pm := &PlotManager{}
+	if err := pm.Load(plotDir); err != nil {
+		return nil, err
+	}
+	return pm, nil
+}
+
+// Load replaces the plots with those in plotDir
+func (pm *PlotManager) Load(plotDir string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 
 	if !farmingDebugMode {
 		log.Printf("Loading plots from: %s", plotDir)
@@ -51,7 +70,7 @@ func InitializePlotManager(plotDir string) error {
 		return fmt.Errorf("failed to load plots: %w", err)
 	}
 
-	globalPlotCollection = pc
+	pm.collection = pc
 
 	if !farmingDebugMode {
 		log.Printf("Successfully loaded %d plot files", len(pc.Plots))
@@ -62,15 +81,23 @@ func InitializePlotManager(plotDir string) error {
 	return nil
 }
 
-// GetPlotCount returns the number of loaded plots
+// GetPlotCount returns the number of plots in the global plot manager
 func GetPlotCount() int {
-	plotMutex.RLock()
-	defer plotMutex.RUnlock()
+	return globalPlots.Count()
+}
+
+// Count returns the number of loaded plots; a nil manager has none
+func (pm *PlotManager) Count() int {
+	if pm == nil {
+		return 0
+	}
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
 
-	if globalPlotCollection == nil {
+	if pm.collection == nil {
 		return 0
 	}
-	return len(globalPlotCollection.Plots)
+	return len(pm.collection.Plots)
 }
 
 // SetFarmingDebugMode enables/disables verbose debug output
@@ -80,14 +107,36 @@ func SetFarmingDebugMode(enabled bool) {
 
 // GenerateProofOfSpace generates a complete mining proof with both plot and miner signatures
 func GenerateProofOfSpace(challengeHash [32]byte, minerPrivateKey []byte) (*ProofOfSpace, error) {
-	plotMutex.RLock()
-	defer plotMutex.RUnlock()
+	// Unmarshal the private key bytes
+	minerPrivateKeyObj := &mldsa87.PrivateKey{}
+	if err := minerPrivateKeyObj.UnmarshalBinary(minerPrivateKey); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal miner private key: %w", err)
+	}
+
+	keyPair := &KeyPair{
+		PublicKey:  minerPrivateKeyObj.Public().(*mldsa87.PublicKey),
+		PrivateKey: minerPrivateKeyObj,
+	}
+	return GenerateProofOfSpaceWithSigner(challengeHash, NewLocalSigner(keyPair))
+}
+
+// GenerateProofOfSpaceWithSigner generates a mining proof from the global plot manager,
+// signing the plot proof with the given signer
+func GenerateProofOfSpaceWithSigner(challengeHash [32]byte, minerSigner Signer) (*ProofOfSpace, error) {
+	return globalPlots.GenerateProof(challengeHash, minerSigner)
+}
+
+// GenerateProof generates a mining proof from these plots, signing the plot proof with
+// the given signer
+func (pm *PlotManager) GenerateProof(challengeHash [32]byte, minerSigner Signer) (*ProofOfSpace, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
 
 	encodedLen := ascii85.MaxEncodedLen(len(challengeHash))
 	dst := make([]byte, encodedLen)
 	ascii85.Encode(dst, challengeHash[:])
 
-	if globalPlotCollection == nil {
+	if pm.collection == nil {
 		return nil, fmt.Errorf("plot collection not initialized - call InitializePlotManager first")
 	}
 
@@ -97,7 +146,7 @@ func GenerateProofOfSpace(challengeHash [32]byte, minerPrivateKey []byte) (*Proo
 
 	// Use LookUp to find the best solution in our plot files
 	// This returns a Solution with plot signature already generated
-	solution, err := globalPlotCollection.LookUp(challengeHash[:])
+	solution, err := pm.collection.LookUp(challengeHash[:])
 	if err != nil {
 		return nil, fmt.Errorf("failed to lookup proof: %w", err)
 	}
@@ -121,20 +170,13 @@ func GenerateProofOfSpace(challengeHash [32]byte, minerPrivateKey []byte) (*Proo
 	// Create plot proof data to be signed by miner using the base85 string
 	plotProofData := createPlotProofData(string(dst), solution.PublicKey, solution.Distance)
 
-	// Generate miner signature using the wallet's ML-DSA87 key
-	// Unmarshal the private key bytes
-	minerPrivateKeyObj := &mldsa87.PrivateKey{}
-	if err := minerPrivateKeyObj.UnmarshalBinary(minerPrivateKey); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal miner private key: %w", err)
+	// Sign the plot proof data with the miner's key (local or external signer)
+	minerSignature, err := minerSigner.Sign(plotProofData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign plot proof: %w", err)
 	}
 
-	// Sign the plot proof data with the miner's key
-	minerSignature := make([]byte, mldsa87.SignatureSize)
-	mldsa87.SignTo(minerPrivateKeyObj, plotProofData, nil, false, minerSignature)
-
-	// Get the public key from the private key
-	minerPublicKeyObj := minerPrivateKeyObj.Public().(*mldsa87.PublicKey)
-	minerPublicKeyBytes, err := minerPublicKeyObj.MarshalBinary()
+	minerPublicKeyBytes, err := PublicKeyToBytes(minerSigner.PublicKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal miner public key: %w", err)
 	}
@@ -0,0 +1,395 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Handshake: when a node dials a peer, the two exchange signed hellos naming their
+// software and protocol versions, chain, height and the services they offer. Hellos are
+// signed with the libp2p host key, so they can't be attributed to another peer, and
+// carry a timestamp, so an old one can't be replayed. A peer on another chain or
+// speaking a protocol version outside what this node supports is disconnected, and
+// dropped again at once if it reconnects within the cooldown. Peers that predate the
+// handshake don't speak the protocol; they stay connected without a hello.
+
+const (
+	HandshakeProtocolID      = "/shadowy/handshake/1.0.0"
+	PeerProtocolVersion      = 1                // Peer protocol this node speaks
+	MinPeerProtocolVersion   = 1                // Oldest peer protocol this node talks to
+	HandshakeTimeout         = 10 * time.Second // Deadline for one hello exchange
+	HandshakeRefreshInterval = 2 * time.Minute  // How often dialed peers are greeted again, refreshing their height
+	IncompatiblePeerCooldown = 30 * time.Minute // Incompatible peers are dropped on reconnect for this long
+
+	handshakeMaxSkew  = 10 * time.Minute // Hellos timestamped further off are refused
+	maxNodeHelloBytes = 4096
+)
+
+// Services a node can offer peers
+const (
+	ServiceArchive     = "archive"      // Keeps every block proof, spent UTXO and transaction body
+	ServiceLightServer = "light-server" // Serves headers with their proofs for the whole chain
+)
+
+// NodeVersion is the software version sent to peers; release builds set it with
+// -ldflags "-X shadowy/lib.NodeVersion=..."
+var NodeVersion = "0.1.0"
+
+// ErrIncompatiblePeer is returned for a peer on another chain or an unsupported
+// protocol version
+var ErrIncompatiblePeer = errors.New("incompatible peer")
+
+// NodeHello is the signed identity a node sends in the handshake
+type NodeHello struct {
+	PeerID             string   `json:"peer_id"`
+	Version            string   `json:"version"`              // Software version
+	ProtocolVersion    int      `json:"protocol_version"`     // Peer protocol spoken
+	MinProtocolVersion int      `json:"min_protocol_version"` // Oldest peer protocol accepted
+	ChainID            string   `json:"chain_id"`
+	Genesis            string   `json:"genesis"` // Genesis fingerprint
+	Height             uint64   `json:"height"`
+	Services           []string `json:"services"`
+	Timestamp          int64    `json:"timestamp"`           // Unix seconds
+	Signature          []byte   `json:"signature,omitempty"` // Host key signature over the canonical JSON without it
+}
+
+// PeerInfo is a connected peer and what its last verified hello said
+type PeerInfo struct {
+	ID              string   `json:"id"`
+	Outbound        bool     `json:"outbound"`
+	Handshake       bool     `json:"handshake"` // False until a hello from the peer is verified
+	Version         string   `json:"version,omitempty"`
+	ProtocolVersion int      `json:"protocol_version,omitempty"`
+	ChainID         string   `json:"chain_id,omitempty"`
+	Height          uint64   `json:"height"`
+	Services        []string `json:"services,omitempty"`
+	HandshakeAt     int64    `json:"handshake_at,omitempty"` // Unix seconds of the last verified hello
+}
+
+// LocalServices returns the services a node with these storage settings offers
+func LocalServices(archive bool, proofPruningDepth int) []string {
+	services := []string{}
+	if archive {
+		services = append(services, ServiceArchive)
+	}
+	if archive || proofPruningDepth == 0 {
+		services = append(services, ServiceLightServer)
+	}
+	return services
+}
+
+// signedBytes returns the bytes a hello's signature covers
+func (h *NodeHello) signedBytes() ([]byte, error) {
+	unsigned := *h
+	unsigned.Signature = nil
+	return CanonicalJSON(&unsigned)
+}
+
+// Sign signs the hello with the sender's libp2p host key
+func (h *NodeHello) Sign(key crypto.PrivKey) error {
+	data, err := h.signedBytes()
+	if err != nil {
+		return err
+	}
+	if h.Signature, err = key.Sign(data); err != nil {
+		return fmt.Errorf("failed to sign hello: %w", err)
+	}
+	return nil
+}
+
+// CheckHello verifies a hello received from a peer: signed by that peer, recent, and
+// compatible with this node
+func CheckHello(hello *NodeHello, from peer.ID, now time.Time) error {
+	if hello.PeerID != from.String() {
+		return fmt.Errorf("hello for %s sent by %s", hello.PeerID, from)
+	}
+	pub, err := from.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("no public key in peer ID %s: %w", from, err)
+	}
+	data, err := hello.signedBytes()
+	if err != nil {
+		return err
+	}
+	if ok, err := pub.Verify(data, hello.Signature); err != nil || !ok {
+		return fmt.Errorf("hello from %s has an invalid signature", from)
+	}
+
+	stamped := time.Unix(hello.Timestamp, 0)
+	if now.Sub(stamped) > handshakeMaxSkew || stamped.Sub(now) > handshakeMaxSkew {
+		return fmt.Errorf("hello from %s is stale or from the future (timestamp %d)", from, hello.Timestamp)
+	}
+
+	genesis := ActiveGenesis()
+	if hello.ChainID != genesis.ChainID || hello.Genesis != genesis.Fingerprint() {
+		return fmt.Errorf("%w: peer is on chain %s, not %s", ErrIncompatiblePeer, hello.ChainID, genesis.ChainID)
+	}
+	if hello.ProtocolVersion < MinPeerProtocolVersion || hello.MinProtocolVersion > PeerProtocolVersion {
+		return fmt.Errorf("%w: peer speaks protocol %d (accepts %d and up), this node speaks %d (accepts %d and up)",
+			ErrIncompatiblePeer, hello.ProtocolVersion, hello.MinProtocolVersion, PeerProtocolVersion, MinPeerProtocolVersion)
+	}
+	return nil
+}
+
+// HandshakeService greets peers on connect and keeps what their hellos said
+type HandshakeService struct {
+	host     host.Host
+	chain    *Blockchain
+	services []string
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu           sync.RWMutex
+	peers        map[peer.ID]*PeerInfo // Verified hellos of connected peers
+	incompatible map[peer.ID]time.Time // Peers disconnected as incompatible, and when
+}
+
+// SetupHandshakeProtocol registers the handshake with libp2p and greets every peer we
+// dial, including those already connected
+func SetupHandshakeProtocol(h host.Host, chain *Blockchain, services []string) *HandshakeService {
+	ctx, cancel := context.WithCancel(context.Background())
+	hs := &HandshakeService{
+		host:         h,
+		chain:        chain,
+		services:     services,
+		ctx:          ctx,
+		cancel:       cancel,
+		peers:        make(map[peer.ID]*PeerInfo),
+		incompatible: make(map[peer.ID]time.Time),
+	}
+
+	h.SetStreamHandler(HandshakeProtocolID, hs.handleStream)
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			if hs.isIncompatible(c.RemotePeer(), time.Now()) {
+				go h.Network().ClosePeer(c.RemotePeer())
+				return
+			}
+			if c.Stat().Direction == network.DirOutbound {
+				go hs.greet(c.RemotePeer())
+			}
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			if n.Connectedness(c.RemotePeer()) != network.Connected {
+				hs.mu.Lock()
+				delete(hs.peers, c.RemotePeer())
+				hs.mu.Unlock()
+			}
+		},
+	})
+
+	for _, p := range hs.dialedPeers() {
+		go hs.greet(p)
+	}
+	go hs.refreshLoop()
+
+	fmt.Printf("[P2P] Handshake: version %s, protocol %d, services %v\n", NodeVersion, PeerProtocolVersion, services)
+	return hs
+}
+
+// Close stops refreshing hellos
+func (hs *HandshakeService) Close() {
+	hs.cancel()
+}
+
+// localHello builds and signs this node's hello
+func (hs *HandshakeService) localHello() (*NodeHello, error) {
+	var height uint64
+	if hs.chain != nil {
+		height = hs.chain.GetHeight() - 1 // Latest block index, as sync reports it
+	}
+	genesis := ActiveGenesis()
+	hello := &NodeHello{
+		PeerID:             hs.host.ID().String(),
+		Version:            NodeVersion,
+		ProtocolVersion:    PeerProtocolVersion,
+		MinProtocolVersion: MinPeerProtocolVersion,
+		ChainID:            genesis.ChainID,
+		Genesis:            genesis.Fingerprint(),
+		Height:             height,
+		Services:           hs.services,
+		Timestamp:          time.Now().Unix(),
+	}
+	key := hs.host.Peerstore().PrivKey(hs.host.ID())
+	if key == nil {
+		return nil, fmt.Errorf("no private key for %s", hs.host.ID())
+	}
+	if err := hello.Sign(key); err != nil {
+		return nil, err
+	}
+	return hello, nil
+}
+
+// greet sends our hello to a peer we dialed and checks the one it sends back
+func (hs *HandshakeService) greet(p peer.ID) {
+	ctx, cancel := context.WithTimeout(hs.ctx, HandshakeTimeout)
+	defer cancel()
+
+	s, err := hs.host.NewStream(ctx, p, HandshakeProtocolID)
+	if err != nil {
+		return // Disconnected, or a peer from before the handshake
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(HandshakeTimeout))
+
+	hello, err := hs.localHello()
+	if err != nil {
+		fmt.Printf("[P2P] Warning: no hello for %s: %v\n", p.String()[:16], err)
+		return
+	}
+	if err := json.NewEncoder(s).Encode(hello); err != nil {
+		return
+	}
+	var theirs NodeHello
+	if err := json.NewDecoder(io.LimitReader(s, maxNodeHelloBytes)).Decode(&theirs); err != nil {
+		return // The peer refused us and hung up
+	}
+	hs.accept(p, &theirs, true)
+}
+
+// handleStream checks the hello of a peer that dialed us and answers with ours
+func (hs *HandshakeService) handleStream(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(HandshakeTimeout))
+
+	p := s.Conn().RemotePeer()
+	var theirs NodeHello
+	if err := json.NewDecoder(io.LimitReader(s, maxNodeHelloBytes)).Decode(&theirs); err != nil {
+		fmt.Printf("[P2P] Failed to decode hello from %s: %v\n", p.String()[:16], err)
+		return
+	}
+	if err := hs.accept(p, &theirs, s.Conn().Stat().Direction == network.DirOutbound); err != nil {
+		return
+	}
+
+	hello, err := hs.localHello()
+	if err != nil {
+		fmt.Printf("[P2P] Warning: no hello for %s: %v\n", p.String()[:16], err)
+		return
+	}
+	json.NewEncoder(s).Encode(hello)
+}
+
+// accept records a verified hello, or disconnects the peer that sent a bad one
+func (hs *HandshakeService) accept(p peer.ID, hello *NodeHello, outbound bool) error {
+	now := time.Now()
+	if err := hs.record(p, hello, outbound, now); err != nil {
+		fmt.Printf("[P2P] Disconnecting %s: %v\n", p.String()[:16], err)
+		go hs.host.Network().ClosePeer(p)
+		return err
+	}
+	return nil
+}
+
+// record checks a hello and stores what it says; incompatible peers start their cooldown
+func (hs *HandshakeService) record(p peer.ID, hello *NodeHello, outbound bool, now time.Time) error {
+	err := CheckHello(hello, p, now)
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if err != nil {
+		delete(hs.peers, p)
+		if errors.Is(err, ErrIncompatiblePeer) {
+			hs.incompatible[p] = now
+		}
+		return err
+	}
+	hs.peers[p] = &PeerInfo{
+		ID:              p.String(),
+		Outbound:        outbound,
+		Handshake:       true,
+		Version:         hello.Version,
+		ProtocolVersion: hello.ProtocolVersion,
+		ChainID:         hello.ChainID,
+		Height:          hello.Height,
+		Services:        hello.Services,
+		HandshakeAt:     now.Unix(),
+	}
+	return nil
+}
+
+// isIncompatible reports whether a peer was disconnected as incompatible within the
+// cooldown, forgetting older entries
+func (hs *HandshakeService) isIncompatible(p peer.ID, now time.Time) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for id, at := range hs.incompatible {
+		if now.Sub(at) > IncompatiblePeerCooldown {
+			delete(hs.incompatible, id)
+		}
+	}
+	_, ok := hs.incompatible[p]
+	return ok
+}
+
+// dialedPeers returns the connected peers we dialed
+func (hs *HandshakeService) dialedPeers() []peer.ID {
+	seen := make(map[peer.ID]bool)
+	var peers []peer.ID
+	for _, c := range hs.host.Network().Conns() {
+		if c.Stat().Direction == network.DirOutbound && !seen[c.RemotePeer()] {
+			seen[c.RemotePeer()] = true
+			peers = append(peers, c.RemotePeer())
+		}
+	}
+	return peers
+}
+
+// refreshLoop greets dialed peers again so their heights stay current
+func (hs *HandshakeService) refreshLoop() {
+	ticker := time.NewTicker(HandshakeRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hs.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, p := range hs.dialedPeers() {
+			go hs.greet(p)
+		}
+	}
+}
+
+// Peer returns what a connected peer's last verified hello said, or nil
+func (hs *HandshakeService) Peer(p peer.ID) *PeerInfo {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	if info, ok := hs.peers[p]; ok {
+		copied := *info
+		return &copied
+	}
+	return nil
+}
+
+// Peers returns every connected peer, with its hello where one was verified
+func (hs *HandshakeService) Peers() []PeerInfo {
+	outbound := make(map[peer.ID]bool)
+	for _, p := range hs.dialedPeers() {
+		outbound[p] = true
+	}
+
+	infos := []PeerInfo{}
+	for _, p := range hs.host.Network().Peers() {
+		info := PeerInfo{ID: p.String(), Outbound: outbound[p]}
+		if hello := hs.Peer(p); hello != nil {
+			info = *hello
+			info.Outbound = outbound[p]
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNodeHello(t *testing.T) {
+	key, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+	malloryKey, _, _ := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	now := time.Now()
+
+	hello := func(edit func(h *NodeHello), signer p2pcrypto.PrivKey) *NodeHello {
+		h := &NodeHello{
+			PeerID:             id.String(),
+			Version:            NodeVersion,
+			ProtocolVersion:    PeerProtocolVersion,
+			MinProtocolVersion: MinPeerProtocolVersion,
+			ChainID:            ActiveGenesis().ChainID,
+			Genesis:            ActiveGenesis().Fingerprint(),
+			Height:             42,
+			Services:           LocalServices(true, 10000),
+			Timestamp:          now.Unix(),
+		}
+		if edit != nil {
+			edit(h)
+		}
+		if err := h.Sign(signer); err != nil {
+			t.Fatalf("Failed to sign hello: %v", err)
+		}
+		return h
+	}
+
+	good := hello(nil, key)
+	if err := CheckHello(good, id, now); err != nil {
+		t.Fatalf("Expected a signed hello to verify, got %v", err)
+	}
+	tampered := *good
+	tampered.Height = 43
+	if err := CheckHello(&tampered, id, now); err == nil {
+		t.Error("Expected a hello changed after signing to be refused")
+	}
+
+	// Forged, stale and misattributed hellos are refused but aren't incompatibility
+	refused := map[string]*NodeHello{
+		"forged": hello(nil, malloryKey),
+		"stale":  hello(func(h *NodeHello) { h.Timestamp = now.Add(-time.Hour).Unix() }, key),
+		"future": hello(func(h *NodeHello) { h.Timestamp = now.Add(time.Hour).Unix() }, key),
+		"other":  hello(func(h *NodeHello) { h.PeerID = "12D3KooWother" }, key),
+	}
+	for name, h := range refused {
+		if err := CheckHello(h, id, now); err == nil || errors.Is(err, ErrIncompatiblePeer) {
+			t.Errorf("%s: expected the hello refused, got %v", name, err)
+		}
+	}
+
+	incompatible := map[string]*NodeHello{
+		"chain":       hello(func(h *NodeHello) { h.ChainID = "shadowy-mainnet-1" }, key),
+		"genesis":     hello(func(h *NodeHello) { h.Genesis = "0000" }, key),
+		"old":         hello(func(h *NodeHello) { h.ProtocolVersion = MinPeerProtocolVersion - 1 }, key),
+		"too new":     hello(func(h *NodeHello) { h.MinProtocolVersion = PeerProtocolVersion + 1 }, key),
+		"new but ok":  hello(func(h *NodeHello) { h.ProtocolVersion = PeerProtocolVersion + 1 }, key),
+		"legacy only": hello(func(h *NodeHello) { h.ProtocolVersion, h.MinProtocolVersion = 0, 0 }, key),
+	}
+	for name, h := range incompatible {
+		err := CheckHello(h, id, now)
+		if name == "new but ok" {
+			if err != nil {
+				t.Errorf("%s: expected a newer peer that still accepts ours to pass, got %v", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrIncompatiblePeer) {
+			t.Errorf("%s: expected ErrIncompatiblePeer, got %v", name, err)
+		}
+	}
+
+	// Incompatible peers are remembered for the cooldown; verified hellos are stored
+	hs := &HandshakeService{peers: make(map[peer.ID]*PeerInfo), incompatible: make(map[peer.ID]time.Time)}
+	if err := hs.record(id, incompatible["chain"], true, now); !errors.Is(err, ErrIncompatiblePeer) {
+		t.Fatalf("Expected the wrong chain recorded as incompatible, got %v", err)
+	}
+	if !hs.isIncompatible(id, now.Add(time.Minute)) {
+		t.Error("Expected the peer to be dropped on reconnect within the cooldown")
+	}
+	if hs.isIncompatible(id, now.Add(IncompatiblePeerCooldown+time.Minute)) {
+		t.Error("Expected the cooldown to expire")
+	}
+	if err := hs.record(id, good, true, now); err != nil {
+		t.Fatalf("Failed to record hello: %v", err)
+	}
+	info := hs.Peer(id)
+	if info == nil || !info.Handshake || info.Height != 42 || info.ChainID != DefaultChainID || len(info.Services) != 2 {
+		t.Errorf("Unexpected peer info %+v", info)
+	}
+}
+
+func TestLocalServices(t *testing.T) {
+	cases := []struct {
+		archive bool
+		depth   int
+		want    []string
+	}{
+		{false, 10000, []string{}},
+		{false, 0, []string{ServiceLightServer}},
+		{true, 10000, []string{ServiceArchive, ServiceLightServer}},
+	}
+	for _, c := range cases {
+		got := LocalServices(c.archive, c.depth)
+		if len(got) != len(c.want) {
+			t.Errorf("archive=%v depth=%d: expected %v, got %v", c.archive, c.depth, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("archive=%v depth=%d: expected %v, got %v", c.archive, c.depth, c.want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,517 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Hashed timelock contracts swap SHADOW or custom tokens atomically against Bitcoin-style
+// chains. Both sides lock funds under the same SHA-256 hash: the initiator, who holds the
+// secret, claims the counterparty's lock on the other chain and so reveals the secret,
+// which the counterparty then uses to claim the initiator's lock here. Each lock has a
+// timeout after which its funds go back to the refund address, so neither side can lose
+// their funds without receiving the other's.
+//
+// An htlc_lock transaction creates HTLC outputs (see HTLCCondition); only htlc_claim,
+// revealing the preimage signed by the recipient, and htlc_refund, signed by the refund
+// key from the timeout on, may spend them. Every HTLC is indexed with its status, and
+// claims record the preimage so the other side of the swap can find it.
+
+const HTLCPrefix = "htlc:" // htlc:{lock txid}:{output index} -> HTLC
+
+const (
+	HTLCStatusLocked   = "locked"
+	HTLCStatusClaimed  = "claimed"
+	HTLCStatusRefunded = "refunded"
+
+	HTLCCheckInterval = 2 * time.Second // How often the HTLC monitor checks the chain
+)
+
+// HTLCTerms are what an HTLC pays and when
+type HTLCTerms struct {
+	Hash      []byte  // SHA-256 of the secret
+	Recipient Address // Claims with the secret
+	Refund    Address // Takes the funds back from Timeout on
+	Timeout   uint64  // Block height
+}
+
+// Condition returns the spend condition locking the HTLC
+func (t *HTLCTerms) Condition() *Condition {
+	return HTLCCondition(t.Recipient, t.Refund, t.Hash, t.Timeout)
+}
+
+// HTLCTerms returns the terms of a condition shaped like HTLCCondition
+func (c *Condition) HTLCTerms() (*HTLCTerms, bool) {
+	if c.Kind != CondOr {
+		return nil, false
+	}
+	claim, refund := c.Subs[0], c.Subs[1]
+	if claim.Kind != CondAnd || claim.Subs[0].Kind != CondSHA256 || claim.Subs[1].Kind != CondPubKey {
+		return nil, false
+	}
+	if refund.Kind != CondAnd || refund.Subs[0].Kind != CondAfter || refund.Subs[1].Kind != CondPubKey {
+		return nil, false
+	}
+	return &HTLCTerms{
+		Hash:      claim.Subs[0].Hash,
+		Recipient: claim.Subs[1].Keys[0],
+		Refund:    refund.Subs[1].Keys[0],
+		Timeout:   refund.Subs[0].Height,
+	}, true
+}
+
+// outputHTLCTerms returns the HTLC terms of an output, if it is an HTLC
+func outputHTLCTerms(output *TxOutput) (*HTLCTerms, bool) {
+	if output == nil || output.Condition == "" {
+		return nil, false
+	}
+	cond, err := ParseCondition(output.Condition)
+	if err != nil {
+		return nil, false
+	}
+	return cond.HTLCTerms()
+}
+
+// NewHTLCSecret returns a random 32-byte secret and its SHA-256 hash
+func NewHTLCSecret() (secret, hash []byte, err error) {
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	sum := sha256.Sum256(secret)
+	return secret, sum[:], nil
+}
+
+// HTLC is an indexed hashed timelock contract
+type HTLC struct {
+	ID          string `json:"id"` // {lock txid}:{output index}
+	LockTxID    string `json:"lock_tx_id"`
+	OutputIndex uint32 `json:"output_index"`
+	Address     string `json:"address"` // Condition address holding the funds
+	Hash        string `json:"hash"`    // SHA-256 of the secret, hex
+	Recipient   string `json:"recipient"`
+	Refund      string `json:"refund"`
+	Timeout     uint64 `json:"timeout"` // Refundable from this height
+	TokenID     string `json:"token_id"`
+	Amount      uint64 `json:"amount"`
+	Status      string `json:"status"`
+	LockHeight  uint64 `json:"lock_height"`
+	SpendTxID   string `json:"spend_tx_id,omitempty"` // Claim or refund
+	SpendHeight uint64 `json:"spend_height,omitempty"`
+	Preimage    string `json:"preimage,omitempty"` // Revealed by the claim, hex
+}
+
+// htlcID returns the ID of the HTLC at an output
+func htlcID(txID string, index uint32) string {
+	return fmt.Sprintf("%s:%d", txID, index)
+}
+
+// putHTLC stores an HTLC record
+func (store *UTXOStore) putHTLC(htlc *HTLC) error {
+	data, err := json.Marshal(htlc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal HTLC: %w", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(HTLCPrefix+htlc.ID), data); err != nil {
+		return fmt.Errorf("failed to store HTLC: %w", err)
+	}
+	return nil
+}
+
+// GetHTLC returns the HTLC with an ID, or nil if there is none
+func (store *UTXOStore) GetHTLC(id string) (*HTLC, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(HTLCPrefix + id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HTLC: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var htlc HTLC
+	if err := json.Unmarshal(data, &htlc); err != nil {
+		return nil, fmt.Errorf("corrupt HTLC %s", id)
+	}
+	return &htlc, nil
+}
+
+// ListHTLCs returns the HTLCs match accepts, in ID order
+func (store *UTXOStore) ListHTLCs(match func(*HTLC) bool) ([]*HTLC, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	iterator, err := store.db.Iterator([]byte(HTLCPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var htlcs []*HTLC
+	for ; iterator.Valid(); iterator.Next() {
+		var htlc HTLC
+		if err := json.Unmarshal(iterator.Value(), &htlc); err != nil {
+			continue
+		}
+		if match == nil || match(&htlc) {
+			htlcs = append(htlcs, &htlc)
+		}
+	}
+	return htlcs, nil
+}
+
+// lookupOutput returns the output of an unspent UTXO, or nil
+func (store *UTXOStore) lookupOutput(txID string, index uint32) *TxOutput {
+	if utxo, err := store.GetUTXO(txID, index); err == nil && utxo != nil {
+		return utxo.Output
+	}
+	return nil
+}
+
+// htlcEvents describes what an HTLC transaction does; lookup resolves spent outputs
+func htlcEvents(tx *Transaction, txID string, height uint64, lookup func(txID string, index uint32) *TxOutput) []TxEvent {
+	var events []TxEvent
+	switch tx.TxType {
+	case TxTypeHTLCLock:
+		for i, output := range tx.Outputs {
+			if terms, ok := outputHTLCTerms(output); ok {
+				events = append(events, TxEvent{Type: TxEventHTLCLocked, Height: height, Address: terms.Recipient.Display(),
+					TokenID: output.TokenID, Amount: output.Amount, HTLCID: htlcID(txID, uint32(i))})
+			}
+		}
+	case TxTypeHTLCClaim, TxTypeHTLCRefund:
+		input := tx.Inputs[0]
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		terms, ok := outputHTLCTerms(spent)
+		if !ok {
+			return nil
+		}
+		event := TxEvent{Type: TxEventHTLCRefunded, Height: height, Address: terms.Refund.Display(),
+			TokenID: spent.TokenID, Amount: spent.Amount, HTLCID: htlcID(input.PrevTxID, input.OutputIndex)}
+		if tx.TxType == TxTypeHTLCClaim {
+			event.Type, event.Address = TxEventHTLCClaimed, terms.Recipient.Display()
+			event.Preimage = hex.EncodeToString(input.Witness.Preimage)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// applyHTLC indexes the HTLCs a transaction locks, claims or refunds (before its inputs
+// are spent)
+func (store *UTXOStore) applyHTLC(tx *Transaction, txID string, height uint64) error {
+	switch tx.TxType {
+	case TxTypeHTLCLock:
+		for i, output := range tx.Outputs {
+			terms, ok := outputHTLCTerms(output)
+			if !ok {
+				continue
+			}
+			err := store.putHTLC(&HTLC{
+				ID:          htlcID(txID, uint32(i)),
+				LockTxID:    txID,
+				OutputIndex: uint32(i),
+				Address:     output.Address.Display(),
+				Hash:        hex.EncodeToString(terms.Hash),
+				Recipient:   terms.Recipient.Display(),
+				Refund:      terms.Refund.Display(),
+				Timeout:     terms.Timeout,
+				TokenID:     output.TokenID,
+				Amount:      output.Amount,
+				Status:      HTLCStatusLocked,
+				LockHeight:  height,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case TxTypeHTLCClaim, TxTypeHTLCRefund:
+		input := tx.Inputs[0]
+		htlc, err := store.GetHTLC(htlcID(input.PrevTxID, input.OutputIndex))
+		if err != nil || htlc == nil {
+			return err
+		}
+		htlc.Status, htlc.SpendTxID, htlc.SpendHeight = HTLCStatusRefunded, txID, height
+		if tx.TxType == TxTypeHTLCClaim {
+			htlc.Status = HTLCStatusClaimed
+			htlc.Preimage = hex.EncodeToString(input.Witness.Preimage)
+		}
+		return store.putHTLC(htlc)
+	}
+	return nil
+}
+
+// validateHTLCLockTransaction validates HTLC locks: signed spends creating at least one HTLC
+func validateHTLCLockTransaction(tx *Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("htlc_lock transaction must have inputs")
+	}
+	locks := 0
+	for _, output := range tx.Outputs {
+		if _, ok := outputHTLCTerms(output); ok {
+			locks++
+		}
+	}
+	if locks == 0 {
+		return fmt.Errorf("htlc_lock transaction must create an HTLC output")
+	}
+	if len(tx.PublicKey) == 0 || len(tx.Signature) == 0 {
+		return fmt.Errorf("htlc_lock transaction must be signed")
+	}
+	return tx.verifySignature()
+}
+
+// validateHTLCSpendTransaction validates claims and refunds: the HTLC is the first input,
+// with a witness revealing the preimage for a claim and none for a refund. Other inputs
+// pay the fee and are covered by the transaction signature.
+func validateHTLCSpendTransaction(tx *Transaction) error {
+	name := tx.TxType.String()
+	if len(tx.Inputs) == 0 || tx.Inputs[0].Witness == nil {
+		return fmt.Errorf("%s transaction must spend an HTLC with a witness as its first input", name)
+	}
+	if len(tx.Outputs) == 0 {
+		return fmt.Errorf("%s transaction must have outputs", name)
+	}
+	preimage := tx.Inputs[0].Witness.Preimage
+	if tx.TxType == TxTypeHTLCClaim && len(preimage) == 0 {
+		return fmt.Errorf("htlc_claim transaction must reveal the preimage")
+	}
+	if tx.TxType == TxTypeHTLCRefund && len(preimage) != 0 {
+		return fmt.Errorf("htlc_refund transaction must not carry a preimage")
+	}
+	if tx.fullyWitnessed() {
+		return nil
+	}
+	if len(tx.PublicKey) == 0 || len(tx.Signature) == 0 {
+		return fmt.Errorf("%s transaction with fee inputs must be signed", name)
+	}
+	return tx.verifySignature()
+}
+
+// CheckHTLCSpends verifies that HTLC outputs are only spent by claims revealing the
+// secret to the recipient and by refunds to the refund key from the timeout on, and that
+// claims and refunds spend an HTLC. lookup resolves spent outputs; inputs it can't
+// resolve are left to other validation.
+func CheckHTLCSpends(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	for i, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil {
+			continue
+		}
+		_, isHTLC := outputHTLCTerms(spent)
+		spendsHTLC := i == 0 && (tx.TxType == TxTypeHTLCClaim || tx.TxType == TxTypeHTLCRefund)
+		if isHTLC && !spendsHTLC {
+			return fmt.Errorf("input %d spends HTLC %s:%d outside an htlc_claim or htlc_refund", i, input.PrevTxID, input.OutputIndex)
+		}
+		if !spendsHTLC {
+			continue
+		}
+		if !isHTLC {
+			return fmt.Errorf("%s input %s:%d is not an HTLC", tx.TxType.String(), input.PrevTxID, input.OutputIndex)
+		}
+		if input.Witness == nil {
+			return fmt.Errorf("%s input %s:%d has no witness", tx.TxType.String(), input.PrevTxID, input.OutputIndex)
+		}
+
+		// The claim must take the hashlock branch and the refund the timelock branch
+		cond, _ := ParseCondition(spent.Condition)
+		signers, err := witnessSigners(tx, i)
+		if err != nil {
+			return err
+		}
+		branch := cond.Subs[0]
+		if tx.TxType == TxTypeHTLCRefund {
+			branch = cond.Subs[1]
+		}
+		if !branch.satisfied(height, signers, input.Witness.Preimage) {
+			if tx.TxType == TxTypeHTLCRefund {
+				return fmt.Errorf("HTLC %s:%d can't be refunded at block %d", input.PrevTxID, input.OutputIndex, height)
+			}
+			return fmt.Errorf("HTLC %s:%d claim needs the preimage and the recipient's signature", input.PrevTxID, input.OutputIndex)
+		}
+	}
+	return nil
+}
+
+// ValidateHTLCSpends rejects a block spending an HTLC other than by its claim or refund terms
+func (bc *Blockchain) ValidateHTLCSpends(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, CheckHTLCSpends)
+}
+
+// checkHTLCSpends rejects a transaction that can't spend its HTLC inputs in the next block
+func (mp *Mempool) checkHTLCSpends(tx *Transaction) error {
+	return mp.checkSpends(tx, CheckHTLCSpends)
+}
+
+// CreateHTLCLockTransaction builds an unsigned htlc_lock paying amount of tokenID from
+// utxos into an HTLC on terms, with change to changeAddress
+func CreateHTLCLockTransaction(registry *TokenRegistry, utxos []*UTXO, terms *HTLCTerms, amount uint64, tokenID string, changeAddress Address, fee, minFee uint64) (*Transaction, error) {
+	built, err := BuildSendTransaction(registry, utxos, []*TxOutput{CreateConditionOutput(registry, terms.Condition(), amount, tokenID)}, changeAddress, fee, minFee)
+	if err != nil {
+		return nil, err
+	}
+	tx := built.Transaction
+	tx.TxType = TxTypeHTLCLock
+	return tx, nil
+}
+
+// CreateHTLCSpendTransaction builds an htlc_claim (with the preimage) or htlc_refund
+// (without) of the HTLC utxo to address. A SHADOW HTLC pays the fee itself; a token HTLC
+// pays it from feeUTXOs, and the transaction then needs a transaction signature after
+// the witness is signed (see AddWitnessSignature).
+func CreateHTLCSpendTransaction(utxo *UTXO, preimage []byte, address Address, fee uint64, feeUTXOs []*UTXO) (*Transaction, error) {
+	txType := TxTypeHTLCRefund
+	if preimage != nil {
+		txType = TxTypeHTLCClaim
+	}
+	builder := NewTxBuilder(txType).AddInput(utxo.TxID, utxo.OutputIndex)
+	output := *utxo.Output
+	output.Address, output.Condition, output.AddressType = address, "", 0
+	output.ScriptPubKey = CreateP2PKHScript(address)
+
+	genesisTokenID := GetGenesisToken().TokenID
+	if output.TokenID == genesisTokenID {
+		if output.Amount <= fee {
+			return nil, fmt.Errorf("HTLC amount %d does not cover the fee of %d", output.Amount, fee)
+		}
+		output.Amount -= fee
+		builder.AddCustomOutput(&output)
+	} else {
+		builder.AddCustomOutput(&output)
+		var shadow uint64
+		for _, feeUTXO := range feeUTXOs {
+			if shadow >= fee {
+				break
+			}
+			if feeUTXO.IsSpent || feeUTXO.Output.Vesting != nil || feeUTXO.Output.Condition != "" || feeUTXO.Output.TokenID != genesisTokenID {
+				continue
+			}
+			builder.AddInput(feeUTXO.TxID, feeUTXO.OutputIndex)
+			shadow += feeUTXO.Output.Amount
+		}
+		if shadow < fee {
+			return nil, fmt.Errorf("insufficient SHADOW for fee: have %d, need %d", shadow, fee)
+		}
+		if change := shadow - fee; change > 0 {
+			builder.AddOutput(address, change, genesisTokenID)
+		}
+	}
+
+	tx := builder.Build()
+	if preimage != nil {
+		tx.SetWitnessPreimage(0, preimage)
+	}
+	return tx, nil
+}
+
+// HTLCEvent is published when an HTLC is locked, claimed, refunded or reaches its timeout
+type HTLCEvent struct {
+	HTLC *HTLC `json:"htlc"`
+}
+
+// HTLCMonitor publishes node events for HTLC transactions in new blocks and for locked
+// HTLCs reaching their timeout
+type HTLCMonitor struct {
+	chain  *Blockchain
+	events *EventHub
+
+	height  uint64          // Next block to check
+	expires map[string]bool // Locked HTLCs not yet announced as expired
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewHTLCMonitor starts following HTLCs from the current chain tip
+func NewHTLCMonitor(chain *Blockchain, events *EventHub) *HTLCMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &HTLCMonitor{chain: chain, events: events, height: chain.GetHeight(), expires: make(map[string]bool), ctx: ctx, cancel: cancel}
+	tip := chain.GetHeight()
+	locked, _ := chain.GetUTXOStore().ListHTLCs(func(h *HTLC) bool { return h.Status == HTLCStatusLocked && h.Timeout > tip })
+	for _, htlc := range locked {
+		m.expires[htlc.ID] = true
+	}
+	go m.loop()
+	return m
+}
+
+// loop checks the chain until the monitor is closed
+func (m *HTLCMonitor) loop() {
+	ticker := time.NewTicker(HTLCCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check publishes the HTLC events of blocks added since the last check, then expiries
+func (m *HTLCMonitor) check() {
+	tip := m.chain.GetHeight()
+	if m.height > tip {
+		m.height = tip // The chain was rolled back
+	}
+	utxoStore := m.chain.GetUTXOStore()
+	for ; m.height < tip; m.height++ {
+		block := m.chain.GetBlock(m.height)
+		if block == nil {
+			continue
+		}
+		for _, txID := range block.Transactions {
+			events, err := utxoStore.GetTxEvents(txID)
+			if err != nil {
+				continue
+			}
+			for _, event := range events {
+				if event.HTLCID == "" {
+					continue
+				}
+				htlc, err := utxoStore.GetHTLC(event.HTLCID)
+				if err != nil || htlc == nil {
+					continue
+				}
+				if event.Type == TxEventHTLCLocked {
+					m.expires[htlc.ID] = true
+				} else {
+					delete(m.expires, htlc.ID)
+				}
+				m.publish(event.Type, htlc) // HTLC node events share the receipt event types
+			}
+		}
+	}
+
+	// Refunds become possible in the block at the timeout height, the next one at tip
+	for id := range m.expires {
+		htlc, err := utxoStore.GetHTLC(id)
+		if err != nil || htlc == nil || htlc.Status != HTLCStatusLocked {
+			delete(m.expires, id)
+			continue
+		}
+		if htlc.Timeout <= tip {
+			delete(m.expires, id)
+			m.publish(EventHTLCExpired, htlc)
+		}
+	}
+}
+
+// publish announces an HTLC event
+func (m *HTLCMonitor) publish(eventType string, htlc *HTLC) {
+	fmt.Printf("[HTLC] %s %s (%d of %s)\n", eventType, htlc.ID[:16], htlc.Amount, htlc.TokenID[:min(16, len(htlc.TokenID))])
+	m.events.Publish(eventType, HTLCEvent{HTLC: htlc})
+}
+
+// Close stops the monitor
+func (m *HTLCMonitor) Close() {
+	m.cancel()
+}
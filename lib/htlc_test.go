@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTLCLifecycle(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	secret, hash, err := NewHTLCSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	if sum := sha256.Sum256(secret); hex.EncodeToString(sum[:]) != hex.EncodeToString(hash) {
+		t.Fatal("Expected the hash to be the secret's SHA-256")
+	}
+
+	// Alice locks 1000 for bob, refundable from block 50
+	terms := &HTLCTerms{Hash: hash, Recipient: bob.Address(), Refund: alice.Address(), Timeout: 50}
+	funding := []*UTXO{{TxID: "htlc-funding", OutputIndex: 0, Output: CreateShadowOutput(alice.Address(), 50000)}}
+	lock, err := CreateHTLCLockTransaction(nil, funding, terms, 1000, GetGenesisToken().TokenID, alice.Address(), 0, MinBuildFee)
+	if err != nil {
+		t.Fatalf("Failed to create lock: %v", err)
+	}
+	lock.Sign(alice)
+	if err := ValidateTransaction(lock); err != nil {
+		t.Fatalf("Expected the lock to validate: %v", err)
+	}
+	if parsed, ok := outputHTLCTerms(lock.Outputs[0]); !ok || parsed.Timeout != 50 || parsed.Recipient != bob.Address() {
+		t.Fatalf("Expected the first output to be the HTLC, got %+v", parsed)
+	}
+
+	// HTLCs can't be created by a plain send
+	send := NewTxBuilder(TxTypeSend).AddInput("htlc-funding", 1).AddConditionOutput(terms.Condition(), 1000, "").Build()
+	send.Sign(alice)
+	if err := ValidateTransaction(send); err == nil {
+		t.Fatal("Expected a send creating an HTLC to be refused")
+	}
+
+	lockID, _ := lock.ID()
+	if err := store.ProcessTokenTransaction(lock, NewTokenRegistry(), bc.GetPoolRegistry(), 10); err != nil {
+		t.Fatalf("Failed to apply lock: %v", err)
+	}
+	for i, output := range lock.Outputs {
+		if err := store.AddUTXO(&UTXO{TxID: lockID, OutputIndex: uint32(i), Output: output, BlockHeight: 10}); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	id := htlcID(lockID, 0)
+	htlc, err := store.GetHTLC(id)
+	if err != nil || htlc == nil || htlc.Status != HTLCStatusLocked || htlc.Amount != 1000 || htlc.Hash != hex.EncodeToString(hash) {
+		t.Fatalf("Expected a locked HTLC record, got %+v (%v)", htlc, err)
+	}
+	if events, _ := store.GetTxEvents(lockID); len(events) != 1 || events[0].Type != TxEventHTLCLocked || events[0].HTLCID != id {
+		t.Fatalf("Expected an htlc_locked receipt, got %+v", events)
+	}
+
+	utxo, _ := store.GetUTXO(lockID, 0)
+	spend := func(preimage []byte, signer *KeyPair, to Address) *Transaction {
+		tx, err := CreateHTLCSpendTransaction(utxo, preimage, to, 100, nil)
+		if err != nil {
+			t.Fatalf("Failed to create HTLC spend: %v", err)
+		}
+		if err := tx.AddWitnessSignature(0, NewLocalSigner(signer)); err != nil {
+			t.Fatalf("Failed to sign witness: %v", err)
+		}
+		return tx
+	}
+
+	// Refunds wait for the timeout and must come from the refund key
+	refund := spend(nil, alice, alice.Address())
+	if err := ValidateTransaction(refund); err != nil {
+		t.Fatalf("Expected the refund to validate: %v", err)
+	}
+	if err := CheckHTLCSpends(refund, 49, store.lookupOutput); err == nil {
+		t.Fatal("Expected a refund before the timeout to be refused")
+	}
+	if err := CheckHTLCSpends(spend(nil, bob, bob.Address()), 50, store.lookupOutput); err == nil {
+		t.Fatal("Expected a refund signed by the recipient to be refused")
+	}
+	if err := CheckHTLCSpends(refund, 50, store.lookupOutput); err != nil {
+		t.Fatalf("Expected the refund at the timeout: %v", err)
+	}
+
+	// Claims need the secret and the recipient's key, even after the timeout
+	if err := CheckHTLCSpends(spend(secret, alice, alice.Address()), 60, store.lookupOutput); err == nil {
+		t.Fatal("Expected a claim signed by the refund key to be refused")
+	}
+	claim := spend(secret, bob, bob.Address())
+	if err := ValidateTransaction(claim); err != nil {
+		t.Fatalf("Expected the claim to validate: %v", err)
+	}
+	if err := CheckHTLCSpends(claim, 20, store.lookupOutput); err != nil {
+		t.Fatalf("Expected bob's claim: %v", err)
+	}
+
+	// A send satisfying the condition still can't take the HTLC
+	witnessed := NewTxBuilder(TxTypeSend).AddInput(lockID, 0).AddCustomOutput(CreateShadowOutput(bob.Address(), 900)).Build()
+	witnessed.SetWitnessPreimage(0, secret)
+	witnessed.AddWitnessSignature(0, NewLocalSigner(bob))
+	if err := CheckSpendConditions(witnessed, 20, store.lookupOutput); err != nil {
+		t.Fatalf("Expected the witness to satisfy the condition: %v", err)
+	}
+	if err := CheckHTLCSpends(witnessed, 20, store.lookupOutput); err == nil {
+		t.Fatal("Expected a send spending an HTLC to be refused")
+	}
+
+	// The claim records the revealed secret for the other side of the swap
+	claimID, _ := claim.ID()
+	if err := store.ProcessTokenTransaction(claim, NewTokenRegistry(), bc.GetPoolRegistry(), 20); err != nil {
+		t.Fatalf("Failed to apply claim: %v", err)
+	}
+	htlc, _ = store.GetHTLC(id)
+	if htlc.Status != HTLCStatusClaimed || htlc.SpendTxID != claimID || htlc.Preimage != hex.EncodeToString(secret) {
+		t.Fatalf("Expected a claimed HTLC with its preimage, got %+v", htlc)
+	}
+	if events, _ := store.GetTxEvents(claimID); len(events) != 1 || events[0].Type != TxEventHTLCClaimed || events[0].Preimage != htlc.Preimage {
+		t.Fatalf("Expected an htlc_claimed receipt, got %+v", events)
+	}
+	if claimed, _ := store.ListHTLCs(func(h *HTLC) bool { return h.Status == HTLCStatusClaimed }); len(claimed) != 1 {
+		t.Fatalf("Expected one claimed HTLC, got %d", len(claimed))
+	}
+}
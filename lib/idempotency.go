@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Idempotency keys make write endpoints safe to retry. A client sends the same
+// Idempotency-Key header on every attempt of one logical request; the first attempt runs
+// and its response is cached, later attempts within the window get the cached response
+// instead of building (and double-spending) a second transaction. Keys are scoped to the
+// authenticated caller, so one client can neither claim nor replay another client's key.
+
+const (
+	IdempotencyHeader  = "Idempotency-Key"
+	IdempotencyWindow  = 24 * time.Hour // How long a key's response is replayed
+	MaxIdempotencyKey  = 255            // Longest accepted key
+	MaxIdempotencyKeys = 10000          // Cached responses kept at most
+
+	// MaxIdempotentBody is the largest body read to fingerprint a keyed request, the
+	// largest any write endpoint accepts (an airdrop upload)
+	MaxIdempotentBody = MaxAirdropUploadBytes
+)
+
+// idempotentResponse is a cached response, or a request still being handled
+type idempotentResponse struct {
+	requestHash [32]byte      // Method, URI and body the key was first used with
+	done        chan struct{} // Closed once the response below is filled in
+	status      int
+	header      http.Header
+	body        []byte
+	created     time.Time
+}
+
+// IdempotencyCache holds responses of requests made with an idempotency key
+type IdempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse // Caller + "\n" + key -> response
+	window  time.Duration
+}
+
+// NewIdempotencyCache creates a cache replaying responses for window
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		entries: make(map[string]*idempotentResponse),
+		window:  window,
+	}
+}
+
+// responseRecorder captures a response while passing it through
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
+
+// Wrap runs next at most once per idempotency key of caller, the identity the request
+// was authenticated as. Requests without the header pass straight through. Reusing a key
+// for a different request is rejected, as is a retry while the first attempt is still
+// running. Server errors and panics are not cached, so a retry after one runs again.
+func (ic *IdempotencyCache) Wrap(caller string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(IdempotencyHeader)
+		if header == "" || r.Method == http.MethodGet {
+			next(w, r)
+			return
+		}
+		if len(header) > MaxIdempotencyKey {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+		key := caller + "\n" + header
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxIdempotentBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+
+		ic.mu.Lock()
+		ic.pruneLocked()
+		if cached, exists := ic.entries[key]; exists {
+			ic.mu.Unlock()
+			if cached.requestHash != requestHash {
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			select {
+			case <-cached.done:
+				for name, values := range cached.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(cached.status)
+				w.Write(cached.body)
+			default:
+				w.Header().Set("Retry-After", "1") // Tells this 409 apart from a handler's own
+				http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+			}
+			return
+		}
+		entry := &idempotentResponse{requestHash: requestHash, done: make(chan struct{}), created: time.Now()}
+		ic.entries[key] = entry
+		ic.mu.Unlock()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			ic.mu.Lock()
+			defer ic.mu.Unlock()
+			if !completed || rec.status >= http.StatusInternalServerError {
+				delete(ic.entries, key) // A panicking handler must not hold the key for the whole window
+			} else {
+				entry.status, entry.header, entry.body = rec.status, w.Header().Clone(), rec.body.Bytes()
+			}
+			close(entry.done)
+		}()
+
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		completed = true
+	}
+}
+
+// pruneLocked drops expired responses, and the oldest ones beyond MaxIdempotencyKeys
+// Must be called with mu held
+func (ic *IdempotencyCache) pruneLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range ic.entries {
+		if time.Since(entry.created) > ic.window {
+			delete(ic.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.created.Before(oldest) {
+			oldestKey, oldest = key, entry.created
+		}
+	}
+	if len(ic.entries) >= MaxIdempotencyKeys && oldestKey != "" {
+		delete(ic.entries, oldestKey)
+	}
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	calls := 0
+	handler := NewIdempotencyCache(time.Hour).Wrap("api_key:test", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"tx_id":"` + strings.Repeat("a", calls) + `"}`))
+	})
+	sendTo := func(target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	send := func(key, body string) *httptest.ResponseRecorder {
+		return sendTo("/api/tx/send", key, body)
+	}
+
+	first := send("retry-1", `{"amount":5}`)
+	retry := send("retry-1", `{"amount":5}`)
+	if calls != 1 {
+		t.Fatalf("Retry should not run the handler again, ran %d times", calls)
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Retry should replay the first response, got %q", retry.Body.String())
+	}
+
+	if rec := send("retry-1", `{"amount":6}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Reusing a key for a different request should be rejected, got %d", rec.Code)
+	}
+	if rec := sendTo("/api/tx/send?dry_run=true", "retry-1", `{"amount":5}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Reusing a key with a different query should be rejected, got %d", rec.Code)
+	}
+	if rec := send("too-large", strings.Repeat("x", MaxIdempotentBody+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a body over the limit to be refused, got %d", rec.Code)
+	}
+
+	send("", `{"amount":5}`)
+	send("", `{"amount":5}`)
+	if calls != 3 {
+		t.Errorf("Requests without a key should always run, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyKeysScopedToCaller(t *testing.T) {
+	cache := NewIdempotencyCache(time.Hour)
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(strings.Repeat("a", calls)))
+	}
+	send := func(caller, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/tx/send", strings.NewReader(body))
+		req.Header.Set(IdempotencyHeader, "shared-key")
+		rec := httptest.NewRecorder()
+		cache.Wrap(caller, handler)(rec, req)
+		return rec
+	}
+
+	alice := send("signature:alice", `{"amount":5}`)
+	if rec := send("signature:bob", `{"amount":6}`); rec.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("Another caller's key should not block this one, got %d after %d calls", rec.Code, calls)
+	}
+	if rec := send("signature:bob", `{"amount":5}`); rec.Header().Get("Idempotent-Replayed") == "true" && rec.Body.String() == alice.Body.String() {
+		t.Fatal("A cached response must not be replayed to a different caller")
+	}
+}
+
+func TestIdempotencyKeyReleasedAfterPanic(t *testing.T) {
+	cache := NewIdempotencyCache(time.Hour)
+	send := func(handler http.HandlerFunc) (rec *httptest.ResponseRecorder, panicked bool) {
+		req := httptest.NewRequest(http.MethodPost, "/api/tx/send", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyHeader, "panic-key")
+		rec = httptest.NewRecorder()
+		defer func() { panicked = recover() != nil }()
+		cache.Wrap("api_key:test", handler)(rec, req)
+		return rec, false
+	}
+
+	if _, panicked := send(func(w http.ResponseWriter, r *http.Request) { panic("boom") }); !panicked {
+		t.Fatal("Expected the handler's panic to propagate")
+	}
+	rec, _ := send(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("A retry after a panic should run again, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,534 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The "inspect" subcommand reads a node's data directory without running the node. The
+// databases are opened read-only and nothing is loaded through the chain, so nothing is
+// repaired, rebuilt or written: what it prints is exactly what is on disk, which is the
+// point when debugging corrupted state. Records that fail to parse are reported and
+// skipped rather than ending the run. A running node holds the databases locked, so it
+// has to be stopped first.
+
+const (
+	inspectOpenTimeout   = 2 * time.Second // Wait for a database lock before giving up
+	DefaultInspectBlocks = 20              // Blocks listed without --count
+)
+
+// chainInspector reads the block and UTXO databases of a stopped node
+type chainInspector struct {
+	blocks *BoltDBAdapter
+	utxos  *UTXOStore
+}
+
+// openChainInspector opens the databases of the chain stored at storePath, as passed to
+// NewBlockchain, read-only
+func openChainInspector(storePath string) (*chainInspector, error) {
+	blocks, err := OpenBoltDBAdapterReadOnly(storePath+".db", inspectOpenTimeout)
+	if err != nil {
+		return nil, err
+	}
+	utxoDB, err := OpenBoltDBAdapterReadOnly(storePath+"_utxo.db", inspectOpenTimeout)
+	if err != nil {
+		blocks.Close()
+		return nil, err
+	}
+	return &chainInspector{blocks: blocks, utxos: &UTXOStore{db: utxoDB, cache: NewUTXOCache(DefaultUTXOCacheSize)}}, nil
+}
+
+// Close closes both databases
+func (ci *chainInspector) Close() {
+	ci.blocks.Close()
+	ci.utxos.db.Close()
+}
+
+// tipHeight returns the stored latest height, or false on a chain with no blocks
+func (ci *chainInspector) tipHeight() (uint64, bool, error) {
+	data, err := ci.blocks.Get([]byte(latestHeightKey))
+	if err != nil || data == nil {
+		return 0, false, err
+	}
+	height, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("latest height %q is not a number", data)
+	}
+	return height, true, nil
+}
+
+// block returns the block stored at height, or nil
+func (ci *chainInspector) block(height uint64) (*Block, error) {
+	data, err := ci.blocks.Get([]byte(fmt.Sprintf("%s%d", blockPrefix, height)))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("block %d does not parse: %w", height, err)
+	}
+	return &block, nil
+}
+
+// blockByRef returns the block at a height or with a hash, or nil
+func (ci *chainInspector) blockByRef(ref string) (*Block, error) {
+	if height, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		return ci.block(height)
+	}
+	data, err := ci.blocks.Get([]byte(blockHashPrefix + ref))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	height, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("hash index for %s holds %q", ref, data)
+	}
+	return ci.block(height)
+}
+
+// RunInspectCommand runs the "inspect" subcommand against the node's data directory
+func RunInspectCommand(args []string) error {
+	return runInspect(DataPath("blockchain"), args, os.Stdout)
+}
+
+// inspectUsage lists the inspect subcommands
+const inspectUsage = `usage: inspect <command>
+  summary                      chain tip, database sizes and record counts (default)
+  blocks [--from N] [--count N] block summaries, newest first
+  block <height|hash>          one block and its transactions
+  tx <txid>                    a stored transaction and the state of its outputs
+  utxos [--address A]          UTXO set totals per token, or an address's unspent outputs
+  tokens                       the token registry and pools
+  pending                      transactions the node wallet submitted that haven't confirmed`
+
+// runInspect runs an inspect command against the chain at storePath, writing to out
+func runInspect(storePath string, args []string, out io.Writer) error {
+	command := "summary"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command == "pending" {
+		return inspectPending(DataPath(DefaultWalletTxsPath), out)
+	}
+
+	ci, err := openChainInspector(storePath)
+	if err != nil {
+		return err
+	}
+	defer ci.Close()
+
+	switch command {
+	case "summary":
+		return ci.summary(out)
+	case "blocks":
+		fs := flag.NewFlagSet("blocks", flag.ContinueOnError)
+		from := fs.Int64("from", -1, "Newest block to list (default: the tip)")
+		count := fs.Int("count", DefaultInspectBlocks, "Blocks to list")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return ci.listBlocks(out, *from, *count)
+	case "block":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: inspect block <height|hash>")
+		}
+		return ci.showBlock(out, args[0])
+	case "tx":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: inspect tx <txid>")
+		}
+		return ci.showTransaction(out, args[0])
+	case "utxos":
+		fs := flag.NewFlagSet("utxos", flag.ContinueOnError)
+		address := fs.String("address", "", "List this address's unspent outputs")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *address != "" {
+			return ci.listAddressUTXOs(out, *address)
+		}
+		return ci.utxoStats(out)
+	case "tokens":
+		return ci.listTokens(out)
+	default:
+		return fmt.Errorf("unknown inspect command %q\n%s", command, inspectUsage)
+	}
+}
+
+// summary prints the tip, the database files and their record counts
+func (ci *chainInspector) summary(out io.Writer) error {
+	height, ok, err := ci.tipHeight()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintf(out, "Chain:   no blocks stored\n")
+	} else if tip, err := ci.block(height); err != nil || tip == nil {
+		fmt.Fprintf(out, "Chain:   height %d, tip block missing or unreadable (%v)\n", height, err)
+	} else {
+		fmt.Fprintf(out, "Chain:   height %d, tip %s at %s\n", height, tip.Hash, time.Unix(tip.Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+	if genesis, _ := ci.blocks.Get([]byte(genesisHashKey)); genesis != nil {
+		fmt.Fprintf(out, "Genesis: %s\n", genesis)
+	}
+
+	for _, db := range []*BoltDBAdapter{ci.blocks, ci.utxos.db} {
+		stats, err := db.Stats()
+		if err != nil {
+			fmt.Fprintf(out, "\n%s: unreadable: %v\n", db.path, err)
+			continue
+		}
+		schema := "unversioned"
+		if version, ok, err := schemaVersion(db); err != nil {
+			schema = err.Error()
+		} else if ok {
+			schema = strconv.Itoa(version)
+		}
+		fmt.Fprintf(out, "\n%s: %d bytes, %d keys, schema version %s\n", stats.Path, stats.SizeBytes, stats.TotalKeys, schema)
+		prefixes := make([]string, 0, len(stats.KeyCounts))
+		for prefix := range stats.KeyCounts {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, prefix := range prefixes {
+			fmt.Fprintf(w, "  %s\t%d\n", prefix, stats.KeyCounts[prefix])
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+// listBlocks prints count block summaries down from height from (the tip if negative)
+func (ci *chainInspector) listBlocks(out io.Writer, from int64, count int) error {
+	height, ok, err := ci.tipHeight()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Fprintf(out, "No blocks stored\n")
+		return nil
+	}
+	if from >= 0 && uint64(from) < height {
+		height = uint64(from)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HEIGHT\tHASH\tTIME\tTXS\tPROPOSER\n")
+	for i := 0; i < count; i++ {
+		block, err := ci.block(height)
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "%d\t%v\t\t\t\n", height, err)
+		case block == nil:
+			fmt.Fprintf(w, "%d\tmissing\t\t\t\n", height)
+		default:
+			txs := len(block.Transactions) + len(block.Settlements)
+			if block.Coinbase != nil {
+				txs++
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%.16s\n", block.Index, block.Hash,
+				time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339), txs, block.Proposer)
+		}
+		if height == 0 {
+			break
+		}
+		height--
+	}
+	return w.Flush()
+}
+
+// showBlock prints a block's header and each of its transactions
+func (ci *chainInspector) showBlock(out io.Writer, ref string) error {
+	block, err := ci.blockByRef(ref)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return fmt.Errorf("block %s not found", ref)
+	}
+
+	fmt.Fprintf(out, "Height:   %d\n", block.Index)
+	fmt.Fprintf(out, "Hash:     %s\n", block.Hash)
+	fmt.Fprintf(out, "Previous: %s\n", block.PreviousHash)
+	fmt.Fprintf(out, "Time:     %s\n", time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339))
+	fmt.Fprintf(out, "Proposer: %s\n", block.Proposer)
+	if block.WinnerAddress != nil {
+		fmt.Fprintf(out, "Winner:   %s\n", block.WinnerAddress.Display())
+	}
+	fmt.Fprintf(out, "Votes:    %d\n", len(block.Votes))
+	if block.Index > 0 {
+		if prev, err := ci.block(block.Index - 1); err == nil && prev != nil && prev.Hash != block.PreviousHash {
+			fmt.Fprintf(out, "⚠️  Previous hash does not match stored block %d (%s)\n", prev.Index, prev.Hash)
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nTXID\tTYPE\tINPUTS\tOUTPUTS\tSHADOW OUT\n")
+	row := func(txID string, tx *Transaction) {
+		if tx == nil {
+			fmt.Fprintf(w, "%s\tbody not stored\t\t\t\n", txID)
+			return
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", txID, tx.TxType, len(tx.Inputs), len(tx.Outputs), tx.GetTotalOutputAmount())
+	}
+	if block.Coinbase != nil {
+		txID, _ := block.Coinbase.ID()
+		row(txID, block.Coinbase)
+	}
+	for _, txID := range block.Transactions {
+		tx, err := ci.utxos.GetTransaction(txID)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%v\t\t\t\n", txID, err)
+			continue
+		}
+		row(txID, tx)
+	}
+	for _, tx := range block.Settlements {
+		txID, _ := tx.ID()
+		row(txID, tx)
+	}
+	return w.Flush()
+}
+
+// showTransaction prints a stored transaction and whether each output is unspent
+func (ci *chainInspector) showTransaction(out io.Writer, txID string) error {
+	tx, err := ci.utxos.GetTransaction(txID)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fmt.Errorf("transaction %s not stored (unknown, or its body was pruned)", txID)
+	}
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s\n\n", data)
+
+	// Node-created outputs (swaps, collateral releases) follow the transaction's own
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "OUTPUT\tAMOUNT\tTOKEN\tADDRESS\tSTATE\n")
+	for i := uint32(0); ; i++ {
+		utxo, err := ci.utxos.GetUTXO(txID, i)
+		if err != nil {
+			fmt.Fprintf(w, "%d\t\t\t\t%v\n", i, err)
+			if int(i) < len(tx.Outputs) {
+				continue
+			}
+			break
+		}
+		if utxo == nil {
+			if int(i) < len(tx.Outputs) {
+				fmt.Fprintf(w, "%d\t%d\t%.16s\t%s\tnot in UTXO set (pruned or never applied)\n",
+					i, tx.Outputs[i].Amount, tx.Outputs[i].TokenID, tx.Outputs[i].Address.Display())
+				continue
+			}
+			break
+		}
+		state := fmt.Sprintf("unspent, created at %d", utxo.BlockHeight)
+		if utxo.IsSpent {
+			spentAt, _ := ci.utxos.db.Get([]byte(fmt.Sprintf("%s%s:%d", SpentPrefix, txID, i)))
+			state = fmt.Sprintf("spent at %s, created at %d", spentAt, utxo.BlockHeight)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%.16s\t%s\t%s\n", i, utxo.Output.Amount, utxo.Output.TokenID, utxo.Output.Address.Display(), state)
+	}
+	return w.Flush()
+}
+
+// utxoStats prints unspent and spent output counts and unspent amounts per token
+func (ci *chainInspector) utxoStats(out io.Writer) error {
+	type tokenTotal struct {
+		outputs int
+		amount  uint64
+	}
+	totals := make(map[string]*tokenTotal)
+	addresses := make(map[Address]bool)
+	var unspent, spent, unreadable int
+
+	iterator, err := ci.utxos.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	for ; iterator.Valid(); iterator.Next() {
+		if !strings.HasPrefix(string(iterator.Key()), UTXOPrefix) {
+			break
+		}
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil || utxo.Output == nil {
+			unreadable++
+			continue
+		}
+		if utxo.IsSpent {
+			spent++
+			continue
+		}
+		unspent++
+		addresses[utxo.Output.Address] = true
+		total := totals[utxo.Output.TokenID]
+		if total == nil {
+			total = &tokenTotal{}
+			totals[utxo.Output.TokenID] = total
+		}
+		total.outputs++
+		total.amount += utxo.Output.Amount
+	}
+	iterator.Close()
+
+	fmt.Fprintf(out, "Unspent: %d outputs at %d addresses\n", unspent, len(addresses))
+	fmt.Fprintf(out, "Spent:   %d outputs (kept until pruned)\n", spent)
+	if unreadable > 0 {
+		fmt.Fprintf(out, "⚠️  %d UTXO records do not parse\n", unreadable)
+	}
+
+	tickers := make(map[string]string)
+	if records, err := ci.utxos.loadTokens(); err == nil {
+		for _, record := range records {
+			tickers[record.Token.TokenID] = record.Token.Ticker
+		}
+	}
+	tickers[GetGenesisToken().TokenID] = GetGenesisToken().Ticker
+	tokenIDs := make([]string, 0, len(totals))
+	for tokenID := range totals {
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	sort.Slice(tokenIDs, func(i, j int) bool { return totals[tokenIDs[i]].amount > totals[tokenIDs[j]].amount })
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nTOKEN\tTICKER\tOUTPUTS\tAMOUNT\n")
+	for _, tokenID := range tokenIDs {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", tokenID, tickers[tokenID], totals[tokenID].outputs, totals[tokenID].amount)
+	}
+	return w.Flush()
+}
+
+// listAddressUTXOs prints an address's unspent outputs from the address index
+func (ci *chainInspector) listAddressUTXOs(out io.Writer, address string) error {
+	addr, _, err := ParseAddress(address)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	utxos, err := ci.utxos.GetUTXOsByAddress(addr)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "OUTPOINT\tAMOUNT\tTOKEN\tHEIGHT\n")
+	for _, utxo := range utxos {
+		fmt.Fprintf(w, "%s:%d\t%d\t%.16s\t%d\n", utxo.TxID, utxo.OutputIndex, utxo.Output.Amount, utxo.Output.TokenID, utxo.BlockHeight)
+	}
+	fmt.Fprintf(w, "\n%d unspent outputs\n", len(utxos))
+	return w.Flush()
+}
+
+// listTokens prints the stored token and pool records
+func (ci *chainInspector) listTokens(out io.Writer) error {
+	records, err := ci.utxos.loadTokens()
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Token.Ticker < records[j].Token.Ticker })
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TOKEN\tTICKER\tSUPPLY\tISSUED\tLOCKED\tMELTED\tBURNED\tCREATOR\tNOTES\n")
+	for _, record := range records {
+		token := record.Token
+		var notes []string
+		if token.Collateral != nil {
+			notes = append(notes, fmt.Sprintf("collateralized, %d positions", len(record.Positions)))
+		}
+		if len(token.MintDelegates) > 0 {
+			notes = append(notes, fmt.Sprintf("%d mint delegates", len(token.MintDelegates)))
+		}
+		if err := token.Validate(); err != nil {
+			notes = append(notes, "⚠️  "+err.Error())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%.16s\t%s\n", token.TokenID, token.Ticker, token.TotalSupply, token.Issued(),
+			token.LockedShadow, token.TotalMelted, token.TotalBurned, token.CreatorAddress.Display(), strings.Join(notes, "; "))
+	}
+	fmt.Fprintf(w, "\n%d tokens\n", len(records))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	pools, err := ci.utxos.loadPools()
+	if err != nil {
+		return err
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nPOOL\tTOKEN A\tTOKEN B\tRESERVE A\tRESERVE B\tLP SUPPLY\tCREATED\n")
+	for _, record := range pools {
+		pool := record.Pool
+		fmt.Fprintf(w, "%s\t%.16s\t%.16s\t%d\t%d\t%d\t%d\n", pool.PoolID, pool.TokenA, pool.TokenB,
+			pool.ReserveA, pool.ReserveB, pool.LPTokenSupply, pool.CreatedAt)
+	}
+	fmt.Fprintf(w, "\n%d pools\n", len(pools))
+	return w.Flush()
+}
+
+// inspectPending prints the node wallet's tracked transactions that haven't confirmed.
+// The mempool itself is only held in memory, so this is the pending state on disk.
+func inspectPending(path string, out io.Writer) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(out, "No tracked wallet transactions (%s not found)\n", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state struct {
+		ScannedHeight uint64                `json:"scanned_height"`
+		Txs           map[string]*TrackedTx `json:"txs"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s does not parse: %w", path, err)
+	}
+
+	var pending []*TrackedTx
+	for _, tracked := range state.Txs {
+		if tracked.Status == WalletTxPending {
+			pending = append(pending, tracked)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].SubmittedHeight < pending[j].SubmittedHeight })
+
+	fmt.Fprintf(out, "Scanned to block %d, %d of %d tracked transactions pending\n", state.ScannedHeight, len(pending), len(state.Txs))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nTXID\tTYPE\tSUBMITTED\tBROADCASTS\tLAST ERROR\n")
+	for _, tracked := range pending {
+		txType := "?"
+		if tracked.Tx != nil {
+			txType = tracked.Tx.TxType.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", tracked.TxID, txType, tracked.SubmittedHeight, tracked.Broadcasts, tracked.LastError)
+	}
+	return w.Flush()
+}
+
+// OpenBoltDBAdapterReadOnly opens an existing database for reading, waiting up to timeout
+// for a writer (such as a running node) to release it
+func OpenBoltDBAdapterReadOnly(dbPath string, timeout time.Duration) (*BoltDBAdapter, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", dbPath, err)
+	}
+	db, err := bolt.Open(dbPath, 0400, &bolt.Options{ReadOnly: true, Timeout: timeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked; stop the node first", dbPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt db: %w", err)
+	}
+	return &BoltDBAdapter{db: db, bucketName: []byte("default"), path: dbPath}, nil
+}
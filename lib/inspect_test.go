@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspectReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	genesis := bc.GetLatestBlock()
+
+	// A running node holds the database, so inspecting it is refused rather than blocking
+	if _, err := OpenBoltDBAdapterReadOnly(path+".db", 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("Expected an open database to be reported locked, got %v", err)
+	}
+	bc.Close()
+
+	digest := func() [32]byte {
+		a, _ := os.ReadFile(path + ".db")
+		b, _ := os.ReadFile(path + "_utxo.db")
+		return sha256.Sum256(append(a, b...))
+	}
+	before := digest()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		if err := runInspect(path, args, &out); err != nil {
+			t.Fatalf("inspect %v failed: %v", args, err)
+		}
+		return out.String()
+	}
+	if out := run(); !strings.Contains(out, "height 0, tip "+genesis.Hash) || !strings.Contains(out, blockPrefix) {
+		t.Errorf("Expected the summary to show the genesis tip and key counts, got:\n%s", out)
+	}
+	if out := run("blocks"); !strings.Contains(out, genesis.Hash) {
+		t.Errorf("Expected the genesis block listed, got:\n%s", out)
+	}
+	if out := run("block", genesis.Hash); !strings.Contains(out, "Height:   0") {
+		t.Errorf("Expected the block looked up by hash, got:\n%s", out)
+	}
+	if out := run("utxos"); !strings.Contains(out, "Unspent:") {
+		t.Errorf("Expected UTXO totals, got:\n%s", out)
+	}
+	if out := run("tokens"); !strings.Contains(out, "0 pools") {
+		t.Errorf("Expected the pool registry, got:\n%s", out)
+	}
+
+	var out bytes.Buffer
+	if err := runInspect(path, []string{"block", "99"}, &out); err == nil {
+		t.Error("Expected a missing block to be an error")
+	}
+	if err := runInspect(path, []string{"frobnicate"}, &out); err == nil {
+		t.Error("Expected an unknown command to be an error")
+	}
+	if digest() != before {
+		t.Error("Expected inspecting to leave the databases unchanged")
+	}
+}
@@ -17,6 +17,15 @@ type LiquidityPool struct {
 	FeePercent    uint64 `json:"fee_percent"`     // Fee in basis points (30 = 0.3%, 100 = 1%)
 	K             uint64 `json:"k"`               // Constant product (reserve_a * reserve_b)
 	CreatedAt     uint64 `json:"created_at"`      // Block height when created
+	FeesA         uint64 `json:"fees_a"`          // Swap fees taken in token A, all time
+	FeesB         uint64 `json:"fees_b"`          // Swap fees taken in token B, all time
+	FeeGrowthA    uint64 `json:"fee_growth_a"`    // Token A fees per LP token, times FeeGrowthScale
+	FeeGrowthB    uint64 `json:"fee_growth_b"`    // Token B fees per LP token, times FeeGrowthScale
+
+	// Price oracle: price of A in B (and B in A) times PriceScale, summed per block
+	PriceCumulativeA uint64 `json:"price_cumulative_a"`
+	PriceCumulativeB uint64 `json:"price_cumulative_b"`
+	PriceObservedAt  uint64 `json:"price_observed_at"` // Height the cumulatives run to
 }
 
 // CreatePoolData represents the data stored in a TX_CREATE_POOL transaction
@@ -139,3 +148,50 @@ func CalculateK(reserveA, reserveB uint64) uint64 {
 	// In production, might want to use big.Int
 	return reserveA * reserveB
 }
+
+// lpTokenSupply returns the LP tokens minted to a new pool's creator, rounded down to
+// whole tokens at 8 decimals so the LP token passes TotalSupply == MaxMint * 10^8
+// validation, and that MaxMint
+func lpTokenSupply(amountA, amountB uint64) (supply, maxMint uint64) {
+	const lpDecimals = 100000000
+	maxMint = CalculateLPTokens(amountA, amountB) / lpDecimals
+	if maxMint == 0 {
+		maxMint = 1 // Minimum 1
+	}
+	return maxMint * lpDecimals, maxMint
+}
+
+// quoteSwap returns the token and amount a swap receives at the pool's reserves
+func quoteSwap(pool *LiquidityPool, tokenIn string, amountIn uint64) (string, uint64, error) {
+	var tokenOut string
+	var reserveIn, reserveOut uint64
+	if tokenIn == pool.TokenA {
+		tokenOut, reserveIn, reserveOut = pool.TokenB, pool.ReserveA, pool.ReserveB
+	} else if tokenIn == pool.TokenB {
+		tokenOut, reserveIn, reserveOut = pool.TokenA, pool.ReserveB, pool.ReserveA
+	} else {
+		return "", 0, fmt.Errorf("token %s not in pool", tokenIn[:8])
+	}
+
+	// amountOut = (amountIn * (10000 - fee) * reserveOut) / ((reserveIn * 10000) + (amountIn * (10000 - fee)))
+	feeMultiplier := uint64(10000 - pool.FeePercent) // e.g., 9970 for 0.3% fee
+	numerator := amountIn * feeMultiplier * reserveOut
+	denominator := (reserveIn * 10000) + (amountIn * feeMultiplier)
+	return tokenOut, numerator / denominator, nil
+}
+
+// quoteAddLiquidity returns the LP tokens minted for a deposit: the smaller of its
+// shares of the two reserves, so the pool ratio is maintained
+func quoteAddLiquidity(pool *LiquidityPool, amountA, amountB uint64) uint64 {
+	ratioA := (amountA * pool.LPTokenSupply) / pool.ReserveA
+	ratioB := (amountB * pool.LPTokenSupply) / pool.ReserveB
+	if ratioA < ratioB {
+		return ratioA
+	}
+	return ratioB
+}
+
+// quoteRemoveLiquidity returns the reserves paid out for burning lpTokens
+func quoteRemoveLiquidity(pool *LiquidityPool, lpTokens uint64) (amountA, amountB uint64) {
+	return (lpTokens * pool.ReserveA) / pool.LPTokenSupply, (lpTokens * pool.ReserveB) / pool.LPTokenSupply
+}
@@ -59,8 +59,9 @@ func CreatePoolTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRe
 	fmt.Printf("[CreatePool] Filtered: tokenA=%d, tokenB=%d, shadow=%d\n",
 		len(availableTokenAUTXOs), len(availableTokenBUTXOs), len(availableShadowUTXOs))
 
-	// Calculate estimated fee first
-	estimatedFee := uint64(11500) // Will refine after selecting UTXOs
+	// Calculate estimated fee first; it includes the creation fee the pool must burn
+	creationFee := ActiveGenesis().PoolCreationRules().CreationFee
+	estimatedFee := uint64(11500) + creationFee // Will refine after selecting UTXOs
 
 	// Select UTXOs for token A
 	var selectedTokenAUTXOs []*UTXO
@@ -135,6 +136,7 @@ func CreatePoolTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRe
 	if estimatedFee < 11500 {
 		estimatedFee = 11500
 	}
+	estimatedFee += creationFee
 
 	// Select SHADOW UTXOs for fee (only if neither token is SHADOW)
 	var selectedShadowUTXOs []*UTXO
@@ -156,7 +158,7 @@ func CreatePoolTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRe
 	// If tokenA or tokenB is SHADOW, fee is already included in their selection
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeCreatePool)
+	txBuilder := NewTxBuilder(TxTypeCreatePool).SetRegistry(tokenRegistry)
 
 	// Add all inputs
 	for _, utxo := range selectedTokenAUTXOs {
@@ -170,7 +172,10 @@ func CreatePoolTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRe
 	}
 
 	// No outputs for locked tokens - they're locked in the pool
-	// Only create change outputs
+	// Only create the creation fee burn and change outputs
+	if creationFee > 0 {
+		txBuilder.AddCustomOutput(CreateBurnOutput(creationFee, genesisTokenID))
+	}
 
 	// Handle change based on which tokens are SHADOW
 	if tokenAIsShadow {
@@ -241,7 +246,7 @@ func CreatePoolTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRe
 }
 
 // CreateAddLiquidityTransaction creates a transaction that adds liquidity to an existing pool
-func CreateAddLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, poolRegistry *PoolRegistry,
+func CreateAddLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry, poolRegistry *PoolRegistry,
 	poolID string, amountA uint64, amountB uint64, minLPTokens uint64) (*Transaction, error) {
 
 	// Get the pool
@@ -382,7 +387,7 @@ func CreateAddLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeAddLiquidity)
+	txBuilder := NewTxBuilder(TxTypeAddLiquidity).SetRegistry(tokenRegistry)
 
 	// Add inputs
 	for _, utxo := range selectedTokenAUTXOs {
@@ -456,7 +461,7 @@ func CreateAddLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 }
 
 // CreateRemoveLiquidityTransaction creates a transaction that removes liquidity from a pool
-func CreateRemoveLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, poolRegistry *PoolRegistry,
+func CreateRemoveLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry, poolRegistry *PoolRegistry,
 	poolID string, lpTokens uint64, minAmountA uint64, minAmountB uint64) (*Transaction, error) {
 
 	// Get the pool
@@ -523,7 +528,7 @@ func CreateRemoveLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOSto
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeRemoveLiquidity)
+	txBuilder := NewTxBuilder(TxTypeRemoveLiquidity).SetRegistry(tokenRegistry)
 
 	// Add inputs
 	for _, utxo := range selectedLPUTXOs {
@@ -570,7 +575,7 @@ func CreateRemoveLiquidityTransaction(nodeWallet *NodeWallet, utxoStore *UTXOSto
 }
 
 // CreateSwapTransaction creates a transaction that swaps tokens through a liquidity pool
-func CreateSwapTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, poolRegistry *PoolRegistry,
+func CreateSwapTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry, poolRegistry *PoolRegistry,
 	poolID string, tokenIn string, amountIn uint64, minAmountOut uint64) (*Transaction, error) {
 
 	// Get the pool
@@ -642,7 +647,7 @@ func CreateSwapTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, poolReg
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeSwap)
+	txBuilder := NewTxBuilder(TxTypeSwap).SetRegistry(tokenRegistry)
 
 	// Add inputs
 	for _, utxo := range selectedTokenInUTXOs {
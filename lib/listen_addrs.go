@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// By default the API listens on every interface and P2P on every IPv4 and IPv6 interface.
+// api_listen_addr binds the API elsewhere, typically loopback behind a reverse proxy, and
+// p2p_listen_addrs replaces the P2P listen multiaddrs. localhost_only binds whatever isn't
+// given to loopback, and refuses given addresses that aren't loopback, so a node meant to
+// be private can't be exposed by one stray setting.
+
+// ResolveAPIListenAddr returns the host:port the API server binds. addr may be empty (all
+// interfaces), a host ("127.0.0.1", "::1", "localhost") listening on port, or host:port
+// ("[::1]:8080").
+func ResolveAPIListenAddr(addr string, port int, localhostOnly bool) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		if localhostOnly {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nil
+		}
+		return fmt.Sprintf(":%d", port), nil
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port: the whole address is the host, IPv6 possibly in brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		portStr = strconv.Itoa(port)
+	}
+	listenPort, err := strconv.Atoi(portStr)
+	if err != nil || listenPort < 1 || listenPort > 65535 {
+		return "", fmt.Errorf("invalid port in %q", addr)
+	}
+	if host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		return "", fmt.Errorf("%q is not an IP address or localhost", host)
+	}
+	if localhostOnly && !isLoopbackHost(host) {
+		return "", fmt.Errorf("localhost_only is set but %q is not a loopback address", addr)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(listenPort)), nil
+}
+
+// ResolveP2PListenAddrs returns the multiaddrs the libp2p host listens on. Empty addrs
+// means TCP on port over IPv4 and IPv6 on every interface, or on loopback only.
+func ResolveP2PListenAddrs(addrs []string, port int, localhostOnly bool) ([]multiaddr.Multiaddr, error) {
+	var given []string
+	for _, addr := range addrs {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			given = append(given, addr)
+		}
+	}
+	if len(given) == 0 {
+		if localhostOnly {
+			given = []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port), fmt.Sprintf("/ip6/::1/tcp/%d", port)}
+		} else {
+			given = []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port), fmt.Sprintf("/ip6/::/tcp/%d", port)}
+		}
+	}
+
+	listen := make([]multiaddr.Multiaddr, 0, len(given))
+	for _, addr := range given {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen multiaddr %q: %w", addr, err)
+		}
+		if _, err := manet.ToIP(maddr); err != nil {
+			return nil, fmt.Errorf("listen multiaddr %q must start with /ip4 or /ip6", addr)
+		}
+		if localhostOnly && !manet.IsIPLoopback(maddr) {
+			return nil, fmt.Errorf("localhost_only is set but %q is not a loopback address", addr)
+		}
+		listen = append(listen, maddr)
+	}
+	return listen, nil
+}
+
+// isLoopbackHost reports whether a listen host only accepts local connections
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackRemote reports whether a request's remote address is on this machine
+func isLoopbackRemote(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// apiURL is the URL a client on this machine reaches the API at
+func apiURL(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "http://" + listenAddr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
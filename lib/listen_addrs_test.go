@@ -0,0 +1,50 @@
+package lib
+
+import "testing"
+
+func TestResolveListenAddrs(t *testing.T) {
+	for _, c := range []struct {
+		addr          string
+		localhostOnly bool
+		want          string
+	}{
+		{"", false, ":8080"},
+		{"", true, "127.0.0.1:8080"},
+		{"127.0.0.1", false, "127.0.0.1:8080"},
+		{"[::1]:9090", true, "[::1]:9090"},
+		{"::1", false, "[::1]:8080"},
+		{"localhost:80", true, "localhost:80"},
+	} {
+		got, err := ResolveAPIListenAddr(c.addr, 8080, c.localhostOnly)
+		if err != nil || got != c.want {
+			t.Errorf("ResolveAPIListenAddr(%q, %v) = %q, %v; expected %q", c.addr, c.localhostOnly, got, err, c.want)
+		}
+	}
+	for _, addr := range []string{"0.0.0.0:0", "example.com:8080", "127.0.0.1:http"} {
+		if _, err := ResolveAPIListenAddr(addr, 8080, addr == "0.0.0.0:0"); err == nil {
+			t.Errorf("Expected %q to be refused", addr)
+		}
+	}
+	if _, err := ResolveAPIListenAddr("0.0.0.0", 8080, true); err == nil {
+		t.Error("Expected localhost_only to refuse a public API address")
+	}
+	if got := apiURL(":8080"); got != "http://localhost:8080" {
+		t.Errorf("Expected a wildcard bind to be reached through localhost, got %s", got)
+	}
+
+	// Dual-stack by default, loopback on both families with localhost_only
+	addrs, err := ResolveP2PListenAddrs(nil, 9000, false)
+	if err != nil || len(addrs) != 2 || addrs[0].String() != "/ip4/0.0.0.0/tcp/9000" || addrs[1].String() != "/ip6/::/tcp/9000" {
+		t.Fatalf("Expected IPv4 and IPv6 wildcard addresses, got %v, %v", addrs, err)
+	}
+	addrs, err = ResolveP2PListenAddrs([]string{" "}, 9000, true)
+	if err != nil || len(addrs) != 2 || addrs[0].String() != "/ip4/127.0.0.1/tcp/9000" || addrs[1].String() != "/ip6/::1/tcp/9000" {
+		t.Fatalf("Expected loopback addresses, got %v, %v", addrs, err)
+	}
+	if _, err := ResolveP2PListenAddrs([]string{"/ip6/::/tcp/9000"}, 9000, true); err == nil {
+		t.Error("Expected localhost_only to refuse a wildcard P2P address")
+	}
+	if _, err := ResolveP2PListenAddrs([]string{"/dns4/example.com/tcp/9000"}, 9000, false); err == nil {
+		t.Error("Expected a listen address without an IP to be refused")
+	}
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Swap fees stay in a pool's reserves, so providers earn them by owning a share of the
+// pool. Each pool also counts the fees it has taken and accumulates fee growth: fees per
+// LP token, scaled by FeeGrowthScale. Growth is recorded per block, so the fees earned by
+// an LP token output are its amount times the growth since the block that created it.
+
+const (
+	FeeGrowthScale     = 1_000_000_000_000 // Fee growth is fees per LP token times this
+	LPFeeGrowthPrefix  = "lpgrowth:"       // lpgrowth:{pool_id}:{height} -> feeGrowth after that block
+	feeBasisPointTotal = 10000             // Pool fees are in basis points
+)
+
+// feeGrowth is a pool's cumulative fee growth for each token
+type feeGrowth struct {
+	A uint64 `json:"a"`
+	B uint64 `json:"b"`
+}
+
+// mulDiv returns a * b / c without overflowing the intermediate product. The caller must
+// ensure the quotient fits in 64 bits.
+func mulDiv(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	if hi >= c {
+		return ^uint64(0)
+	}
+	quotient, _ := bits.Div64(hi, lo, c)
+	return quotient
+}
+
+// accrueSwapFee credits the fee taken from amountIn of tokenIn to the pool's providers
+func (pool *LiquidityPool) accrueSwapFee(tokenIn string, amountIn uint64) {
+	fee := mulDiv(amountIn, pool.FeePercent, feeBasisPointTotal)
+	if fee == 0 || pool.LPTokenSupply == 0 {
+		return
+	}
+	growth := mulDiv(fee, FeeGrowthScale, pool.LPTokenSupply)
+	if tokenIn == pool.TokenA {
+		pool.FeesA += fee
+		pool.FeeGrowthA += growth
+	} else {
+		pool.FeesB += fee
+		pool.FeeGrowthB += growth
+	}
+}
+
+// recordFeeGrowth stores the pool's fee growth as of height
+func (store *UTXOStore) recordFeeGrowth(pool *LiquidityPool, height uint64) error {
+	data, err := json.Marshal(feeGrowth{A: pool.FeeGrowthA, B: pool.FeeGrowthB})
+	if err != nil {
+		return fmt.Errorf("failed to marshal fee growth: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	key := fmt.Sprintf("%s%s:%020d", LPFeeGrowthPrefix, pool.PoolID, height)
+	if err := store.db.Set([]byte(key), data); err != nil {
+		return fmt.Errorf("failed to store fee growth: %w", err)
+	}
+	return nil
+}
+
+// feeGrowthAt returns the pool's fee growth after each of heights (ascending) in one pass
+// over its history
+func (store *UTXOStore) feeGrowthAt(poolID string, heights []uint64) ([]feeGrowth, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	prefix := LPFeeGrowthPrefix + poolID + ":"
+	iterator, err := store.db.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	growths := make([]feeGrowth, len(heights))
+	var current feeGrowth
+	i := 0
+	for ; iterator.Valid() && i < len(heights); iterator.Next() {
+		var height uint64
+		fmt.Sscanf(string(iterator.Key()[len(prefix):]), "%d", &height)
+		for i < len(heights) && heights[i] < height {
+			growths[i] = current
+			i++
+		}
+		if err := json.Unmarshal(iterator.Value(), &current); err != nil {
+			return nil, fmt.Errorf("corrupt fee growth record %s", iterator.Key())
+		}
+	}
+	for ; i < len(heights); i++ {
+		growths[i] = current
+	}
+	return growths, nil
+}
+
+// LPPosition is an address's share of a liquidity pool
+type LPPosition struct {
+	PoolID      string  `json:"pool_id"`
+	TokenA      string  `json:"token_a"`
+	TokenB      string  `json:"token_b"`
+	LPTokens    uint64  `json:"lp_tokens"`
+	Share       float64 `json:"share"`        // Fraction of the pool's LP supply
+	UnderlyingA uint64  `json:"underlying_a"` // Token A redeemable now, fees included
+	UnderlyingB uint64  `json:"underlying_b"`
+	FeesA       uint64  `json:"fees_a"` // Token A fees earned since the LP tokens were received
+	FeesB       uint64  `json:"fees_b"`
+}
+
+// LPPositions returns address's positions in every pool it holds LP tokens of
+func (bc *Blockchain) LPPositions(address Address) ([]*LPPosition, error) {
+	utxos, err := bc.utxoStore.GetUTXOsByAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UTXOs: %w", err)
+	}
+
+	// LP tokens are identified by their pool's ID
+	held := make(map[string][]*UTXO)
+	for _, utxo := range utxos {
+		if utxo.IsSpent || utxo.Output == nil {
+			continue
+		}
+		if _, err := bc.poolRegistry.GetPool(utxo.Output.TokenID); err == nil {
+			held[utxo.Output.TokenID] = append(held[utxo.Output.TokenID], utxo)
+		}
+	}
+
+	positions := make([]*LPPosition, 0, len(held))
+	for poolID, outputs := range held {
+		pool, err := bc.poolRegistry.GetPool(poolID)
+		if err != nil {
+			continue
+		}
+		sort.Slice(outputs, func(i, j int) bool { return outputs[i].BlockHeight < outputs[j].BlockHeight })
+		heights := make([]uint64, len(outputs))
+		for i, utxo := range outputs {
+			heights[i] = utxo.BlockHeight
+		}
+		growths, err := bc.utxoStore.feeGrowthAt(poolID, heights)
+		if err != nil {
+			return nil, err
+		}
+
+		position := &LPPosition{PoolID: poolID, TokenA: pool.TokenA, TokenB: pool.TokenB}
+		for i, utxo := range outputs {
+			amount := utxo.Output.Amount
+			position.LPTokens += amount
+			position.FeesA += mulDiv(amount, pool.FeeGrowthA-growths[i].A, FeeGrowthScale)
+			position.FeesB += mulDiv(amount, pool.FeeGrowthB-growths[i].B, FeeGrowthScale)
+		}
+		if pool.LPTokenSupply > 0 {
+			position.Share = float64(position.LPTokens) / float64(pool.LPTokenSupply)
+			position.UnderlyingA = mulDiv(position.LPTokens, pool.ReserveA, pool.LPTokenSupply)
+			position.UnderlyingB = mulDiv(position.LPTokens, pool.ReserveB, pool.LPTokenSupply)
+		}
+		positions = append(positions, position)
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].PoolID < positions[j].PoolID })
+	return positions, nil
+}
@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLPPositionFees(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	poolID := strings.Repeat("ef", 32)
+	pools := NewPoolRegistry()
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: strings.Repeat("ab", 32), TokenB: GetGenesisToken().TokenID,
+		ReserveA: 10_000, ReserveB: 20_000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+	bc := &Blockchain{utxoStore: store, poolRegistry: pools}
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	deposit := func(txID string, owner Address, amount, height uint64) {
+		output := CreateTokenOutput(owner, amount, poolID, "liquidity_pool", nil)
+		if err := store.AddUTXO(&UTXO{TxID: txID, Output: output, BlockHeight: height}); err != nil {
+			t.Fatalf("Failed to add LP UTXO: %v", err)
+		}
+	}
+	swap := func(tokenIn string, amountIn, height uint64) {
+		pool, _ := pools.GetPool(poolID)
+		pool.accrueSwapFee(tokenIn, amountIn)
+		if err := pools.UpdatePool(pool); err != nil {
+			t.Fatalf("Failed to update pool: %v", err)
+		}
+		if err := store.recordFeeGrowth(pool, height); err != nil {
+			t.Fatalf("Failed to record fee growth: %v", err)
+		}
+	}
+
+	// Alice holds 60% from block 1; a swap at block 3 pays 300 token A in fees; Bob
+	// receives 40% at block 5; a swap at block 6 pays 300 SHADOW in fees
+	deposit("alice-lp", alice.Address(), 600, 1)
+	swap(strings.Repeat("ab", 32), 100_000, 3)
+	deposit("bob-lp", bob.Address(), 400, 5)
+	swap(GetGenesisToken().TokenID, 100_000, 6)
+
+	pool, _ := pools.GetPool(poolID)
+	if pool.FeesA != 300 || pool.FeesB != 300 {
+		t.Fatalf("Expected 300/300 fees taken, got %d/%d", pool.FeesA, pool.FeesB)
+	}
+
+	positions, err := bc.LPPositions(alice.Address())
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("Expected one position, got %v (%v)", positions, err)
+	}
+	got := positions[0]
+	if got.LPTokens != 600 || got.Share != 0.6 || got.UnderlyingA != 6000 || got.UnderlyingB != 12_000 {
+		t.Errorf("Unexpected position: %+v", got)
+	}
+	if got.FeesA != 180 || got.FeesB != 180 {
+		t.Errorf("Alice should earn 60%% of both fees, got %d/%d", got.FeesA, got.FeesB)
+	}
+
+	// Bob only earns fees from after his deposit
+	positions, _ = bc.LPPositions(bob.Address())
+	if len(positions) != 1 || positions[0].FeesA != 0 || positions[0].FeesB != 120 {
+		t.Errorf("Bob should earn only the later SHADOW fees, got %+v", positions)
+	}
+}
@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The memo index maps the memo of each confirmed send to its transaction, so a merchant
+// embedding an order ID in the memo can find the payment. Memos are indexed by their
+// sha256, which keeps keys short and free of separators. The index is optional
+// (memo_index) and only covers blocks applied while it was enabled; --reindex fills it
+// in for the whole chain.
+
+// MemoPrefix is the key prefix of the memo index
+const MemoPrefix = "memo:" // memo:{sha256(memo)}:{inverted height}:{txid} -> ""
+
+// MaxMemoSearchResults caps the transactions returned for one memo
+const MaxMemoSearchResults = 100
+
+// MemoMatch is a confirmed transaction carrying a searched memo
+type MemoMatch struct {
+	TxID   string `json:"tx_id"`
+	Height uint64 `json:"height"`
+}
+
+// memoHash is the index key component for a memo
+func memoHash(memo []byte) string {
+	sum := sha256.Sum256(memo)
+	return hex.EncodeToString(sum[:])
+}
+
+// txMemo returns the memo of a transaction, or nil if it carries none. Only sends carry
+// memos; other types use Data for their payloads.
+func txMemo(tx *Transaction) []byte {
+	if tx.TxType != TxTypeSend || len(tx.Data) == 0 {
+		return nil
+	}
+	return tx.Data
+}
+
+// SetMemoIndex turns indexing of transaction memos on or off
+func (store *UTXOStore) SetMemoIndex(enabled bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.memoIndex = enabled
+}
+
+// MemoIndexEnabled reports whether memos are being indexed
+func (store *UTXOStore) MemoIndexEnabled() bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.memoIndex
+}
+
+// indexMemoLocked adds a transaction to the memo index (caller holds store.mutex)
+func (store *UTXOStore) indexMemoLocked(tx *Transaction, txID string, height int64) error {
+	memo := txMemo(tx)
+	if !store.memoIndex || memo == nil {
+		return nil
+	}
+	key := fmt.Sprintf("%s%s:%020d:%s", MemoPrefix, memoHash(memo), int64(999999999999999999)-height, txID)
+	if err := store.db.Set([]byte(key), []byte("")); err != nil {
+		return fmt.Errorf("failed to store memo index: %w", err)
+	}
+	return nil
+}
+
+// SearchMemo returns confirmed transactions whose memo is exactly memo, newest first
+func (store *UTXOStore) SearchMemo(memo string, limit int) ([]MemoMatch, error) {
+	if limit <= 0 || limit > MaxMemoSearchResults {
+		limit = MaxMemoSearchResults
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	prefix := MemoPrefix + memoHash([]byte(memo)) + ":"
+	iterator, err := store.db.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	matches := []MemoMatch{}
+	for ; iterator.Valid() && len(matches) < limit; iterator.Next() {
+		key := string(iterator.Key())
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		parts := strings.SplitN(key[len(prefix):], ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		inverted, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		matches = append(matches, MemoMatch{TxID: parts[1], Height: uint64(999999999999999999 - inverted)})
+	}
+	return matches, nil
+}
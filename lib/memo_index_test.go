@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoIndexSearch(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to create UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	merchant, _ := GenerateKeyPair()
+	send := func(memo string, amount uint64, txType TxType) *Transaction {
+		tx := NewTxBuilder(txType).AddCustomOutput(CreateShadowOutput(merchant.Address(), amount)).Build()
+		tx.Data = []byte(memo)
+		return tx
+	}
+
+	// Nothing is indexed while the index is off
+	if err := store.StoreTransaction(send("order-1234", 1, TxTypeSend), 3); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	store.SetMemoIndex(true)
+
+	first, second := send("order-1234", 2, TxTypeSend), send("order-1234", 3, TxTypeSend)
+	for height, tx := range map[int64]*Transaction{
+		5: first,
+		7: second,
+		8: send("order-9999", 4, TxTypeSend),
+		9: send("order-1234", 5, TxTypeMintToken), // Not a memo: other types carry payloads in Data
+	} {
+		if err := store.StoreTransaction(tx, height); err != nil {
+			t.Fatalf("Failed to store transaction: %v", err)
+		}
+	}
+
+	matches, err := store.SearchMemo("order-1234", 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	firstID, _ := first.ID()
+	secondID, _ := second.ID()
+	if len(matches) != 2 || matches[0].TxID != secondID || matches[0].Height != 7 || matches[1].TxID != firstID {
+		t.Fatalf("Expected the two order-1234 sends newest first, got %+v", matches)
+	}
+	if matches, _ := store.SearchMemo("order-1234", 1); len(matches) != 1 {
+		t.Errorf("Expected the limit to cap results, got %d", len(matches))
+	}
+	if matches, _ := store.SearchMemo("order-12", 0); len(matches) != 0 {
+		t.Errorf("Expected only exact memo matches, got %d", len(matches))
+	}
+}
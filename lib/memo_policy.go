@@ -0,0 +1,166 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Exchanges often receive deposits for many customers at one address and tell them
+// apart by the memo (destination tag). Address book entries can require a memo; the
+// memo monitor watches the mempool and new blocks for payments to those addresses
+// without one and publishes a memo_missing event for each, first when the payment is
+// pending and again when it confirms, so the deposit can be held for manual review.
+
+const MemoCheckInterval = 2 * time.Second // How often the monitor checks the mempool and chain
+
+// MemoMissing is a payment to a memo-required address that carries no memo
+type MemoMissing struct {
+	TxID      string `json:"tx_id"`
+	Address   string `json:"address"`
+	Label     string `json:"label"` // Address book entry requiring the memo
+	TokenID   string `json:"token_id"`
+	Amount    uint64 `json:"amount"` // Total paid to the address in this token
+	Confirmed bool   `json:"confirmed"`
+	Height    uint64 `json:"height,omitempty"` // Block height once confirmed
+}
+
+// missingMemos returns the payments in tx to addresses in required (address -> label)
+// if tx has no memo, one per address and token
+func missingMemos(tx *Transaction, txID string, required map[Address]string) []MemoMissing {
+	if len(required) == 0 || txMemo(tx) != nil {
+		return nil
+	}
+
+	type payment struct {
+		addr    Address
+		tokenID string
+	}
+	paid := make(map[payment]uint64)
+	for _, output := range tx.Outputs {
+		if _, ok := required[output.Address]; ok {
+			paid[payment{output.Address, output.TokenID}] += output.Amount
+		}
+	}
+
+	var missing []MemoMissing
+	for p, amount := range paid {
+		missing = append(missing, MemoMissing{
+			TxID:    txID,
+			Address: p.addr.Display(),
+			Label:   required[p.addr],
+			TokenID: p.tokenID,
+			Amount:  amount,
+		})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Address != missing[j].Address {
+			return missing[i].Address < missing[j].Address
+		}
+		return missing[i].TokenID < missing[j].TokenID
+	})
+	return missing
+}
+
+// MemoMonitor publishes memo_missing events for payments to memo-required addresses
+type MemoMonitor struct {
+	chain   *Blockchain
+	mempool *Mempool
+	book    *AddressBook
+	events  *EventHub
+
+	height  uint64          // Next block to check
+	pending map[string]bool // Mempool transactions already checked
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewMemoMonitor starts checking transactions from the current chain tip on
+func NewMemoMonitor(chain *Blockchain, mempool *Mempool, book *AddressBook, events *EventHub) *MemoMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &MemoMonitor{
+		chain:   chain,
+		mempool: mempool,
+		book:    book,
+		events:  events,
+		height:  chain.GetHeight(),
+		pending: make(map[string]bool),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go m.loop()
+	return m
+}
+
+// loop checks for new transactions until the monitor is closed
+func (m *MemoMonitor) loop() {
+	ticker := time.NewTicker(MemoCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check flags payments without memos in mempool transactions not seen before and in
+// blocks added since the last check
+func (m *MemoMonitor) check() {
+	required := m.book.MemoRequired()
+
+	seen := make(map[string]bool)
+	for _, tx := range m.mempool.GetTransactions() {
+		txID, err := tx.ID()
+		if err != nil {
+			continue
+		}
+		seen[txID] = true
+		if !m.pending[txID] {
+			m.publish(missingMemos(tx, txID, required), 0)
+		}
+	}
+	m.pending = seen
+
+	tip := m.chain.GetHeight()
+	if m.height > tip {
+		m.height = tip // The chain was rolled back
+	}
+	utxoStore := m.chain.GetUTXOStore()
+	for ; m.height < tip; m.height++ {
+		block := m.chain.GetBlock(m.height)
+		if block == nil || len(required) == 0 {
+			continue
+		}
+		for _, txID := range block.Transactions {
+			tx, err := utxoStore.GetTransaction(txID)
+			if err != nil || tx == nil {
+				continue
+			}
+			m.publish(missingMemos(tx, txID, required), block.Index)
+		}
+	}
+}
+
+// publish announces missing memos, confirmed at height unless it is 0
+func (m *MemoMonitor) publish(missing []MemoMissing, height uint64) {
+	status := "pending"
+	if height > 0 {
+		status = "confirmed"
+	}
+	for _, mm := range missing {
+		mm.Confirmed, mm.Height = height > 0, height
+		fmt.Printf("[Memo] ⚠️  %s transaction %s pays %s without a memo\n", status, mm.TxID[:16], mm.Label)
+		m.events.Publish(EventMemoMissing, mm)
+	}
+}
+
+// Close stops the monitor
+func (m *MemoMonitor) Close() {
+	m.cancel()
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoMonitor(t *testing.T) {
+	dir := t.TempDir()
+	bc, err := NewBlockchain(filepath.Join(dir, "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	book, err := NewAddressBook(filepath.Join(dir, "addressbook.db"))
+	if err != nil {
+		t.Fatalf("Failed to open address book: %v", err)
+	}
+	defer book.Close()
+
+	exchange, _ := GenerateKeyPair()
+	other, _ := GenerateKeyPair()
+	if _, err := book.Set("exchange-deposits", exchange.Address(), "customer deposits"); err != nil {
+		t.Fatalf("Failed to set label: %v", err)
+	}
+	if _, err := book.SetRequireMemo("exchange-deposits", true); err != nil {
+		t.Fatalf("Failed to require memo: %v", err)
+	}
+	// Updating the label keeps the requirement
+	if entry, _ := book.Set("exchange-deposits", exchange.Address(), "hot deposits"); !entry.RequireMemo {
+		t.Fatal("Expected the memo requirement to survive an update")
+	}
+
+	untagged := NewTxBuilder(TxTypeSend).AddInput("memo-test-funding", 0).
+		AddCustomOutput(CreateShadowOutput(exchange.Address(), 70)).
+		AddCustomOutput(CreateShadowOutput(exchange.Address(), 30)).
+		AddCustomOutput(CreateShadowOutput(other.Address(), 5)).Build()
+	tagged := NewTxBuilder(TxTypeSend).AddInput("memo-test-funding", 1).
+		AddCustomOutput(CreateShadowOutput(exchange.Address(), 40)).Build()
+	tagged.Data = []byte("customer-1234")
+	elsewhere := NewTxBuilder(TxTypeSend).AddInput("memo-test-funding", 2).
+		AddCustomOutput(CreateShadowOutput(other.Address(), 40)).Build()
+	untagged.Sign(other)
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	for _, tx := range []*Transaction{untagged, tagged, elsewhere} {
+		txID, _ := tx.ID()
+		mp.entries[txID] = &MempoolEntry{Tx: tx}
+	}
+
+	hub := NewEventHub()
+	events, cancel := hub.Subscribe([]string{EventMemoMissing})
+	defer cancel()
+	m := &MemoMonitor{chain: bc, mempool: mp, book: book, events: hub, height: bc.GetHeight(), pending: make(map[string]bool)}
+
+	// Only the untagged payment is flagged, once, with its outputs to the address summed
+	m.check()
+	m.check()
+	untaggedID, _ := untagged.ID()
+	if len(events) != 1 {
+		t.Fatalf("Expected one memo_missing event, got %d", len(events))
+	}
+	mm := (<-events).Data.(MemoMissing)
+	if mm.TxID != untaggedID || mm.Amount != 100 || mm.Label != "exchange-deposits" || mm.Confirmed {
+		t.Fatalf("Unexpected pending event: %+v", mm)
+	}
+
+	// It is flagged again when it confirms
+	funding := &UTXO{TxID: "memo-test-funding", OutputIndex: 0, Output: CreateShadowOutput(other.Address(), 105)}
+	if err := bc.GetUTXOStore().AddUTXO(funding); err != nil {
+		t.Fatalf("Failed to add funding: %v", err)
+	}
+	if err := bc.GetUTXOStore().StoreTransaction(untagged, 1); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	prev := bc.GetLatestBlock()
+	block := &Block{Index: 1, Timestamp: prev.Timestamp + 1, Transactions: []string{untaggedID}, PreviousHash: prev.Hash, Proposer: "memo-test-proposer"}
+	block.Hash = bc.calculateBlockHash(block)
+	if err := bc.AddBlock(block, nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	delete(mp.entries, untaggedID)
+	m.check()
+	if len(events) != 1 {
+		t.Fatalf("Expected a confirmed memo_missing event, got %d", len(events))
+	}
+	if mm := (<-events).Data.(MemoMissing); !mm.Confirmed || mm.Height != 1 {
+		t.Fatalf("Unexpected confirmed event: %+v", mm)
+	}
+
+	// Lifting the requirement stops the flags
+	book.SetRequireMemo("exchange-deposits", false)
+	if got := missingMemos(untagged, untaggedID, book.MemoRequired()); len(got) != 0 {
+		t.Fatalf("Expected no flags without a requirement, got %+v", got)
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 const (
@@ -22,26 +24,47 @@ type MempoolEntry struct {
 	AddedAtBlock   uint64    // Block height when tx was added
 	AddedTimestamp time.Time // Timestamp when tx was added
 	SizeBytes      int       // Approximate size in bytes
+	Fee            uint64    // Paid fee when admitted, for replace-by-fee
+	Local          bool      // Submitted through this node; evicted only as a last resort
 }
 
 // Mempool represents a shared transaction mempool
 type Mempool struct {
-	entries       map[string]*MempoolEntry // txID -> entry
-	txLock        sync.RWMutex
-	pubsub        *pubsub.PubSub
-	topic         *pubsub.Topic
-	sub           *pubsub.Subscription
-	ctx           context.Context
-	cancel        context.CancelFunc
-	expiryBlocks  int // Transactions expire after this many blocks
-	maxSizeBytes  int // Maximum mempool size in bytes
-	currentHeight uint64
+	entries         map[string]*MempoolEntry // txID -> entry
+	txLock          sync.RWMutex
+	host            host.Host
+	pubsub          *pubsub.PubSub
+	topic           *pubsub.Topic
+	sub             *pubsub.Subscription
+	ctx             context.Context
+	cancel          context.CancelFunc
+	expiryBlocks    int // Transactions expire after this many blocks
+	maxSizeBytes    int // Maximum mempool size in bytes
+	currentHeight   uint64
+	relay           *txRelay         // Announcement-based relay state
+	minRelayFee     uint64           // Minimum paid fee to accept and relay a tx, 0 = no floor
+	minRelayFeeRate uint64           // Minimum paid fee per 1000 weight to accept and relay a tx, 0 = no floor
+	relayDust       uint64           // Smallest SHADOW output relayed, on top of the network's dust threshold
+	utxoStore       *UTXOStore       // For pricing inputs against minRelayFee
+	acceptTokenFees bool             // Count token-denominated fees at their pool price
+	poolRegistry    *PoolRegistry    // For pricing token fees
+	tokenRegistry   *TokenRegistry   // For checking token mint versions
+	walletTxs       *WalletTxTracker // Rebroadcasts locally submitted transactions until they confirm
+	leader          func() peer.ID   // Current consensus leader, sent new local transactions (nil: gossip only)
+	admission       *admissionQueue  // Transactions waiting for signature verification
+	evictions       mempoolEvictionStats
+	conflicts       conflictLog       // Transactions that lost a double-spend
+	events          *EventHub         // Where conflicts are announced (nil: nowhere)
+	evidence        *EvidenceStore    // Where rejected gossip is recorded (nil: nowhere)
+	packages        map[string]string // Package member txID -> package ID, for block templates
 }
 
 // MempoolMessage is the gossip message format
 type MempoolMessage struct {
-	Type        string       `json:"type"` // "add_tx"
-	Transaction *Transaction `json:"transaction"`
+	Type        string       `json:"type"` // "inv" (tx IDs only) or legacy "add_tx" (full body)
+	Transaction *Transaction `json:"transaction,omitempty"`
+	TxIDs       []string     `json:"tx_ids,omitempty"`
+	ChainID     string       `json:"chain_id"` // Messages from other networks are dropped
 	Timestamp   int64        `json:"timestamp"`
 }
 
@@ -50,7 +73,7 @@ func NewMempool(h host.Host, ps *pubsub.PubSub, expiryBlocks int, maxSizeMB int)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Join the mempool topic
-	topic, err := ps.Join(MempoolTopic)
+	topic, err := ps.Join(ActiveGenesis().Topic(MempoolTopic))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to join topic: %w", err)
@@ -65,6 +88,7 @@ func NewMempool(h host.Host, ps *pubsub.PubSub, expiryBlocks int, maxSizeMB int)
 
 	mp := &Mempool{
 		entries:       make(map[string]*MempoolEntry),
+		host:          h,
 		pubsub:        ps,
 		topic:         topic,
 		sub:           sub,
@@ -73,10 +97,26 @@ func NewMempool(h host.Host, ps *pubsub.PubSub, expiryBlocks int, maxSizeMB int)
 		expiryBlocks:  expiryBlocks,
 		maxSizeBytes:  maxSizeMB * 1024 * 1024, // Convert MB to bytes
 		currentHeight: 0,
-	}
+		relay:         newTxRelay(),
+		admission:     newAdmissionQueue(DefaultAdmissionQueueSize),
+	}
+
+	// Serve transaction bodies on request and announce our mempool to new peers
+	h.SetStreamHandler(TxRelayProtocolID, mp.handleTxRequest)
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			go mp.announceToPeer(c.RemotePeer())
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			if n.Connectedness(c.RemotePeer()) != network.Connected {
+				mp.relay.dropPeer(c.RemotePeer())
+			}
+		},
+	})
 
 	// Start listening for mempool messages
 	go mp.listenForMessages()
+	mp.startAdmissionWorkers()
 
 	fmt.Printf("[Mempool] Created: expiry=%d blocks, maxSize=%dMB\n", expiryBlocks, maxSizeMB)
 	return mp, nil
@@ -101,88 +141,293 @@ func (mp *Mempool) listenForMessages() {
 			fmt.Printf("[Mempool] Failed to decode message: %v\n", err)
 			continue
 		}
+		if !ActiveGenesis().AcceptsChainID(mempoolMsg.ChainID) {
+			fmt.Printf("[Mempool] ⚠️  Dropping message for chain %q\n", mempoolMsg.ChainID)
+			continue
+		}
 
 		// Process based on type
 		switch mempoolMsg.Type {
+		case "inv":
+			// Fetch bodies from whoever relayed or published the announcement
+			candidates := []peer.ID{msg.ReceivedFrom}
+			if from := msg.GetFrom(); from != msg.ReceivedFrom {
+				candidates = append(candidates, from)
+			}
+			go mp.handleInv(mempoolMsg.TxIDs, candidates)
+
 		case "add_tx":
+			// Full-body gossip from nodes that predate announcement relay
 			if mempoolMsg.Transaction != nil {
-				// Get transaction ID
-				txID, err := mempoolMsg.Transaction.ID()
-				if err != nil {
-					fmt.Printf("[Mempool] Failed to get transaction ID: %v\n", err)
-					continue
-				}
+				mp.addGossipTransaction(mempoolMsg.Transaction, msg.ReceivedFrom)
+			}
+		}
+	}
+}
 
-				// Verify signature before adding to mempool
-				if !mp.verifyTransaction(mempoolMsg.Transaction) {
-					fmt.Printf("[Mempool] Rejected invalid transaction: %s\n", txID)
-					continue
-				}
+// addGossipTransaction queues a transaction received from the network, from peer from,
+// for verification
+func (mp *Mempool) addGossipTransaction(tx *Transaction, from peer.ID) {
+	// Get transaction ID
+	txID, err := tx.ID()
+	if err != nil {
+		fmt.Printf("[Mempool] Failed to get transaction ID: %v\n", err)
+		return
+	}
 
-				mp.txLock.Lock()
-				// Only add if we don't already have it (avoid duplicates)
-				if _, exists := mp.entries[txID]; !exists {
-					txSize := mp.estimateTxSize(mempoolMsg.Transaction)
-					entry := &MempoolEntry{
-						Tx:             mempoolMsg.Transaction,
-						AddedAtBlock:   mp.currentHeight,
-						AddedTimestamp: time.Now(),
-						SizeBytes:      txSize,
-					}
-					mp.entries[txID] = entry
-					fmt.Printf("[Mempool] Added transaction from gossip: %s (total: %d)\n",
-						txID, len(mp.entries))
+	// Dedupe across mempool removal so mined or rejected txs aren't processed again
+	if !mp.relay.markSeen(txID) {
+		return
+	}
 
-					// Check if we need to evict old transactions
-					mp.enforceMemoryLimitLocked()
-				}
-				mp.txLock.Unlock()
-			}
-		}
+	if err := CheckTxVersion(tx); err != nil {
+		fmt.Printf("[Mempool] Rejected transaction %s: %v\n", txID[:16], err)
+		mp.recordRejected(tx, from, err)
+		return
+	}
+	if err := CheckTxSize(tx); err != nil {
+		fmt.Printf("[Mempool] Rejected transaction %s: %v\n", txID[:16], err)
+		mp.recordRejected(tx, from, err)
+		return
+	}
+	if err := CheckTxWeight(tx); err != nil {
+		fmt.Printf("[Mempool] Rejected transaction %s: %v\n", txID[:16], err)
+		mp.recordRejected(tx, from, err)
+		return
+	}
+	if err := mp.checkDust(tx); err != nil {
+		fmt.Printf("[Mempool] Rejected transaction %s: %v\n", txID[:16], err)
+		mp.recordRejected(tx, from, err)
+		return
+	}
+
+	if !mp.admission.enqueue(admissionJob{tx: tx, txID: txID, from: from}) {
+		// Forget it so a later announcement is fetched again
+		mp.relay.unmarkSeen(txID)
+		fmt.Printf("[Mempool] Dropped transaction %s: verification queue full\n", txID[:16])
 	}
 }
 
-// verifyTransaction checks if a transaction has a valid signature
-func (mp *Mempool) verifyTransaction(tx *Transaction) bool {
+// admitGossip checks a verified transaction from the network against the mempool and
+// chain state and adds it
+func (mp *Mempool) admitGossip(tx *Transaction, txID string) error {
+	if err := mp.checkTTL(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkVesting(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkTokenVersions(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkTokenMint(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkCollateral(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkPoolCreation(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkInputSignatures(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkSpendConditions(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkHTLCSpends(tx); err != nil {
+		return err
+	}
+
+	if err := mp.checkWeightFee(tx); err != nil {
+		return err
+	}
+
+	if err := mp.meetsRelayFee(tx); err != nil {
+		mp.relay.mu.Lock()
+		mp.relay.stats.belowFee++
+		mp.relay.mu.Unlock()
+		return err
+	}
+
+	fee := mp.feeOf(tx)
+
+	mp.txLock.Lock()
+
+	// Only add if we don't already have it (avoid duplicates)
+	if _, exists := mp.entries[txID]; exists {
+		mp.txLock.Unlock()
+		return nil
+	}
+
+	// Conflicting spends: the higher-fee transaction wins on every node
+	replaced, err := mp.replaceConflictsLocked(tx, fee)
+	if err != nil {
+		mp.txLock.Unlock()
+		return err
+	}
+
+	txSize := mp.estimateTxSize(tx)
+	entry := &MempoolEntry{
+		Tx:             tx,
+		AddedAtBlock:   mp.currentHeight,
+		AddedTimestamp: time.Now(),
+		SizeBytes:      txSize,
+		Fee:            fee,
+	}
+	mp.entries[txID] = entry
+	fmt.Printf("[Mempool] Added transaction from gossip: %s (total: %d)\n",
+		txID, len(mp.entries))
+
+	// Over the size cap the lowest fee rates go, possibly this one
+	err = mp.enforceMemoryLimitLocked(txID)
+	mp.txLock.Unlock()
+
+	mp.recordReplaced(txID, replaced)
+	return err
+}
+
+// verifyTransaction checks a transaction's structure and signatures
+func (mp *Mempool) verifyTransaction(tx *Transaction) error {
 	txID, _ := tx.ID()
 
 	// For coinbase transactions, no signature verification needed
 	if tx.TxType == TxTypeCoinbase {
 		fmt.Printf("[Mempool] Accepting coinbase transaction %s\n", txID[:16])
-		return true
+		return nil
 	}
 
 	fmt.Printf("[Mempool] Verifying transaction %s (type: %s)\n", txID[:16], tx.TxType.String())
 
 	// Check if transaction is signed
-	if len(tx.Signature) == 0 {
-		fmt.Printf("[Mempool] Transaction %s has no signature\n", txID[:16])
-		return false
+	if len(tx.Signature) == 0 && !tx.HasInputSignatures() && !tx.fullyWitnessed() {
+		return fmt.Errorf("transaction is not signed")
 	}
 
 	// Verify the signature using existing ValidateTransaction function
 	if err := ValidateTransaction(tx); err != nil {
-		fmt.Printf("[Mempool] Transaction %s failed validation: %v\n", txID[:16], err)
-		return false
+		return fmt.Errorf("invalid transaction: %w", err)
 	}
 
 	fmt.Printf("[Mempool] Transaction %s validation passed\n", txID[:16])
-	return true
+	return nil
 }
 
-// AddTransaction adds a transaction to the mempool and gossips it
-func (mp *Mempool) AddTransaction(tx *Transaction) error {
+// sanityCheck runs the quick admission checks that need no signature verification.
+// Returns the transaction ID.
+func (mp *Mempool) sanityCheck(tx *Transaction) (string, error) {
 	// Get transaction ID
 	txID, err := tx.ID()
 	if err != nil {
-		return fmt.Errorf("failed to get transaction ID: %w", err)
+		return "", fmt.Errorf("failed to get transaction ID: %w", err)
+	}
+
+	// Signatures must be bound to this chain
+	if err := CheckTxVersion(tx); err != nil {
+		return txID, err
+	}
+
+	// Oversized transactions can never be mined; refuse them before verifying
+	if err := CheckTxSize(tx); err != nil {
+		return txID, err
+	}
+	if err := CheckTxWeight(tx); err != nil {
+		return txID, err
+	}
+	if err := mp.checkDust(tx); err != nil {
+		return txID, err
+	}
+
+	// Expired transactions can never be mined
+	if err := mp.checkTTL(tx); err != nil {
+		return txID, err
+	}
+
+	// Locked vesting funds must be carried forward
+	if err := mp.checkVesting(tx); err != nil {
+		return txID, err
+	}
+
+	// Token outputs must match the mint version of their registry entry
+	if err := mp.checkTokenVersions(tx); err != nil {
+		return txID, err
+	}
+
+	// Mints must lock SHADOW equal to the supply they create
+	if err := mp.checkTokenMint(tx); err != nil {
+		return txID, err
 	}
 
-	// Verify signature first
-	if !mp.verifyTransaction(tx) {
-		return fmt.Errorf("invalid transaction signature")
+	// Collateral positions only change within their token's terms
+	if err := mp.checkCollateral(tx); err != nil {
+		return txID, err
 	}
 
+	// New pools must meet the network's liquidity minimum and burn the creation fee
+	if err := mp.checkPoolCreation(tx); err != nil {
+		return txID, err
+	}
+
+	// Inputs signed on their own must be signed by their owners
+	if err := mp.checkInputSignatures(tx); err != nil {
+		return txID, err
+	}
+
+	// Conditioned outputs must be spent with a witness meeting their condition
+	if err := mp.checkSpendConditions(tx); err != nil {
+		return txID, err
+	}
+
+	// HTLCs are only spent by their claim or refund
+	if err := mp.checkHTLCSpends(tx); err != nil {
+		return txID, err
+	}
+
+	// Every transaction pays at least the network's minimum fee for its weight
+	if err := mp.checkWeightFee(tx); err != nil {
+		return txID, err
+	}
+
+	// Transactions below the relay floor would never propagate
+	if err := mp.meetsRelayFee(tx); err != nil {
+		return txID, err
+	}
+
+	if mp.HasTransaction(txID) {
+		return txID, fmt.Errorf("transaction already in mempool")
+	}
+	return txID, nil
+}
+
+// AddTransaction adds a transaction to the mempool and gossips it, verifying its
+// signatures on the caller's goroutine. API submissions use SubmitTransaction instead.
+func (mp *Mempool) AddTransaction(tx *Transaction) error {
+	txID, err := mp.sanityCheck(tx)
+	if err != nil {
+		return err
+	}
+
+	// Verify signature
+	if err := mp.verifyTransaction(tx); err != nil {
+		return err
+	}
+
+	return mp.insertLocal(tx, txID)
+}
+
+// insertLocal adds a verified transaction submitted through this node, tracks it for
+// rebroadcast and announces it
+func (mp *Mempool) insertLocal(tx *Transaction, txID string) error {
+	fee := mp.feeOf(tx)
+
 	mp.txLock.Lock()
 	// Check if we already have it
 	if _, exists := mp.entries[txID]; exists {
@@ -197,51 +442,50 @@ func (mp *Mempool) AddTransaction(tx *Transaction) error {
 		return fmt.Errorf("transaction too large: %d bytes (max %d KB)", txSize, MaxTransactionSize/1024)
 	}
 
-	// Check for double-spend: reject if any input is already used by pending tx
-	for _, input := range tx.Inputs {
-		inputKey := fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)
-		for existingTxID, entry := range mp.entries {
-			for _, existingInput := range entry.Tx.Inputs {
-				existingKey := fmt.Sprintf("%s:%d", existingInput.PrevTxID, existingInput.OutputIndex)
-				if inputKey == existingKey {
-					mp.txLock.Unlock()
-					return fmt.Errorf("double-spend detected: input %s already used by pending tx %s", inputKey[:16], existingTxID[:16])
-				}
-			}
-		}
+	// Double-spend: only a replacement paying more than the pending spenders is accepted
+	replaced, err := mp.replaceConflictsLocked(tx, fee)
+	if err != nil {
+		mp.txLock.Unlock()
+		return err
 	}
 	entry := &MempoolEntry{
 		Tx:             tx,
 		AddedAtBlock:   mp.currentHeight,
 		AddedTimestamp: time.Now(),
 		SizeBytes:      txSize,
+		Fee:            fee,
+		Local:          true,
 	}
 	mp.entries[txID] = entry
 	txCount := len(mp.entries)
 
-	// Check if we need to evict old transactions
-	mp.enforceMemoryLimitLocked()
+	// Over the size cap the lowest fee rates go; local transactions only as a last resort
+	if err := mp.enforceMemoryLimitLocked(txID); err != nil {
+		mp.txLock.Unlock()
+		mp.recordReplaced(txID, replaced)
+		return err
+	}
 	mp.txLock.Unlock()
 
 	fmt.Printf("[Mempool] Added transaction locally: %s (total: %d)\n", txID, txCount)
+	mp.recordReplaced(txID, replaced)
 
-	// Gossip to other nodes
-	msg := MempoolMessage{
-		Type:        "add_tx",
-		Transaction: tx,
-		Timestamp:   time.Now().Unix(),
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+	// Track it so it is rebroadcast if the announcement never reaches a miner
+	if mp.walletTxs != nil {
+		if err := mp.walletTxs.Track(tx); err != nil {
+			fmt.Printf("[Mempool] Warning: failed to track local transaction %s: %v\n", txID, err)
+		}
 	}
 
-	if err := mp.topic.Publish(mp.ctx, data); err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+	// Announce the ID; peers fetch the body only if they don't have it. The leader gets
+	// the body directly so it doesn't wait on gossip.
+	mp.relay.markSeen(txID)
+	go mp.forwardToLeader(tx)
+	if err := mp.announce([]string{txID}); err != nil {
+		return err
 	}
 
-	fmt.Printf("[Mempool] Gossiped transaction to network: %s\n", txID)
+	fmt.Printf("[Mempool] Announced transaction to network: %s\n", txID)
 	return nil
 }
 
@@ -287,6 +531,29 @@ func (mp *Mempool) RemoveTransaction(txID string) {
 	fmt.Printf("[Mempool] Removed transaction: %s (remaining: %d)\n", txID, len(mp.entries))
 }
 
+// Flush empties the mempool, keeping this node's own transactions if keepLocal. Flushed
+// transactions are forgotten by the relay, so peers announcing them again are fetched from.
+// Returns the number removed.
+func (mp *Mempool) Flush(keepLocal bool) int {
+	mp.txLock.Lock()
+	var flushed []string
+	for txID, entry := range mp.entries {
+		if keepLocal && entry.Local {
+			continue
+		}
+		delete(mp.entries, txID)
+		flushed = append(flushed, txID)
+	}
+	remaining := len(mp.entries)
+	mp.txLock.Unlock()
+
+	for _, txID := range flushed {
+		mp.relay.unmarkSeen(txID)
+	}
+	fmt.Printf("[Mempool] Flushed %d transactions (remaining: %d)\n", len(flushed), remaining)
+	return len(flushed)
+}
+
 // Count returns the number of transactions in the mempool
 func (mp *Mempool) Count() int {
 	mp.txLock.RLock()
@@ -316,6 +583,7 @@ func (mp *Mempool) PrintStatus() {
 
 // Close shuts down the mempool
 func (mp *Mempool) Close() error {
+	mp.host.RemoveStreamHandler(TxRelayProtocolID)
 	mp.cancel()
 	mp.sub.Cancel()
 	return mp.topic.Close()
@@ -330,112 +598,119 @@ func (mp *Mempool) UpdateBlockHeight(height uint64) {
 	mp.cleanupExpiredTransactionsLocked()
 }
 
-// PurgeInvalidTransactions removes transactions with spent inputs
-// Should be called after each block is added
-func (mp *Mempool) PurgeInvalidTransactions(utxoStore *UTXOStore) {
+// PurgeInvalidTransactions removes transactions with spent inputs after block is added.
+// Transactions that lost a double-spend to the block, or whose pending parent did, are
+// recorded as conflicted.
+func (mp *Mempool) PurgeInvalidTransactions(utxoStore *UTXOStore, block *Block) {
 	mp.txLock.Lock()
 	defer mp.txLock.Unlock()
 
 	beforeCount := len(mp.entries)
 	var invalidTxs []string
 
-	for txID, entry := range mp.entries {
-		// Check if all inputs are still unspent
-		for _, input := range entry.Tx.Inputs {
-			utxo, err := utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex)
-			if err != nil || utxo == nil || utxo.IsSpent {
-				// Input no longer available - transaction is invalid
-				invalidTxs = append(invalidTxs, txID)
-				break
+	// The block's own transactions are confirmed, not invalid
+	spenders := mp.blockSpendersLocked(block, utxoStore)
+	confirmed := make(map[string]bool)
+	if block != nil {
+		for _, txID := range block.Transactions {
+			confirmed[txID] = true
+		}
+	}
+	conflicted := make(map[string]*TxConflict)
+
+	// Repeat until stable: purging a parent invalidates children spending its outputs
+	for purged := true; purged; {
+		purged = false
+		for txID, entry := range mp.entries {
+			// Check if all inputs are still unspent, or outputs of a pending parent
+			for _, input := range entry.Tx.Inputs {
+				if _, pending := mp.entries[input.PrevTxID]; pending {
+					continue
+				}
+				utxo, err := utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex)
+				if err != nil || utxo == nil || utxo.IsSpent {
+					// Input no longer available - transaction is invalid
+					invalidTxs = append(invalidTxs, txID)
+					delete(mp.entries, txID)
+					purged = true
+					if !confirmed[txID] {
+						outpoint := fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)
+						if winner, ok := spenders[outpoint]; ok {
+							conflicted[txID] = &TxConflict{TxID: txID, Cause: ConflictDoubleSpent, ConflictingTxID: winner, Input: outpoint, Height: block.Index}
+						} else if parent, ok := conflicted[input.PrevTxID]; ok {
+							conflicted[txID] = &TxConflict{TxID: txID, Cause: ConflictParent, ConflictingTxID: parent.ConflictingTxID, Input: outpoint, Height: parent.Height}
+						} else if utxo != nil && utxo.IsSpent {
+							conflicted[txID] = &TxConflict{TxID: txID, Cause: ConflictDoubleSpent, Input: outpoint}
+						}
+					}
+					break
+				}
 			}
 		}
 	}
+	for _, conflict := range conflicted {
+		mp.markConflictedLocked(conflict)
+	}
 
 	if len(invalidTxs) > 0 {
-		for _, txID := range invalidTxs {
-			delete(mp.entries, txID)
-		}
-		fmt.Printf("[Mempool] 🧹 Purged %d transactions with spent inputs (%d -> %d remaining)\n",
-			len(invalidTxs), beforeCount, len(mp.entries))
+		fmt.Printf("[Mempool] 🧹 Purged %d transactions with spent inputs (%d -> %d remaining, %d conflicted)\n",
+			len(invalidTxs), beforeCount, len(mp.entries), len(conflicted))
 	} else if beforeCount > 0 {
 		fmt.Printf("[Mempool] 🧹 Checked %d transactions, none invalid\n", beforeCount)
 	}
 }
 
-// cleanupExpiredTransactionsLocked removes transactions older than expiryBlocks
-// Must be called with txLock held
-func (mp *Mempool) cleanupExpiredTransactionsLocked() {
-	if mp.expiryBlocks <= 0 {
-		return
-	}
-
-	var expiredTxs []string
-	for txID, entry := range mp.entries {
-		age := mp.currentHeight - entry.AddedAtBlock
-		if age >= uint64(mp.expiryBlocks) {
-			expiredTxs = append(expiredTxs, txID)
-		}
-	}
+// checkTTL rejects a transaction whose TTL has passed for the next block
+func (mp *Mempool) checkTTL(tx *Transaction) error {
+	mp.txLock.RLock()
+	nextHeight := mp.currentHeight + 1
+	mp.txLock.RUnlock()
 
-	if len(expiredTxs) > 0 {
-		for _, txID := range expiredTxs {
-			delete(mp.entries, txID)
-		}
-		fmt.Printf("[Mempool] Expired %d transactions (age >= %d blocks)\n", len(expiredTxs), mp.expiryBlocks)
+	if tx.ExpiredAt(nextHeight) {
+		return fmt.Errorf("transaction expired at block %d (next block is %d)", tx.MempoolTTL, nextHeight)
 	}
+	return nil
 }
 
-// enforceMemoryLimitLocked evicts oldest transactions if mempool exceeds size limit
-// Must be called with txLock held
-func (mp *Mempool) enforceMemoryLimitLocked() {
-	if mp.maxSizeBytes <= 0 {
-		return
+// TTLRemaining returns how many more blocks can include a pending transaction, or
+// false if the transaction has no TTL
+func (mp *Mempool) TTLRemaining(tx *Transaction) (uint64, bool) {
+	if tx.MempoolTTL == 0 {
+		return 0, false
 	}
 
-	// Calculate current size
-	currentSize := 0
-	for _, entry := range mp.entries {
-		currentSize += entry.SizeBytes
-	}
-
-	if currentSize <= mp.maxSizeBytes {
-		return
-	}
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
 
-	// Need to evict - sort entries by timestamp (oldest first)
-	type entryWithID struct {
-		txID  string
-		entry *MempoolEntry
+	if uint64(tx.MempoolTTL) <= mp.currentHeight {
+		return 0, true
 	}
+	return uint64(tx.MempoolTTL) - mp.currentHeight, true
+}
 
-	entries := make([]entryWithID, 0, len(mp.entries))
+// cleanupExpiredTransactionsLocked removes transactions older than expiryBlocks and
+// transactions whose own TTL has passed
+// Must be called with txLock held
+func (mp *Mempool) cleanupExpiredTransactionsLocked() {
+	var expiredTxs []string
+	ttlExpired := 0
 	for txID, entry := range mp.entries {
-		entries = append(entries, entryWithID{txID, entry})
-	}
-
-	// Sort by timestamp (oldest first)
-	for i := 0; i < len(entries)-1; i++ {
-		for j := i + 1; j < len(entries); j++ {
-			if entries[i].entry.AddedTimestamp.After(entries[j].entry.AddedTimestamp) {
-				entries[i], entries[j] = entries[j], entries[i]
-			}
+		if entry.Tx.ExpiredAt(mp.currentHeight + 1) {
+			expiredTxs = append(expiredTxs, txID)
+			ttlExpired++
+			continue
 		}
-	}
-
-	// Evict oldest until we're under the limit
-	evictedCount := 0
-	for _, e := range entries {
-		if currentSize <= mp.maxSizeBytes {
-			break
+		if mp.expiryBlocks > 0 && mp.currentHeight-entry.AddedAtBlock >= uint64(mp.expiryBlocks) {
+			expiredTxs = append(expiredTxs, txID)
 		}
-		delete(mp.entries, e.txID)
-		currentSize -= e.entry.SizeBytes
-		evictedCount++
 	}
 
-	if evictedCount > 0 {
-		fmt.Printf("[Mempool] Evicted %d oldest transactions to enforce %d MB limit\n",
-			evictedCount, mp.maxSizeBytes/(1024*1024))
+	if len(expiredTxs) > 0 {
+		for _, txID := range expiredTxs {
+			delete(mp.entries, txID)
+		}
+		fmt.Printf("[Mempool] Expired %d transactions (%d past their TTL, %d age >= %d blocks)\n",
+			len(expiredTxs), ttlExpired, len(expiredTxs)-ttlExpired, mp.expiryBlocks)
 	}
 }
 
@@ -444,8 +719,11 @@ func (mp *Mempool) estimateTxSize(tx *Transaction) int {
 	// Rough estimate: count inputs, outputs, and signature
 	size := 100 // Base overhead
 
-	// Inputs (UTXO references)
+	// Inputs (UTXO references, plus their own signatures if signed separately)
 	size += len(tx.Inputs) * 100
+	for _, input := range tx.Inputs {
+		size += len(input.PublicKey) + len(input.Signature)
+	}
 
 	// Outputs
 	for _, output := range tx.Outputs {
@@ -0,0 +1,202 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Admission runs in two stages. The quick sanity stage (size, TTL, relay fee and the
+// other cheap checks) runs on the caller's goroutine; signature verification, which
+// dominates the cost, runs on a pool of workers fed by a bounded queue. Submissions that
+// would overflow the queue are turned away with ErrMempoolBusy rather than piling up.
+
+const (
+	DefaultAdmissionQueueSize = 1024  // Transactions waiting for verification
+	MaxAdmissionRejections    = 10000 // Rejection reasons remembered for status queries
+)
+
+// ErrMempoolBusy is returned when the verification queue is full. The submission can be
+// retried unchanged.
+var ErrMempoolBusy = errors.New("mempool verification queue is full, retry later")
+
+// Admission states reported by AdmissionStatus
+const (
+	AdmissionVerifying = "verifying"
+	AdmissionRejected  = "rejected"
+)
+
+// admissionJob is a transaction waiting for verification
+type admissionJob struct {
+	tx    *Transaction
+	txID  string
+	local bool    // Submitted through this node: tracked and announced once admitted
+	from  peer.ID // Peer it came from, for gossip
+}
+
+// admissionQueue feeds submitted transactions to the verification workers
+type admissionQueue struct {
+	jobs chan admissionJob
+
+	mu       sync.Mutex
+	pending  map[string]struct{} // Queued or being verified
+	rejected map[string]string   // txID -> reason
+	order    []string            // Ring of rejected IDs, oldest evicted first
+	next     int
+	stats    struct{ queued, admitted, rejected, busy uint64 }
+}
+
+// newAdmissionQueue creates a queue holding at most size transactions
+func newAdmissionQueue(size int) *admissionQueue {
+	if size <= 0 {
+		size = DefaultAdmissionQueueSize
+	}
+	return &admissionQueue{
+		jobs:     make(chan admissionJob, size),
+		pending:  make(map[string]struct{}),
+		rejected: make(map[string]string),
+		order:    make([]string, MaxAdmissionRejections),
+	}
+}
+
+// enqueue queues a job; false if the queue is full. A transaction already queued is not
+// queued again.
+func (q *admissionQueue) enqueue(job admissionJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[job.txID]; ok {
+		return true
+	}
+	select {
+	case q.jobs <- job:
+		q.pending[job.txID] = struct{}{}
+		delete(q.rejected, job.txID) // A resubmission gets a fresh verdict
+		q.stats.queued++
+		return true
+	default:
+		q.stats.busy++
+		return false
+	}
+}
+
+// finish records a job's outcome; reason is empty if it was admitted
+func (q *admissionQueue) finish(txID, reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.pending, txID)
+	if reason == "" {
+		q.stats.admitted++
+		return
+	}
+	q.stats.rejected++
+	if old := q.order[q.next]; old != "" {
+		delete(q.rejected, old)
+	}
+	q.order[q.next] = txID
+	q.next = (q.next + 1) % len(q.order)
+	q.rejected[txID] = reason
+}
+
+// startAdmissionWorkers verifies queued transactions on runtime.NumCPU workers until the
+// mempool is closed
+func (mp *Mempool) startAdmissionWorkers() {
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go mp.runAdmissionWorker()
+	}
+}
+
+// runAdmissionWorker verifies queued transactions until the mempool is closed
+func (mp *Mempool) runAdmissionWorker() {
+	for {
+		select {
+		case job := <-mp.admission.jobs:
+			reason := ""
+			if err := mp.admit(job); err != nil {
+				reason = err.Error()
+				fmt.Printf("[Mempool] Rejected transaction %s: %v\n", job.txID[:16], err)
+				if !job.local {
+					mp.recordRejected(job.tx, job.from, err)
+				}
+			}
+			mp.admission.finish(job.txID, reason)
+		case <-mp.ctx.Done():
+			return
+		}
+	}
+}
+
+// admit verifies a queued transaction and adds it to the mempool
+func (mp *Mempool) admit(job admissionJob) error {
+	if err := mp.verifyTransaction(job.tx); err != nil {
+		return err
+	}
+	if job.local {
+		return mp.insertLocal(job.tx, job.txID)
+	}
+	return mp.admitGossip(job.tx, job.txID)
+}
+
+// SubmitTransaction runs the sanity stage and queues the transaction for verification,
+// returning before its signatures are checked. The outcome can be followed with
+// AdmissionStatus. Returns ErrMempoolBusy if the queue is full.
+func (mp *Mempool) SubmitTransaction(tx *Transaction) error {
+	txID, err := mp.sanityCheck(tx)
+	if err != nil {
+		return err
+	}
+	if !mp.admission.enqueue(admissionJob{tx: tx, txID: txID, local: true}) {
+		return ErrMempoolBusy
+	}
+	return nil
+}
+
+// AdmissionStatus reports a transaction that is not in the mempool but was submitted:
+// AdmissionVerifying while queued, or AdmissionRejected with the reason. ok is false if
+// the transaction is unknown to admission.
+func (mp *Mempool) AdmissionStatus(txID string) (status, reason string, ok bool) {
+	mp.admission.mu.Lock()
+	defer mp.admission.mu.Unlock()
+
+	if _, pending := mp.admission.pending[txID]; pending {
+		return AdmissionVerifying, "", true
+	}
+	if reason, rejected := mp.admission.rejected[txID]; rejected {
+		return AdmissionRejected, reason, true
+	}
+	return "", "", false
+}
+
+// AdmissionStats returns admission queue counters
+func (mp *Mempool) AdmissionStats() map[string]interface{} {
+	mp.admission.mu.Lock()
+	defer mp.admission.mu.Unlock()
+
+	return map[string]interface{}{
+		"queue_length":   len(mp.admission.jobs),
+		"queue_capacity": cap(mp.admission.jobs),
+		"queued":         mp.admission.stats.queued,
+		"admitted":       mp.admission.stats.admitted,
+		"rejected":       mp.admission.stats.rejected,
+		"busy":           mp.admission.stats.busy,
+	}
+}
+
+// SetEvidenceStore sets where the mempool records transactions it rejects from peers
+func (mp *Mempool) SetEvidenceStore(evidence *EvidenceStore) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.evidence = evidence
+}
+
+// recordRejected records a transaction from peer from as evidence
+func (mp *Mempool) recordRejected(tx *Transaction, from peer.ID, reason error) {
+	mp.txLock.RLock()
+	evidence := mp.evidence
+	mp.txLock.RUnlock()
+	evidence.RecordTransaction(EvidenceSourceGossip, from.String(), tx, reason)
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMempoolAdmission(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mp := &Mempool{
+		entries:   make(map[string]*MempoolEntry),
+		relay:     newTxRelay(),
+		admission: newAdmissionQueue(1),
+		ctx:       ctx,
+	}
+
+	kp, _ := GenerateKeyPair()
+	txs := signedSends(t, kp, 3)
+	firstID, _ := txs[0].ID()
+	secondID, _ := txs[1].ID()
+
+	// With no worker running the queue holds one transaction
+	mp.addGossipTransaction(txs[0], "")
+	if status, _, _ := mp.AdmissionStatus(firstID); status != AdmissionVerifying {
+		t.Fatalf("Expected the first transaction to be verifying, got %q", status)
+	}
+	if err := mp.SubmitTransaction(txs[1]); !errors.Is(err, ErrMempoolBusy) {
+		t.Fatalf("Expected a full queue to refuse the submission, got %v", err)
+	}
+	mp.addGossipTransaction(txs[2], "")
+	thirdID, _ := txs[2].ID()
+	if mp.relay.seen.Has(thirdID) {
+		t.Error("Expected a dropped gossip transaction to be fetched again on its next announcement")
+	}
+
+	go mp.runAdmissionWorker()
+	waitFor := func(what string, done func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor("admission", func() bool { return mp.HasTransaction(firstID) })
+	if _, _, ok := mp.AdmissionStatus(firstID); ok {
+		t.Error("Expected an admitted transaction to leave the admission state")
+	}
+
+	// A forged signature is rejected off the caller's goroutine, with the reason kept
+	forged := *txs[1]
+	forged.Signature = append([]byte(nil), txs[1].Signature...)
+	forged.Signature[0] ^= 0xff
+	forgedID, _ := forged.ID()
+	mp.addGossipTransaction(&forged, "")
+	waitFor("rejection", func() bool {
+		status, _, _ := mp.AdmissionStatus(forgedID)
+		return status == AdmissionRejected
+	})
+	if _, reason, _ := mp.AdmissionStatus(forgedID); reason == "" {
+		t.Error("Expected a rejection reason")
+	}
+	if mp.HasTransaction(forgedID) || mp.HasTransaction(secondID) {
+		t.Error("Expected only the verified transaction in the mempool")
+	}
+}
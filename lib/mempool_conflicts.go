@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A pending transaction loses a double-spend when a block confirms another transaction
+// spending one of its inputs, or when a higher-fee replacement evicts it. Either way it
+// can never confirm, so the mempool remembers it as conflicted, with the transaction
+// that won, and publishes a tx_conflicted event so the originating wallet can react.
+
+const MaxConflictRecords = 10000 // Conflicted transactions remembered for status queries
+
+// TxStatusConflicted is reported for a transaction that lost a double-spend
+const TxStatusConflicted = "conflicted"
+
+// Why a transaction is conflicted
+const (
+	ConflictDoubleSpent = "double_spent"      // An input was spent by a confirmed transaction
+	ConflictReplaced    = "replaced"          // Evicted by a higher-fee spender of the same inputs
+	ConflictParent      = "parent_conflicted" // Spends an output of a conflicted transaction
+)
+
+// TxConflict records a pending transaction that lost a double-spend
+type TxConflict struct {
+	TxID            string `json:"tx_id"`
+	Cause           string `json:"cause"`
+	ConflictingTxID string `json:"conflicting_tx_id,omitempty"` // The winner, if known
+	Input           string `json:"input,omitempty"`             // Outpoint both spent, txid:index
+	Height          uint64 `json:"height,omitempty"`            // Block that confirmed the winner
+	Time            int64  `json:"time"`
+}
+
+// conflictLog remembers the most recent conflicted transactions
+type conflictLog struct {
+	mu    sync.Mutex
+	byID  map[string]*TxConflict
+	order []string // Ring of conflicted IDs, oldest evicted first
+	next  int
+}
+
+// record remembers a conflict, evicting the oldest when full
+func (l *conflictLog) record(conflict *TxConflict) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.byID == nil {
+		l.byID = make(map[string]*TxConflict)
+		l.order = make([]string, MaxConflictRecords)
+	}
+	if _, ok := l.byID[conflict.TxID]; !ok {
+		if old := l.order[l.next]; old != "" {
+			delete(l.byID, old)
+		}
+		l.order[l.next] = conflict.TxID
+		l.next = (l.next + 1) % len(l.order)
+	}
+	l.byID[conflict.TxID] = conflict
+}
+
+// SetEventHub sets where the mempool publishes conflict events
+func (mp *Mempool) SetEventHub(events *EventHub) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.events = events
+}
+
+// markConflicted records and announces a transaction that lost a double-spend
+// Must be called with txLock held
+func (mp *Mempool) markConflictedLocked(conflict *TxConflict) {
+	conflict.Time = time.Now().Unix()
+	mp.conflicts.record(conflict)
+	mp.events.Publish(EventTxConflicted, conflict)
+
+	winner := "an unknown transaction"
+	if conflict.ConflictingTxID != "" {
+		winner = conflict.ConflictingTxID[:16]
+	}
+	fmt.Printf("[Mempool] ⚔️  Transaction %s conflicted (%s) with %s\n", conflict.TxID[:16], conflict.Cause, winner)
+}
+
+// ConflictStatus returns why a transaction that left the mempool lost a double-spend;
+// ok is false if it isn't known to have
+func (mp *Mempool) ConflictStatus(txID string) (*TxConflict, bool) {
+	mp.conflicts.mu.Lock()
+	defer mp.conflicts.mu.Unlock()
+	conflict, ok := mp.conflicts.byID[txID]
+	return conflict, ok
+}
+
+// blockSpenders maps each outpoint spent by a block's transactions to its spender
+// Must be called with txLock held
+func (mp *Mempool) blockSpendersLocked(block *Block, utxoStore *UTXOStore) map[string]string {
+	spenders := make(map[string]string)
+	if block == nil {
+		return spenders
+	}
+	for _, txID := range block.Transactions {
+		var tx *Transaction
+		if entry, ok := mp.entries[txID]; ok {
+			tx = entry.Tx
+		} else {
+			tx, _ = utxoStore.GetTransaction(txID)
+		}
+		if tx == nil {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			spenders[fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)] = txID
+		}
+	}
+	return spenders
+}
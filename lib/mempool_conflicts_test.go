@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMempoolConflicts(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to create UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	owner, _ := GenerateKeyPair()
+	funding := "funding-tx-0000000000000000"
+	if err := store.AddUTXO(&UTXO{TxID: funding, OutputIndex: 0, Output: CreateShadowOutput(owner.Address(), 1000), BlockHeight: 1}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+
+	spend := func(prevTxID string) *Transaction {
+		return &Transaction{Inputs: []*TxInput{{PrevTxID: prevTxID, OutputIndex: 0}}}
+	}
+	hub := NewEventHub()
+	events, cancel := hub.Subscribe([]string{EventTxConflicted})
+	defer cancel()
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	mp.SetEventHub(hub)
+	mp.entries["winner-tx-000000000000000"] = &MempoolEntry{Tx: spend(funding)}
+	mp.entries["loser-tx-0000000000000000"] = &MempoolEntry{Tx: spend(funding)}
+	mp.entries["child-tx-0000000000000000"] = &MempoolEntry{Tx: spend("loser-tx-0000000000000000")}
+
+	// A block confirms the winner; the loser and its child can never confirm
+	if err := store.SpendUTXO(funding, 0, 2); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	mp.PurgeInvalidTransactions(store, &Block{Index: 2, Transactions: []string{"winner-tx-000000000000000"}})
+
+	if mp.Count() != 0 {
+		t.Fatalf("Expected every spender purged, %d left", mp.Count())
+	}
+	if _, ok := mp.ConflictStatus("winner-tx-000000000000000"); ok {
+		t.Error("Expected the confirmed transaction not to be conflicted")
+	}
+	loser, ok := mp.ConflictStatus("loser-tx-0000000000000000")
+	if !ok || loser.Cause != ConflictDoubleSpent || loser.ConflictingTxID != "winner-tx-000000000000000" || loser.Height != 2 || loser.Input != funding+":0" {
+		t.Fatalf("Expected the loser double-spent by the winner, got %+v", loser)
+	}
+	child, ok := mp.ConflictStatus("child-tx-0000000000000000")
+	if !ok || child.Cause != ConflictParent || child.ConflictingTxID != "winner-tx-000000000000000" {
+		t.Fatalf("Expected the child conflicted through its parent, got %+v", child)
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < 2 {
+		select {
+		case event := <-events:
+			if event.Type != EventTxConflicted {
+				t.Fatalf("Unexpected event %s", event.Type)
+			}
+			seen[event.Data.(*TxConflict).TxID] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Expected two tx_conflicted events, got %v", seen)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// When the mempool grows past mempool_max_size_mb, the transactions paying the least per
+// byte are evicted first, since they are the last a miner would pack. Two kinds are
+// spared while anything else can go: transactions added in the last
+// MempoolEvictionGrace, so a burst can't churn out what just arrived, and transactions
+// submitted through this node, which its wallet is waiting on. A new transaction gets no
+// grace: if it pays the lowest rate of all it is evicted on arrival, i.e. refused.
+
+// MempoolEvictionGrace is how long a newly added transaction is spared from eviction
+const MempoolEvictionGrace = time.Minute
+
+// ErrMempoolFull is returned for a transaction evicted on arrival because the mempool is
+// full of transactions paying a higher fee rate
+var ErrMempoolFull = errors.New("mempool full")
+
+// mempoolEvictionStats counts evictions; guarded by txLock
+type mempoolEvictionStats struct {
+	evicted       uint64    // Transactions evicted to stay under the size cap
+	evictedBytes  uint64    // Their estimated size
+	evictedRecent uint64    // Evicted within their grace period: nothing cheaper was left
+	evictedLocal  uint64    // Submitted through this node: nothing cheaper was left
+	refused       uint64    // New transactions evicted on arrival
+	lastFeeRate   float64   // Fee rate of the last transaction evicted
+	lastEviction  time.Time // When the last transaction was evicted
+}
+
+// FeeRate returns the fee the entry pays per estimated byte
+func (e *MempoolEntry) FeeRate() float64 {
+	if e.SizeBytes <= 0 {
+		return 0
+	}
+	return float64(e.Fee) / float64(e.SizeBytes)
+}
+
+// evictionTier orders entries for eviction: unprotected first, then those still in their
+// grace period, then local ones
+func (e *MempoolEntry) evictionTier(now time.Time, incoming bool) int {
+	switch {
+	case e.Local:
+		return 2
+	case !incoming && now.Sub(e.AddedTimestamp) < MempoolEvictionGrace:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// enforceMemoryLimitLocked evicts the lowest fee rate transactions until the mempool fits
+// its size cap. incoming is the transaction just added; returns an ErrMempoolFull error
+// if it was evicted itself.
+// Must be called with txLock held
+func (mp *Mempool) enforceMemoryLimitLocked(incoming string) error {
+	if mp.maxSizeBytes <= 0 {
+		return nil
+	}
+
+	currentSize := 0
+	for _, entry := range mp.entries {
+		currentSize += entry.SizeBytes
+	}
+	if currentSize <= mp.maxSizeBytes {
+		return nil
+	}
+
+	type candidate struct {
+		txID  string
+		entry *MempoolEntry
+		tier  int
+	}
+	now := time.Now()
+	candidates := make([]candidate, 0, len(mp.entries))
+	for txID, entry := range mp.entries {
+		candidates = append(candidates, candidate{txID, entry, entry.evictionTier(now, txID == incoming)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.tier != b.tier {
+			return a.tier < b.tier
+		}
+		if a.entry.FeeRate() != b.entry.FeeRate() {
+			return a.entry.FeeRate() < b.entry.FeeRate()
+		}
+		if !a.entry.AddedTimestamp.Equal(b.entry.AddedTimestamp) {
+			return a.entry.AddedTimestamp.Before(b.entry.AddedTimestamp)
+		}
+		return a.txID < b.txID
+	})
+
+	var refused error
+	evictedCount := 0
+	for _, c := range candidates {
+		if currentSize <= mp.maxSizeBytes {
+			break
+		}
+		delete(mp.entries, c.txID)
+		currentSize -= c.entry.SizeBytes
+		evictedCount++
+
+		stats := &mp.evictions
+		stats.evicted++
+		stats.evictedBytes += uint64(c.entry.SizeBytes)
+		switch c.tier {
+		case 1:
+			stats.evictedRecent++
+		case 2:
+			stats.evictedLocal++
+		}
+		stats.lastFeeRate = c.entry.FeeRate()
+		stats.lastEviction = now
+
+		if c.txID == incoming {
+			stats.refused++
+			refused = fmt.Errorf("%w: fee rate %.3f per byte is below every pending transaction's",
+				ErrMempoolFull, c.entry.FeeRate())
+		}
+	}
+
+	fmt.Printf("[Mempool] Evicted %d lowest fee rate transactions to enforce %d MB limit\n",
+		evictedCount, mp.maxSizeBytes/(1024*1024))
+	return refused
+}
+
+// EvictionStats reports the mempool's size against its cap and what eviction dropped
+func (mp *Mempool) EvictionStats() map[string]interface{} {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+
+	now := time.Now()
+	sizeBytes, local, recent := 0, 0, 0
+	minFeeRate, first := 0.0, true
+	for _, entry := range mp.entries {
+		sizeBytes += entry.SizeBytes
+		switch entry.evictionTier(now, false) {
+		case 1:
+			recent++
+		case 2:
+			local++
+		}
+		if rate := entry.FeeRate(); first || rate < minFeeRate {
+			minFeeRate, first = rate, false
+		}
+	}
+
+	stats := map[string]interface{}{
+		"count":             len(mp.entries),
+		"size_bytes":        sizeBytes,
+		"max_size_bytes":    mp.maxSizeBytes,
+		"local":             local,
+		"in_grace":          recent,
+		"min_fee_rate":      minFeeRate,
+		"evicted":           mp.evictions.evicted,
+		"evicted_bytes":     mp.evictions.evictedBytes,
+		"evicted_in_grace":  mp.evictions.evictedRecent,
+		"evicted_local":     mp.evictions.evictedLocal,
+		"refused":           mp.evictions.refused,
+		"last_evicted_rate": mp.evictions.lastFeeRate,
+	}
+	if !mp.evictions.lastEviction.IsZero() {
+		stats["last_eviction"] = mp.evictions.lastEviction.Unix()
+	}
+	return stats
+}
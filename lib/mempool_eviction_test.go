@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMempoolEviction(t *testing.T) {
+	mp := &Mempool{
+		entries:      make(map[string]*MempoolEntry),
+		relay:        newTxRelay(),
+		maxSizeBytes: 1200,
+	}
+	old := time.Now().Add(-2 * MempoolEvictionGrace)
+	add := func(txID string, fee uint64, added time.Time, local bool) error {
+		mp.txLock.Lock()
+		defer mp.txLock.Unlock()
+		mp.entries[txID] = &MempoolEntry{Tx: &Transaction{}, AddedTimestamp: added, SizeBytes: 400, Fee: fee, Local: local}
+		return mp.enforceMemoryLimitLocked(txID)
+	}
+	mp.entries["old-cheap"] = &MempoolEntry{AddedTimestamp: old, SizeBytes: 400, Fee: 40}
+	mp.entries["old-rich"] = &MempoolEntry{AddedTimestamp: old, SizeBytes: 400, Fee: 800}
+	mp.entries["recent-cheap"] = &MempoolEntry{AddedTimestamp: time.Now(), SizeBytes: 400, Fee: 4}
+
+	// The lowest fee rate goes first; local and recently added transactions are spared
+	if err := add("local-free", 0, old, true); err != nil {
+		t.Fatalf("Local transaction refused: %v", err)
+	}
+	if mp.HasTransaction("old-cheap") || mp.Count() != 3 {
+		t.Fatalf("Expected only old-cheap evicted, %d left", mp.Count())
+	}
+
+	// A newcomer paying the lowest rate is refused rather than displacing anything
+	if err := add("new-poor", 8, time.Now(), false); !errors.Is(err, ErrMempoolFull) {
+		t.Fatalf("Expected the newcomer to be refused, got %v", err)
+	}
+	if mp.HasTransaction("new-poor") || !mp.HasTransaction("old-rich") {
+		t.Fatal("Expected the pending transactions to stay")
+	}
+
+	// One paying more displaces the cheapest unprotected transaction
+	if err := add("new-rich", 4000, time.Now(), false); err != nil {
+		t.Fatalf("Newcomer refused: %v", err)
+	}
+	for _, txID := range []string{"recent-cheap", "local-free", "new-rich"} {
+		if !mp.HasTransaction(txID) {
+			t.Errorf("Expected %s to be kept", txID)
+		}
+	}
+
+	stats := mp.EvictionStats()
+	if stats["evicted"] != uint64(3) || stats["refused"] != uint64(1) || stats["local"] != 1 {
+		t.Errorf("Unexpected eviction stats: %v", stats)
+	}
+}
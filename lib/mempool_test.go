@@ -0,0 +1,264 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMempoolTTLExpiry(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	newTx := func(ttl uint32) (*Transaction, string) {
+		tx := NewTxBuilder(TxTypeSend).
+			AddInput("prev", ttl).
+			AddOutput(kp.Address(), 100, "").
+			SetMempoolTTL(ttl).
+			Build()
+		id, _ := tx.ID()
+		return tx, id
+	}
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), currentHeight: 10}
+	shortTx, shortID := newTx(12)
+	foreverTx, foreverID := newTx(0)
+	mp.entries[shortID] = &MempoolEntry{Tx: shortTx, AddedAtBlock: 10}
+	mp.entries[foreverID] = &MempoolEntry{Tx: foreverTx, AddedAtBlock: 10}
+
+	if remaining, hasTTL := mp.TTLRemaining(shortTx); !hasTTL || remaining != 2 {
+		t.Errorf("Expected 2 blocks remaining, got %d (hasTTL=%v)", remaining, hasTTL)
+	}
+	if _, hasTTL := mp.TTLRemaining(foreverTx); hasTTL {
+		t.Error("Transaction without a TTL should report no TTL")
+	}
+
+	// Block 12 can still include it
+	mp.UpdateBlockHeight(11)
+	if !mp.HasTransaction(shortID) {
+		t.Fatal("Transaction should survive until its TTL height")
+	}
+
+	// Once block 12 is mined, block 13 can't
+	mp.UpdateBlockHeight(12)
+	if mp.HasTransaction(shortID) {
+		t.Error("Transaction past its TTL should be dropped")
+	}
+	if !mp.HasTransaction(foreverID) {
+		t.Error("Transaction without a TTL should be kept")
+	}
+	if err := mp.checkTTL(shortTx); err == nil {
+		t.Error("Expired transaction should be rejected on admission")
+	}
+}
+
+func TestMempoolRefusesUnboundVersion(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	tx := NewTxBuilder(TxTypeSend).AddInput("prev", 0).AddOutput(kp.Address(), 100, "").Build()
+	tx.Version = LegacyTxVersion
+	tx.Sign(kp)
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	if err := mp.AddTransaction(tx); err == nil || !strings.Contains(err.Error(), "not bound to a chain") {
+		t.Errorf("Expected a version 1 transaction to be refused, got %v", err)
+	}
+	if err := mp.checkPackageMember(tx, "", nil, nil); err == nil {
+		t.Error("Expected a version 1 package member to be refused")
+	}
+}
+
+func TestBlockRejectsExpiredTransaction(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := bc.utxoStore.AddUTXO(&UTXO{TxID: "ttl-test-funding", Output: CreateShadowOutput(kp.Address(), 100)}); err != nil {
+		t.Fatalf("Failed to add funding: %v", err)
+	}
+	tx := NewTxBuilder(TxTypeSend).AddInput("ttl-test-funding", 0).AddOutput(kp.Address(), 100, "").SetMempoolTTL(1).Build()
+	tx.Sign(kp)
+	txID, _ := tx.ID()
+	if err := bc.utxoStore.StoreTransaction(tx, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+
+	block := bc.ProposeBlock([]string{txID}, "ttl-test-proposer", nil)
+	if err := bc.ValidateTransactionExpiry(block, nil); err != nil {
+		t.Fatalf("Transaction should be valid at its TTL height: %v", err)
+	}
+	if err := bc.AddBlock(block, nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	late := bc.ProposeBlock([]string{txID}, "ttl-test-proposer", nil)
+	if err := bc.ValidateTransactionExpiry(late, nil); err == nil {
+		t.Error("Transaction should be expired past its TTL height")
+	}
+	if err := bc.AddBlock(late, nil); err == nil {
+		t.Error("Block including a transaction past its TTL should be rejected")
+	}
+}
+
+func TestReplaceByFee(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "aa", Output: CreateShadowOutput(kp.Address(), 10000)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	admit := func(tx *Transaction) error {
+		fee := mp.feeOf(tx)
+		mp.txLock.Lock()
+		defer mp.txLock.Unlock()
+		if _, err := mp.replaceConflictsLocked(tx, fee); err != nil {
+			return err
+		}
+		txID, _ := tx.ID()
+		mp.entries[txID] = &MempoolEntry{Tx: tx, Fee: fee}
+		return nil
+	}
+	spend := func(keep uint64) *Transaction {
+		return NewTxBuilder(TxTypeSend).AddInput("aa", 0).AddOutput(kp.Address(), keep, "").Build()
+	}
+
+	original := spend(9900) // Fee 100
+	if err := admit(original); err != nil {
+		t.Fatalf("Failed to admit original: %v", err)
+	}
+	if err := admit(spend(9950)); err == nil {
+		t.Fatal("Lower-fee double-spend should be rejected")
+	}
+	if err := admit(spend(9900)); err == nil {
+		t.Fatal("Equal-fee double-spend should be rejected")
+	}
+
+	replacement := spend(9800) // Fee 200
+	if err := admit(replacement); err != nil {
+		t.Fatalf("Higher-fee replacement should be accepted: %v", err)
+	}
+	originalID, _ := original.ID()
+	if mp.HasTransaction(originalID) || mp.Count() != 1 {
+		t.Fatal("Replaced transaction should be evicted")
+	}
+
+	// Cancelling builds the cheapest winning replacement back to the owner
+	cancel, err := BuildCancelReplacement(replacement, kp.Address(), store, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to build cancel replacement: %v", err)
+	}
+	if fee := PaidFee(cancel, store); fee != 201 {
+		t.Errorf("Expected cancel fee 201, got %d", fee)
+	}
+	if err := admit(cancel); err != nil {
+		t.Fatalf("Cancel replacement should win the conflict: %v", err)
+	}
+
+	other, _ := GenerateKeyPair()
+	if _, err := BuildCancelReplacement(cancel, other.Address(), store, nil, 0); err == nil {
+		t.Error("Cancel should require owning the inputs")
+	}
+
+	// A replacement outbids the descendants it would orphan too, and evicts them with it
+	cancelID, _ := cancel.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(cancelID, 0).AddOutput(kp.Address(), 9699, "").Build() // Fee 100
+	if err := admit(child); err != nil {
+		t.Fatalf("Failed to admit child: %v", err)
+	}
+	if err := admit(spend(9749)); err == nil {
+		t.Fatal("Replacement outbidding only the conflict should be rejected")
+	}
+	childID, _ := child.ID()
+	if fees := mp.DescendantFees(cancelID); fees != 100 {
+		t.Errorf("Expected the child's 100 in descendant fees, got %d", fees)
+	}
+	if err := admit(NewTxBuilder(TxTypeSend).AddInput("aa", 0).AddInput(childID, 0).AddOutput(kp.Address(), 1000, "").Build()); err == nil {
+		t.Fatal("Replacement spending a descendant it evicts should be rejected")
+	}
+	if err := admit(spend(9649)); err != nil {
+		t.Fatalf("Replacement outbidding the conflict and its descendants should be accepted: %v", err)
+	}
+	if mp.HasTransaction(cancelID) || mp.HasTransaction(childID) || mp.Count() != 1 {
+		t.Fatal("Replaced transaction and its descendants should be evicted")
+	}
+}
+
+func TestTokenFee(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	shadowID := GetGenesisToken().TokenID
+	tokenID := strings.Repeat("ab", 32)
+	pools := NewPoolRegistry()
+	for _, pool := range []*LiquidityPool{
+		{PoolID: strings.Repeat("01", 32), TokenA: tokenID, TokenB: shadowID, ReserveA: 1_000_000, ReserveB: 1_000_000, FeePercent: 30},
+		{PoolID: strings.Repeat("02", 32), TokenA: shadowID, TokenB: tokenID, ReserveA: 4_000_000, ReserveB: 1_000_000, FeePercent: 30},
+	} {
+		if err := pools.RegisterPool(pool); err != nil {
+			t.Fatalf("Failed to register pool: %v", err)
+		}
+	}
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "cc", Output: CreateTokenOutput(kp.Address(), 50_000, tokenID, "custom", nil)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	tx := NewTxBuilder(TxTypeSend).
+		AddInput("cc", 0).
+		AddOutput(kp.Address(), 40_000, tokenID).
+		SetTokenFee(tokenID, 10_000).
+		Build()
+
+	// Priced through the deepest SHADOW pool: 10000 tokens at 4 SHADOW each, less slippage and pool fee
+	shadow, pool, err := QuoteTokenFee(tx.TokenFee, pools)
+	if err != nil {
+		t.Fatalf("Failed to quote token fee: %v", err)
+	}
+	if pool.PoolID != strings.Repeat("02", 32) || shadow < 39_000 || shadow >= 40_000 {
+		t.Errorf("Expected ~39600 SHADOW from the deeper pool, got %d from %s", shadow, pool.PoolID[:16])
+	}
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store, minRelayFee: 1000}
+	mp.SetTokenFeePolicy(false, pools)
+	if err := mp.meetsRelayFee(tx); err == nil {
+		t.Error("Token fee should not count on nodes that haven't opted in")
+	}
+	mp.SetTokenFeePolicy(true, pools)
+	if err := mp.meetsRelayFee(tx); err != nil {
+		t.Errorf("Token fee should meet the relay floor once accepted: %v", err)
+	}
+
+	// Block time: the pool takes the tokens and the winner gets the SHADOW
+	winner, _ := GenerateKeyPair()
+	winnerAddr := winner.Address()
+	bc := &Blockchain{utxoStore: store, poolRegistry: pools}
+	txID, _ := tx.ID()
+	bc.applyTokenFee(tx, txID, &Block{Index: 1, WinnerAddress: &winnerAddr})
+
+	paid, err := store.GetUTXO(txID, uint32(len(tx.Outputs)))
+	if err != nil || paid == nil || paid.Output.Amount != shadow || paid.Output.Address != winnerAddr {
+		t.Fatalf("Winner should receive %d SHADOW, got %+v (%v)", shadow, paid, err)
+	}
+	if pool, _ = pools.GetPool(pool.PoolID); pool.ReserveB != 1_010_000 || pool.ReserveA != 4_000_000-shadow {
+		t.Errorf("Pool reserves not updated: %d/%d", pool.ReserveA, pool.ReserveB)
+	}
+
+	// Only sends may pay in tokens, and only in a custom token
+	bad := NewTxBuilder(TxTypeSend).AddInput("cc", 0).SetTokenFee(shadowID, 1).Build()
+	if err := validateTokenFee(bad); err == nil {
+		t.Error("Token fee in SHADOW should be rejected")
+	}
+	bad = NewTxBuilder(TxTypeMelt).AddInput("cc", 0).SetTokenFee(tokenID, 1).Build()
+	if err := validateTokenFee(bad); err == nil {
+		t.Error("Token fee on a non-send transaction should be rejected")
+	}
+}
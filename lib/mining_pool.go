@@ -0,0 +1,548 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPoolPayoutBlocks = 100               // Blocks between pool payouts
+	DefaultPoolFeePercent   = 1                 // Operator's cut of pool rewards
+	DefaultPoolStatePath    = "pool_state.json" // Farmer points and payout history
+	PoolPayoutTxFee         = 11500             // Fee paid by each payout transaction
+	PoolPayoutCheckInterval = 30 * time.Second  // How often the operator checks for a due payout
+	MaxPoolPayoutHistory    = 50                // Payouts kept in the pool state
+	plotHashBits            = 256               // Proof distance is a Hamming distance over 256-bit hashes
+)
+
+// PoolPartial is a farmer's proof sent to a pool operator. Partials earn contribution
+// points; the operator competes for blocks with the best one. The farmer's key signs
+// the payout address, so nobody else can redirect the farmer's share.
+type PoolPartial struct {
+	Proof         *ProofOfSpace `json:"proof"`
+	PayoutAddress string        `json:"payout_address"`
+	Signature     []byte        `json:"signature"` // Farmer (miner key) signature over payout address and proof
+}
+
+// poolPartialMessage is what a farmer signs to authenticate a partial
+func poolPartialMessage(payoutAddress string, proof *ProofOfSpace) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("shadowy-pool-partial/%s/%s/%s",
+		ActiveGenesis().ChainID, payoutAddress, hex.EncodeToString(proof.MinerSignature))))
+	return h[:]
+}
+
+// NewPoolPartial signs a partial with the farmer's signer, which must be the key that
+// signed the proof
+func NewPoolPartial(proof *ProofOfSpace, payout Address, signer Signer) (*PoolPartial, error) {
+	signature, err := signer.Sign(poolPartialMessage(payout.String(), proof))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign partial: %w", err)
+	}
+	return &PoolPartial{Proof: proof, PayoutAddress: payout.String(), Signature: signature}, nil
+}
+
+// Verify checks the partial's signature and returns the farmer (miner key) address and
+// the payout address
+func (p *PoolPartial) Verify() (Address, Address, error) {
+	var farmer Address
+	if p.Proof == nil {
+		return farmer, farmer, fmt.Errorf("partial has no proof")
+	}
+	payout, err := ParseRewardAddress(p.PayoutAddress)
+	if err != nil {
+		return farmer, payout, err
+	}
+	minerKey, err := PublicKeyFromBytes(p.Proof.MinerPublicKey)
+	if err != nil {
+		return farmer, payout, fmt.Errorf("invalid miner public key: %w", err)
+	}
+	if !VerifySignature(poolPartialMessage(p.PayoutAddress, p.Proof), p.Signature, minerKey) {
+		return farmer, payout, fmt.Errorf("invalid partial signature")
+	}
+	return DeriveAddress(minerKey), payout, nil
+}
+
+// PartialPoints scores a proof by how many plot hashes it takes, on average, to find one
+// this close to a challenge: 1 / P(distance <= d) for a random 256-bit hash. A farmer's
+// best proof per challenge then earns points in proportion to their plot space.
+func PartialPoints(distance uint64) float64 {
+	if distance >= plotHashBits {
+		return 1
+	}
+
+	// log P(X <= d) for X ~ Binomial(256, 1/2), summed in log space
+	logCDF := math.Inf(-1)
+	for k := uint64(0); k <= distance; k++ {
+		logTerm := logChoose(plotHashBits, k) - plotHashBits*math.Ln2
+		hi, lo := math.Max(logCDF, logTerm), math.Min(logCDF, logTerm)
+		logCDF = hi + math.Log1p(math.Exp(lo-hi))
+	}
+	return math.Exp(-logCDF)
+}
+
+// logChoose returns ln(n choose k)
+func logChoose(n, k uint64) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// PoolFarmer is a farmer's standing with the pool
+type PoolFarmer struct {
+	PayoutAddress string  `json:"payout_address"`
+	Points        float64 `json:"points"` // Since the last payout
+	Partials      uint64  `json:"partials"`
+	TotalPaid     uint64  `json:"total_paid"`
+	LastPartial   int64   `json:"last_partial"`
+}
+
+// PoolPayout records one payout round
+type PoolPayout struct {
+	Height      uint64 `json:"height"`
+	TxID        string `json:"tx_id"`
+	Earned      uint64 `json:"earned"` // Rewards paid to the pool since the previous payout, plus carry
+	OperatorFee uint64 `json:"operator_fee"`
+	Paid        uint64 `json:"paid"`
+	Farmers     int    `json:"farmers"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+// poolState is persisted to the pool state file
+type poolState struct {
+	Farmers          map[string]*PoolFarmer `json:"farmers"` // Farmer (miner key) address -> standing
+	LastPayoutHeight uint64                 `json:"last_payout_height"`
+	Carry            uint64                 `json:"carry"` // Rewards not yet paid out
+	BlocksWon        uint64                 `json:"blocks_won"`
+	Payouts          []*PoolPayout          `json:"payouts"`
+}
+
+// PoolOperator runs a mining pool: it scores farmer partials, competes for blocks with
+// the best of them under the node wallet, and pays farmers proportionally to their
+// points every payoutBlocks blocks.
+type PoolOperator struct {
+	chain        *Blockchain
+	consensus    *ConsensusEngine
+	mempool      *Mempool
+	wallet       *NodeWallet
+	payoutBlocks uint64
+	feePercent   uint64
+	statePath    string
+
+	mu         sync.Mutex
+	state      poolState
+	seen       map[string]bool // farmer:challenge already scored
+	seenHeight uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPoolOperator loads the pool state and starts the payout loop
+func NewPoolOperator(chain *Blockchain, consensus *ConsensusEngine, mempool *Mempool, wallet *NodeWallet, payoutBlocks, feePercent int, statePath string) (*PoolOperator, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	po := &PoolOperator{
+		chain:        chain,
+		consensus:    consensus,
+		mempool:      mempool,
+		wallet:       wallet,
+		payoutBlocks: uint64(payoutBlocks),
+		feePercent:   uint64(feePercent),
+		statePath:    statePath,
+		seen:         make(map[string]bool),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	data, err := os.ReadFile(statePath)
+	switch {
+	case os.IsNotExist(err):
+		// New pool: only rewards won from now on are shared
+		po.state.LastPayoutHeight = chain.GetLatestBlock().Index
+	case err != nil:
+		cancel()
+		return nil, fmt.Errorf("failed to read pool state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &po.state); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse pool state: %w", err)
+		}
+	}
+	if po.state.Farmers == nil {
+		po.state.Farmers = make(map[string]*PoolFarmer)
+	}
+
+	go po.payoutLoop()
+
+	fmt.Printf("[Pool] ⛏️  Pool operator started: %d farmers, payout every %d blocks, fee %d%%\n",
+		len(po.state.Farmers), payoutBlocks, feePercent)
+	return po, nil
+}
+
+// SubmitPartial scores a farmer's partial and, if it is the best proof for the next
+// block, competes with it for the pool. Returns the points awarded.
+func (po *PoolOperator) SubmitPartial(partial *PoolPartial) (float64, error) {
+	farmer, payout, err := partial.Verify()
+	if err != nil {
+		return 0, err
+	}
+	if !po.chain.IsRecentChallenge(partial.Proof.ChallengeHash) {
+		return 0, fmt.Errorf("proof is not for the current challenge")
+	}
+	if !ValidateProofOfSpace(partial.Proof) {
+		return 0, fmt.Errorf("invalid proof of space")
+	}
+
+	height := po.chain.GetHeight() + 1
+	points := PartialPoints(partial.Proof.Distance)
+
+	po.mu.Lock()
+	if height != po.seenHeight {
+		po.seen = make(map[string]bool)
+		po.seenHeight = height
+	}
+	key := farmer.String() + ":" + hex.EncodeToString(partial.Proof.ChallengeHash[:])
+	if po.seen[key] {
+		po.mu.Unlock()
+		return 0, fmt.Errorf("partial already submitted for this challenge")
+	}
+	po.seen[key] = true
+
+	standing := po.state.Farmers[farmer.String()]
+	if standing == nil {
+		standing = &PoolFarmer{}
+		po.state.Farmers[farmer.String()] = standing
+	}
+	standing.PayoutAddress = payout.String()
+	standing.Points += points
+	standing.Partials++
+	standing.LastPartial = time.Now().Unix()
+	po.mu.Unlock()
+
+	if po.consensus.SubmitProof(height, partial.Proof) {
+		fmt.Printf("[Pool] 🏆 Partial from %s is the best proof for height %d (distance %d)\n",
+			farmer.String()[:16], height, partial.Proof.Distance)
+	}
+	return points, nil
+}
+
+// Stats summarizes the pool for farmers
+func (po *PoolOperator) Stats() map[string]interface{} {
+	po.mu.Lock()
+	defer po.mu.Unlock()
+
+	farmers := make(map[string]*PoolFarmer, len(po.state.Farmers))
+	var totalPoints float64
+	for addr, f := range po.state.Farmers {
+		copied := *f
+		farmers[addr] = &copied
+		totalPoints += f.Points
+	}
+
+	return map[string]interface{}{
+		"pool_address":       po.wallet.Address.Display(),
+		"fee_percent":        po.feePercent,
+		"payout_blocks":      po.payoutBlocks,
+		"last_payout_height": po.state.LastPayoutHeight,
+		"next_payout_height": po.state.LastPayoutHeight + po.payoutBlocks,
+		"carry":              po.state.Carry,
+		"blocks_won":         po.state.BlocksWon,
+		"total_points":       totalPoints,
+		"farmers":            farmers,
+		"payouts":            po.state.Payouts,
+	}
+}
+
+// payoutLoop pays farmers whenever a payout is due
+func (po *PoolOperator) payoutLoop() {
+	ticker := time.NewTicker(PoolPayoutCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-po.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := po.Payout(false); err != nil {
+				fmt.Printf("[Pool] ⚠️  Payout failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// Payout shares the rewards the pool has won since the last payout among farmers in
+// proportion to their points. Unless forced, it only runs every payoutBlocks blocks.
+func (po *PoolOperator) Payout(force bool) error {
+	latest := po.chain.GetLatestBlock().Index
+
+	po.mu.Lock()
+	defer po.mu.Unlock()
+
+	if latest <= po.state.LastPayoutHeight || (!force && latest < po.state.LastPayoutHeight+po.payoutBlocks) {
+		return nil
+	}
+
+	// Rewards paid to the pool wallet by coinbases since the last payout
+	genesisTokenID := GetGenesisToken().TokenID
+	var earned uint64
+	for _, block := range po.chain.GetBlockRange(po.state.LastPayoutHeight+1, latest) {
+		if block.Coinbase == nil {
+			continue
+		}
+		won := false
+		for _, output := range block.Coinbase.Outputs {
+			if output.Address == po.wallet.Address && output.TokenID == genesisTokenID {
+				earned += output.Amount
+				won = true
+			}
+		}
+		if won {
+			po.state.BlocksWon++
+		}
+	}
+	po.state.LastPayoutHeight = latest
+
+	total := po.state.Carry + earned
+	fee := total * po.feePercent / 100
+	shares := SplitPoolRewards(po.state.Farmers, total-fee)
+	if len(shares) == 0 {
+		// Nothing to split yet; keep the rewards for the next round
+		po.state.Carry = total
+		return po.saveLocked()
+	}
+
+	tx, paid, err := po.buildPayout(shares)
+	if err == nil {
+		err = po.wallet.SignTransaction(tx)
+	}
+	if err == nil {
+		err = po.mempool.AddTransaction(tx)
+	}
+	if err != nil {
+		// Points and rewards roll over to the next round
+		po.state.Carry = total
+		if saveErr := po.saveLocked(); saveErr != nil {
+			fmt.Printf("[Pool] ⚠️  %v\n", saveErr)
+		}
+		return err
+	}
+
+	txID, _ := tx.ID()
+	for farmer, share := range shares {
+		f := po.state.Farmers[farmer]
+		f.TotalPaid += share
+		f.Points = 0
+	}
+	po.state.Carry = 0
+	po.state.Payouts = append(po.state.Payouts, &PoolPayout{
+		Height:      latest,
+		TxID:        txID,
+		Earned:      total,
+		OperatorFee: fee,
+		Paid:        paid,
+		Farmers:     len(shares),
+		Timestamp:   time.Now().Unix(),
+	})
+	if len(po.state.Payouts) > MaxPoolPayoutHistory {
+		po.state.Payouts = po.state.Payouts[len(po.state.Payouts)-MaxPoolPayoutHistory:]
+	}
+
+	fmt.Printf("[Pool] 💸 Paid %d to %d farmers at height %d (fee %d, tx %s)\n",
+		paid, len(shares), latest, fee, txID[:16])
+	return po.saveLocked()
+}
+
+// SplitPoolRewards divides amount, less the payout transaction fee, between farmers by
+// points. Rounding dust stays with the pool, and farmers whose share rounds down to
+// zero keep their points for the next round.
+func SplitPoolRewards(farmers map[string]*PoolFarmer, amount uint64) map[string]uint64 {
+	var totalPoints float64
+	for _, f := range farmers {
+		totalPoints += f.Points
+	}
+	if totalPoints == 0 || amount <= PoolPayoutTxFee {
+		return nil
+	}
+
+	distributable := float64(amount - PoolPayoutTxFee)
+	shares := make(map[string]uint64)
+	for farmer, f := range farmers {
+		if share := uint64(distributable * f.Points / totalPoints); share > 0 {
+			shares[farmer] = share
+		}
+	}
+	return shares
+}
+
+// buildPayout builds an unsigned transaction paying each farmer's share from the pool
+// wallet, with change back to the wallet. Returns the transaction and the total paid.
+func (po *PoolOperator) buildPayout(shares map[string]uint64) (*Transaction, uint64, error) {
+	// One output per payout address, in a deterministic order
+	byAddress := make(map[string]uint64)
+	var paid uint64
+	for farmer, share := range shares {
+		byAddress[po.state.Farmers[farmer].PayoutAddress] += share
+		paid += share
+	}
+	addresses := make([]string, 0, len(byAddress))
+	for addr := range byAddress {
+		addresses = append(addresses, addr)
+	}
+	sort.Strings(addresses)
+
+	utxos, err := po.chain.GetUTXOStore().GetUTXOsByAddress(po.wallet.Address)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load pool wallet UTXOs: %w", err)
+	}
+
+	genesisTokenID := GetGenesisToken().TokenID
+	needed := paid + PoolPayoutTxFee
+	builder := NewTxBuilder(TxTypeSend)
+	var selected uint64
+	for _, utxo := range utxos {
+		if selected >= needed {
+			break
+		}
+		if utxo.IsSpent || utxo.Output.TokenID != genesisTokenID {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		selected += utxo.Output.Amount
+	}
+	if selected < needed {
+		return nil, 0, fmt.Errorf("pool wallet holds %d, payout needs %d", selected, needed)
+	}
+
+	for _, addrStr := range addresses {
+		addr, err := ParseRewardAddress(addrStr)
+		if err != nil {
+			return nil, 0, err
+		}
+		builder.AddOutput(addr, byAddress[addrStr], genesisTokenID)
+	}
+	if change := selected - needed; change > 0 {
+		builder.AddOutput(po.wallet.Address, change, genesisTokenID)
+	}
+
+	return builder.Build(), paid, nil
+}
+
+// saveLocked writes the pool state to disk
+// Must be called with mu held
+func (po *PoolOperator) saveLocked() error {
+	data, err := json.MarshalIndent(po.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool state: %w", err)
+	}
+	if err := os.WriteFile(po.statePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pool state: %w", err)
+	}
+	return nil
+}
+
+// Close stops the payout loop and saves the pool state
+func (po *PoolOperator) Close() error {
+	po.cancel()
+
+	po.mu.Lock()
+	defer po.mu.Unlock()
+	return po.saveLocked()
+}
+
+// PoolClient sends this node's proofs to a pool operator instead of competing for
+// blocks directly
+type PoolClient struct {
+	url           string
+	signer        Signer
+	payoutAddress Address
+	httpClient    *http.Client
+
+	mu       sync.Mutex
+	accepted uint64
+	rejected uint64
+	points   float64
+	lastErr  string
+}
+
+// NewPoolClient creates a client for the pool at url. Partials are signed with signer
+// (the key proofs are made with) and claim rewards for payoutAddress.
+func NewPoolClient(url string, signer Signer, payoutAddress Address) *PoolClient {
+	return &PoolClient{
+		url:           strings.TrimRight(url, "/"),
+		signer:        signer,
+		payoutAddress: payoutAddress,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SubmitPartial sends a proof to the pool
+func (pc *PoolClient) SubmitPartial(proof *ProofOfSpace) {
+	points, err := pc.submit(proof)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if err != nil {
+		pc.rejected++
+		pc.lastErr = err.Error()
+		fmt.Printf("[Pool] ⚠️  Partial rejected: %v\n", err)
+		return
+	}
+	pc.accepted++
+	pc.points += points
+}
+
+func (pc *PoolClient) submit(proof *ProofOfSpace) (float64, error) {
+	partial, err := NewPoolPartial(proof, pc.payoutAddress, pc.signer)
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(partial)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal partial: %w", err)
+	}
+
+	resp, err := pc.httpClient.Post(pc.url+"/api/mining_pool/partial", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("pool unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Points float64 `json:"points"`
+		Error  string  `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("pool returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("pool returned %s: %s", resp.Status, result.Error)
+	}
+	return result.Points, nil
+}
+
+// Stats summarizes this farmer's submissions
+func (pc *PoolClient) Stats() map[string]interface{} {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return map[string]interface{}{
+		"pool_url":       pc.url,
+		"payout_address": pc.payoutAddress.String(),
+		"accepted":       pc.accepted,
+		"rejected":       pc.rejected,
+		"points":         pc.points,
+		"last_error":     pc.lastErr,
+	}
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPartialPoints(t *testing.T) {
+	// Half of all hashes are within distance 128 of a challenge
+	if points := PartialPoints(128); points < 1.8 || points > 2 {
+		t.Errorf("Expected about 2 points at distance 128, got %f", points)
+	}
+	if points := PartialPoints(256); points != 1 {
+		t.Errorf("Any hash is within distance 256, expected 1 point, got %f", points)
+	}
+	if points := PartialPoints(0); math.Abs(math.Log2(points)-256) > 1e-6 {
+		t.Errorf("Expected 2^256 points at distance 0, got 2^%f", math.Log2(points))
+	}
+
+	// Strictly better below the point where nearly every hash qualifies
+	for d := uint64(1); d <= 256; d++ {
+		closer, farther := PartialPoints(d-1), PartialPoints(d)
+		if farther > closer || (d <= 160 && farther == closer) {
+			t.Fatalf("Closer proofs must earn more points: distance %d vs %d", d-1, d)
+		}
+	}
+}
+
+func TestPoolPartialSignature(t *testing.T) {
+	farmer, _ := GenerateKeyPair()
+	payout, _ := GenerateKeyPair()
+	minerKey, _ := PublicKeyToBytes(farmer.PublicKey)
+	proof := &ProofOfSpace{
+		Distance:       90,
+		MinerPublicKey: minerKey,
+		MinerSignature: []byte("plot proof signature"),
+	}
+
+	partial, err := NewPoolPartial(proof, payout.Address(), NewLocalSigner(farmer))
+	if err != nil {
+		t.Fatalf("Failed to sign partial: %v", err)
+	}
+	farmerAddr, payoutAddr, err := partial.Verify()
+	if err != nil {
+		t.Fatalf("Valid partial rejected: %v", err)
+	}
+	if farmerAddr != farmer.Address() || payoutAddr != payout.Address() {
+		t.Error("Partial should identify the farmer by miner key and pay the signed address")
+	}
+
+	// Someone relaying the partial cannot redirect the payout
+	thief, _ := GenerateKeyPair()
+	partial.PayoutAddress = thief.Address().String()
+	if _, _, err := partial.Verify(); err == nil {
+		t.Error("Partial with a swapped payout address should be rejected")
+	}
+}
+
+func TestSplitPoolRewards(t *testing.T) {
+	farmers := map[string]*PoolFarmer{
+		"big":   {PayoutAddress: "a", Points: 300},
+		"small": {PayoutAddress: "b", Points: 100},
+		"idle":  {PayoutAddress: "c", Points: 0},
+	}
+
+	shares := SplitPoolRewards(farmers, 400_000+PoolPayoutTxFee)
+	if shares["big"] != 300_000 || shares["small"] != 100_000 {
+		t.Errorf("Expected 300000/100000 split, got %d/%d", shares["big"], shares["small"])
+	}
+	if _, ok := shares["idle"]; ok {
+		t.Error("Farmers without points should not be paid")
+	}
+
+	if shares := SplitPoolRewards(farmers, PoolPayoutTxFee); len(shares) != 0 {
+		t.Error("Rewards that only cover the transaction fee should not be split")
+	}
+	if shares := SplitPoolRewards(map[string]*PoolFarmer{}, 1_000_000); len(shares) != 0 {
+		t.Error("No farmers should mean no payouts")
+	}
+}
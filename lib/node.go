@@ -1,49 +1,160 @@
 package lib
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
-// StartNode starts the blockchain node in node mode
-func StartNode(config *CLIConfig) error {
-	// Use ports from config (defaults: 9000 for P2P, 8080 for API)
-	p2pPort := config.P2PPort
-	apiPort := config.APIPort
+// Node runs a node inside another Go program, such as an integration test or a service
+// that embeds the chain. It is configured with a CLIConfig (DefaultConfig for the
+// defaults) instead of flags, and reports errors instead of exiting.
+//
+//	config := lib.DefaultConfig()
+//	config.Seeds = []string{"/ip4/203.0.113.5/tcp/9000/p2p/12D3KooW..."}
+//	config.DataDir = "/var/lib/shadowy"
+//	node, err := lib.NewNode(config)
+//	...
+//	if err := node.Start(ctx); err != nil { ... }
+//	defer node.Stop()
+//
+// The chain genesis is process-wide, so nodes sharing a process must run the same
+// network. Each node keeps its files under its own data dir and farms its own plots.
+type Node struct {
+	config *CLIConfig
+
+	mu      sync.Mutex
+	backend *P2PBlockchainNode // Set while running
+	done    chan struct{}      // Closed when the running node stops
+}
+
+var (
+	ErrNodeRunning    = errors.New("node is already running")
+	ErrNodeNotRunning = errors.New("node is not running")
+)
+
+// NewNode validates config and returns a node ready to Start
+func NewNode(config *CLIConfig) (*Node, error) {
+	if config == nil {
+		return nil, fmt.Errorf("node configuration is required (see DefaultConfig)")
+	}
+	if err := config.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid node configuration: %w", err)
+	}
+	return &Node{config: config}, nil
+}
+
+// Start opens the node's chain and wallet, connects to peers, and starts consensus and
+// the HTTP API. It returns once the node is running; the node stops when ctx is done or
+// Stop is called, and may be started again after that.
+func (n *Node) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.backend != nil {
+		return ErrNodeRunning
+	}
+	config := n.config
+
+	// Switch to the configured network before anything touches chain state
+	if config.GenesisFile != "" {
+		genesis, err := LoadChainGenesis(config.GenesisFile)
+		if err != nil {
+			return err
+		}
+		if genesis.Fingerprint() != ActiveGenesis().Fingerprint() {
+			SetActiveGenesis(genesis)
+		}
+	}
 	SetFarmingDebugMode(true)
-	// Initialize plot manager if plot directories are configured
-	if len(config.Dirs) > 0 {
-		// Use the first directory for plots (can be enhanced to support multiple)
-		plotDir := config.Dirs[0]
-		if err := InitializePlotManager(plotDir); err != nil {
-			return fmt.Errorf("failed to initialize plot manager: %w", err)
+
+	// Service managers track the node by its PID file
+	if config.PIDFile != "" {
+		if err := WritePIDFile(config.PIDFile); err != nil {
+			return err
+		}
+	}
+
+	backend, err := NewP2PBlockchainNode(config.P2PPort, config.APIPort, config)
+	if err != nil {
+		err = fmt.Errorf("failed to create blockchain node: %w", err)
+		if config.PIDFile != "" {
+			RemovePIDFile(config.PIDFile)
 		}
-	} else {
-		// Try default ./plots directory
-		if err := InitializePlotManager("./plots"); err != nil {
-			fmt.Printf("⚠️  No plots found in ./plots: %v\n", err)
-			fmt.Printf("⚠️  Node will run without farming capability\n")
+		return err
+	}
+
+	done := make(chan struct{})
+	n.backend, n.done = backend, done
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := n.Stop(); err != nil && !errors.Is(err, ErrNodeNotRunning) {
+				fmt.Printf("[Node] Warning: shutdown failed: %v\n", err)
+			}
+		case <-done:
 		}
+	}()
+	return nil
+}
+
+// Stop shuts the node down and closes its databases
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.backend == nil {
+		return ErrNodeNotRunning
+	}
+
+	err := n.backend.Close()
+	if n.config.PIDFile != "" {
+		RemovePIDFile(n.config.PIDFile)
 	}
+	close(n.done)
+	n.backend = nil
+	return err
+}
 
-	// Create the P2P blockchain node
-	node, err := NewP2PBlockchainNode(p2pPort, apiPort, config)
+// Done returns a channel closed when the node last started stops, or nil if it never started
+func (n *Node) Done() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.done
+}
+
+// Backend returns the running node's chain, mempool, wallet and services, or nil if the
+// node isn't running
+func (n *Node) Backend() *P2PBlockchainNode {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.backend
+}
+
+// StartNode runs the node until it receives SIGINT or SIGTERM
+func StartNode(config *CLIConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	node, err := NewNode(config)
 	if err != nil {
-		return fmt.Errorf("failed to create blockchain node: %w", err)
+		return err
+	}
+	if err := node.Start(ctx); err != nil {
+		return err
 	}
 
 	fmt.Printf("🌑 Shadowy Node Started\n")
-	fmt.Printf("  P2P Port: %d\n", p2pPort)
-	fmt.Printf("  API Port: %d\n", apiPort)
+	fmt.Printf("  P2P Port: %d\n", config.P2PPort)
+	fmt.Printf("  API Port: %d\n", config.APIPort)
 	fmt.Printf("\nPress Ctrl+C to stop...\n")
 
-	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
-
+	<-ctx.Done()
 	fmt.Println("\nShutting down node...")
-	return node.Close()
+	if err := node.Stop(); err != nil && !errors.Is(err, ErrNodeNotRunning) {
+		return err
+	}
+	return nil
 }
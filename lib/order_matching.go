@@ -0,0 +1,322 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Offers created with AutoMatch are limit orders: besides being accepted by hand, they
+// are kept in an order book that block proposers match at proposal time. Orders fill
+// completely or not at all. Each unmatched order, oldest first, is paired with the
+// crossing order offering the best price (oldest first on ties) and settles at the older
+// order's price: it receives exactly its want amount, the newer order receives all of
+// the older order's tokens and is refunded what it did not need to pay. Settlements are
+// carried in the block like the coinbase and checked by every validator against the book.
+
+const OrderBookPrefix = "orderbook:" // orderbook:{offer_tx_id} -> LimitOrder
+
+// MatchOffersData represents the data stored in a TX_MATCH_OFFERS transaction
+type MatchOffersData struct {
+	MakerOfferTxID string `json:"maker_offer_tx_id"` // Older offer, whose price is used
+	TakerOfferTxID string `json:"taker_offer_tx_id"` // Newer offer
+}
+
+// LimitOrder is an open auto-match offer in the order book
+type LimitOrder struct {
+	OfferData
+	OfferTxID string `json:"offer_tx_id"`
+	Height    uint64 `json:"height"` // Block the offer was included in, for time priority
+}
+
+// bookOffer adds an offer to the order book if it opted in to automatic matching
+func (store *UTXOStore) bookOffer(offerTx *Transaction, offerTxID string, height uint64) error {
+	var order LimitOrder
+	if err := json.Unmarshal(offerTx.Data, &order.OfferData); err != nil {
+		return fmt.Errorf("failed to parse offer data: %w", err)
+	}
+	if !order.AutoMatch {
+		return nil
+	}
+	order.OfferTxID = offerTxID
+	order.Height = height
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order: %w", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(OrderBookPrefix+offerTxID), data); err != nil {
+		return fmt.Errorf("failed to store order: %w", err)
+	}
+	return nil
+}
+
+// unbookOffer removes an offer from the order book; offers not in it are ignored
+func (store *UTXOStore) unbookOffer(offerTxID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Delete([]byte(OrderBookPrefix + offerTxID)); err != nil {
+		return fmt.Errorf("failed to remove order: %w", err)
+	}
+	return nil
+}
+
+// GetOrder returns an open order from the order book, or nil if there is none
+func (store *UTXOStore) GetOrder(offerTxID string) (*LimitOrder, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(OrderBookPrefix + offerTxID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var order LimitOrder
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("corrupt order %s", offerTxID)
+	}
+	return &order, nil
+}
+
+// OpenOrders returns every order in the order book, oldest first
+func (store *UTXOStore) OpenOrders() ([]*LimitOrder, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(OrderBookPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var orders []*LimitOrder
+	for ; iterator.Valid(); iterator.Next() {
+		var order LimitOrder
+		if err := json.Unmarshal(iterator.Value(), &order); err != nil {
+			return nil, fmt.Errorf("corrupt order %s", iterator.Key())
+		}
+		orders = append(orders, &order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].before(orders[j]) })
+	return orders, nil
+}
+
+// before reports whether o has time priority over other
+func (o *LimitOrder) before(other *LimitOrder) bool {
+	if o.Height != other.Height {
+		return o.Height < other.Height
+	}
+	return o.OfferTxID < other.OfferTxID
+}
+
+// crosses reports whether o and other can fill each other completely
+func (o *LimitOrder) crosses(other *LimitOrder) bool {
+	return o.HaveTokenID == other.WantTokenID && o.WantTokenID == other.HaveTokenID &&
+		o.HaveAmount >= other.WantAmount && other.HaveAmount >= o.WantAmount
+}
+
+// pricedAbove reports whether o offers more of its token per unit wanted than other
+func (o *LimitOrder) pricedAbove(other *LimitOrder) bool {
+	hiA, loA := bits.Mul64(o.HaveAmount, other.WantAmount)
+	hiB, loB := bits.Mul64(other.HaveAmount, o.WantAmount)
+	return hiA > hiB || (hiA == hiB && loA > loB)
+}
+
+// matchOrders pairs crossing orders by price-time priority, skipping orders expired at
+// height. orders must be oldest first.
+func matchOrders(orders []*LimitOrder, height uint64) [][2]*LimitOrder {
+	matched := make(map[string]bool)
+	var matches [][2]*LimitOrder
+	for i, maker := range orders {
+		if matched[maker.OfferTxID] || height > maker.ExpiresAtBlock {
+			continue
+		}
+		var best *LimitOrder
+		for _, taker := range orders[i+1:] {
+			if matched[taker.OfferTxID] || height > taker.ExpiresAtBlock || !maker.crosses(taker) {
+				continue
+			}
+			if best == nil || taker.pricedAbove(best) {
+				best = taker
+			}
+		}
+		if best != nil {
+			matched[maker.OfferTxID] = true
+			matched[best.OfferTxID] = true
+			matches = append(matches, [2]*LimitOrder{maker, best})
+		}
+	}
+	return matches
+}
+
+// settlementOutputs pays out a match at the maker's price, stamping token outputs with
+// their mint version in registry
+func settlementOutputs(registry *TokenRegistry, maker, taker *LimitOrder) []*TxOutput {
+	builder := NewTxBuilder(TxTypeMatchOffers).SetRegistry(registry).
+		AddOutput(maker.OfferAddress, maker.WantAmount, maker.WantTokenID).
+		AddOutput(taker.OfferAddress, maker.HaveAmount, maker.HaveTokenID)
+	if refund := taker.HaveAmount - maker.WantAmount; refund > 0 {
+		builder.AddOutput(taker.OfferAddress, refund, taker.HaveTokenID)
+	}
+	return builder.Build().Outputs
+}
+
+// newSettlementTransaction creates the transaction settling a match
+func newSettlementTransaction(registry *TokenRegistry, maker, taker *LimitOrder) (*Transaction, error) {
+	data, err := json.Marshal(MatchOffersData{MakerOfferTxID: maker.OfferTxID, TakerOfferTxID: taker.OfferTxID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal match data: %w", err)
+	}
+	builder := NewTxBuilder(TxTypeMatchOffers).SetData(data)
+	for _, output := range settlementOutputs(registry, maker, taker) {
+		builder.AddCustomOutput(output)
+	}
+	return builder.Build(), nil
+}
+
+// MatchOrders returns settlement transactions for the order book as of the block at
+// height. Offers in skip (accepted or cancelled by transactions in the same block) are
+// left alone.
+func (bc *Blockchain) MatchOrders(height uint64, skip map[string]bool) ([]*Transaction, error) {
+	orders, err := bc.utxoStore.OpenOrders()
+	if err != nil {
+		return nil, err
+	}
+	open := orders[:0]
+	for _, order := range orders {
+		if !skip[order.OfferTxID] {
+			open = append(open, order)
+		}
+	}
+
+	var settlements []*Transaction
+	for _, match := range matchOrders(open, height) {
+		tx, err := newSettlementTransaction(bc.tokenRegistry, match[0], match[1])
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, tx)
+	}
+	return settlements, nil
+}
+
+// referencedOffers returns the offers accepted or cancelled by txs
+func referencedOffers(txs []*Transaction) map[string]bool {
+	offers := make(map[string]bool)
+	for _, tx := range txs {
+		switch tx.TxType {
+		case TxTypeAcceptOffer:
+			var acceptData AcceptOfferData
+			if json.Unmarshal(tx.Data, &acceptData) == nil {
+				offers[acceptData.OfferTxID] = true
+			}
+		case TxTypeCancelOffer:
+			var cancelData CancelOfferData
+			if json.Unmarshal(tx.Data, &cancelData) == nil {
+				offers[cancelData.OfferTxID] = true
+			}
+		}
+	}
+	return offers
+}
+
+// closesSettledOffer reports whether tx accepts or cancels an auto-match offer that has
+// left the order book, which would pay out tokens a settlement already paid
+func (bc *Blockchain) closesSettledOffer(tx *Transaction) bool {
+	for offerTxID := range referencedOffers([]*Transaction{tx}) {
+		offerTx, err := bc.utxoStore.GetTransaction(offerTxID)
+		if err != nil || offerTx == nil {
+			continue
+		}
+		var offerData OfferData
+		if json.Unmarshal(offerTx.Data, &offerData) != nil || !offerData.AutoMatch {
+			continue
+		}
+		if order, err := bc.utxoStore.GetOrder(offerTxID); err == nil && order == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSettlements checks a block's settlements against the order book. They must
+// directly follow the coinbase in the block's transaction list, so the book they are
+// checked against is the one they are applied to.
+func (bc *Blockchain) ValidateSettlements(block *Block) error {
+	offset := 0
+	if block.Coinbase != nil {
+		offset = 1
+	}
+	used := make(map[string]bool)
+	for i, tx := range block.Settlements {
+		txID, err := tx.ID()
+		if err != nil {
+			return fmt.Errorf("settlement %d: %w", i, err)
+		}
+		if offset+i >= len(block.Transactions) || block.Transactions[offset+i] != txID {
+			return fmt.Errorf("settlement %s is not listed after the coinbase", txID[:16])
+		}
+		if err := ValidateTransaction(tx); err != nil {
+			return fmt.Errorf("settlement %s: %w", txID[:16], err)
+		}
+
+		var matchData MatchOffersData
+		if err := json.Unmarshal(tx.Data, &matchData); err != nil {
+			return fmt.Errorf("settlement %s: invalid match data: %w", txID[:16], err)
+		}
+		if used[matchData.MakerOfferTxID] || used[matchData.TakerOfferTxID] {
+			return fmt.Errorf("settlement %s: offer already settled in this block", txID[:16])
+		}
+		used[matchData.MakerOfferTxID] = true
+		used[matchData.TakerOfferTxID] = true
+
+		maker, err := bc.utxoStore.GetOrder(matchData.MakerOfferTxID)
+		if err != nil {
+			return err
+		}
+		taker, err := bc.utxoStore.GetOrder(matchData.TakerOfferTxID)
+		if err != nil {
+			return err
+		}
+		if maker == nil || taker == nil {
+			return fmt.Errorf("settlement %s: offer is not in the order book", txID[:16])
+		}
+		if block.Index > maker.ExpiresAtBlock || block.Index > taker.ExpiresAtBlock {
+			return fmt.Errorf("settlement %s: offer has expired", txID[:16])
+		}
+		if taker.before(maker) || !maker.crosses(taker) {
+			return fmt.Errorf("settlement %s: offers do not match", txID[:16])
+		}
+
+		expected := settlementOutputs(bc.tokenRegistry, maker, taker)
+		if len(tx.Outputs) != len(expected) {
+			return fmt.Errorf("settlement %s: expected %d outputs, got %d", txID[:16], len(expected), len(tx.Outputs))
+		}
+		for j, output := range tx.Outputs {
+			want := expected[j]
+			if output.Address != want.Address || output.Amount != want.Amount || output.TokenID != want.TokenID {
+				return fmt.Errorf("settlement %s: output %d does not pay the matched amounts", txID[:16], j)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMatchOffersTransaction validates offer settlement transactions. They spend
+// nothing and are unsigned: the tokens paid out are the ones the two offers locked.
+func validateMatchOffersTransaction(tx *Transaction) error {
+	if len(tx.Inputs) != 0 {
+		return fmt.Errorf("match offers transaction must have no inputs")
+	}
+	if len(tx.Outputs) == 0 {
+		return fmt.Errorf("match offers transaction must have outputs")
+	}
+	if len(tx.Data) == 0 {
+		return fmt.Errorf("match offers transaction must reference its offers in Data field")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOrderMatching(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	tokenX := strings.Repeat("ab", 32)
+	shadow := GetGenesisToken().TokenID
+	offer := func(prevTxID string, haveToken string, have uint64, wantToken string, want uint64, autoMatch bool) (string, Address) {
+		kp, _ := GenerateKeyPair()
+		data, _ := json.Marshal(OfferData{
+			HaveTokenID: haveToken, WantTokenID: wantToken, HaveAmount: have, WantAmount: want,
+			ExpiresAtBlock: 100, OfferAddress: kp.Address(), AutoMatch: autoMatch,
+		})
+		funding := &UTXO{TxID: strings.Repeat(prevTxID, 32), OutputIndex: 0, Output: CreateTokenOutput(kp.Address(), have, haveToken, "custom", nil)}
+		if err := store.AddUTXO(funding); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+		tx := NewTxBuilder(TxTypeOffer).AddInput(funding.TxID, 0).SetData(data).Build()
+		tx.Sign(kp)
+		txID, _ := tx.ID()
+		if err := store.StoreTransaction(tx, 0); err != nil {
+			t.Fatalf("Failed to store offer: %v", err)
+		}
+		return txID, kp.Address()
+	}
+
+	// A sells 100 X for 200 SHADOW; B and C both cross it a block later, C at a better
+	// price; D would too but did not opt in
+	a, aliceAddr := offer("aa", tokenX, 100, shadow, 200, true)
+	if err := bc.AddBlock(bc.ProposeBlock([]string{a}, "match-test-proposer", nil), nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	b, _ := offer("bb", shadow, 250, tokenX, 90, true)
+	c, carolAddr := offer("cc", shadow, 300, tokenX, 100, true)
+	d, _ := offer("dd", shadow, 1000, tokenX, 1, false)
+	var offers []*Transaction
+	for _, txID := range []string{b, c, d} {
+		tx, _ := store.GetTransaction(txID)
+		offers = append(offers, tx)
+	}
+	var ordered []string
+	for _, tx := range CanonicalTxOrder(offers, utxoLookup(store)) {
+		txID, _ := tx.ID()
+		ordered = append(ordered, txID)
+	}
+	if err := bc.AddBlock(bc.ProposeBlock(ordered, "match-test-proposer", nil), nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	settlements, err := bc.MatchOrders(bc.GetHeight(), nil)
+	if err != nil || len(settlements) != 1 {
+		t.Fatalf("Expected one settlement, got %d (%v)", len(settlements), err)
+	}
+	var matchData MatchOffersData
+	json.Unmarshal(settlements[0].Data, &matchData)
+	if matchData.MakerOfferTxID != a || matchData.TakerOfferTxID != c {
+		t.Fatalf("Expected A matched with the better priced C, got %+v", matchData)
+	}
+
+	// Offers being accepted or cancelled in the block are not matched
+	if skipped, _ := bc.MatchOrders(bc.GetHeight(), map[string]bool{a: true}); len(skipped) != 0 {
+		t.Errorf("Skipped offer should not be matched, got %d settlements", len(skipped))
+	}
+
+	// Validators reject a settlement that does not pay the matched amounts
+	tampered := *settlements[0]
+	tampered.Outputs = []*TxOutput{CreateShadowOutput(aliceAddr, 201)}
+	tampered.Outputs = append(tampered.Outputs, settlements[0].Outputs[1:]...)
+	tamperedID, _ := tampered.ID()
+	block := bc.ProposeBlock([]string{tamperedID}, "match-test-proposer", nil)
+	block.Settlements = []*Transaction{&tampered}
+	if err := bc.AddBlock(block, nil); err == nil || !strings.Contains(err.Error(), "matched amounts") {
+		t.Fatalf("Expected tampered settlement to be rejected, got %v", err)
+	}
+
+	settlementID, _ := settlements[0].ID()
+	block = bc.ProposeBlock([]string{settlementID}, "match-test-proposer", nil)
+	block.Settlements = settlements
+	if err := bc.AddBlock(block, nil); err != nil {
+		t.Fatalf("Failed to add settlement block: %v", err)
+	}
+
+	// A gets its price; C gets all of A's tokens and the SHADOW it did not need back
+	if got, _ := store.GetIndexedBalance(aliceAddr, shadow); got != 200 {
+		t.Errorf("Expected maker to receive 200 SHADOW, got %d", got)
+	}
+	if got, _ := store.GetIndexedBalance(carolAddr, tokenX); got != 100 {
+		t.Errorf("Expected taker to receive 100 X, got %d", got)
+	}
+	if got, _ := store.GetIndexedBalance(carolAddr, shadow); got != 100 {
+		t.Errorf("Expected taker refund of 100 SHADOW, got %d", got)
+	}
+
+	orders, _ := store.OpenOrders()
+	if len(orders) != 1 || orders[0].OfferTxID != b {
+		t.Errorf("Only B should be left in the order book, got %d orders", len(orders))
+	}
+	if !bc.closesSettledOffer(NewTxBuilder(TxTypeAcceptOffer).SetData([]byte(`{"offer_tx_id":"` + a + `"}`)).Build()) {
+		t.Error("Accepting a settled offer should be refused")
+	}
+}
@@ -0,0 +1,427 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Outputs normally pay an address and are spent by its key. An output may instead carry
+// a spend condition, written as a descriptor:
+//
+//	pk(ADDR)             signed by the key of ADDR
+//	multi(K,ADDR,...)    signed by K of the listed keys
+//	after(H)             spent in a block at height H or later
+//	sha256(HASH)         the witness reveals a preimage hashing to HASH (hex)
+//	and(A,B), or(A,B)    both, or either, of two conditions
+//
+// The output's address is the hash of the canonical descriptor, so balances, history and
+// watch-only wallets follow it like any address. Spending it takes a witness on the
+// input: signatures over the input's SIGHASH_ALL payload and, for hashlocks, the
+// preimage. Hashlocks use SHA-256 so the same secret can lock a contract on another
+// chain, making HTLCs for cross-chain atomic swaps:
+//
+//	or(and(sha256(H),pk(RECIPIENT)),and(after(T),pk(REFUND)))
+
+const (
+	MaxDescriptorLength = 1024 // Longest descriptor accepted
+	MaxConditionDepth   = 8    // Deepest nesting of and/or
+	MaxMultisigKeys     = 16   // Most keys in a multi()
+	MaxPreimageLength   = 64   // Longest hashlock preimage
+)
+
+// Condition kinds
+const (
+	CondPubKey   = "pk"
+	CondMultisig = "multi"
+	CondAfter    = "after"
+	CondSHA256   = "sha256"
+	CondAnd      = "and"
+	CondOr       = "or"
+)
+
+// Condition is a parsed spend condition
+type Condition struct {
+	Kind      string
+	Keys      []Address    // pk, multi
+	Threshold int          // multi
+	Height    uint64       // after
+	Hash      []byte       // sha256
+	Subs      []*Condition // and, or
+}
+
+// SpendWitness satisfies the condition of the output an input spends
+type SpendWitness struct {
+	Signatures []WitnessSignature `json:"signatures,omitempty"`
+	Preimage   []byte             `json:"preimage,omitempty"` // Hashlock secret
+}
+
+// WitnessSignature is a key's signature over the input's SIGHASH_ALL payload
+type WitnessSignature struct {
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+// ParseCondition parses a descriptor
+func ParseCondition(descriptor string) (*Condition, error) {
+	if len(descriptor) > MaxDescriptorLength {
+		return nil, fmt.Errorf("descriptor is longer than %d characters", MaxDescriptorLength)
+	}
+	cond, err := parseCondition(strings.ReplaceAll(descriptor, " ", ""), 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	return cond, nil
+}
+
+// parseCondition parses one kind(args) term at depth
+func parseCondition(term string, depth int) (*Condition, error) {
+	if depth > MaxConditionDepth {
+		return nil, fmt.Errorf("nested deeper than %d", MaxConditionDepth)
+	}
+	open := strings.IndexByte(term, '(')
+	if open <= 0 || !strings.HasSuffix(term, ")") {
+		return nil, fmt.Errorf("expected kind(...), got %q", term)
+	}
+	kind := term[:open]
+	args, err := splitArgs(term[open+1 : len(term)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	cond := &Condition{Kind: kind}
+	switch kind {
+	case CondPubKey:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("pk takes one address")
+		}
+		addr, _, err := ParseAddress(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("pk: %w", err)
+		}
+		cond.Keys = []Address{addr}
+	case CondMultisig:
+		if len(args) < 2 || len(args)-1 > MaxMultisigKeys {
+			return nil, fmt.Errorf("multi takes a threshold and 1 to %d addresses", MaxMultisigKeys)
+		}
+		threshold, err := strconv.Atoi(args[0])
+		if err != nil || threshold < 1 || threshold > len(args)-1 {
+			return nil, fmt.Errorf("multi threshold must be between 1 and the number of keys")
+		}
+		cond.Threshold = threshold
+		seen := make(map[Address]bool)
+		for _, arg := range args[1:] {
+			addr, _, err := ParseAddress(arg)
+			if err != nil {
+				return nil, fmt.Errorf("multi: %w", err)
+			}
+			if seen[addr] {
+				return nil, fmt.Errorf("multi lists %s twice", arg)
+			}
+			seen[addr] = true
+			cond.Keys = append(cond.Keys, addr)
+		}
+	case CondAfter:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("after takes one height")
+		}
+		height, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil || height == 0 {
+			return nil, fmt.Errorf("after: invalid height %q", args[0])
+		}
+		cond.Height = height
+	case CondSHA256:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("sha256 takes one hash")
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("sha256: expected a %d-byte hex hash", sha256.Size)
+		}
+		cond.Hash = hash
+	case CondAnd, CondOr:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes two conditions", kind)
+		}
+		for _, arg := range args {
+			sub, err := parseCondition(arg, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			cond.Subs = append(cond.Subs, sub)
+		}
+	default:
+		return nil, fmt.Errorf("unknown condition %q", kind)
+	}
+	return cond, nil
+}
+
+// splitArgs splits a comma-separated argument list at the top nesting level
+func splitArgs(list string) ([]string, error) {
+	var args []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+	args = append(args, list[start:])
+	for _, arg := range args {
+		if arg == "" {
+			return nil, fmt.Errorf("empty argument")
+		}
+	}
+	return args, nil
+}
+
+// String returns the canonical descriptor
+func (c *Condition) String() string {
+	var args []string
+	switch c.Kind {
+	case CondPubKey:
+		args = []string{c.Keys[0].String()}
+	case CondMultisig:
+		args = []string{strconv.Itoa(c.Threshold)}
+		for _, key := range c.Keys {
+			args = append(args, key.String())
+		}
+	case CondAfter:
+		args = []string{strconv.FormatUint(c.Height, 10)}
+	case CondSHA256:
+		args = []string{hex.EncodeToString(c.Hash)}
+	case CondAnd, CondOr:
+		for _, sub := range c.Subs {
+			args = append(args, sub.String())
+		}
+	}
+	return c.Kind + "(" + strings.Join(args, ",") + ")"
+}
+
+// Address returns the address outputs locked by the condition pay
+func (c *Condition) Address() Address {
+	return Address(blake2b.Sum256([]byte("condition:" + c.String())))
+}
+
+// HTLCCondition is a hashed timelock contract: the recipient spends it by revealing the
+// preimage of hash, or the refund key spends it from height timeout on
+func HTLCCondition(recipient, refund Address, hash []byte, timeout uint64) *Condition {
+	return &Condition{Kind: CondOr, Subs: []*Condition{
+		{Kind: CondAnd, Subs: []*Condition{{Kind: CondSHA256, Hash: hash}, {Kind: CondPubKey, Keys: []Address{recipient}}}},
+		{Kind: CondAnd, Subs: []*Condition{{Kind: CondAfter, Height: timeout}, {Kind: CondPubKey, Keys: []Address{refund}}}},
+	}}
+}
+
+// satisfied reports whether a spend at height by signers revealing preimage meets the condition
+func (c *Condition) satisfied(height uint64, signers map[Address]bool, preimage []byte) bool {
+	switch c.Kind {
+	case CondPubKey:
+		return signers[c.Keys[0]]
+	case CondMultisig:
+		signed := 0
+		for _, key := range c.Keys {
+			if signers[key] {
+				signed++
+			}
+		}
+		return signed >= c.Threshold
+	case CondAfter:
+		return height >= c.Height
+	case CondSHA256:
+		hash := sha256.Sum256(preimage)
+		return preimage != nil && string(hash[:]) == string(c.Hash)
+	case CondAnd:
+		return c.Subs[0].satisfied(height, signers, preimage) && c.Subs[1].satisfied(height, signers, preimage)
+	case CondOr:
+		return c.Subs[0].satisfied(height, signers, preimage) || c.Subs[1].satisfied(height, signers, preimage)
+	}
+	return false
+}
+
+// CreateConditionOutput creates an output of amount of tokenID locked by cond, stamped
+// with the token's mint version in registry
+func CreateConditionOutput(registry *TokenRegistry, cond *Condition, amount uint64, tokenID string) *TxOutput {
+	var output *TxOutput
+	if tokenID == "" || tokenID == "SHADOW" || tokenID == GetGenesisToken().TokenID {
+		output = CreateShadowOutput(cond.Address(), amount)
+	} else {
+		output = registry.NewTokenOutput(cond.Address(), amount, tokenID, "custom", nil)
+	}
+	output.Condition = cond.String()
+	return output
+}
+
+// AddConditionOutput adds an output locked by cond
+func (tb *TxBuilder) AddConditionOutput(cond *Condition, amount uint64, tokenID string) *TxBuilder {
+	tb.outputs = append(tb.outputs, CreateConditionOutput(tb.registry, cond, amount, tokenID))
+	return tb
+}
+
+// AddWitnessSignature signs input index's SIGHASH_ALL payload into its witness. Sign
+// after the inputs and outputs are final; a whole-transaction signature goes last.
+func (tx *Transaction) AddWitnessSignature(index int, signer Signer) error {
+	hash, err := tx.InputSigningHash(index, SigHashAll)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign input %d: %w", index, err)
+	}
+	pkBytes, err := PublicKeyToBytes(signer.PublicKey())
+	if err != nil {
+		return fmt.Errorf("failed to serialize public key: %w", err)
+	}
+
+	input := tx.Inputs[index]
+	if input.Witness == nil {
+		input.Witness = &SpendWitness{}
+	}
+	input.Witness.Signatures = append(input.Witness.Signatures, WitnessSignature{PublicKey: pkBytes, Signature: signature})
+	return nil
+}
+
+// SetWitnessPreimage reveals a hashlock preimage in input index's witness
+func (tx *Transaction) SetWitnessPreimage(index int, preimage []byte) {
+	input := tx.Inputs[index]
+	if input.Witness == nil {
+		input.Witness = &SpendWitness{}
+	}
+	input.Witness.Preimage = preimage
+}
+
+// fullyWitnessed reports whether every input carries a witness, which then authorizes
+// the transaction without a transaction signature
+func (tx *Transaction) fullyWitnessed() bool {
+	for _, input := range tx.Inputs {
+		if input.Witness == nil {
+			return false
+		}
+	}
+	return len(tx.Inputs) > 0
+}
+
+// validateOutputConditions checks conditioned outputs: only sends and HTLC locks may
+// create them, HTLCs only through locks, the descriptor must be canonical and the output
+// must pay its address
+func validateOutputConditions(tx *Transaction) error {
+	for i, output := range tx.Outputs {
+		if output.Condition == "" {
+			continue
+		}
+		if tx.TxType != TxTypeSend && tx.TxType != TxTypeHTLCLock {
+			return fmt.Errorf("output %d: conditioned outputs can only be created by send transactions", i)
+		}
+		if output.Vesting != nil {
+			return fmt.Errorf("output %d: an output can't both vest and carry a condition", i)
+		}
+		cond, err := ParseCondition(output.Condition)
+		if err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+		if _, isHTLC := cond.HTLCTerms(); isHTLC != (tx.TxType == TxTypeHTLCLock) {
+			if isHTLC {
+				return fmt.Errorf("output %d: HTLC outputs are created by htlc_lock transactions", i)
+			}
+			return fmt.Errorf("output %d: htlc_lock outputs must carry an HTLC condition", i)
+		}
+		if cond.String() != output.Condition {
+			return fmt.Errorf("output %d: descriptor is not canonical, expected %s", i, cond.String())
+		}
+		if cond.Address() != output.Address {
+			return fmt.Errorf("output %d: address does not match its condition", i)
+		}
+	}
+	for i, input := range tx.Inputs {
+		if input.Witness != nil && len(input.Witness.Preimage) > MaxPreimageLength {
+			return fmt.Errorf("input %d: preimage is longer than %d bytes", i, MaxPreimageLength)
+		}
+	}
+	return nil
+}
+
+// CheckSpendConditions verifies that every input spending a conditioned output at height
+// carries a witness satisfying the condition, and that no other input carries one.
+// lookup resolves spent outputs; inputs it can't resolve are left to other validation.
+func CheckSpendConditions(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	for i, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil {
+			continue
+		}
+		if spent.Condition == "" {
+			if input.Witness != nil {
+				return fmt.Errorf("input %d carries a witness but %s:%d has no condition", i, input.PrevTxID, input.OutputIndex)
+			}
+			continue
+		}
+
+		cond, err := ParseCondition(spent.Condition)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		if input.Witness == nil {
+			return fmt.Errorf("input %d spends conditioned output %s:%d without a witness", i, input.PrevTxID, input.OutputIndex)
+		}
+
+		signers, err := witnessSigners(tx, i)
+		if err != nil {
+			return err
+		}
+		if !cond.satisfied(height, signers, input.Witness.Preimage) {
+			return fmt.Errorf("input %d does not satisfy %s at block %d", i, spent.Condition, height)
+		}
+	}
+	return nil
+}
+
+// witnessSigners verifies the witness signatures of input i and returns the addresses
+// of their keys
+func witnessSigners(tx *Transaction, i int) (map[Address]bool, error) {
+	hash, err := tx.InputSigningHash(i, SigHashAll)
+	if err != nil {
+		return nil, fmt.Errorf("input %d: %w", i, err)
+	}
+	signers := make(map[Address]bool)
+	for _, sig := range tx.Inputs[i].Witness.Signatures {
+		publicKey, err := PublicKeyFromBytes(sig.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: invalid witness public key: %w", i, err)
+		}
+		valid, err := verifyCachedSignature(hash, sig.Signature, sig.PublicKey)
+		if err != nil || !valid {
+			return nil, fmt.Errorf("input %d: invalid witness signature", i)
+		}
+		signers[DeriveAddress(publicKey)] = true
+	}
+	return signers, nil
+}
+
+// ValidateSpendConditions rejects a block with a transaction that doesn't satisfy the
+// conditions of the outputs it spends
+func (bc *Blockchain) ValidateSpendConditions(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, CheckSpendConditions)
+}
+
+// checkSpendConditions rejects a transaction that can't spend its conditioned inputs in
+// the next block
+func (mp *Mempool) checkSpendConditions(tx *Transaction) error {
+	return mp.checkSpends(tx, CheckSpendConditions)
+}
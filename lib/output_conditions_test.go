@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	hash := sha256.Sum256([]byte("swap-secret"))
+
+	htlc := HTLCCondition(bob.Address(), alice.Address(), hash[:], 500)
+	parsed, err := ParseCondition(htlc.String())
+	if err != nil {
+		t.Fatalf("Failed to parse canonical HTLC: %v", err)
+	}
+	if parsed.String() != htlc.String() || parsed.Address() != htlc.Address() {
+		t.Fatalf("Round trip changed the descriptor: %s", parsed.String())
+	}
+	if htlc.Address() == alice.Address() || htlc.Address() == bob.Address() {
+		t.Fatal("Expected the condition address to differ from its keys")
+	}
+
+	for _, descriptor := range []string{
+		"",
+		"pk()",
+		"pkh(" + alice.Address().String() + ")",
+		"multi(3," + alice.Address().String() + "," + bob.Address().String() + ")",
+		"multi(1," + alice.Address().String() + "," + alice.Address().String() + ")",
+		"after(0)",
+		"sha256(abcd)",
+		"and(after(5))",
+		"or(after(5),after(6)",
+		strings.Repeat("and(after(1),", MaxConditionDepth+1) + "after(1)" + strings.Repeat(")", MaxConditionDepth+1),
+	} {
+		if _, err := ParseCondition(descriptor); err == nil {
+			t.Fatalf("Expected %q to be refused", descriptor)
+		}
+	}
+}
+
+func TestSpendConditions(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	carol, _ := GenerateKeyPair()
+	secret := []byte("swap-secret")
+	hash := sha256.Sum256(secret)
+
+	// Alice locks 1000 in an HTLC: bob claims with the secret, or alice refunds from 500
+	htlc := HTLCCondition(bob.Address(), alice.Address(), hash[:], 500)
+	lock := NewTxBuilder(TxTypeHTLCLock).AddInput("htlc-test-funding", 0).
+		AddConditionOutput(htlc, 1000, "").Build()
+	lock.Sign(alice)
+	if err := ValidateTransaction(lock); err != nil {
+		t.Fatalf("Expected the lock to validate: %v", err)
+	}
+	lockID, _ := lock.ID()
+	lookup := func(txID string, index uint32) *TxOutput {
+		if txID == lockID && int(index) < len(lock.Outputs) {
+			return lock.Outputs[index]
+		}
+		return nil
+	}
+
+	// An output whose address doesn't match its condition is refused
+	forged := NewTxBuilder(TxTypeHTLCLock).AddInput("htlc-test-funding", 1).
+		AddConditionOutput(htlc, 1000, "").Build()
+	forged.Outputs[0].Address = bob.Address()
+	forged.Sign(alice)
+	if err := ValidateTransaction(forged); err == nil {
+		t.Fatal("Expected a mismatched condition address to be refused")
+	}
+
+	spend := func(to Address, preimage []byte, signers ...*KeyPair) *Transaction {
+		tx := NewTxBuilder(TxTypeSend).AddInput(lockID, 0).
+			AddCustomOutput(CreateShadowOutput(to, 900)).Build()
+		if preimage != nil {
+			tx.SetWitnessPreimage(0, preimage)
+		}
+		for _, kp := range signers {
+			if err := tx.AddWitnessSignature(0, NewLocalSigner(kp)); err != nil {
+				t.Fatalf("Failed to sign witness: %v", err)
+			}
+		}
+		return tx
+	}
+
+	// Bob claims with the secret; the witness alone authorizes the transaction
+	claim := spend(bob.Address(), secret, bob)
+	if err := ValidateTransaction(claim); err != nil {
+		t.Fatalf("Expected a witnessed claim to validate: %v", err)
+	}
+	if err := CheckSpendConditions(claim, 10, lookup); err != nil {
+		t.Fatalf("Expected bob's claim to satisfy the HTLC: %v", err)
+	}
+	if err := CheckInputSignatures(claim, 10, lookup); err != nil {
+		t.Fatalf("Expected input ownership to defer to the condition: %v", err)
+	}
+	if err := CheckSpendConditions(spend(bob.Address(), []byte("wrong"), bob), 10, lookup); err == nil {
+		t.Fatal("Expected a wrong preimage to be refused")
+	}
+	if err := CheckSpendConditions(spend(carol.Address(), secret, carol), 10, lookup); err == nil {
+		t.Fatal("Expected the secret alone not to let carol claim")
+	}
+
+	// Alice refunds only after the timeout
+	refund := spend(alice.Address(), nil, alice)
+	if err := CheckSpendConditions(refund, 499, lookup); err == nil {
+		t.Fatal("Expected a refund before the timeout to be refused")
+	}
+	if err := CheckSpendConditions(refund, 500, lookup); err != nil {
+		t.Fatalf("Expected a refund at the timeout: %v", err)
+	}
+
+	// Changing the outputs after signing invalidates the witness signature
+	refund.Outputs[0].Address = carol.Address()
+	if err := CheckSpendConditions(refund, 500, lookup); err == nil {
+		t.Fatal("Expected a redirected refund to be refused")
+	}
+
+	// A plain signed spend can't take a conditioned output
+	plain := NewTxBuilder(TxTypeSend).AddInput(lockID, 0).
+		AddCustomOutput(CreateShadowOutput(carol.Address(), 900)).Build()
+	plain.Sign(carol)
+	if err := CheckSpendConditions(plain, 1000, lookup); err == nil {
+		t.Fatal("Expected a spend without a witness to be refused")
+	}
+
+	// Two of three keys spend a multisig output
+	multisig, err := ParseCondition("multi(2," + alice.Address().String() + "," + bob.Address().String() + "," + carol.Address().String() + ")")
+	if err != nil {
+		t.Fatalf("Failed to parse multisig: %v", err)
+	}
+	lock.Outputs[0] = CreateConditionOutput(nil, multisig, 1000, "")
+	if err := CheckSpendConditions(spend(alice.Address(), nil, carol), 10, lookup); err == nil {
+		t.Fatal("Expected one signature to be refused")
+	}
+	if err := CheckSpendConditions(spend(alice.Address(), nil, carol, alice), 10, lookup); err != nil {
+		t.Fatalf("Expected two signatures to satisfy the multisig: %v", err)
+	}
+}
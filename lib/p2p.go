@@ -8,6 +8,7 @@ import (
 
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	"github.com/multiformats/go-multiaddr"
@@ -24,6 +25,10 @@ type P2PNode struct {
 	cancel   context.CancelFunc
 	peers    map[peer.ID]peer.AddrInfo
 	peerLock sync.RWMutex
+
+	gater      *subnetGater      // Limits outbound peers per subnet
+	peerConfig PeerManagerConfig // Set by StartPeerManager
+	book       *peerBook         // Peers learned by peer exchange, loaded by StartPeerManager
 }
 
 // discoveryNotifee implements the mdns.Notifee interface for peer discovery
@@ -75,32 +80,38 @@ func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
 	}()
 }
 
-// NewP2PNode creates a new libp2p node
-func NewP2PNode(listenPort int) (*P2PNode, error) {
+// NewP2PNode creates a new libp2p node listening on listenAddrs (see ResolveP2PListenAddrs)
+func NewP2PNode(listenAddrs []multiaddr.Multiaddr) (*P2PNode, error) {
+	if len(listenAddrs) == 0 {
+		return nil, fmt.Errorf("no listen addresses")
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Create multiaddr for listening
-	listenAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort))
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create listen address: %w", err)
-	}
+	// Outbound dials are gated on subnet diversity (eclipse resistance)
+	gater := &subnetGater{maxPerSubnet: DefaultMaxPeersPerSubnet}
 
 	// Create libp2p host
 	h, err := libp2p.New(
-		libp2p.ListenAddrs(listenAddr),
+		libp2p.ListenAddrs(listenAddrs...),
 		libp2p.DisableRelay(), // We don't need relay for local network
+		libp2p.ConnectionGater(gater),
 	)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
 	}
 
+	gater.mu.Lock()
+	gater.network = h.Network()
+	gater.mu.Unlock()
+
 	node := &P2PNode{
 		Host:   h,
 		ctx:    ctx,
 		cancel: cancel,
 		peers:  make(map[peer.ID]peer.AddrInfo),
+		gater:  gater,
+		book:   &peerBook{peers: make(map[peer.ID]*KnownPeer)},
 	}
 
 	// Setup mDNS discovery (for local network)
@@ -191,8 +202,32 @@ func (n *P2PNode) ConnectToPeer(addrStr string) error {
 	return nil
 }
 
+// DisconnectPeer closes every connection to a peer and forgets it. The peer manager and
+// mDNS may connect to it again later.
+func (n *P2PNode) DisconnectPeer(id peer.ID) error {
+	if n.Host.Network().Connectedness(id) != network.Connected {
+		return fmt.Errorf("not connected to %s", id.String())
+	}
+	if err := n.Host.Network().ClosePeer(id); err != nil {
+		return fmt.Errorf("failed to disconnect: %w", err)
+	}
+
+	n.peerLock.Lock()
+	delete(n.peers, id)
+	n.peerLock.Unlock()
+
+	fmt.Printf("[P2P] Manually disconnected from peer: %s\n", id.String())
+	return nil
+}
+
 // Close shuts down the P2P node
 func (n *P2PNode) Close() error {
+	if err := n.SaveAnchors(); err != nil {
+		fmt.Printf("[P2P] Warning: failed to save anchor peers: %v\n", err)
+	}
+	if err := n.book.save(); err != nil {
+		fmt.Printf("[P2P] Warning: failed to save peer book: %v\n", err)
+	}
 	n.cancel()
 	return n.Host.Close()
 }
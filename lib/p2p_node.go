@@ -1,34 +1,136 @@
 package lib
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
+
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 )
 
 // P2PBlockchainNode represents a complete blockchain node with P2P and mempool
 type P2PBlockchainNode struct {
-	P2P       *P2PNode
-	Mempool   *Mempool
-	Wallet    *NodeWallet
-	Chain     *Blockchain
-	Consensus *ConsensusEngine
-	apiPort   int
-	apiKey    string // Optional API key for write endpoints
+	P2P        *P2PNode
+	Mempool    *Mempool
+	Wallet     *NodeWallet
+	Chain      *Blockchain
+	Consensus  *ConsensusEngine
+	Addresses  *AddressBook        // Local labels for addresses
+	Sync       *BlockSyncClient    // Block download from peers
+	SyncServer *BlockSyncHandler   // Serves blocks and headers to syncing peers within limits
+	Handshakes *HandshakeService   // Signed hellos exchanged with peers: version, chain, height and services
+	MiningPool *PoolOperator       // Set when running as a mining pool operator
+	WalletTxs  *WalletTxTracker    // Local transactions, rebroadcast until they confirm (nil when read-only)
+	Receive    *ReceiveAddressPool // Fresh derived receive addresses (nil when read-only or remote signing)
+	Watch      *WatchOnlyWallet    // Addresses monitored without their keys
+	Events     *EventHub           // Node events pushed to /api/ws subscribers
+	Memos      *MemoMonitor        // Flags payments to memo-required addresses that lack a memo
+	Orphans    *OrphanWatcher      // Reports transactions a reorg takes out of the chain
+	HTLCs      *HTLCMonitor        // Announces HTLC locks, claims, refunds and timeouts
+	Backups    *BackupScheduler    // Wallet and chain checkpoint backups, scheduled and on request
+	Airdrops   *AirdropManager     // Token distributions paid from the wallet (nil when read-only)
+	apiKeys    apiKeyring          // Optional API key for write endpoints, rotated by /api/admin/api_key/rotate
+	apiServer  *http.Server        // Set by startAPI, shut down by Close
+
+	idempotency *IdempotencyCache // Responses replayed for retried write requests
+	apiSigners  map[Address]bool  // Addresses whose signed requests may use write endpoints
+	requests    *RequestVerifier  // Checks signed requests and their nonces
+	readyMaxLag uint64            // Blocks behind the best peer /readyz tolerates
+	readOnly    bool              // No wallet, no farming, write endpoints disabled
+	dataDir     string            // Root of the node's files, "" = the working directory
+
+	requestAudit *RequestAuditLog // Calls to write endpoints; nil on read-only nodes
+
+	snapshotLock sync.Mutex // Held while /api/admin/snapshot writes an archive
+}
+
+// dataPath returns where one of the node's files lives
+func (n *P2PBlockchainNode) dataPath(name string) string {
+	return dataPathIn(n.dataDir, name)
+}
+
+// loadNodePlots loads the plots a node farms with from the first configured plot
+// directory, or ./plots if none is configured. Read-only nodes don't farm, and a node
+// without plots in ./plots runs without farming.
+func loadNodePlots(config *CLIConfig) (*PlotManager, error) {
+	if config.ReadOnly {
+		fmt.Printf("📖 Read-only node: farming disabled\n")
+		return nil, nil
+	}
+	if len(config.Dirs) > 0 {
+		// Use the first directory for plots (can be enhanced to support multiple)
+		plots, err := LoadPlotManager(config.Dirs[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize plot manager: %w", err)
+		}
+		return plots, nil
+	}
+	plots, err := LoadPlotManager("./plots")
+	if err != nil {
+		fmt.Printf("⚠️  No plots found in ./plots: %v\n", err)
+		fmt.Printf("⚠️  Node will run without farming capability\n")
+		return nil, nil
+	}
+	return plots, nil
 }
 
 // NewP2PBlockchainNode creates a new blockchain node
 func NewP2PBlockchainNode(p2pPort, apiPort int, config *CLIConfig) (*P2PBlockchainNode, error) {
+	if err := SetAddressFormat(config.AddressFormat, config.AcceptHexAddresses); err != nil {
+		return nil, err
+	}
+
+	// The node's files live under its own data dir, falling back to the process-wide one
+	dir := dataDir
+	if config.DataDir != "" {
+		var err error
+		if dir, err = resolveDataDir(config.DataDir); err != nil {
+			return nil, err
+		}
+	}
+	plots, err := loadNodePlots(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen addresses were validated with the config; resolve them again for callers that skip it
+	p2pAddrs, err := ResolveP2PListenAddrs(config.P2PListenAddrs, p2pPort, config.LocalhostOnly)
+	if err != nil {
+		return nil, err
+	}
+	apiAddr, err := ResolveAPIListenAddr(config.APIListenAddr, apiPort, config.LocalhostOnly)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create P2P node
-	p2p, err := NewP2PNode(p2pPort)
+	p2p, err := NewP2PNode(p2pAddrs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create P2P node: %w", err)
 	}
 
+	// Diverse outbound peers, anchor reconnection, and the connectivity watchdog
+	p2p.StartPeerManager(PeerManagerConfig{
+		Seeds:               config.Seeds,
+		MinOutboundPeers:    config.MinOutboundPeers,
+		TargetOutboundPeers: config.TargetOutboundPeers,
+		MaxPeersPerSubnet:   config.MaxPeersPerSubnet,
+		AnchorsPath:         dataPathIn(dir, DefaultAnchorsPath),
+		PeerBookPath:        dataPathIn(dir, DefaultPeerBookPath),
+	})
+
 	// Create shared gossipsub instance
 	ctx := context.Background()
 	ps, err := pubsub.NewGossipSub(ctx, p2p.Host)
@@ -37,6 +139,13 @@ func NewP2PBlockchainNode(p2pPort, apiPort int, config *CLIConfig) (*P2PBlockcha
 		return nil, fmt.Errorf("failed to create gossipsub: %w", err)
 	}
 
+	// Learn and gossip signed peer addresses
+	if config.PeerExchange {
+		if err := p2p.StartPeerExchange(ps); err != nil {
+			fmt.Printf("[P2P] Warning: peer exchange disabled: %v\n", err)
+		}
+	}
+
 	// Create mempool with expiration and size limits from config
 	expiryBlocks := config.MempoolTxExpiryBlocks
 	maxSizeMB := config.MempoolMaxSizeMB
@@ -52,27 +161,107 @@ func NewP2PBlockchainNode(p2pPort, apiPort int, config *CLIConfig) (*P2PBlockcha
 		return nil, fmt.Errorf("failed to create mempool: %w", err)
 	}
 
-	// Create wallet for this node (with optional encryption)
-	wallet, err := LoadOrCreateNodeWallet(config.WalletPassword)
+	// Create wallet for this node (with optional encryption); read-only nodes have none
+	var wallet *NodeWallet
+	if !config.ReadOnly {
+		wallet, err = loadOrCreateNodeWalletIn(dir, config.WalletPassword)
+		if err != nil {
+			p2p.Close()
+			mempool.Close()
+			return nil, fmt.Errorf("failed to create wallet: %w", err)
+		}
+	}
+
+	// Delegate signing to an external keystore if configured
+	if config.RemoteSignerURL != "" && wallet != nil {
+		remoteSigner, err := NewRemoteSigner(config.RemoteSignerURL, config.RemoteSignerKeyID, config.RemoteSignerToken)
+		if err != nil {
+			p2p.Close()
+			mempool.Close()
+			return nil, fmt.Errorf("failed to connect to remote signer: %w", err)
+		}
+		wallet.UseSigner(remoteSigner)
+		fmt.Printf("🔐 Using remote signer %s (address %s)\n", config.RemoteSignerURL, wallet.Address.String()[:16]+"...")
+	}
+
+	// Create blockchain with persistent storage
+	chain, err := NewBlockchain(dataPathIn(dir, "blockchain"))
 	if err != nil {
 		p2p.Close()
 		mempool.Close()
-		return nil, fmt.Errorf("failed to create wallet: %w", err)
+		return nil, fmt.Errorf("failed to create blockchain: %w", err)
 	}
 
-	// Create blockchain with persistent storage
-	chain, err := NewBlockchain("blockchain")
+	// Index memos of new blocks (and of every block, when reindexing)
+	chain.GetUTXOStore().SetMemoIndex(config.MemoIndex)
+
+	// Rebuild derived state from stored blocks if requested
+	if config.Reindex {
+		if err := chain.Reindex(); err != nil {
+			p2p.Close()
+			mempool.Close()
+			chain.Close()
+			return nil, fmt.Errorf("reindex failed: %w", err)
+		}
+	}
+
+	// Configure pruning; archive nodes keep all proofs, spent UTXOs and transactions
+	if config.Archive {
+		chain.SetProofPruningDepth(0)
+		chain.SetUTXOPruneDepth(0)
+		chain.SetTxPruneDepth(0)
+	} else {
+		chain.SetProofPruningDepth(config.ProofPruningDepth)
+		chain.SetUTXOPruneDepth(config.UTXOPruneDepth)
+		chain.SetTxPruneDepth(config.TxPruneDepth)
+	}
+	chain.GetUTXOStore().SetCacheSize(config.UTXOCacheSize)
+	chain.GetUTXOStore().SetCacheMemoryLimit(int64(config.UTXOCacheMB) * 1024 * 1024)
+	if config.UTXOInMemory {
+		if _, err := chain.GetUTXOStore().EnableMemorySet(); err != nil {
+			p2p.Close()
+			mempool.Close()
+			chain.Close()
+			return nil, fmt.Errorf("failed to load UTXO set into memory: %w", err)
+		}
+	}
+	mempool.SetRelayPolicy(RelayPolicy{MinFee: config.MinRelayFee, MinFeeRate: config.MinRelayFeeRate, DustThreshold: config.DustThreshold}, chain.GetUTXOStore())
+	mempool.SetTokenFeePolicy(config.AcceptTokenFees, chain.GetPoolRegistry())
+	mempool.SetTokenRegistry(chain.TokenRegistry())
+	events := NewEventHub()
+	mempool.SetEventHub(events)
+	chain.StartCompactionScheduler(time.Duration(config.DBCompactionHours) * time.Hour)
+
+	// Open the local address book
+	addressBook, err := NewAddressBook(dataPathIn(dir, "addressbook.db"))
 	if err != nil {
 		p2p.Close()
 		mempool.Close()
-		return nil, fmt.Errorf("failed to create blockchain: %w", err)
+		chain.Close()
+		return nil, fmt.Errorf("failed to open address book: %w", err)
 	}
 
-	// Configure proof pruning
-	chain.SetProofPruningDepth(config.ProofPruningDepth)
+	// Blocks and transactions rejected from peers are kept for investigation
+	evidence, err := OpenEvidenceStore(dataPathIn(dir, DefaultEvidencePath))
+	if err != nil {
+		p2p.Close()
+		mempool.Close()
+		chain.Close()
+		addressBook.Close()
+		return nil, err
+	}
+	chain.SetEvidenceStore(evidence)
+	mempool.SetEvidenceStore(evidence)
 
 	// Setup sync protocol (for serving blocks to others)
-	SetupSyncProtocol(p2p.Host, chain)
+	syncServer := SetupSyncProtocol(p2p.Host, chain, SyncServeLimits{
+		MaxBlocksPerRequest: config.SyncMaxBlocks,
+		MaxSessions:         config.SyncMaxSessions,
+		PeerBytesPerSec:     int64(config.SyncPeerBandwidthKB) * 1024,
+	})
+
+	// Exchange signed hellos with peers, disconnecting those on another chain or protocol
+	handshakes := SetupHandshakeProtocol(p2p.Host, chain, LocalServices(config.Archive, config.ProofPruningDepth))
 
 	// Wait briefly for peers to connect, then sync if needed
 	fmt.Printf("[Node] Waiting for peers to connect...\n")
@@ -90,66 +279,336 @@ func NewP2PBlockchainNode(p2pPort, apiPort int, config *CLIConfig) (*P2PBlockcha
 		fmt.Printf("[Node] No peers available for sync, starting with local chain\n")
 	}
 
-	// Create consensus engine with shared gossip (AFTER sync)
-	consensus, err := NewConsensusEngine(chain, mempool, p2p.Host, ps, wallet, wallet.Address)
+	// Rewards go to the node wallet unless a separate (e.g. cold) wallet is configured
+	var rewardAddr Address
+	if wallet != nil {
+		rewardAddr = wallet.Address
+	}
+	if config.RewardAddress != "" {
+		if rewardAddr, err = ParseRewardAddress(config.RewardAddress); err != nil {
+			p2p.Close()
+			mempool.Close()
+			chain.Close()
+			addressBook.Close()
+			evidence.Close()
+			handshakes.Close()
+			return nil, err
+		}
+	}
+
+	// Create consensus engine with shared gossip (AFTER sync). It is the only consensus
+	// runtime and shares the chain, UTXO store and mempool with everything else.
+	fmt.Printf("[Node] Consensus engine: %s\n", ConsensusEngineGossip)
+	consensus, err := NewConsensusEngine(chain, mempool, p2p.Host, ps, wallet, plots, rewardAddr, config.FailoverRole)
 	if err != nil {
 		p2p.Close()
 		mempool.Close()
 		chain.Close()
+		addressBook.Close()
+		evidence.Close()
+		handshakes.Close()
 		return nil, fmt.Errorf("failed to create consensus: %w", err)
 	}
 
+	// Push new local transactions straight to the leader as well as gossiping them
+	mempool.SetLeaderSource(consensus.Leader)
+
 	node := &P2PBlockchainNode{
-		P2P:       p2p,
-		Mempool:   mempool,
-		Wallet:    wallet,
-		Chain:     chain,
-		Consensus: consensus,
-		apiPort:   apiPort,
-		apiKey:    config.APIKey, // Set from config
+		P2P:        p2p,
+		Mempool:    mempool,
+		Wallet:     wallet,
+		Chain:      chain,
+		Consensus:  consensus,
+		Sync:       syncClient,
+		SyncServer: syncServer,
+		Handshakes: handshakes,
+		Addresses:  addressBook,
+		Events:     events,
+		apiKeys:    apiKeyring{current: config.APIKey}, // Set from config
+
+		idempotency: NewIdempotencyCache(IdempotencyWindow),
+		apiSigners:  make(map[Address]bool),
+		requests:    NewRequestVerifier(),
+		readyMaxLag: uint64(config.ReadyMaxLag),
+		readOnly:    config.ReadOnly,
+		dataDir:     dir,
+	}
+
+	// Self-custody clients authorized to sign write requests
+	for _, s := range config.APISigners {
+		addr, _, err := ParseAddress(strings.TrimSpace(s))
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("invalid api_signers address %q: %w", s, err)
+		}
+		node.apiSigners[addr] = true
+	}
+
+	// Transactions this node submits are rebroadcast until they confirm or expire
+	if !config.ReadOnly {
+		tracker, err := NewWalletTxTracker(chain, mempool, config.RebroadcastBlocks, dataPathIn(dir, DefaultWalletTxsPath))
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("failed to load wallet transactions: %w", err)
+		}
+		node.WalletTxs = tracker
+
+		// Airdrops pick up where they stopped
+		airdrops, err := NewAirdropManager(chain, mempool, wallet, dataPathIn(dir, DefaultAirdropsPath))
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("failed to load airdrops: %w", err)
+		}
+		node.Airdrops = airdrops
+	}
+
+	// Backups of the wallet and a chain checkpoint, locally and optionally to a bucket
+	backupConfig := BackupConfig{
+		Interval: time.Duration(config.BackupIntervalHours) * time.Hour,
+		Dir:      config.BackupDir,
+		Keep:     config.BackupKeep,
+		DataDir:  dir,
+	}
+	if backupConfig.Dir == "" {
+		backupConfig.Dir = dataPathIn(dir, DefaultBackupDir)
+	}
+	if config.BackupS3URL != "" {
+		target, err := NewS3Target(config.BackupS3URL, config.BackupS3Region, config.BackupS3AccessKey, config.BackupS3SecretKey)
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("invalid backup bucket: %w", err)
+		}
+		backupConfig.S3 = target
+	}
+	node.Backups = NewBackupScheduler(chain, wallet, backupConfig)
+	node.Backups.Start()
+
+	// Write endpoint calls are recorded for compliance; read-only nodes serve none
+	if !config.ReadOnly {
+		requestAudit, err := OpenRequestAuditLog(dataPathIn(dir, DefaultRequestAuditPath))
+		if err != nil {
+			node.Close()
+			return nil, err
+		}
+		node.requestAudit = requestAudit
+	}
+
+	// Addresses imported to be monitored, such as cold storage, without their keys
+	watch, err := NewWatchOnlyWallet(dataPathIn(dir, "watchonly.db"))
+	if err != nil {
+		node.Close()
+		return nil, fmt.Errorf("failed to open watch-only wallet: %w", err)
+	}
+	node.Watch = watch
+
+	// Deposits to addresses whose address book entry requires a memo are flagged
+	node.Memos = NewMemoMonitor(chain, mempool, addressBook, events)
+
+	// Clients following confirmations hear when a reorg takes a transaction back out
+	node.Orphans = NewOrphanWatcher(chain, mempool, events)
+
+	// Swap counterparties hear when an HTLC is claimed (revealing the secret) or expires
+	node.HTLCs = NewHTLCMonitor(chain, events)
+
+	// Payers get fresh receive addresses derived from the wallet key; a remote signer's
+	// key never reaches the node, so there is nothing to derive them from
+	if !config.ReadOnly && wallet.Signer == nil {
+		receive, err := NewReceiveAddressPool(chain, wallet, config.AddressGapLimit, dataPathIn(dir, DefaultReceiveAddressesPath))
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("failed to load receive addresses: %w", err)
+		}
+		node.Receive = receive
+	}
+
+	// Pool operators score partials from farmers; pool farmers send their proofs to one
+	if config.PoolOperator {
+		pool, err := NewPoolOperator(chain, consensus, mempool, wallet, config.PoolPayoutBlocks, config.PoolFeePercent, dataPathIn(dir, DefaultPoolStatePath))
+		if err != nil {
+			node.Close()
+			return nil, fmt.Errorf("failed to start mining pool: %w", err)
+		}
+		node.MiningPool = pool
+	} else if config.PoolURL != "" {
+		consensus.SetPoolClient(NewPoolClient(config.PoolURL, wallet.GetSigner(), rewardAddr))
+		fmt.Printf("[Node] ⛏️  Farming for pool %s, payouts to %s\n", config.PoolURL, rewardAddr.String())
 	}
 
 	// Start HTTP API
+	node.apiServer = &http.Server{Addr: apiAddr, Handler: node.apiHandler()}
 	go node.startAPI()
 
-	fmt.Printf("[Node] Started with P2P on port %d, API on port %d\n", p2pPort, apiPort)
-	if node.apiKey != "" {
+	fmt.Printf("[Node] Started with P2P on %v, API on %s\n", p2pAddrs, apiAddr)
+	if node.apiKeys.Enabled() {
 		fmt.Printf("[Node] 🔒 API key authentication enabled for write endpoints\n")
 	}
-	fmt.Printf("[Node] Wallet address: %s\n", wallet.Address.String())
+	if len(node.apiSigners) > 0 {
+		fmt.Printf("[Node] 🔏 Signed request authentication enabled for %d address(es) and the node wallet\n", len(node.apiSigners))
+	}
+	if node.readOnly {
+		fmt.Printf("[Node] 📖 Read-only mode: no wallet, no farming, write endpoints disabled\n")
+	} else {
+		fmt.Printf("[Node] Wallet address: %s\n", wallet.Address.String())
+	}
+	if config.Archive {
+		fmt.Printf("[Node] 🏛️  Archive mode: keeping all proofs and spent UTXOs\n")
+	}
 
 	return node, nil
 }
 
-// requireAuth is middleware that checks API key for write endpoints
+// requireAuth is middleware that checks API key or request signature for write
+// endpoints. Authorized requests carrying an Idempotency-Key are run at most once per key.
 func (n *P2PBlockchainNode) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return n.authorize(next, authWrite)
+}
+
+// requireSpender is requireAuth for transaction submission, which also accepts a request
+// signed by the owner of every output the transaction spends
+func (n *P2PBlockchainNode) requireSpender(next http.HandlerFunc) http.HandlerFunc {
+	return n.authorize(next, authSpender)
+}
+
+// requireAdmin is requireAuth for /api/admin endpoints, which a node without an API key
+// or signers only serves to callers on the same machine
+func (n *P2PBlockchainNode) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return n.authorize(next, authAdmin)
+}
+
+// authScope is who authorize lets through besides API key holders and api_signers
+type authScope int
+
+const (
+	authWrite   authScope = iota
+	authSpender           // Also a request signed by the owner of every output spent
+	authAdmin             // Only loopback callers when there's no API key or signers
+)
+
+// authorize wraps next in API key / signed request checks. Every call, refused or not,
+// is recorded in the request audit log. Idempotency keys are scoped to the API key or
+// signer the request was authorized as.
+func (n *P2PBlockchainNode) authorize(next http.HandlerFunc, scope authScope) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// If no API key configured, allow all requests
-		if n.apiKey == "" {
-			next(w, r)
+		audit := n.auditRequest(w, r)
+		defer audit.record()
+		w = audit.rec
+
+		// Read-only nodes serve no writes, whoever asks
+		if n.readOnly {
+			http.Error(w, "Forbidden: this node is read-only", http.StatusForbidden)
+			return
+		}
+
+		// If no API key or signers configured, allow all requests, but admin ones only locally
+		if !n.apiKeys.Enabled() && len(n.apiSigners) == 0 {
+			if scope == authAdmin && !isLoopbackRemote(r.RemoteAddr) {
+				http.Error(w, "Forbidden: admin endpoints need an API key or api_signers unless called from this machine", http.StatusForbidden)
+				return
+			}
+			audit.authorized(AuditAuthNone, "")
+			n.idempotency.Wrap(AuditAuthNone, next)(w, r)
+			return
+		}
+
+		// Signed request: authorized by the signer's address
+		if IsSignedRequest(r) {
+			signer, err := n.requests.Verify(w, r)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+				return
+			}
+			audit.authorized(AuditAuthSignature, signer.String())
+			if !n.apiSigners[signer] && signer != n.Wallet.Address && !(scope == authSpender && n.spendsOwnOutputs(r, signer)) {
+				http.Error(w, fmt.Sprintf("Forbidden: %s is not authorized for this endpoint", signer.Display()), http.StatusForbidden)
+				return
+			}
+			n.idempotency.Wrap(AuditAuthSignature+":"+signer.String(), next)(w, r)
 			return
 		}
 
 		// Check X-API-Key header
-		providedKey := r.Header.Get("X-API-Key")
-		if providedKey != n.apiKey {
-			http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
+		key := r.Header.Get("X-API-Key")
+		if !n.apiKeys.Valid(key) {
+			http.Error(w, "Unauthorized: Invalid or missing API key or request signature", http.StatusUnauthorized)
 			return
 		}
+		audit.authorized(AuditAuthAPIKey, APIKeyID(key))
 
-		next(w, r)
+		n.idempotency.Wrap(AuditAuthAPIKey+":"+APIKeyID(key), next)(w, r)
+	}
+}
+
+// spendsOwnOutputs reports whether the transaction in a request body, or every member of
+// a submitted package, only spends outputs owned by signer. The body is put back for the
+// handler.
+func (n *P2PBlockchainNode) spendsOwnOutputs(r *http.Request, signer Address) bool {
+	// Anything longer is too large for the submit endpoints; the handler says so
+	limit := int64(MaxRawTxEncoded)
+	if r.URL.Path == "/api/tx/submit_package" {
+		limit *= MaxPackageTxs
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return false
+	}
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+	var txs []*Transaction
+	switch r.URL.Path {
+	case "/api/tx/submit_raw":
+		var tx *Transaction
+		tx, err = ParseRawTxBody(r.Header.Get("Content-Type"), body)
+		txs = []*Transaction{tx}
+	case "/api/tx/submit_package":
+		var pkg struct {
+			Transactions []*Transaction `json:"transactions"`
+		}
+		err = json.Unmarshal(body, &pkg)
+		txs = pkg.Transactions
+	default:
+		tx := &Transaction{}
+		err = json.Unmarshal(body, tx)
+		txs = []*Transaction{tx}
 	}
+	if err != nil || len(txs) == 0 {
+		return false
+	}
+	return PackageSpendsOnlyFrom(txs, signer, n.Chain.GetUTXOStore())
 }
 
 // startAPI starts the HTTP API server
 func (n *P2PBlockchainNode) startAPI() {
+	ln, err := net.Listen("tcp", n.apiServer.Addr)
+	if err != nil {
+		fmt.Printf("[API] Server error: %v\n", err)
+		return
+	}
+	fmt.Printf("[API] Listening on http://%s\n", ln.Addr())
+	if err := n.apiServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+		fmt.Printf("[API] Server error: %v\n", err)
+	}
+}
+
+// apiHandler routes the HTTP API
+func (n *P2PBlockchainNode) apiHandler() http.Handler {
 	mux := http.NewServeMux()
 
 	// Submit transaction endpoint (protected)
-	mux.HandleFunc("/api/tx/submit", n.requireAuth(n.handleSubmitTransaction))
+	mux.HandleFunc("/api/tx/submit", n.requireSpender(n.handleSubmitTransaction))
+	mux.HandleFunc("/api/tx/submit_raw", n.requireSpender(n.handleSubmitRawTransaction))
+
+	// Submit dependent transactions as one package (protected like submission)
+	mux.HandleFunc("/api/tx/submit_package", n.requireSpender(n.handleSubmitPackage))
+
+	// Dry-run a transaction against current state (protected like submission)
+	mux.HandleFunc("/api/tx/simulate", n.requireSpender(n.handleSimulateTransaction))
+
+	// Node events (transaction conflicts) over a WebSocket
+	mux.HandleFunc("/api/ws", n.handleEvents)
 
 	// Get mempool endpoint
 	mux.HandleFunc("/api/mempool", n.handleGetMempool)
+	mux.HandleFunc("/api/mempool/stats", n.handleMempoolStats)
 
 	// Get transaction by ID
 	mux.HandleFunc("/api/tx/", n.handleGetTransaction)
@@ -157,35 +616,83 @@ func (n *P2PBlockchainNode) startAPI() {
 	// Create and send transaction endpoint (protected)
 	mux.HandleFunc("/api/tx/send", n.requireAuth(n.handleSendTransaction))
 
+	// Build an unsigned transaction for an external wallet to sign
+	mux.HandleFunc("/api/tx/build", n.handleBuildTransaction)
+
+	// Sign the node wallet's inputs of a collaborative transaction (protected)
+	mux.HandleFunc("/api/tx/sign-inputs", n.requireAuth(n.handleSignInputs))
+
+	// Find confirmed and pending transactions by memo
+	mux.HandleFunc("/api/tx/search", n.handleSearchTransactions)
+	mux.HandleFunc("/api/condition", n.handleParseCondition) // Descriptor -> canonical form and address
+
+	// Hashed timelock contracts for cross-chain atomic swaps
+	mux.HandleFunc("/api/htlc", n.handleGetHTLCs)
+	mux.HandleFunc("/api/htlc/lock", n.requireAuth(n.handleLockHTLC))     // Protected
+	mux.HandleFunc("/api/htlc/claim", n.requireAuth(n.handleClaimHTLC))   // Protected
+	mux.HandleFunc("/api/htlc/refund", n.requireAuth(n.handleRefundHTLC)) // Protected
+
 	// Peer status endpoint
 	mux.HandleFunc("/api/peers", n.handleGetPeers)
 
 	// Chain endpoints
 	mux.HandleFunc("/api/chain", n.handleGetChain)
 	mux.HandleFunc("/api/chain/height", n.handleGetHeight)
+	mux.HandleFunc("/api/chain/params", n.handleGetChainParams)
+	mux.HandleFunc("/api/chain/state_hash", n.handleGetStateHash)
 	mux.HandleFunc("/api/chain/block/", n.handleGetBlock)
+	mux.HandleFunc("/api/chain/block/hash/", n.handleGetBlockByHash)   // Get block by hash
 	mux.HandleFunc("/api/blocks", n.handleGetBlocks)                   // Paginated block list
-	mux.HandleFunc("/api/block/hash/", n.handleGetBlockByHash)         // Get block by hash
+	mux.HandleFunc("/api/block/hash/", n.handleGetBlockByHash)         // Alias of /api/chain/block/hash/
 	mux.HandleFunc("/api/transaction/", n.handleGetTransactionDetails) // Full transaction details
 
 	// Consensus status
 	mux.HandleFunc("/api/consensus/status", n.handleConsensusStatus)
+	mux.HandleFunc("/api/consensus/reward_address", n.requireAuth(n.handleRewardAddress)) // Protected
+
+	// Mining pool endpoints (partials are authenticated by the farmer's signature)
+	mux.HandleFunc("/api/mining_pool/partial", n.handlePoolPartial)
+	mux.HandleFunc("/api/mining_pool/stats", n.handlePoolStats)
 
 	// Balance and UTXO query
 	mux.HandleFunc("/api/balance", n.handleGetBalance)
+	mux.HandleFunc("/api/balances", n.handleGetBalances)
 	mux.HandleFunc("/api/utxos", n.handleGetUTXOs)
+	mux.HandleFunc("/api/vesting", n.handleGetVesting)
+	mux.HandleFunc("/api/vesting/claim", n.requireAuth(n.handleClaimVesting)) // Protected
 	mux.HandleFunc("/api/transactions", n.handleGetTransactions)
 	mux.HandleFunc("/api/transactions/send", n.requireAuth(n.handleSendTransaction)) // Alias (protected)
 
 	// Node and wallet info
 	mux.HandleFunc("/api/status", n.handleGetStatus)
 	mux.HandleFunc("/api/wallet/info", n.handleGetWalletInfo)
+	mux.HandleFunc("/api/wallet/pending", n.handleGetWalletPending)
+	mux.HandleFunc("/api/wallet/new_address", n.requireAuth(n.handleNewAddress)) // Protected
+	mux.HandleFunc("/api/wallet/addresses", n.handleGetReceiveAddresses)
+	mux.HandleFunc("/api/wallet/watch", n.handleWatchOnly) // Writes protected inside handler
+	mux.HandleFunc("/api/sync/status", n.handleSyncStatus)
+	mux.HandleFunc("/api/sync/serving", n.handleSyncServing)
+
+	// Explorer statistics
+	mux.HandleFunc("/api/stats/supply", n.handleSupplyStats)
+	mux.HandleFunc("/api/stats/richlist", n.handleRichList)
+	mux.HandleFunc("/api/stats/blocks", n.handleBlockStats)
 
 	// Token endpoints
 	mux.HandleFunc("/api/tokens", n.handleGetTokens)
 	mux.HandleFunc("/api/token/info", n.handleGetTokenInfo)
-	mux.HandleFunc("/api/token/mint", n.requireAuth(n.handleMintToken)) // Protected
-	mux.HandleFunc("/api/token/melt", n.requireAuth(n.handleMeltToken)) // Protected
+	mux.HandleFunc("/api/token/mint", n.requireAuth(n.handleMintToken))        // Protected
+	mux.HandleFunc("/api/token/melt", n.requireAuth(n.handleMeltToken))        // Protected
+	mux.HandleFunc("/api/token/burn", n.requireAuth(n.handleBurnToken))        // Protected
+	mux.HandleFunc("/api/token/delegate", n.requireAuth(n.handleDelegateMint)) // Protected
+	mux.HandleFunc("/api/token/collateral", n.handleGetPositions)
+	mux.HandleFunc("/api/token/collateral/issue", n.requireAuth(n.handleCollateralIssue))       // Protected
+	mux.HandleFunc("/api/token/collateral/melt", n.requireAuth(n.handleCollateralMelt))         // Protected
+	mux.HandleFunc("/api/token/collateral/liquidate", n.requireAuth(n.handleLiquidatePosition)) // Protected
+	mux.HandleFunc("/api/token/burns", n.handleGetTokenBurns)
+	mux.HandleFunc("/api/token/airdrop", n.handleAirdrop) // Writes protected inside handler
+	mux.HandleFunc("/api/token/utxos", n.handleGetTokenUTXOs)
+	mux.HandleFunc("/api/token/transactions", n.handleGetTokenTransactions)
 
 	// Swap endpoints
 	mux.HandleFunc("/api/swap/offer", n.requireAuth(n.handleCreateOffer))  // Protected
@@ -196,10 +703,27 @@ func (n *P2PBlockchainNode) startAPI() {
 	// Pool endpoints
 	mux.HandleFunc("/api/pool/create", n.requireAuth(n.handleCreatePool)) // Protected
 	mux.HandleFunc("/api/pool/list", n.handleListPools)
+	mux.HandleFunc("/api/pool/position", n.handleGetPoolPosition)
+	mux.HandleFunc("/api/pool/twap", n.handleGetPoolTWAP)
 	mux.HandleFunc("/api/pool/add_liquidity", n.requireAuth(n.handleAddLiquidity))       // Protected
 	mux.HandleFunc("/api/pool/remove_liquidity", n.requireAuth(n.handleRemoveLiquidity)) // Protected
 	mux.HandleFunc("/api/pool/swap", n.requireAuth(n.handleSwap))                        // Protected
 
+	// Admin endpoints (protected)
+	mux.HandleFunc("/api/admin/db/stats", n.requireAdmin(n.handleDBStats))
+	mux.HandleFunc("/api/admin/audit", n.requireAdmin(n.handleAudit))
+	mux.HandleFunc("/api/admin/evidence", n.requireAdmin(n.handleEvidence))
+	mux.HandleFunc("/api/admin/mempool/flush", n.requireAdmin(n.handleAdminMempoolFlush))
+	mux.HandleFunc("/api/admin/rebroadcast", n.requireAdmin(n.handleAdminRebroadcast))
+	mux.HandleFunc("/api/admin/peers/connect", n.requireAdmin(n.handleAdminPeerConnect))
+	mux.HandleFunc("/api/admin/peers/disconnect", n.requireAdmin(n.handleAdminPeerDisconnect))
+	mux.HandleFunc("/api/admin/api_key/rotate", n.requireAdmin(n.handleAdminRotateAPIKey))
+	mux.HandleFunc("/api/admin/snapshot", n.requireAdmin(n.handleAdminSnapshot))
+	mux.HandleFunc("/api/admin/backup/now", n.requireAdmin(n.handleAdminBackupNow))
+
+	// Address book (writes protected inside handler)
+	mux.HandleFunc("/api/addressbook", n.handleAddressBook)
+
 	// Mempool management
 	mux.HandleFunc("/api/mempool/cancel", n.requireAuth(n.handleCancelMempoolTx)) // Protected
 
@@ -208,11 +732,14 @@ func (n *P2PBlockchainNode) startAPI() {
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 	})
 
-	addr := fmt.Sprintf(":%d", n.apiPort)
-	fmt.Printf("[API] Listening on http://0.0.0.0%s\n", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		fmt.Printf("[API] Server error: %v\n", err)
-	}
+	// Liveness and readiness probes for service managers
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+	})
+	mux.HandleFunc("/readyz", n.handleReadyz)
+
+	return mux
 }
 
 // handleSubmitTransaction handles transaction submission
@@ -227,293 +754,1873 @@ func (n *P2PBlockchainNode) handleSubmitTransaction(w http.ResponseWriter, r *ht
 		http.Error(w, fmt.Sprintf("Invalid transaction: %v", err), http.StatusBadRequest)
 		return
 	}
+	n.acceptTransaction(w, &tx)
+}
 
-	// Add to mempool (will verify signature and gossip)
-	if err := n.Mempool.AddTransaction(&tx); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
+// handleSubmitRawTransaction handles submission of a transaction in its raw form, as
+// bytes, hex or base64
+func (n *P2PBlockchainNode) handleSubmitRawTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	txID, _ := tx.ID()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "accepted",
-		"tx_id":  txID,
-	})
-}
-
-// handleGetMempool returns all transactions in the mempool
-func (n *P2PBlockchainNode) handleGetMempool(w http.ResponseWriter, r *http.Request) {
-	txs := n.Mempool.GetTransactions()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count":        len(txs),
-		"transactions": txs,
-	})
-}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRawTxEncoded))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body exceeds %d bytes", MaxRawTxEncoded), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
 
-// handleGetTransaction returns a specific transaction
-func (n *P2PBlockchainNode) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
-	// Extract TX ID from path
-	txID := r.URL.Path[len("/api/tx/"):]
-	if txID == "" {
-		http.Error(w, "Transaction ID required", http.StatusBadRequest)
+	tx, err := ParseRawTxBody(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		if errors.Is(err, ErrRawTxTooLarge) {
+			http.Error(w, fmt.Sprintf("Invalid raw transaction: %v", err), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Invalid raw transaction: %v", err), http.StatusBadRequest)
 		return
 	}
+	n.acceptTransaction(w, tx)
+}
 
-	tx, exists := n.Mempool.GetTransaction(txID)
-	if !exists {
-		http.Error(w, "Transaction not found", http.StatusNotFound)
+// acceptTransaction queues a submitted transaction and writes the submit response
+func (n *P2PBlockchainNode) acceptTransaction(w http.ResponseWriter, tx *Transaction) {
+	// Queue for signature verification; it is added and gossiped once verified
+	if err := n.Mempool.SubmitTransaction(tx); err != nil {
+		if errors.Is(err, ErrMempoolBusy) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
 		return
 	}
 
+	txID, _ := tx.ID()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tx)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "accepted",
+		"tx_id":  txID,
+	})
 }
 
-// handleCancelMempoolTx allows users to cancel their own pending transactions
-func (n *P2PBlockchainNode) handleCancelMempoolTx(w http.ResponseWriter, r *http.Request) {
+// handleSubmitPackage checks a package of dependent transactions and adds all of it to
+// the mempool or none of it
+func (n *P2PBlockchainNode) handleSubmitPackage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		TxID      string `json:"tx_id"`
-		PublicKey []byte `json:"public_key"`
+		Transactions []*Transaction `json:"transactions"`
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Invalid package: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Get the transaction from mempool
-	tx, exists := n.Mempool.GetTransaction(req.TxID)
-	if !exists {
-		http.Error(w, "Transaction not found in mempool", http.StatusNotFound)
+	result, err := n.Mempool.SubmitPackage(req.Transactions)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add package: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Verify the caller owns this transaction by checking the signature public key
-	// Extract public key from transaction signature
-	if !tx.VerifyOwnership(req.PublicKey) {
-		http.Error(w, "Unauthorized: You don't own this transaction", http.StatusForbidden)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleSimulateTransaction validates a transaction and reports its fee, the outputs it
+// would create and the events it would emit, without adding it to the mempool
+func (n *P2PBlockchainNode) handleSimulateTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Remove from mempool
-	n.Mempool.RemoveTransaction(req.TxID)
+	var tx Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid transaction: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Transaction %s cancelled", req.TxID[:16]),
-	})
+	json.NewEncoder(w).Encode(n.Mempool.Simulate(&tx))
 }
 
-// handleSendTransaction creates and sends a transaction
-func (n *P2PBlockchainNode) handleSendTransaction(w http.ResponseWriter, r *http.Request) {
+// handleBuildTransaction selects coins from any address and returns an unsigned send for
+// the address owner to sign locally and submit
+func (n *P2PBlockchainNode) handleBuildTransaction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		ToAddress string `json:"to_address"`
-		Amount    uint64 `json:"amount"`
-		Token     string `json:"token"`    // Legacy field
-		TokenID   string `json:"token_id"` // API spec field
-		Fee       uint64 `json:"fee"`      // Optional fee
-		Memo      string `json:"memo"`     // Optional memo
+		FromAddress   string `json:"from_address"`
+		ChangeAddress string `json:"change_address"` // Optional, defaults to from_address
+		Outputs       []struct {
+			Address string `json:"address"`  // Address or address book label
+			Amount  uint64 `json:"amount"`   // Base units
+			TokenID string `json:"token_id"` // Optional, defaults to SHADOW
+		} `json:"outputs"`
+		Fee        uint64 `json:"fee"`         // Optional fixed fee, 0 = estimate
+		Memo       string `json:"memo"`        // Optional ASCII memo, up to 64 bytes
+		MempoolTTL uint32 `json:"mempool_ttl"` // Optional last block height that may include the tx
 	}
-
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Parse destination address
-	toAddr, _, err := ParseAddress(req.ToAddress)
+	from, _, err := ParseAPIAddress(req.FromAddress)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Invalid from_address: %v", err), http.StatusBadRequest)
 		return
 	}
-
-	// Use SHADOW token if not specified
-	// Support both "token" (legacy) and "token_id" (API spec)
-	tokenID := req.TokenID
-	if tokenID == "" {
-		tokenID = req.Token
+	change := from
+	if req.ChangeAddress != "" {
+		if change, _, err = ParseAPIAddress(req.ChangeAddress); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid change_address: %v", err), http.StatusBadRequest)
+			return
+		}
 	}
-	if tokenID == "" || tokenID == "SHADOW" {
-		tokenID = GetGenesisToken().TokenID
+	if len(req.Memo) > 64 || !isASCII(req.Memo) {
+		http.Error(w, "Memo must be ASCII and <= 64 bytes", http.StatusBadRequest)
+		return
 	}
 
-	// Get UTXOs for our wallet
-	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(n.Wallet.Address)
+	var outputs []*TxOutput
+	for i, o := range req.Outputs {
+		to, toType, err := n.resolveRecipient(o.Address, TxTypeSend)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid address for output %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		var output *TxOutput
+		if o.TokenID == "" || o.TokenID == "SHADOW" || o.TokenID == GetGenesisToken().TokenID {
+			output = CreateShadowOutput(to, o.Amount)
+		} else if _, exists := n.Chain.TokenRegistry().GetToken(o.TokenID); exists {
+			output = n.Chain.TokenRegistry().NewTokenOutput(to, o.Amount, o.TokenID, "custom", nil)
+		} else {
+			http.Error(w, fmt.Sprintf("Unknown token for output %d: %s", i, o.TokenID), http.StatusBadRequest)
+			return
+		}
+		if toType != AddressTypeWallet {
+			output.AddressType = toType
+		}
+		outputs = append(outputs, output)
+	}
+
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(from)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if sending custom token (not SHADOW)
-	genesisTokenID := GetGenesisToken().TokenID
-	isCustomToken := tokenID != genesisTokenID
-
-	// Filter for unspent UTXOs of the requested token
-	var availableTokenUTXOs []*UTXO
-	var availableShadowUTXOs []*UTXO
+	// Leave outputs already spent by pending transactions alone
+	pending := n.Mempool.PendingSpends()
+	available := make([]*UTXO, 0, len(utxos))
 	for _, utxo := range utxos {
-		if !utxo.IsSpent {
-			if utxo.Output.TokenID == tokenID {
-				availableTokenUTXOs = append(availableTokenUTXOs, utxo)
-			} else if utxo.Output.TokenID == genesisTokenID {
-				availableShadowUTXOs = append(availableShadowUTXOs, utxo)
-			}
+		if !pending[fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)] {
+			available = append(available, utxo)
 		}
 	}
 
-	// Estimate fee first to know how much we need
-	estimatedFee := req.Fee
-	if estimatedFee == 0 {
-		estimatedFee = 11500 // Default minimum fee
-	}
+	built, err := BuildSendTransaction(n.Chain.TokenRegistry(), available, outputs, change, req.Fee, n.Mempool.MinRelayFee())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Memo != "" {
+		built.Transaction.Data = []byte(req.Memo)
+	}
+	built.Transaction.MempoolTTL = req.MempoolTTL
+	if err := built.Finish(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(built)
+}
+
+// handleSignInputs signs every input of a transaction that spends the node wallet's
+// outputs under the requested sighash mode, for transactions built by several parties
+func (n *P2PBlockchainNode) handleSignInputs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Transaction *Transaction `json:"transaction"`
+		SigHash     string       `json:"sighash"` // e.g. "ALL" (default) or "SINGLE|ANYONECANPAY"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Transaction == nil {
+		http.Error(w, "Invalid request: transaction is required", http.StatusBadRequest)
+		return
+	}
+	sigHash, err := ParseSigHashType(req.SigHash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx := req.Transaction
+	signed := make([]int, 0)
+	var watched []Address
+	for i, input := range tx.Inputs {
+		utxo, err := n.Chain.GetUTXOStore().GetUTXO(input.PrevTxID, input.OutputIndex)
+		if err != nil || utxo == nil {
+			continue
+		}
+		if n.Watch != nil && n.Watch.IsWatchOnly(utxo.Output.Address) {
+			watched = append(watched, utxo.Output.Address)
+		}
+		if utxo.Output.Address != n.Wallet.Address {
+			continue
+		}
+		if err := tx.SignInput(i, sigHash, n.Wallet.GetSigner()); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to sign input %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		signed = append(signed, i)
+	}
+	if len(signed) == 0 && len(watched) > 0 {
+		writeNotSignable(w, watched[0])
+		return
+	}
+	if len(signed) == 0 {
+		http.Error(w, "No inputs spend the node wallet's outputs", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transaction":   tx,
+		"signed_inputs": signed,
+		"sighash":       sigHash.String(),
+	})
+}
+
+// handleSearchTransactions finds transactions whose memo is exactly ?memo=: confirmed ones
+// from the memo index, newest first, and pending ones from the mempool
+func (n *P2PBlockchainNode) handleSearchTransactions(w http.ResponseWriter, r *http.Request) {
+	memo := r.URL.Query().Get("memo")
+	if memo == "" {
+		http.Error(w, "memo parameter required", http.StatusBadRequest)
+		return
+	}
+	utxoStore := n.Chain.GetUTXOStore()
+	if !utxoStore.MemoIndexEnabled() {
+		http.Error(w, "Memo index is disabled on this node (enable memo_index)", http.StatusNotFound)
+		return
+	}
+
+	limit := MaxMemoSearchResults
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	confirmed, err := utxoStore.SearchMemo(memo, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	pending := []string{}
+	for _, tx := range n.Mempool.GetTransactions() {
+		if txMemo := txMemo(tx); txMemo != nil && string(txMemo) == memo {
+			txID, _ := tx.ID()
+			pending = append(pending, txID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"memo":         memo,
+		"count":        len(confirmed),
+		"transactions": confirmed,
+		"pending":      pending,
+	})
+}
+
+// handleGetMempool returns all transactions in the mempool
+func (n *P2PBlockchainNode) handleGetMempool(w http.ResponseWriter, r *http.Request) {
+	txs := n.Mempool.GetTransactions()
+
+	// Blocks left before each TTL-bound transaction can no longer be mined
+	ttlRemaining := make(map[string]uint64)
+	for _, tx := range txs {
+		if remaining, hasTTL := n.Mempool.TTLRemaining(tx); hasTTL {
+			txID, _ := tx.ID()
+			ttlRemaining[txID] = remaining
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":         len(txs),
+		"transactions":  txs,
+		"ttl_remaining": ttlRemaining,
+		"relay":         n.Mempool.RelayStats(),
+		"admission":     n.Mempool.AdmissionStats(),
+	})
+}
+
+// handleMempoolStats reports the mempool's size against its cap and eviction counts
+func (n *P2PBlockchainNode) handleMempoolStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.Mempool.EvictionStats())
+}
+
+// handleGetTransaction returns a pending transaction, its admission status, or the
+// details of a confirmed one
+func (n *P2PBlockchainNode) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	// Extract TX ID from path
+	txID := r.URL.Path[len("/api/tx/"):]
+	if txID == "" {
+		http.Error(w, "Transaction ID required", http.StatusBadRequest)
+		return
+	}
+
+	tx, exists := n.Mempool.GetTransaction(txID)
+	if !exists {
+		// Submitted but not admitted: still being verified, or rejected
+		if status, reason, ok := n.Mempool.AdmissionStatus(txID); ok {
+			code := http.StatusAccepted
+			if status == AdmissionRejected {
+				code = http.StatusUnprocessableEntity
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]string{
+				"tx_id":  txID,
+				"status": status,
+				"reason": reason,
+			})
+			return
+		}
+		// Confirmed: report its status and receipt
+		if response, ok := n.transactionDetails(txID); ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if n.writeConflicted(w, txID) || n.writePruned(w, txID) {
+			return
+		}
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	// Report the pending transaction with its status; numbers are kept exact
+	data, err := json.Marshal(tx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	response := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	response["status"] = TxStatusPending
+	response["confirmations"] = 0
+	if chainStatus, _ := n.Chain.TxChainStatus(txID); chainStatus != nil && chainStatus.Status == TxStatusOrphaned {
+		// Back in the mempool after a reorg replaced its block
+		response["orphaned_height"] = chainStatus.Height
+		response["orphaned_block_hash"] = chainStatus.BlockHash
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// eventUpgrader upgrades /api/ws requests; the default origin check refuses pages from
+// other sites
+var eventUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// handleEvents streams node events to a WebSocket client: every type, or only those
+// listed in ?types=
+func (n *P2PBlockchainNode) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var types []string
+	for _, eventType := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			types = append(types, eventType)
+		}
+	}
+
+	conn, err := eventUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has replied with the error
+	}
+	defer conn.Close()
+
+	events, cancel := n.Events.Subscribe(types)
+	defer cancel()
+
+	// Clients send nothing; reading notices when they go away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(EventPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			conn.SetWriteDeadline(time.Now().Add(EventWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "node shutting down"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(EventWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// writeConflicted reports a transaction that left the mempool after losing a
+// double-spend; false if it didn't
+func (n *P2PBlockchainNode) writeConflicted(w http.ResponseWriter, txID string) bool {
+	conflict, ok := n.Mempool.ConflictStatus(txID)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tx_id":    txID,
+		"status":   TxStatusConflicted,
+		"conflict": conflict,
+	})
+	return true
+}
+
+// writePruned reports a confirmed transaction whose body was pruned: 410 Gone with the
+// block it was in. Returns false if the transaction wasn't pruned.
+func (n *P2PBlockchainNode) writePruned(w http.ResponseWriter, txID string) bool {
+	height, ok := n.Chain.GetUTXOStore().TxPrunedAt(txID)
+	if !ok {
+		return false
+	}
+	response := map[string]interface{}{
+		"tx_id":        txID,
+		"status":       TxStatusPruned,
+		"block_height": height,
+	}
+	if block := n.Chain.GetBlock(height); block != nil {
+		response["block_hash"] = block.Hash
+		response["confirmations"] = n.Chain.GetHeight() - height
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	json.NewEncoder(w).Encode(response)
+	return true
+}
+
+// handleCancelMempoolTx allows users to cancel their own pending transactions
+func (n *P2PBlockchainNode) handleCancelMempoolTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TxID      string `json:"tx_id"`
+		PublicKey []byte `json:"public_key"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get the transaction from mempool
+	tx, exists := n.Mempool.GetTransaction(req.TxID)
+	if !exists {
+		http.Error(w, "Transaction not found in mempool", http.StatusNotFound)
+		return
+	}
+
+	// Verify the caller owns this transaction by checking the signature public key
+	// Extract public key from transaction signature
+	if !tx.VerifyOwnership(req.PublicKey) {
+		http.Error(w, "Unauthorized: You don't own this transaction", http.StatusForbidden)
+		return
+	}
+
+	// Transactions spending this node's coins are cancelled network-wide by a
+	// higher-fee replacement paying the inputs back to the wallet, which must also outbid
+	// the transactions spending its outputs
+	utxoStore := n.Chain.GetUTXOStore()
+	replaceFloor := n.Mempool.MinRelayFee() + n.Mempool.DescendantFees(req.TxID)
+	replacement, err := BuildCancelReplacement(tx, n.Wallet.Address, utxoStore, n.Chain.TokenRegistry(), replaceFloor)
+	if err == nil {
+		if err = n.Wallet.SignTransaction(replacement); err == nil {
+			err = n.Mempool.AddTransaction(replacement)
+		}
+	}
+	if err == nil {
+		replacementID, _ := replacement.ID()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":        true,
+			"network_wide":   true,
+			"replacement_id": replacementID,
+			"fee":            PaidFee(replacement, utxoStore),
+			"message":        fmt.Sprintf("Transaction %s replaced by %s", req.TxID[:16], replacementID[:16]),
+		})
+		return
+	}
+
+	// Otherwise only this node forgets it; the owner must broadcast a replacement
+	n.Mempool.RemoveTransaction(req.TxID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"network_wide": false,
+		"message": fmt.Sprintf("Transaction %s removed from this node only (%v); submit a transaction spending the same inputs with a higher fee to cancel it network-wide",
+			req.TxID[:16], err),
+	})
+}
+
+// resolveRecipient resolves an address or address book label and checks that a
+// transaction of txType may pay its address type. Labels resolve to wallet addresses.
+func (n *P2PBlockchainNode) resolveRecipient(s string, txType TxType) (Address, AddressType, error) {
+	addr, err := n.Addresses.Resolve(s)
+	if err != nil {
+		return addr, 0, err
+	}
+	addrType := AddressTypeWallet
+	if _, parsedType, err := ParseAPIAddress(s); err == nil {
+		addrType = parsedType
+	}
+	if err := CheckRecipientType(addrType, txType); err != nil {
+		return addr, addrType, err
+	}
+	return addr, addrType, nil
+}
+
+// handleSendTransaction creates and sends a transaction
+func (n *P2PBlockchainNode) handleSendTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ToAddress string `json:"to_address"`
+		Amount    uint64 `json:"amount"`
+		Token     string `json:"token"`     // Legacy field
+		TokenID   string `json:"token_id"`  // API spec field
+		Fee       uint64 `json:"fee"`       // Optional fee
+		TokenFee  uint64 `json:"token_fee"` // Optional fee paid in the sent custom token instead of SHADOW
+		Memo      string `json:"memo"`      // Optional memo
+
+		Vesting *VestingSchedule `json:"vesting"` // Optional: the recipient's output vests on this schedule
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Parse destination address (or address book label)
+	toAddr, toType, err := n.resolveRecipient(req.ToAddress, TxTypeSend)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Use SHADOW token if not specified
+	// Support both "token" (legacy) and "token_id" (API spec)
+	tokenID := req.TokenID
+	if tokenID == "" {
+		tokenID = req.Token
+	}
+	if tokenID == "" || tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	}
+
+	// Check if sending custom token (not SHADOW)
+	genesisTokenID := GetGenesisToken().TokenID
+	isCustomToken := tokenID != genesisTokenID
+
+	// Get our wallet's UTXOs of the token, and of SHADOW for the fee
+	utxoStore := n.Chain.GetUTXOStore()
+	utxos, err := utxoStore.GetUTXOsByAddressAndToken(n.Wallet.Address, tokenID)
+	if err == nil && isCustomToken {
+		var shadowUTXOs []*UTXO
+		shadowUTXOs, err = utxoStore.GetUTXOsByAddressAndToken(n.Wallet.Address, genesisTokenID)
+		utxos = append(utxos, shadowUTXOs...)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Filter for unspent UTXOs of the requested token (vesting UTXOs are claimed separately)
+	var availableTokenUTXOs []*UTXO
+	var availableShadowUTXOs []*UTXO
+	for _, utxo := range utxos {
+		if !utxo.IsSpent && utxo.Output.Vesting == nil {
+			if utxo.Output.TokenID == tokenID {
+				availableTokenUTXOs = append(availableTokenUTXOs, utxo)
+			} else if utxo.Output.TokenID == genesisTokenID {
+				availableShadowUTXOs = append(availableShadowUTXOs, utxo)
+			}
+		}
+	}
+
+	// Estimate fee first to know how much we need
+	estimatedFee := req.Fee
+	if estimatedFee == 0 {
+		estimatedFee = 11500 // Default minimum fee
+	}
+
+	// If sending SHADOW, we need to cover amount + fee from same UTXOs
+	requiredAmount := req.Amount
+	if tokenID == genesisTokenID {
+		requiredAmount = req.Amount + estimatedFee
+	}
+
+	// A token fee is paid from the token being sent, so no SHADOW is needed
+	if req.TokenFee > 0 {
+		if !isCustomToken {
+			http.Error(w, "token_fee is only for custom token sends; use fee for SHADOW", http.StatusBadRequest)
+			return
+		}
+		requiredAmount = req.Amount + req.TokenFee
+	}
+
+	// Select token UTXOs to cover the required amount
+	var selectedTokenUTXOs []*UTXO
+	var tokenTotal uint64
+	for _, utxo := range availableTokenUTXOs {
+		selectedTokenUTXOs = append(selectedTokenUTXOs, utxo)
+		tokenTotal += utxo.Output.Amount
+		if tokenTotal >= requiredAmount {
+			break
+		}
+	}
+
+	if tokenTotal < req.Amount+req.TokenFee {
+		http.Error(w, fmt.Sprintf("Insufficient %s balance: have %d, need %d", tokenID[:16], tokenTotal, req.Amount+req.TokenFee), http.StatusBadRequest)
+		return
+	}
+
+	// For custom tokens, also need SHADOW for fee
+	var selectedShadowUTXOs []*UTXO
+	var shadowTotal uint64
+	var targetFee uint64
+
+	if isCustomToken && req.TokenFee == 0 {
+		// Estimate fee based on token inputs + shadow inputs needed
+		if req.Fee > 0 {
+			targetFee = req.Fee
+		} else {
+			// Estimate: token inputs + ~2 shadow inputs + outputs
+			targetFee = uint64(len(selectedTokenUTXOs)+2) * 1150
+			if targetFee < 11500 {
+				targetFee = 11500
+			}
+		}
+
+		// Select SHADOW UTXOs for fee
+		for _, utxo := range availableShadowUTXOs {
+			selectedShadowUTXOs = append(selectedShadowUTXOs, utxo)
+			shadowTotal += utxo.Output.Amount
+			if shadowTotal >= targetFee {
+				break
+			}
+		}
+
+		if shadowTotal < targetFee {
+			http.Error(w, fmt.Sprintf("Insufficient SHADOW for fee: have %d, need %d", shadowTotal, targetFee), http.StatusBadRequest)
+			return
+		}
+	} else if !isCustomToken {
+		// Sending SHADOW: fee comes from the same UTXOs
+		if req.Fee > 0 {
+			targetFee = req.Fee
+		} else {
+			targetFee = uint64(len(selectedTokenUTXOs)) * 1150
+			if targetFee < 11500 {
+				targetFee = 11500
+			}
+		}
+
+		if tokenTotal < req.Amount+targetFee {
+			http.Error(w, fmt.Sprintf("Insufficient balance: have %d, need %d (including %d fee)", tokenTotal, req.Amount+targetFee, targetFee), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Create transaction manually to support memo
+	txBuilder := NewTxBuilder(TxTypeSend).SetRegistry(n.Chain.TokenRegistry())
+	txBuilder.SetTimestamp(time.Now().Unix())
+
+	// Add token inputs
+	for _, utxo := range selectedTokenUTXOs {
+		txBuilder.AddInput(utxo.TxID, utxo.OutputIndex)
+	}
+
+	// For custom tokens, also add SHADOW inputs for fee
+	if isCustomToken {
+		for _, utxo := range selectedShadowUTXOs {
+			txBuilder.AddInput(utxo.TxID, utxo.OutputIndex)
+		}
+	}
+
+	// Add output to recipient (token)
+	var recipient *TxOutput
+	if req.Vesting != nil {
+		if err := req.Vesting.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid vesting schedule: %v", err), http.StatusBadRequest)
+			return
+		}
+		recipient = CreateVestingOutput(n.Chain.TokenRegistry(), toAddr, req.Amount, tokenID, *req.Vesting)
+	} else if isCustomToken {
+		recipient = n.Chain.TokenRegistry().NewTokenOutput(toAddr, req.Amount, tokenID, "custom", nil)
+	} else {
+		recipient = CreateShadowOutput(toAddr, req.Amount)
+	}
+	if toType != AddressTypeWallet {
+		recipient.AddressType = toType
+	}
+	txBuilder.AddCustomOutput(recipient)
+
+	// Add change outputs
+	if isCustomToken {
+		// Custom token: change is separate for token and SHADOW
+		tokenChange := tokenTotal - req.Amount - req.TokenFee
+		if tokenChange > 0 {
+			txBuilder.AddOutput(n.Wallet.Address, tokenChange, tokenID)
+		}
+
+		shadowChange := shadowTotal - targetFee
+		if shadowChange > 0 {
+			txBuilder.AddOutput(n.Wallet.Address, shadowChange, genesisTokenID)
+		}
+	} else {
+		// SHADOW: fee is deducted from same UTXOs
+		change := tokenTotal - req.Amount - targetFee
+		if change > 0 {
+			txBuilder.AddOutput(n.Wallet.Address, change, tokenID)
+		}
+	}
+
+	if req.TokenFee > 0 {
+		txBuilder.SetTokenFee(tokenID, req.TokenFee)
+	}
+
+	tx := txBuilder.Build()
+
+	// Add memo if provided
+	if req.Memo != "" {
+		// Validate memo is ASCII and max 64 bytes
+		if len(req.Memo) > 64 {
+			http.Error(w, "Memo must be <= 64 bytes", http.StatusBadRequest)
+			return
+		}
+		for _, c := range req.Memo {
+			if c > 127 {
+				http.Error(w, "Memo must be ASCII only", http.StatusBadRequest)
+				return
+			}
+		}
+		tx.Data = []byte(req.Memo)
+	}
+
+	// Sign the transaction
+	if err := n.Wallet.SignTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Add to mempool
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	txID, _ := tx.ID()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"tx_id":  txID,
+		"tx":     tx,
+	})
+}
+
+// handleGetPeers returns connected peers
+func (n *P2PBlockchainNode) handleGetPeers(w http.ResponseWriter, r *http.Request) {
+	peers := n.P2P.GetPeers()
+	peerStrs := make([]string, len(peers))
+	for i, p := range peers {
+		peerStrs[i] = p.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":            len(peers),
+		"peers":            peerStrs,
+		"outbound":         len(n.P2P.OutboundPeers()),
+		"outbound_subnets": n.P2P.OutboundSubnets(),
+		"known_peers":      n.P2P.KnownPeerCount(),
+		"details":          n.Handshakes.Peers(),
+	})
+}
+
+// handleGetChain returns the entire blockchain
+func (n *P2PBlockchainNode) handleGetChain(w http.ResponseWriter, r *http.Request) {
+	blocks := n.Chain.GetBlocks()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height": len(blocks),
+		"blocks": blocks,
+	})
+}
+
+// handleParseCondition parses a spend condition descriptor and returns its canonical
+// form and the address outputs locked by it pay
+func (n *P2PBlockchainNode) handleParseCondition(w http.ResponseWriter, r *http.Request) {
+	cond, err := ParseCondition(r.URL.Query().Get("descriptor"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"descriptor": cond.String(),
+		"address":    cond.Address().Display(),
+	})
+}
+
+// walletAvailableUTXOs returns the node wallet's UTXOs not spent by pending transactions
+func (n *P2PBlockchainNode) walletAvailableUTXOs() ([]*UTXO, error) {
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		return nil, err
+	}
+	pending := n.Mempool.PendingSpends()
+	available := make([]*UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !pending[fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)] {
+			available = append(available, utxo)
+		}
+	}
+	return available, nil
+}
+
+// handleLockHTLC locks node wallet funds in an HTLC. Without a hash the node generates
+// the secret and returns it; keep it to claim the other side of the swap.
+func (n *P2PBlockchainNode) handleLockHTLC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Recipient string `json:"recipient"` // Claims with the secret
+		Refund    string `json:"refund"`    // Refunded from the timeout on (default: node wallet)
+		Hash      string `json:"hash"`      // SHA-256 of the secret, hex (default: generate a secret)
+		Timeout   uint64 `json:"timeout"`   // Block height the refund becomes possible at
+		Amount    uint64 `json:"amount"`
+		TokenID   string `json:"token_id"` // "SHADOW" or empty for SHADOW
+		Fee       uint64 `json:"fee"`      // Optional fee
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	terms := &HTLCTerms{Refund: n.Wallet.Address, Timeout: req.Timeout}
+	var err error
+	if terms.Recipient, _, err = ParseAPIAddress(req.Recipient); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid recipient: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Refund != "" {
+		if terms.Refund, _, err = ParseAPIAddress(req.Refund); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid refund: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if height := n.Chain.GetHeight(); req.Timeout <= height {
+		http.Error(w, fmt.Sprintf("Timeout must be above the current height %d", height), http.StatusBadRequest)
+		return
+	}
+
+	var secret []byte
+	if req.Hash == "" {
+		if secret, terms.Hash, err = NewHTLCSecret(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if terms.Hash, err = hex.DecodeString(req.Hash); err != nil || len(terms.Hash) != sha256.Size {
+		http.Error(w, "Hash must be 32 bytes of hex", http.StatusBadRequest)
+		return
+	}
+
+	tokenID := req.TokenID
+	if tokenID == "" || tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	} else if _, exists := n.Chain.TokenRegistry().GetToken(tokenID); !exists {
+		http.Error(w, fmt.Sprintf("Unknown token: %s", tokenID), http.StatusBadRequest)
+		return
+	}
+
+	utxos, err := n.walletAvailableUTXOs()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tx, err := CreateHTLCLockTransaction(n.Chain.TokenRegistry(), utxos, terms, req.Amount, tokenID, n.Wallet.Address, req.Fee, n.Mempool.MinRelayFee())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create lock: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := n.Wallet.SignTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	txID, _ := tx.ID()
+	response := map[string]interface{}{
+		"status":     "success",
+		"tx_id":      txID,
+		"htlc_id":    htlcID(txID, 0),
+		"address":    terms.Condition().Address().Display(),
+		"descriptor": terms.Condition().String(),
+		"hash":       hex.EncodeToString(terms.Hash),
+		"timeout":    terms.Timeout,
+	}
+	if secret != nil {
+		response["secret"] = hex.EncodeToString(secret)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleClaimHTLC claims an HTLC paying the node wallet by revealing its secret
+func (n *P2PBlockchainNode) handleClaimHTLC(w http.ResponseWriter, r *http.Request) {
+	n.spendHTLC(w, r, TxTypeHTLCClaim)
+}
+
+// handleRefundHTLC refunds an HTLC to the node wallet after its timeout
+func (n *P2PBlockchainNode) handleRefundHTLC(w http.ResponseWriter, r *http.Request) {
+	n.spendHTLC(w, r, TxTypeHTLCRefund)
+}
+
+// spendHTLC claims or refunds an HTLC to the node wallet
+func (n *P2PBlockchainNode) spendHTLC(w http.ResponseWriter, r *http.Request, txType TxType) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		HTLCID   string `json:"htlc_id"`
+		Preimage string `json:"preimage"` // Secret, hex (claims only)
+		Fee      uint64 `json:"fee"`      // Optional fee
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Fee == 0 {
+		req.Fee = 11500 // Default minimum fee
+	}
+
+	htlc, err := n.Chain.GetUTXOStore().GetHTLC(req.HTLCID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if htlc == nil {
+		http.Error(w, "HTLC not found", http.StatusNotFound)
+		return
+	}
+	if htlc.Status != HTLCStatusLocked {
+		http.Error(w, fmt.Sprintf("HTLC is already %s", htlc.Status), http.StatusConflict)
+		return
+	}
+	utxo, err := n.Chain.GetUTXOStore().GetUTXO(htlc.LockTxID, htlc.OutputIndex)
+	if err != nil || utxo == nil || utxo.IsSpent {
+		http.Error(w, "HTLC output not found", http.StatusNotFound)
+		return
+	}
+	terms, _ := outputHTLCTerms(utxo.Output)
+
+	var preimage []byte
+	if txType == TxTypeHTLCClaim {
+		if terms.Recipient != n.Wallet.Address {
+			http.Error(w, "HTLC does not pay the node wallet", http.StatusForbidden)
+			return
+		}
+		if preimage, err = hex.DecodeString(req.Preimage); err != nil || len(preimage) == 0 {
+			http.Error(w, "Preimage must be hex", http.StatusBadRequest)
+			return
+		}
+		if sum := sha256.Sum256(preimage); !bytes.Equal(sum[:], terms.Hash) {
+			http.Error(w, "Preimage does not match the HTLC hash", http.StatusBadRequest)
+			return
+		}
+	} else {
+		if terms.Refund != n.Wallet.Address {
+			http.Error(w, "HTLC does not refund to the node wallet", http.StatusForbidden)
+			return
+		}
+		if height := n.Chain.GetHeight(); height+1 < terms.Timeout {
+			http.Error(w, fmt.Sprintf("HTLC can be refunded from block %d", terms.Timeout), http.StatusBadRequest)
+			return
+		}
+	}
+
+	feeUTXOs, err := n.walletAvailableUTXOs()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tx, err := CreateHTLCSpendTransaction(utxo, preimage, n.Wallet.Address, req.Fee, feeUTXOs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create %s: %v", txType.String(), err), http.StatusBadRequest)
+		return
+	}
+	if err := tx.AddWitnessSignature(0, n.Wallet.GetSigner()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to sign witness: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !tx.fullyWitnessed() {
+		if err := n.Wallet.SignTransaction(tx); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to sign transaction: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	txID, _ := tx.ID()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"tx_id":   txID,
+		"htlc_id": htlc.ID,
+	})
+}
+
+// handleGetHTLCs returns one HTLC by id, or the HTLCs an address locked, can claim or
+// can refund, optionally by status
+func (n *P2PBlockchainNode) handleGetHTLCs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	utxoStore := n.Chain.GetUTXOStore()
+
+	if id := query.Get("id"); id != "" {
+		htlc, err := utxoStore.GetHTLC(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if htlc == nil {
+			http.Error(w, "HTLC not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(htlc)
+		return
+	}
+
+	var address string
+	if s := query.Get("address"); s != "" {
+		addr, _, err := ParseAPIAddress(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+			return
+		}
+		address = addr.Display()
+	}
+	status := query.Get("status")
+	htlcs, err := utxoStore.ListHTLCs(func(h *HTLC) bool {
+		return (address == "" || h.Recipient == address || h.Refund == address) && (status == "" || h.Status == status)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if htlcs == nil {
+		htlcs = []*HTLC{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"htlcs": htlcs,
+		"count": len(htlcs),
+	})
+}
+
+// handleGetChainParams returns the network parameters, fees and supported transaction
+// types, so clients don't hard-code them
+func (n *P2PBlockchainNode) handleGetChainParams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.Chain.ChainParams(n.Mempool.RelayPolicy()))
+}
+
+// handleGetHeight returns the current blockchain height
+func (n *P2PBlockchainNode) handleGetHeight(w http.ResponseWriter, r *http.Request) {
+	height := n.Chain.GetHeight()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height": height,
+	})
+}
+
+// handleGetStateHash returns the UTXO set state hash after a block (default: the tip)
+func (n *P2PBlockchainNode) handleGetStateHash(w http.ResponseWriter, r *http.Request) {
+	block := n.Chain.GetLatestBlock()
+	if heightStr := r.URL.Query().Get("height"); heightStr != "" {
+		var height uint64
+		if _, err := fmt.Sscanf(heightStr, "%d", &height); err != nil {
+			http.Error(w, "Invalid height parameter", http.StatusBadRequest)
+			return
+		}
+		block = n.Chain.GetBlock(height)
+	}
+	if block == nil {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+
+	stateHash, err := n.Chain.GetUTXOStore().StateHash(block.Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stateHash == "" {
+		http.Error(w, "No state hash recorded for this block", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height":     block.Index,
+		"block_hash": block.Hash,
+		"state_hash": stateHash,
+	})
+}
+
+// handleGetBlock returns a specific block by index
+func (n *P2PBlockchainNode) handleGetBlock(w http.ResponseWriter, r *http.Request) {
+	// Extract block index from path
+	indexStr := r.URL.Path[len("/api/chain/block/"):]
+	if indexStr == "" {
+		http.Error(w, "Block index required", http.StatusBadRequest)
+		return
+	}
+
+	var index uint64
+	if _, err := fmt.Sscanf(indexStr, "%d", &index); err != nil {
+		http.Error(w, "Invalid block index", http.StatusBadRequest)
+		return
+	}
+
+	block := n.Chain.GetBlock(index)
+	if block == nil {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(block)
+}
+
+// handleGetBlocks returns a paginated list of recent blocks
+func (n *P2PBlockchainNode) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
+	// Parse query parameters
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 20 // default
+	offset := 0
+
+	if limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if offsetStr != "" {
+		if _, err := fmt.Sscanf(offsetStr, "%d", &offset); err != nil {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Cap limit to prevent abuse
+	if limit > 100 {
+		limit = 100
+	}
+
+	height := n.Chain.GetHeight()
+	blocks := []map[string]interface{}{}
+
+	// Get blocks in reverse order (newest first)
+	start := int(height) - 1 - offset
+	if start < 0 {
+		start = 0
+	}
+
+	count := 0
+	for i := start; i >= 0 && count < limit; i-- {
+		block := n.Chain.GetBlock(uint64(i))
+		if block == nil {
+			continue
+		}
+
+		// Return summary info (not full block with all transactions)
+		blockSummary := map[string]interface{}{
+			"index":     block.Index,
+			"hash":      block.Hash,
+			"prev_hash": block.PreviousHash,
+			"timestamp": block.Timestamp,
+			"tx_count":  len(block.Transactions),
+			"has_proof": block.WinningProof != nil,
+		}
+
+		// Add coinbase reward if present
+		if block.Coinbase != nil && len(block.Coinbase.Outputs) > 0 {
+			blockSummary["reward"] = block.Coinbase.Outputs[0].Amount
+		}
+
+		if block.WinningProof != nil {
+			blockSummary["proof_distance"] = block.WinningProof.Distance
+		}
+
+		blocks = append(blocks, blockSummary)
+		count++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height": height,
+		"blocks": blocks,
+		"limit":  limit,
+		"offset": offset,
+		"count":  len(blocks),
+	})
+}
+
+// handleGetBlockByHash returns a specific block by its hash
+func (n *P2PBlockchainNode) handleGetBlockByHash(w http.ResponseWriter, r *http.Request) {
+	// Extract block hash from path (served under /api/chain/block/hash/ and /api/block/hash/)
+	hashStr := r.URL.Path[strings.LastIndex(r.URL.Path, "/hash/")+len("/hash/"):]
+	if hashStr == "" {
+		http.Error(w, "Block hash required", http.StatusBadRequest)
+		return
+	}
+
+	block := n.Chain.GetBlockByHash(hashStr)
+	if block == nil {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(block)
+}
+
+// handleGetTransactionDetails returns full details of a transaction by hash
+func (n *P2PBlockchainNode) handleGetTransactionDetails(w http.ResponseWriter, r *http.Request) {
+	// Extract transaction hash from path
+	txHash := r.URL.Path[len("/api/transaction/"):]
+	if txHash == "" {
+		http.Error(w, "Transaction hash required", http.StatusBadRequest)
+		return
+	}
+
+	response, ok := n.transactionDetails(txHash)
+	if !ok {
+		if !n.writeConflicted(w, txHash) && !n.writePruned(w, txHash) {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// transactionDetails describes a stored transaction: where it was confirmed, whether it
+// failed, and the events it emitted
+func (n *P2PBlockchainNode) transactionDetails(txHash string) (map[string]interface{}, bool) {
+	utxoStore := n.Chain.GetUTXOStore()
+	tx, err := utxoStore.GetTransaction(txHash)
+	if err != nil || tx == nil {
+		return nil, false
+	}
+
+	// Find which block contains this transaction: indexed when it was applied, or by a
+	// scan for transactions applied before the index existed
+	chainStatus, _ := n.Chain.TxChainStatus(txHash)
+	var block *Block
+	if chainStatus != nil && chainStatus.Status != TxStatusOrphaned {
+		block = n.Chain.GetBlock(chainStatus.Height)
+	} else if chainStatus == nil {
+		block = n.findTransactionBlock(txHash)
+	}
+
+	// Build response with transaction details
+	response := map[string]interface{}{
+		"tx_hash":   txHash,
+		"tx_type":   tx.TxType,
+		"version":   tx.Version,
+		"locktime":  tx.LockTime,
+		"timestamp": tx.Timestamp,
+		"inputs":    tx.Inputs,
+		"outputs":   tx.Outputs,
+	}
+
+	if block != nil {
+		response["block_height"] = block.Index
+		response["block_hash"] = block.Hash
+		response["block_timestamp"] = block.Timestamp
+		response["confirmations"] = n.Chain.GetHeight() - block.Index
+		response["confirmed"] = true
+		response["status"] = TxStatusConfirmed
+		if chainStatus != nil {
+			response["status"] = chainStatus.Status
+		}
+		if failure, err := utxoStore.GetTxFailure(txHash); err == nil && failure != nil {
+			response["status"] = TxStatusFailed
+			response["failure"] = failure
+		}
+		if events, err := utxoStore.GetTxEvents(txHash); err == nil && events != nil {
+			response["events"] = events
+		}
+	} else {
+		response["confirmed"] = false
+		response["confirmations"] = 0
+		response["in_mempool"] = n.Mempool.HasTransaction(txHash)
+		if chainStatus != nil {
+			// A reorg replaced its block; report the block it left with
+			response["status"] = TxStatusOrphaned
+			response["orphaned_height"] = chainStatus.Height
+			response["orphaned_block_hash"] = chainStatus.BlockHash
+		}
+	}
+
+	// Add parsed data for special transaction types
+	if len(tx.Data) > 0 {
+		response["data"] = tx.Data
+	}
+	return response, true
+}
+
+// findTransactionBlock scans the chain for the block containing a transaction
+func (n *P2PBlockchainNode) findTransactionBlock(txHash string) *Block {
+	height := n.Chain.GetHeight()
+	for i := uint64(0); i < height; i++ {
+		block := n.Chain.GetBlock(i)
+		if block == nil {
+			continue
+		}
+		for _, txID := range blockTxIDs(block) {
+			if txID == txHash {
+				return block
+			}
+		}
+	}
+	return nil
+}
+
+// handleConsensusStatus returns consensus status
+func (n *P2PBlockchainNode) handleConsensusStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"is_leader":      n.Consensus.IsLeader(),
+		"node_id":        n.Consensus.nodeID,
+		"height":         n.Chain.GetHeight(),
+		"reward_address": n.Consensus.RewardAddress().Display(),
+		"fork_blocks":    n.Consensus.ForkBlocks(),
+		"engine":         ConsensusEngineGossip,
+		"failover":       n.Consensus.failover.Status(),
+	})
+}
 
-	// If sending SHADOW, we need to cover amount + fee from same UTXOs
-	requiredAmount := req.Amount
-	if tokenID == genesisTokenID {
-		requiredAmount = req.Amount + estimatedFee
+// handleRewardAddress shows (GET) or changes (POST) where block rewards are paid
+func (n *P2PBlockchainNode) handleRewardAddress(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if n.MiningPool != nil {
+			http.Error(w, "Pool operators must claim rewards with the node wallet", http.StatusConflict)
+			return
+		}
+		var req struct {
+			RewardAddress string `json:"reward_address"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Empty resets to the node wallet
+		addr := n.Wallet.Address
+		if req.RewardAddress != "" {
+			var err error
+			if addr, err = ParseRewardAddress(req.RewardAddress); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		n.Consensus.SetRewardAddress(addr)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Select token UTXOs to cover the required amount
-	var selectedTokenUTXOs []*UTXO
-	var tokenTotal uint64
-	for _, utxo := range availableTokenUTXOs {
-		selectedTokenUTXOs = append(selectedTokenUTXOs, utxo)
-		tokenTotal += utxo.Output.Amount
-		if tokenTotal >= requiredAmount {
-			break
+	rewardAddr := n.Consensus.RewardAddress()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reward_address": rewardAddr.Display(),
+		"is_node_wallet": rewardAddr == n.Wallet.Address,
+	})
+}
+
+// handlePoolPartial scores a partial proof submitted by a pool farmer
+func (n *P2PBlockchainNode) handlePoolPartial(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if n.MiningPool == nil {
+		http.Error(w, "This node is not a pool operator", http.StatusNotFound)
+		return
+	}
+
+	var partial PoolPartial
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&partial); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	points, err := n.MiningPool.SubmitPartial(&partial)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"accepted": false,
+			"error":    err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": true,
+		"points":   points,
+	})
+}
+
+// handlePoolStats returns the pool's farmers and payouts, or this farmer's pool submissions
+func (n *P2PBlockchainNode) handlePoolStats(w http.ResponseWriter, r *http.Request) {
+	var stats map[string]interface{}
+	if n.MiningPool != nil {
+		stats = n.MiningPool.Stats()
+		stats["mode"] = "operator"
+	} else if pc := n.Consensus.PoolClient(); pc != nil {
+		stats = pc.Stats()
+		stats["mode"] = "farmer"
+	} else {
+		stats = map[string]interface{}{"mode": "solo"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleGetBalance returns the balance and UTXOs for an address
+func (n *P2PBlockchainNode) handleGetBalance(w http.ResponseWriter, r *http.Request) {
+	addrStr, ok := n.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse address
+	addr, _, err := ParseAPIAddress(addrStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var height uint64
+	heightStr := r.URL.Query().Get("height")
+	if heightStr != "" {
+		if _, err := fmt.Sscanf(heightStr, "%d", &height); err != nil {
+			http.Error(w, "Invalid height parameter", http.StatusBadRequest)
+			return
 		}
 	}
+	chainTip := n.Chain.GetHeight() - 1
 
-	if tokenTotal < req.Amount {
-		http.Error(w, fmt.Sprintf("Insufficient %s balance: have %d, need %d", tokenID[:16], tokenTotal, req.Amount), http.StatusBadRequest)
+	// Read from a snapshot so blocks applied meanwhile can't be half counted; the
+	// balance is as of the snapshot's height. Writers wait for open snapshots, so it
+	// is closed before anything else that could wait on them.
+	snapshot, err := n.Chain.GetUTXOStore().Snapshot()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read UTXOs: %v", err), http.StatusInternalServerError)
 		return
 	}
+	tip, horizon := snapshot.Height, snapshot.PruneHorizon()
+	if !snapshot.Known {
+		tip = chainTip
+	}
 
-	// For custom tokens, also need SHADOW for fee
-	var selectedShadowUTXOs []*UTXO
-	var shadowTotal uint64
-	var targetFee uint64
+	// Get UTXOs for this address, optionally as of a past block height
+	var utxos []*UTXO
+	switch {
+	case heightStr == "":
+		height = tip
+		utxos, err = snapshot.GetUTXOsByAddress(addr)
+	case height <= tip && height >= horizon:
+		utxos, err = snapshot.GetUTXOsAtHeight(addr, height)
+	}
+	snapshot.Close()
 
-	if isCustomToken {
-		// Estimate fee based on token inputs + shadow inputs needed
-		if req.Fee > 0 {
-			targetFee = req.Fee
+	if height > tip {
+		http.Error(w, fmt.Sprintf("Height %d is above chain tip %d", height, tip), http.StatusBadRequest)
+		return
+	}
+	if height < horizon {
+		http.Error(w, fmt.Sprintf("History below height %d has been pruned", horizon), http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Calculate balance by token
+	balanceMap := make(map[string]uint64)
+	utxoList := []map[string]interface{}{}
+
+	for _, utxo := range utxos {
+		if !utxo.IsSpent {
+			// Add to balance
+			balanceMap[utxo.Output.TokenID] += utxo.Output.Amount
+
+			// Add to UTXO list
+			utxoList = append(utxoList, map[string]interface{}{
+				"tx_id":        utxo.TxID,
+				"output_index": utxo.OutputIndex,
+				"amount":       utxo.Output.Amount,
+				"token_id":     utxo.Output.TokenID,
+				"address_type": string(utxo.Output.Type()),
+				"block_height": utxo.BlockHeight,
+			})
+		}
+	}
+
+	// Convert balance map to array with token details
+	balances := []map[string]interface{}{}
+	tokenRegistry := n.Chain.TokenRegistry()
+
+	fmt.Printf("[Balance] Token registry has %d tokens registered\n", tokenRegistry.GetTokenCount())
+
+	for tokenID, balance := range balanceMap {
+		tokenInfo := map[string]interface{}{
+			"token_id": tokenID,
+			"balance":  balance,
+		}
+
+		// Look up token metadata from registry
+		token, exists := tokenRegistry.GetToken(tokenID)
+		if exists {
+			tokenInfo["name"] = token.Ticker // Use ticker as name
+			tokenInfo["ticker"] = token.Ticker
+			tokenInfo["decimals"] = token.MaxDecimals
 		} else {
-			// Estimate: token inputs + ~2 shadow inputs + outputs
-			targetFee = uint64(len(selectedTokenUTXOs)+2) * 1150
-			if targetFee < 11500 {
-				targetFee = 11500
-			}
+			// For unknown tokens, provide defaults
+			tokenInfo["name"] = "Unknown Token"
+			tokenInfo["ticker"] = "???"
+			tokenInfo["decimals"] = 8
 		}
 
-		// Select SHADOW UTXOs for fee
-		for _, utxo := range availableShadowUTXOs {
-			selectedShadowUTXOs = append(selectedShadowUTXOs, utxo)
-			shadowTotal += utxo.Output.Amount
-			if shadowTotal >= targetFee {
-				break
-			}
+		balances = append(balances, tokenInfo)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address":  addrStr,
+		"height":   height,
+		"balances": balances,
+		"utxos":    utxoList,
+		"count":    len(utxoList),
+	})
+}
+
+// handleGetBalances returns the token balances of many addresses, read from the balance
+// index in one snapshot so every balance is as of the same block
+func (n *P2PBlockchainNode) handleGetBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Addresses []string `json:"addresses"`
+		Tokens    []string `json:"tokens"` // Optional token IDs, default all held
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Addresses) == 0 || len(req.Addresses) > MaxBalanceBatchAddresses {
+		http.Error(w, fmt.Sprintf("addresses must list 1 to %d addresses", MaxBalanceBatchAddresses), http.StatusBadRequest)
+		return
+	}
+
+	addrs := make([]Address, len(req.Addresses))
+	for i, addrStr := range req.Addresses {
+		addr, _, err := ParseAPIAddress(addrStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid address %q: %v", addrStr, err), http.StatusBadRequest)
+			return
 		}
+		addrs[i] = addr
+	}
 
-		if shadowTotal < targetFee {
-			http.Error(w, fmt.Sprintf("Insufficient SHADOW for fee: have %d, need %d", shadowTotal, targetFee), http.StatusBadRequest)
+	chainTip := n.Chain.GetHeight() - 1
+	snapshot, err := n.Chain.GetUTXOStore().Snapshot()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read balances: %v", err), http.StatusInternalServerError)
+		return
+	}
+	height := snapshot.Height
+	if !snapshot.Known {
+		height = chainTip
+	}
+
+	results := make([]AddressBalances, len(addrs))
+	held := make(map[string]bool)
+	for i, addr := range addrs {
+		balances, err := snapshot.GetIndexedBalances(addr, req.Tokens)
+		if err != nil {
+			snapshot.Close()
+			http.Error(w, fmt.Sprintf("Failed to read balances: %v", err), http.StatusInternalServerError)
 			return
 		}
-	} else {
-		// Sending SHADOW: fee comes from the same UTXOs
-		if req.Fee > 0 {
-			targetFee = req.Fee
-		} else {
-			targetFee = uint64(len(selectedTokenUTXOs)) * 1150
-			if targetFee < 11500 {
-				targetFee = 11500
-			}
+		results[i] = AddressBalances{Address: req.Addresses[i], Balances: balances}
+		for tokenID := range balances {
+			held[tokenID] = true
 		}
+	}
+	snapshot.Close()
 
-		if tokenTotal < req.Amount+targetFee {
-			http.Error(w, fmt.Sprintf("Insufficient balance: have %d, need %d (including %d fee)", tokenTotal, req.Amount+targetFee, targetFee), http.StatusBadRequest)
-			return
+	// Token metadata once per token, not per balance
+	tokens := make(map[string]map[string]interface{})
+	tokenRegistry := n.Chain.TokenRegistry()
+	for tokenID := range held {
+		if token, exists := tokenRegistry.GetToken(tokenID); exists {
+			tokens[tokenID] = map[string]interface{}{"ticker": token.Ticker, "decimals": token.MaxDecimals}
 		}
 	}
 
-	// Create transaction manually to support memo
-	txBuilder := NewTxBuilder(TxTypeSend)
-	txBuilder.SetTimestamp(time.Now().Unix())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"height":   height,
+		"balances": results,
+		"tokens":   tokens,
+		"count":    len(results),
+	})
+}
 
-	// Add token inputs
-	for _, utxo := range selectedTokenUTXOs {
-		txBuilder.AddInput(utxo.TxID, utxo.OutputIndex)
+// handleGetUTXOs returns UTXOs for an address
+func (n *P2PBlockchainNode) handleGetUTXOs(w http.ResponseWriter, r *http.Request) {
+	addrStr, ok := n.addressParam(w, r)
+	if !ok {
+		return
 	}
 
-	// For custom tokens, also add SHADOW inputs for fee
-	if isCustomToken {
-		for _, utxo := range selectedShadowUTXOs {
-			txBuilder.AddInput(utxo.TxID, utxo.OutputIndex)
+	// Parse address
+	addr, _, err := ParseAPIAddress(addrStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Get UTXOs for this address, from the token index if filtering by token
+	var utxos []*UTXO
+	if tokenID := r.URL.Query().Get("token_id"); tokenID != "" {
+		if tokenID == "SHADOW" {
+			tokenID = GetGenesisToken().TokenID
+		}
+		utxos, err = n.Chain.GetUTXOStore().GetUTXOsByAddressAndToken(addr, tokenID)
+	} else {
+		utxos, err = n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Build UTXO list
+	utxoList := []map[string]interface{}{}
+	for _, utxo := range utxos {
+		if !utxo.IsSpent {
+			utxoList = append(utxoList, map[string]interface{}{
+				"tx_id":        utxo.TxID,
+				"output_index": utxo.OutputIndex,
+				"amount":       utxo.Output.Amount,
+				"token_id":     utxo.Output.TokenID,
+				"address":      utxo.Output.AddressString(),
+				"address_type": string(utxo.Output.Type()),
+				"block_height": utxo.BlockHeight,
+				"is_spent":     utxo.IsSpent,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address": addrStr,
+		"utxos":   utxoList,
+		"count":   len(utxoList),
+	})
+}
+
+// handleGetVesting returns an address's vesting outputs with locked vs vested amounts
+func (n *P2PBlockchainNode) handleGetVesting(w http.ResponseWriter, r *http.Request) {
+	addrStr, ok := n.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	addr, _, err := ParseAPIAddress(addrStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Amounts as of the next block, the earliest a claim could be mined
+	height := n.Chain.GetHeight()
+	type totals struct {
+		Locked uint64 `json:"locked"`
+		Vested uint64 `json:"vested"`
+	}
+	byToken := make(map[string]*totals)
+	outputs := []map[string]interface{}{}
+	for _, utxo := range utxos {
+		if utxo.IsSpent || utxo.Output.Vesting == nil {
+			continue
 		}
+		vested := utxo.Output.Vesting.VestedAmount(utxo.Output.Amount, height)
+		locked := utxo.Output.Amount - vested
+		if byToken[utxo.Output.TokenID] == nil {
+			byToken[utxo.Output.TokenID] = &totals{}
+		}
+		byToken[utxo.Output.TokenID].Locked += locked
+		byToken[utxo.Output.TokenID].Vested += vested
+		outputs = append(outputs, map[string]interface{}{
+			"tx_id":        utxo.TxID,
+			"output_index": utxo.OutputIndex,
+			"token_id":     utxo.Output.TokenID,
+			"amount":       utxo.Output.Amount,
+			"address_type": string(utxo.Output.Type()),
+			"schedule":     utxo.Output.Vesting,
+			"vested":       vested,
+			"locked":       locked,
+		})
 	}
 
-	// Add output to recipient (token)
-	txBuilder.AddOutput(toAddr, req.Amount, tokenID)
-
-	// Add change outputs
-	if isCustomToken {
-		// Custom token: change is separate for token and SHADOW
-		tokenChange := tokenTotal - req.Amount
-		if tokenChange > 0 {
-			txBuilder.AddOutput(n.Wallet.Address, tokenChange, tokenID)
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address": addrStr,
+		"height":  height,
+		"totals":  byToken,
+		"outputs": outputs,
+		"count":   len(outputs),
+	})
+}
 
-		shadowChange := shadowTotal - targetFee
-		if shadowChange > 0 {
-			txBuilder.AddOutput(n.Wallet.Address, shadowChange, genesisTokenID)
-		}
-	} else {
-		// SHADOW: fee is deducted from same UTXOs
-		change := tokenTotal - req.Amount - targetFee
-		if change > 0 {
-			txBuilder.AddOutput(n.Wallet.Address, change, tokenID)
-		}
+// handleClaimVesting moves the vested part of the node wallet's vesting outputs into
+// regular outputs, carrying the locked part forward
+func (n *P2PBlockchainNode) handleClaimVesting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
 	}
 
-	tx := txBuilder.Build()
+	var req struct {
+		TokenID string `json:"token_id"` // Token to claim ("SHADOW" or empty for SHADOW)
+		Fee     uint64 `json:"fee"`      // Optional fee
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Fee == 0 {
+		req.Fee = 11500 // Default minimum fee
+	}
 
-	// Add memo if provided
-	if req.Memo != "" {
-		// Validate memo is ASCII and max 64 bytes
-		if len(req.Memo) > 64 {
-			http.Error(w, "Memo must be <= 64 bytes", http.StatusBadRequest)
-			return
-		}
-		for _, c := range req.Memo {
-			if c > 127 {
-				http.Error(w, "Memo must be ASCII only", http.StatusBadRequest)
-				return
-			}
-		}
-		tx.Data = []byte(req.Memo)
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	// Sign the transaction
+	tx, claimed, err := CreateVestingClaimTransaction(n.Chain.TokenRegistry(), utxos, req.TokenID, n.Chain.GetHeight(), n.Wallet.Address, req.Fee)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create claim: %v", err), http.StatusBadRequest)
+		return
+	}
 	if err := n.Wallet.SignTransaction(tx); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to sign transaction: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Add to mempool
 	if err := n.Mempool.AddTransaction(tx); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to add transaction: %v", err), http.StatusBadRequest)
 		return
@@ -522,492 +2629,677 @@ func (n *P2PBlockchainNode) handleSendTransaction(w http.ResponseWriter, r *http
 	txID, _ := tx.ID()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"tx_id":  txID,
-		"tx":     tx,
+		"status":  "success",
+		"tx_id":   txID,
+		"claimed": claimed,
 	})
 }
 
-// handleGetPeers returns connected peers
-func (n *P2PBlockchainNode) handleGetPeers(w http.ResponseWriter, r *http.Request) {
-	peers := n.P2P.GetPeers()
-	peerStrs := make([]string, len(peers))
-	for i, p := range peers {
-		peerStrs[i] = p.String()
+// handleGetTransactions returns transaction history for an address
+func (n *P2PBlockchainNode) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
+	addrStr, ok := n.addressParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse address
+	addr, _, err := ParseAPIAddress(addrStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Get UTXOs to find transactions involving this address
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get transactions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Build transaction list (deduplicate by tx_id)
+	txMap := make(map[string]map[string]interface{})
+	for _, utxo := range utxos {
+		if _, exists := txMap[utxo.TxID]; !exists {
+			// Get the full transaction from the block
+			block := n.Chain.GetBlock(utxo.BlockHeight)
+			if block != nil {
+				// For now, just return basic info
+				entry := map[string]interface{}{
+					"tx_id":        utxo.TxID,
+					"block_height": utxo.BlockHeight,
+					"timestamp":    block.Timestamp,
+				}
+
+				// Resolve address book labels for the tx outputs
+				if tx, err := n.Chain.GetUTXOStore().GetTransaction(utxo.TxID); err == nil && tx != nil {
+					labels := make(map[string]string)
+					for _, output := range tx.Outputs {
+						if label := n.Addresses.LabelFor(output.Address); label != "" {
+							labels[output.Address.Display()] = label
+						}
+					}
+					if len(labels) > 0 {
+						entry["labels"] = labels
+					}
+				}
+
+				txMap[utxo.TxID] = entry
+			}
+		}
+	}
+
+	// Convert map to slice
+	txList := []map[string]interface{}{}
+	for _, tx := range txMap {
+		txList = append(txList, tx)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count": len(peers),
-		"peers": peerStrs,
+		"address":      addrStr,
+		"label":        n.Addresses.LabelFor(addr),
+		"transactions": txList,
+		"count":        len(txList),
 	})
 }
 
-// handleGetChain returns the entire blockchain
-func (n *P2PBlockchainNode) handleGetChain(w http.ResponseWriter, r *http.Request) {
-	blocks := n.Chain.GetBlocks()
-
+// handleReadyz reports readiness: 200 when synced to within ready_max_lag blocks of the
+// best peer, 503 otherwise
+func (n *P2PBlockchainNode) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := n.Readiness()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"height": len(blocks),
-		"blocks": blocks,
-	})
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
 }
 
-// handleGetHeight returns the current blockchain height
-func (n *P2PBlockchainNode) handleGetHeight(w http.ResponseWriter, r *http.Request) {
-	height := n.Chain.GetHeight()
+// handleSyncStatus returns block sync progress, rate, and ETA
+func (n *P2PBlockchainNode) handleSyncStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"height": height,
-	})
+	json.NewEncoder(w).Encode(n.Sync.Status())
 }
 
-// handleGetBlock returns a specific block by index
-func (n *P2PBlockchainNode) handleGetBlock(w http.ResponseWriter, r *http.Request) {
-	// Extract block index from path
-	indexStr := r.URL.Path[len("/api/chain/block/"):]
-	if indexStr == "" {
-		http.Error(w, "Block index required", http.StatusBadRequest)
+// handleSyncServing returns the limits on serving syncing peers and what has been served
+func (n *P2PBlockchainNode) handleSyncServing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var index uint64
-	if _, err := fmt.Sscanf(indexStr, "%d", &index); err != nil {
-		http.Error(w, "Invalid block index", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.SyncServer.ServeStats())
+}
+
+// handleSupplyStats returns circulating, burned, melted, and locked SHADOW totals
+func (n *P2PBlockchainNode) handleSupplyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	block := n.Chain.GetBlock(index)
-	if block == nil {
-		http.Error(w, "Block not found", http.StatusNotFound)
+	stats, err := n.Chain.SupplyStats()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compute supply stats: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(block)
+	json.NewEncoder(w).Encode(stats)
 }
 
-// handleGetBlocks returns a paginated list of recent blocks
-func (n *P2PBlockchainNode) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 20 // default
-	offset := 0
+// handleBlockStats returns the size of recent blocks against the block size limits
+func (n *P2PBlockchainNode) handleBlockStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	if limitStr != "" {
-		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil {
-			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+	count := DefaultBlockStatsCount
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		if _, err := fmt.Sscanf(countStr, "%d", &count); err != nil || count <= 0 {
+			http.Error(w, "Invalid count parameter", http.StatusBadRequest)
 			return
 		}
 	}
+	if count > MaxBlockStatsCount {
+		count = MaxBlockStatsCount
+	}
 
-	if offsetStr != "" {
-		if _, err := fmt.Sscanf(offsetStr, "%d", &offset); err != nil {
-			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.Chain.BlockSizeStats(count))
+}
+
+// handleRichList returns the largest holders of a token (SHADOW by default)
+func (n *P2PBlockchainNode) handleRichList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := DefaultRichListLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
 			return
 		}
 	}
 
 	// Cap limit to prevent abuse
-	if limit > 100 {
-		limit = 100
+	if limit > MaxRichListLimit {
+		limit = MaxRichListLimit
 	}
 
-	height := n.Chain.GetHeight()
-	blocks := []map[string]interface{}{}
+	tokenID := r.URL.Query().Get("token")
+	if tokenID == "" {
+		tokenID = GetGenesisToken().TokenID
+	}
 
-	// Get blocks in reverse order (newest first)
-	start := int(height) - 1 - offset
-	if start < 0 {
-		start = 0
+	utxoStore := n.Chain.GetUTXOStore()
+	entries, err := utxoStore.RichList(tokenID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to build rich list: %v", err), http.StatusInternalServerError)
+		return
+	}
+	supply, err := utxoStore.CirculatingSupply(tokenID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read supply: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	count := 0
-	for i := start; i >= 0 && count < limit; i-- {
-		block := n.Chain.GetBlock(uint64(i))
-		if block == nil {
-			continue
+	holders := make([]map[string]interface{}, 0, len(entries))
+	for i, entry := range entries {
+		percent := 0.0
+		if supply > 0 {
+			percent = float64(entry.Balance) * 100 / float64(supply)
 		}
-
-		// Return summary info (not full block with all transactions)
-		blockSummary := map[string]interface{}{
-			"index":     block.Index,
-			"hash":      block.Hash,
-			"prev_hash": block.PreviousHash,
-			"timestamp": block.Timestamp,
-			"tx_count":  len(block.Transactions),
-			"has_proof": block.WinningProof != nil,
+		label, address := "", entry.Address
+		if addr, _, err := ParseAddress(entry.Address); err == nil {
+			label, address = n.Addresses.LabelFor(addr), addr.Display()
 		}
+		holders = append(holders, map[string]interface{}{
+			"rank":    i + 1,
+			"address": address,
+			"label":   label,
+			"balance": entry.Balance,
+			"percent": percent,
+		})
+	}
 
-		// Add coinbase reward if present
-		if block.Coinbase != nil && len(block.Coinbase.Outputs) > 0 {
-			blockSummary["reward"] = block.Coinbase.Outputs[0].Amount
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token_id":    tokenID,
+		"circulating": supply,
+		"holders":     holders,
+		"count":       len(holders),
+	})
+}
 
-		if block.WinningProof != nil {
-			blockSummary["proof_distance"] = block.WinningProof.Distance
-		}
+// handleAudit runs the chain consistency audit and returns the report
+func (n *P2PBlockchainNode) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		blocks = append(blocks, blockSummary)
-		count++
+	report, err := n.Chain.Audit()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Audit failed: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"height": height,
-		"blocks": blocks,
-		"limit":  limit,
-		"offset": offset,
-		"count":  len(blocks),
-	})
+	json.NewEncoder(w).Encode(report)
 }
 
-// handleGetBlockByHash returns a specific block by its hash
-func (n *P2PBlockchainNode) handleGetBlockByHash(w http.ResponseWriter, r *http.Request) {
-	// Extract block hash from path
-	hashStr := r.URL.Path[len("/api/block/hash/"):]
-	if hashStr == "" {
-		http.Error(w, "Block hash required", http.StatusBadRequest)
+// handleEvidence lists rejected blocks and transactions, newest first, or returns one
+// entry with its payload (?id=)
+func (n *P2PBlockchainNode) handleEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	evidence := n.Chain.Evidence()
+	if evidence == nil {
+		http.Error(w, "evidence store not open", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	if id := query.Get("id"); id != "" {
+		entry, err := evidence.Get(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read evidence: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if entry == nil {
+			http.Error(w, "evidence not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
 		return
 	}
-
-	// Search for block with matching hash
-	height := n.Chain.GetHeight()
-	for i := uint64(0); i < height; i++ {
-		block := n.Chain.GetBlock(i)
-		if block != nil && block.Hash == hashStr {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(block)
+
+	filter := EvidenceFilter{
+		Kind:   query.Get("kind"),
+		Source: query.Get("source"),
+		Peer:   query.Get("peer"),
+		Hash:   query.Get("hash"),
+	}
+	if s := query.Get("since"); s != "" {
+		if _, err := fmt.Sscanf(s, "%d", &filter.Since); err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if _, err := fmt.Sscanf(s, "%d", &filter.Limit); err != nil || filter.Limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
 			return
 		}
 	}
+	entries, err := evidence.Query(filter, query.Get("payload") == "true")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read evidence: %v", err), http.StatusInternalServerError)
+		return
+	}
 
-	http.Error(w, "Block not found", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":    len(entries),
+		"evidence": entries,
+	})
 }
 
-// handleGetTransactionDetails returns full details of a transaction by hash
-func (n *P2PBlockchainNode) handleGetTransactionDetails(w http.ResponseWriter, r *http.Request) {
-	// Extract transaction hash from path
-	txHash := r.URL.Path[len("/api/transaction/"):]
-	if txHash == "" {
-		http.Error(w, "Transaction hash required", http.StatusBadRequest)
+// handleDBStats returns database file sizes, key counts, cache hit rates, and last compaction time
+func (n *P2PBlockchainNode) handleDBStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get transaction from UTXO store
-	utxoStore := n.Chain.GetUTXOStore()
-	tx, err := utxoStore.GetTransaction(txHash)
-	if err != nil || tx == nil {
-		http.Error(w, "Transaction not found", http.StatusNotFound)
+	health, err := n.Chain.DBHealth()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read database stats: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Find which block contains this transaction
-	var blockHeight uint64
-	var blockHash string
-	var blockTimestamp int64
-	var confirmations uint64
-
-	height := n.Chain.GetHeight()
-	found := false
-
-	for i := uint64(0); i < height; i++ {
-		block := n.Chain.GetBlock(i)
-		if block == nil {
-			continue
-		}
-
-		// Check if this block contains the transaction
-		for _, txID := range block.Transactions {
-			if txID == txHash {
-				blockHeight = block.Index
-				blockHash = block.Hash
-				blockTimestamp = block.Timestamp
-				confirmations = height - block.Index
-				found = true
-				break
-			}
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stores":      health,
+		"utxo_cache":  n.Chain.GetUTXOStore().UTXOCacheStats(),
+		"utxo_memory": n.Chain.GetUTXOStore().MemorySetStats(),
+		"sig_cache":   GetSigCacheStats(),
+	})
+}
 
-		// Also check coinbase
-		if block.Coinbase != nil {
-			coinbaseTxID, _ := block.Coinbase.ID()
-			if coinbaseTxID == txHash {
-				blockHeight = block.Index
-				blockHash = block.Hash
-				blockTimestamp = block.Timestamp
-				confirmations = height - block.Index
-				found = true
+// handleAddressBook lists (GET), adds (POST), or removes (DELETE) address book entries
+func (n *P2PBlockchainNode) handleAddressBook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if label := r.URL.Query().Get("label"); label != "" {
+			entry, ok := n.Addresses.Get(label)
+			if !ok {
+				http.Error(w, "Label not found", http.StatusNotFound)
+				return
 			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(entry.ToJSON())
+			return
 		}
 
-		if found {
-			break
+		entries := []map[string]interface{}{}
+		for _, entry := range n.Addresses.List() {
+			entries = append(entries, entry.ToJSON())
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	case http.MethodPost:
+		n.requireAuth(n.handleAddressBookSet)(w, r)
+	case http.MethodDelete:
+		n.requireAuth(n.handleAddressBookDelete)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
+}
 
-	// Build response with transaction details
-	response := map[string]interface{}{
-		"tx_hash":   txHash,
-		"tx_type":   tx.TxType,
-		"version":   tx.Version,
-		"locktime":  tx.LockTime,
-		"timestamp": tx.Timestamp,
-		"inputs":    tx.Inputs,
-		"outputs":   tx.Outputs,
+// handleAddressBookSet adds or updates a label
+func (n *P2PBlockchainNode) handleAddressBookSet(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Label       string `json:"label"`
+		Address     string `json:"address"`
+		Note        string `json:"note"`
+		RequireMemo *bool  `json:"require_memo"` // Optional, unchanged if omitted
 	}
 
-	if found {
-		response["block_height"] = blockHeight
-		response["block_hash"] = blockHash
-		response["block_timestamp"] = blockTimestamp
-		response["confirmations"] = confirmations
-		response["confirmed"] = true
-	} else {
-		response["confirmed"] = false
-		response["in_mempool"] = n.Mempool.HasTransaction(txHash)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	// Add parsed data for special transaction types
-	if len(tx.Data) > 0 {
-		response["data"] = tx.Data
+	addr, _, err := ParseAPIAddress(req.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
+	entry, err := n.Addresses.Set(req.Label, addr, req.Note)
+	if err == nil && req.RequireMemo != nil {
+		entry, err = n.Addresses.SetRequireMemo(req.Label, *req.RequireMemo)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save label: %v", err), http.StatusBadRequest)
+		return
+	}
 
-// handleConsensusStatus returns consensus status
-func (n *P2PBlockchainNode) handleConsensusStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"is_leader": n.Consensus.IsLeader(),
-		"node_id":   n.Consensus.nodeID,
-		"height":    n.Chain.GetHeight(),
+		"success": true,
+		"entry":   entry.ToJSON(),
 	})
 }
 
-// handleGetBalance returns the balance and UTXOs for an address
-func (n *P2PBlockchainNode) handleGetBalance(w http.ResponseWriter, r *http.Request) {
-	// Get address from query parameter or use node's own address
-	addrStr := r.URL.Query().Get("address")
-	if addrStr == "" {
-		addrStr = n.Wallet.Address.String()
-	}
-
-	// Parse address
-	addr, _, err := ParseAddress(addrStr)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+// handleAddressBookDelete removes a label
+func (n *P2PBlockchainNode) handleAddressBookDelete(w http.ResponseWriter, r *http.Request) {
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		http.Error(w, "label parameter required", http.StatusBadRequest)
 		return
 	}
 
-	// Get UTXOs for this address
-	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+	if err := n.Addresses.Delete(label); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete label: %v", err), http.StatusNotFound)
 		return
 	}
 
-	// Calculate balance by token
-	balanceMap := make(map[string]uint64)
-	utxoList := []map[string]interface{}{}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Label %s removed", label),
+	})
+}
 
-	for _, utxo := range utxos {
-		if !utxo.IsSpent {
-			// Add to balance
-			balanceMap[utxo.Output.TokenID] += utxo.Output.Amount
+// handleGetStatus returns node status information
+func (n *P2PBlockchainNode) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	peers := n.P2P.GetPeers()
+	peerStrs := make([]string, len(peers))
+	for i, p := range peers {
+		peerStrs[i] = p.String()
+	}
 
-			// Add to UTXO list
-			utxoList = append(utxoList, map[string]interface{}{
-				"tx_id":        utxo.TxID,
-				"output_index": utxo.OutputIndex,
-				"amount":       utxo.Output.Amount,
-				"token_id":     utxo.Output.TokenID,
-				"block_height": utxo.BlockHeight,
-			})
-		}
+	walletInfo := map[string]string{}
+	if n.Wallet != nil {
+		walletInfo["address"] = n.Wallet.Address.Display()
 	}
 
-	// Convert balance map to array with token details
-	balances := []map[string]interface{}{}
-	tokenRegistry := GetGlobalTokenRegistry()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"node_id":     n.P2P.Host.ID().String(),
+		"chain_id":    ActiveGenesis().ChainID,
+		"read_only":   n.readOnly,
+		"wallet_info": walletInfo,
+		"genesis_token": map[string]interface{}{
+			"token_id": GetGenesisToken().TokenID,
+			"name":     GetGenesisToken().Ticker,
+			"symbol":   GetGenesisToken().Ticker,
+			"decimals": GetGenesisToken().MaxDecimals,
+		},
+		"chain_height":     n.Chain.GetHeight(),
+		"peers":            peerStrs,
+		"peer_count":       len(peers),
+		"http_server_addr": apiURL(n.apiServer.Addr),
+		"is_leader":        n.Consensus.IsLeader(),
+	})
+}
 
-	fmt.Printf("[Balance] Token registry has %d tokens registered\n", tokenRegistry.GetTokenCount())
+// addressParam returns the address query parameter, defaulting to the node wallet. Read-only
+// nodes have no wallet, so they require one and reply 400 otherwise.
+func (n *P2PBlockchainNode) addressParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if addrStr := r.URL.Query().Get("address"); addrStr != "" {
+		return addrStr, true
+	}
+	if n.Wallet == nil {
+		http.Error(w, "address is required on a read-only node", http.StatusBadRequest)
+		return "", false
+	}
+	return n.Wallet.Address.Display(), true
+}
 
-	for tokenID, balance := range balanceMap {
-		tokenInfo := map[string]interface{}{
-			"token_id": tokenID,
-			"balance":  balance,
-		}
+// handleGetWalletInfo returns wallet information
+func (n *P2PBlockchainNode) handleGetWalletInfo(w http.ResponseWriter, r *http.Request) {
+	if n.Wallet == nil {
+		http.Error(w, "This node is read-only and has no wallet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address": n.Wallet.Address.Display(),
+	})
+}
 
-		// Look up token metadata from registry
-		token, exists := tokenRegistry.GetToken(tokenID)
-		if exists {
-			tokenInfo["name"] = token.Ticker // Use ticker as name
-			tokenInfo["ticker"] = token.Ticker
-			tokenInfo["decimals"] = token.MaxDecimals
-		} else {
-			// For unknown tokens, provide defaults
-			tokenInfo["name"] = "Unknown Token"
-			tokenInfo["ticker"] = "???"
-			tokenInfo["decimals"] = 8
-		}
+// handleGetWalletPending lists transactions this node submitted and whether each is
+// pending, confirmed or expired; ?status= filters by status
+func (n *P2PBlockchainNode) handleGetWalletPending(w http.ResponseWriter, r *http.Request) {
+	if n.WalletTxs == nil {
+		http.Error(w, "This node is read-only and submits no transactions", http.StatusNotFound)
+		return
+	}
 
-		balances = append(balances, tokenInfo)
+	status := WalletTxStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", WalletTxPending, WalletTxConfirmed, WalletTxExpired:
+	default:
+		http.Error(w, "status must be pending, confirmed or expired", http.StatusBadRequest)
+		return
 	}
 
+	txs := n.WalletTxs.List(status)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"address":  addrStr,
-		"balances": balances,
-		"utxos":    utxoList,
-		"count":    len(utxoList),
+		"count":        len(txs),
+		"transactions": txs,
 	})
 }
 
-// handleGetUTXOs returns UTXOs for an address
-func (n *P2PBlockchainNode) handleGetUTXOs(w http.ResponseWriter, r *http.Request) {
-	// Get address from query parameter or use node's own address
-	addrStr := r.URL.Query().Get("address")
-	if addrStr == "" {
-		addrStr = n.Wallet.Address.String()
+// handleNewAddress issues a fresh receive address derived from the wallet key
+func (n *P2PBlockchainNode) handleNewAddress(w http.ResponseWriter, r *http.Request) {
+	if n.Receive == nil {
+		http.Error(w, "This node has no local wallet key to derive receive addresses from", http.StatusNotFound)
+		return
 	}
 
-	// Parse address
-	addr, _, err := ParseAddress(addrStr)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+	issued, err := n.Receive.NewAddress()
+	if errors.Is(err, ErrGapLimitReached) {
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
-
-	// Get UTXOs for this address
-	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to issue receive address: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Build UTXO list
-	utxoList := []map[string]interface{}{}
-	for _, utxo := range utxos {
-		if !utxo.IsSpent {
-			utxoList = append(utxoList, map[string]interface{}{
-				"tx_id":        utxo.TxID,
-				"output_index": utxo.OutputIndex,
-				"amount":       utxo.Output.Amount,
-				"token_id":     utxo.Output.TokenID,
-				"address":      utxo.Output.Address.String(),
-				"block_height": utxo.BlockHeight,
-				"is_spent":     utxo.IsSpent,
-			})
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address":   issued.Display(),
+		"index":     issued.Index,
+		"unused":    n.Receive.Unused(),
+		"gap_limit": n.Receive.GapLimit(),
+	})
+}
+
+// handleGetReceiveAddresses lists issued receive addresses and how often each was paid
+func (n *P2PBlockchainNode) handleGetReceiveAddresses(w http.ResponseWriter, r *http.Request) {
+	if n.Receive == nil {
+		http.Error(w, "This node has no local wallet key to derive receive addresses from", http.StatusNotFound)
+		return
 	}
 
+	addresses := n.Receive.List()
+	for _, issued := range addresses {
+		issued.Address = issued.Display()
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"address": addrStr,
-		"utxos":   utxoList,
-		"count":   len(utxoList),
+		"count":     len(addresses),
+		"unused":    n.Receive.Unused(),
+		"gap_limit": n.Receive.GapLimit(),
+		"addresses": addresses,
 	})
 }
 
-// handleGetTransactions returns transaction history for an address
-func (n *P2PBlockchainNode) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
-	// Get address from query parameter or use node's own address
-	addrStr := r.URL.Query().Get("address")
-	if addrStr == "" {
-		addrStr = n.Wallet.Address.String()
+// handleWatchOnly lists watch-only addresses (GET), imports one (POST), or stops watching
+// one (DELETE). GET with ?address= returns one entry with its balance and pending
+// transactions.
+func (n *P2PBlockchainNode) handleWatchOnly(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if addrStr := r.URL.Query().Get("address"); addrStr != "" {
+			n.handleWatchOnlyGet(w, addrStr)
+			return
+		}
+
+		entries := []map[string]interface{}{}
+		for _, entry := range n.Watch.List() {
+			entries = append(entries, n.watchOnlyStatus(entry, false))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	case http.MethodPost:
+		n.requireAuth(n.handleWatchOnlyImport)(w, r)
+	case http.MethodDelete:
+		n.requireAuth(n.handleWatchOnlyRemove)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
+}
 
-	// Parse address
-	addr, _, err := ParseAddress(addrStr)
+// handleWatchOnlyGet returns one watch-only entry with its balance and pending transactions
+func (n *P2PBlockchainNode) handleWatchOnlyGet(w http.ResponseWriter, addrStr string) {
+	addr, _, err := ParseAPIAddress(addrStr)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
 		return
 	}
-
-	// Get UTXOs to find transactions involving this address
-	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(addr)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get transactions: %v", err), http.StatusInternalServerError)
+	entry, ok := n.Watch.Get(addr)
+	if !ok {
+		http.Error(w, "Address is not watched", http.StatusNotFound)
 		return
 	}
 
-	// Build transaction list (deduplicate by tx_id)
-	txMap := make(map[string]map[string]interface{})
-	for _, utxo := range utxos {
-		if _, exists := txMap[utxo.TxID]; !exists {
-			// Get the full transaction from the block
-			block := n.Chain.GetBlock(utxo.BlockHeight)
-			if block != nil {
-				// For now, just return basic info
-				txMap[utxo.TxID] = map[string]interface{}{
-					"tx_id":        utxo.TxID,
-					"block_height": utxo.BlockHeight,
-					"timestamp":    block.Timestamp,
-				}
-			}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.watchOnlyStatus(entry, true))
+}
+
+// watchOnlyStatus describes a watch-only entry with its SHADOW balance and pending
+// transactions, listed in full when detailed
+func (n *P2PBlockchainNode) watchOnlyStatus(entry *WatchOnlyEntry, detailed bool) map[string]interface{} {
+	utxoStore := n.Chain.GetUTXOStore()
+	balance, _ := utxoStore.GetIndexedBalance(entry.Address, GetGenesisToken().TokenID)
+	pending := PendingForAddress(n.Mempool.GetTransactions(), entry.Address, n.Mempool.pendingLookup(utxoStore))
+
+	// Flag incoming payments without the memo the address book requires
+	_, requireMemo := n.Addresses.MemoRequired()[entry.Address]
+	missingMemo := 0
+	for i := range pending {
+		if requireMemo && pending[i].Incoming && !pending[i].memo {
+			pending[i].MissingMemo = true
+			missingMemo++
 		}
 	}
-
-	// Convert map to slice
-	txList := []map[string]interface{}{}
-	for _, tx := range txMap {
-		txList = append(txList, tx)
+
+	status := entry.ToJSON()
+	status["balance"] = balance
+	status["pending_count"] = len(pending)
+	status["require_memo"] = requireMemo
+	status["missing_memo_count"] = missingMemo
+	if detailed {
+		status["pending"] = pending
+	}
+	return status
+}
+
+// handleWatchOnlyImport imports an address or public key as a watch-only entry
+func (n *P2PBlockchainNode) handleWatchOnlyImport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address   string `json:"address"`    // Address to watch, or
+		PublicKey string `json:"public_key"` // hex ML-DSA87 public key whose address to watch
+		Label     string `json:"label"`      // Optional
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	key := req.Address
+	if key == "" {
+		key = req.PublicKey
+	}
+	if key == "" {
+		http.Error(w, "address or public_key is required", http.StatusBadRequest)
+		return
+	}
+
+	if addr, _, err := ParseWatchOnlyKey(key); err == nil && n.Wallet != nil && addr == n.Wallet.Address {
+		http.Error(w, "The node wallet address is already signable", http.StatusBadRequest)
+		return
+	}
+	entry, err := n.Watch.Import(key, req.Label)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to import: %v", err), http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"address":      addrStr,
-		"transactions": txList,
-		"count":        len(txList),
+		"success": true,
+		"entry":   entry.ToJSON(),
 	})
 }
 
-// handleGetStatus returns node status information
-func (n *P2PBlockchainNode) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	peers := n.P2P.GetPeers()
-	peerStrs := make([]string, len(peers))
-	for i, p := range peers {
-		peerStrs[i] = p.String()
+// handleWatchOnlyRemove stops watching an address
+func (n *P2PBlockchainNode) handleWatchOnlyRemove(w http.ResponseWriter, r *http.Request) {
+	addr, _, err := ParseAPIAddress(r.URL.Query().Get("address"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := n.Watch.Remove(addr); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"node_id": n.P2P.Host.ID().String(),
-		"wallet_info": map[string]string{
-			"address": n.Wallet.Address.String(),
-		},
-		"genesis_token": map[string]interface{}{
-			"token_id": GetGenesisToken().TokenID,
-			"name":     GetGenesisToken().Ticker,
-			"symbol":   GetGenesisToken().Ticker,
-			"decimals": GetGenesisToken().MaxDecimals,
-		},
-		"chain_height":     n.Chain.GetHeight(),
-		"peers":            peerStrs,
-		"peer_count":       len(peers),
-		"http_server_addr": fmt.Sprintf("http://localhost:%d", n.apiPort),
-		"is_leader":        n.Consensus.IsLeader(),
+		"success": true,
+		"message": fmt.Sprintf("Stopped watching %s", addr.Display()),
 	})
 }
 
-// handleGetWalletInfo returns wallet information
-func (n *P2PBlockchainNode) handleGetWalletInfo(w http.ResponseWriter, r *http.Request) {
+// writeNotSignable replies 403 NOT_SIGNABLE to a request to sign for a watch-only address
+func writeNotSignable(w http.ResponseWriter, addr Address) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"address": n.Wallet.Address.String(),
+		"error":   NotSignableCode,
+		"message": ErrNotSignable.Error(),
+		"address": addr.Display(),
 	})
 }
 
 // handleGetTokens returns token registry information
 func (n *P2PBlockchainNode) handleGetTokens(w http.ResponseWriter, r *http.Request) {
-	registry := GetGlobalTokenRegistry()
+	registry := n.Chain.TokenRegistry()
 	tokens := registry.ListTokens()
 
 	tokenList := make([]map[string]interface{}, 0)
@@ -1023,9 +3315,10 @@ func (n *P2PBlockchainNode) handleGetTokens(w http.ResponseWriter, r *http.Reque
 			"max_mint":      token.MaxMint,
 			"max_decimals":  token.MaxDecimals,
 			"total_supply":  token.TotalSupply,
+			"unissued":      token.Unissued,
 			"locked_shadow": token.LockedShadow,
 			"total_melted":  token.TotalMelted,
-			"creator":       token.CreatorAddress.String(),
+			"creator":       token.CreatorAddress.Display(),
 			"is_shadow":     token.IsBaseToken(),
 			"fully_melted":  token.IsFullyMelted(),
 		})
@@ -1045,7 +3338,7 @@ func (n *P2PBlockchainNode) handleGetTokenInfo(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	registry := GetGlobalTokenRegistry()
+	registry := n.Chain.TokenRegistry()
 	token, exists := registry.GetToken(tokenID)
 	if !exists {
 		http.Error(w, "token not found", http.StatusNotFound)
@@ -1062,7 +3355,12 @@ func (n *P2PBlockchainNode) handleGetTokenInfo(w http.ResponseWriter, r *http.Re
 		"total_supply":     token.TotalSupply,
 		"locked_shadow":    token.LockedShadow,
 		"total_melted":     token.TotalMelted,
-		"creator":          token.CreatorAddress.String(),
+		"total_burned":     token.TotalBurned,
+		"issued":           token.Issued(),
+		"unissued":         token.Unissued,
+		"mint_delegates":   mintDelegatesResponse(token),
+		"collateral":       token.Collateral,
+		"creator":          token.CreatorAddress.Display(),
 		"creation_time":    token.CreationTime,
 		"is_shadow":        token.IsBaseToken(),
 		"fully_melted":     token.IsFullyMelted(),
@@ -1070,6 +3368,23 @@ func (n *P2PBlockchainNode) handleGetTokenInfo(w http.ResponseWriter, r *http.Re
 	})
 }
 
+// mintDelegatesResponse lists the addresses a token's creator let mint its unissued supply
+func mintDelegatesResponse(token *TokenInfo) []map[string]interface{} {
+	delegates := make([]map[string]interface{}, 0, len(token.MintDelegates))
+	for _, d := range token.MintDelegates {
+		delegates = append(delegates, map[string]interface{}{
+			"delegate":      d.Delegate.Display(),
+			"period_blocks": d.PeriodBlocks,
+			"period_limit":  d.PeriodLimit,
+			"granted_at":    d.GrantedAt,
+			"period":        d.Period,
+			"period_minted": d.PeriodMinted,
+			"total_minted":  d.TotalMinted,
+		})
+	}
+	return delegates
+}
+
 func (n *P2PBlockchainNode) handleMintToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
@@ -1077,85 +3392,420 @@ func (n *P2PBlockchainNode) handleMintToken(w http.ResponseWriter, r *http.Reque
 	}
 
 	var req struct {
-		Ticker      string `json:"ticker"`
-		Description string `json:"description"`
-		MaxMint     uint64 `json:"max_mint"`
-		MaxDecimals uint8  `json:"max_decimals"`
+		Ticker        string `json:"ticker"`
+		Description   string `json:"description"`
+		MaxMint       uint64 `json:"max_mint"`
+		MaxDecimals   uint8  `json:"max_decimals"`
+		InitialSupply uint64 `json:"initial_supply"` // Optional: mint only this much now, the rest later
+
+		// Minting more of a token created with initial_supply, as its creator or a delegate
+		TokenID string `json:"token_id"`
+		Amount  uint64 `json:"amount"`
+
+		// Optional: a token issued against SHADOW positions instead of minted
+		Collateral *CollateralTerms `json:"collateral"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Get SHADOW UTXOs for staking
+	shadowTokenID := GetGenesisToken().TokenID
+	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Filter for SHADOW UTXOs and calculate required amount
+	// Calculate total supply and estimated fee first
+	totalSupply := req.MaxMint
+	for i := uint8(0); i < req.MaxDecimals; i++ {
+		totalSupply *= 10
+	}
+	if req.TokenID != "" {
+		totalSupply = req.Amount
+	} else if req.Collateral != nil {
+		totalSupply = 0
+	} else if req.InitialSupply > 0 {
+		totalSupply = req.InitialSupply
+	}
+
+	// Estimate fee (will be recalculated in CreateTokenMintTransaction)
+	estimatedFee := CalculateTxFee(TxTypeMintToken, 10, 2, 0) // Estimate ~10 inputs
+	requiredAmount := totalSupply + estimatedFee
+
+	// Select only enough UTXOs to cover the required amount
+	var shadowUTXOs []*UTXO
+	totalSelected := uint64(0)
+	for _, utxo := range utxos {
+		if utxo.Output.TokenID == shadowTokenID {
+			shadowUTXOs = append(shadowUTXOs, utxo)
+			totalSelected += utxo.Output.Amount
+
+			// Stop once we have enough (with some buffer for fee adjustment)
+			if totalSelected >= requiredAmount*2 {
+				break
+			}
+		}
+	}
+
+	// Create mint transaction
+	var tx *Transaction
+	if req.TokenID != "" {
+		token, exists := n.Chain.TokenRegistry().GetToken(req.TokenID)
+		if !exists {
+			http.Error(w, "token not found", http.StatusNotFound)
+			return
+		}
+		req.Ticker = token.Ticker
+		tx, err = CreateSupplyMintTransaction(token, n.Wallet.Address, shadowUTXOs, req.Amount)
+	} else if req.Collateral != nil {
+		tx, err = CreateCollateralTokenTransaction(
+			n.Chain.TokenRegistry(),
+			n.Wallet.Address,
+			shadowUTXOs,
+			req.Ticker,
+			req.Description,
+			req.MaxMint,
+			req.MaxDecimals,
+			*req.Collateral,
+		)
+	} else {
+		tx, err = CreatePartialTokenMintTransaction(
+			n.Chain.TokenRegistry(),
+			n.Wallet.Address,
+			shadowUTXOs,
+			req.Ticker,
+			req.Description,
+			req.MaxMint,
+			req.MaxDecimals,
+			req.InitialSupply,
+		)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create mint transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Sign transaction
+	if err := n.Wallet.SignTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to sign transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Broadcast transaction
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to broadcast transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	txID, _ := tx.ID()
+	tokenID := txID // Token ID = TX ID for minting
+	if req.TokenID != "" {
+		tokenID = req.TokenID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"tx_id":    txID,
+		"token_id": tokenID,
+		"message":  fmt.Sprintf("Token %s minting transaction broadcast", req.Ticker),
+	})
+}
+
+// handleDelegateMint lets another address mint the node wallet's token, or revokes it
+func (n *P2PBlockchainNode) handleDelegateMint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TokenID      string `json:"token_id"`
+		Delegate     string `json:"delegate"`
+		PeriodBlocks uint64 `json:"period_blocks"` // Optional: limit period length
+		PeriodLimit  uint64 `json:"period_limit"`  // Optional: most the delegate may mint per period
+		Revoke       bool   `json:"revoke"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	delegate, _, err := ParseAddress(req.Delegate)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid delegate address: %v", err), http.StatusBadRequest)
+		return
+	}
+	token, exists := n.Chain.TokenRegistry().GetToken(req.TokenID)
+	if !exists || token.IsBaseToken() {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+	if token.CreatorAddress != n.Wallet.Address {
+		http.Error(w, "only the token's creator can delegate minting", http.StatusForbidden)
+		return
+	}
+
+	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tx, err := CreateMintDelegationTransaction(n.Wallet.Address, utxos, MintDelegationData{
+		TokenID:      req.TokenID,
+		Delegate:     delegate,
+		PeriodBlocks: req.PeriodBlocks,
+		PeriodLimit:  req.PeriodLimit,
+		Revoke:       req.Revoke,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create delegation transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := n.Wallet.SignTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to sign transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to broadcast transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	txID, _ := tx.ID()
+	action := "delegated to"
+	if req.Revoke {
+		action = "revoked for"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"tx_id":    txID,
+		"token_id": req.TokenID,
+		"delegate": delegate.Display(),
+		"message":  fmt.Sprintf("Minting of %s %s %s", token.Ticker, action, delegate.Display()),
+	})
+}
+
+// collateralPrice returns a collateralized token's price for the next block
+func (n *P2PBlockchainNode) collateralPrice(token *TokenInfo) uint64 {
+	return CollateralPrice(n.Chain.utxoStore, n.Chain.GetPoolRegistry(), token, n.Chain.GetHeight()+1)
+}
+
+// handleGetPositions lists collateral positions, optionally only a token's, an owner's
+// or those that can be liquidated at the next block's price
+func (n *P2PBlockchainNode) handleGetPositions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tokenID := query.Get("token_id")
+	var owner Address
+	if s := query.Get("owner"); s != "" {
+		addr, _, err := ParseAddress(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid owner address: %v", err), http.StatusBadRequest)
+			return
+		}
+		owner = addr
+	}
+	liquidatable := query.Get("liquidatable") == "true"
+
+	registry := n.Chain.TokenRegistry()
+	prices := make(map[string]uint64)
+	list := make([]map[string]interface{}, 0)
+	for _, position := range registry.ListPositions(func(p *CollateralPosition) bool {
+		return (tokenID == "" || p.TokenID == tokenID) && (owner == Address{} || p.Owner == owner)
+	}) {
+		token, exists := registry.GetToken(position.TokenID)
+		if !exists {
+			continue
+		}
+		price, ok := prices[token.TokenID]
+		if !ok {
+			price = n.collateralPrice(token)
+			prices[token.TokenID] = price
+		}
+		ratio := position.CollateralRatio(price)
+		canLiquidate := ratio < token.Collateral.LiquidationRatio
+		if liquidatable && !canLiquidate {
+			continue
+		}
+		entry := map[string]interface{}{
+			"position_id":  position.ID,
+			"token_id":     position.TokenID,
+			"ticker":       token.Ticker,
+			"owner":        position.Owner.Display(),
+			"collateral":   position.Collateral,
+			"debt":         position.Debt,
+			"price":        price,
+			"liquidatable": canLiquidate,
+			"opened_at":    position.OpenedAt,
+			"updated_at":   position.UpdatedAt,
+		}
+		if position.Debt > 0 {
+			entry["ratio"] = ratio
+		}
+		list = append(list, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":     len(list),
+		"positions": list,
+	})
+}
+
+// handleCollateralIssue locks node wallet SHADOW in a position and issues its token
+func (n *P2PBlockchainNode) handleCollateralIssue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TokenID    string `json:"token_id"`
+		PositionID string `json:"position_id"` // Optional: add to this position instead of opening one
+		Collateral uint64 `json:"collateral"`  // SHADOW to lock
+		Issue      uint64 `json:"issue"`       // Tokens to issue
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	registry := n.Chain.TokenRegistry()
+	if req.PositionID != "" {
+		position, exists := registry.GetPosition(req.PositionID)
+		if !exists {
+			http.Error(w, "position not found", http.StatusNotFound)
+			return
+		}
+		req.TokenID = position.TokenID
+	}
+	token, exists := registry.GetToken(req.TokenID)
+	if !exists {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+
+	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tx, err := CreateCollateralIssueTransaction(token, n.Wallet.Address, utxos, req.PositionID, req.Collateral, req.Issue)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create issue transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	n.submitCollateralTx(w, tx, req.PositionID, fmt.Sprintf("Issuing %d %s against %d SHADOW", req.Issue, token.Ticker, req.Collateral))
+}
+
+// handleCollateralMelt melts node wallet tokens back into one of its positions and
+// withdraws SHADOW from it
+func (n *P2PBlockchainNode) handleCollateralMelt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PositionID string `json:"position_id"`
+		Repay      uint64 `json:"repay"`    // Tokens to melt
+		Withdraw   uint64 `json:"withdraw"` // SHADOW to release
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	position, exists := n.Chain.TokenRegistry().GetPosition(req.PositionID)
+	if !exists {
+		http.Error(w, "position not found", http.StatusNotFound)
+		return
+	}
+	if position.Owner != n.Wallet.Address {
+		http.Error(w, "only the position's owner can melt into it", http.StatusForbidden)
+		return
+	}
+
+	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tx, err := CreateCollateralMeltTransaction(n.Chain.TokenRegistry(), position, utxos, req.Repay, req.Withdraw, n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create melt transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	n.submitCollateralTx(w, tx, position.ID, fmt.Sprintf("Repaying %d and withdrawing %d SHADOW", req.Repay, req.Withdraw))
+}
+
+// handleLiquidatePosition repays an undercollateralized position's debt from the node
+// wallet and takes its collateral
+func (n *P2PBlockchainNode) handleLiquidatePosition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PositionID string `json:"position_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	registry := n.Chain.TokenRegistry()
+	position, exists := registry.GetPosition(req.PositionID)
+	if !exists {
+		http.Error(w, "position not found", http.StatusNotFound)
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+	token, _ := registry.GetToken(position.TokenID)
+	if ratio := position.CollateralRatio(n.collateralPrice(token)); ratio >= token.Collateral.LiquidationRatio {
+		http.Error(w, fmt.Sprintf("position is %d%% collateralized, liquidation is below %d%%", ratio, token.Collateral.LiquidationRatio), http.StatusBadRequest)
 		return
 	}
 
-	// Get SHADOW UTXOs for staking
-	shadowTokenID := GetGenesisToken().TokenID
 	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Filter for SHADOW UTXOs and calculate required amount
-	// Calculate total supply and estimated fee first
-	totalSupply := req.MaxMint
-	for i := uint8(0); i < req.MaxDecimals; i++ {
-		totalSupply *= 10
-	}
-
-	// Estimate fee (will be recalculated in CreateTokenMintTransaction)
-	estimatedFee := CalculateTxFee(TxTypeMintToken, 10, 2, 0) // Estimate ~10 inputs
-	requiredAmount := totalSupply + estimatedFee
-
-	// Select only enough UTXOs to cover the required amount
-	var shadowUTXOs []*UTXO
-	totalSelected := uint64(0)
-	for _, utxo := range utxos {
-		if utxo.Output.TokenID == shadowTokenID {
-			shadowUTXOs = append(shadowUTXOs, utxo)
-			totalSelected += utxo.Output.Amount
-
-			// Stop once we have enough (with some buffer for fee adjustment)
-			if totalSelected >= requiredAmount*2 {
-				break
-			}
-		}
-	}
-
-	// Create mint transaction
-	tx, err := CreateTokenMintTransaction(
-		n.Wallet.Address,
-		shadowUTXOs,
-		req.Ticker,
-		req.Description,
-		req.MaxMint,
-		req.MaxDecimals,
-	)
+	tx, err := CreateLiquidationTransaction(n.Chain.TokenRegistry(), position, utxos, n.Wallet.Address)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to create mint transaction: %v", err), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("failed to create liquidation transaction: %v", err), http.StatusBadRequest)
 		return
 	}
+	n.submitCollateralTx(w, tx, position.ID, fmt.Sprintf("Liquidating %d %s for %d SHADOW", position.Debt, token.Ticker, position.Collateral))
+}
 
-	// Sign transaction
+// submitCollateralTx signs and broadcasts a collateral transaction and reports it
+func (n *P2PBlockchainNode) submitCollateralTx(w http.ResponseWriter, tx *Transaction, positionID, message string) {
 	if err := n.Wallet.SignTransaction(tx); err != nil {
 		http.Error(w, fmt.Sprintf("failed to sign transaction: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Broadcast transaction
 	if err := n.Mempool.AddTransaction(tx); err != nil {
-		http.Error(w, fmt.Sprintf("failed to broadcast transaction: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("failed to broadcast transaction: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	txID, _ := tx.ID()
-
+	if positionID == "" {
+		positionID = txID // Opening a position names it after the transaction
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":  true,
-		"tx_id":    txID,
-		"token_id": txID, // Token ID = TX ID for minting
-		"message":  fmt.Sprintf("Token %s minting transaction broadcast", req.Ticker),
+		"success":     true,
+		"tx_id":       txID,
+		"position_id": positionID,
+		"message":     message,
 	})
 }
 
@@ -1176,7 +3826,7 @@ func (n *P2PBlockchainNode) handleMeltToken(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Get token UTXOs
-	utxos, err := n.Chain.utxoStore.GetUTXOsByAddress(n.Wallet.Address)
+	utxos, err := n.Chain.utxoStore.GetUTXOsByAddressAndToken(n.Wallet.Address, req.TokenID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
 		return
@@ -1243,6 +3893,292 @@ func (n *P2PBlockchainNode) handleMeltToken(w http.ResponseWriter, r *http.Reque
 	})
 }
 
+// handleBurnToken provably destroys tokens from the node wallet
+func (n *P2PBlockchainNode) handleBurnToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST method required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TokenID string `json:"token_id"` // Token to burn ("SHADOW" or empty for SHADOW)
+		Amount  uint64 `json:"amount"`
+		Memo    string `json:"memo"` // Optional ASCII memo, up to 64 bytes
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByAddress(n.Wallet.Address)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := CreateBurnTransaction(n.Chain.TokenRegistry(), utxos, req.TokenID, req.Amount, n.Wallet.Address, req.Memo)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create burn transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := n.Wallet.SignTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to sign transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := n.Mempool.AddTransaction(tx); err != nil {
+		http.Error(w, fmt.Sprintf("failed to broadcast transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	txID, _ := tx.ID()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"tx_id":         txID,
+		"burned_amount": req.Amount,
+		"message":       fmt.Sprintf("Burned %d tokens", req.Amount),
+	})
+}
+
+// handleGetTokenBurns returns the total and per-transaction burns of a token
+func (n *P2PBlockchainNode) handleGetTokenBurns(w http.ResponseWriter, r *http.Request) {
+	tokenID := r.URL.Query().Get("token_id")
+	if tokenID == "" {
+		http.Error(w, "token_id parameter required", http.StatusBadRequest)
+		return
+	}
+	if tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	}
+
+	registry := n.Chain.TokenRegistry()
+	token, exists := registry.GetToken(tokenID)
+	if !exists {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+
+	burns := registry.GetBurns(tokenID)
+	if burns == nil {
+		burns = []*BurnRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token_id":     token.TokenID,
+		"ticker":       token.Ticker,
+		"burn_address": BurnAddress.Display(),
+		"total_burned": token.TotalBurned,
+		"count":        len(burns),
+		"burns":        burns,
+	})
+}
+
+// handleAirdrop lists airdrops or reports one (GET ?id=) and starts new ones (POST)
+func (n *P2PBlockchainNode) handleAirdrop(w http.ResponseWriter, r *http.Request) {
+	if n.Airdrops == nil {
+		http.Error(w, "This node is read-only and submits no transactions", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			airdrops := n.Airdrops.List()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"count":    len(airdrops),
+				"airdrops": airdrops,
+			})
+			return
+		}
+		airdrop := n.Airdrops.Get(id)
+		if airdrop == nil {
+			http.Error(w, "Airdrop not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"airdrop":    airdrop.Summary(),
+			"memo":       airdrop.Memo,
+			"recipients": airdrop.RecipientStatus(),
+		})
+	case http.MethodPost:
+		n.requireAuth(n.handleAirdropCreate)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAirdropCreate starts an airdrop from a JSON recipient list, a text/csv body or a
+// multipart upload with a "file" field. CSV rows are address,amount; with CSV, token_id,
+// memo and fee come from query or form fields.
+func (n *P2PBlockchainNode) handleAirdropCreate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TokenID    string       `json:"token_id"` // "SHADOW" or empty for SHADOW
+		Recipients []AirdropRow `json:"recipients"`
+		Memo       string       `json:"memo"` // Optional ASCII memo on every transaction, up to 64 bytes
+		Fee        uint64       `json:"fee"`  // Optional fixed fee per transaction, 0 = estimate
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxAirdropUploadBytes)
+	mediaType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	var csvBody io.Reader
+	switch mediaType {
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid upload: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		csvBody = file
+	case "text/csv":
+		csvBody = r.Body
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if csvBody != nil {
+		req.TokenID, req.Memo = r.FormValue("token_id"), r.FormValue("memo")
+		if fee := r.FormValue("fee"); fee != "" {
+			if _, err := fmt.Sscanf(fee, "%d", &req.Fee); err != nil {
+				http.Error(w, "Invalid fee", http.StatusBadRequest)
+				return
+			}
+		}
+		rows, err := ParseAirdropCSV(csvBody)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Recipients = rows
+	}
+
+	if req.TokenID == "" || req.TokenID == "SHADOW" {
+		req.TokenID = GetGenesisToken().TokenID
+	} else if _, exists := n.Chain.TokenRegistry().GetToken(req.TokenID); !exists {
+		http.Error(w, fmt.Sprintf("Unknown token: %s", req.TokenID), http.StatusBadRequest)
+		return
+	}
+	if len(req.Memo) > 64 || !isASCII(req.Memo) {
+		http.Error(w, "Memo must be ASCII and <= 64 bytes", http.StatusBadRequest)
+		return
+	}
+
+	recipients := make([]*AirdropRecipient, 0, len(req.Recipients))
+	for i, row := range req.Recipients {
+		to, toType, err := n.resolveRecipient(row.Address, TxTypeSend)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid address for recipient %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		recipients = append(recipients, &AirdropRecipient{Address: to.StringWithType(toType), Amount: row.Amount})
+	}
+
+	airdrop, err := n.Airdrops.Create(req.TokenID, recipients, req.Memo, req.Fee)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start airdrop: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(airdrop.Summary())
+}
+
+// handleGetTokenUTXOs returns unspent outputs of a token across all addresses
+func (n *P2PBlockchainNode) handleGetTokenUTXOs(w http.ResponseWriter, r *http.Request) {
+	tokenID := r.URL.Query().Get("token_id")
+	if tokenID == "" {
+		http.Error(w, "token_id parameter required", http.StatusBadRequest)
+		return
+	}
+	if tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	}
+
+	limit := MaxTokenUTXOList
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit > MaxTokenUTXOList {
+		limit = MaxTokenUTXOList
+	}
+
+	utxos, err := n.Chain.GetUTXOStore().GetUTXOsByToken(tokenID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get UTXOs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	utxoList := []map[string]interface{}{}
+	for _, utxo := range utxos {
+		utxoList = append(utxoList, map[string]interface{}{
+			"tx_id":        utxo.TxID,
+			"output_index": utxo.OutputIndex,
+			"amount":       utxo.Output.Amount,
+			"address":      utxo.Output.AddressString(),
+			"address_type": string(utxo.Output.Type()),
+			"block_height": utxo.BlockHeight,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token_id": tokenID,
+		"utxos":    utxoList,
+		"count":    len(utxoList),
+	})
+}
+
+// handleGetTokenTransactions lists confirmed transactions that moved a token, newest first
+func (n *P2PBlockchainNode) handleGetTokenTransactions(w http.ResponseWriter, r *http.Request) {
+	tokenID := r.URL.Query().Get("token_id")
+	if tokenID == "" {
+		http.Error(w, "token_id parameter required", http.StatusBadRequest)
+		return
+	}
+	if tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	}
+	if _, exists := n.Chain.TokenRegistry().GetToken(tokenID); !exists {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+
+	count := DefaultTokenTxPage
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		if _, err := fmt.Sscanf(countStr, "%d", &count); err != nil || count <= 0 {
+			http.Error(w, "Invalid count parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	transactions, err := n.Chain.GetUTXOStore().GetTransactionsByToken(tokenID, count, r.URL.Query().Get("after"))
+	if err != nil {
+		if errors.Is(err, ErrUnknownCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get transactions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token_id":     tokenID,
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
 // handleCreateOffer creates a new atomic swap offer
 func (n *P2PBlockchainNode) handleCreateOffer(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -1256,6 +4192,7 @@ func (n *P2PBlockchainNode) handleCreateOffer(w http.ResponseWriter, r *http.Req
 		HaveAmount     uint64 `json:"have_amount"`
 		WantAmount     uint64 `json:"want_amount"`
 		ExpiresAtBlock uint64 `json:"expires_at_block"`
+		AutoMatch      bool   `json:"auto_match"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1289,11 +4226,13 @@ func (n *P2PBlockchainNode) handleCreateOffer(w http.ResponseWriter, r *http.Req
 	tx, err := CreateOfferTransaction(
 		n.Wallet,
 		n.Chain.GetUTXOStore(),
+		n.Chain.TokenRegistry(),
 		req.HaveTokenID,
 		req.WantTokenID,
 		req.HaveAmount,
 		req.WantAmount,
 		req.ExpiresAtBlock,
+		req.AutoMatch,
 	)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create offer: %v", err), http.StatusBadRequest)
@@ -1312,6 +4251,7 @@ func (n *P2PBlockchainNode) handleCreateOffer(w http.ResponseWriter, r *http.Req
 		"tx_id":      txID,
 		"status":     "offer_created",
 		"expires_at": req.ExpiresAtBlock,
+		"auto_match": req.AutoMatch,
 	})
 }
 
@@ -1342,6 +4282,7 @@ func (n *P2PBlockchainNode) handleAcceptOffer(w http.ResponseWriter, r *http.Req
 	tx, err := CreateAcceptOfferTransaction(
 		n.Wallet,
 		n.Chain.GetUTXOStore(),
+		n.Chain.TokenRegistry(),
 		req.OfferTxID,
 		currentHeight,
 	)
@@ -1392,6 +4333,7 @@ func (n *P2PBlockchainNode) handleCancelOffer(w http.ResponseWriter, r *http.Req
 	tx, err := CreateCancelOfferTransaction(
 		n.Wallet,
 		n.Chain.GetUTXOStore(),
+		n.Chain.TokenRegistry(),
 		req.OfferTxID,
 		currentHeight,
 	)
@@ -1416,7 +4358,7 @@ func (n *P2PBlockchainNode) handleCancelOffer(w http.ResponseWriter, r *http.Req
 	})
 }
 
-// isOfferConsumed checks if an offer has been accepted or cancelled
+// isOfferConsumed checks if an offer has been accepted, cancelled or matched
 func (n *P2PBlockchainNode) isOfferConsumed(offerTxID string, utxoStore *UTXOStore) bool {
 	currentHeight := n.Chain.GetHeight()
 
@@ -1448,6 +4390,13 @@ func (n *P2PBlockchainNode) isOfferConsumed(offerTxID string, utxoStore *UTXOSto
 						return true
 					}
 				}
+			} else if tx.TxType == TxTypeMatchOffers {
+				var matchData MatchOffersData
+				if err := json.Unmarshal(tx.Data, &matchData); err == nil {
+					if matchData.MakerOfferTxID == offerTxID || matchData.TakerOfferTxID == offerTxID {
+						return true
+					}
+				}
 			}
 		}
 	}
@@ -1508,7 +4457,8 @@ func (n *P2PBlockchainNode) handleListOffers(w http.ResponseWriter, r *http.Requ
 				"have_amount":      offerData.HaveAmount,
 				"want_amount":      offerData.WantAmount,
 				"expires_at_block": offerData.ExpiresAtBlock,
-				"offer_address":    offerData.OfferAddress.String(),
+				"offer_address":    offerData.OfferAddress.Display(),
+				"auto_match":       offerData.AutoMatch,
 				"block_height":     i,
 			})
 		}
@@ -1555,7 +4505,7 @@ func (n *P2PBlockchainNode) handleCreatePool(w http.ResponseWriter, r *http.Requ
 
 	// Get stores
 	utxoStore := n.Chain.GetUTXOStore()
-	tokenRegistry := GetGlobalTokenRegistry()
+	tokenRegistry := n.Chain.TokenRegistry()
 	poolRegistry := n.Chain.GetPoolRegistry()
 
 	// Check if pool already exists for this token pair (in either order)
@@ -1580,6 +4530,10 @@ func (n *P2PBlockchainNode) handleCreatePool(w http.ResponseWriter, r *http.Requ
 		http.Error(w, fmt.Sprintf("Failed to create pool transaction: %v", err), http.StatusBadRequest)
 		return
 	}
+	if err := CheckPoolCreation(tx, poolRegistry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	txID, _ := tx.ID()
 	fmt.Printf("[API] Created pool transaction: %s (type: %d, inputs: %d, outputs: %d)\n",
@@ -1596,16 +4550,77 @@ func (n *P2PBlockchainNode) handleCreatePool(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"tx_id":   txID,
-		"status":  "pool_creation_submitted",
-		"pool_id": txID, // Pool ID is the creation transaction ID
+		"tx_id":        txID,
+		"status":       "pool_creation_submitted",
+		"pool_id":      txID, // Pool ID is the creation transaction ID
+		"creation_fee": ActiveGenesis().PoolCreationRules().CreationFee,
+	})
+}
+
+// handleGetPoolPosition returns an address's LP tokens in each pool, what they redeem
+// for, and the swap fees they have earned
+func (n *P2PBlockchainNode) handleGetPoolPosition(w http.ResponseWriter, r *http.Request) {
+	addrStr, ok := n.addressParam(w, r)
+	if !ok {
+		return
+	}
+	addr, _, err := ParseAPIAddress(addrStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid address: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	positions, err := n.Chain.LPPositions(addr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get positions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if poolID := r.URL.Query().Get("pool_id"); poolID != "" {
+		filtered := positions[:0]
+		for _, position := range positions {
+			if position.PoolID == poolID {
+				filtered = append(filtered, position)
+			}
+		}
+		positions = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address":   addr.Display(),
+		"positions": positions,
 	})
 }
 
+// handleGetPoolTWAP returns a pool's average prices over the last window blocks
+func (n *P2PBlockchainNode) handleGetPoolTWAP(w http.ResponseWriter, r *http.Request) {
+	poolID := r.URL.Query().Get("pool_id")
+	if poolID == "" {
+		http.Error(w, "pool_id is required", http.StatusBadRequest)
+		return
+	}
+	window := uint64(DefaultTWAPWindow)
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		if _, err := fmt.Sscanf(windowStr, "%d", &window); err != nil {
+			http.Error(w, "Invalid window parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	twap, err := n.Chain.PoolTWAP(poolID, window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(twap)
+}
+
 // handleListPools lists all active liquidity pools
 func (n *P2PBlockchainNode) handleListPools(w http.ResponseWriter, r *http.Request) {
 	poolRegistry := n.Chain.GetPoolRegistry()
-	tokenRegistry := GetGlobalTokenRegistry()
+	tokenRegistry := n.Chain.TokenRegistry()
 
 	pools := poolRegistry.GetAllPools()
 
@@ -1641,6 +4656,8 @@ func (n *P2PBlockchainNode) handleListPools(w http.ResponseWriter, r *http.Reque
 			"rate_a_to_b":     rateAtoB,
 			"rate_b_to_a":     rateBtoA,
 			"created_at":      pool.CreatedAt,
+			"fees_a":          pool.FeesA,
+			"fees_b":          pool.FeesB,
 		}
 
 		if tokenA != nil {
@@ -1687,7 +4704,7 @@ func (n *P2PBlockchainNode) handleAddLiquidity(w http.ResponseWriter, r *http.Re
 	poolRegistry := n.Chain.GetPoolRegistry()
 
 	// Create add liquidity transaction
-	tx, err := CreateAddLiquidityTransaction(n.Wallet, utxoStore, poolRegistry,
+	tx, err := CreateAddLiquidityTransaction(n.Wallet, utxoStore, n.Chain.TokenRegistry(), poolRegistry,
 		req.PoolID, req.AmountA, req.AmountB, req.MinLPTokens)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create transaction: %v", err), http.StatusBadRequest)
@@ -1732,7 +4749,7 @@ func (n *P2PBlockchainNode) handleRemoveLiquidity(w http.ResponseWriter, r *http
 	poolRegistry := n.Chain.GetPoolRegistry()
 
 	// Create remove liquidity transaction
-	tx, err := CreateRemoveLiquidityTransaction(n.Wallet, utxoStore, poolRegistry,
+	tx, err := CreateRemoveLiquidityTransaction(n.Wallet, utxoStore, n.Chain.TokenRegistry(), poolRegistry,
 		req.PoolID, req.LPTokens, req.MinAmountA, req.MinAmountB)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create transaction: %v", err), http.StatusBadRequest)
@@ -1777,7 +4794,7 @@ func (n *P2PBlockchainNode) handleSwap(w http.ResponseWriter, r *http.Request) {
 	poolRegistry := n.Chain.GetPoolRegistry()
 
 	// Create swap transaction
-	tx, err := CreateSwapTransaction(n.Wallet, utxoStore, poolRegistry,
+	tx, err := CreateSwapTransaction(n.Wallet, utxoStore, n.Chain.TokenRegistry(), poolRegistry,
 		req.PoolID, req.TokenIn, req.AmountIn, req.MinAmountOut)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create transaction: %v", err), http.StatusBadRequest)
@@ -1800,8 +4817,45 @@ func (n *P2PBlockchainNode) handleSwap(w http.ResponseWriter, r *http.Request) {
 
 // Close shuts down the node
 func (n *P2PBlockchainNode) Close() error {
+	if n.MiningPool != nil {
+		n.MiningPool.Close()
+	}
+	if n.WalletTxs != nil {
+		n.WalletTxs.Close()
+	}
+	if n.Receive != nil {
+		n.Receive.Close()
+	}
+	if n.Watch != nil {
+		n.Watch.Close()
+	}
+	if n.Memos != nil {
+		n.Memos.Close()
+	}
+	if n.Orphans != nil {
+		n.Orphans.Close()
+	}
+	if n.HTLCs != nil {
+		n.HTLCs.Close()
+	}
+	if n.Backups != nil {
+		n.Backups.Close()
+	}
+	if n.Airdrops != nil {
+		n.Airdrops.Close()
+	}
+	if n.apiServer != nil {
+		n.apiServer.Close()
+	}
+	if n.requestAudit != nil {
+		n.requestAudit.Close()
+	}
+	n.Events.Close() // Hijacked WebSocket connections outlive the server
+	n.Handshakes.Close()
 	n.Consensus.Close()
 	n.Mempool.Close()
 	n.Chain.Close()
+	n.Chain.Evidence().Close()
+	n.Addresses.Close()
 	return n.P2P.Close()
 }
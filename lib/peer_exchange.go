@@ -0,0 +1,343 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Peer exchange: every node periodically gossips its own signed peer record, plus the
+// freshest records it has learned, on a dedicated topic. Records are libp2p signed
+// envelopes, so a relayed record can't have its addresses altered, and their sequence
+// number is a timestamp, so stale addresses age out. Learned peers are saved across
+// restarts and dialed to keep outbound connections at the target.
+
+const (
+	PeerExchangeTopic          = "shadowy-pex"
+	PeerExchangeInterval       = 2 * time.Minute // How often a node gossips its record
+	MaxPeerRecordsPerMessage   = 32              // Records per gossip message, own included
+	MaxKnownPeers              = 1000            // Learned peers kept, oldest records dropped
+	PeerRecordMaxAge           = 24 * time.Hour  // Records older than this are ignored
+	DefaultTargetOutboundPeers = 8               // Dial learned peers until this many outbound
+	DefaultPeerBookPath        = "peers.json"
+
+	peerRecordMaxSkew = 10 * time.Minute // Records timestamped further ahead are refused
+)
+
+// PeerExchangeMessage carries signed peer records
+type PeerExchangeMessage struct {
+	ChainID string   `json:"chain_id"`
+	Records [][]byte `json:"records"` // Marshaled libp2p peer record envelopes
+}
+
+// KnownPeer is a peer learned through peer exchange
+type KnownPeer struct {
+	ID     string   `json:"id"`
+	Addrs  []string `json:"addrs"`
+	Seen   int64    `json:"seen"`   // Record timestamp, unix nanoseconds
+	Record []byte   `json:"record"` // Signed envelope, relayed as received
+}
+
+// peerBook holds the peers learned through peer exchange
+type peerBook struct {
+	mu    sync.Mutex
+	path  string
+	peers map[peer.ID]*KnownPeer
+}
+
+// openPeerRecord verifies a signed peer record and checks it is fresh
+func openPeerRecord(data []byte, now time.Time) (*peer.PeerRecord, error) {
+	envelope, rec, err := record.ConsumeEnvelope(data, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer record: %w", err)
+	}
+	peerRec, ok := rec.(*peer.PeerRecord)
+	if !ok {
+		return nil, fmt.Errorf("not a peer record")
+	}
+	if !peerRec.PeerID.MatchesPublicKey(envelope.PublicKey) {
+		return nil, fmt.Errorf("peer record for %s not signed by that peer", peerRec.PeerID)
+	}
+	if len(peerRec.Addrs) == 0 {
+		return nil, fmt.Errorf("peer record for %s has no addresses", peerRec.PeerID)
+	}
+
+	stamped := time.Unix(0, int64(peerRec.Seq))
+	if now.Sub(stamped) > PeerRecordMaxAge {
+		return nil, fmt.Errorf("peer record for %s is stale", peerRec.PeerID)
+	}
+	if stamped.Sub(now) > peerRecordMaxSkew {
+		return nil, fmt.Errorf("peer record for %s is from the future", peerRec.PeerID)
+	}
+	return peerRec, nil
+}
+
+// loadPeerBook reads learned peers from path (a missing file yields an empty book)
+func loadPeerBook(path string) (*peerBook, error) {
+	book := &peerBook{path: path, peers: make(map[peer.ID]*KnownPeer)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return book, nil
+	}
+	if err != nil {
+		return book, fmt.Errorf("failed to read peer book: %w", err)
+	}
+
+	var known []*KnownPeer
+	if err := json.Unmarshal(data, &known); err != nil {
+		return book, fmt.Errorf("failed to parse peer book: %w", err)
+	}
+	// Keep only records that still verify
+	now := time.Now()
+	for _, kp := range known {
+		if rec, err := openPeerRecord(kp.Record, now); err == nil {
+			book.peers[rec.PeerID] = knownPeer(rec, kp.Record)
+		}
+	}
+	return book, nil
+}
+
+// knownPeer builds a book entry from a verified record
+func knownPeer(rec *peer.PeerRecord, data []byte) *KnownPeer {
+	kp := &KnownPeer{ID: rec.PeerID.String(), Seen: int64(rec.Seq), Record: data}
+	for _, addr := range rec.Addrs {
+		kp.Addrs = append(kp.Addrs, addr.String())
+	}
+	return kp
+}
+
+// learn adds a signed record to the book; returns whether it was new or newer
+func (b *peerBook) learn(data []byte, self peer.ID, now time.Time) (bool, error) {
+	rec, err := openPeerRecord(data, now)
+	if err != nil {
+		return false, err
+	}
+	if rec.PeerID == self {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if old, ok := b.peers[rec.PeerID]; ok && old.Seen >= int64(rec.Seq) {
+		return false, nil
+	}
+	b.peers[rec.PeerID] = knownPeer(rec, data)
+
+	if len(b.peers) > MaxKnownPeers {
+		var oldest peer.ID
+		for id, kp := range b.peers {
+			if oldest == "" || kp.Seen < b.peers[oldest].Seen {
+				oldest = id
+			}
+		}
+		delete(b.peers, oldest)
+	}
+	return true, nil
+}
+
+// freshest returns the records of the n most recently updated peers
+func (b *peerBook) freshest(n int) [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	known := make([]*KnownPeer, 0, len(b.peers))
+	for _, kp := range b.peers {
+		known = append(known, kp)
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i].Seen > known[j].Seen })
+
+	var records [][]byte
+	for _, kp := range known {
+		if len(records) >= n {
+			break
+		}
+		records = append(records, kp.Record)
+	}
+	return records
+}
+
+// sample returns up to n random learned peers for which skip is false
+func (b *peerBook) sample(n int, skip func(peer.ID) bool) []peer.AddrInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var infos []peer.AddrInfo
+	for id, kp := range b.peers {
+		if skip(id) {
+			continue
+		}
+		pi := peer.AddrInfo{ID: id}
+		for _, s := range kp.Addrs {
+			if maddr, err := multiaddr.NewMultiaddr(s); err == nil {
+				pi.Addrs = append(pi.Addrs, maddr)
+			}
+		}
+		infos = append(infos, pi)
+	}
+	rand.Shuffle(len(infos), func(i, j int) { infos[i], infos[j] = infos[j], infos[i] })
+	if len(infos) > n {
+		infos = infos[:n]
+	}
+	return infos
+}
+
+// count returns the number of learned peers
+func (b *peerBook) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.peers)
+}
+
+// save writes the learned peers to the book's file
+func (b *peerBook) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	known := make([]*KnownPeer, 0, len(b.peers))
+	for _, kp := range b.peers {
+		known = append(known, kp)
+	}
+	b.mu.Unlock()
+	sort.Slice(known, func(i, j int) bool { return known[i].Seen > known[j].Seen })
+
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal peer book: %w", err)
+	}
+	if err := os.WriteFile(b.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write peer book: %w", err)
+	}
+	return nil
+}
+
+// KnownPeerCount returns the number of peers learned through peer exchange
+func (n *P2PNode) KnownPeerCount() int {
+	return n.book.count()
+}
+
+// signedSelfRecord returns this node's peer record, signed with its libp2p key
+func (n *P2PNode) signedSelfRecord() ([]byte, error) {
+	var addrs []multiaddr.Multiaddr
+	for _, addr := range n.Host.Addrs() {
+		if !manet.IsIPLoopback(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no dialable addresses")
+	}
+
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: n.Host.ID(), Addrs: addrs})
+	envelope, err := record.Seal(rec, n.Host.Peerstore().PrivKey(n.Host.ID()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign peer record: %w", err)
+	}
+	return envelope.Marshal()
+}
+
+// StartPeerExchange joins the peer exchange topic, learning peers from it and gossiping
+// this node's record
+func (n *P2PNode) StartPeerExchange(ps *pubsub.PubSub) error {
+	topic, err := ps.Join(ActiveGenesis().Topic(PeerExchangeTopic))
+	if err != nil {
+		return fmt.Errorf("failed to join peer exchange topic: %w", err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		topic.Close()
+		return fmt.Errorf("failed to subscribe to peer exchange topic: %w", err)
+	}
+
+	go n.listenForPeerRecords(sub)
+	go n.announceLoop(topic)
+	fmt.Printf("[P2P] Peer exchange active, %d learned peers\n", n.book.count())
+	return nil
+}
+
+// listenForPeerRecords learns the records gossiped by other nodes
+func (n *P2PNode) listenForPeerRecords(sub *pubsub.Subscription) {
+	defer sub.Cancel()
+	for {
+		msg, err := sub.Next(n.ctx)
+		if err != nil {
+			return // Node shutting down
+		}
+		if msg.ReceivedFrom == n.Host.ID() {
+			continue
+		}
+
+		var pex PeerExchangeMessage
+		if err := json.Unmarshal(msg.Data, &pex); err != nil || pex.ChainID != ActiveGenesis().ChainID {
+			continue
+		}
+		if len(pex.Records) > MaxPeerRecordsPerMessage {
+			continue
+		}
+
+		learned := 0
+		now := time.Now()
+		for _, data := range pex.Records {
+			if ok, _ := n.book.learn(data, n.Host.ID(), now); ok {
+				learned++
+			}
+		}
+		if learned > 0 {
+			fmt.Printf("[P2P] Learned %d peer addresses from %s (%d known)\n", learned, msg.ReceivedFrom.String()[:16], n.book.count())
+		}
+	}
+}
+
+// announceLoop gossips this node's record and the freshest learned ones, and saves the
+// peer book
+func (n *P2PNode) announceLoop(topic *pubsub.Topic) {
+	defer topic.Close()
+	ticker := time.NewTicker(PeerExchangeInterval)
+	defer ticker.Stop()
+
+	for {
+		self, err := n.signedSelfRecord()
+		if err == nil {
+			pex := PeerExchangeMessage{
+				ChainID: ActiveGenesis().ChainID,
+				Records: append([][]byte{self}, n.book.freshest(MaxPeerRecordsPerMessage-1)...),
+			}
+			if data, err := json.Marshal(pex); err == nil {
+				topic.Publish(n.ctx, data)
+			}
+		} else {
+			fmt.Printf("[P2P] Warning: not announcing peer record: %v\n", err)
+		}
+		if err := n.book.save(); err != nil {
+			fmt.Printf("[P2P] Warning: failed to save peer book: %v\n", err)
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dialKnownPeers dials up to count learned peers we aren't connected to
+func (n *P2PNode) dialKnownPeers(count int) {
+	if count <= 0 {
+		return
+	}
+	n.dialAll(n.book.sample(count, func(id peer.ID) bool {
+		return id == n.Host.ID() || n.Host.Network().Connectedness(id) == network.Connected
+	}))
+}
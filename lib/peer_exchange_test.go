@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// signPeerRecord seals a record of id at addr, stamped at the given time, with key
+func signPeerRecord(t *testing.T, key p2pcrypto.PrivKey, id peer.ID, addr string, stamped time.Time) []byte {
+	t.Helper()
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{multiaddr.StringCast(addr)}})
+	rec.Seq = uint64(stamped.UnixNano())
+	envelope, err := record.Seal(rec, key)
+	if err != nil {
+		t.Fatalf("Failed to seal record: %v", err)
+	}
+	data, err := envelope.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal record: %v", err)
+	}
+	return data
+}
+
+func TestPeerBookLearn(t *testing.T) {
+	newPeer := func() (p2pcrypto.PrivKey, peer.ID) {
+		key, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		id, _ := peer.IDFromPrivateKey(key)
+		return key, id
+	}
+	aliceKey, alice := newPeer()
+	malloryKey, _ := newPeer()
+	_, self := newPeer()
+
+	path := filepath.Join(t.TempDir(), "peers.json")
+	book, err := loadPeerBook(path)
+	if err != nil {
+		t.Fatalf("Missing peer book should not be an error: %v", err)
+	}
+	now := time.Now()
+
+	fresh := signPeerRecord(t, aliceKey, alice, "/ip4/203.0.113.5/tcp/9000", now.Add(-time.Minute))
+	if ok, err := book.learn(fresh, self, now); !ok || err != nil {
+		t.Fatalf("Expected a fresh signed record to be learned, got %v (%v)", ok, err)
+	}
+
+	// An older record doesn't replace a newer one
+	older := signPeerRecord(t, aliceKey, alice, "/ip4/198.51.100.7/tcp/9000", now.Add(-time.Hour))
+	if ok, _ := book.learn(older, self, now); ok {
+		t.Error("Expected an older record to be ignored")
+	}
+
+	// Records must be signed by the peer they describe, and be recent
+	rejected := map[string][]byte{
+		"forged":  signPeerRecord(t, malloryKey, alice, "/ip4/192.0.2.66/tcp/9000", now),
+		"stale":   signPeerRecord(t, aliceKey, alice, "/ip4/203.0.113.5/tcp/9000", now.Add(-2*PeerRecordMaxAge)),
+		"future":  signPeerRecord(t, aliceKey, alice, "/ip4/203.0.113.5/tcp/9000", now.Add(time.Hour)),
+		"garbage": []byte("not an envelope"),
+	}
+	for name, data := range rejected {
+		if ok, err := book.learn(data, self, now); ok || err == nil {
+			t.Errorf("%s: expected the record to be refused", name)
+		}
+	}
+
+	infos := book.sample(5, func(peer.ID) bool { return false })
+	if len(infos) != 1 || infos[0].ID != alice || infos[0].Addrs[0].String() != "/ip4/203.0.113.5/tcp/9000" {
+		t.Fatalf("Expected alice at her signed address, got %v", infos)
+	}
+	if infos := book.sample(5, func(id peer.ID) bool { return id == alice }); len(infos) != 0 {
+		t.Errorf("Expected skipped peers to be left out, got %v", infos)
+	}
+
+	// Learned peers survive a restart
+	if err := book.save(); err != nil {
+		t.Fatalf("Failed to save peer book: %v", err)
+	}
+	reloaded, err := loadPeerBook(path)
+	if err != nil {
+		t.Fatalf("Failed to load peer book: %v", err)
+	}
+	if reloaded.count() != 1 || len(reloaded.freshest(MaxPeerRecordsPerMessage)) != 1 {
+		t.Errorf("Expected alice's record after reload, got %d peers", reloaded.count())
+	}
+}
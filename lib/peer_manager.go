@@ -0,0 +1,311 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	DefaultMinOutboundPeers  = 4                // Re-bootstrap below this many outbound peers
+	DefaultMaxPeersPerSubnet = 2                // Outbound peers allowed per /16 (IPv4) or /32 (IPv6)
+	MaxAnchorPeers           = 8                // Outbound peers saved for reconnection on restart
+	OutboundCheckInterval    = 30 * time.Second // How often the watchdog checks connectivity
+	DefaultAnchorsPath       = "anchors.json"
+)
+
+// PeerManagerConfig controls outbound peer selection and the connectivity watchdog
+type PeerManagerConfig struct {
+	Seeds               []string // Bootstrap peers as libp2p multiaddrs with /p2p/ IDs
+	MinOutboundPeers    int      // Re-bootstrap when outbound peers drop below this
+	TargetOutboundPeers int      // Dial peers learned by peer exchange until this many outbound
+	MaxPeersPerSubnet   int      // Outbound peers allowed per subnet, 0 = unlimited
+	AnchorsPath         string   // File holding anchor peers across restarts
+	PeerBookPath        string   // File holding peers learned by peer exchange
+}
+
+// AnchorPeer is an outbound peer persisted for reconnection on restart
+type AnchorPeer struct {
+	ID    string   `json:"id"`
+	Addrs []string `json:"addrs"`
+}
+
+// subnetGater refuses outbound dials that would exceed the per-subnet limit, so a single
+// network range can't supply all of our outbound peers
+type subnetGater struct {
+	mu           sync.RWMutex
+	network      network.Network
+	maxPerSubnet int
+}
+
+// subnetKey returns the /16 (IPv4) or /32 (IPv6) group of an address, or "" for addresses
+// that are exempt from diversity limits (loopback, private, link-local, or unresolved)
+func subnetKey(addr multiaddr.Multiaddr) string {
+	ip, err := manet.ToIP(addr)
+	if err != nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
+
+// outboundBySubnet counts current outbound connections per subnet
+func outboundBySubnet(n network.Network) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range n.Conns() {
+		if c.Stat().Direction != network.DirOutbound {
+			continue
+		}
+		if key := subnetKey(c.RemoteMultiaddr()); key != "" {
+			counts[key]++
+		}
+	}
+	return counts
+}
+
+// setLimit changes the per-subnet outbound limit (0 = unlimited)
+func (g *subnetGater) setLimit(maxPerSubnet int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxPerSubnet = maxPerSubnet
+}
+
+func (g *subnetGater) InterceptPeerDial(p peer.ID) bool { return true }
+
+func (g *subnetGater) InterceptAddrDial(p peer.ID, addr multiaddr.Multiaddr) bool {
+	g.mu.RLock()
+	limit, nw := g.maxPerSubnet, g.network
+	g.mu.RUnlock()
+
+	key := subnetKey(addr)
+	if limit <= 0 || nw == nil || key == "" {
+		return true
+	}
+	if outboundBySubnet(nw)[key] >= limit {
+		fmt.Printf("[P2P] Skipping dial to %s: subnet %s already has %d outbound peers\n", addr, key, limit)
+		return false
+	}
+	return true
+}
+
+func (g *subnetGater) InterceptAccept(network.ConnMultiaddrs) bool { return true }
+
+func (g *subnetGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *subnetGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// OutboundPeers returns peers we dialed
+func (n *P2PNode) OutboundPeers() []peer.ID {
+	seen := make(map[peer.ID]bool)
+	var peers []peer.ID
+	for _, c := range n.Host.Network().Conns() {
+		if c.Stat().Direction == network.DirOutbound && !seen[c.RemotePeer()] {
+			seen[c.RemotePeer()] = true
+			peers = append(peers, c.RemotePeer())
+		}
+	}
+	return peers
+}
+
+// OutboundSubnets returns the number of outbound connections per subnet
+func (n *P2PNode) OutboundSubnets() map[string]int {
+	return outboundBySubnet(n.Host.Network())
+}
+
+// StartPeerManager applies the subnet diversity limit, reconnects to saved anchor
+// peers, and starts a watchdog that re-bootstraps when outbound peers run low
+func (n *P2PNode) StartPeerManager(cfg PeerManagerConfig) {
+	if cfg.AnchorsPath == "" {
+		cfg.AnchorsPath = DefaultAnchorsPath
+	}
+	if cfg.PeerBookPath == "" {
+		cfg.PeerBookPath = DefaultPeerBookPath
+	}
+	n.peerConfig = cfg
+	n.gater.setLimit(cfg.MaxPeersPerSubnet)
+
+	book, err := loadPeerBook(cfg.PeerBookPath)
+	if err != nil {
+		fmt.Printf("[P2P] Warning: failed to load learned peers: %v\n", err)
+	}
+	n.book = book
+
+	anchors, err := LoadAnchors(cfg.AnchorsPath)
+	if err != nil {
+		fmt.Printf("[P2P] Warning: failed to load anchor peers: %v\n", err)
+	}
+	if len(anchors) > 0 {
+		fmt.Printf("[P2P] Reconnecting to %d anchor peers\n", len(anchors))
+	}
+	n.dialAll(anchors)
+	n.dialAll(seedAddrInfos(cfg.Seeds))
+	n.dialKnownPeers(cfg.TargetOutboundPeers)
+
+	go n.outboundWatchdog()
+}
+
+// outboundWatchdog checks outbound connectivity and re-bootstraps when it drops
+func (n *P2PNode) outboundWatchdog() {
+	ticker := time.NewTicker(OutboundCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		outbound := len(n.OutboundPeers())
+		if outbound >= n.peerConfig.MinOutboundPeers {
+			// Keep anchors fresh while connectivity is healthy
+			if err := n.SaveAnchors(); err != nil {
+				fmt.Printf("[P2P] Warning: failed to save anchor peers: %v\n", err)
+			}
+			// Top up from peers learned by peer exchange
+			n.dialKnownPeers(n.peerConfig.TargetOutboundPeers - outbound)
+			continue
+		}
+
+		fmt.Printf("[P2P] ⚠️  Only %d outbound peers (minimum %d), re-bootstrapping\n",
+			outbound, n.peerConfig.MinOutboundPeers)
+		n.Rebootstrap()
+	}
+}
+
+// Rebootstrap dials anchors, seeds, previously discovered peers and learned peers we're
+// not connected to
+func (n *P2PNode) Rebootstrap() {
+	anchors, _ := LoadAnchors(n.peerConfig.AnchorsPath)
+	n.dialAll(anchors)
+	n.dialAll(seedAddrInfos(n.peerConfig.Seeds))
+
+	n.peerLock.RLock()
+	known := make([]peer.AddrInfo, 0, len(n.peers))
+	for _, pi := range n.peers {
+		known = append(known, pi)
+	}
+	n.peerLock.RUnlock()
+	n.dialAll(known)
+	n.dialKnownPeers(n.peerConfig.TargetOutboundPeers)
+}
+
+// dialAll connects to each peer we aren't already connected to (subject to the gater)
+func (n *P2PNode) dialAll(peers []peer.AddrInfo) {
+	for _, pi := range peers {
+		if pi.ID == n.Host.ID() || n.Host.Network().Connectedness(pi.ID) == network.Connected {
+			continue
+		}
+		go func(pi peer.AddrInfo) {
+			if err := n.Host.Connect(n.ctx, pi); err != nil {
+				fmt.Printf("[P2P] Failed to connect to %s: %v\n", pi.ID.String(), err)
+				return
+			}
+			n.peerLock.Lock()
+			n.peers[pi.ID] = pi
+			n.peerLock.Unlock()
+			fmt.Printf("[P2P] Connected to peer: %s\n", pi.ID.String())
+		}(pi)
+	}
+}
+
+// seedAddrInfos parses seeds given as libp2p multiaddrs; other formats are skipped
+func seedAddrInfos(seeds []string) []peer.AddrInfo {
+	var infos []peer.AddrInfo
+	for _, seed := range seeds {
+		maddr, err := multiaddr.NewMultiaddr(seed)
+		if err != nil {
+			continue
+		}
+		pi, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, *pi)
+	}
+	return infos
+}
+
+// SaveAnchors writes up to MaxAnchorPeers current outbound peers to the anchors file
+func (n *P2PNode) SaveAnchors() error {
+	if n.peerConfig.AnchorsPath == "" {
+		return nil
+	}
+
+	var anchors []AnchorPeer
+	for _, p := range n.OutboundPeers() {
+		if len(anchors) >= MaxAnchorPeers {
+			break
+		}
+		var addrs []string
+		for _, c := range n.Host.Network().ConnsToPeer(p) {
+			if c.Stat().Direction == network.DirOutbound {
+				addrs = append(addrs, c.RemoteMultiaddr().String())
+			}
+		}
+		anchors = append(anchors, AnchorPeer{ID: p.String(), Addrs: addrs})
+	}
+
+	// Don't wipe good anchors while we're disconnected
+	if len(anchors) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(anchors, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal anchors: %w", err)
+	}
+	if err := os.WriteFile(n.peerConfig.AnchorsPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write anchors: %w", err)
+	}
+	return nil
+}
+
+// LoadAnchors reads anchor peers from path (a missing file yields no anchors)
+func LoadAnchors(path string) ([]peer.AddrInfo, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anchors: %w", err)
+	}
+
+	var anchors []AnchorPeer
+	if err := json.Unmarshal(data, &anchors); err != nil {
+		return nil, fmt.Errorf("failed to parse anchors: %w", err)
+	}
+
+	var infos []peer.AddrInfo
+	for _, a := range anchors {
+		id, err := peer.Decode(a.ID)
+		if err != nil {
+			continue
+		}
+		pi := peer.AddrInfo{ID: id}
+		for _, s := range a.Addrs {
+			if maddr, err := multiaddr.NewMultiaddr(s); err == nil {
+				pi.Addrs = append(pi.Addrs, maddr)
+			}
+		}
+		if len(pi.Addrs) > 0 {
+			infos = append(infos, pi)
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestSubnetKey(t *testing.T) {
+	cases := map[string]string{
+		"/ip4/203.0.113.5/tcp/9000":   "203.0.0.0/16",
+		"/ip4/203.0.200.9/tcp/9000":   "203.0.0.0/16",
+		"/ip4/192.168.1.10/tcp/9000":  "", // Private
+		"/ip4/127.0.0.1/tcp/9000":     "", // Loopback
+		"/ip6/2001:db8:1::1/tcp/9000": "2001:db8::/32",
+		"/dns4/example.com/tcp/9000":  "", // Unresolved
+	}
+	for addr, want := range cases {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			t.Fatalf("Bad test address %s: %v", addr, err)
+		}
+		if got := subnetKey(maddr); got != want {
+			t.Errorf("subnetKey(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestLoadAnchors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anchors.json")
+
+	// Missing file is not an error
+	if anchors, err := LoadAnchors(path); err != nil || len(anchors) != 0 {
+		t.Fatalf("Expected no anchors for missing file, got %v (%v)", anchors, err)
+	}
+
+	data := `[
+  {"id": "12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA", "addrs": ["/ip4/203.0.113.5/tcp/9000"]},
+  {"id": "not-a-peer-id", "addrs": ["/ip4/203.0.113.6/tcp/9000"]},
+  {"id": "12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA", "addrs": ["garbage"]}
+]`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write anchors: %v", err)
+	}
+
+	anchors, err := LoadAnchors(path)
+	if err != nil {
+		t.Fatalf("Failed to load anchors: %v", err)
+	}
+	if len(anchors) != 1 || len(anchors[0].Addrs) != 1 {
+		t.Fatalf("Expected exactly one usable anchor, got %v", anchors)
+	}
+}
+
+func TestSeedAddrInfos(t *testing.T) {
+	seeds := []string{
+		"/ip4/203.0.113.5/tcp/9000/p2p/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA",
+		"c1664df26a9bdf2e5b14f88ebacd6e12e42a761e@127.0.0.1:26666", // Legacy format, skipped
+	}
+	if infos := seedAddrInfos(seeds); len(infos) != 1 {
+		t.Fatalf("Expected one multiaddr seed, got %d", len(infos))
+	}
+}
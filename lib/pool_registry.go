@@ -1,23 +1,54 @@
 package lib
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 )
 
-// PoolRegistry manages all liquidity pools in the system
+// Pools are persisted in the UTXO store as blocks change them, inside the same batch as
+// the block's UTXO updates, and loaded at startup. Scanning the chain for pool creations
+// is only needed once for databases from before pools were persisted; --reindex replays
+// every block and rebuilds them exactly.
+
+const (
+	PoolPrefix          = "pool:"            // pool:{pool_id} -> storedPool
+	poolIndexVersionKey = "poolmeta:version" // Set once the stored pools are complete
+	poolIndexVersion    = "1"
+)
+
+// storedPool is a persisted pool with its LP token, so both can be restored without the
+// transaction that created them
+type storedPool struct {
+	Pool    *LiquidityPool `json:"pool"`
+	LPToken *TokenInfo     `json:"lp_token,omitempty"`
+}
+
+// PoolRegistry manages all liquidity pools in the system. Pools are handed out as copies,
+// so changes only take effect (and are persisted) through RegisterPool and UpdatePool.
 type PoolRegistry struct {
-	pools map[string]*LiquidityPool // poolID -> pool
-	mutex sync.RWMutex
+	pools  map[string]*LiquidityPool // poolID -> pool
+	mutex  sync.RWMutex
+	store  *UTXOStore     // Persists every change; nil keeps pools in memory only
+	tokens *TokenRegistry // Holds the pools' LP tokens, persisted with them
 }
 
-// NewPoolRegistry creates a new pool registry
+// NewPoolRegistry creates a new in-memory pool registry
 func NewPoolRegistry() *PoolRegistry {
 	return &PoolRegistry{
 		pools: make(map[string]*LiquidityPool),
 	}
 }
 
+// NewPersistentPoolRegistry creates a pool registry that writes every change to store,
+// with the LP tokens of its pools in tokens
+func NewPersistentPoolRegistry(store *UTXOStore, tokens *TokenRegistry) *PoolRegistry {
+	pr := NewPoolRegistry()
+	pr.store = store
+	pr.tokens = tokens
+	return pr
+}
+
 // RegisterPool registers a new liquidity pool
 func (pr *PoolRegistry) RegisterPool(pool *LiquidityPool) error {
 	pr.mutex.Lock()
@@ -42,8 +73,11 @@ func (pr *PoolRegistry) RegisterPool(pool *LiquidityPool) error {
 		return err
 	}
 
-	// Store in memory
-	pr.pools[pool.PoolID] = pool
+	if err := pr.persistLocked(pool); err != nil {
+		return err
+	}
+	stored := *pool
+	pr.pools[pool.PoolID] = &stored
 
 	fmt.Printf("[PoolRegistry] ✅ Registered pool %s: %s/%s (K=%d, fee=%d bps)\n",
 		pool.PoolID[:16], pool.TokenA[:8], pool.TokenB[:8], pool.K, pool.FeePercent)
@@ -61,7 +95,8 @@ func (pr *PoolRegistry) GetPool(poolID string) (*LiquidityPool, error) {
 		return nil, fmt.Errorf("pool %s not found", poolID)
 	}
 
-	return pool, nil
+	copied := *pool
+	return &copied, nil
 }
 
 // UpdatePool updates an existing pool (takes pool object)
@@ -73,8 +108,11 @@ func (pr *PoolRegistry) UpdatePool(pool *LiquidityPool) error {
 		return fmt.Errorf("pool %s not found", pool.PoolID)
 	}
 
-	// Update the pool in registry
-	pr.pools[pool.PoolID] = pool
+	if err := pr.persistLocked(pool); err != nil {
+		return err
+	}
+	stored := *pool
+	pr.pools[pool.PoolID] = &stored
 
 	return nil
 }
@@ -84,17 +122,23 @@ func (pr *PoolRegistry) UpdatePoolReserves(poolID string, reserveA, reserveB, lp
 	pr.mutex.Lock()
 	defer pr.mutex.Unlock()
 
-	pool, exists := pr.pools[poolID]
+	existing, exists := pr.pools[poolID]
 	if !exists {
 		return fmt.Errorf("pool %s not found", poolID)
 	}
 
 	// Update reserves and recalculate K
+	pool := *existing
 	pool.ReserveA = reserveA
 	pool.ReserveB = reserveB
 	pool.LPTokenSupply = lpTokenSupply
 	pool.K = CalculateK(reserveA, reserveB)
 
+	if err := pr.persistLocked(&pool); err != nil {
+		return err
+	}
+	pr.pools[poolID] = &pool
+
 	return nil
 }
 
@@ -105,7 +149,8 @@ func (pr *PoolRegistry) GetAllPools() []*LiquidityPool {
 
 	pools := make([]*LiquidityPool, 0, len(pr.pools))
 	for _, pool := range pr.pools {
-		pools = append(pools, pool)
+		copied := *pool
+		pools = append(pools, &copied)
 	}
 	return pools
 }
@@ -118,17 +163,117 @@ func (pr *PoolRegistry) FindPoolByTokens(tokenA, tokenB string) (*LiquidityPool,
 	for _, pool := range pr.pools {
 		if (pool.TokenA == tokenA && pool.TokenB == tokenB) ||
 			(pool.TokenA == tokenB && pool.TokenB == tokenA) {
-			return pool, nil
+			copied := *pool
+			return &copied, nil
 		}
 	}
 
 	return nil, fmt.Errorf("no pool found for token pair %s/%s", tokenA, tokenB)
 }
 
-
 // GetPoolCount returns the number of registered pools
 func (pr *PoolRegistry) GetPoolCount() int {
 	pr.mutex.RLock()
 	defer pr.mutex.RUnlock()
 	return len(pr.pools)
 }
+
+// persistLocked writes a pool and its LP token to the store
+// Must be called with mutex held
+func (pr *PoolRegistry) persistLocked(pool *LiquidityPool) error {
+	if pr.store == nil {
+		return nil
+	}
+	record := &storedPool{Pool: pool}
+	if lpToken, exists := pr.tokens.GetToken(pool.LPTokenID); exists {
+		record.LPToken = lpToken
+	}
+	if err := pr.store.savePool(record); err != nil {
+		return fmt.Errorf("failed to persist pool %s: %w", pool.PoolID, err)
+	}
+	return nil
+}
+
+// Load replaces the registry's pools with those in the store and restores their LP tokens
+// to the token registry
+func (pr *PoolRegistry) Load() error {
+	records, err := pr.store.loadPools()
+	if err != nil {
+		return err
+	}
+
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	tokenRegistry := pr.tokens
+	pr.pools = make(map[string]*LiquidityPool, len(records))
+	for _, record := range records {
+		pr.pools[record.Pool.PoolID] = record.Pool
+
+		// LP supply follows the pool; restored as stored, not validated like a new token
+		if lpToken := record.LPToken; lpToken != nil {
+			if existing, exists := tokenRegistry.Tokens[lpToken.TokenID]; exists {
+				lpToken = existing
+			}
+			lpToken.TotalSupply = record.Pool.LPTokenSupply
+			lpToken.LockedShadow = record.Pool.LPTokenSupply
+			tokenRegistry.Tokens[lpToken.TokenID] = lpToken
+		}
+	}
+
+	fmt.Printf("[PoolRegistry] Loaded %d pools from storage\n", len(pr.pools))
+	return nil
+}
+
+// savePool writes a pool record
+func (store *UTXOStore) savePool(record *storedPool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.db.Set([]byte(PoolPrefix+record.Pool.PoolID), data)
+}
+
+// loadPools reads every stored pool record
+func (store *UTXOStore) loadPools() ([]*storedPool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(PoolPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var records []*storedPool
+	for ; iterator.Valid(); iterator.Next() {
+		var record storedPool
+		if err := json.Unmarshal(iterator.Value(), &record); err != nil || record.Pool == nil {
+			return nil, fmt.Errorf("corrupt pool record %s", iterator.Key())
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// HasPoolIndex reports whether the store holds every pool, so the registry can be loaded
+// instead of rebuilt from the chain
+func (store *UTXOStore) HasPoolIndex() bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(poolIndexVersionKey))
+	return err == nil && string(data) == poolIndexVersion
+}
+
+// markPoolIndex records that the stored pools are complete
+func (store *UTXOStore) markPoolIndex() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(poolIndexVersionKey), []byte(poolIndexVersion)); err != nil {
+		return fmt.Errorf("failed to store pool index version: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPoolRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	poolID := strings.Repeat("cd", 32)
+	lpToken := &TokenInfo{
+		TokenID:      poolID,
+		Ticker:       "LPTEST",
+		Desc:         "TestLiquidityPool",
+		MaxMint:      10,
+		MaxDecimals:  8,
+		TotalSupply:  10_0000_0000,
+		LockedShadow: 10_0000_0000,
+		CreationTime: 1,
+	}
+	if err := bc.TokenRegistry().RegisterToken(lpToken); err != nil {
+		t.Fatalf("Failed to register LP token: %v", err)
+	}
+
+	pools := bc.GetPoolRegistry()
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: strings.Repeat("ab", 32), TokenB: GetGenesisToken().TokenID,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 10_0000_0000, FeePercent: 30,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+
+	// Pools are handed out as copies; only UpdatePool changes the registry
+	pool, _ := pools.GetPool(poolID)
+	pool.ReserveA = 1
+	if stored, _ := pools.GetPool(poolID); stored.ReserveA != 1000 {
+		t.Fatal("Changing a returned pool should not change the registry")
+	}
+	pool.ReserveA, pool.ReserveB, pool.LPTokenSupply = 1500, 3000, 15_0000_0000
+	if err := pools.UpdatePool(pool); err != nil {
+		t.Fatalf("Failed to update pool: %v", err)
+	}
+	bc.Close()
+
+	// Reopening loads the latest reserves and restores the LP token without a chain scan
+	bc, err = NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen chain: %v", err)
+	}
+	defer bc.Close()
+
+	loaded, err := bc.GetPoolRegistry().GetPool(poolID)
+	if err != nil {
+		t.Fatalf("Pool not loaded: %v", err)
+	}
+	if loaded.ReserveA != 1500 || loaded.ReserveB != 3000 || loaded.LPTokenSupply != 15_0000_0000 {
+		t.Errorf("Expected updated reserves 1500/3000, got %d/%d", loaded.ReserveA, loaded.ReserveB)
+	}
+	restored, exists := bc.TokenRegistry().GetToken(poolID)
+	if !exists || restored.Ticker != "LPTEST" || restored.TotalSupply != 15_0000_0000 {
+		t.Errorf("LP token not restored with the pool's supply: %+v", restored)
+	}
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Pool creation is limited so pool lists aren't filled with dust pools. A new pool's
+// deposits must be worth at least MinLiquidity SHADOW, and its creation transaction must
+// burn CreationFee SHADOW. A fee paid to the block winner would cost a farming creator
+// nothing, so it is burned. Validators check both when accepting transactions and blocks.
+
+const (
+	DefaultMinPoolLiquidity = 10_0000_0000 // 10 SHADOW
+	DefaultPoolCreationFee  = 1_0000_0000  // 1 SHADOW
+)
+
+// PoolRules limits pool creation. Zero values disable a limit.
+type PoolRules struct {
+	MinLiquidity uint64 `json:"min_liquidity"` // Minimum SHADOW value of a new pool's deposits, base units
+	CreationFee  uint64 `json:"creation_fee"`  // SHADOW burned by each pool creation, base units
+}
+
+// DefaultPoolRules returns the pool rules of networks whose genesis doesn't set them
+func DefaultPoolRules() PoolRules {
+	return PoolRules{MinLiquidity: DefaultMinPoolLiquidity, CreationFee: DefaultPoolCreationFee}
+}
+
+// PoolCreationRules returns the network's pool rules
+func (g *ChainGenesis) PoolCreationRules() PoolRules {
+	if g.PoolRules == nil {
+		return DefaultPoolRules()
+	}
+	return *g.PoolRules
+}
+
+// shadowValue prices amount of tokenID in SHADOW through the token's SHADOW pool
+func shadowValue(tokenID string, amount uint64, poolRegistry *PoolRegistry) (uint64, bool) {
+	if tokenID == GetGenesisToken().TokenID {
+		return amount, true
+	}
+	if poolRegistry == nil {
+		return 0, false
+	}
+	pool, reserveToken, reserveShadow := tokenFeePool(tokenID, poolRegistry)
+	if pool == nil || reserveToken == 0 {
+		return 0, false
+	}
+	return mulDiv(amount, reserveShadow, reserveToken), true
+}
+
+// PoolLiquidityValue values a new pool's deposits in SHADOW. Both sides are worth the
+// same at the pool's own price, so this is twice the side that can be priced: SHADOW
+// itself, or a token with a SHADOW pool. When both can, the lower is used, so an
+// overpriced side can't inflate the value. Pools of two unpriced tokens are worth 0.
+func PoolLiquidityValue(poolData *CreatePoolData, poolRegistry *PoolRegistry) uint64 {
+	valueA, pricedA := shadowValue(poolData.TokenA, poolData.AmountA, poolRegistry)
+	valueB, pricedB := shadowValue(poolData.TokenB, poolData.AmountB, poolRegistry)
+
+	var side uint64
+	switch {
+	case pricedA && pricedB:
+		side = min(valueA, valueB)
+	case pricedA:
+		side = valueA
+	case pricedB:
+		side = valueB
+	}
+	if side > ^uint64(0)/2 {
+		return ^uint64(0)
+	}
+	return side * 2
+}
+
+// CheckPoolCreation checks a pool creation against the network's pool rules, pricing
+// its deposits with the pools in poolRegistry. Other transactions pass.
+func CheckPoolCreation(tx *Transaction, poolRegistry *PoolRegistry) error {
+	if tx.TxType != TxTypeCreatePool {
+		return nil
+	}
+	rules := ActiveGenesis().PoolCreationRules()
+
+	var poolData CreatePoolData
+	if err := json.Unmarshal(tx.Data, &poolData); err != nil {
+		return fmt.Errorf("failed to parse pool data: %w", err)
+	}
+	if value := PoolLiquidityValue(&poolData, poolRegistry); value < rules.MinLiquidity {
+		return fmt.Errorf("pool liquidity is worth %s SHADOW, minimum is %s (pair with SHADOW or a token with a SHADOW pool)",
+			FormatAmount(value), FormatAmount(rules.MinLiquidity))
+	}
+
+	var burned uint64
+	for _, output := range tx.Outputs {
+		if output.IsBurn() {
+			burned += output.Amount
+		}
+	}
+	if burned < rules.CreationFee {
+		return fmt.Errorf("pool creation must burn %s SHADOW, burns %s",
+			FormatAmount(rules.CreationFee), FormatAmount(burned))
+	}
+	return nil
+}
+
+// ValidatePoolCreations rejects a block with a pool creation breaking the pool rules
+func (bc *Blockchain) ValidatePoolCreations(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, func(tx *Transaction, height uint64, lookup func(string, uint32) *TxOutput) error {
+		return CheckPoolCreation(tx, bc.poolRegistry)
+	})
+}
+
+// checkPoolCreation checks a pool creation against the pools as of the next block
+func (mp *Mempool) checkPoolCreation(tx *Transaction) error {
+	mp.txLock.RLock()
+	poolRegistry := mp.poolRegistry
+	mp.txLock.RUnlock()
+
+	if poolRegistry == nil {
+		return nil
+	}
+	return CheckPoolCreation(tx, poolRegistry)
+}
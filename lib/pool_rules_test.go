@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPoolCreationRules(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	shadow := GetGenesisToken().TokenID
+	tokenX := strings.Repeat("ab", 32)
+	tokenY := strings.Repeat("cd", 32)
+	pools := bc.GetPoolRegistry()
+
+	// X trades at 2 SHADOW
+	poolID := strings.Repeat("fa", 32)
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: tokenX, TokenB: shadow,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		data CreatePoolData
+		want uint64
+	}{
+		{"shadow pair", CreatePoolData{TokenA: tokenY, TokenB: shadow, AmountA: 7, AmountB: 50}, 100},
+		{"priced token", CreatePoolData{TokenA: tokenX, TokenB: tokenY, AmountA: 30, AmountB: 1}, 120},
+		{"lower side of two", CreatePoolData{TokenA: tokenX, TokenB: shadow, AmountA: 30, AmountB: 50}, 100},
+		{"unpriced tokens", CreatePoolData{TokenA: tokenY, TokenB: strings.Repeat("ef", 32), AmountA: 1e9, AmountB: 1e9}, 0},
+	}
+	for _, c := range cases {
+		if got := PoolLiquidityValue(&c.data, pools); got != c.want {
+			t.Errorf("%s: expected value %d, got %d", c.name, c.want, got)
+		}
+	}
+
+	poolTx := func(shadowAmount uint64, outputs ...*TxOutput) *Transaction {
+		data, _ := json.Marshal(CreatePoolData{TokenA: tokenY, TokenB: shadow, AmountA: 1, AmountB: shadowAmount})
+		builder := NewTxBuilder(TxTypeCreatePool).SetData(data)
+		for _, output := range outputs {
+			builder.AddCustomOutput(output)
+		}
+		return builder.Build()
+	}
+	fee := CreateBurnOutput(DefaultPoolCreationFee, shadow)
+
+	if err := CheckPoolCreation(poolTx(DefaultMinPoolLiquidity/2-1, fee), pools); err == nil || !strings.Contains(err.Error(), "minimum") {
+		t.Errorf("Expected too little liquidity to be rejected, got %v", err)
+	}
+	if err := CheckPoolCreation(poolTx(DefaultMinPoolLiquidity/2), pools); err == nil || !strings.Contains(err.Error(), "burn") {
+		t.Errorf("Expected a missing creation fee to be rejected, got %v", err)
+	}
+	if err := CheckPoolCreation(poolTx(DefaultMinPoolLiquidity/2, fee), pools); err != nil {
+		t.Errorf("Expected a valid pool creation to pass, got %v", err)
+	}
+}
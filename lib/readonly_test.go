@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyNode(t *testing.T) {
+	n := &P2PBlockchainNode{readOnly: true, idempotency: NewIdempotencyCache(IdempotencyWindow)}
+	called := false
+	handler := n.requireSpender(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	// Writes are refused even with no API key configured
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/tx/submit", strings.NewReader("{}")))
+	if rec.Code != http.StatusForbidden || called {
+		t.Errorf("Expected 403 without running the handler, got %d", rec.Code)
+	}
+
+	// Queries must name an address, there is no node wallet to default to
+	rec = httptest.NewRecorder()
+	if _, ok := n.addressParam(rec, httptest.NewRequest(http.MethodGet, "/api/balance", nil)); ok || rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without an address, got %d", rec.Code)
+	}
+	addr, ok := n.addressParam(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/balance?address=S42", nil))
+	if !ok || addr != "S42" {
+		t.Errorf("Expected the given address, got %q", addr)
+	}
+}
@@ -0,0 +1,236 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+const (
+	ReindexBatchSize      = 500   // Blocks applied per database transaction during reindex
+	ReindexProgressBlocks = 5000  // Blocks between progress lines
+	resetDeleteChunk      = 10000 // Keys deleted per database transaction when wiping state
+	maxReportedMismatches = 10    // Consistency problems printed before giving up
+)
+
+// derivedStatePrefixes are UTXO store keys computed from blocks. Transactions (tx:) are
+// kept because blocks reference them by ID; validator registrations come from the network.
+var derivedStatePrefixes = []string{
+	UTXOPrefix, AddressPrefix, HeightPrefix, SpentPrefix, SpentAtPrefix,
+	AddrTxPrefix, AddrTxIndexCount, MemoPrefix, TokenTxPrefix, BalancePrefix, SupplyPrefix, OfferLockPrefix, AddrTokenPrefix, TokenUTXOPrefix,
+	PoolPrefix, LPFeeGrowthPrefix, PoolOraclePrefix, OrderBookPrefix, TxStatusPrefix, TxEventPrefix, TxConfirmPrefix, HTLCPrefix,
+	TokenPrefix, balanceIndexVersionKey, poolIndexVersionKey, tokenIndexVersionKey, PruneHorizonKey, AppliedHeightKey,
+	StateAccumulatorKey, StateHashPrefix,
+}
+
+// ResetDerivedState deletes every UTXO, index, and counter key, leaving stored
+// transactions in place. Returns the number of keys removed.
+func (store *UTXOStore) ResetDerivedState() (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	removed := 0
+	for _, prefix := range derivedStatePrefixes {
+		// Delete in chunks - bolt can't write while a read cursor is open
+		for {
+			iterator, err := store.db.Iterator([]byte(prefix), nil)
+			if err != nil {
+				return removed, fmt.Errorf("failed to create iterator: %w", err)
+			}
+			var keys [][]byte
+			for ; iterator.Valid() && len(keys) < resetDeleteChunk; iterator.Next() {
+				keys = append(keys, append([]byte(nil), iterator.Key()...))
+			}
+			iterator.Close()
+
+			if len(keys) == 0 {
+				break
+			}
+			if err := store.db.DeleteBatch(keys); err != nil {
+				return removed, fmt.Errorf("failed to delete %s keys: %w", prefix, err)
+			}
+			removed += len(keys)
+		}
+	}
+
+	store.cache.Clear()
+	if store.memory != nil {
+		store.memory.replace(make(map[string]*UTXO))
+	}
+	return removed, nil
+}
+
+// CheckConsistency recomputes balances, supply and the state accumulator from the UTXO
+// set and compares them with the balance index, and checks every unspent output is in
+// the address index
+func (store *UTXOStore) CheckConsistency() error {
+	mismatches, err := store.indexMismatches()
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		for i, m := range mismatches {
+			if i == maxReportedMismatches {
+				break
+			}
+			fmt.Printf("[Reindex] ❌ %s\n", m)
+		}
+		return fmt.Errorf("%d index mismatches (first: %s)", len(mismatches), mismatches[0])
+	}
+	return nil
+}
+
+// indexMismatches lists every disagreement between the UTXO set and the address and
+// balance indices and the state accumulator
+func (store *UTXOStore) indexMismatches() ([]string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	expected := make(map[string]uint64)
+	acc := new(big.Int)
+	var outpoints, addrKeys []string
+
+	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	for ; iterator.Valid(); iterator.Next() {
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil {
+			iterator.Close()
+			return nil, fmt.Errorf("corrupt UTXO record %s: %w", iterator.Key(), err)
+		}
+		if utxo.IsSpent || utxo.Output == nil {
+			continue
+		}
+		out := utxo.Output
+		addrStr := out.Address.String()
+		expected[fmt.Sprintf("%s%s:%s", BalancePrefix, addrStr, out.TokenID)] += out.Amount
+		expected[SupplyPrefix+out.TokenID] += out.Amount
+		outpoints = append(outpoints, fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex))
+		addrKeys = append(addrKeys, fmt.Sprintf("%s%s:%s:%d", AddressPrefix, addrStr, utxo.TxID, utxo.OutputIndex))
+		if err := accumulateState(acc, &utxo, true); err != nil {
+			iterator.Close()
+			return nil, err
+		}
+	}
+	iterator.Close()
+
+	var mismatches []string
+	stored, err := store.readStateAccumulator()
+	if err != nil {
+		return nil, err
+	}
+	if stored.Cmp(acc) != 0 {
+		mismatches = append(mismatches, fmt.Sprintf("state accumulator is %x, UTXO set says %x", stored, acc))
+	}
+	for i, key := range addrKeys {
+		if data, err := store.db.Get([]byte(key)); err != nil || data == nil {
+			mismatches = append(mismatches, "missing address index for "+outpoints[i])
+		}
+	}
+
+	actual := make(map[string]uint64)
+	for _, prefix := range []string{BalancePrefix, SupplyPrefix} {
+		iterator, err := store.db.Iterator([]byte(prefix), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create iterator: %w", err)
+		}
+		for ; iterator.Valid(); iterator.Next() {
+			amount, _ := strconv.ParseUint(string(iterator.Value()), 10, 64)
+			actual[string(iterator.Key())] = amount
+		}
+		iterator.Close()
+	}
+
+	for key, want := range expected {
+		if got := actual[key]; got != want {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %d, UTXO set says %d", key, got, want))
+		}
+	}
+	for key, got := range actual {
+		if _, ok := expected[key]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is %d, UTXO set says 0", key, got))
+		}
+	}
+
+	return mismatches, nil
+}
+
+// Reindex wipes the UTXO store's derived state and the token and pool registries, then
+// rebuilds them deterministically by replaying every stored block. Must run before the
+// node starts consensus or serves requests.
+func (bc *Blockchain) Reindex() error {
+	bc.chainLock.Lock()
+	defer bc.chainLock.Unlock()
+
+	if horizon := bc.utxoStore.TxPruneHorizon(); horizon > 0 {
+		return fmt.Errorf("transaction bodies below height %d have been pruned, so the chain can't be replayed", horizon)
+	}
+
+	started := time.Now()
+	total := len(bc.blocks)
+
+	fmt.Printf("[Reindex] Wiping UTXO, token, and pool state...\n")
+	removed, err := bc.utxoStore.ResetDerivedState()
+	if err != nil {
+		return fmt.Errorf("failed to reset UTXO store: %w", err)
+	}
+	bc.tokenRegistry = NewPersistentTokenRegistry(bc.utxoStore)
+	bc.poolRegistry = NewPersistentPoolRegistry(bc.utxoStore, bc.tokenRegistry)
+	fmt.Printf("[Reindex] Removed %d keys, replaying %d blocks\n", removed, total)
+
+	for start := 0; start < total; start += ReindexBatchSize {
+		end := start + ReindexBatchSize
+		if end > total {
+			end = total
+		}
+
+		if err := bc.utxoStore.BeginBatch(); err != nil {
+			return fmt.Errorf("failed to start UTXO batch: %w", err)
+		}
+		for _, block := range bc.blocks[start:end] {
+			if err := bc.applyBlockState(block, nil); err != nil {
+				bc.utxoStore.DiscardBatch()
+				return fmt.Errorf("failed to replay block %d: %w", block.Index, err)
+			}
+		}
+		if err := bc.utxoStore.CommitBatch(); err != nil {
+			return fmt.Errorf("failed to commit UTXO batch: %w", err)
+		}
+
+		if end%ReindexProgressBlocks < ReindexBatchSize || end == total {
+			elapsed := time.Since(started).Seconds()
+			rate := 0.0
+			if elapsed > 0 {
+				rate = float64(end) / elapsed
+			}
+			fmt.Printf("[Reindex] %d/%d blocks (%.1f%%), %.0f blocks/sec\n",
+				end, total, float64(end)*100/float64(total), rate)
+		}
+	}
+
+	// The index and stored tokens and pools are current as of the replay
+	if err := bc.utxoStore.db.Set([]byte(balanceIndexVersionKey), []byte(balanceIndexVersion)); err != nil {
+		return fmt.Errorf("failed to store balance index version: %w", err)
+	}
+	if err := bc.utxoStore.markPoolIndex(); err != nil {
+		return err
+	}
+	if err := bc.utxoStore.markTokenIndex(); err != nil {
+		return err
+	}
+
+	fmt.Printf("[Reindex] Checking consistency...\n")
+	if err := bc.utxoStore.CheckConsistency(); err != nil {
+		return fmt.Errorf("consistency check failed after reindex: %w", err)
+	}
+
+	utxos, _ := bc.utxoStore.GetTotalUTXOs()
+	fmt.Printf("[Reindex] ✅ Rebuilt %d blocks in %s: %d UTXO records, %d tokens, %d pools\n",
+		total, time.Since(started).Round(time.Second), utxos,
+		bc.tokenRegistry.GetTokenCount(), bc.poolRegistry.GetPoolCount())
+	return nil
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReindexRebuildsState(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	shadowID := GetGenesisToken().TokenID
+
+	addBlock := func(coinbase *Transaction, txIDs []string) {
+		prev := bc.GetLatestBlock()
+		block := &Block{
+			Index:        prev.Index + 1,
+			Timestamp:    prev.Timestamp + 1,
+			Transactions: txIDs,
+			Coinbase:     coinbase,
+			PreviousHash: prev.Hash,
+			Proposer:     "reindex-test-proposer",
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		if err := bc.AddBlock(block, nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	// Alice mines 100, then sends 60 to bob and 40 back to herself
+	coinbase := &Transaction{
+		Version: 1,
+		TxType:  TxTypeCoinbase,
+		Outputs: []*TxOutput{CreateShadowOutput(alice.Address(), 100)},
+	}
+	addBlock(coinbase, nil)
+	coinbaseID, _ := coinbase.ID()
+
+	send := &Transaction{
+		Version:   1,
+		TxType:    TxTypeSend,
+		Inputs:    []*TxInput{{PrevTxID: coinbaseID, OutputIndex: 0}},
+		Outputs:   []*TxOutput{CreateShadowOutput(bob.Address(), 60), CreateShadowOutput(alice.Address(), 40)},
+		Timestamp: 1,
+	}
+	send.Sign(alice)
+	if err := bc.GetUTXOStore().StoreTransaction(send, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	sendID, _ := send.ID()
+	addBlock(nil, []string{sendID})
+
+	store := bc.GetUTXOStore()
+	if err := store.CheckConsistency(); err != nil {
+		t.Fatalf("Fresh chain should be consistent: %v", err)
+	}
+
+	// Corrupt the indices
+	if err := store.db.Set([]byte(SupplyPrefix+shadowID), []byte("999")); err != nil {
+		t.Fatalf("Failed to corrupt supply: %v", err)
+	}
+	if err := store.db.Delete([]byte(BalancePrefix + bob.Address().String() + ":" + shadowID)); err != nil {
+		t.Fatalf("Failed to corrupt balance: %v", err)
+	}
+	if err := store.CheckConsistency(); err == nil {
+		t.Fatal("Expected corrupted indices to fail the consistency check")
+	}
+
+	if err := bc.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	if supply, _ := store.CirculatingSupply(shadowID); supply != 100 {
+		t.Fatalf("Expected supply 100 after reindex, got %d", supply)
+	}
+	if bal, _ := store.GetIndexedBalance(bob.Address(), shadowID); bal != 60 {
+		t.Fatalf("Expected bob balance 60 after reindex, got %d", bal)
+	}
+	if utxo, _ := store.GetUTXO(coinbaseID, 0); utxo == nil || !utxo.IsSpent {
+		t.Fatal("Expected coinbase output to be spent after reindex")
+	}
+	if tx, _ := store.GetTransaction(sendID); tx == nil {
+		t.Fatal("Reindex must keep stored transactions")
+	}
+}
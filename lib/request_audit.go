@@ -0,0 +1,271 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Every call to a protected (write) endpoint is appended to the request audit log, one
+// JSON object per line: when, which endpoint, who authorized it, what was asked, how it
+// ended and the transaction it produced. Exchanges running a node need this trail for
+// compliance. API keys are identified by a fingerprint, never the key itself, and
+// request fields that look like secrets are redacted. The file is rotated by size, and
+// only the last few rotations are kept.
+
+const (
+	DefaultRequestAuditPath = "request_audit.log" // Under the data directory
+	MaxRequestAuditBytes    = 10 << 20            // Size at which the log is rotated
+	RequestAuditBackups     = 5                   // Rotated logs kept (request_audit.log.1 is the newest)
+	maxAuditBodyPeek        = 64 << 10            // Request body bytes read for the summary
+	maxAuditSummary         = 512                 // Longest recorded request summary
+	maxAuditValue           = 64                  // Longest recorded string field
+)
+
+// How a request to a protected endpoint was authorized
+const (
+	AuditAuthNone      = "none"      // No API key or signers configured
+	AuditAuthAPIKey    = "api_key"   // X-API-Key header
+	AuditAuthSignature = "signature" // Signed request
+)
+
+// RequestAuditEntry is one line of the request audit log
+type RequestAuditEntry struct {
+	Timestamp  int64  `json:"timestamp"`
+	Method     string `json:"method"`
+	Endpoint   string `json:"endpoint"`
+	Remote     string `json:"remote"`
+	Auth       string `json:"auth"`             // AuditAuth* (empty when refused before authorization)
+	KeyID      string `json:"key_id,omitempty"` // API key fingerprint, or the signer's address
+	Status     int    `json:"status"`
+	Summary    string `json:"summary,omitempty"` // Request fields, secrets redacted
+	TxID       string `json:"tx_id,omitempty"`   // Transaction the request produced
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// RequestAuditLog appends entries to a size-rotated file
+type RequestAuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// OpenRequestAuditLog opens (or creates) the audit log at path for appending
+func OpenRequestAuditLog(path string) (*RequestAuditLog, error) {
+	log := &RequestAuditLog{path: path}
+	if err := log.openLocked(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// openLocked opens the current file. Must be called with mu held (or before sharing).
+func (l *RequestAuditLog) openLocked() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open request audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat request audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Record appends an entry, rotating the file first if it would grow past MaxRequestAuditBytes
+func (l *RequestAuditLog) Record(entry *RequestAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("request audit log is closed")
+	}
+	if l.size > 0 && l.size+int64(len(line)) > MaxRequestAuditBytes {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotateLocked shifts request_audit.log.N up by one, dropping the oldest, and starts a
+// new file. Must be called with mu held.
+func (l *RequestAuditLog) rotateLocked() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", l.path, RequestAuditBackups))
+	for i := RequestAuditBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate request audit log: %w", err)
+	}
+	return l.openLocked()
+}
+
+// Close closes the file
+func (l *RequestAuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// APIKeyID is the fingerprint identifying an API key in the audit log: the first 8 bytes
+// of its SHA-256, which can't be used to recover the key
+func APIKeyID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:8])
+}
+
+// auditedRequest collects the audit entry of one protected request while it runs
+type auditedRequest struct {
+	log   *RequestAuditLog
+	entry RequestAuditEntry
+	rec   *responseRecorder
+	start time.Time
+	body  []byte // First maxAuditBodyPeek bytes of the request body
+	whole bool   // body is the whole request body
+}
+
+// auditRequest starts auditing r. The handler must write its response to the returned
+// recorder and call record when done. The body is peeked at and put back for the handler.
+func (n *P2PBlockchainNode) auditRequest(w http.ResponseWriter, r *http.Request) *auditedRequest {
+	a := &auditedRequest{
+		log:   n.requestAudit,
+		rec:   &responseRecorder{ResponseWriter: w},
+		start: time.Now(),
+		entry: RequestAuditEntry{
+			Timestamp: time.Now().Unix(),
+			Method:    r.Method,
+			Endpoint:  r.URL.Path,
+			Remote:    r.RemoteAddr,
+		},
+	}
+	if a.log != nil && r.Body != nil {
+		a.body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditBodyPeek+1))
+		a.whole = len(a.body) <= maxAuditBodyPeek
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(a.body), r.Body), r.Body}
+		if !a.whole {
+			a.body = a.body[:maxAuditBodyPeek]
+		}
+	}
+	return a
+}
+
+// authorized notes how the request was authorized
+func (a *auditedRequest) authorized(auth, keyID string) {
+	a.entry.Auth, a.entry.KeyID = auth, keyID
+}
+
+// record writes the entry once the response is complete
+func (a *auditedRequest) record() {
+	if a.log == nil {
+		return
+	}
+	a.entry.Status = a.rec.status
+	if a.entry.Status == 0 {
+		a.entry.Status = http.StatusOK
+	}
+	a.entry.Summary = summarizeRequestBody(a.body, a.whole)
+	if a.entry.Status < http.StatusBadRequest {
+		a.entry.TxID = responseTxID(a.rec.body.Bytes())
+	}
+	a.entry.DurationMs = time.Since(a.start).Milliseconds()
+	if err := a.log.Record(&a.entry); err != nil {
+		fmt.Printf("[API] ⚠️  Failed to write request audit log: %v\n", err)
+	}
+}
+
+// summarizeRequestBody lists the top-level fields of a JSON request body, sorted, with
+// long strings cut short, nested values reduced to their size and secrets redacted
+func summarizeRequestBody(body []byte, whole bool) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var fields map[string]json.RawMessage
+	if !whole || json.Unmarshal(body, &fields) != nil {
+		return fmt.Sprintf("%d bytes", len(body))
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+summarizeField(name, fields[name]))
+	}
+	summary := strings.Join(parts, " ")
+	if len(summary) > maxAuditSummary {
+		summary = summary[:maxAuditSummary] + "…"
+	}
+	return summary
+}
+
+// summarizeField renders one request field for the audit log
+func summarizeField(name string, raw json.RawMessage) string {
+	lower := strings.ToLower(name)
+	for _, secret := range []string{"password", "passphrase", "secret", "private", "mnemonic", "api_key"} {
+		if strings.Contains(lower, secret) {
+			return "[redacted]"
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "?"
+	}
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxAuditValue {
+			v = v[:maxAuditValue] + "…"
+		}
+		return fmt.Sprintf("%q", v)
+	case []interface{}:
+		return fmt.Sprintf("[%d]", len(v))
+	case map[string]interface{}:
+		return fmt.Sprintf("{%d}", len(v))
+	default:
+		return string(raw)
+	}
+}
+
+// responseTxID returns the tx_id field of a JSON response, if any
+func responseTxID(body []byte) string {
+	var resp struct {
+		TxID string `json:"tx_id"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return ""
+	}
+	return resp.TxID
+}
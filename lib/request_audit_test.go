@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultRequestAuditPath)
+	audit, err := OpenRequestAuditLog(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer audit.Close()
+
+	n := &P2PBlockchainNode{apiKeys: apiKeyring{current: "audit-key-0123456789"}, idempotency: NewIdempotencyCache(IdempotencyWindow), requestAudit: audit}
+	handler := n.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req["to"] == nil {
+			http.Error(w, "Handler did not get the body", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tx_id": "audit-test-tx"})
+	})
+	call := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/tx/send",
+			strings.NewReader(`{"to": "S123", "amount": 5, "wallet_password": "hunter2", "outputs": [1, 2]}`))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+	if call("wrong-key") != http.StatusUnauthorized || call("audit-key-0123456789") != http.StatusOK {
+		t.Fatal("Expected the wrong key refused and the right one accepted")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	defer file.Close()
+	var entries []RequestAuditEntry
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var entry RequestAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+	if refused := entries[0]; refused.Status != http.StatusUnauthorized || refused.Auth != "" || refused.TxID != "" {
+		t.Errorf("Unexpected entry for the refused call: %+v", refused)
+	}
+	ok := entries[1]
+	if ok.Status != http.StatusOK || ok.Auth != AuditAuthAPIKey || ok.KeyID != APIKeyID("audit-key-0123456789") ||
+		ok.Endpoint != "/api/tx/send" || ok.TxID != "audit-test-tx" {
+		t.Errorf("Unexpected entry for the accepted call: %+v", ok)
+	}
+	if want := `amount=5 outputs=[2] to="S123" wallet_password=[redacted]`; ok.Summary != want {
+		t.Errorf("Expected summary %q, got %q", want, ok.Summary)
+	}
+	if strings.Contains(ok.KeyID, "audit-key") {
+		t.Error("Expected the API key itself to stay out of the log")
+	}
+
+	// A full log is rotated before the next entry
+	audit.size = MaxRequestAuditBytes
+	if err := audit.Record(&RequestAuditEntry{Endpoint: "/api/admin/snapshot"}); err != nil {
+		t.Fatalf("Failed to record after rotation: %v", err)
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() == 0 {
+		t.Fatalf("Expected the old log at %s.1: %v", path, err)
+	}
+	if info, _ := os.Stat(path); info.Size() != audit.size {
+		t.Errorf("Expected a new log holding one entry, got %d bytes", info.Size())
+	}
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedRequests(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	body := `{"to_address":"S42","amount":5}`
+	signed := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/tx/send", strings.NewReader(body))
+		if err := SignRequest(req, []byte(body), NewLocalSigner(kp)); err != nil {
+			t.Fatalf("Failed to sign request: %v", err)
+		}
+		return req
+	}
+
+	verifier := NewRequestVerifier()
+	req := signed()
+	signer, err := verifier.Verify(httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("Valid signed request rejected: %v", err)
+	}
+	if signer != kp.Address() {
+		t.Error("Signed request should be authorized as the signing key's address")
+	}
+
+	// Replaying the same request is rejected by its nonce
+	replay := httptest.NewRequest(http.MethodPost, "/api/tx/send", strings.NewReader(body))
+	replay.Header = req.Header.Clone()
+	if _, err := verifier.Verify(httptest.NewRecorder(), replay); err == nil {
+		t.Error("Replayed request should be rejected")
+	}
+
+	// The signature covers the body and path
+	tampered := signed()
+	tampered.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to_address":"S42","amount":500}`)).Body
+	if _, err := verifier.Verify(httptest.NewRecorder(), tampered); err == nil {
+		t.Error("Request with a modified body should be rejected")
+	}
+	moved := signed()
+	moved.URL.Path = "/api/token/burn"
+	if _, err := verifier.Verify(httptest.NewRecorder(), moved); err == nil {
+		t.Error("Request sent to a different endpoint should be rejected")
+	}
+
+	requery := httptest.NewRequest(http.MethodDelete, "/api/addressbook?label=savings", nil)
+	if err := SignRequest(requery, nil, NewLocalSigner(kp)); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	requery.URL.RawQuery = "label=payroll"
+	if _, err := verifier.Verify(httptest.NewRecorder(), requery); err == nil {
+		t.Error("Request with a modified query string should be rejected")
+	}
+
+	oversized := signed()
+	oversized.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", MaxSignedBody+1))).Body
+	if _, err := verifier.Verify(httptest.NewRecorder(), oversized); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("Oversized body should be refused before its signature is checked, got %v", err)
+	}
+
+	stale := signed()
+	stale.Header.Set(SignedRequestTimestampHeader, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err := verifier.Verify(httptest.NewRecorder(), stale); err == nil {
+		t.Error("Request with a stale timestamp should be rejected")
+	}
+
+	// Nonces are forgotten oldest first once their timestamps can no longer pass
+	expired := len(verifier.order)
+	for i := range verifier.order {
+		verifier.order[i].at = time.Now().Add(-3 * MaxRequestSkew)
+	}
+	if _, err := verifier.Verify(httptest.NewRecorder(), signed()); err != nil {
+		t.Fatalf("Valid signed request rejected: %v", err)
+	}
+	if expired == 0 || len(verifier.nonces) != 1 || len(verifier.order) != 1 {
+		t.Errorf("Expected %d expired nonces dropped, %d remain (%d queued)", expired, len(verifier.nonces), len(verifier.order))
+	}
+}
+
+func TestPackageSpendsOnlyFrom(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	owner, _ := GenerateKeyPair()
+	other, _ := GenerateKeyPair()
+	for i, addr := range []Address{owner.Address(), other.Address()} {
+		if err := store.AddUTXO(&UTXO{TxID: "package-auth-funding", OutputIndex: uint32(i), Output: CreateShadowOutput(addr, 1000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	parent := NewTxBuilder(TxTypeSend).AddInput("package-auth-funding", 0).AddOutput(owner.Address(), 900, "").Build()
+	parentID, _ := parent.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddOutput(other.Address(), 800, "").Build()
+	if !PackageSpendsOnlyFrom([]*Transaction{parent, child}, owner.Address(), store) {
+		t.Error("Expected a child spending its parent's output to the owner to count as owned")
+	}
+	theft := NewTxBuilder(TxTypeSend).AddInput("package-auth-funding", 1).AddOutput(owner.Address(), 900, "").Build()
+	if PackageSpendsOnlyFrom([]*Transaction{parent, theft}, owner.Address(), store) {
+		t.Error("Expected a package with a member spending another's outputs to be refused")
+	}
+	if PackageSpendsOnlyFrom([]*Transaction{child, parent}, owner.Address(), store) {
+		t.Error("Expected a child listed before its parent to be refused")
+	}
+}
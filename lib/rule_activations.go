@@ -0,0 +1,34 @@
+package lib
+
+// Consensus rules added after a network launched are enforced from an activation height,
+// so blocks accepted before the rule existed stay valid when a node resyncs. The built-in
+// network activates them at DefaultRuleActivationHeight; a custom network enforces them
+// from genesis unless its genesis sets a height.
+
+// DefaultRuleActivationHeight is where the built-in network starts enforcing rules that
+// postdate its launch
+const DefaultRuleActivationHeight = 1_600_000
+
+// RuleActivations are the heights from which later consensus rules are enforced
+type RuleActivations struct {
+	TxOrder     uint64 `json:"tx_order"`     // Transactions listed in canonical order
+	BlockWeight uint64 `json:"block_weight"` // Block weight limit and minimum fee per weight
+	Coinbase    uint64 `json:"coinbase"`     // Coinbase paying the proof winner at most reward plus fees
+	MedianTime  uint64 `json:"median_time"`  // Block stamped no earlier than the median time past
+}
+
+// RuleActivationHeights returns the network's rule activation heights
+func (g *ChainGenesis) RuleActivationHeights() RuleActivations {
+	if g.Activations != nil {
+		return *g.Activations
+	}
+	if g.IsDefault() {
+		return RuleActivations{
+			TxOrder:     DefaultRuleActivationHeight,
+			BlockWeight: DefaultRuleActivationHeight,
+			Coinbase:    DefaultRuleActivationHeight,
+			MedianTime:  DefaultRuleActivationHeight,
+		}
+	}
+	return RuleActivations{}
+}
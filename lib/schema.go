@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Stores used to write keys in whatever format the code of the day used, so changing a
+// format left older data dirs unreadable. Each store now records its schema version.
+// When the chain opens, a store runs the migrations above its version in order and saves
+// the version after each one, so an interrupted upgrade resumes where it stopped. A store
+// written by a newer node is refused rather than misread. A store without the key
+// predates versioning (version 0); a new, empty store starts at the latest version.
+//
+// Migrations are append-only: once released, a migration's version and effect never
+// change, and a format change ships with a new one at the end of its store's list.
+
+// SchemaVersionKey holds a store's schema version
+const SchemaVersionKey = "schemameta:version"
+
+// ErrSchemaTooNew is returned when a store was written by a newer node
+var ErrSchemaTooNew = errors.New("database schema is newer than this node supports")
+
+// Migration upgrades a store to Version
+type Migration struct {
+	Version     int
+	Description string
+	Run         func() error
+}
+
+// migrations returns the UTXO store's migrations, oldest first
+func (store *UTXOStore) migrations() []Migration {
+	return []Migration{
+		{Version: 1, Description: "backfill coinbase transaction records from UTXOs", Run: store.MigrateCoinbaseTransactions},
+		{Version: 2, Description: "index outputs spent before pruning by spent height", Run: store.MigrateSpentHeightIndex},
+	}
+}
+
+// migrations returns the block store's migrations, oldest first
+func (bs *BlockStore) migrations() []Migration {
+	return nil // Blocks are still stored as first written
+}
+
+// Migrate brings the UTXO store up to the latest schema version
+func (store *UTXOStore) Migrate() error {
+	return migrateSchema(store.db, "UTXO", store.migrations())
+}
+
+// Migrate brings the block store up to the latest schema version
+func (bs *BlockStore) Migrate() error {
+	return migrateSchema(bs.db, "block", bs.migrations())
+}
+
+// schemaVersion returns a store's schema version and whether it has one recorded
+func schemaVersion(db *BoltDBAdapter) (int, bool, error) {
+	data, err := db.Get([]byte(SchemaVersionKey))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if data == nil {
+		return 0, false, nil
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, false, fmt.Errorf("corrupt schema version %q", data)
+	}
+	return version, true, nil
+}
+
+// setSchemaVersion records a store's schema version
+func setSchemaVersion(db *BoltDBAdapter, version int) error {
+	if err := db.Set([]byte(SchemaVersionKey), []byte(strconv.Itoa(version))); err != nil {
+		return fmt.Errorf("failed to store schema version: %w", err)
+	}
+	return nil
+}
+
+// isEmptyStore reports whether a store holds no keys at all
+func isEmptyStore(db *BoltDBAdapter) (bool, error) {
+	iterator, err := db.Iterator(nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+	return !iterator.Valid(), nil
+}
+
+// migrateSchema runs the migrations above a store's schema version, in order
+func migrateSchema(db *BoltDBAdapter, name string, migrations []Migration) error {
+	latest := 0
+	for _, m := range migrations {
+		if m.Version <= latest {
+			return fmt.Errorf("%s store migration %d is out of order", name, m.Version)
+		}
+		latest = m.Version
+	}
+
+	version, versioned, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("%s store: %w", name, err)
+	}
+	if !versioned {
+		empty, err := isEmptyStore(db)
+		if err != nil {
+			return fmt.Errorf("%s store: %w", name, err)
+		}
+		if empty {
+			return setSchemaVersion(db, latest) // Nothing written in an older format
+		}
+	}
+	if version > latest {
+		return fmt.Errorf("%w: %s store is at schema version %d, this node knows up to %d (upgrade the node, or use a data dir it wrote)",
+			ErrSchemaTooNew, name, version, latest)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		fmt.Printf("[Chain] Migrating %s store to schema version %d: %s\n", name, m.Version, m.Description)
+		if err := m.Run(); err != nil {
+			return fmt.Errorf("%s store migration %d (%s) failed: %w", name, m.Version, m.Description, err)
+		}
+		if err := setSchemaVersion(db, m.Version); err != nil {
+			return err
+		}
+	}
+	if !versioned && latest == 0 {
+		return setSchemaVersion(db, 0)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateSchema(t *testing.T) {
+	db, err := NewBoltDBAdapter(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer db.Close()
+
+	var ran []int
+	failAt := 0
+	migrations := func() []Migration {
+		var ms []Migration
+		for _, v := range []int{1, 2, 3} {
+			v := v
+			ms = append(ms, Migration{Version: v, Description: "test", Run: func() error {
+				if v == failAt {
+					return errors.New("boom")
+				}
+				ran = append(ran, v)
+				return nil
+			}})
+		}
+		return ms
+	}
+	version := func() int {
+		v, ok, err := schemaVersion(db)
+		if err != nil || !ok {
+			t.Fatalf("Expected a recorded schema version, got %v (%v)", ok, err)
+		}
+		return v
+	}
+
+	// A new store starts at the latest version without migrating
+	if err := migrateSchema(db, "test", migrations()); err != nil {
+		t.Fatalf("Failed to migrate empty store: %v", err)
+	}
+	if len(ran) != 0 || version() != 3 {
+		t.Fatalf("Expected an empty store marked at version 3 with nothing run, got %v at %d", ran, version())
+	}
+
+	// Data from before versioning runs every migration, in order; a failure stops there
+	db.Delete([]byte(SchemaVersionKey))
+	db.Set([]byte("data"), []byte("old format"))
+	failAt = 3
+	if err := migrateSchema(db, "test", migrations()); err == nil {
+		t.Fatal("Expected the failed migration to be reported")
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 || version() != 2 {
+		t.Fatalf("Expected migrations 1 and 2 run and version 2, got %v at %d", ran, version())
+	}
+
+	// The next start resumes after the last migration that finished
+	failAt, ran = 0, nil
+	if err := migrateSchema(db, "test", migrations()); err != nil {
+		t.Fatalf("Failed to resume migrations: %v", err)
+	}
+	if len(ran) != 1 || ran[0] != 3 || version() != 3 {
+		t.Fatalf("Expected only migration 3 run, got %v at %d", ran, version())
+	}
+
+	// A store written by a newer node is refused and left alone
+	setSchemaVersion(db, 4)
+	if err := migrateSchema(db, "test", migrations()); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew, got %v", err)
+	}
+	if version() != 4 {
+		t.Errorf("Expected the newer version kept, got %d", version())
+	}
+
+	misordered := []Migration{{Version: 2}, {Version: 1}}
+	if err := migrateSchema(db, "test", misordered); err == nil {
+		t.Error("Expected out of order migrations to be refused")
+	}
+}
+
+func TestChainSchemaVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	utxoStore := bc.GetUTXOStore()
+	latest := utxoStore.migrations()[len(utxoStore.migrations())-1].Version
+	if v, ok, _ := schemaVersion(utxoStore.db); !ok || v != latest {
+		t.Errorf("Expected a new UTXO store at version %d, got %d (%v)", latest, v, ok)
+	}
+	if v, ok, _ := schemaVersion(bc.store.db); !ok || v != 0 {
+		t.Errorf("Expected a new block store at version 0, got %d (%v)", v, ok)
+	}
+
+	// An unversioned UTXO store gets its coinbase records backfilled once
+	kp, _ := GenerateKeyPair()
+	utxoStore.db.Delete([]byte(SchemaVersionKey))
+	if err := utxoStore.AddUTXO(&UTXO{TxID: "schema-test-coinbase", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 1_000), BlockHeight: 1}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	if err := utxoStore.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	tx, err := utxoStore.GetTransaction("schema-test-coinbase")
+	if err != nil || tx == nil || tx.TxType != TxTypeCoinbase {
+		t.Fatalf("Expected a backfilled coinbase record, got %+v (%v)", tx, err)
+	}
+
+	// Outputs spent before the spent-height index existed get indexed, so pruning finds them.
+	// Older nodes wrote spent:{txid}:{index} with an empty value, so each spend is dated by
+	// the block of its spending transaction, or the store's highest block if that's unknown.
+	legacySpend := func(txID string, height uint64) {
+		utxo := &UTXO{TxID: txID, OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 500), BlockHeight: height}
+		if err := utxoStore.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+		utxo.IsSpent = true
+		data, _ := json.Marshal(utxo)
+		utxoStore.db.Set([]byte(UTXOPrefix+txID+":0"), data)
+		utxoStore.db.Set([]byte(SpentPrefix+txID+":0"), []byte(""))
+	}
+	legacySpend("schema-test-spent", 2)
+	legacySpend("schema-test-orphan", 3)
+	spender := &Transaction{TxType: TxTypeSend, Inputs: []*TxInput{{PrevTxID: "schema-test-spent", OutputIndex: 0}}}
+	spenderData, _ := json.Marshal(spender)
+	utxoStore.db.Set([]byte(TxPrefix+"schema-test-spender"), spenderData)
+	if err := utxoStore.AddUTXO(&UTXO{TxID: "schema-test-spender", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 400), BlockHeight: 5}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	if err := utxoStore.AddUTXO(&UTXO{TxID: "schema-test-tip", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 1_000), BlockHeight: 20}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	setSchemaVersion(utxoStore.db, 1)
+	if err := utxoStore.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if spent, _ := utxoStore.db.Get([]byte(SpentPrefix + "schema-test-spent:0")); string(spent) != "5" {
+		t.Errorf("Expected the spend dated by its spending transaction's block 5, got %q", spent)
+	}
+	if spent, _ := utxoStore.db.Get([]byte(SpentPrefix + "schema-test-orphan:0")); string(spent) != "20" {
+		t.Errorf("Expected a spend without a known transaction dated at the store's tip 20, got %q", spent)
+	}
+	if pruned, err := utxoStore.PruneSpentUTXOs(10); err != nil || pruned != 1 {
+		t.Fatalf("Expected the output spent at block 5 to be pruned, got %d (%v)", pruned, err)
+	}
+	if pruned, err := utxoStore.PruneSpentUTXOs(21); err != nil || pruned != 1 {
+		t.Fatalf("Expected the output spent at the tip to be pruned after it, got %d (%v)", pruned, err)
+	}
+
+	// A data dir upgraded by a newer node is refused
+	setSchemaVersion(utxoStore.db, latest+1)
+	bc.Close()
+	if _, err := NewBlockchain(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected the newer UTXO store to be refused, got %v", err)
+	}
+}
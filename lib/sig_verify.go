@@ -0,0 +1,222 @@
+package lib
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ML-DSA87 verification dominates block validation. Verified signatures are remembered,
+// so a transaction checked on its way into the mempool is not checked again when its
+// block arrives, and the signatures of a block or sync batch are verified across all
+// CPUs before the block is applied. A signature is remembered by a hash of its signing
+// hash (which commits to the transaction and chain), public key and signature bytes, so
+// a cache hit needs neither the key parsed nor the signature checked.
+
+// DefaultSigCacheSize is the number of verified signatures remembered
+const DefaultSigCacheSize = 100000
+
+// sigCacheKey identifies one verified signature
+type sigCacheKey [32]byte
+
+// SigCache remembers verified signatures, dropping the oldest once full
+type SigCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[sigCacheKey]struct{}
+	order    []sigCacheKey // Ring of keys in insertion order
+	next     int
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// SigCacheStats reports signature cache effectiveness
+type SigCacheStats struct {
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+// NewSigCache creates a cache holding at most capacity signatures (<= 0 uses the default)
+func NewSigCache(capacity int) *SigCache {
+	if capacity <= 0 {
+		capacity = DefaultSigCacheSize
+	}
+	return &SigCache{
+		capacity: capacity,
+		entries:  make(map[sigCacheKey]struct{}, capacity),
+		order:    make([]sigCacheKey, 0, capacity),
+	}
+}
+
+// Contains reports whether the signature has been verified
+func (c *SigCache) Contains(key sigCacheKey) bool {
+	c.mu.Lock()
+	_, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return ok
+}
+
+// Add records a verified signature
+func (c *SigCache) Add(key sigCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.order) < c.capacity {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.entries, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % c.capacity
+	}
+	c.entries[key] = struct{}{}
+}
+
+// Stats returns the current cache statistics
+func (c *SigCache) Stats() SigCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return SigCacheStats{
+		Entries:  entries,
+		Capacity: c.capacity,
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+	}
+}
+
+// verifiedSignatures is shared by mempool admission and block validation
+var verifiedSignatures = NewSigCache(DefaultSigCacheSize)
+
+// GetSigCacheStats returns the signature cache statistics
+func GetSigCacheStats() SigCacheStats {
+	return verifiedSignatures.Stats()
+}
+
+// verifyCachedSignature verifies a signature over message by a serialized public key,
+// consulting and filling the signature cache. The key is only parsed on a miss.
+func verifyCachedSignature(message, signature, publicKey []byte) (bool, error) {
+	hasher, _ := blake2b.New256(nil)
+	hasher.Write(message)
+	hasher.Write(publicKey)
+	hasher.Write(signature)
+	var key sigCacheKey
+	copy(key[:], hasher.Sum(nil))
+
+	if verifiedSignatures.Contains(key) {
+		return true, nil
+	}
+	pk, err := PublicKeyFromBytes(publicKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid public key: %w", err)
+	}
+	if !VerifySignature(message, signature, pk) {
+		return false, nil
+	}
+	verifiedSignatures.Add(key)
+	return true, nil
+}
+
+// verifySignature checks the transaction signature against the transaction public key
+func (tx *Transaction) verifySignature() error {
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return fmt.Errorf("failed to compute transaction hash: %w", err)
+	}
+	valid, err := verifyCachedSignature(hash, tx.Signature, tx.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid transaction signature")
+	}
+	return nil
+}
+
+// VerifyTxSignatures checks every signature a transaction carries: its input signatures,
+// or its transaction signature. Whether a signature is required at all is left to
+// ValidateTransaction.
+func VerifyTxSignatures(tx *Transaction) error {
+	if tx.HasInputSignatures() {
+		return validateInputSignatures(tx)
+	}
+	if len(tx.Signature) == 0 || len(tx.PublicKey) == 0 {
+		return nil
+	}
+	return tx.verifySignature()
+}
+
+// VerifyTxSignaturesParallel verifies the transactions' signatures on runtime.NumCPU
+// workers. errs[i] is the result for txs[i].
+func VerifyTxSignaturesParallel(txs []*Transaction) []error {
+	errs := make([]error, len(txs))
+	workers := min(runtime.NumCPU(), len(txs))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(txs) {
+					return
+				}
+				errs[i] = VerifyTxSignatures(txs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// blockSignedTxs returns the transactions of a block that can carry signatures
+func (bc *Blockchain) blockSignedTxs(block *Block, mempool *Mempool) []*Transaction {
+	var txs []*Transaction
+	for _, tx := range bc.blockTxs(block, mempool) {
+		if tx.TxType != TxTypeCoinbase {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// ValidateBlockSignatures rejects a block carrying a transaction with an invalid
+// signature. Signatures already verified, e.g. on mempool admission, are not checked again.
+func (bc *Blockchain) ValidateBlockSignatures(block *Block, mempool *Mempool) error {
+	txs := bc.blockSignedTxs(block, mempool)
+	for i, err := range VerifyTxSignaturesParallel(txs) {
+		if err != nil {
+			txID, _ := txs[i].ID()
+			return fmt.Errorf("transaction %s: %w", txID, err)
+		}
+	}
+	return nil
+}
+
+// PreverifyBlockSignatures verifies the signatures of a batch of blocks in one parallel
+// pass, so applying them one by one finds every signature in the cache. Failures are
+// left for ValidateBlockSignatures to report against their block.
+func (bc *Blockchain) PreverifyBlockSignatures(blocks []*Block) {
+	var txs []*Transaction
+	for _, block := range blocks {
+		txs = append(txs, bc.blockSignedTxs(block, nil)...)
+	}
+	VerifyTxSignaturesParallel(txs)
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// signedSends builds n distinct signed sends from kp
+func signedSends(t testing.TB, kp *KeyPair, n int) []*Transaction {
+	batch := time.Now().UnixNano()
+	txs := make([]*Transaction, n)
+	for i := range txs {
+		txs[i] = NewTxBuilder(TxTypeSend).AddInput(fmt.Sprintf("prev%d-%d", batch, i), 0).AddOutput(kp.Address(), 10, "SHADOW").Build()
+		if err := txs[i].Sign(kp); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+	}
+	return txs
+}
+
+func TestVerifyTxSignatures(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	txs := signedSends(t, alice, 3)
+
+	forged := *txs[1]
+	forged.Signature = append([]byte(nil), txs[1].Signature...)
+	forged.Signature[0] ^= 0xff
+
+	errs := VerifyTxSignaturesParallel([]*Transaction{txs[0], &forged, txs[2]})
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("Expected valid signatures to verify, got %v", errs)
+	}
+	if errs[1] == nil {
+		t.Fatal("Expected a forged signature to fail")
+	}
+
+	// A re-validated transaction is served from the cache
+	before := GetSigCacheStats()
+	if err := ValidateTransaction(txs[0]); err != nil {
+		t.Fatalf("Expected the transaction to validate, got %v", err)
+	}
+	if after := GetSigCacheStats(); after.Hits != before.Hits+1 {
+		t.Errorf("Expected a cache hit, got %+v then %+v", before, after)
+	}
+
+	// The cache doesn't vouch for another key presented with the same transaction ID
+	swapped := *txs[0]
+	swapped.PublicKey, _ = PublicKeyToBytes(bob.PublicKey)
+	if err := VerifyTxSignatures(&swapped); err == nil {
+		t.Error("Expected a substituted public key to fail")
+	}
+}
+
+func BenchmarkVerifyTxSignatures(b *testing.B) {
+	kp, _ := GenerateKeyPair()
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			txs := signedSends(b, kp, 64)
+			b.StartTimer()
+			for _, tx := range txs {
+				VerifyTxSignatures(tx)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			txs := signedSends(b, kp, 64)
+			b.StartTimer()
+			VerifyTxSignaturesParallel(txs)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		txs := signedSends(b, kp, 64)
+		VerifyTxSignaturesParallel(txs)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			VerifyTxSignaturesParallel(txs)
+		}
+	})
+}
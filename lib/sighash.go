@@ -0,0 +1,247 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Sighash modes let each input be signed on its own, committing to only part of the
+// transaction, so several parties can build one transaction together: a swap maker signs
+// their input with SINGLE|ANYONECANPAY (committing to their input and the output paying
+// them), and the taker adds their own inputs and outputs and signs with ALL. A
+// transaction is either signed as a whole (Transaction.Signature) or every input carries
+// its own signature; per-input signatures are only accepted on sends.
+
+// SigHashType selects what an input signature commits to
+type SigHashType uint8
+
+const (
+	SigHashAll          SigHashType = 0x01 // Every input and output
+	SigHashSingle       SigHashType = 0x03 // Every input, and only the output at the input's index
+	SigHashAnyoneCanPay SigHashType = 0x80 // Modifier: only this input, others may be added
+)
+
+// base returns the mode without the ANYONECANPAY modifier
+func (s SigHashType) base() SigHashType {
+	return s &^ SigHashAnyoneCanPay
+}
+
+// AnyoneCanPay reports whether the signature leaves other inputs open
+func (s SigHashType) AnyoneCanPay() bool {
+	return s&SigHashAnyoneCanPay != 0
+}
+
+// Valid reports whether the sighash type is a known mode
+func (s SigHashType) Valid() bool {
+	return s.base() == SigHashAll || s.base() == SigHashSingle
+}
+
+// String returns the mode as written in the API, e.g. "SINGLE|ANYONECANPAY"
+func (s SigHashType) String() string {
+	var name string
+	switch s.base() {
+	case SigHashAll:
+		name = "ALL"
+	case SigHashSingle:
+		name = "SINGLE"
+	default:
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(s))
+	}
+	if s.AnyoneCanPay() {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// ParseSigHashType parses a mode such as "ALL" or "SINGLE|ANYONECANPAY"; empty means ALL
+func ParseSigHashType(str string) (SigHashType, error) {
+	var base, modifier SigHashType
+	for _, part := range strings.Split(strings.ToUpper(str), "|") {
+		var mode SigHashType
+		switch strings.TrimSpace(part) {
+		case "":
+			continue
+		case "ALL":
+			mode = SigHashAll
+		case "SINGLE":
+			mode = SigHashSingle
+		case "ANYONECANPAY":
+			modifier = SigHashAnyoneCanPay
+			continue
+		default:
+			return 0, fmt.Errorf("unknown sighash mode %q", part)
+		}
+		if base != 0 && base != mode {
+			return 0, fmt.Errorf("sighash %q combines ALL and SINGLE", str)
+		}
+		base = mode
+	}
+	if base == 0 {
+		base = SigHashAll
+	}
+	return base | modifier, nil
+}
+
+// HasInputSignatures reports whether any input carries its own signature
+func (tx *Transaction) HasInputSignatures() bool {
+	for _, input := range tx.Inputs {
+		if len(input.Signature) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// unsignedInput returns a copy of an input without its signature fields
+func unsignedInput(input *TxInput) *TxInput {
+	return &TxInput{
+		PrevTxID:    input.PrevTxID,
+		OutputIndex: input.OutputIndex,
+		ScriptSig:   input.ScriptSig,
+		Sequence:    input.Sequence,
+	}
+}
+
+// InputSigningHash returns the payload an input's key signs under sigHash: the canonical
+// JSON of the transaction fields, inputs and outputs the mode commits to, plus the input
+// being signed and the mode, hashed and bound to the chain ID like SigningHash.
+func (tx *Transaction) InputSigningHash(index int, sigHash SigHashType) ([]byte, error) {
+	if index < 0 || index >= len(tx.Inputs) {
+		return nil, fmt.Errorf("input %d out of range", index)
+	}
+	if !sigHash.Valid() {
+		return nil, fmt.Errorf("invalid sighash type 0x%02x", uint8(sigHash))
+	}
+
+	committed := &Transaction{
+		TxType:     tx.TxType,
+		Version:    tx.Version,
+		Timestamp:  tx.Timestamp,
+		LockTime:   tx.LockTime,
+		MempoolTTL: tx.MempoolTTL,
+		TokenFee:   tx.TokenFee,
+		Data:       tx.Data,
+	}
+	if sigHash.AnyoneCanPay() {
+		committed.Inputs = []*TxInput{unsignedInput(tx.Inputs[index])}
+	} else {
+		for _, input := range tx.Inputs {
+			committed.Inputs = append(committed.Inputs, unsignedInput(input))
+		}
+	}
+	if sigHash.base() == SigHashSingle {
+		if index >= len(tx.Outputs) {
+			return nil, fmt.Errorf("SINGLE signature on input %d has no matching output", index)
+		}
+		committed.Outputs = []*TxOutput{tx.Outputs[index]}
+	} else {
+		committed.Outputs = tx.Outputs
+	}
+
+	payload, err := CanonicalJSON(struct {
+		Transaction *Transaction `json:"transaction"`
+		Input       *TxInput     `json:"input"`
+		SigHash     SigHashType  `json:"sighash"`
+	}{committed, unsignedInput(tx.Inputs[index]), sigHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode input signing payload: %w", err)
+	}
+
+	hash := blake2b.Sum256(payload)
+	signingHash := blake2b.Sum256(append([]byte(ActiveGenesis().ChainID+":"), hash[:]...))
+	return signingHash[:], nil
+}
+
+// SignInput signs one input under sigHash with the key owning the output it spends
+func (tx *Transaction) SignInput(index int, sigHash SigHashType, signer Signer) error {
+	hash, err := tx.InputSigningHash(index, sigHash)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign input %d: %w", index, err)
+	}
+	pkBytes, err := PublicKeyToBytes(signer.PublicKey())
+	if err != nil {
+		return fmt.Errorf("failed to serialize public key: %w", err)
+	}
+
+	input := tx.Inputs[index]
+	input.SigHash = sigHash
+	input.PublicKey = pkBytes
+	input.Signature = signature
+	return nil
+}
+
+// validateInputSignatures checks per-input signatures: only sends may use them, every
+// input must then be signed, and no whole-transaction signature may be mixed in
+func validateInputSignatures(tx *Transaction) error {
+	if !tx.HasInputSignatures() {
+		return nil
+	}
+	if tx.TxType != TxTypeSend {
+		return fmt.Errorf("per-input signatures are only allowed on send transactions")
+	}
+	if len(tx.Signature) > 0 {
+		return fmt.Errorf("transaction has both a transaction signature and input signatures")
+	}
+
+	for i, input := range tx.Inputs {
+		if len(input.Signature) == 0 && input.Witness != nil {
+			continue // Authorized by its witness
+		}
+		if len(input.Signature) == 0 || len(input.PublicKey) == 0 {
+			return fmt.Errorf("input %d is not signed", i)
+		}
+		hash, err := tx.InputSigningHash(i, input.SigHash)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		valid, err := verifyCachedSignature(hash, input.Signature, input.PublicKey)
+		if err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+		if !valid {
+			return fmt.Errorf("input %d: invalid %s signature", i, input.SigHash)
+		}
+	}
+	return nil
+}
+
+// CheckInputSignatures verifies that every input signature was made by the key owning
+// the output it spends. lookup resolves spent outputs; inputs it can't resolve are left
+// to other validation.
+func CheckInputSignatures(tx *Transaction, _ uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	if !tx.HasInputSignatures() {
+		return nil
+	}
+	for i, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil || len(input.Signature) == 0 || spent.Condition != "" {
+			continue // Conditioned outputs are checked by CheckSpendConditions
+		}
+		publicKey, err := PublicKeyFromBytes(input.PublicKey)
+		if err != nil {
+			return fmt.Errorf("input %d: invalid public key: %w", i, err)
+		}
+		if DeriveAddress(publicKey) != spent.Address {
+			return fmt.Errorf("input %d spends %s:%d, which its signer does not own",
+				i, input.PrevTxID, input.OutputIndex)
+		}
+	}
+	return nil
+}
+
+// ValidateInputSignatures rejects a block with an input signed by a key that does not own
+// the output it spends
+func (bc *Blockchain) ValidateInputSignatures(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, CheckInputSignatures)
+}
+
+// checkInputSignatures rejects a transaction with an input signed by a non-owner
+func (mp *Mempool) checkInputSignatures(tx *Transaction) error {
+	return mp.checkSpends(tx, CheckInputSignatures)
+}
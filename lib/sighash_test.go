@@ -0,0 +1,84 @@
+package lib
+
+import "testing"
+
+func TestSigHashCollaborativeSwap(t *testing.T) {
+	maker, _ := GenerateKeyPair()
+	taker, _ := GenerateKeyPair()
+	tokenID := "f6e5d4c3b2a1a9b8c7d6e5f4a3b2c1d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6"
+
+	// The maker offers 500 tokens for 10000 SHADOW paid to them
+	tx := NewTxBuilder(TxTypeSend).
+		AddInput("maker-tokens", 0).
+		AddOutput(maker.Address(), 10_000, "SHADOW").
+		Build()
+	if err := tx.SignInput(0, SigHashSingle|SigHashAnyoneCanPay, NewLocalSigner(maker)); err != nil {
+		t.Fatalf("Maker failed to sign: %v", err)
+	}
+
+	// The taker completes it with their SHADOW and takes the tokens
+	tx.Inputs = append(tx.Inputs, NewTxInput("taker-shadow", 1))
+	tx.Outputs = append(tx.Outputs, CreateTokenOutput(taker.Address(), 500, tokenID, "custom", nil))
+	if err := tx.SignInput(1, SigHashAll, NewLocalSigner(taker)); err != nil {
+		t.Fatalf("Taker failed to sign: %v", err)
+	}
+	if err := ValidateTransaction(tx); err != nil {
+		t.Fatalf("Combined transaction should validate: %v", err)
+	}
+
+	// Inputs must be signed by the owners of what they spend
+	owners := map[string]Address{"maker-tokens": maker.Address(), "taker-shadow": taker.Address()}
+	lookup := func(txID string, index uint32) *TxOutput {
+		return CreateShadowOutput(owners[txID], 1)
+	}
+	if err := CheckInputSignatures(tx, 0, lookup); err != nil {
+		t.Errorf("Owners signed their inputs: %v", err)
+	}
+	owners["maker-tokens"] = taker.Address()
+	if err := CheckInputSignatures(tx, 0, lookup); err == nil {
+		t.Error("Input signed by a non-owner should be rejected")
+	}
+
+	// Changing the maker's payment breaks their SINGLE signature
+	tx.Outputs[0].Amount = 1
+	if err := ValidateTransaction(tx); err == nil {
+		t.Error("SINGLE signature should commit to its output")
+	}
+	tx.Outputs[0].Amount = 10_000
+
+	// Adding an input after the taker signed breaks their ALL signature
+	tx.Inputs = append(tx.Inputs, NewTxInput("extra", 0))
+	tx.Inputs[2].Signature = tx.Inputs[1].Signature
+	tx.Inputs[2].PublicKey = tx.Inputs[1].PublicKey
+	if err := ValidateTransaction(tx); err == nil {
+		t.Error("ALL signature should commit to every input")
+	}
+	tx.Inputs = tx.Inputs[:2]
+
+	// Unsigned inputs and mixed signing are rejected
+	tx.Inputs[1].Signature = nil
+	if err := ValidateTransaction(tx); err == nil {
+		t.Error("Every input must be signed")
+	}
+}
+
+func TestParseSigHashType(t *testing.T) {
+	tests := map[string]SigHashType{
+		"":                    SigHashAll,
+		"all":                 SigHashAll,
+		"SINGLE":              SigHashSingle,
+		"ANYONECANPAY":        SigHashAll | SigHashAnyoneCanPay,
+		"SINGLE|ANYONECANPAY": SigHashSingle | SigHashAnyoneCanPay,
+	}
+	for str, want := range tests {
+		got, err := ParseSigHashType(str)
+		if err != nil || got != want {
+			t.Errorf("ParseSigHashType(%q) = %v, %v; want %v", str, got, err, want)
+		}
+	}
+	for _, str := range []string{"NONE", "ALL|SINGLE"} {
+		if _, err := ParseSigHashType(str); err == nil {
+			t.Errorf("ParseSigHashType(%q) should fail", str)
+		}
+	}
+}
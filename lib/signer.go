@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+)
+
+// Signer abstracts where a private key lives. The node only needs to be able to
+// request a signature over a hash and learn the matching public key, so keys can
+// be held in process memory or by an external HSM-like signing service.
+type Signer interface {
+	Sign(hash []byte) ([]byte, error)
+	PublicKey() *mldsa87.PublicKey
+}
+
+// LocalSigner signs with an in-memory ML-DSA87 key pair
+type LocalSigner struct {
+	keyPair *KeyPair
+}
+
+// NewLocalSigner wraps a key pair as a Signer
+func NewLocalSigner(kp *KeyPair) *LocalSigner {
+	return &LocalSigner{keyPair: kp}
+}
+
+// Sign signs the given hash with the in-memory private key
+func (ls *LocalSigner) Sign(hash []byte) ([]byte, error) {
+	if ls.keyPair == nil || ls.keyPair.PrivateKey == nil {
+		return nil, fmt.Errorf("local signer has no private key")
+	}
+	return ls.keyPair.Sign(hash)
+}
+
+// PublicKey returns the public key of the wrapped key pair
+func (ls *LocalSigner) PublicKey() *mldsa87.PublicKey {
+	if ls.keyPair == nil {
+		return nil
+	}
+	return ls.keyPair.PublicKey
+}
+
+// RemoteSignRequest is the body POSTed to a remote signer's /sign endpoint
+type RemoteSignRequest struct {
+	KeyID string `json:"key_id"`
+	Hash  string `json:"hash"` // Base64 encoded hash to sign
+}
+
+// RemoteSignResponse is returned by a remote signer's /sign endpoint
+type RemoteSignResponse struct {
+	Signature   string `json:"signature"`             // Base64 encoded ML-DSA87 signature
+	Attestation string `json:"attestation,omitempty"` // Opaque attestation blob from the signing device
+}
+
+// RemotePublicKeyResponse is returned by a remote signer's /pubkey endpoint
+type RemotePublicKeyResponse struct {
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // Base64 encoded ML-DSA87 public key
+}
+
+// RemoteSigner delegates signing to an external HTTP signing service.
+//
+// Protocol:
+//
+//	GET  {url}/pubkey?key_id=ID -> RemotePublicKeyResponse
+//	POST {url}/sign             -> RemoteSignResponse
+//
+// Every signature returned by the service is verified locally against the
+// public key fetched at construction time, so a misbehaving or compromised
+// signer cannot hand back signatures for a different key.
+type RemoteSigner struct {
+	url       string
+	keyID     string
+	authToken string
+	client    *http.Client
+	publicKey *mldsa87.PublicKey
+
+	mu              sync.Mutex
+	lastAttestation string
+}
+
+// NewRemoteSigner connects to a remote signing service and fetches the public key for keyID.
+// authToken is optional and sent as a bearer token.
+func NewRemoteSigner(url, keyID, authToken string) (*RemoteSigner, error) {
+	if url == "" {
+		return nil, fmt.Errorf("remote signer URL cannot be empty")
+	}
+
+	rs := &RemoteSigner{
+		url:       strings.TrimRight(url, "/"),
+		keyID:     keyID,
+		authToken: authToken,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := rs.fetchPublicKey(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// fetchPublicKey retrieves and caches the signer's public key
+func (rs *RemoteSigner) fetchPublicKey() error {
+	req, err := http.NewRequest(http.MethodGet, rs.url+"/pubkey?"+url.Values{"key_id": {rs.keyID}}.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build public key request: %w", err)
+	}
+	rs.setAuth(req)
+
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote signer returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var pkResp RemotePublicKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pkResp); err != nil {
+		return fmt.Errorf("failed to decode public key response: %w", err)
+	}
+
+	pkBytes, err := base64.StdEncoding.DecodeString(pkResp.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key encoding: %w", err)
+	}
+
+	pk, err := PublicKeyFromBytes(pkBytes)
+	if err != nil {
+		return err
+	}
+
+	rs.publicKey = pk
+	return nil
+}
+
+// Sign asks the remote service to sign the hash and verifies the result locally
+func (rs *RemoteSigner) Sign(hash []byte) ([]byte, error) {
+	if len(hash) == 0 {
+		return nil, fmt.Errorf("cannot sign empty message")
+	}
+
+	body, err := json.Marshal(RemoteSignRequest{
+		KeyID: rs.keyID,
+		Hash:  base64.StdEncoding.EncodeToString(hash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sign request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rs.url+"/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sign request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rs.setAuth(req)
+
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("remote signer returned %d: %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+	}
+
+	var signResp RemoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signResp); err != nil {
+		return nil, fmt.Errorf("failed to decode sign response: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signResp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	// Never trust the remote blindly - the signature must verify under the advertised key
+	if !VerifySignature(hash, signature, rs.publicKey) {
+		return nil, fmt.Errorf("remote signer returned a signature that does not verify")
+	}
+
+	rs.mu.Lock()
+	rs.lastAttestation = signResp.Attestation
+	rs.mu.Unlock()
+
+	return signature, nil
+}
+
+// PublicKey returns the cached public key of the remote signer
+func (rs *RemoteSigner) PublicKey() *mldsa87.PublicKey {
+	return rs.publicKey
+}
+
+// LastAttestation returns the attestation blob from the most recent signature
+func (rs *RemoteSigner) LastAttestation() string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.lastAttestation
+}
+
+// setAuth adds the bearer token if one is configured
+func (rs *RemoteSigner) setAuth(req *http.Request) {
+	if rs.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rs.authToken)
+	}
+}
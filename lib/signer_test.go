@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestSignerServer starts an HTTP remote signer backed by the given key pair
+func newTestSignerServer(t *testing.T, kp *KeyPair, tamper bool) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pubkey", func(w http.ResponseWriter, r *http.Request) {
+		pkBytes, _ := PublicKeyToBytes(kp.PublicKey)
+		json.NewEncoder(w).Encode(RemotePublicKeyResponse{
+			KeyID:     r.URL.Query().Get("key_id"),
+			PublicKey: base64.StdEncoding.EncodeToString(pkBytes),
+		})
+	})
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		var req RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hash, _ := base64.StdEncoding.DecodeString(req.Hash)
+		sig, _ := kp.Sign(hash)
+		if tamper {
+			sig[0] ^= 0xFF
+		}
+		json.NewEncoder(w).Encode(RemoteSignResponse{
+			Signature:   base64.StdEncoding.EncodeToString(sig),
+			Attestation: "test-attestation",
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLocalSignerSignsTransaction(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	tx := NewTxBuilder(TxTypeSend).AddOutput(kp.Address(), 1000, "").Build()
+	if err := tx.SignWith(NewLocalSigner(kp)); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	hash, _ := tx.SigningHash()
+	if !VerifySignature(hash, tx.Signature, kp.PublicKey) {
+		t.Fatal("Signature from local signer does not verify")
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	server := newTestSignerServer(t, kp, false)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(server.URL, "node-key", "")
+	if err != nil {
+		t.Fatalf("Failed to create remote signer: %v", err)
+	}
+
+	if DeriveAddress(signer.PublicKey()) != kp.Address() {
+		t.Fatal("Remote signer public key mismatch")
+	}
+
+	wallet := &NodeWallet{}
+	wallet.UseSigner(signer)
+	if wallet.Address != kp.Address() {
+		t.Fatal("Wallet address should follow the remote signer key")
+	}
+
+	tx := NewTxBuilder(TxTypeSend).AddOutput(kp.Address(), 1000, "").Build()
+	if err := wallet.SignTransaction(tx); err != nil {
+		t.Fatalf("Failed to sign via remote signer: %v", err)
+	}
+
+	hash, _ := tx.SigningHash()
+	if !VerifySignature(hash, tx.Signature, kp.PublicKey) {
+		t.Fatal("Signature from remote signer does not verify")
+	}
+
+	if signer.LastAttestation() != "test-attestation" {
+		t.Fatalf("Expected attestation to be recorded, got %q", signer.LastAttestation())
+	}
+}
+
+func TestRemoteSignerRejectsBadSignature(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	server := newTestSignerServer(t, kp, true)
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(server.URL, "node-key", "")
+	if err != nil {
+		t.Fatalf("Failed to create remote signer: %v", err)
+	}
+
+	if _, err := signer.Sign([]byte("some hash")); err == nil {
+		t.Fatal("Expected tampered signature to be rejected")
+	}
+}
+
+func TestRemoteSignerEscapesKeyID(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	var gotKeyID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKeyID = r.URL.Query().Get("key_id")
+		pkBytes, _ := PublicKeyToBytes(kp.PublicKey)
+		json.NewEncoder(w).Encode(RemotePublicKeyResponse{KeyID: gotKeyID, PublicKey: base64.StdEncoding.EncodeToString(pkBytes)})
+	}))
+	defer server.Close()
+
+	// A key ID with query characters reaches the signer intact
+	keyID := "ops/node key&key_id=other#1"
+	if _, err := NewRemoteSigner(server.URL, keyID, ""); err != nil {
+		t.Fatalf("Failed to create remote signer: %v", err)
+	}
+	if gotKeyID != keyID {
+		t.Errorf("Expected key ID %q, got %q", keyID, gotKeyID)
+	}
+}
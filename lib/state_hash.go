@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// The state hash commits to the contents of the UTXO set. Every unspent output is hashed
+// with its outpoint, and the accumulator is the sum of those hashes mod 2^256: adding an
+// output adds its hash, spending it subtracts it, so the accumulator is kept up to date
+// with the balance index at no more than a read and a write per change, and two stores
+// holding the same unspent outputs hold the same accumulator whatever order they were
+// built in. After each block, sha256(height || accumulator) is recorded as that block's
+// state hash. Nodes that applied the same blocks but disagree on a state hash have
+// diverged state.
+
+const (
+	StateAccumulatorKey = "statemeta:acc" // Sum mod 2^256 of unspent output hashes (hex)
+	StateHashPrefix     = "statehash:"    // statehash:{height} -> state hash after the block
+	stateHashDomain     = "shadowy/utxo/v1"
+)
+
+// stateModulus is 2^256
+var stateModulus = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// utxoStateHash hashes an unspent output with its outpoint
+func utxoStateHash(utxo *UTXO) (*big.Int, error) {
+	output, err := json.Marshal(utxo.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(stateHashDomain))
+	fmt.Fprintf(h, "%s:%d:", utxo.TxID, utxo.OutputIndex)
+	h.Write(output)
+	return new(big.Int).SetBytes(h.Sum(nil)), nil
+}
+
+// accumulateState adds or removes an output hash from an accumulator in place
+func accumulateState(acc *big.Int, utxo *UTXO, add bool) error {
+	element, err := utxoStateHash(utxo)
+	if err != nil {
+		return err
+	}
+	if add {
+		acc.Add(acc, element)
+	} else {
+		acc.Sub(acc, element)
+	}
+	acc.Mod(acc, stateModulus)
+	return nil
+}
+
+// readStateAccumulator reads the accumulator (zero for an empty set) (caller holds store.mutex)
+func (store *UTXOStore) readStateAccumulator() (*big.Int, error) {
+	data, err := store.db.Get([]byte(StateAccumulatorKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state accumulator: %w", err)
+	}
+	acc := new(big.Int)
+	if data != nil {
+		if _, ok := acc.SetString(string(data), 16); !ok {
+			return nil, fmt.Errorf("corrupt state accumulator %q", data)
+		}
+	}
+	return acc, nil
+}
+
+// writeStateAccumulator stores the accumulator (caller holds store.mutex)
+func (store *UTXOStore) writeStateAccumulator(acc *big.Int) error {
+	if err := store.db.Set([]byte(StateAccumulatorKey), []byte(acc.Text(16))); err != nil {
+		return fmt.Errorf("failed to store state accumulator: %w", err)
+	}
+	return nil
+}
+
+// adjustStateAccumulator adds or removes an unspent output (caller holds store.mutex)
+func (store *UTXOStore) adjustStateAccumulator(utxo *UTXO, add bool) error {
+	acc, err := store.readStateAccumulator()
+	if err != nil {
+		return err
+	}
+	if err := accumulateState(acc, utxo, add); err != nil {
+		return err
+	}
+	return store.writeStateAccumulator(acc)
+}
+
+// stateHash binds an accumulator to the height it was reached at
+func stateHash(height uint64, acc *big.Int) string {
+	var buf [8 + 32]byte
+	binary.BigEndian.PutUint64(buf[:8], height)
+	acc.FillBytes(buf[8:])
+	sum := sha256.Sum256(buf[:])
+	return hex.EncodeToString(sum[:])
+}
+
+// recordStateHashLocked stores the state hash after the block at height (caller holds store.mutex)
+func (store *UTXOStore) recordStateHashLocked(height uint64) error {
+	acc, err := store.readStateAccumulator()
+	if err != nil {
+		return err
+	}
+	key := StateHashPrefix + strconv.FormatUint(height, 10)
+	if err := store.db.Set([]byte(key), []byte(stateHash(height, acc))); err != nil {
+		return fmt.Errorf("failed to store state hash: %w", err)
+	}
+	return nil
+}
+
+// StateHash returns the state hash recorded after the block at height, or "" if none
+// was (the block was applied before state hashes were recorded)
+func (store *UTXOStore) StateHash(height uint64) (string, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	data, err := store.db.Get([]byte(StateHashPrefix + strconv.FormatUint(height, 10)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read state hash: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStateHashCommitsToUTXOSet(t *testing.T) {
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	utxos := []*UTXO{
+		{TxID: "state-hash-funding-a", OutputIndex: 0, Output: CreateShadowOutput(alice.Address(), 100), BlockHeight: 1},
+		{TxID: "state-hash-funding-b", OutputIndex: 0, Output: CreateShadowOutput(bob.Address(), 50), BlockHeight: 1},
+		{TxID: "state-hash-funding-b", OutputIndex: 1, Output: CreateShadowOutput(alice.Address(), 25), BlockHeight: 1},
+	}
+
+	openStore := func(name string) *UTXOStore {
+		store, err := NewUTXOStore(filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatalf("Failed to create UTXO store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	recorded := func(store *UTXOStore, height uint64) string {
+		if err := store.setAppliedHeight(height); err != nil {
+			t.Fatalf("Failed to record state hash: %v", err)
+		}
+		hash, err := store.StateHash(height)
+		if err != nil || hash == "" {
+			t.Fatalf("Expected a state hash at height %d, got %q (%v)", height, hash, err)
+		}
+		return hash
+	}
+
+	// The same outputs added in any order give the same state hash
+	forward, backward := openStore("forward.db"), openStore("backward.db")
+	for i := range utxos {
+		if err := forward.AddUTXO(utxos[i]); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+		copied := *utxos[len(utxos)-1-i]
+		if err := backward.AddUTXO(&copied); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	before := recorded(forward, 1)
+	if got := recorded(backward, 1); got != before {
+		t.Fatalf("Expected equal sets to hash alike, got %s and %s", before[:16], got[:16])
+	}
+
+	// Spending diverges the state; the same spend brings the stores back together
+	if err := forward.SpendUTXO("state-hash-funding-a", 0, 2); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	spent := recorded(forward, 2)
+	if got := recorded(backward, 2); got == spent {
+		t.Fatal("Expected the state hash to change when an output is spent")
+	}
+	if err := backward.SpendUTXO("state-hash-funding-a", 0, 2); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	if got := recorded(backward, 2); got != spent {
+		t.Fatalf("Expected the same spend to give the same state hash, got %s and %s", spent[:16], got[:16])
+	}
+
+	// The accumulator is rebuilt with the balance index and checked for consistency
+	if err := forward.CheckConsistency(); err != nil {
+		t.Fatalf("Fresh store should be consistent: %v", err)
+	}
+	if err := forward.db.Set([]byte(StateAccumulatorKey), []byte("1")); err != nil {
+		t.Fatalf("Failed to corrupt accumulator: %v", err)
+	}
+	if err := forward.CheckConsistency(); err == nil {
+		t.Fatal("Expected a corrupted accumulator to fail the consistency check")
+	}
+	if err := forward.RebuildBalanceIndex(); err != nil {
+		t.Fatalf("Failed to rebuild balance index: %v", err)
+	}
+	if got := recorded(forward, 2); got != spent {
+		t.Fatalf("Expected the rebuilt accumulator to match, got %s want %s", got[:16], spent[:16])
+	}
+}
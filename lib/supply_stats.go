@@ -0,0 +1,434 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Balance index prefixes. The index is kept up to date by AddUTXO/SpendUTXO so supply
+// and rich list queries never have to scan the UTXO set.
+const (
+	BalancePrefix   = "bal:"       // bal:{address}:{tokenID} -> unspent amount
+	SupplyPrefix    = "supply:"    // supply:{tokenID} -> total unspent amount
+	OfferLockPrefix = "offerlock:" // offerlock:{tokenID} -> amount locked in open offers
+
+	balanceIndexVersionKey = "balmeta:version"
+	balanceIndexVersion    = "3" // 2: token index, 3: state accumulator
+
+	DefaultRichListLimit = 100
+	MaxRichListLimit     = 1000
+
+	MaxBalanceBatchAddresses = 10000 // Addresses per /api/balances request
+)
+
+// RichListEntry is one address in the rich list
+type RichListEntry struct {
+	Address string `json:"address"`
+	Balance uint64 `json:"balance"`
+}
+
+// AddressBalances is one address's token balances in a batch balance query
+type AddressBalances struct {
+	Address  string            `json:"address"`
+	Balances map[string]uint64 `json:"balances"` // Token ID -> unspent amount
+}
+
+// TokenMeltStats is the melted total for one custom token
+type TokenMeltStats struct {
+	TokenID     string `json:"token_id"`
+	Ticker      string `json:"ticker"`
+	TotalMelted uint64 `json:"total_melted"`
+}
+
+// SupplyStats summarizes where SHADOW currently sits
+type SupplyStats struct {
+	TotalSupply    uint64           `json:"total_supply"`     // Genesis supply cap
+	Circulating    uint64           `json:"circulating"`      // Unspent SHADOW outputs
+	Burned         uint64           `json:"burned"`           // SHADOW held by the zero address or destroyed by burn transactions
+	LockedInPools  uint64           `json:"locked_in_pools"`  // SHADOW reserves of liquidity pools
+	LockedInOffers uint64           `json:"locked_in_offers"` // SHADOW locked in open swap offers
+	LockedInTokens uint64           `json:"locked_in_tokens"` // SHADOW backing unmelted custom tokens
+	Melted         []TokenMeltStats `json:"melted"`           // Melted totals per custom token
+	Height         uint64           `json:"height"`
+}
+
+// adjustCounter adds or subtracts amount from a decimal counter key (caller holds store.mutex).
+// Counters floor at zero and are deleted when they reach it.
+func (store *UTXOStore) adjustCounter(key string, amount uint64, add bool) error {
+	if amount == 0 {
+		return nil
+	}
+
+	var current uint64
+	data, err := store.db.Get([]byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to read counter %s: %w", key, err)
+	}
+	if data != nil {
+		current, _ = strconv.ParseUint(string(data), 10, 64)
+	}
+
+	switch {
+	case add:
+		current += amount
+	case amount >= current:
+		current = 0
+	default:
+		current -= amount
+	}
+
+	if current == 0 {
+		return store.db.Delete([]byte(key))
+	}
+	return store.db.Set([]byte(key), []byte(strconv.FormatUint(current, 10)))
+}
+
+// readCounter reads a decimal counter key (0 if missing)
+func (store *UTXOStore) readCounter(key string) (uint64, error) {
+	data, err := store.db.Get([]byte(key))
+	if err != nil {
+		return 0, err
+	}
+	if data == nil {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(data), 10, 64)
+}
+
+// indexOutput adds or removes an unspent output from the balance index (caller holds store.mutex)
+func (store *UTXOStore) indexOutput(utxo *UTXO, add bool) error {
+	if utxo == nil || utxo.Output == nil {
+		return nil
+	}
+	out := utxo.Output
+	balKey := fmt.Sprintf("%s%s:%s", BalancePrefix, out.Address.String(), out.TokenID)
+	if err := store.adjustCounter(balKey, out.Amount, add); err != nil {
+		return fmt.Errorf("failed to update balance index: %w", err)
+	}
+	if err := store.adjustCounter(SupplyPrefix+out.TokenID, out.Amount, add); err != nil {
+		return fmt.Errorf("failed to update supply index: %w", err)
+	}
+	if err := store.indexTokenOutput(utxo, add); err != nil {
+		return fmt.Errorf("failed to update token index: %w", err)
+	}
+	return store.adjustStateAccumulator(utxo, add)
+}
+
+// indexOffer adds or removes an offer's locked amount (caller holds store.mutex)
+func (store *UTXOStore) indexOffer(offerTx *Transaction, add bool) error {
+	var offerData OfferData
+	if err := json.Unmarshal(offerTx.Data, &offerData); err != nil {
+		return fmt.Errorf("failed to parse offer data: %w", err)
+	}
+	return store.adjustCounter(OfferLockPrefix+offerData.HaveTokenID, offerData.HaveAmount, add)
+}
+
+// trackOffer updates the offer lock index for an offer, accept, or cancel transaction
+func (store *UTXOStore) trackOffer(offerTx *Transaction, add bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.indexOffer(offerTx, add); err != nil {
+		fmt.Printf("[SwapOffer] Warning: Failed to update offer lock index: %v\n", err)
+	}
+}
+
+// GetIndexedBalance returns an address's unspent balance of a token from the balance index
+func (store *UTXOStore) GetIndexedBalance(address Address, tokenID string) (uint64, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.readCounter(fmt.Sprintf("%s%s:%s", BalancePrefix, address.String(), tokenID))
+}
+
+// GetIndexedBalances returns an address's unspent balance of each token it holds, or of
+// tokenIDs only if any are given, from the balance index as of the snapshot
+func (view *UTXOSnapshot) GetIndexedBalances(address Address, tokenIDs []string) (map[string]uint64, error) {
+	prefix := fmt.Sprintf("%s%s:", BalancePrefix, address.String())
+	balances := make(map[string]uint64)
+
+	if len(tokenIDs) > 0 {
+		for _, tokenID := range tokenIDs {
+			data, err := view.snap.Get([]byte(prefix + tokenID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read balance: %w", err)
+			}
+			if balance, err := strconv.ParseUint(string(data), 10, 64); err == nil && balance > 0 {
+				balances[tokenID] = balance
+			}
+		}
+		return balances, nil
+	}
+
+	iterator, err := view.snap.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+	for ; iterator.Valid(); iterator.Next() {
+		if balance, err := strconv.ParseUint(string(iterator.Value()), 10, 64); err == nil && balance > 0 {
+			balances[string(iterator.Key()[len(prefix):])] = balance
+		}
+	}
+	return balances, nil
+}
+
+// CirculatingSupply returns the total unspent amount of a token
+func (store *UTXOStore) CirculatingSupply(tokenID string) (uint64, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.readCounter(SupplyPrefix + tokenID)
+}
+
+// OfferLocked returns the amount of a token locked in open swap offers
+func (store *UTXOStore) OfferLocked(tokenID string) (uint64, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.readCounter(OfferLockPrefix + tokenID)
+}
+
+// RichList returns the top holders of a token, largest first
+func (store *UTXOStore) RichList(tokenID string, limit int) ([]RichListEntry, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(BalancePrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	suffix := ":" + tokenID
+	var entries []RichListEntry
+	for ; iterator.Valid(); iterator.Next() {
+		key := string(iterator.Key())
+		if !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		balance, err := strconv.ParseUint(string(iterator.Value()), 10, 64)
+		if err != nil || balance == 0 {
+			continue
+		}
+		entries = append(entries, RichListEntry{
+			Address: strings.TrimSuffix(strings.TrimPrefix(key, BalancePrefix), suffix),
+			Balance: balance,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Balance != entries[j].Balance {
+			return entries[i].Balance > entries[j].Balance
+		}
+		return entries[i].Address < entries[j].Address
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// HasBalanceIndex reports whether the balance index has been built at the current version
+func (store *UTXOStore) HasBalanceIndex() bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(balanceIndexVersionKey))
+	return err == nil && string(data) == balanceIndexVersion
+}
+
+// RebuildBalanceIndex recomputes balances, supply, offer locks, the token index, and the
+// state accumulator from the UTXO set and stored transactions. Used once to index databases created before the
+// current index version.
+func (store *UTXOStore) RebuildBalanceIndex() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Collect everything first - bolt can't write while a read cursor is open
+	counters := make(map[string]uint64)
+	acc := new(big.Int)
+	var stale, tokenKeys [][]byte
+	for _, prefix := range []string{BalancePrefix, SupplyPrefix, OfferLockPrefix, AddrTokenPrefix, TokenUTXOPrefix} {
+		iterator, err := store.db.Iterator([]byte(prefix), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create iterator: %w", err)
+		}
+		for ; iterator.Valid(); iterator.Next() {
+			stale = append(stale, append([]byte(nil), iterator.Key()...))
+		}
+		iterator.Close()
+	}
+
+	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	for ; iterator.Valid(); iterator.Next() {
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil || utxo.IsSpent || utxo.Output == nil {
+			continue
+		}
+		out := utxo.Output
+		counters[fmt.Sprintf("%s%s:%s", BalancePrefix, out.Address.String(), out.TokenID)] += out.Amount
+		counters[SupplyPrefix+out.TokenID] += out.Amount
+		addrKey, tokenKey := tokenIndexKeys(&utxo)
+		tokenKeys = append(tokenKeys, []byte(addrKey), []byte(tokenKey))
+		if err := accumulateState(acc, &utxo, true); err != nil {
+			iterator.Close()
+			return err
+		}
+	}
+	iterator.Close()
+
+	// Offers stay locked until accepted or cancelled
+	openOffers := make(map[string]OfferData)
+	closedOffers := make(map[string]bool)
+	iterator, err = store.db.Iterator([]byte(TxPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	for ; iterator.Valid(); iterator.Next() {
+		var tx Transaction
+		if err := json.Unmarshal(iterator.Value(), &tx); err != nil {
+			continue
+		}
+		switch tx.TxType {
+		case TxTypeOffer:
+			var offerData OfferData
+			if err := json.Unmarshal(tx.Data, &offerData); err == nil {
+				openOffers[strings.TrimPrefix(string(iterator.Key()), TxPrefix)] = offerData
+			}
+		case TxTypeAcceptOffer:
+			var acceptData AcceptOfferData
+			if err := json.Unmarshal(tx.Data, &acceptData); err == nil {
+				closedOffers[acceptData.OfferTxID] = true
+			}
+		case TxTypeCancelOffer:
+			var cancelData CancelOfferData
+			if err := json.Unmarshal(tx.Data, &cancelData); err == nil {
+				closedOffers[cancelData.OfferTxID] = true
+			}
+		case TxTypeMatchOffers:
+			var matchData MatchOffersData
+			if err := json.Unmarshal(tx.Data, &matchData); err == nil {
+				closedOffers[matchData.MakerOfferTxID] = true
+				closedOffers[matchData.TakerOfferTxID] = true
+			}
+		}
+	}
+	iterator.Close()
+
+	for offerID, offerData := range openOffers {
+		if !closedOffers[offerID] {
+			counters[OfferLockPrefix+offerData.HaveTokenID] += offerData.HaveAmount
+		}
+	}
+
+	// Write in one database transaction; the token index has keys for every unspent output
+	batched := !store.db.InBatch()
+	if batched {
+		if err := store.db.BeginBatch(); err != nil {
+			return fmt.Errorf("failed to start balance index batch: %w", err)
+		}
+	}
+	if err := store.writeBalanceIndex(stale, counters, tokenKeys, acc); err != nil {
+		if batched {
+			store.db.DiscardBatch()
+		}
+		return err
+	}
+	if batched {
+		if err := store.db.CommitBatch(); err != nil {
+			return fmt.Errorf("failed to commit balance index: %w", err)
+		}
+	}
+
+	fmt.Printf("[UTXO] ✅ Rebuilt balance index (%d counters)\n", len(counters))
+	return nil
+}
+
+// writeBalanceIndex replaces the stale index keys with the rebuilt ones (caller holds store.mutex)
+func (store *UTXOStore) writeBalanceIndex(stale [][]byte, counters map[string]uint64, tokenKeys [][]byte, acc *big.Int) error {
+	if err := store.db.DeleteBatch(stale); err != nil {
+		return fmt.Errorf("failed to clear balance index: %w", err)
+	}
+	for key, amount := range counters {
+		if amount == 0 {
+			continue
+		}
+		if err := store.db.Set([]byte(key), []byte(strconv.FormatUint(amount, 10))); err != nil {
+			return fmt.Errorf("failed to store balance index: %w", err)
+		}
+	}
+	for _, key := range tokenKeys {
+		if err := store.db.Set(key, []byte("")); err != nil {
+			return fmt.Errorf("failed to store token index: %w", err)
+		}
+	}
+	if err := store.writeStateAccumulator(acc); err != nil {
+		return err
+	}
+	if err := store.db.Set([]byte(balanceIndexVersionKey), []byte(balanceIndexVersion)); err != nil {
+		return fmt.Errorf("failed to store balance index version: %w", err)
+	}
+	return nil
+}
+
+// SupplyStats reports circulating, burned, melted, and locked SHADOW from the balance index
+func (bc *Blockchain) SupplyStats() (*SupplyStats, error) {
+	genesis := GetGenesisToken()
+	shadowID := genesis.TokenID
+
+	circulating, err := bc.utxoStore.CirculatingSupply(shadowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read circulating supply: %w", err)
+	}
+	burned, err := bc.utxoStore.GetIndexedBalance(BurnAddress, shadowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read burned supply: %w", err)
+	}
+	if token, exists := bc.tokenRegistry.GetToken(shadowID); exists {
+		burned += token.TotalBurned
+	}
+	lockedInOffers, err := bc.utxoStore.OfferLocked(shadowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offer locks: %w", err)
+	}
+
+	stats := &SupplyStats{
+		TotalSupply:    genesis.TotalSupply,
+		Circulating:    circulating,
+		Burned:         burned,
+		LockedInOffers: lockedInOffers,
+		Melted:         []TokenMeltStats{},
+		Height:         bc.GetHeight() - 1,
+	}
+
+	for _, pool := range bc.poolRegistry.GetAllPools() {
+		if pool.TokenA == shadowID {
+			stats.LockedInPools += pool.ReserveA
+		}
+		if pool.TokenB == shadowID {
+			stats.LockedInPools += pool.ReserveB
+		}
+	}
+
+	for _, token := range bc.tokenRegistry.ListTokens() {
+		if token.IsBaseToken() {
+			continue
+		}
+		released := token.CalculateMeltValue(token.TotalMelted)
+		if token.LockedShadow > released {
+			stats.LockedInTokens += token.LockedShadow - released
+		}
+		if token.TotalMelted > 0 {
+			stats.Melted = append(stats.Melted, TokenMeltStats{
+				TokenID:     token.TokenID,
+				Ticker:      token.Ticker,
+				TotalMelted: token.TotalMelted,
+			})
+		}
+	}
+
+	return stats, nil
+}
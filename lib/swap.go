@@ -7,12 +7,13 @@ import (
 
 // OfferData represents the data stored in a TX_OFFER transaction
 type OfferData struct {
-	HaveTokenID  string `json:"have_token_id"`  // Token being offered
-	WantTokenID  string `json:"want_token_id"`  // Token wanted in exchange
-	HaveAmount   uint64 `json:"have_amount"`    // Amount of have token
-	WantAmount   uint64 `json:"want_amount"`    // Amount of want token
-	ExpiresAtBlock uint64 `json:"expires_at_block"` // Block height when offer expires
-	OfferAddress Address `json:"offer_address"`  // Address that created the offer
+	HaveTokenID    string  `json:"have_token_id"`        // Token being offered
+	WantTokenID    string  `json:"want_token_id"`        // Token wanted in exchange
+	HaveAmount     uint64  `json:"have_amount"`          // Amount of have token
+	WantAmount     uint64  `json:"want_amount"`          // Amount of want token
+	ExpiresAtBlock uint64  `json:"expires_at_block"`     // Block height when offer expires
+	OfferAddress   Address `json:"offer_address"`        // Address that created the offer
+	AutoMatch      bool    `json:"auto_match,omitempty"` // Let block proposers settle against crossing offers
 }
 
 // AcceptOfferData represents the data stored in a TX_ACCEPT_OFFER transaction
@@ -26,20 +27,27 @@ type CancelOfferData struct {
 }
 
 // CreateOfferTransaction creates a transaction that locks tokens for an atomic swap offer
-func CreateOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
+func CreateOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry,
 	haveTokenID string, wantTokenID string,
-	haveAmount uint64, wantAmount uint64, expiresAtBlock uint64) (*Transaction, error) {
+	haveAmount uint64, wantAmount uint64, expiresAtBlock uint64, autoMatch bool) (*Transaction, error) {
 
-	// Get UTXOs for the token being offered
-	utxos, err := utxoStore.GetUTXOsByAddress(nodeWallet.Address)
+	// Get UTXOs for the token being offered, and SHADOW for the fee
+	genesisTokenID := GetGenesisToken().TokenID
+	utxos, err := utxoStore.GetUTXOsByAddressAndToken(nodeWallet.Address, haveTokenID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get UTXOs: %w", err)
 	}
+	if haveTokenID != genesisTokenID {
+		shadowUTXOs, err := utxoStore.GetUTXOsByAddressAndToken(nodeWallet.Address, genesisTokenID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get UTXOs: %w", err)
+		}
+		utxos = append(utxos, shadowUTXOs...)
+	}
 
 	// Filter for unspent UTXOs of the "have" token
 	var availableTokenUTXOs []*UTXO
 	var availableShadowUTXOs []*UTXO
-	genesisTokenID := GetGenesisToken().TokenID
 
 	for _, utxo := range utxos {
 		if !utxo.IsSpent {
@@ -87,7 +95,7 @@ func CreateOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeOffer)
+	txBuilder := NewTxBuilder(TxTypeOffer).SetRegistry(tokenRegistry)
 
 	// Add token inputs (these will be locked by the offer)
 	for _, utxo := range selectedTokenUTXOs {
@@ -120,6 +128,7 @@ func CreateOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 		WantAmount:     wantAmount,
 		ExpiresAtBlock: expiresAtBlock,
 		OfferAddress:   nodeWallet.Address,
+		AutoMatch:      autoMatch,
 	}
 
 	offerDataBytes, err := json.Marshal(offerData)
@@ -139,7 +148,7 @@ func CreateOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 }
 
 // CreateAcceptOfferTransaction creates a transaction that accepts and executes an atomic swap offer
-func CreateAcceptOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
+func CreateAcceptOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry,
 	offerTxID string, currentBlockHeight uint64) (*Transaction, error) {
 
 	// Get the offer transaction
@@ -253,7 +262,7 @@ func CreateAcceptOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeAcceptOffer)
+	txBuilder := NewTxBuilder(TxTypeAcceptOffer).SetRegistry(tokenRegistry)
 
 	// Add inputs (either tokens or SHADOW, depending on what we're trading)
 	for _, utxo := range selectedTokenUTXOs {
@@ -311,7 +320,7 @@ func CreateAcceptOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 }
 
 // CreateCancelOfferTransaction creates a transaction that cancels an offer and returns locked tokens
-func CreateCancelOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
+func CreateCancelOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore, tokenRegistry *TokenRegistry,
 	offerTxID string, currentBlockHeight uint64) (*Transaction, error) {
 
 	// Get the offer transaction
@@ -368,7 +377,7 @@ func CreateCancelOfferTransaction(nodeWallet *NodeWallet, utxoStore *UTXOStore,
 	}
 
 	// Build transaction
-	txBuilder := NewTxBuilder(TxTypeCancelOffer)
+	txBuilder := NewTxBuilder(TxTypeCancelOffer).SetRegistry(tokenRegistry)
 
 	// Add SHADOW inputs for fee
 	for _, utxo := range selectedShadowUTXOs {
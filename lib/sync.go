@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -19,36 +22,48 @@ const (
 
 // SyncRequest is sent to request blocks
 type SyncRequest struct {
-	Type       string `json:"type"`         // "height" or "blocks"
+	Type       string `json:"type"` // "height", "blocks", or "headers"
 	StartBlock uint64 `json:"start,omitempty"`
 	EndBlock   uint64 `json:"end,omitempty"`
 }
 
 // SyncResponse contains the response data
 type SyncResponse struct {
-	Type   string   `json:"type"`   // "height" or "blocks"
-	Height uint64   `json:"height,omitempty"`
-	Blocks []*Block `json:"blocks,omitempty"`
-	Error  string   `json:"error,omitempty"`
+	Type    string         `json:"type"` // "height", "blocks", or "headers"
+	Height  uint64         `json:"height,omitempty"`
+	Blocks  []*Block       `json:"blocks,omitempty"`
+	Headers []*BlockHeader `json:"headers,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Next    uint64         `json:"next,omitempty"` // "blocks": the range was cut short, continue from here
+
+	Transactions []*Transaction `json:"transactions,omitempty"` // "blocks": bodies of their transactions, unless pruned
 }
 
 // BlockSyncHandler handles incoming sync requests
 type BlockSyncHandler struct {
-	chain *Blockchain
+	chain  *Blockchain
+	server *syncServer // Serving limits and counters
 }
 
-// NewBlockSyncHandler creates a sync handler
-func NewBlockSyncHandler(chain *Blockchain) *BlockSyncHandler {
+// NewBlockSyncHandler creates a sync handler that serves within limits
+func NewBlockSyncHandler(chain *Blockchain, limits SyncServeLimits) *BlockSyncHandler {
 	return &BlockSyncHandler{
-		chain: chain,
+		chain:  chain,
+		server: newSyncServer(limits),
 	}
 }
 
 // SetupSyncProtocol registers the sync handler with libp2p
-func SetupSyncProtocol(h host.Host, chain *Blockchain) {
-	handler := NewBlockSyncHandler(chain)
+func SetupSyncProtocol(h host.Host, chain *Blockchain, limits SyncServeLimits) *BlockSyncHandler {
+	handler := NewBlockSyncHandler(chain, limits)
 	h.SetStreamHandler(SyncProtocolID, handler.HandleStream)
 	fmt.Printf("[Sync] Registered sync protocol handler\n")
+	return handler
+}
+
+// ServeStats returns the serving limits and what has been served to syncing peers
+func (h *BlockSyncHandler) ServeStats() SyncServeStats {
+	return h.server.Stats()
 }
 
 // HandleStream processes incoming sync requests
@@ -65,6 +80,18 @@ func (h *BlockSyncHandler) HandleStream(s network.Stream) {
 		return
 	}
 
+	// Block and header requests read from disk; hold them to the session limits
+	remote := s.Conn().RemotePeer()
+	out := io.Writer(s)
+	if req.Type == "blocks" || req.Type == "headers" {
+		if !h.server.acquire(remote, time.Now()) {
+			json.NewEncoder(s).Encode(SyncResponse{Type: req.Type, Error: syncBusyMessage})
+			return
+		}
+		defer h.server.release(remote)
+		out = h.server.writer(remote, s)
+	}
+
 	var resp SyncResponse
 
 	switch req.Type {
@@ -83,12 +110,42 @@ func (h *BlockSyncHandler) HandleStream(s network.Stream) {
 				Error: "invalid range: end < start",
 			}
 		} else {
+			end, next := h.server.clampBlocks(req.StartBlock, req.EndBlock)
+			blocks := h.chain.GetBlockRange(req.StartBlock, end)
+			if uint64(len(blocks)) < end-req.StartBlock+1 {
+				next = 0 // Ran past our tip; there is nothing more to page through
+			}
+			resp = SyncResponse{
+				Type:         "blocks",
+				Blocks:       blocks,
+				Next:         next,
+				Transactions: h.chain.storedBlockTxs(blocks),
+			}
+			h.server.served(len(blocks), 0)
+			fmt.Printf("[Sync] Serving blocks %d-%d to peer\n", req.StartBlock, end)
+		}
+
+	case "headers":
+		// Return headers for the requested range (capped to HeaderBatchSize)
+		if req.EndBlock < req.StartBlock {
+			resp = SyncResponse{
+				Type:  "headers",
+				Error: "invalid range: end < start",
+			}
+		} else {
+			if req.EndBlock-req.StartBlock >= HeaderBatchSize {
+				req.EndBlock = req.StartBlock + HeaderBatchSize - 1
+			}
 			blocks := h.chain.GetBlockRange(req.StartBlock, req.EndBlock)
+			headers := make([]*BlockHeader, len(blocks))
+			for i, block := range blocks {
+				headers[i] = block.Header()
+			}
 			resp = SyncResponse{
-				Type:   "blocks",
-				Blocks: blocks,
+				Type:    "headers",
+				Headers: headers,
 			}
-			fmt.Printf("[Sync] Serving blocks %d-%d to peer\n", req.StartBlock, req.EndBlock)
+			h.server.served(0, len(headers))
 		}
 
 	default:
@@ -98,8 +155,8 @@ func (h *BlockSyncHandler) HandleStream(s network.Stream) {
 		}
 	}
 
-	// Send response
-	encoder := json.NewEncoder(s)
+	// Send response, paced to the peer's bandwidth limit
+	encoder := json.NewEncoder(out)
 	if err := encoder.Encode(resp); err != nil {
 		fmt.Printf("[Sync] Failed to send response: %v\n", err)
 	}
@@ -107,8 +164,13 @@ func (h *BlockSyncHandler) HandleStream(s network.Stream) {
 
 // BlockSyncClient handles requesting blocks from peers
 type BlockSyncClient struct {
-	host  host.Host
-	chain *Blockchain
+	host     host.Host
+	chain    *Blockchain
+	progress syncProgress
+	scores   syncPeerScores // Misbehavior of peers we sync from
+
+	peerHeightsMu sync.Mutex
+	peerHeights   peerHeightCache // Last poll of peer tips, for readiness
 }
 
 // NewBlockSyncClient creates a sync client
@@ -148,8 +210,29 @@ func (c *BlockSyncClient) GetPeerHeight(peerID peer.ID) (uint64, error) {
 	return resp.Height, nil
 }
 
-// RequestBlocks requests a range of blocks from a peer
-func (c *BlockSyncClient) RequestBlocks(peerID peer.ID, start, end uint64) ([]*Block, error) {
+// RequestBlocks requests a range of blocks and their transactions from a peer,
+// following its pages when the peer caps the blocks per response
+func (c *BlockSyncClient) RequestBlocks(peerID peer.ID, start, end uint64) ([]*Block, []*Transaction, error) {
+	var blocks []*Block
+	var txs []*Transaction
+	for {
+		resp, err := c.request(peerID, SyncRequest{Type: "blocks", StartBlock: start, EndBlock: end})
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, resp.Blocks...)
+		txs = append(txs, resp.Transactions...)
+
+		// Only follow pages that move forward, so a peer can't keep us looping
+		if resp.Next <= start || resp.Next > end || len(resp.Blocks) == 0 {
+			return blocks, txs, nil
+		}
+		start = resp.Next
+	}
+}
+
+// request sends a sync request to a peer and reads the response
+func (c *BlockSyncClient) request(peerID peer.ID, req SyncRequest) (*SyncResponse, error) {
 	s, err := c.host.NewStream(context.Background(), peerID, SyncProtocolID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open stream: %w", err)
@@ -157,11 +240,6 @@ func (c *BlockSyncClient) RequestBlocks(peerID peer.ID, start, end uint64) ([]*B
 	defer s.Close()
 
 	// Send request
-	req := SyncRequest{
-		Type:       "blocks",
-		StartBlock: start,
-		EndBlock:   end,
-	}
 	encoder := json.NewEncoder(s)
 	if err := encoder.Encode(req); err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -177,11 +255,14 @@ func (c *BlockSyncClient) RequestBlocks(peerID peer.ID, start, end uint64) ([]*B
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if resp.Error == syncBusyMessage {
+		return nil, ErrSyncServerBusy
+	}
 	if resp.Error != "" {
 		return nil, fmt.Errorf("peer error: %s", resp.Error)
 	}
 
-	return resp.Blocks, nil
+	return &resp, nil
 }
 
 // SyncFromPeer syncs the blockchain from a peer
@@ -206,24 +287,45 @@ func (c *BlockSyncClient) SyncFromPeer(peerID peer.ID) error {
 	blocksNeeded := peerHeight - myHeight
 	fmt.Printf("[Sync] Need to download %d blocks\n", blocksNeeded)
 
-	for start := myHeight + 1; start <= peerHeight; start += BlockBatchSize {
+	c.progress.start(myHeight, peerHeight, 1)
+	if err := c.syncRangeFromPeer(peerID, myHeight+1, peerHeight); err != nil {
+		c.progress.finish(err)
+		return err
+	}
+	c.progress.finish(nil)
+
+	fmt.Printf("[Sync] ✓ Sync complete! Chain height now: %d\n", c.chain.GetHeight())
+	return nil
+}
+
+// syncRangeFromPeer downloads and applies [from, to] sequentially from a single peer
+func (c *BlockSyncClient) syncRangeFromPeer(peerID peer.ID, from, to uint64) error {
+	for start := from; start <= to; start += BlockBatchSize {
 		end := start + BlockBatchSize - 1
-		if end > peerHeight {
-			end = peerHeight
+		if end > to {
+			end = to
 		}
 
 		fmt.Printf("[Sync] Requesting blocks %d-%d...\n", start, end)
 
-		blocks, err := c.RequestBlocks(peerID, start, end)
+		blocks, txs, err := c.RequestBlocks(peerID, start, end)
 		if err != nil {
-			return fmt.Errorf("failed to get blocks %d-%d: %w", start, end, err)
+			return c.penalizePeer(peerID, false, fmt.Errorf("failed to get blocks %d-%d: %w", start, end, err))
 		}
 
-		if len(blocks) == 0 {
-			return fmt.Errorf("peer returned no blocks for range %d-%d", start, end)
+		// Check the batch is complete, linked to our chain and carries valid proofs
+		if err := c.VerifyBlockRange(blocks, start, end); err != nil {
+			return c.penalizePeer(peerID, true, err)
+		}
+		if prev := c.chain.GetBlock(start - 1); prev == nil || blocks[0].PreviousHash != prev.Hash {
+			return c.penalizePeer(peerID, true, fmt.Errorf("block %d does not link to our chain", start))
 		}
 
+		// Verify the batch's signatures on all CPUs up front
+		c.chain.PreverifyBlockSignatures(blocks)
+
 		// Add blocks to our chain
+		bodies := blockTxMempool(txs)
 		for _, block := range blocks {
 			// Check if we already have this block (could have arrived via consensus during sync)
 			currentHeight := c.chain.GetHeight() - 1 // Convert to block index
@@ -231,8 +333,13 @@ func (c *BlockSyncClient) SyncFromPeer(peerID peer.ID) error {
 				continue
 			}
 
-			if err := c.chain.AddBlock(block, nil); err != nil {
-				return fmt.Errorf("failed to add block %d: %w", block.Index, err)
+			if err := c.chain.AddBlock(block, bodies); err != nil {
+				err = fmt.Errorf("failed to add block %d: %w", block.Index, err)
+				if errors.Is(err, ErrBlockInvalid) {
+					c.chain.Evidence().RecordBlock(EvidenceSourceSync, peerID.String(), block, err)
+					return c.penalizePeer(peerID, true, err)
+				}
+				return err
 			}
 
 			// Only log every 100 blocks during sync
@@ -240,39 +347,75 @@ func (c *BlockSyncClient) SyncFromPeer(peerID peer.ID) error {
 				fmt.Printf("[Sync] Progress: block %d (hash: %s)\n", block.Index, block.Hash[:16])
 			}
 		}
+		c.progress.update(c.chain.GetHeight() - 1)
 	}
 
-	fmt.Printf("[Sync] ✓ Sync complete! Chain height now: %d\n", c.chain.GetHeight())
 	return nil
 }
 
-// SyncFromBestPeer finds the best peer and syncs from them
+// SyncFromBestPeer finds the best peers and syncs from them, moving on to the next-best
+// peer whenever one sends invalid data
 func (c *BlockSyncClient) SyncFromBestPeer() error {
 	peers := c.host.Network().Peers()
 	if len(peers) == 0 {
 		return fmt.Errorf("no peers available for sync")
 	}
 
-	// Try to find peer with highest height
-	var bestPeer peer.ID
-	var bestHeight uint64
+	// Collect the heights of peers that aren't banned
+	peerHeights := make(map[peer.ID]uint64)
+	myHeight := c.chain.GetHeight() - 1
+	responded := 0
 
 	for _, p := range peers {
+		if c.scores.banned(p, time.Now()) {
+			continue
+		}
 		height, err := c.GetPeerHeight(p)
 		if err != nil {
 			fmt.Printf("[Sync] Failed to get height from %s: %v\n", p.String()[:16], err)
 			continue
 		}
-
-		if height > bestHeight {
-			bestHeight = height
-			bestPeer = p
-		}
+		responded++
+		peerHeights[p] = height
 	}
 
-	if bestPeer == "" {
+	if responded == 0 {
 		return fmt.Errorf("no peers responded with height")
 	}
+	ranked := c.rankSyncPeers(peerHeights, myHeight)
+	if len(ranked) == 0 {
+		fmt.Printf("[Sync] Already synced (my: %d)\n", myHeight)
+		return nil
+	}
+
+	// Validate headers first, then download bodies in parallel. A peer caught sending
+	// invalid data is dropped and headers-first retried without it; peers that don't
+	// serve headers yet make it fail and we fall back to sequential sync.
+	ahead := make(map[peer.ID]uint64)
+	for _, p := range ranked {
+		ahead[p] = peerHeights[p]
+	}
+	for len(ahead) > 0 {
+		err := c.HeadersFirstSync(ahead)
+		if err == nil {
+			return nil
+		}
+		var bad *badPeerError
+		if !errors.As(err, &bad) {
+			fmt.Printf("[Sync] Headers-first sync failed, falling back to sequential sync: %v\n", err)
+			break
+		}
+		fmt.Printf("[Sync] Headers-first sync failed, retrying without %s: %v\n", shortPeerID(bad.peer), err)
+		delete(ahead, bad.peer)
+	}
 
-	return c.SyncFromPeer(bestPeer)
+	// Sequential sync from each peer in turn, tallest first
+	var lastErr error
+	for _, p := range c.rankSyncPeers(peerHeights, c.chain.GetHeight()-1) {
+		if lastErr = c.SyncFromPeer(p); lastErr == nil {
+			return nil
+		}
+		fmt.Printf("[Sync] Sync from %s failed, trying the next-best peer: %v\n", shortPeerID(p), lastErr)
+	}
+	return lastErr
 }
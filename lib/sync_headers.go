@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// HeaderBatchSize is the maximum number of headers served per request
+const HeaderBatchSize = 500
+
+// BlockHeader is everything needed to check a block's place in the chain: the fields
+// covered by the block hash plus the winning proof. Coinbase and votes are body data.
+type BlockHeader struct {
+	Index        uint64        `json:"index"`
+	Timestamp    int64         `json:"timestamp"`
+	Transactions []string      `json:"transactions"`
+	PreviousHash string        `json:"previous_hash"`
+	Hash         string        `json:"hash"`
+	Proposer     string        `json:"proposer"`
+	WinningProof *ProofOfSpace `json:"winning_proof,omitempty"`
+}
+
+// Header returns the block's header
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		Index:        b.Index,
+		Timestamp:    b.Timestamp,
+		Transactions: b.Transactions,
+		PreviousHash: b.PreviousHash,
+		Hash:         b.Hash,
+		Proposer:     b.Proposer,
+		WinningProof: b.WinningProof,
+	}
+}
+
+// hashableBlock returns a block carrying the header's hashed fields
+func (h *BlockHeader) hashableBlock() *Block {
+	return &Block{
+		Index:        h.Index,
+		Timestamp:    h.Timestamp,
+		Transactions: h.Transactions,
+		PreviousHash: h.PreviousHash,
+		Proposer:     h.Proposer,
+	}
+}
+
+// RequestHeaders requests a range of headers from a peer (peers cap it at HeaderBatchSize)
+func (c *BlockSyncClient) RequestHeaders(peerID peer.ID, start, end uint64) ([]*BlockHeader, error) {
+	resp, err := c.request(peerID, SyncRequest{Type: "headers", StartBlock: start, EndBlock: end})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Headers, nil
+}
+
+// VerifyHeaderChain checks that headers start at start, link to prevHash and to each
+// other, and carry correct hashes. Proofs are checked separately by VerifyHeaderProofs.
+func (c *BlockSyncClient) VerifyHeaderChain(headers []*BlockHeader, start uint64, prevHash string) error {
+	for i, header := range headers {
+		if header == nil {
+			return fmt.Errorf("nil header at offset %d", i)
+		}
+		if header.Index != start+uint64(i) {
+			return fmt.Errorf("header at offset %d has index %d, expected %d", i, header.Index, start+uint64(i))
+		}
+		if header.PreviousHash != prevHash {
+			return fmt.Errorf("header %d does not link to previous block", header.Index)
+		}
+		if header.Hash != c.chain.calculateBlockHash(header.hashableBlock()) {
+			return fmt.Errorf("header %d hash mismatch", header.Index)
+		}
+		prevHash = header.Hash
+	}
+	return nil
+}
+
+// VerifyHeaderProofs validates the winning proofs of a header batch across all CPUs.
+// Headers without a proof (pruned by the serving peer) are accepted.
+func VerifyHeaderProofs(headers []*BlockHeader) error {
+	jobs := make(chan *BlockHeader)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for header := range jobs {
+				if !ValidateProofOfSpace(header.WinningProof) {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("header %d has an invalid proof of space", header.Index)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, header := range headers {
+		if header.WinningProof != nil {
+			jobs <- header
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// HeadersFirstSync downloads and validates the header chain from the tallest peer, then
+// fetches block bodies in parallel from all peers ahead of us, checks them against the
+// validated headers, and applies them with batched database writes.
+func (c *BlockSyncClient) HeadersFirstSync(peerHeights map[peer.ID]uint64) error {
+	latest := c.chain.GetLatestBlock()
+	myHeight := latest.Index
+
+	peers, targetHeight := selectSyncPeers(peerHeights, myHeight)
+	if len(peers) == 0 {
+		fmt.Printf("[Sync] Already synced (my: %d)\n", myHeight)
+		return nil
+	}
+
+	// The tallest peer serves headers
+	headerPeer := peers[0]
+	for _, p := range peers {
+		if peerHeights[p] > peerHeights[headerPeer] {
+			headerPeer = p
+		}
+	}
+
+	fmt.Printf("[Sync] Headers-first sync from %d peers (my: %d, target: %d)\n", len(peers), myHeight, targetHeight)
+	c.progress.start(myHeight, targetHeight, len(peers))
+	c.progress.setPhase(SyncPhaseHeaders)
+
+	hashes, err := c.fetchHeaders(headerPeer, myHeight+1, targetHeight, latest.Hash)
+	if err != nil {
+		c.progress.finish(err)
+		return err
+	}
+	if len(hashes) == 0 {
+		err := fmt.Errorf("peer %s returned no headers", headerPeer.String()[:16])
+		c.progress.finish(err)
+		return err
+	}
+	targetHeight = myHeight + uint64(len(hashes))
+	fmt.Printf("[Sync] ✓ Validated %d headers, downloading blocks\n", len(hashes))
+
+	// Bodies must match the validated header chain exactly
+	from := myHeight + 1
+	verify := func(blocks []*Block, start, end uint64) error {
+		if err := c.VerifyBlockRange(blocks, start, end); err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			if block.Hash != hashes[block.Index-from] {
+				return fmt.Errorf("block %d does not match validated header", block.Index)
+			}
+		}
+		return nil
+	}
+
+	c.progress.setPhase(SyncPhaseBlocks)
+	err = c.downloadAndApply(peers, peerHeights, from, targetHeight, verify, c.applyBlocksBatch)
+	c.progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[Sync] ✓ Headers-first sync complete! Chain height now: %d\n", c.chain.GetHeight())
+	return nil
+}
+
+// fetchHeaders downloads and validates headers [from, to] from one peer, returning the
+// validated block hashes in order. Stops early if the peer has fewer headers than asked.
+func (c *BlockSyncClient) fetchHeaders(p peer.ID, from, to uint64, prevHash string) ([]string, error) {
+	hashes := make([]string, 0, to-from+1)
+
+	for start := from; start <= to; {
+		end := start + HeaderBatchSize - 1
+		if end > to {
+			end = to
+		}
+
+		headers, err := c.RequestHeaders(p, start, end)
+		if err != nil {
+			return nil, c.penalizePeer(p, false, fmt.Errorf("failed to get headers %d-%d: %w", start, end, err))
+		}
+		if len(headers) == 0 {
+			break
+		}
+
+		if err := c.VerifyHeaderChain(headers, start, prevHash); err != nil {
+			return nil, c.penalizePeer(p, true, fmt.Errorf("invalid headers: %w", err))
+		}
+		if err := VerifyHeaderProofs(headers); err != nil {
+			return nil, c.penalizePeer(p, true, fmt.Errorf("invalid headers: %w", err))
+		}
+
+		for _, header := range headers {
+			hashes = append(hashes, header.Hash)
+		}
+		prevHash = headers[len(headers)-1].Hash
+		start = headers[len(headers)-1].Index + 1
+		c.progress.headers(start - 1)
+	}
+
+	return hashes, nil
+}
+
+// applyBlocksBatch adds an in-order batch of blocks, with the bodies of their
+// transactions, using batched database writes
+func (c *BlockSyncClient) applyBlocksBatch(blocks []*Block, txs []*Transaction) error {
+	// Skip blocks that arrived via consensus during sync
+	currentHeight := c.chain.GetHeight() - 1
+	for len(blocks) > 0 && blocks[0].Index <= currentHeight {
+		blocks = blocks[1:]
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	return c.chain.AddBlocksBatch(blocks, blockTxMempool(txs))
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyHeaderChain(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	c := NewBlockSyncClient(nil, bc)
+
+	headers := func() []*BlockHeader {
+		blocks := buildTestRange(bc, 5, 4)
+		headers := make([]*BlockHeader, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		return headers
+	}
+
+	if err := c.VerifyHeaderChain(headers(), 5, "prev"); err != nil {
+		t.Fatalf("Valid headers rejected: %v", err)
+	}
+
+	// Must link to the local tip
+	if err := c.VerifyHeaderChain(headers(), 5, "other"); err == nil {
+		t.Fatal("Expected headers not extending our tip to be rejected")
+	}
+
+	// Must start where we asked
+	if err := c.VerifyHeaderChain(headers(), 6, "prev"); err == nil {
+		t.Fatal("Expected misaligned headers to be rejected")
+	}
+
+	// Tampered tx list changes the hash
+	tampered := headers()
+	tampered[1].Transactions = []string{"injected"}
+	if err := c.VerifyHeaderChain(tampered, 5, "prev"); err == nil {
+		t.Fatal("Expected tampered header to be rejected")
+	}
+}
+
+func TestAddBlocksBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	prev := bc.GetLatestBlock()
+	var blocks []*Block
+	for i := 0; i < 3; i++ {
+		coinbase := &Transaction{
+			Version:   1,
+			TxType:    TxTypeCoinbase,
+			Outputs:   []*TxOutput{CreateShadowOutput(kp.Address(), 100)},
+			Timestamp: int64(i),
+		}
+		block := &Block{
+			Index:        prev.Index + 1,
+			Timestamp:    prev.Timestamp + 1,
+			Coinbase:     coinbase,
+			PreviousHash: prev.Hash,
+			Proposer:     "batch-test-proposer",
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		blocks = append(blocks, block)
+		prev = block
+	}
+
+	if err := bc.AddBlocksBatch(blocks, nil); err != nil {
+		t.Fatalf("Batch add failed: %v", err)
+	}
+	if bc.GetHeight() != 4 {
+		t.Fatalf("Expected height 4, got %d", bc.GetHeight())
+	}
+
+	// Everything is committed and survives a reopen
+	bc.Close()
+	bc, err = NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen chain: %v", err)
+	}
+	defer bc.Close()
+
+	if bc.GetHeight() != 4 {
+		t.Fatalf("Expected height 4 after reopen, got %d", bc.GetHeight())
+	}
+	utxos, err := bc.GetUTXOStore().GetUTXOsByAddress(kp.Address())
+	if err != nil || len(utxos) != 3 {
+		t.Fatalf("Expected 3 coinbase UTXOs after reopen, got %d (%v)", len(utxos), err)
+	}
+}
@@ -0,0 +1,331 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	MaxSyncPeers     = 8 // Maximum peers downloading ranges concurrently
+	MaxRangeAttempts = 3 // Attempts per range before sync gives up
+	MaxPeerFailures  = 3 // Consecutive failures before a peer is dropped from the sync
+
+	SyncPhaseHeaders = "headers"
+	SyncPhaseBlocks  = "blocks"
+)
+
+// SyncStatus reports sync progress for the API
+type SyncStatus struct {
+	Syncing       bool    `json:"syncing"`
+	Phase         string  `json:"phase,omitempty"` // "headers" or "blocks" while syncing
+	HeadersHeight uint64  `json:"headers_height,omitempty"`
+	StartHeight   uint64  `json:"start_height"`
+	CurrentHeight uint64  `json:"current_height"`
+	TargetHeight  uint64  `json:"target_height"`
+	Peers         int     `json:"peers"`
+	BlocksPerSec  float64 `json:"blocks_per_sec"`
+	ETASeconds    int64   `json:"eta_seconds"`
+	StartedAt     int64   `json:"started_at"`
+	FinishedAt    int64   `json:"finished_at,omitempty"`
+	LastError     string  `json:"last_error,omitempty"`
+
+	PeerScores []SyncPeerScore `json:"peer_scores,omitempty"` // Peers that misbehaved, worst first
+}
+
+// syncProgress tracks the current sync for status reporting
+type syncProgress struct {
+	mu     sync.RWMutex
+	status SyncStatus
+}
+
+// start marks the beginning of a sync run
+func (p *syncProgress) start(startHeight, targetHeight uint64, peers int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = SyncStatus{
+		Syncing:       true,
+		StartHeight:   startHeight,
+		CurrentHeight: startHeight,
+		TargetHeight:  targetHeight,
+		Phase:         SyncPhaseBlocks,
+		Peers:         peers,
+		StartedAt:     time.Now().Unix(),
+	}
+}
+
+// update records the latest applied height and recomputes rate and ETA
+func (p *syncProgress) update(currentHeight uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.CurrentHeight = currentHeight
+
+	elapsed := time.Since(time.Unix(p.status.StartedAt, 0)).Seconds()
+	done := currentHeight - p.status.StartHeight
+	if elapsed > 0 && done > 0 {
+		p.status.BlocksPerSec = float64(done) / elapsed
+		remaining := p.status.TargetHeight - currentHeight
+		p.status.ETASeconds = int64(float64(remaining) / p.status.BlocksPerSec)
+	}
+}
+
+// setPhase records the current sync phase
+func (p *syncProgress) setPhase(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Phase = phase
+}
+
+// headers records the height of the validated header chain
+func (p *syncProgress) headers(height uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.HeadersHeight = height
+}
+
+// finish marks the sync run as complete (err may be nil)
+func (p *syncProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Syncing = false
+	p.status.Phase = ""
+	p.status.ETASeconds = 0
+	p.status.FinishedAt = time.Now().Unix()
+	if err != nil {
+		p.status.LastError = err.Error()
+	}
+}
+
+// snapshot returns a copy of the current status
+func (p *syncProgress) snapshot() SyncStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// syncRange is a contiguous block range to download
+type syncRange struct {
+	start    uint64
+	end      uint64
+	attempts int
+}
+
+// syncResult is a downloaded and verified range
+type syncResult struct {
+	start  uint64
+	blocks []*Block
+	txs    []*Transaction // Bodies of the blocks' transactions
+	peer   peer.ID        // Who served it, blamed if it fails to apply
+}
+
+// VerifyBlockRange checks that a downloaded range is complete, ordered, self-consistent,
+// internally linked and carries valid proofs of space. Linking to the local tip is
+// checked when the range is applied.
+func (c *BlockSyncClient) VerifyBlockRange(blocks []*Block, start, end uint64) error {
+	expected := end - start + 1
+	if uint64(len(blocks)) != expected {
+		return fmt.Errorf("expected %d blocks for range %d-%d, got %d", expected, start, end, len(blocks))
+	}
+
+	for i, block := range blocks {
+		if block == nil {
+			return fmt.Errorf("nil block at offset %d", i)
+		}
+		if block.Index != start+uint64(i) {
+			return fmt.Errorf("block at offset %d has index %d, expected %d", i, block.Index, start+uint64(i))
+		}
+		if block.Hash != c.chain.calculateBlockHash(block) {
+			return fmt.Errorf("block %d hash mismatch", block.Index)
+		}
+		if i > 0 && block.PreviousHash != blocks[i-1].Hash {
+			return fmt.Errorf("block %d does not link to block %d", block.Index, blocks[i-1].Index)
+		}
+	}
+
+	// Proofs aren't covered by the block hash, so check the ones the bodies carry
+	headers := make([]*BlockHeader, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	return VerifyHeaderProofs(headers)
+}
+
+// selectSyncPeers returns up to MaxSyncPeers peers ahead of myHeight and the best height among them
+func selectSyncPeers(peerHeights map[peer.ID]uint64, myHeight uint64) ([]peer.ID, uint64) {
+	var targetHeight uint64
+	var peers []peer.ID
+	for p, h := range peerHeights {
+		if h <= myHeight {
+			continue
+		}
+		peers = append(peers, p)
+		if h > targetHeight {
+			targetHeight = h
+		}
+		if len(peers) >= MaxSyncPeers {
+			break
+		}
+	}
+	return peers, targetHeight
+}
+
+// recordRejectedBlock records the block of a range AddBlock rejected as evidence. Blocks
+// are added in order and adding stops at the first rejected, so it is the one after the
+// tip.
+func (c *BlockSyncClient) recordRejectedBlock(from peer.ID, blocks []*Block, err error) {
+	next := c.chain.GetHeight()
+	for _, block := range blocks {
+		if block.Index == next {
+			c.chain.Evidence().RecordBlock(EvidenceSourceSync, from.String(), block, err)
+			return
+		}
+	}
+}
+
+// rangeVerifier checks a downloaded range before it is accepted
+type rangeVerifier func(blocks []*Block, start, end uint64) error
+
+// downloadAndApply fetches [from, to] in BlockBatchSize ranges across peers, checks each
+// with verify, and hands verified ranges to apply in ascending order
+func (c *BlockSyncClient) downloadAndApply(peers []peer.ID, peerHeights map[peer.ID]uint64,
+	from, to uint64, verify rangeVerifier, apply func([]*Block, []*Transaction) error) error {
+
+	// Every range lives in exactly one place (queue, in flight, or results), so a queue
+	// sized to the range count never blocks on requeue
+	var ranges []syncRange
+	for start := from; start <= to; start += BlockBatchSize {
+		end := start + BlockBatchSize - 1
+		if end > to {
+			end = to
+		}
+		ranges = append(ranges, syncRange{start: start, end: end})
+	}
+
+	queue := make(chan syncRange, len(ranges))
+	for _, r := range ranges {
+		queue <- r
+	}
+
+	results := make(chan syncResult, len(peers)*2)
+	fatal := make(chan error, len(peers))
+	done := make(chan struct{})
+	workersGone := make(chan struct{})
+	var liveWorkers atomic.Int32
+	liveWorkers.Store(int32(len(peers)))
+
+	defer close(done)
+
+	for _, p := range peers {
+		go c.rangeWorker(p, peerHeights[p], verify, queue, results, fatal, done, &liveWorkers, workersGone)
+	}
+
+	// Reassemble: buffer out-of-order ranges and apply as soon as the next one is present
+	pending := make(map[uint64]syncResult)
+	next := from
+	for next <= to {
+		select {
+		case res := <-results:
+			pending[res.start] = res
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				blocks := ready.blocks
+				if err := apply(blocks, ready.txs); err != nil {
+					if errors.Is(err, ErrBlockInvalid) {
+						c.recordRejectedBlock(ready.peer, blocks, err)
+						return c.penalizePeer(ready.peer, true, err)
+					}
+					return err
+				}
+				next = blocks[len(blocks)-1].Index + 1
+				c.progress.update(next - 1)
+				fmt.Printf("[Sync] Progress: block %d/%d\n", next-1, to)
+			}
+		case err := <-fatal:
+			return err
+		case <-workersGone:
+			return fmt.Errorf("all sync peers failed or fell behind at block %d", next)
+		}
+	}
+
+	return nil
+}
+
+// rangeWorker downloads ranges from one peer until the queue is drained or the peer fails
+func (c *BlockSyncClient) rangeWorker(p peer.ID, peerHeight uint64, verify rangeVerifier, queue chan syncRange,
+	results chan<- syncResult, fatal chan<- error, done <-chan struct{},
+	liveWorkers *atomic.Int32, workersGone chan<- struct{}) {
+
+	defer func() {
+		if liveWorkers.Add(-1) == 0 {
+			close(workersGone)
+		}
+	}()
+
+	failures := 0
+	for {
+		var r syncRange
+		select {
+		case r = <-queue:
+		case <-done:
+			return
+		}
+
+		// This peer can't serve the range; leave it for a taller peer
+		if r.end > peerHeight {
+			queue <- r
+			return
+		}
+
+		blocks, txs, err := c.RequestBlocks(p, r.start, r.end)
+		if err != nil {
+			c.penalizePeer(p, false, err)
+		} else if err = verify(blocks, r.start, r.end); err != nil {
+			c.penalizePeer(p, true, err)
+		}
+
+		if err != nil {
+			fmt.Printf("[Sync] Range %d-%d from %s failed: %v\n", r.start, r.end, p.String()[:16], err)
+			r.attempts++
+			if r.attempts >= MaxRangeAttempts {
+				select {
+				case fatal <- fmt.Errorf("range %d-%d failed after %d attempts: %w", r.start, r.end, r.attempts, err):
+				default:
+				}
+				return
+			}
+			queue <- r
+
+			failures++
+			if failures >= MaxPeerFailures || c.scores.banned(p, time.Now()) {
+				fmt.Printf("[Sync] Dropping peer %s after %d failures\n", p.String()[:16], failures)
+				return
+			}
+			continue
+		}
+
+		failures = 0
+		select {
+		case results <- syncResult{start: r.start, blocks: blocks, txs: txs, peer: p}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Status returns the current sync progress
+func (c *BlockSyncClient) Status() SyncStatus {
+	status := c.progress.snapshot()
+	if !status.Syncing {
+		status.CurrentHeight = c.chain.GetHeight() - 1
+	}
+	status.PeerScores = c.scores.snapshot()
+	return status
+}
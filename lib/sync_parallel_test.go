@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTestRange creates n linked blocks starting at index start
+func buildTestRange(bc *Blockchain, start uint64, n int) []*Block {
+	blocks := make([]*Block, n)
+	prevHash := "prev"
+	for i := range blocks {
+		block := &Block{
+			Index:        start + uint64(i),
+			Timestamp:    int64(1000 + i),
+			PreviousHash: prevHash,
+			Proposer:     "test",
+		}
+		block.Hash = bc.calculateBlockHash(block)
+		prevHash = block.Hash
+		blocks[i] = block
+	}
+	return blocks
+}
+
+func TestVerifyBlockRange(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	c := NewBlockSyncClient(nil, bc)
+
+	if err := c.VerifyBlockRange(buildTestRange(bc, 10, 5), 10, 14); err != nil {
+		t.Fatalf("Valid range rejected: %v", err)
+	}
+
+	// Short range
+	if err := c.VerifyBlockRange(buildTestRange(bc, 10, 4), 10, 14); err == nil {
+		t.Fatal("Expected short range to be rejected")
+	}
+
+	// Wrong starting index
+	if err := c.VerifyBlockRange(buildTestRange(bc, 11, 5), 10, 14); err == nil {
+		t.Fatal("Expected misaligned range to be rejected")
+	}
+
+	// Tampered block
+	blocks := buildTestRange(bc, 10, 5)
+	blocks[2].Proposer = "evil"
+	if err := c.VerifyBlockRange(blocks, 10, 14); err == nil {
+		t.Fatal("Expected tampered block to be rejected")
+	}
+
+	// Broken linkage (re-hashed so only the link is wrong)
+	blocks = buildTestRange(bc, 10, 5)
+	blocks[3].PreviousHash = "other"
+	blocks[3].Hash = bc.calculateBlockHash(blocks[3])
+	if err := c.VerifyBlockRange(blocks, 10, 14); err == nil {
+		t.Fatal("Expected unlinked range to be rejected")
+	}
+
+	// Bogus proof (not covered by the hash)
+	blocks = buildTestRange(bc, 10, 5)
+	blocks[1].WinningProof = &ProofOfSpace{Distance: 1}
+	if err := c.VerifyBlockRange(blocks, 10, 14); err == nil {
+		t.Fatal("Expected an invalid proof to be rejected")
+	}
+}
+
+func TestSyncProgress(t *testing.T) {
+	var p syncProgress
+	p.start(100, 1100, 3)
+	p.status.StartedAt = time.Now().Add(-10 * time.Second).Unix()
+
+	p.update(600)
+	status := p.snapshot()
+	if !status.Syncing || status.Peers != 3 {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+	if status.BlocksPerSec < 40 || status.BlocksPerSec > 60 {
+		t.Fatalf("Expected ~50 blocks/sec, got %f", status.BlocksPerSec)
+	}
+	if status.ETASeconds < 8 || status.ETASeconds > 12 {
+		t.Fatalf("Expected ~10s ETA, got %d", status.ETASeconds)
+	}
+
+	p.finish(nil)
+	status = p.snapshot()
+	if status.Syncing || status.ETASeconds != 0 || status.FinishedAt == 0 {
+		t.Fatalf("Unexpected finished status: %+v", status)
+	}
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Sync trusts no peer: everything downloaded is checked against the header chain and
+// proof rules before it is applied. Peers are scored on what they send. Invalid data
+// costs far more than a failed request, and a peer reaching SyncBanScore is skipped by
+// sync and disconnected for SyncBanDuration, so the next-best peer takes over.
+
+const (
+	SyncPenaltyInvalid = 50               // Invalid headers, blocks or proofs
+	SyncPenaltyFailure = 10               // Failed requests and short responses
+	SyncBanScore       = 100              // Peers reaching this are banned from sync
+	SyncBanDuration    = 30 * time.Minute // How long a banned peer is skipped
+)
+
+// SyncPeerScore is a peer's sync misbehavior record
+type SyncPeerScore struct {
+	Peer        string `json:"peer"`
+	Score       int    `json:"score"`
+	BannedUntil int64  `json:"banned_until,omitempty"` // Unix seconds
+	LastReason  string `json:"last_reason,omitempty"`
+}
+
+// syncPeerScores tracks how peers behave during sync
+type syncPeerScores struct {
+	mu     sync.Mutex
+	scores map[peer.ID]*SyncPeerScore
+}
+
+// badPeerError is a sync failure caused by invalid data from a peer
+type badPeerError struct {
+	peer peer.ID
+	err  error
+}
+
+func (e *badPeerError) Error() string {
+	return fmt.Sprintf("invalid data from %s: %v", shortPeerID(e.peer), e.err)
+}
+
+func (e *badPeerError) Unwrap() error { return e.err }
+
+// shortPeerID abbreviates a peer ID for logs
+func shortPeerID(p peer.ID) string {
+	if s := p.String(); len(s) > 16 {
+		return s[:16]
+	}
+	return p.String()
+}
+
+// penalize adds points to a peer's score; returns whether the peer is now banned
+func (s *syncPeerScores) penalize(p peer.ID, points int, reason string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scores == nil {
+		s.scores = make(map[peer.ID]*SyncPeerScore)
+	}
+	score, ok := s.scores[p]
+	if !ok {
+		score = &SyncPeerScore{Peer: p.String()}
+		s.scores[p] = score
+	}
+	score.Score += points
+	score.LastReason = reason
+	if score.Score < SyncBanScore {
+		return false
+	}
+
+	// Serve out the ban with a clean slate
+	score.Score = 0
+	score.BannedUntil = now.Add(SyncBanDuration).Unix()
+	return true
+}
+
+// banned reports whether a peer is currently banned from sync
+func (s *syncPeerScores) banned(p peer.ID, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score, ok := s.scores[p]
+	return ok && now.Unix() < score.BannedUntil
+}
+
+// snapshot returns every scored peer, worst first
+func (s *syncPeerScores) snapshot() []SyncPeerScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scores := make([]SyncPeerScore, 0, len(s.scores))
+	for _, score := range s.scores {
+		scores = append(scores, *score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].BannedUntil != scores[j].BannedUntil {
+			return scores[i].BannedUntil > scores[j].BannedUntil
+		}
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+// penalizePeer scores a peer for a failed request, or for invalid data when invalid is
+// set, and disconnects it if that bans it. Returns err, blamed on the peer if invalid.
+// A peer turning us away at its session limits is not scored.
+func (c *BlockSyncClient) penalizePeer(p peer.ID, invalid bool, err error) error {
+	if errors.Is(err, ErrSyncServerBusy) {
+		return err // The peer is protecting itself, not misbehaving
+	}
+	points := SyncPenaltyFailure
+	if invalid {
+		points = SyncPenaltyInvalid
+	}
+	if c.scores.penalize(p, points, err.Error(), time.Now()) {
+		fmt.Printf("[Sync] 🚫 Banning peer %s for %v: %v\n", shortPeerID(p), SyncBanDuration, err)
+		if c.host != nil {
+			c.host.Network().ClosePeer(p)
+		}
+	}
+	if invalid {
+		return &badPeerError{peer: p, err: err}
+	}
+	return err
+}
+
+// PeerScores returns the sync scores of peers that have misbehaved
+func (c *BlockSyncClient) PeerScores() []SyncPeerScore {
+	return c.scores.snapshot()
+}
+
+// rankSyncPeers returns the peers ahead of myHeight that aren't banned, tallest first
+func (c *BlockSyncClient) rankSyncPeers(peerHeights map[peer.ID]uint64, myHeight uint64) []peer.ID {
+	now := time.Now()
+	var ranked []peer.ID
+	for p, h := range peerHeights {
+		if h > myHeight && !c.scores.banned(p, now) {
+			ranked = append(ranked, p)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if peerHeights[ranked[i]] != peerHeights[ranked[j]] {
+			return peerHeights[ranked[i]] > peerHeights[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	return ranked
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSyncPeerScores(t *testing.T) {
+	c := NewBlockSyncClient(nil, nil)
+	honest, flaky, liar := peer.ID("honest-peer-0000"), peer.ID("flaky-peer-00000"), peer.ID("lying-peer-00000")
+	heights := map[peer.ID]uint64{honest: 90, flaky: 120, liar: 150}
+
+	// Failed requests cost little; invalid data is blamed on the peer
+	if err := c.penalizePeer(flaky, false, fmt.Errorf("timeout")); errors.As(err, new(*badPeerError)) {
+		t.Error("Expected a failed request not to be blamed as invalid data")
+	}
+	err := c.penalizePeer(liar, true, fmt.Errorf("block 120 hash mismatch"))
+	var bad *badPeerError
+	if !errors.As(err, &bad) || bad.peer != liar {
+		t.Fatalf("Expected invalid data blamed on the liar, got %v", err)
+	}
+
+	ranked := c.rankSyncPeers(heights, 100)
+	if len(ranked) != 2 || ranked[0] != liar || ranked[1] != flaky {
+		t.Fatalf("Expected peers ahead of us tallest first, got %v", ranked)
+	}
+
+	// A second offense bans the liar and the next-best peer leads
+	c.penalizePeer(liar, true, fmt.Errorf("invalid proof"))
+	ranked = c.rankSyncPeers(heights, 100)
+	if len(ranked) != 1 || ranked[0] != flaky {
+		t.Fatalf("Expected the banned peer skipped, got %v", ranked)
+	}
+
+	scores := c.PeerScores()
+	if len(scores) != 2 || scores[0].Peer != liar.String() || scores[0].BannedUntil == 0 || scores[1].Score != SyncPenaltyFailure {
+		t.Errorf("Expected the banned liar first, got %+v", scores)
+	}
+}
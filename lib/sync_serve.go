@@ -0,0 +1,238 @@
+package lib
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Serving history is the expensive side of sync: each request reads blocks from disk and
+// streams them to the peer. A serving node caps the blocks in one "blocks" response (the
+// response says where to continue), the sync sessions it runs at once, and the bytes per
+// second it sends each peer, so one syncing peer can't saturate its disk and uplink.
+
+const (
+	DefaultSyncMaxBlocksPerRequest = BlockBatchSize // Blocks in one "blocks" response
+	DefaultSyncMaxSessions         = 8              // Block and header requests served at once
+	DefaultSyncPeerBandwidthKB     = 1024           // KiB per second sent to each peer, 0 = unlimited
+	MaxSyncSessionsPerPeer         = 2              // Sessions one peer may hold at once
+	SyncPeerIdleTimeout            = time.Minute    // Bandwidth state kept for peers this long after their last request
+	syncWriteChunk                 = 16 * 1024      // Bytes written between bandwidth checks
+)
+
+// syncBusyMessage is the error a serving node sends when it is at its session limits
+const syncBusyMessage = "sync server busy, retry later"
+
+// ErrSyncServerBusy is returned when a peer turns a sync request away at its session
+// limits. The request can be retried, and the peer is not penalized for it.
+var ErrSyncServerBusy = errors.New(syncBusyMessage)
+
+// SyncServeLimits bounds what this node serves to syncing peers
+type SyncServeLimits struct {
+	MaxBlocksPerRequest int   // Blocks in one response; longer ranges are paginated
+	MaxSessions         int   // Block and header requests served at once
+	PeerBytesPerSec     int64 // Response bytes per second per peer, 0 = unlimited
+}
+
+// DefaultSyncServeLimits returns the serving limits used when none are configured
+func DefaultSyncServeLimits() SyncServeLimits {
+	return SyncServeLimits{
+		MaxBlocksPerRequest: DefaultSyncMaxBlocksPerRequest,
+		MaxSessions:         DefaultSyncMaxSessions,
+		PeerBytesPerSec:     DefaultSyncPeerBandwidthKB * 1024,
+	}
+}
+
+// SyncServeStats reports what this node has served to syncing peers
+type SyncServeStats struct {
+	ActiveSessions      int    `json:"active_sessions"`
+	MaxSessions         int    `json:"max_sessions"`
+	MaxBlocksPerRequest int    `json:"max_blocks_per_request"`
+	PeerBytesPerSec     int64  `json:"peer_bytes_per_sec"`
+	Requests            uint64 `json:"requests"`       // Block and header requests served
+	BlocksServed        uint64 `json:"blocks_served"`  // Blocks sent
+	HeadersServed       uint64 `json:"headers_served"` // Headers sent
+	BytesSent           uint64 `json:"bytes_sent"`     // Response bytes written
+	Paginated           uint64 `json:"paginated"`      // Block requests cut to max_blocks_per_request
+	Busy                uint64 `json:"busy"`           // Requests turned away at the session limits
+	Throttled           uint64 `json:"throttled"`      // Writes delayed by the per-peer bandwidth limit
+	ThrottledMs         uint64 `json:"throttled_ms"`   // Total time writes were delayed
+	TrackedPeers        int    `json:"tracked_peers"`  // Peers with bandwidth state
+}
+
+// byteBucket is a token bucket of bytes refilled at rate per second, holding at most one
+// second's worth
+type byteBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends n bytes and returns how long the caller must wait before sending them
+func (b *byteBucket) take(n int, rate int64, now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	if b.tokens > float64(rate) {
+		b.tokens = float64(rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// syncServer enforces the serving limits and counts what is served
+type syncServer struct {
+	limits SyncServeLimits
+
+	mu       sync.Mutex
+	sessions int
+	perPeer  map[peer.ID]int
+	buckets  map[peer.ID]*byteBucket
+	stats    SyncServeStats
+}
+
+// newSyncServer creates serving state, filling unset limits with the defaults
+func newSyncServer(limits SyncServeLimits) *syncServer {
+	defaults := DefaultSyncServeLimits()
+	if limits.MaxBlocksPerRequest <= 0 {
+		limits.MaxBlocksPerRequest = defaults.MaxBlocksPerRequest
+	}
+	if limits.MaxSessions <= 0 {
+		limits.MaxSessions = defaults.MaxSessions
+	}
+	if limits.PeerBytesPerSec < 0 {
+		limits.PeerBytesPerSec = 0
+	}
+	return &syncServer{
+		limits:  limits,
+		perPeer: make(map[peer.ID]int),
+		buckets: make(map[peer.ID]*byteBucket),
+	}
+}
+
+// acquire opens a session for p; false if the node or the peer is at its session limit
+func (s *syncServer) acquire(p peer.ID, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions >= s.limits.MaxSessions || s.perPeer[p] >= MaxSyncSessionsPerPeer {
+		s.stats.Busy++
+		return false
+	}
+	s.sessions++
+	s.perPeer[p]++
+	s.stats.Requests++
+
+	// Forget the bandwidth state of peers that stopped syncing
+	for id, b := range s.buckets {
+		if s.perPeer[id] == 0 && now.Sub(b.last) > SyncPeerIdleTimeout {
+			delete(s.buckets, id)
+		}
+	}
+	if _, ok := s.buckets[p]; !ok {
+		s.buckets[p] = &byteBucket{tokens: float64(s.limits.PeerBytesPerSec), last: now}
+	}
+	return true
+}
+
+// release closes a session opened by acquire
+func (s *syncServer) release(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions--
+	if s.perPeer[p]--; s.perPeer[p] <= 0 {
+		delete(s.perPeer, p)
+	}
+}
+
+// clampBlocks caps a block range at MaxBlocksPerRequest; next is where the peer should
+// continue, 0 when the whole range fits
+func (s *syncServer) clampBlocks(start, end uint64) (clampedEnd, next uint64) {
+	max := uint64(s.limits.MaxBlocksPerRequest)
+	if end-start < max {
+		return end, 0
+	}
+
+	s.mu.Lock()
+	s.stats.Paginated++
+	s.mu.Unlock()
+	clampedEnd = start + max - 1
+	return clampedEnd, clampedEnd + 1
+}
+
+// served counts the blocks and headers in a response
+func (s *syncServer) served(blocks, headers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.BlocksServed += uint64(blocks)
+	s.stats.HeadersServed += uint64(headers)
+}
+
+// wait spends n bytes of p's bandwidth and returns how long to hold the write back
+func (s *syncServer) wait(p peer.ID, n int, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.BytesSent += uint64(n)
+	b, ok := s.buckets[p]
+	if s.limits.PeerBytesPerSec == 0 || !ok {
+		return 0
+	}
+	delay := b.take(n, s.limits.PeerBytesPerSec, now)
+	if delay > 0 {
+		s.stats.Throttled++
+		s.stats.ThrottledMs += uint64(delay / time.Millisecond)
+	}
+	return delay
+}
+
+// writer returns w paced to p's bandwidth limit
+func (s *syncServer) writer(p peer.ID, w io.Writer) io.Writer {
+	return &throttledWriter{server: s, peer: p, w: w}
+}
+
+// Stats returns the serving limits and counters
+func (s *syncServer) Stats() SyncServeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.ActiveSessions = s.sessions
+	stats.MaxSessions = s.limits.MaxSessions
+	stats.MaxBlocksPerRequest = s.limits.MaxBlocksPerRequest
+	stats.PeerBytesPerSec = s.limits.PeerBytesPerSec
+	stats.TrackedPeers = len(s.buckets)
+	return stats
+}
+
+// throttledWriter writes in chunks, sleeping whenever the peer is over its bandwidth
+type throttledWriter struct {
+	server *syncServer
+	peer   peer.ID
+	w      io.Writer
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > syncWriteChunk {
+			chunk = chunk[:syncWriteChunk]
+		}
+		if delay := t.server.wait(t.peer, len(chunk), time.Now()); delay > 0 {
+			time.Sleep(delay)
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
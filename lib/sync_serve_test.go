@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSyncServeSessions(t *testing.T) {
+	s := newSyncServer(SyncServeLimits{MaxSessions: 3})
+	a, b := peer.ID("peer-a-000000000"), peer.ID("peer-b-000000000")
+	now := time.Now()
+
+	// One peer can't take every session
+	if !s.acquire(a, now) || !s.acquire(a, now) {
+		t.Fatal("Expected a peer to get its own sessions")
+	}
+	if s.acquire(a, now) {
+		t.Fatalf("Expected a peer held to %d sessions", MaxSyncSessionsPerPeer)
+	}
+	if !s.acquire(b, now) {
+		t.Fatal("Expected another peer to get the last session")
+	}
+	if s.acquire(b, now) {
+		t.Fatal("Expected the node held to max_sessions")
+	}
+
+	s.release(a)
+	if !s.acquire(b, now) {
+		t.Fatal("Expected a released session to be reusable")
+	}
+
+	stats := s.Stats()
+	if stats.ActiveSessions != 3 || stats.Busy != 2 || stats.Requests != 4 {
+		t.Errorf("Unexpected session stats: %+v", stats)
+	}
+}
+
+func TestSyncServeClampBlocks(t *testing.T) {
+	s := newSyncServer(SyncServeLimits{MaxBlocksPerRequest: 10})
+
+	if end, next := s.clampBlocks(5, 14); end != 14 || next != 0 {
+		t.Errorf("Expected a full page served whole, got end %d next %d", end, next)
+	}
+	if end, next := s.clampBlocks(5, 100); end != 14 || next != 15 {
+		t.Errorf("Expected the range cut to 10 blocks continuing at 15, got end %d next %d", end, next)
+	}
+	if end, next := s.clampBlocks(0, ^uint64(0)); end != 9 || next != 10 {
+		t.Errorf("Expected the widest range paginated, got end %d next %d", end, next)
+	}
+	if stats := s.Stats(); stats.Paginated != 2 {
+		t.Errorf("Expected 2 paginated requests, got %d", stats.Paginated)
+	}
+}
+
+func TestSyncServeBandwidth(t *testing.T) {
+	s := newSyncServer(SyncServeLimits{PeerBytesPerSec: 1000})
+	p := peer.ID("peer-a-000000000")
+	now := time.Now()
+	s.acquire(p, now)
+
+	// A second's worth goes out at once, the rest waits for the bucket to refill
+	if delay := s.wait(p, 1000, now); delay != 0 {
+		t.Errorf("Expected the burst sent at once, waited %v", delay)
+	}
+	if delay := s.wait(p, 500, now); delay != 500*time.Millisecond {
+		t.Errorf("Expected 500 bytes over the limit to wait 500ms, waited %v", delay)
+	}
+	if delay := s.wait(p, 500, now.Add(1500*time.Millisecond)); delay != 0 {
+		t.Errorf("Expected the refilled bucket to send at once, waited %v", delay)
+	}
+
+	stats := s.Stats()
+	if stats.BytesSent != 2000 || stats.Throttled != 1 || stats.ThrottledMs != 500 {
+		t.Errorf("Unexpected bandwidth stats: %+v", stats)
+	}
+
+	// Unlimited servers never wait
+	free := newSyncServer(SyncServeLimits{})
+	free.acquire(p, now)
+	var buf bytes.Buffer
+	if _, err := free.writer(p, &buf).Write(make([]byte, 3*syncWriteChunk)); err != nil || buf.Len() != 3*syncWriteChunk {
+		t.Fatalf("Expected all bytes written, got %d (%v)", buf.Len(), err)
+	}
+}
+
+func TestSyncServeIdlePeersForgotten(t *testing.T) {
+	s := newSyncServer(SyncServeLimits{})
+	a, b := peer.ID("peer-a-000000000"), peer.ID("peer-b-000000000")
+	now := time.Now()
+
+	s.acquire(a, now)
+	s.release(a)
+	s.acquire(b, now.Add(SyncPeerIdleTimeout+time.Second))
+	if stats := s.Stats(); stats.TrackedPeers != 1 {
+		t.Errorf("Expected the idle peer's bandwidth state dropped, tracking %d peers", stats.TrackedPeers)
+	}
+}
+
+func TestBusyPeerNotPenalized(t *testing.T) {
+	c := NewBlockSyncClient(nil, nil)
+	p := peer.ID("busy-peer-000000")
+
+	for i := 0; i < SyncBanScore; i++ {
+		if err := c.penalizePeer(p, false, fmt.Errorf("range 1-100: %w", ErrSyncServerBusy)); !errors.Is(err, ErrSyncServerBusy) {
+			t.Fatalf("Expected the busy error returned, got %v", err)
+		}
+	}
+	if scores := c.PeerScores(); len(scores) != 0 {
+		t.Errorf("Expected a busy peer not to be scored, got %+v", scores)
+	}
+}
@@ -0,0 +1,277 @@
+// Package testharness runs multi-node networks in one process for integration tests of
+// consensus, sync and swaps. Each node gets its own temporary data dir and free ports,
+// is seeded with the nodes started before it, and syncs from them on startup. Nodes don't
+// farm; blocks are produced on demand with MineUntilHeight and SubmitAndConfirm, and
+// reach the other nodes through the normal commit gossip.
+//
+//	nw := testharness.NewNetwork(t, 3)
+//	nw.MineUntilHeight(nw.Nodes[0], 3)
+//	tx := nw.Send(nw.Nodes[0], nw.Nodes[1].Address(), 1000)
+//	nw.SubmitAndConfirm(nw.Nodes[2], nw.Nodes[0], tx)
+//	nw.ExpectBalance(nw.Nodes[1].Address(), testharness.ShadowTokenID(), 1000)
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shadowy/lib"
+)
+
+// DefaultTimeout bounds how long a helper waits for the network to converge
+const DefaultTimeout = 30 * time.Second
+
+// Node is one in-process node of a test network
+type Node struct {
+	*lib.P2PBlockchainNode
+	Index   int
+	DataDir string
+	P2PPort int
+	APIPort int
+
+	embedded *lib.Node
+}
+
+// Address returns the node wallet's address
+func (n *Node) Address() lib.Address {
+	return n.Wallet.Address
+}
+
+// Multiaddr returns the address other nodes dial to reach this node
+func (n *Node) Multiaddr() string {
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/%s", n.P2PPort, n.P2P.Host.ID())
+}
+
+// APIURL returns the base URL of the node's HTTP API
+func (n *Node) APIURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", n.APIPort)
+}
+
+// Network is a set of connected in-process nodes, closed when the test ends
+type Network struct {
+	Nodes   []*Node
+	Timeout time.Duration // How long helpers wait for convergence (default DefaultTimeout)
+
+	t   testing.TB
+	dir string // Parent of the node data dirs
+}
+
+// NewNetwork starts size connected nodes
+func NewNetwork(t testing.TB, size int) *Network {
+	t.Helper()
+	nw := &Network{Timeout: DefaultTimeout, t: t, dir: t.TempDir()}
+	t.Cleanup(nw.Close) // Runs before the data dirs are removed
+	for i := 0; i < size; i++ {
+		nw.AddNode()
+	}
+	nw.WaitForPeers()
+	return nw
+}
+
+// AddNode starts another node seeded with the running ones. It syncs the chain from
+// them before returning.
+func (nw *Network) AddNode() *Node {
+	nw.t.Helper()
+	index := len(nw.Nodes)
+	dataDir := filepath.Join(nw.dir, fmt.Sprintf("node%d", index))
+	p2pPort, apiPort := freePort(nw.t), freePort(nw.t)
+
+	var seeds []string
+	for _, node := range nw.Nodes {
+		seeds = append(seeds, node.Multiaddr())
+	}
+	config := lib.DefaultConfig()
+	config.Seeds = seeds
+	config.Dirs = nil
+	config.P2PPort = p2pPort
+	config.APIPort = apiPort
+	config.MinOutboundPeers = 1
+	config.PeerExchange = false // Every node is seeded with the others
+	config.DataDir = dataDir
+
+	embedded, err := lib.NewNode(config)
+	if err == nil {
+		err = embedded.Start(context.Background())
+	}
+	if err != nil {
+		nw.t.Fatalf("Failed to start node %d: %v", index, err)
+	}
+
+	node := &Node{
+		P2PBlockchainNode: embedded.Backend(),
+		embedded:          embedded,
+		Index:             index,
+		DataDir:           dataDir,
+		P2PPort:           p2pPort,
+		APIPort:           apiPort,
+	}
+	nw.Nodes = append(nw.Nodes, node)
+	return node
+}
+
+// Close stops every node
+func (nw *Network) Close() {
+	for _, node := range nw.Nodes {
+		node.embedded.Stop()
+	}
+	nw.Nodes = nil
+}
+
+// WaitForPeers waits until every node is connected to every other node
+func (nw *Network) WaitForPeers() {
+	nw.t.Helper()
+	nw.waitFor("full peer mesh", func() bool {
+		for _, node := range nw.Nodes {
+			if len(node.P2P.Host.Network().Peers()) < len(nw.Nodes)-1 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WaitForHeight waits until every node has at least height blocks and they agree on
+// the block at height-1
+func (nw *Network) WaitForHeight(height uint64) {
+	nw.t.Helper()
+	nw.waitFor(fmt.Sprintf("height %d", height), func() bool {
+		var hash string
+		for _, node := range nw.Nodes {
+			if node.Chain.GetHeight() < height {
+				return false
+			}
+			block := node.Chain.GetBlock(height - 1)
+			if block == nil || (hash != "" && block.Hash != hash) {
+				return false
+			}
+			hash = block.Hash
+		}
+		return true
+	})
+}
+
+// MineUntilHeight has miner produce blocks, paying its wallet, until the chain reaches
+// height, then waits for every node to follow
+func (nw *Network) MineUntilHeight(miner *Node, height uint64) {
+	nw.t.Helper()
+	for miner.Chain.GetHeight() < height {
+		if _, err := miner.Consensus.MineBlock(miner.Address()); err != nil {
+			nw.t.Fatalf("Node %d failed to mine block %d: %v", miner.Index, miner.Chain.GetHeight(), err)
+		}
+	}
+	nw.WaitForHeight(height)
+}
+
+// SubmitAndConfirm submits tx through via, waits for it to gossip to miner, has miner
+// mine it and waits for every node to apply the block. Returns the confirming block.
+func (nw *Network) SubmitAndConfirm(via, miner *Node, tx *lib.Transaction) *lib.Block {
+	nw.t.Helper()
+	txID, err := tx.ID()
+	if err != nil {
+		nw.t.Fatalf("Failed to compute transaction ID: %v", err)
+	}
+	if err := via.Mempool.AddTransaction(tx); err != nil {
+		nw.t.Fatalf("Node %d refused transaction %s: %v", via.Index, txID, err)
+	}
+	nw.waitFor(fmt.Sprintf("transaction %s to reach node %d", txID[:16], miner.Index), func() bool {
+		return miner.Mempool.HasTransaction(txID)
+	})
+
+	block, err := miner.Consensus.MineBlock(miner.Address())
+	if err != nil {
+		nw.t.Fatalf("Node %d failed to mine transaction %s: %v", miner.Index, txID, err)
+	}
+	confirmed := false
+	for _, id := range block.Transactions {
+		if id == txID {
+			confirmed = true
+			break
+		}
+	}
+	if !confirmed {
+		nw.t.Fatalf("Block %d left out transaction %s", block.Index, txID)
+	}
+	nw.WaitForHeight(block.Index + 1)
+	return block
+}
+
+// ExpectBalance waits until every node reports want of tokenID for addr
+func (nw *Network) ExpectBalance(addr lib.Address, tokenID string, want uint64) {
+	nw.t.Helper()
+	balances := make([]uint64, len(nw.Nodes))
+	converged := nw.poll(func() bool {
+		for i, node := range nw.Nodes {
+			balance, err := node.Chain.GetUTXOStore().GetBalance(addr)
+			if err != nil {
+				nw.t.Fatalf("Node %d failed to read balance: %v", node.Index, err)
+			}
+			balances[i] = balance[tokenID]
+		}
+		for _, balance := range balances {
+			if balance != want {
+				return false
+			}
+		}
+		return true
+	})
+	if !converged {
+		nw.t.Fatalf("Expected a balance of %d for %s on every node, got %v", want, addr.String(), balances)
+	}
+}
+
+// Send builds and signs a SHADOW send of amount from the node wallet to to
+func (nw *Network) Send(from *Node, to lib.Address, amount uint64) *lib.Transaction {
+	nw.t.Helper()
+	utxos, err := from.Chain.GetUTXOStore().GetUTXOsByAddressAndToken(from.Address(), ShadowTokenID())
+	if err != nil {
+		nw.t.Fatalf("Failed to list node %d's UTXOs: %v", from.Index, err)
+	}
+	built, err := lib.BuildSendTransaction(nil, utxos, []*lib.TxOutput{lib.CreateShadowOutput(to, amount)}, from.Address(), 0, 0)
+	if err != nil {
+		nw.t.Fatalf("Failed to build send from node %d: %v", from.Index, err)
+	}
+	if err := from.Wallet.SignTransaction(built.Transaction); err != nil {
+		nw.t.Fatalf("Failed to sign send from node %d: %v", from.Index, err)
+	}
+	return built.Transaction
+}
+
+// ShadowTokenID returns the token ID of the native SHADOW token
+func ShadowTokenID() string {
+	return lib.GetGenesisToken().TokenID
+}
+
+// waitFor polls done until it holds, failing the test on timeout
+func (nw *Network) waitFor(what string, done func() bool) {
+	nw.t.Helper()
+	if !nw.poll(done) {
+		nw.t.Fatalf("Timed out after %v waiting for %s", nw.Timeout, what)
+	}
+}
+
+// poll calls done until it holds or the timeout passes; returns whether it held
+func (nw *Network) poll(done func() bool) bool {
+	deadline := time.Now().Add(nw.Timeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// freePort returns a TCP port nothing is listening on
+func freePort(t testing.TB) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
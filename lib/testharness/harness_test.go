@@ -0,0 +1,78 @@
+package testharness
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shadowy/lib"
+)
+
+func TestNetwork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Starts several nodes")
+	}
+	nw := NewNetwork(t, 3)
+	miner, recipient, relay := nw.Nodes[0], nw.Nodes[1], nw.Nodes[2]
+
+	// Blocks mined on one node are committed on all of them
+	nw.MineUntilHeight(miner, 3)
+
+	// A send submitted to one node gossips to the miner and confirms everywhere
+	tx := nw.Send(miner, recipient.Address(), 1000)
+	block := nw.SubmitAndConfirm(relay, miner, tx)
+	nw.ExpectBalance(recipient.Address(), ShadowTokenID(), 1000)
+
+	// A node joining later syncs the chain from its seeds and follows new blocks
+	late := nw.AddNode()
+	if got := late.Chain.GetHeight(); got != block.Index+1 {
+		t.Fatalf("Expected the late node to sync to height %d, got %d", block.Index+1, got)
+	}
+	nw.WaitForPeers()
+	nw.MineUntilHeight(miner, late.Chain.GetHeight()+1)
+}
+
+func TestEmbeddedNodeLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Starts a node")
+	}
+	config := lib.DefaultConfig()
+	config.Seeds = []string{"not-a-seed"}
+	if _, err := lib.NewNode(config); err == nil {
+		t.Fatal("Expected an invalid seed to be refused")
+	}
+
+	config.Seeds = nil
+	config.Dirs = nil
+	config.P2PPort, config.APIPort = freePort(t), freePort(t)
+	config.DataDir = filepath.Join(t.TempDir(), "node")
+	node, err := lib.NewNode(config)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := node.Start(ctx); err != nil {
+		t.Fatalf("Failed to start node: %v", err)
+	}
+	if node.Backend() == nil || node.Backend().Chain.GetHeight() != 1 {
+		t.Fatal("Expected a running node with the genesis block")
+	}
+	if err := node.Start(ctx); !errors.Is(err, lib.ErrNodeRunning) {
+		t.Fatalf("Expected a second start to fail, got %v", err)
+	}
+
+	// Cancelling the context stops the node
+	cancel()
+	select {
+	case <-node.Done():
+	case <-time.After(DefaultTimeout):
+		t.Fatal("Node did not stop when its context was cancelled")
+	}
+	if err := node.Stop(); !errors.Is(err, lib.ErrNodeNotRunning) {
+		t.Fatalf("Expected the node to be stopped, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// Burns destroy tokens outright. Unlike a melt, nothing is unlocked: the burned amount
+// is sent to BurnAddress in burn outputs, which are recorded in the token registry
+// instead of the UTXO set, so the burn is provable from the chain and can never be
+// undone.
+
+const (
+	BurnTokenType = "burn" // TokenType of burn outputs
+	OpBurn        = 0x6a   // ScriptPubKey of burn outputs (OP_RETURN: unspendable)
+	MaxBurnMemo   = 64     // Bytes of ASCII memo a burn may carry
+)
+
+// BurnRecord is one burn of a token
+type BurnRecord struct {
+	TxID        string `json:"tx_id"`
+	Amount      uint64 `json:"amount"`
+	BlockHeight uint64 `json:"block_height"`
+	Burner      string `json:"burner,omitempty"` // Address of the signing key
+	Memo        string `json:"memo,omitempty"`
+}
+
+// CreateBurnTransaction builds an unsigned TX_BURN destroying amount of tokenID. Inputs
+// must cover the burn and fee (fee in SHADOW); change goes back to changeAddress.
+// Token outputs carry the token's mint version in registry.
+func CreateBurnTransaction(registry *TokenRegistry, utxos []*UTXO, tokenID string, amount uint64, changeAddress Address, memo string) (*Transaction, error) {
+	genesisTokenID := GetGenesisToken().TokenID
+	if tokenID == "" || tokenID == "SHADOW" {
+		tokenID = genesisTokenID
+	}
+	if amount == 0 {
+		return nil, fmt.Errorf("burn amount must be positive")
+	}
+	if len(memo) > MaxBurnMemo || !isASCII(memo) {
+		return nil, fmt.Errorf("memo must be ASCII and at most %d bytes", MaxBurnMemo)
+	}
+
+	fee := CalculateTxFee(TxTypeBurn, 0, 0, 0)
+	need := map[string]uint64{tokenID: amount}
+	need[genesisTokenID] += fee
+
+	builder := NewTxBuilder(TxTypeBurn).SetRegistry(registry)
+	have := make(map[string]uint64)
+	for _, utxo := range utxos {
+		if utxo.IsSpent || have[utxo.Output.TokenID] >= need[utxo.Output.TokenID] {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		have[utxo.Output.TokenID] += utxo.Output.Amount
+	}
+	for id, n := range need {
+		if have[id] < n {
+			return nil, fmt.Errorf("insufficient balance of %s: have %d, need %d", id, have[id], n)
+		}
+	}
+
+	burn := CreateBurnOutput(amount, tokenID)
+	burn.MintVersion = registry.MintVersion(tokenID)
+	builder.AddCustomOutput(burn)
+	if change := have[tokenID] - need[tokenID]; change > 0 && tokenID != genesisTokenID {
+		builder.AddOutput(changeAddress, change, tokenID)
+	}
+	if change := have[genesisTokenID] - need[genesisTokenID]; change > 0 {
+		builder.AddOutput(changeAddress, change, genesisTokenID)
+	}
+	if memo != "" {
+		builder.SetData([]byte(memo))
+	}
+
+	return builder.Build(), nil
+}
+
+// validateBurnTransaction validates TX_BURN structure
+func validateBurnTransaction(tx *Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("burn transaction must have inputs")
+	}
+	if len(tx.Data) > MaxBurnMemo || !isASCII(string(tx.Data)) {
+		return fmt.Errorf("burn memo must be ASCII and at most %d bytes", MaxBurnMemo)
+	}
+
+	burns := 0
+	for i, output := range tx.Outputs {
+		if !output.IsBurn() {
+			continue
+		}
+		if output.Amount == 0 {
+			return fmt.Errorf("burn output %d has zero amount", i)
+		}
+		if output.Address != BurnAddress {
+			return fmt.Errorf("burn output %d must pay the burn address", i)
+		}
+		burns++
+	}
+	if burns == 0 {
+		return fmt.Errorf("burn transaction must have at least one burn output")
+	}
+	return nil
+}
+
+// validateBurnOutputs keeps burn outputs to burn transactions and pool creations (which
+// burn the creation fee), where they are recorded
+func validateBurnOutputs(tx *Transaction) error {
+	if tx.TxType == TxTypeBurn || tx.TxType == TxTypeCreatePool {
+		return nil
+	}
+	for i, output := range tx.Outputs {
+		if output.IsBurn() {
+			return fmt.Errorf("output %d is a burn output; use a burn transaction", i)
+		}
+	}
+	return nil
+}
+
+// RecordBurns records a burn transaction's burn outputs against their tokens
+func (tr *TokenRegistry) RecordBurns(tx *Transaction, blockHeight uint64) error {
+	txID, err := tx.ID()
+	if err != nil {
+		return err
+	}
+	burner, memo := "", ""
+	if tx.TxType == TxTypeBurn {
+		memo = string(tx.Data) // Other transactions use Data for their own payload
+	}
+	if len(tx.PublicKey) > 0 {
+		if pk, err := PublicKeyFromBytes(tx.PublicKey); err == nil {
+			burner = DeriveAddress(pk).String()
+		}
+	}
+
+	for _, output := range tx.Outputs {
+		if !output.IsBurn() {
+			continue
+		}
+		token, exists := tr.Tokens[output.TokenID]
+		if !exists {
+			return fmt.Errorf("token %s not found", output.TokenID)
+		}
+		if token.TotalBurned+token.TotalMelted+output.Amount > token.Issued() && !token.IsBaseToken() {
+			return fmt.Errorf("burn of %d exceeds outstanding supply of %s", output.Amount, token.Ticker)
+		}
+
+		token.TotalBurned += output.Amount
+		if tr.Burns == nil {
+			tr.Burns = make(map[string][]*BurnRecord)
+		}
+		tr.Burns[output.TokenID] = append(tr.Burns[output.TokenID], &BurnRecord{
+			TxID:        txID,
+			Amount:      output.Amount,
+			BlockHeight: blockHeight,
+			Burner:      burner,
+			Memo:        memo,
+		})
+		if err := tr.persist(output.TokenID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBurns returns the burns recorded for a token, oldest first
+func (tr *TokenRegistry) GetBurns(tokenID string) []*BurnRecord {
+	return tr.Burns[tokenID]
+}
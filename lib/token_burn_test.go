@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestBurnTransaction(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	shadowID := GetGenesisToken().TokenID
+	utxos := []*UTXO{{
+		TxID:        "funding",
+		OutputIndex: 0,
+		Output:      CreateShadowOutput(kp.Address(), 1_000_000),
+	}}
+
+	tx, err := CreateBurnTransaction(nil, utxos, "SHADOW", 250_000, kp.Address(), "proof of burn")
+	if err != nil {
+		t.Fatalf("Failed to create burn: %v", err)
+	}
+	if err := validateBurnTransaction(tx); err != nil {
+		t.Fatalf("Valid burn rejected: %v", err)
+	}
+	if !tx.Outputs[0].IsBurn() || tx.Outputs[0].Address != BurnAddress || tx.Outputs[0].TokenID != shadowID {
+		t.Fatalf("Expected a SHADOW burn output to the burn address, got %+v", tx.Outputs[0])
+	}
+	if _, err := CreateBurnTransaction(nil, utxos, "SHADOW", 1_000_000, kp.Address(), ""); err == nil {
+		t.Error("Burn leaving nothing for the fee should be rejected")
+	}
+
+	// Burn outputs only belong in burn transactions
+	send := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddCustomOutput(CreateBurnOutput(5, shadowID)).Build()
+	if err := validateBurnOutputs(send); err == nil {
+		t.Error("Burn output in a send should be rejected")
+	}
+
+	registry := NewTokenRegistry()
+	if err := registry.RecordBurns(tx, 7); err != nil {
+		t.Fatalf("Failed to record burn: %v", err)
+	}
+	token, _ := registry.GetToken(shadowID)
+	burns := registry.GetBurns(shadowID)
+	if token.TotalBurned != 250_000 || len(burns) != 1 {
+		t.Fatalf("Expected 250000 burned in 1 burn, got %d in %d", token.TotalBurned, len(burns))
+	}
+	if burns[0].BlockHeight != 7 || burns[0].Memo != "proof of burn" {
+		t.Errorf("Burn record not kept: %+v", burns[0])
+	}
+}
@@ -0,0 +1,454 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// A token can be created with only part of its supply minted (see
+// CreatePartialTokenMintTransaction); the rest stays unissued, and supply mints (mint
+// transactions naming the token) add to it, locking SHADOW 1:1 like the creation did,
+// until the cap is reached. Only the creator may mint, unless it delegates: a
+// delegate_mint transaction signed by the creator lets another address, such as an
+// issuance service, mint too, optionally at most PeriodLimit per PeriodBlocks blocks.
+// Delegations are revoked the same way. The rights are checked when a supply mint enters
+// the mempool, when a block is validated and again when it is applied.
+
+// MintDelegation is an address the creator of a token let mint its unissued supply
+type MintDelegation struct {
+	Delegate     Address `json:"delegate"`
+	PeriodBlocks uint64  `json:"period_blocks,omitempty"` // Length of a limit period, 0 = no limit
+	PeriodLimit  uint64  `json:"period_limit,omitempty"`  // Most it may mint per period
+	GrantedAt    uint64  `json:"granted_at"`              // Block height of the delegation
+	Period       uint64  `json:"period"`                  // Period PeriodMinted counts
+	PeriodMinted uint64  `json:"period_minted"`
+	TotalMinted  uint64  `json:"total_minted"`
+}
+
+// MintDelegationData is the Data of a delegate_mint transaction
+type MintDelegationData struct {
+	TokenID      string  `json:"token_id"`
+	Delegate     Address `json:"delegate"`
+	PeriodBlocks uint64  `json:"period_blocks,omitempty"`
+	PeriodLimit  uint64  `json:"period_limit,omitempty"`
+	Revoke       bool    `json:"revoke,omitempty"` // Withdraw the delegate's rights
+}
+
+// period returns the limit period containing height
+func (d *MintDelegation) period(height uint64) uint64 {
+	if d.PeriodBlocks == 0 {
+		return 0
+	}
+	return height / d.PeriodBlocks
+}
+
+// mintedIn returns what the delegate minted in height's period
+func (d *MintDelegation) mintedIn(height uint64) uint64 {
+	if d.Period != d.period(height) {
+		return 0
+	}
+	return d.PeriodMinted
+}
+
+// Delegation returns the delegation of a token to an address, or nil
+func (ti *TokenInfo) Delegation(delegate Address) *MintDelegation {
+	for _, d := range ti.MintDelegates {
+		if d.Delegate == delegate {
+			return d
+		}
+	}
+	return nil
+}
+
+// txSigner returns the address of the key that signed a transaction
+func txSigner(tx *Transaction) (Address, error) {
+	if len(tx.PublicKey) == 0 {
+		return Address{}, fmt.Errorf("transaction is not signed")
+	}
+	pk, err := PublicKeyFromBytes(tx.PublicKey)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	return DeriveAddress(pk), nil
+}
+
+// checkSupplyMintTerms checks a supply mint has a single output of the token, carrying
+// the minted amount with as much SHADOW locked, and otherwise only SHADOW change
+func checkSupplyMintTerms(tx *Transaction, mintData TokenMintData) (*TxOutput, error) {
+	if mintData.Amount == 0 {
+		return nil, fmt.Errorf("supply mint amount must be positive")
+	}
+	if mintData.Ticker != "" || mintData.MaxMint != 0 || mintData.InitialSupply != 0 {
+		return nil, fmt.Errorf("supply mint can't set token terms")
+	}
+
+	var tokenOutput *TxOutput
+	genesisTokenID := GetGenesisToken().TokenID
+	for _, output := range tx.Outputs {
+		if output.TokenID == mintData.TokenID {
+			if tokenOutput != nil {
+				return nil, fmt.Errorf("supply mint must have one token output")
+			}
+			tokenOutput = output
+		} else if output.TokenID != genesisTokenID {
+			return nil, fmt.Errorf("supply mint can only return SHADOW change")
+		}
+	}
+	if tokenOutput == nil {
+		return nil, fmt.Errorf("no output of token %s", mintData.TokenID)
+	}
+	if tokenOutput.Amount != mintData.Amount {
+		return nil, fmt.Errorf("token output amount (%d) doesn't match minted amount (%d)",
+			tokenOutput.Amount, mintData.Amount)
+	}
+	if tokenOutput.LockedShadow != mintData.Amount {
+		return nil, fmt.Errorf("locked SHADOW (%d) must equal minted amount (%d)",
+			tokenOutput.LockedShadow, mintData.Amount)
+	}
+	if tokenOutput.MintVersion != mintData.MintVersion {
+		return nil, fmt.Errorf("token output mint version (%d) doesn't match mint data (%d)",
+			tokenOutput.MintVersion, mintData.MintVersion)
+	}
+	return tokenOutput, nil
+}
+
+// supplyMint returns the mint data of a supply mint
+func supplyMint(tx *Transaction) (TokenMintData, bool) {
+	var mintData TokenMintData
+	if tx.TxType != TxTypeMintToken || json.Unmarshal(tx.Data, &mintData) != nil || !mintData.IsSupplyMint() {
+		return mintData, false
+	}
+	return mintData, true
+}
+
+// CheckMintRights rejects a supply mint at height whose signer may not mint the token,
+// that exceeds the unissued supply or that exceeds the signer's period limit. minted
+// holds what earlier supply mints in the same block take (see blockMints); nil for none.
+func (tr *TokenRegistry) CheckMintRights(tx *Transaction, mintData TokenMintData, height uint64, minted map[string]uint64) error {
+	token, exists := tr.GetToken(mintData.TokenID)
+	if !exists || token.IsBaseToken() {
+		return fmt.Errorf("token %s not found", mintData.TokenID)
+	}
+	if token.IsFullyMelted() {
+		return fmt.Errorf("%s was fully melted and can't be minted", token.Ticker) // Its ticker may be reissued
+	}
+	if token.Collateral != nil {
+		return fmt.Errorf("%s is issued against collateral positions", token.Ticker)
+	}
+	if mintData.MintVersion != token.MintVersion {
+		return fmt.Errorf("mint version %d doesn't match token's %d", mintData.MintVersion, token.MintVersion)
+	}
+	if unissued := token.Unissued - min(token.Unissued, minted[token.TokenID]); mintData.Amount > unissued {
+		return fmt.Errorf("mint of %d exceeds the %d %s left under the cap", mintData.Amount, unissued, token.Ticker)
+	}
+
+	signer, err := txSigner(tx)
+	if err != nil {
+		return err
+	}
+	if signer == token.CreatorAddress {
+		return nil
+	}
+	delegation := token.Delegation(signer)
+	if delegation == nil {
+		return fmt.Errorf("%s may not mint %s", signer.Display(), token.Ticker)
+	}
+	if delegation.PeriodLimit > 0 {
+		already := delegation.mintedIn(height) + minted[mintedKey(token.TokenID, signer)]
+		if already+mintData.Amount > delegation.PeriodLimit {
+			return fmt.Errorf("mint of %d exceeds delegate limit of %d per %d blocks (%d minted this period)",
+				mintData.Amount, delegation.PeriodLimit, delegation.PeriodBlocks, already)
+		}
+	}
+	return nil
+}
+
+// mintedKey keys what a delegate minted earlier in a block
+func mintedKey(tokenID string, delegate Address) string {
+	return tokenID + ":" + delegate.String()
+}
+
+// MintSupply applies a supply mint at height: the token's unissued supply shrinks, its
+// locked SHADOW grows, and a delegate's period total is updated
+func (tr *TokenRegistry) MintSupply(tx *Transaction, mintData TokenMintData, height uint64) error {
+	if err := tr.CheckMintRights(tx, mintData, height, nil); err != nil {
+		return err
+	}
+	token := tr.Tokens[mintData.TokenID]
+	token.Unissued -= mintData.Amount
+	token.LockedShadow += mintData.Amount
+
+	// Delegations are replaced rather than changed in place, so a block's registry
+	// snapshot still holds the counts from before it
+	signer, _ := txSigner(tx)
+	if current := token.Delegation(signer); current != nil && signer != token.CreatorAddress {
+		delegation := *current
+		if period := delegation.period(height); delegation.Period != period {
+			delegation.Period, delegation.PeriodMinted = period, 0
+		}
+		delegation.PeriodMinted += mintData.Amount
+		delegation.TotalMinted += mintData.Amount
+
+		delegates := make([]*MintDelegation, len(token.MintDelegates))
+		for i, d := range token.MintDelegates {
+			if d == current {
+				d = &delegation
+			}
+			delegates[i] = d
+		}
+		token.MintDelegates = delegates
+	}
+	return tr.persist(token.TokenID)
+}
+
+// CheckMintDelegation rejects a delegate_mint transaction not signed by the token's
+// creator, or revoking a delegation that doesn't exist
+func (tr *TokenRegistry) CheckMintDelegation(tx *Transaction) (MintDelegationData, error) {
+	var data MintDelegationData
+	if err := json.Unmarshal(tx.Data, &data); err != nil {
+		return data, fmt.Errorf("invalid delegation data: %w", err)
+	}
+	token, exists := tr.GetToken(data.TokenID)
+	if !exists || token.IsBaseToken() {
+		return data, fmt.Errorf("token %s not found", data.TokenID)
+	}
+	signer, err := txSigner(tx)
+	if err != nil {
+		return data, err
+	}
+	if token.Collateral != nil {
+		return data, fmt.Errorf("%s is issued against collateral positions", token.Ticker)
+	}
+	if signer != token.CreatorAddress {
+		return data, fmt.Errorf("only the creator of %s may delegate minting", token.Ticker)
+	}
+	if data.Delegate == token.CreatorAddress {
+		return data, fmt.Errorf("the creator can already mint %s", token.Ticker)
+	}
+	if data.Revoke && token.Delegation(data.Delegate) == nil {
+		return data, fmt.Errorf("%s has no minting rights for %s", data.Delegate.Display(), token.Ticker)
+	}
+	return data, nil
+}
+
+// RecordMintDelegation applies a delegate_mint transaction at height. Granting rights to
+// an existing delegate replaces its limits and starts a new period count.
+func (tr *TokenRegistry) RecordMintDelegation(tx *Transaction, height uint64) (MintDelegationData, error) {
+	data, err := tr.CheckMintDelegation(tx)
+	if err != nil {
+		return data, err
+	}
+	token := tr.Tokens[data.TokenID]
+
+	delegates := make([]*MintDelegation, 0, len(token.MintDelegates)+1)
+	var total uint64
+	for _, d := range token.MintDelegates {
+		if d.Delegate == data.Delegate {
+			total = d.TotalMinted
+			continue
+		}
+		delegates = append(delegates, d)
+	}
+	if !data.Revoke {
+		delegation := &MintDelegation{
+			Delegate:     data.Delegate,
+			PeriodBlocks: data.PeriodBlocks,
+			PeriodLimit:  data.PeriodLimit,
+			GrantedAt:    height,
+			TotalMinted:  total,
+		}
+		delegation.Period = delegation.period(height)
+		delegates = append(delegates, delegation)
+	}
+	token.MintDelegates = delegates
+	return data, tr.persist(token.TokenID)
+}
+
+// blockMints tracks the supply mints and delegation changes earlier in a block, which
+// the registry doesn't reflect until the block is applied
+type blockMints struct {
+	minted  map[string]uint64 // Token ID, or mintedKey for a delegate -> amount
+	changed map[string]bool   // mintedKey -> delegation granted or revoked
+}
+
+func newBlockMints() *blockMints {
+	return &blockMints{minted: make(map[string]uint64), changed: make(map[string]bool)}
+}
+
+// check rejects a supply mint or delegation the registry doesn't allow at height after
+// the ones before it in the block, and records it if allowed. A delegate can't mint in
+// the block that changes its rights. Other transactions pass.
+func (bm *blockMints) check(tr *TokenRegistry, tx *Transaction, height uint64) error {
+	if tx.TxType == TxTypeDelegateMint {
+		data, err := tr.CheckMintDelegation(tx)
+		if err != nil {
+			return err
+		}
+		key := mintedKey(data.TokenID, data.Delegate)
+		if bm.changed[key] {
+			return fmt.Errorf("%s's minting rights already change in the block", data.Delegate.Display())
+		}
+		bm.changed[key] = true
+		return nil
+	}
+	mintData, ok := supplyMint(tx)
+	if !ok {
+		return nil
+	}
+	signer, err := txSigner(tx)
+	if err != nil {
+		return err
+	}
+	key := mintedKey(mintData.TokenID, signer)
+	if bm.changed[key] {
+		return fmt.Errorf("%s's minting rights change earlier in the block", signer.Display())
+	}
+	if err := tr.CheckMintRights(tx, mintData, height, bm.minted); err != nil {
+		return err
+	}
+	bm.minted[mintData.TokenID] += mintData.Amount
+	bm.minted[key] += mintData.Amount
+	return nil
+}
+
+// filterMintLimits drops supply mints and delegations that together break a token's cap,
+// a delegate's period limit or the creator's rights, so a proposer doesn't build a block
+// ValidateTokenMints rejects. The block lists transactions by fee rate, so delegations
+// are taken first: a delegate whose rights change isn't left minting before or after.
+func filterMintLimits(candidates []*Transaction, registry *TokenRegistry, height uint64) []*Transaction {
+	bm := newBlockMints()
+	skip := make(map[*Transaction]bool)
+	for _, tx := range candidates {
+		if tx.TxType == TxTypeDelegateMint && bm.check(registry, tx, height) != nil {
+			skip[tx] = true
+		}
+	}
+	kept := make([]*Transaction, 0, len(candidates))
+	for _, tx := range candidates {
+		if skip[tx] || (tx.TxType != TxTypeDelegateMint && bm.check(registry, tx, height) != nil) {
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	return kept
+}
+
+// CreateSupplyMintTransaction builds an unsigned mint of amount more of token, paid to
+// minter, who must sign it and be the token's creator or a delegate. SHADOW inputs cover
+// the amount locked and the fee; change goes back to minter.
+func CreateSupplyMintTransaction(token *TokenInfo, minter Address, shadowUTXOs []*UTXO, amount uint64) (*Transaction, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("mint amount must be positive")
+	}
+	if amount > token.Unissued {
+		return nil, fmt.Errorf("mint of %d exceeds the %d %s left under the cap", amount, token.Unissued, token.Ticker)
+	}
+
+	mintData := TokenMintData{TokenID: token.TokenID, Amount: amount, MintVersion: token.MintVersion}
+	data, err := json.Marshal(mintData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal mint data: %w", err)
+	}
+
+	builder := NewTxBuilder(TxTypeMintToken)
+	genesisTokenID := GetGenesisToken().TokenID
+	fee := CalculateTxFee(TxTypeMintToken, 0, 2, 0)
+	var have uint64
+	for _, utxo := range shadowUTXOs {
+		if have >= amount+fee {
+			break
+		}
+		if utxo.IsSpent || utxo.Output.TokenID != genesisTokenID || utxo.Output.Vesting != nil {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		have += utxo.Output.Amount
+	}
+	if have < amount+fee {
+		return nil, fmt.Errorf("insufficient SHADOW: have %d, need %d (stake %d + fee %d)", have, amount+fee, amount, fee)
+	}
+
+	builder.AddCustomOutput(&TxOutput{
+		Amount:       amount,
+		Address:      minter,
+		TokenID:      token.TokenID,
+		TokenType:    "custom",
+		MintVersion:  token.MintVersion,
+		LockedShadow: amount, // 1:1 SHADOW locked
+		ScriptPubKey: CreateP2PKHScript(minter),
+	})
+	if change := have - amount - fee; change > 0 {
+		builder.AddCustomOutput(CreateShadowOutput(minter, change))
+	}
+	builder.SetData(data)
+	return builder.Build(), nil
+}
+
+// CreateMintDelegationTransaction builds an unsigned delegate_mint transaction, which the
+// token's creator must sign. SHADOW inputs pay the fee; change goes back to creator.
+func CreateMintDelegationTransaction(creator Address, shadowUTXOs []*UTXO, delegation MintDelegationData) (*Transaction, error) {
+	if delegation.PeriodLimit > 0 && delegation.PeriodBlocks == 0 {
+		return nil, fmt.Errorf("period_limit needs period_blocks")
+	}
+	data, err := json.Marshal(delegation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal delegation: %w", err)
+	}
+
+	builder := NewTxBuilder(TxTypeDelegateMint)
+	genesisTokenID := GetGenesisToken().TokenID
+	fee := CalculateTxFee(TxTypeDelegateMint, 0, 1, 0)
+	var have uint64
+	for _, utxo := range shadowUTXOs {
+		if have >= fee {
+			break
+		}
+		if utxo.IsSpent || utxo.Output.TokenID != genesisTokenID || utxo.Output.Vesting != nil {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		have += utxo.Output.Amount
+	}
+	if have < fee {
+		return nil, fmt.Errorf("insufficient SHADOW for the fee: have %d, need %d", have, fee)
+	}
+	if change := have - fee; change > 0 {
+		builder.AddCustomOutput(CreateShadowOutput(creator, change))
+	}
+	builder.SetData(data)
+	return builder.Build(), nil
+}
+
+// validateDelegateMintTransaction validates TX_DELEGATE_MINT structure: a signed
+// delegation that pays its fee in SHADOW and creates nothing else
+func validateDelegateMintTransaction(tx *Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("delegate mint transaction must have inputs for the fee")
+	}
+	if len(tx.Signature) == 0 {
+		return fmt.Errorf("delegate mint transaction must be signed")
+	}
+	var data MintDelegationData
+	if err := json.Unmarshal(tx.Data, &data); err != nil {
+		return fmt.Errorf("invalid delegation data: %w", err)
+	}
+	if data.TokenID == "" {
+		return fmt.Errorf("delegation must name a token")
+	}
+	if data.Delegate == (Address{}) {
+		return fmt.Errorf("delegation must name a delegate")
+	}
+	if data.PeriodLimit > 0 && data.PeriodBlocks == 0 {
+		return fmt.Errorf("period_limit needs period_blocks")
+	}
+	if data.Revoke && (data.PeriodBlocks != 0 || data.PeriodLimit != 0) {
+		return fmt.Errorf("a revocation can't set limits")
+	}
+	genesisTokenID := GetGenesisToken().TokenID
+	for i, output := range tx.Outputs {
+		if output.TokenID != genesisTokenID {
+			return fmt.Errorf("output %d: delegate mint transaction can only return SHADOW change", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMintDelegation(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store, registry := bc.GetUTXOStore(), bc.tokenRegistry
+
+	wallet := func(funding string) (*NodeWallet, []*UTXO) {
+		kp, _ := GenerateKeyPair()
+		utxo := &UTXO{TxID: funding, Output: CreateShadowOutput(kp.Address(), 1_000_000)}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+		return &NodeWallet{KeyPair: kp, Address: kp.Address()}, []*UTXO{utxo}
+	}
+	creator, creatorUTXOs := wallet("creator-funding")
+	delegate, delegateUTXOs := wallet("delegate-funding")
+	stranger, strangerUTXOs := wallet("stranger-funding")
+	apply := func(w *NodeWallet, tx *Transaction, height int64) error {
+		if err := w.SignTransaction(tx); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return store.ProcessTokenTransaction(tx, registry, nil, height)
+	}
+
+	// The token is created with 1000 of its 10000 minted
+	create, err := CreatePartialTokenMintTransaction(registry, creator.Address, creatorUTXOs, "ISSUED", "Partial", 100, 2, 1000)
+	if err != nil {
+		t.Fatalf("Failed to create mint: %v", err)
+	}
+	if err := creator.SignTransaction(create); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	tokenID, _ := create.ID() // Before applying it replaces the PENDING token ID
+	if err := store.ProcessTokenTransaction(create, registry, nil, 1); err != nil {
+		t.Fatalf("Failed to apply mint: %v", err)
+	}
+	token, _ := registry.GetToken(tokenID)
+	if token.Issued() != 1000 || token.Unissued != 9000 || token.LockedShadow != 1000 {
+		t.Fatalf("Expected 1000 issued and 9000 unissued, got %d and %d", token.Issued(), token.Unissued)
+	}
+	supplyMint := func(w *NodeWallet, utxos []*UTXO, amount uint64) *Transaction {
+		tx, err := CreateSupplyMintTransaction(token, w.Address, utxos, amount)
+		if err != nil {
+			t.Fatalf("Failed to create supply mint: %v", err)
+		}
+		if err := w.SignTransaction(tx); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return tx
+	}
+
+	// Only the creator may mint until it delegates, and only the creator may delegate
+	if err := apply(stranger, supplyMint(stranger, strangerUTXOs, 100), 2); err == nil {
+		t.Fatal("Expected a mint by a stranger to be rejected")
+	}
+	if err := apply(creator, supplyMint(creator, creatorUTXOs, 500), 2); err != nil {
+		t.Fatalf("Creator's mint rejected: %v", err)
+	}
+	grant := MintDelegationData{TokenID: tokenID, Delegate: delegate.Address, PeriodBlocks: 10, PeriodLimit: 300}
+	forged, _ := CreateMintDelegationTransaction(stranger.Address, strangerUTXOs, grant)
+	if err := apply(stranger, forged, 3); err == nil {
+		t.Fatal("Expected a delegation by a stranger to be rejected")
+	}
+	delegation, _ := CreateMintDelegationTransaction(creator.Address, creatorUTXOs, grant)
+	if err := apply(creator, delegation, 3); err != nil {
+		t.Fatalf("Failed to delegate: %v", err)
+	}
+
+	// A block rolled back after the delegate's mint doesn't leave it counted
+	restore := registry.snapshot()
+	if err := apply(delegate, supplyMint(delegate, delegateUTXOs, 300), 4); err != nil {
+		t.Fatalf("Delegate's mint rejected: %v", err)
+	}
+	restore()
+	if d := token.Delegation(delegate.Address); d.PeriodMinted != 0 || d.TotalMinted != 0 {
+		t.Fatalf("Expected the rolled back mint not counted, got %+v", d)
+	}
+
+	// The delegate mints up to 300 per 10 blocks
+	if err := apply(delegate, supplyMint(delegate, delegateUTXOs, 200), 4); err != nil {
+		t.Fatalf("Delegate's mint rejected: %v", err)
+	}
+	over := supplyMint(delegate, delegateUTXOs, 200)
+	if err := apply(delegate, over, 5); err == nil {
+		t.Fatal("Expected a mint over the period limit to be rejected")
+	}
+	if err := apply(delegate, over, 10); err != nil {
+		t.Fatalf("Mint in the next period rejected: %v", err)
+	}
+	if d := token.Delegation(delegate.Address); d.Period != 1 || d.PeriodMinted != 200 || d.TotalMinted != 400 {
+		t.Errorf("Expected 200 minted in period 1 and 400 in all, got %+v", d)
+	}
+	if token.Unissued != 8100 || token.LockedShadow != 1900 || token.Validate() != nil {
+		t.Errorf("Expected 1900 issued, got %d with %d locked", token.Issued(), token.LockedShadow)
+	}
+	if err := apply(creator, supplyMint(creator, creatorUTXOs, 8100), 11); err != nil {
+		t.Fatalf("Mint up to the cap rejected: %v", err)
+	}
+	if _, err := CreateSupplyMintTransaction(token, creator.Address, creatorUTXOs, 1); err == nil {
+		t.Error("Expected a mint over the cap to be refused")
+	}
+	token.Unissued, token.LockedShadow = 1000, 9000 // Leave room to test the block rules
+
+	// A block can't take the delegate over its limit, nor let it mint after a revocation
+	first, second := supplyMint(delegate, delegateUTXOs, 200), supplyMint(delegate, delegateUTXOs, 150)
+	if kept := filterMintLimits([]*Transaction{first, second}, registry, 20); len(kept) != 1 || kept[0] != first {
+		t.Fatalf("Expected only the first mint kept, got %d", len(kept))
+	}
+	revoke, _ := CreateMintDelegationTransaction(creator.Address, creatorUTXOs, MintDelegationData{TokenID: tokenID, Delegate: delegate.Address, Revoke: true})
+	if err := creator.SignTransaction(revoke); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if kept := filterMintLimits([]*Transaction{first, revoke}, registry, 20); len(kept) != 1 || kept[0] != revoke {
+		t.Fatalf("Expected only the revocation kept, got %d", len(kept))
+	}
+	mints := newBlockMints()
+	if err := mints.check(registry, revoke, 20); err != nil {
+		t.Fatalf("Revocation rejected: %v", err)
+	}
+	if err := mints.check(registry, first, 20); err == nil {
+		t.Error("Expected a mint after the delegate's revocation in the block to be rejected")
+	}
+
+	if err := store.ProcessTokenTransaction(revoke, registry, nil, 20); err != nil {
+		t.Fatalf("Failed to revoke: %v", err)
+	}
+	if err := apply(delegate, first, 21); err == nil {
+		t.Error("Expected a mint by a revoked delegate to be rejected")
+	}
+}
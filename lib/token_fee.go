@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// Fee abstraction: a send can pay its fee in a custom token instead of SHADOW. The
+// token fee is left unspent by the transaction (inputs of the token exceed outputs by
+// at least the fee) and, when the block is applied, swapped into SHADOW through the
+// token's SHADOW pool at the pool's price at that point in the block. The SHADOW goes
+// to the block winner as an implicit output of the transaction, the same way swap
+// outputs are created. Nodes opt in to counting token fees with accept_token_fees;
+// proposers that don't opt in leave such transactions for someone else's block.
+
+// TokenFee is a transaction fee paid in a custom token
+type TokenFee struct {
+	TokenID string `json:"token_id"` // Custom token the fee is paid in
+	Amount  uint64 `json:"amount"`   // Token base units, converted to SHADOW at block time
+}
+
+// validateTokenFee checks a transaction's token fee declaration
+func validateTokenFee(tx *Transaction) error {
+	if tx.TokenFee == nil {
+		return nil
+	}
+	if tx.TxType != TxTypeSend {
+		return fmt.Errorf("token fees are only supported on send transactions")
+	}
+	if tx.TokenFee.Amount == 0 {
+		return fmt.Errorf("token fee amount must be positive")
+	}
+	if tx.TokenFee.TokenID == "" || tx.TokenFee.TokenID == "SHADOW" || tx.TokenFee.TokenID == GetGenesisToken().TokenID {
+		return fmt.Errorf("token fee must be paid in a custom token")
+	}
+	return nil
+}
+
+// TokenSurplus returns how much more of tokenID a transaction spends than it creates
+func TokenSurplus(tx *Transaction, utxoStore *UTXOStore, tokenID string) uint64 {
+	return tokenSurplus(tx, utxoLookup(utxoStore), tokenID)
+}
+
+// tokenSurplus returns how much more of tokenID a transaction spends than it creates,
+// resolving inputs with lookup
+func tokenSurplus(tx *Transaction, lookup func(txID string, index uint32) *TxOutput, tokenID string) uint64 {
+	var in, out uint64
+	for _, input := range tx.Inputs {
+		if output := lookup(input.PrevTxID, input.OutputIndex); output != nil && output.TokenID == tokenID {
+			in += output.Amount
+		}
+	}
+	for _, output := range tx.Outputs {
+		if output.TokenID == tokenID {
+			out += output.Amount
+		}
+	}
+	if out >= in {
+		return 0
+	}
+	return in - out
+}
+
+// tokenFeePool picks the pool a token fee converts through: the token/SHADOW pool with
+// the deepest SHADOW reserve, ties broken by pool ID so every node picks the same one.
+// Returns the pool and its token and SHADOW reserves.
+func tokenFeePool(tokenID string, poolRegistry *PoolRegistry) (*LiquidityPool, uint64, uint64) {
+	genesisTokenID := GetGenesisToken().TokenID
+
+	var best *LiquidityPool
+	var bestToken, bestShadow uint64
+	for _, pool := range poolRegistry.GetAllPools() {
+		var reserveToken, reserveShadow uint64
+		switch {
+		case pool.TokenA == tokenID && pool.TokenB == genesisTokenID:
+			reserveToken, reserveShadow = pool.ReserveA, pool.ReserveB
+		case pool.TokenB == tokenID && pool.TokenA == genesisTokenID:
+			reserveToken, reserveShadow = pool.ReserveB, pool.ReserveA
+		default:
+			continue
+		}
+		if best == nil || reserveShadow > bestShadow || (reserveShadow == bestShadow && pool.PoolID < best.PoolID) {
+			best, bestToken, bestShadow = pool, reserveToken, reserveShadow
+		}
+	}
+	return best, bestToken, bestShadow
+}
+
+// QuoteTokenFee prices a token fee in SHADOW through the token's SHADOW pool. Returns
+// the pool so the caller can apply the conversion.
+func QuoteTokenFee(fee *TokenFee, poolRegistry *PoolRegistry) (uint64, *LiquidityPool, error) {
+	if poolRegistry == nil {
+		return 0, nil, fmt.Errorf("no pool registry")
+	}
+	pool, reserveIn, reserveOut := tokenFeePool(fee.TokenID, poolRegistry)
+	if pool == nil {
+		return 0, nil, fmt.Errorf("no SHADOW pool for token %s", fee.TokenID)
+	}
+	shadow := CalculateSwapOutput(fee.Amount, reserveIn, reserveOut, pool.FeePercent)
+	if shadow == 0 {
+		return 0, nil, fmt.Errorf("token fee of %d is worth nothing in pool %s", fee.Amount, pool.PoolID[:16])
+	}
+	return shadow, pool, nil
+}
+
+// applyTokenFee converts a transaction's token fee into SHADOW for the block winner.
+// Must run before the transaction's inputs are spent. Fees that can't be converted (no
+// winner, no pool, or not actually left unspent) are burned, like any other surplus.
+// Caller holds chainLock
+func (bc *Blockchain) applyTokenFee(tx *Transaction, txID string, block *Block) {
+	if tx.TokenFee == nil || block.WinnerAddress == nil || validateTokenFee(tx) != nil {
+		return
+	}
+	if TokenSurplus(tx, bc.utxoStore, tx.TokenFee.TokenID) < tx.TokenFee.Amount {
+		fmt.Printf("[Chain] Warning: Transaction %s does not leave its token fee unspent\n", txID[:16])
+		return
+	}
+
+	shadow, pool, err := QuoteTokenFee(tx.TokenFee, bc.poolRegistry)
+	if err != nil {
+		fmt.Printf("[Chain] Warning: Token fee of %s burned: %v\n", txID[:16], err)
+		return
+	}
+
+	// The pool takes the fee tokens and pays out the SHADOW
+	pool.observePrice(block.Index)
+	if pool.TokenA == tx.TokenFee.TokenID {
+		pool.ReserveA += tx.TokenFee.Amount
+		pool.ReserveB -= shadow
+	} else {
+		pool.ReserveB += tx.TokenFee.Amount
+		pool.ReserveA -= shadow
+	}
+	pool.K = CalculateK(pool.ReserveA, pool.ReserveB)
+	pool.accrueSwapFee(tx.TokenFee.TokenID, tx.TokenFee.Amount)
+	if err := bc.poolRegistry.UpdatePool(pool); err != nil {
+		fmt.Printf("[Chain] Warning: Failed to update pool for token fee %s: %v\n", txID[:16], err)
+		return
+	}
+	if err := bc.utxoStore.recordFeeGrowth(pool, block.Index); err != nil {
+		fmt.Printf("[Chain] Warning: Failed to record fee growth for token fee %s: %v\n", txID[:16], err)
+	}
+	if err := bc.utxoStore.recordPriceObservation(pool, block.Index); err != nil {
+		fmt.Printf("[Chain] Warning: Failed to record price observation for token fee %s: %v\n", txID[:16], err)
+	}
+
+	// Implicit output after the transaction's own outputs
+	utxo := &UTXO{
+		TxID:        txID,
+		OutputIndex: uint32(len(tx.Outputs)),
+		Output:      CreateShadowOutput(*block.WinnerAddress, shadow),
+		BlockHeight: block.Index,
+		IsSpent:     false,
+	}
+	if err := bc.utxoStore.AddUTXO(utxo); err != nil {
+		fmt.Printf("[Chain] Warning: Failed to pay token fee %s: %v\n", txID[:16], err)
+	}
+}
+
+// SetTokenFeePolicy sets whether token fees count toward this node's relay floor and
+// block selection, priced through the given pools
+func (mp *Mempool) SetTokenFeePolicy(accept bool, poolRegistry *PoolRegistry) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.acceptTokenFees = accept
+	mp.poolRegistry = poolRegistry
+}
+
+// AcceptsTokenFees reports whether this node opted in to token-denominated fees
+func (mp *Mempool) AcceptsTokenFees() bool {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+	return mp.acceptTokenFees
+}
+
+// tokenFeeValue returns the SHADOW value of a transaction's token fee, or 0 if this node
+// doesn't accept token fees or the fee can't be priced
+func (mp *Mempool) tokenFeeValue(tx *Transaction) uint64 {
+	mp.txLock.RLock()
+	accept, poolRegistry, utxoStore := mp.acceptTokenFees, mp.poolRegistry, mp.utxoStore
+	mp.txLock.RUnlock()
+
+	if !accept || tx.TokenFee == nil || utxoStore == nil {
+		return 0
+	}
+	if TokenSurplus(tx, utxoStore, tx.TokenFee.TokenID) < tx.TokenFee.Amount {
+		return 0
+	}
+	shadow, _, err := QuoteTokenFee(tx.TokenFee, poolRegistry)
+	if err != nil {
+		return 0
+	}
+	return shadow
+}
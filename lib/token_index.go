@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unspent outputs are also indexed by token, per address and network-wide, as part of
+// the balance index. Token queries and coin selection for one token read only that
+// token's outputs instead of every output an address holds.
+const (
+	AddrTokenPrefix  = "addrtok:" // addrtok:{address}:{tokenID}:{txid}:{index} -> ""
+	TokenUTXOPrefix  = "tokutxo:" // tokutxo:{tokenID}:{txid}:{index} -> ""
+	MaxTokenUTXOList = 1000
+)
+
+// tokenIndexKeys returns the token index keys of an output
+func tokenIndexKeys(utxo *UTXO) (string, string) {
+	out := utxo.Output
+	outpoint := fmt.Sprintf("%s:%d", utxo.TxID, utxo.OutputIndex)
+	return fmt.Sprintf("%s%s:%s:%s", AddrTokenPrefix, out.Address.String(), out.TokenID, outpoint),
+		fmt.Sprintf("%s%s:%s", TokenUTXOPrefix, out.TokenID, outpoint)
+}
+
+// indexTokenOutput adds or removes an unspent output from the token index (caller holds store.mutex)
+func (store *UTXOStore) indexTokenOutput(utxo *UTXO, add bool) error {
+	addrKey, tokenKey := tokenIndexKeys(utxo)
+	for _, key := range []string{addrKey, tokenKey} {
+		var err error
+		if add {
+			err = store.db.Set([]byte(key), []byte(""))
+		} else {
+			err = store.db.Delete([]byte(key))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// utxosFromIndex reads the unspent outputs whose outpoints follow prefix in the index,
+// at most limit of them (0 = no limit)
+func (store *UTXOStore) utxosFromIndex(prefix string, limit int) ([]*UTXO, error) {
+	iterator, err := store.db.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	var outpoints []string
+	for ; iterator.Valid() && (limit == 0 || len(outpoints) < limit); iterator.Next() {
+		outpoints = append(outpoints, string(iterator.Key()[len(prefix):]))
+	}
+	iterator.Close()
+
+	var utxos []*UTXO
+	for _, outpoint := range outpoints {
+		lastColon := strings.LastIndex(outpoint, ":")
+		if lastColon == -1 {
+			continue // Skip malformed keys
+		}
+		var outputIndex uint32
+		fmt.Sscanf(outpoint[lastColon+1:], "%d", &outputIndex)
+
+		utxo, err := store.GetUTXO(outpoint[:lastColon], outputIndex)
+		if err != nil {
+			continue // Skip errored UTXOs
+		}
+		if utxo != nil && !utxo.IsSpent {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos, nil
+}
+
+// GetUTXOsByAddressAndToken returns an address's unspent outputs of one token
+func (store *UTXOStore) GetUTXOsByAddressAndToken(address Address, tokenID string) ([]*UTXO, error) {
+	return store.utxosFromIndex(fmt.Sprintf("%s%s:%s:", AddrTokenPrefix, address.String(), tokenID), 0)
+}
+
+// GetUTXOsByToken returns up to limit unspent outputs of a token across all addresses
+func (store *UTXOStore) GetUTXOsByToken(tokenID string, limit int) ([]*UTXO, error) {
+	return store.utxosFromIndex(fmt.Sprintf("%s%s:", TokenUTXOPrefix, tokenID), limit)
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenIndex(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	shadowID := GetGenesisToken().TokenID
+	tokenX := strings.Repeat("ab", 32)
+
+	add := func(txID string, addr Address, amount uint64, tokenID string) {
+		utxo := &UTXO{TxID: txID, Output: CreateTokenOutput(addr, amount, tokenID, "custom", nil)}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	add("a1", alice.Address(), 300, shadowID)
+	add("a2", alice.Address(), 20, tokenX)
+	add("a3", alice.Address(), 30, tokenX)
+	add("b1", bob.Address(), 40, tokenX)
+
+	if err := store.SpendUTXO("a3", 0, 5); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+
+	check := func(when string) {
+		if utxos, _ := store.GetUTXOsByAddressAndToken(alice.Address(), tokenX); len(utxos) != 1 || utxos[0].TxID != "a2" {
+			t.Errorf("%s: expected alice's unspent X output only, got %d", when, len(utxos))
+		}
+		if utxos, _ := store.GetUTXOsByAddressAndToken(alice.Address(), shadowID); len(utxos) != 1 || utxos[0].Output.Amount != 300 {
+			t.Errorf("%s: expected alice's SHADOW output only, got %d", when, len(utxos))
+		}
+		if utxos, _ := store.GetUTXOsByToken(tokenX, 0); len(utxos) != 2 {
+			t.Errorf("%s: expected 2 unspent X outputs network-wide, got %d", when, len(utxos))
+		}
+		if utxos, _ := store.GetUTXOsByToken(tokenX, 1); len(utxos) != 1 {
+			t.Errorf("%s: expected limit to apply, got %d", when, len(utxos))
+		}
+	}
+	check("incremental")
+
+	// A rebuild from the UTXO set matches the incremental index
+	if err := store.RebuildBalanceIndex(); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	check("rebuilt")
+}
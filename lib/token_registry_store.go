@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tokens are persisted in the UTXO store as blocks change them, inside the same batch as
+// the block's UTXO updates, and loaded at startup, the same way pools are. Scanning the
+// chain for mints is only needed once for databases from before tokens were persisted;
+// --reindex replays every block and rebuilds them exactly, melts and burns included.
+
+const (
+	TokenPrefix          = "token:"            // token:{token_id} -> storedToken
+	tokenIndexVersionKey = "tokenmeta:version" // Set once the stored tokens are complete
+	tokenIndexVersion    = "1"
+)
+
+// storedToken is a persisted token with its burn history and open collateral positions
+type storedToken struct {
+	Token     *TokenInfo            `json:"token"`
+	Burns     []*BurnRecord         `json:"burns,omitempty"`
+	Positions []*CollateralPosition `json:"positions,omitempty"`
+}
+
+// NewPersistentTokenRegistry creates a token registry that writes every change to store
+func NewPersistentTokenRegistry(store *UTXOStore) *TokenRegistry {
+	tr := NewTokenRegistry()
+	tr.store = store
+	return tr
+}
+
+// persist writes a token and its burns to the store
+func (tr *TokenRegistry) persist(tokenID string) error {
+	if tr.store == nil {
+		return nil
+	}
+	token, exists := tr.Tokens[tokenID]
+	if !exists {
+		return nil
+	}
+	var positions []*CollateralPosition
+	if token.Collateral != nil {
+		positions = tr.ListPositions(func(p *CollateralPosition) bool { return p.TokenID == tokenID })
+	}
+	if err := tr.store.saveToken(&storedToken{Token: token, Burns: tr.Burns[tokenID], Positions: positions}); err != nil {
+		return fmt.Errorf("failed to persist token %s: %w", tokenID, err)
+	}
+	return nil
+}
+
+// persistAll writes every token to the store
+func (tr *TokenRegistry) persistAll() error {
+	for tokenID := range tr.Tokens {
+		if err := tr.persist(tokenID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load adds the tokens in the store to the registry, replacing any with the same ID
+func (tr *TokenRegistry) Load() error {
+	records, err := tr.store.loadTokens()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		tr.Tokens[record.Token.TokenID] = record.Token
+		if len(record.Burns) > 0 {
+			tr.Burns[record.Token.TokenID] = record.Burns
+		}
+		for _, position := range record.Positions {
+			tr.Positions[position.ID] = position
+		}
+	}
+
+	fmt.Printf("[TokenRegistry] Loaded %d tokens from storage\n", len(records))
+	return nil
+}
+
+// saveToken writes a token record
+func (store *UTXOStore) saveToken(record *storedToken) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.db.Set([]byte(TokenPrefix+record.Token.TokenID), data)
+}
+
+// loadTokens reads every stored token record
+func (store *UTXOStore) loadTokens() ([]*storedToken, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(TokenPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var records []*storedToken
+	for ; iterator.Valid(); iterator.Next() {
+		var record storedToken
+		if err := json.Unmarshal(iterator.Value(), &record); err != nil || record.Token == nil {
+			return nil, fmt.Errorf("corrupt token record %s", iterator.Key())
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// HasTokenIndex reports whether the store holds every token, so the registry can be
+// loaded instead of rebuilt from the chain
+func (store *UTXOStore) HasTokenIndex() bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(tokenIndexVersionKey))
+	return err == nil && string(data) == tokenIndexVersion
+}
+
+// markTokenIndex records that the stored tokens are complete
+func (store *UTXOStore) markTokenIndex() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(tokenIndexVersionKey), []byte(tokenIndexVersion)); err != nil {
+		return fmt.Errorf("failed to store token index version: %w", err)
+	}
+	return nil
+}
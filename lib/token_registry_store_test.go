@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain")
+	bc, err := NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	token, err := CreateCustomToken("PERSIST", "PersistedToken", 10, 0, kp.Address())
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	tokenID := strings.Repeat("ef", 32)
+	token.SetTokenID(tokenID)
+	if err := bc.TokenRegistry().RegisterToken(token); err != nil {
+		t.Fatalf("Failed to register token: %v", err)
+	}
+	if err := bc.TokenRegistry().RecordMelt(tokenID, 4); err != nil {
+		t.Fatalf("Failed to record melt: %v", err)
+	}
+
+	// Each chain owns its registry; another chain doesn't see the token
+	other, err := NewBlockchain(filepath.Join(t.TempDir(), "other"))
+	if err != nil {
+		t.Fatalf("Failed to create second chain: %v", err)
+	}
+	if _, exists := other.TokenRegistry().GetToken(tokenID); exists {
+		t.Error("Expected a token registered on one chain to be absent from another")
+	}
+	other.Close()
+	bc.Close()
+
+	// Reopening loads the token with its melt from the store
+	bc, err = NewBlockchain(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen chain: %v", err)
+	}
+	defer bc.Close()
+
+	if !bc.GetUTXOStore().HasTokenIndex() {
+		t.Fatal("Expected the token index to be marked complete")
+	}
+	loaded, exists := bc.TokenRegistry().GetToken(tokenID)
+	if !exists {
+		t.Fatal("Token not loaded from storage")
+	}
+	if loaded.Ticker != "PERSIST" || loaded.TotalMelted != 4 {
+		t.Errorf("Expected PERSIST with 4 melted, got %s with %d", loaded.Ticker, loaded.TotalMelted)
+	}
+	if _, exists := bc.TokenRegistry().GetToken(GetGenesisToken().TokenID); !exists {
+		t.Error("Expected the genesis token in a loaded registry")
+	}
+}
@@ -5,31 +5,71 @@ import (
 	"fmt"
 )
 
-// TokenMintData represents the metadata stored in TX_MINT transaction Data field
+// TokenMintData represents the metadata stored in TX_MINT transaction Data field. A mint
+// either creates a token or, with TokenID set, mints more of one created with part of its
+// supply unissued (see MintSupply).
 type TokenMintData struct {
-	Ticker      string `json:"ticker"`       // 3-32 chars, [A-Za-z0-9]
-	Desc        string `json:"desc"`         // 0-64 chars, [A-Za-z0-9]
-	MaxMint     uint64 `json:"max_mint"`     // Max base units (1 to 21M)
-	MaxDecimals uint8  `json:"max_decimals"` // 0-8 decimals
-	MintVersion uint8  `json:"mint_version"` // Currently 0
+	Ticker        string `json:"ticker"`                   // 3-32 chars, [A-Za-z0-9]
+	Desc          string `json:"desc"`                     // 0-64 chars, [A-Za-z0-9]
+	MaxMint       uint64 `json:"max_mint"`                 // Max base units (1 to 21M)
+	MaxDecimals   uint8  `json:"max_decimals"`             // 0-8 decimals
+	MintVersion   uint8  `json:"mint_version"`             // Issue of the ticker, see TokenRegistry.NextMintVersion
+	InitialSupply uint64 `json:"initial_supply,omitempty"` // Supply minted at creation, 0 = all of it
+
+	// Issue the supply against collateral positions instead (see CollateralTerms)
+	Collateral *CollateralTerms `json:"collateral,omitempty"`
+
+	// Minting more of an existing token
+	TokenID string `json:"token_id,omitempty"`
+	Amount  uint64 `json:"amount,omitempty"`
+}
+
+// IsSupplyMint reports whether the mint adds supply to an existing token
+func (md TokenMintData) IsSupplyMint() bool {
+	return md.TokenID != ""
 }
 
 // CreateTokenMintTransaction creates a TX_MINT transaction per spec
 // Inputs: SHADOW UTXOs totaling (MAX_MINT * 10^MAX_DECIMALS) + fee
 // Outputs: Single token UTXO with full supply sent to creator
+// A reissued ticker takes the next mint version in registry.
 func CreateTokenMintTransaction(
+	registry *TokenRegistry,
 	creator Address,
 	shadowUTXOs []*UTXO,
 	ticker string,
 	desc string,
 	maxMint uint64,
 	maxDecimals uint8,
+) (*Transaction, error) {
+	return CreatePartialTokenMintTransaction(registry, creator, shadowUTXOs, ticker, desc, maxMint, maxDecimals, 0)
+}
+
+// CreatePartialTokenMintTransaction creates a token minting only initialSupply of its
+// supply (0 = all of it) and locking as much SHADOW. The rest can be minted later by the
+// creator or its delegates.
+func CreatePartialTokenMintTransaction(
+	registry *TokenRegistry,
+	creator Address,
+	shadowUTXOs []*UTXO,
+	ticker string,
+	desc string,
+	maxMint uint64,
+	maxDecimals uint8,
+	initialSupply uint64,
 ) (*Transaction, error) {
 	// Calculate total supply
 	totalSupply := maxMint
 	for i := uint8(0); i < maxDecimals; i++ {
 		totalSupply *= 10
 	}
+	minted := totalSupply
+	if initialSupply > 0 {
+		if initialSupply > totalSupply {
+			return nil, fmt.Errorf("initial_supply %d exceeds total supply %d", initialSupply, totalSupply)
+		}
+		minted = initialSupply
+	}
 
 	// Validate ticker/desc format
 	if len(ticker) < 3 || len(ticker) > 32 {
@@ -45,6 +85,12 @@ func CreateTokenMintTransaction(
 		return nil, fmt.Errorf("max_decimals cannot exceed 8")
 	}
 
+	// A reissued ticker gets the next mint version
+	mintVersion, err := registry.NextMintVersion(ticker)
+	if err != nil {
+		return nil, err
+	}
+
 	builder := NewTxBuilder(TxTypeMintToken)
 
 	// Add SHADOW UTXOs as inputs
@@ -61,10 +107,10 @@ func CreateTokenMintTransaction(
 	fee := CalculateTxFee(TxTypeMintToken, len(builder.inputs), 2, 0) // Token output + change
 
 	// Check we have enough SHADOW for staking + fee
-	requiredShadow := totalSupply + fee
+	requiredShadow := minted + fee
 	if totalShadowInput < requiredShadow {
 		return nil, fmt.Errorf("insufficient SHADOW: have %d, need %d (stake %d + fee %d)",
-			totalShadowInput, requiredShadow, totalSupply, fee)
+			totalShadowInput, requiredShadow, minted, fee)
 	}
 
 	// Create token metadata
@@ -73,7 +119,10 @@ func CreateTokenMintTransaction(
 		Desc:        desc,
 		MaxMint:     maxMint,
 		MaxDecimals: maxDecimals,
-		MintVersion: 0,
+		MintVersion: mintVersion,
+	}
+	if minted < totalSupply {
+		mintData.InitialSupply = minted
 	}
 
 	mintDataBytes, err := json.Marshal(mintData)
@@ -86,17 +135,18 @@ func CreateTokenMintTransaction(
 	// Add token output with temporary token ID (will be updated after signing)
 	// For now, use a placeholder - the actual token ID is set during ABCI processing
 	tokenOutput := &TxOutput{
-		Amount:       totalSupply,
+		Amount:       minted,
 		Address:      creator,
 		TokenID:      "PENDING", // Placeholder - actual token ID = TX ID after signing
 		TokenType:    "custom",
-		LockedShadow: totalSupply, // 1:1 SHADOW locked
+		MintVersion:  mintVersion,
+		LockedShadow: minted, // 1:1 SHADOW locked
 		ScriptPubKey: CreateP2PKHScript(creator),
 	}
 	builder.AddCustomOutput(tokenOutput)
 
 	// Add SHADOW change output if any
-	shadowChange := totalShadowInput - totalSupply - fee
+	shadowChange := totalShadowInput - minted - fee
 	if shadowChange > 0 {
 		shadowChangeOutput := CreateShadowOutput(creator, shadowChange)
 		builder.AddCustomOutput(shadowChangeOutput)
@@ -164,6 +214,7 @@ func CreateTokenMeltTransaction(
 			Address:      changeAddress,
 			TokenID:      tokenID,
 			TokenType:    "custom",
+			MintVersion:  tokenUTXOs[0].Output.MintVersion,
 			LockedShadow: changeLockedShadow,
 			ScriptPubKey: CreateP2PKHScript(changeAddress),
 		}
@@ -177,38 +228,69 @@ func CreateTokenMeltTransaction(
 	return builder.Build(), nil
 }
 
+// checkMintVersion requires a mint to carry the next mint version of its ticker
+func checkMintVersion(mintData TokenMintData, registry *TokenRegistry) error {
+	next, err := registry.NextMintVersion(mintData.Ticker)
+	if err != nil {
+		return err
+	}
+	if mintData.MintVersion != next {
+		return fmt.Errorf("mint_version must be %d for ticker %s, got %d",
+			next, mintData.Ticker, mintData.MintVersion)
+	}
+	return nil
+}
+
 // ValidateTokenMintTransaction validates a TX_MINT transaction per spec
 func ValidateTokenMintTransaction(tx *Transaction, registry *TokenRegistry) error {
+	mintData, _, err := checkMintTerms(tx)
+	if err != nil {
+		return err
+	}
+	if mintData.IsSupplyMint() {
+		return nil // Minting rights depend on the height, see CheckMintRights
+	}
+
+	// Check ticker availability
+	if err := registry.CheckTickerAvailable(mintData.Ticker); err != nil {
+		return err
+	}
+	return checkMintVersion(mintData, registry)
+}
+
+// checkMintTerms checks a mint's parameters are within the token limits and that its
+// single token output carries the whole supply with the same amount of SHADOW locked.
+// Returns the mint data and the token output.
+func checkMintTerms(tx *Transaction) (TokenMintData, *TxOutput, error) {
+	var mintData TokenMintData
 	if tx.TxType != TxTypeMintToken {
-		return fmt.Errorf("not a mint transaction")
+		return mintData, nil, fmt.Errorf("not a mint transaction")
 	}
 
 	// Parse mint data
-	var mintData TokenMintData
 	if err := json.Unmarshal(tx.Data, &mintData); err != nil {
-		return fmt.Errorf("invalid mint data: %w", err)
+		return mintData, nil, fmt.Errorf("invalid mint data: %w", err)
+	}
+	if mintData.IsSupplyMint() {
+		output, err := checkSupplyMintTerms(tx, mintData)
+		return mintData, output, err
 	}
 
 	// Validate mint parameters
 	if len(mintData.Ticker) < 3 || len(mintData.Ticker) > 32 {
-		return fmt.Errorf("invalid ticker length")
+		return mintData, nil, fmt.Errorf("invalid ticker length")
 	}
 	if len(mintData.Desc) > 64 {
-		return fmt.Errorf("invalid desc length")
+		return mintData, nil, fmt.Errorf("invalid desc length")
 	}
 	if mintData.MaxMint == 0 || mintData.MaxMint > 21_000_000 {
-		return fmt.Errorf("invalid max_mint: %d", mintData.MaxMint)
+		return mintData, nil, fmt.Errorf("invalid max_mint: %d", mintData.MaxMint)
 	}
 	if mintData.MaxDecimals > 8 {
-		return fmt.Errorf("max_decimals exceeds 8")
+		return mintData, nil, fmt.Errorf("max_decimals exceeds 8")
 	}
-	if mintData.MintVersion != 0 {
-		return fmt.Errorf("mint_version must be 0")
-	}
-
-	// Check ticker availability
-	if err := registry.CheckTickerAvailable(mintData.Ticker); err != nil {
-		return err
+	if mintData.Collateral != nil {
+		return mintData, nil, checkCollateralMintTerms(tx, mintData)
 	}
 
 	// Calculate expected total supply
@@ -219,43 +301,132 @@ func ValidateTokenMintTransaction(tx *Transaction, registry *TokenRegistry) erro
 
 	// Validate outputs - should have exactly one token output
 	if len(tx.Outputs) == 0 {
-		return fmt.Errorf("mint transaction must have at least one output")
+		return mintData, nil, fmt.Errorf("mint transaction must have at least one output")
 	}
 
-	// Find token output
+	// Find token output; everything else is SHADOW change
 	var tokenOutput *TxOutput
+	genesisTokenID := GetGenesisToken().TokenID
 	for _, output := range tx.Outputs {
 		if output.TokenType == "custom" {
 			if tokenOutput != nil {
-				return fmt.Errorf("mint transaction can only create one token type")
+				return mintData, nil, fmt.Errorf("mint transaction can only create one token type")
 			}
 			tokenOutput = output
+		} else if output.TokenID != genesisTokenID {
+			return mintData, nil, fmt.Errorf("mint transaction can only return SHADOW change")
 		}
 	}
 
 	if tokenOutput == nil {
-		return fmt.Errorf("no token output found")
+		return mintData, nil, fmt.Errorf("no token output found")
 	}
 
-	// Validate token output
+	// Validate token output. Its ID is the PENDING placeholder until the mint is applied.
 	txID, _ := tx.ID()
-	if tokenOutput.TokenID != txID {
-		return fmt.Errorf("token ID must equal TX ID")
+	if tokenOutput.TokenID != "PENDING" && tokenOutput.TokenID != txID {
+		return mintData, nil, fmt.Errorf("token ID must equal TX ID")
+	}
+
+	minted := totalSupply
+	if mintData.InitialSupply > 0 {
+		if mintData.InitialSupply > totalSupply {
+			return mintData, nil, fmt.Errorf("initial supply (%d) exceeds total supply (%d)",
+				mintData.InitialSupply, totalSupply)
+		}
+		minted = mintData.InitialSupply
+	}
+
+	if tokenOutput.Amount != minted {
+		return mintData, nil, fmt.Errorf("token output amount (%d) doesn't match minted supply (%d)",
+			tokenOutput.Amount, minted)
 	}
 
-	if tokenOutput.Amount != totalSupply {
-		return fmt.Errorf("token output amount (%d) doesn't match total supply (%d)",
-			tokenOutput.Amount, totalSupply)
+	if tokenOutput.LockedShadow != minted {
+		return mintData, nil, fmt.Errorf("locked SHADOW (%d) must equal minted supply (%d)",
+			tokenOutput.LockedShadow, minted)
 	}
 
-	if tokenOutput.LockedShadow != totalSupply {
-		return fmt.Errorf("locked SHADOW (%d) must equal total supply (%d)",
-			tokenOutput.LockedShadow, totalSupply)
+	if tokenOutput.MintVersion != mintData.MintVersion {
+		return mintData, nil, fmt.Errorf("token output mint version (%d) doesn't match mint data (%d)",
+			tokenOutput.MintVersion, mintData.MintVersion)
 	}
 
+	return mintData, tokenOutput, nil
+}
+
+// CheckTokenMint rejects a mint breaking the token limits or whose SHADOW inputs don't
+// cover the SHADOW it locks: the inputs must all be SHADOW and exceed the change by at
+// least the token supply. Other transactions pass. lookup resolves spent outputs.
+func CheckTokenMint(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	if tx.TxType != TxTypeMintToken {
+		return nil
+	}
+	_, tokenOutput, err := checkMintTerms(tx)
+	if err != nil {
+		return err
+	}
+	if tokenOutput == nil {
+		return nil // A collateralized token locks nothing until it is issued
+	}
+
+	genesisTokenID := GetGenesisToken().TokenID
+	var shadowIn, change uint64
+	for _, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil {
+			return fmt.Errorf("mint input %s:%d not found", input.PrevTxID, input.OutputIndex)
+		}
+		if spent.TokenID != genesisTokenID {
+			return fmt.Errorf("mint input %s:%d is not SHADOW", input.PrevTxID, input.OutputIndex)
+		}
+		shadowIn += spent.Amount
+	}
+	for _, output := range tx.Outputs {
+		if output != tokenOutput {
+			change += output.Amount
+		}
+	}
+	if shadowIn < change || shadowIn-change < tokenOutput.LockedShadow {
+		return fmt.Errorf("mint locks %d SHADOW but its inputs cover %d over the change",
+			tokenOutput.LockedShadow, shadowIn-min(shadowIn, change))
+	}
 	return nil
 }
 
+// ValidateTokenMints rejects a block with a mint that doesn't lock SHADOW equal to the
+// supply it creates or breaks the token limits, or with a supply mint or delegation its
+// signer has no right to
+func (bc *Blockchain) ValidateTokenMints(block *Block, mempool *Mempool) error {
+	mints := newBlockMints()
+	return bc.checkBlockSpends(block, mempool, func(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+		if err := CheckTokenMint(tx, height, lookup); err != nil {
+			return err
+		}
+		return mints.check(bc.tokenRegistry, tx, height)
+	})
+}
+
+// checkTokenMint rejects a mint that doesn't lock SHADOW equal to its supply, resolving
+// inputs from the chain and pending transactions, and a supply mint or delegation its
+// signer has no right to in the next block
+func (mp *Mempool) checkTokenMint(tx *Transaction) error {
+	mp.txLock.RLock()
+	height, utxoStore, tokenRegistry := mp.currentHeight+1, mp.utxoStore, mp.tokenRegistry
+	mp.txLock.RUnlock()
+
+	if utxoStore == nil {
+		return nil
+	}
+	if err := CheckTokenMint(tx, 0, mp.pendingLookup(utxoStore)); err != nil {
+		return err
+	}
+	if tokenRegistry == nil {
+		return nil
+	}
+	return newBlockMints().check(tokenRegistry, tx, height)
+}
+
 // ValidateTokenMeltTransaction validates a TX_MELT transaction per spec
 func ValidateTokenMeltTransaction(tx *Transaction, utxoStore *UTXOStore) error {
 	if tx.TxType != TxTypeMelt {
@@ -336,3 +507,129 @@ func ValidateTokenMeltTransaction(tx *Transaction, utxoStore *UTXOStore) error {
 
 	return nil
 }
+
+// CheckTokenVersions rejects a transaction that spends or creates outputs of a registered
+// token under a mint version other than the token's. Once a ticker is melted and
+// reissued, this keeps outputs of one issue from being passed off as the other's.
+// Inputs lookup can't resolve are left to other validation.
+func (tr *TokenRegistry) CheckTokenVersions(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	check := func(output *TxOutput) error {
+		token, ok := tr.GetToken(output.TokenID)
+		if !ok || output.MintVersion == token.MintVersion {
+			return nil
+		}
+		return fmt.Errorf("%s output has mint version %d, registry has %d",
+			token.Ticker, output.MintVersion, token.MintVersion)
+	}
+
+	for _, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil {
+			continue
+		}
+		if err := check(spent); err != nil {
+			return fmt.Errorf("input %s:%d: %w", input.PrevTxID, input.OutputIndex, err)
+		}
+	}
+	for i, output := range tx.Outputs {
+		if err := check(output); err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateTokenVersions rejects a block with a transaction whose token outputs don't
+// match their registry entry's mint version
+func (bc *Blockchain) ValidateTokenVersions(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, bc.tokenRegistry.CheckTokenVersions)
+}
+
+// mintTicker returns the ticker a mint transaction registers
+func mintTicker(tx *Transaction) (string, bool) {
+	if tx.TxType != TxTypeMintToken {
+		return "", false
+	}
+	var mintData TokenMintData
+	if err := json.Unmarshal(tx.Data, &mintData); err != nil || mintData.IsSupplyMint() {
+		return "", false
+	}
+	return mintData.Ticker, true
+}
+
+// ValidateTokenTickers rejects a block minting a ticker an active token already uses.
+// Mints are checked in block order, so when two mints in the block claim the same ticker
+// the first wins and the block is invalid for carrying the second; a ticker freed by a
+// melt can be minted again from the next block.
+func (bc *Blockchain) ValidateTokenTickers(block *Block, mempool *Mempool) error {
+	minted := make(map[string]string) // Ticker -> mint earlier in the block
+	return bc.checkBlockSpends(block, mempool, func(tx *Transaction, height uint64, lookup func(string, uint32) *TxOutput) error {
+		ticker, ok := mintTicker(tx)
+		if !ok {
+			return nil
+		}
+		if first, taken := minted[ticker]; taken {
+			return fmt.Errorf("ticker %s already minted by %s earlier in the block", ticker, first)
+		}
+		if err := bc.tokenRegistry.CheckTickerAvailable(ticker); err != nil {
+			return err
+		}
+		minted[ticker], _ = tx.ID()
+		return nil
+	})
+}
+
+// filterTickerConflicts drops mints of tickers an active token uses and keeps one mint
+// per ticker, the earliest by timestamp then ID, so a proposer doesn't build a block
+// ValidateTokenTickers rejects
+func filterTickerConflicts(candidates []*Transaction, registry *TokenRegistry) []*Transaction {
+	winners := make(map[string]*Transaction)
+	winnerIDs := make(map[string]string)
+	for _, tx := range candidates {
+		ticker, ok := mintTicker(tx)
+		if !ok {
+			continue
+		}
+		txID, _ := tx.ID()
+		if current, exists := winners[ticker]; exists &&
+			(current.Timestamp < tx.Timestamp || (current.Timestamp == tx.Timestamp && winnerIDs[ticker] < txID)) {
+			continue
+		}
+		winners[ticker], winnerIDs[ticker] = tx, txID
+	}
+
+	kept := make([]*Transaction, 0, len(candidates))
+	for _, tx := range candidates {
+		if ticker, ok := mintTicker(tx); ok {
+			if winners[ticker] != tx {
+				continue
+			}
+			if registry != nil && registry.CheckTickerAvailable(ticker) != nil {
+				continue
+			}
+		}
+		kept = append(kept, tx)
+	}
+	return kept
+}
+
+// SetTokenRegistry sets the registry incoming transactions' token outputs are checked
+// against
+func (mp *Mempool) SetTokenRegistry(registry *TokenRegistry) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.tokenRegistry = registry
+}
+
+// checkTokenVersions rejects a transaction whose token outputs don't match their
+// registry entry's mint version
+func (mp *Mempool) checkTokenVersions(tx *Transaction) error {
+	mp.txLock.RLock()
+	registry := mp.tokenRegistry
+	mp.txLock.RUnlock()
+
+	if registry == nil {
+		return nil
+	}
+	return mp.checkSpends(tx, registry.CheckTokenVersions)
+}
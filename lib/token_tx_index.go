@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Confirmed transactions are also indexed by every token they move, so the history of
+// one token (all SCOIN transfers, say) is a prefix scan. A transaction moves the tokens
+// of its outputs and of the outputs it spends; a mint's new token is indexed under the
+// mint's ID. Keys carry the inverted height, newest first, like the address index.
+
+// TokenTxPrefix is the key prefix of the token transaction index
+const TokenTxPrefix = "tokentx:" // tokentx:{tokenID}:{inverted height}:{txid} -> ""
+
+const (
+	DefaultTokenTxPage = 32  // Transactions per page when the caller doesn't say
+	MaxTokenTxPage     = 100 // Most transactions returned per page
+)
+
+// ErrUnknownCursor is returned when a pagination cursor names a transaction that was
+// never confirmed
+var ErrUnknownCursor = errors.New("after transaction is not confirmed")
+
+// TokenTx is a confirmed transaction that moved a token
+type TokenTx struct {
+	TxID        string       `json:"tx_id"`
+	BlockHeight uint64       `json:"block_height"`
+	Transaction *Transaction `json:"transaction,omitempty"` // Nil once its body is pruned
+}
+
+// tokenTxKey is the token index key of a transaction
+func tokenTxKey(tokenID string, height int64, txID string) string {
+	return fmt.Sprintf("%s%s:%020d:%s", TokenTxPrefix, tokenID, int64(999999999999999999)-height, txID)
+}
+
+// indexTokenTxLocked adds a transaction to the index under each of tokens, the token IDs
+// of the outputs it creates and spends (caller holds store.mutex)
+func (store *UTXOStore) indexTokenTxLocked(tokens map[string]bool, txID string, height int64) error {
+	for tokenID := range tokens {
+		switch tokenID {
+		case "":
+			continue
+		case "PENDING":
+			tokenID = txID // A mint's token takes the mint's ID when it is applied
+		case "SHADOW":
+			tokenID = GetGenesisToken().TokenID
+		}
+		if err := store.db.Set([]byte(tokenTxKey(tokenID, height, txID)), []byte("")); err != nil {
+			return fmt.Errorf("failed to store token-tx index: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetTransactionsByToken returns up to count confirmed transactions that moved a token,
+// newest first, starting after afterTxID if set
+func (store *UTXOStore) GetTransactionsByToken(tokenID string, count int, afterTxID string) ([]TokenTx, error) {
+	if count <= 0 {
+		count = DefaultTokenTxPage
+	}
+	if count > MaxTokenTxPage {
+		count = MaxTokenTxPage
+	}
+
+	prefix := TokenTxPrefix + tokenID + ":"
+	start := prefix
+	if afterTxID != "" {
+		conf, err := store.GetTxConfirmation(afterTxID)
+		if err != nil {
+			return nil, err
+		}
+		if conf == nil {
+			return nil, ErrUnknownCursor
+		}
+		start = tokenTxKey(tokenID, int64(conf.Height), afterTxID) + "\x00" // Just past the cursor
+	}
+
+	// An explicit end, as a start past the prefix would otherwise end the scan at once
+	end := TokenTxPrefix + tokenID + ";"
+
+	store.mutex.RLock()
+	iterator, err := store.db.Iterator([]byte(start), []byte(end))
+	if err != nil {
+		store.mutex.RUnlock()
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	entries := []TokenTx{}
+	for ; iterator.Valid() && len(entries) < count; iterator.Next() {
+		key := string(iterator.Key())
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		parts := strings.SplitN(key[len(prefix):], ":", 2)
+		if len(parts) != 2 {
+			continue // Skip malformed keys
+		}
+		inverted, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, TokenTx{TxID: parts[1], BlockHeight: uint64(999999999999999999 - inverted)})
+	}
+	iterator.Close()
+	store.mutex.RUnlock()
+
+	for i := range entries {
+		tx, err := store.GetTransaction(entries[i].TxID)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Transaction = tx
+	}
+	return entries, nil
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenTxIndex(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to create UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+	shadowID := GetGenesisToken().TokenID
+	apply := func(tx *Transaction, height int64) string {
+		txID, _ := tx.ID()
+		if err := store.StoreTransaction(tx, height); err != nil {
+			t.Fatalf("Failed to store transaction: %v", err)
+		}
+		if err := store.recordTxConfirmation(txID, uint64(height), "block"); err != nil {
+			t.Fatalf("Failed to record confirmation: %v", err)
+		}
+		for i, output := range tx.Outputs {
+			if output.TokenID == "PENDING" {
+				output.TokenID = txID
+			}
+			if err := store.AddUTXO(&UTXO{TxID: txID, OutputIndex: uint32(i), Output: output, BlockHeight: uint64(height)}); err != nil {
+				t.Fatalf("Failed to add UTXO: %v", err)
+			}
+		}
+		return txID
+	}
+
+	// The mint is indexed under the token it creates
+	mint := NewTxBuilder(TxTypeMintToken).
+		AddCustomOutput(CreateTokenOutput(alice.Address(), 1_000, "PENDING", "custom", nil)).
+		AddCustomOutput(CreateShadowOutput(alice.Address(), 5_000)).Build()
+	tokenID := apply(mint, 2)
+
+	// A transfer spending the token is indexed under it, and under SHADOW for its SHADOW output
+	transfer := NewTxBuilder(TxTypeSend).AddInput(tokenID, 0).
+		AddCustomOutput(CreateTokenOutput(bob.Address(), 400, tokenID, "custom", nil)).
+		AddCustomOutput(CreateTokenOutput(alice.Address(), 600, tokenID, "custom", nil)).Build()
+	transferID := apply(transfer, 5)
+	other := NewTxBuilder(TxTypeSend).AddCustomOutput(CreateShadowOutput(bob.Address(), 1)).Build()
+	otherID := apply(other, 6)
+
+	txs, err := store.GetTransactionsByToken(tokenID, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to list token transactions: %v", err)
+	}
+	if len(txs) != 2 || txs[0].TxID != transferID || txs[0].BlockHeight != 5 || txs[1].TxID != tokenID || txs[1].BlockHeight != 2 {
+		t.Fatalf("Expected the transfer then the mint, got %+v", txs)
+	}
+	if txs[0].Transaction == nil || len(txs[0].Transaction.Outputs) != 2 {
+		t.Errorf("Expected the transfer's body, got %+v", txs[0].Transaction)
+	}
+
+	shadow, _ := store.GetTransactionsByToken(shadowID, 0, "")
+	if len(shadow) != 2 || shadow[0].TxID != otherID || shadow[1].TxID != tokenID {
+		t.Errorf("Expected the SHADOW send then the mint under SHADOW, got %+v", shadow)
+	}
+
+	// Pages continue after the cursor
+	page, err := store.GetTransactionsByToken(tokenID, 1, "")
+	if err != nil || len(page) != 1 || page[0].TxID != transferID {
+		t.Fatalf("Expected the first page to hold the transfer, got %+v (%v)", page, err)
+	}
+	page, err = store.GetTransactionsByToken(tokenID, 1, page[0].TxID)
+	if err != nil || len(page) != 1 || page[0].TxID != tokenID {
+		t.Fatalf("Expected the second page to hold the mint, got %+v (%v)", page, err)
+	}
+	if page, _ = store.GetTransactionsByToken(tokenID, 1, tokenID); len(page) != 0 {
+		t.Errorf("Expected nothing after the mint, got %+v", page)
+	}
+	if _, err := store.GetTransactionsByToken(tokenID, 1, "missing"); !errors.Is(err, ErrUnknownCursor) {
+		t.Errorf("Expected ErrUnknownCursor, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenReissueMintVersion(t *testing.T) {
+	registry := NewTokenRegistry()
+
+	kp, _ := GenerateKeyPair()
+	first, err := CreateCustomToken("REISSUE", "FirstIssue", 10, 0, kp.Address())
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	first.SetTokenID("first-mint")
+	if err := registry.RegisterToken(first); err != nil {
+		t.Fatalf("Failed to register first issue: %v", err)
+	}
+	if err := registry.RecordMelt(first.TokenID, first.TotalSupply); err != nil {
+		t.Fatalf("Failed to melt first issue: %v", err)
+	}
+
+	// The reissued ticker must take the next mint version
+	second, _ := CreateCustomToken("REISSUE", "SecondIssue", 10, 0, kp.Address())
+	second.SetTokenID("second-mint")
+	if err := registry.RegisterToken(second); err == nil {
+		t.Fatal("Expected a reissue reusing mint version 0 to be rejected")
+	}
+	second.MintVersion = 1
+	if err := registry.RegisterToken(second); err != nil {
+		t.Fatalf("Failed to register second issue: %v", err)
+	}
+	if next, _ := registry.NextMintVersion("REISSUE"); next != 2 {
+		t.Errorf("Expected next mint version 2, got %d", next)
+	}
+	if err := checkMintVersion(TokenMintData{Ticker: "REISSUE", MintVersion: 1}, registry); err == nil {
+		t.Error("Expected a mint repeating version 1 to be rejected")
+	}
+
+	// Outputs are stamped with their token's mint version
+	output := registry.NewTokenOutput(kp.Address(), 5, second.TokenID, "custom", nil)
+	if output.MintVersion != 1 {
+		t.Fatalf("Expected the output to carry mint version 1, got %d", output.MintVersion)
+	}
+	outputs := map[string]*TxOutput{"funding:0": output}
+	lookup := func(txID string, index uint32) *TxOutput { return outputs[txID+":0"] }
+
+	spend := NewTxBuilder(TxTypeSend).SetRegistry(registry).AddInput("funding", 0).
+		AddOutput(kp.Address(), 5, second.TokenID).Build()
+	if err := registry.CheckTokenVersions(spend, 10, lookup); err != nil {
+		t.Fatalf("Valid spend rejected: %v", err)
+	}
+
+	// An output claiming the melted issue's version can't be created or spent
+	replayed := *output
+	replayed.MintVersion = 0
+	forged := NewTxBuilder(TxTypeSend).AddInput("other", 0).AddCustomOutput(&replayed).Build()
+	if err := registry.CheckTokenVersions(forged, 10, lookup); err == nil {
+		t.Error("Expected an output with a stale mint version to be rejected")
+	}
+	outputs["funding:0"] = &replayed
+	if err := registry.CheckTokenVersions(spend, 10, lookup); err == nil {
+		t.Error("Expected a spend of an output with a stale mint version to be rejected")
+	}
+}
+
+func TestTickerUniquenessInBlocks(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	mint := func(ticker string, timestamp int64) *Transaction {
+		data, _ := json.Marshal(TokenMintData{Ticker: ticker, Desc: "Test", MaxMint: 10})
+		return NewTxBuilder(TxTypeMintToken).SetTimestamp(timestamp).SetData(data).
+			AddOutput(kp.Address(), 10, "PENDING").Build()
+	}
+	first, second, other := mint("DUPE", 100), mint("DUPE", 200), mint("OTHER", 300)
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry)}
+	block := func(txs ...*Transaction) *Block {
+		ids := []string{}
+		for _, tx := range txs {
+			id, _ := tx.ID()
+			mempool.entries[id] = &MempoolEntry{Tx: tx}
+			ids = append(ids, id)
+		}
+		return &Block{Index: 1, Transactions: ids}
+	}
+
+	if err := bc.ValidateTokenTickers(block(first, other), mempool); err != nil {
+		t.Fatalf("Expected distinct tickers to be valid: %v", err)
+	}
+	// The first mint in block order wins, whichever was created first
+	if err := bc.ValidateTokenTickers(block(second, other, first), mempool); err == nil {
+		t.Fatal("Expected a block minting a ticker twice to be rejected")
+	}
+
+	// Proposers keep the earliest mint of each ticker
+	kept := filterTickerConflicts([]*Transaction{second, other, first}, bc.tokenRegistry)
+	if len(kept) != 2 || kept[0] != other || kept[1] != first {
+		t.Fatalf("Expected the other mint and the first DUPE mint, got %d transactions", len(kept))
+	}
+
+	// A ticker an active token holds can't be minted again
+	token, _ := CreateCustomToken("DUPE", "Active", 10, 0, kp.Address())
+	token.SetTokenID("active-dupe")
+	if err := bc.tokenRegistry.RegisterToken(token); err != nil {
+		t.Fatalf("Failed to register token: %v", err)
+	}
+	if err := bc.ValidateTokenTickers(block(first), mempool); err == nil {
+		t.Fatal("Expected a mint of an active ticker to be rejected")
+	}
+	if kept := filterTickerConflicts([]*Transaction{first, other}, bc.tokenRegistry); len(kept) != 1 || kept[0] != other {
+		t.Fatalf("Expected the active ticker's mint to be left out, got %d transactions", len(kept))
+	}
+}
+
+func TestMintLocksSupply(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	funding := &UTXO{TxID: "mint-funding", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 5_000_000)}
+	outputs := map[string]*TxOutput{"mint-funding:0": funding.Output}
+	lookup := func(txID string, index uint32) *TxOutput { return outputs[txID+":0"] }
+
+	mint, err := CreateTokenMintTransaction(NewTokenRegistry(), kp.Address(), []*UTXO{funding}, "STAKED", "", 1000, 2)
+	if err != nil {
+		t.Fatalf("Failed to create mint: %v", err)
+	}
+	if err := CheckTokenMint(mint, 10, lookup); err != nil {
+		t.Fatalf("Expected the mint to lock its supply: %v", err)
+	}
+	if err := ValidateTokenMintTransaction(mint, NewTokenRegistry()); err != nil {
+		t.Fatalf("Expected a pending mint to validate: %v", err)
+	}
+
+	// Keeping the staked SHADOW as change leaves the supply unbacked
+	unbacked := *mint
+	unbacked.Outputs = []*TxOutput{mint.Outputs[0], CreateShadowOutput(kp.Address(), mint.Outputs[1].Amount+100_000)}
+	if err := CheckTokenMint(&unbacked, 10, lookup); err == nil {
+		t.Error("Expected a mint not consuming its stake to be rejected")
+	}
+
+	// The token output must carry exactly the supply the terms allow
+	inflated := *mint
+	token := *mint.Outputs[0]
+	token.Amount *= 2
+	token.LockedShadow *= 2
+	inflated.Outputs = []*TxOutput{&token, mint.Outputs[1]}
+	if err := CheckTokenMint(&inflated, 10, lookup); err == nil {
+		t.Error("Expected a mint over its max_mint to be rejected")
+	}
+
+	// Stake must be SHADOW
+	outputs["mint-funding:0"] = CreateTokenOutput(kp.Address(), 5_000_000, "other-token", "custom", nil)
+	if err := CheckTokenMint(mint, 10, lookup); err == nil {
+		t.Error("Expected a mint staking another token to be rejected")
+	}
+}
@@ -2,6 +2,7 @@ package lib
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"time"
 
@@ -18,66 +19,57 @@ type TokenInfo struct {
 	Desc   string `json:"desc"`   // 0-64 chars, [A-Za-z0-9] only (optional description)
 
 	// Token economics
-	MaxMint       uint64 `json:"max_mint"`       // Maximum base units (before decimals), max 21 million
-	MaxDecimals   uint8  `json:"max_decimals"`   // Number of decimal places (0-8 for SHADOW decimals)
-	TotalSupply   uint64 `json:"total_supply"`   // Total token supply in smallest unit (MaxMint * 10^MaxDecimals)
-	LockedShadow  uint64 `json:"locked_shadow"`  // SHADOW satoshis locked (1:1 with TotalSupply for custom tokens)
-	TotalMelted   uint64 `json:"total_melted"`   // Total tokens melted (for tracking when ticker can be reused)
-	MintVersion   uint8  `json:"mint_version"`   // Version of minting logic (currently 0)
+	MaxMint      uint64 `json:"max_mint"`           // Maximum base units (before decimals), max 21 million
+	MaxDecimals  uint8  `json:"max_decimals"`       // Number of decimal places (0-8 for SHADOW decimals)
+	TotalSupply  uint64 `json:"total_supply"`       // Total token supply in smallest unit (MaxMint * 10^MaxDecimals)
+	LockedShadow uint64 `json:"locked_shadow"`      // SHADOW satoshis locked (1:1 with TotalSupply for custom tokens)
+	TotalMelted  uint64 `json:"total_melted"`       // Total tokens melted (for tracking when ticker can be reused)
+	TotalBurned  uint64 `json:"total_burned"`       // Total tokens destroyed by burn transactions
+	MintVersion  uint8  `json:"mint_version"`       // Issue of the ticker: 0 for its first token, +1 each time a fully melted ticker is reissued
+	Unissued     uint64 `json:"unissued,omitempty"` // Supply under the cap not minted yet (see MintSupply)
+
+	// Addresses the creator let mint the unissued supply
+	MintDelegates []*MintDelegation `json:"mint_delegates,omitempty"`
+
+	// Issued against collateral positions instead of locking SHADOW 1:1 (see CollateralTerms)
+	Collateral *CollateralTerms `json:"collateral,omitempty"`
 
 	// Creation metadata
 	CreatorAddress Address `json:"creator_address"` // Address that created this token
 	CreationTime   int64   `json:"creation_time"`   // Unix timestamp when created
 }
 
-// GenesisTokenInfo creates the base SHADOW token for the network
+// GenesisTokenInfo creates the base SHADOW token for the network (see ActiveGenesis)
 func GenesisTokenInfo() *TokenInfo {
-	// Use a deterministic address for genesis (all zeros for system)
-	var genesisAddr Address // Zero address for system-created tokens
-
-	// Fixed genesis creation time for deterministic token ID
-	genesisTime := int64(1704067200) // 2024-01-01 00:00:00 GMT
-
-	maxMint := uint64(21_000_000)  // 21 million base units
-	maxDecimals := uint8(8)        // 8 decimal places
-	totalSupply := uint64(2_100_000_000_000_000) // 21M * 10^8
-
-	tokenInfo := &TokenInfo{
-		TokenID:        calculateGenesisTokenID(), // Deterministic genesis hash
-		Ticker:         "SHADOW",
-		Desc:           "Base token for Shadow Network",
-		MaxMint:        maxMint,
-		MaxDecimals:    maxDecimals,
-		TotalSupply:    totalSupply,
-		LockedShadow:   0, // Base token doesn't lock SHADOW
-		TotalMelted:    0, // No tokens melted yet
-		MintVersion:    0,
-		CreatorAddress: genesisAddr,
-		CreationTime:   genesisTime,
-	}
-
-	return tokenInfo
+	return ActiveGenesis().TokenInfo()
 }
 
 // calculateGenesisTokenID creates a deterministic token ID for genesis SHADOW token
 func calculateGenesisTokenID() string {
-	// Use deterministic hash based on genesis parameters
-	// This ensures SHADOW token ID is stable across code changes
-	genesisTime := int64(1704067200) // 2024-01-01 00:00:00 GMT
-	maxMint := uint64(21_000_000)
-	maxDecimals := uint8(8)
-	ticker := "SHADOW"
-
-	// Hash the genesis parameters
+	return GenesisTokenInfo().TokenID
+}
+
+// genesisTokenID hashes the genesis token parameters into its ID
+// This ensures SHADOW token ID is stable across code changes
+func genesisTokenID(ticker string, genesisTime int64, maxMint uint64, maxDecimals uint8) string {
 	hashInput := fmt.Sprintf("%s_%d_%d_%d", ticker, genesisTime, maxMint, maxDecimals)
 	hash := make([]byte, 32)
 	sha3.ShakeSum256(hash, []byte(hashInput))
 	return fmt.Sprintf("%x", hash)
 }
 
+// Issued returns the supply minted so far: the whole supply unless the token was created
+// with part of it left to mint later
+func (ti *TokenInfo) Issued() uint64 {
+	return ti.TotalSupply - ti.Unissued
+}
+
 // IsFullyMelted returns true if all tokens have been melted
 func (ti *TokenInfo) IsFullyMelted() bool {
-	return ti.TotalMelted >= ti.TotalSupply
+	if ti.Collateral != nil {
+		return false // Melted back into positions, which can always issue again
+	}
+	return ti.TotalMelted >= ti.Issued()
 }
 
 // Validate checks if the token info is valid per the spec
@@ -114,11 +106,6 @@ func (ti *TokenInfo) Validate() error {
 		return fmt.Errorf("max_decimals cannot exceed 8 (SHADOW decimals), got %d", ti.MaxDecimals)
 	}
 
-	// Validate MINT_VERSION (currently must be 0)
-	if ti.MintVersion != 0 {
-		return fmt.Errorf("mint_version must be 0, got %d", ti.MintVersion)
-	}
-
 	// Validate TotalSupply matches MaxMint * 10^MaxDecimals
 	expectedSupply := ti.MaxMint
 	for i := uint8(0); i < ti.MaxDecimals; i++ {
@@ -129,10 +116,14 @@ func (ti *TokenInfo) Validate() error {
 			ti.TotalSupply, expectedSupply)
 	}
 
-	// For custom tokens, validate staking (LockedShadow must equal TotalSupply)
-	if !ti.IsBaseToken() && ti.LockedShadow != ti.TotalSupply {
-		return fmt.Errorf("locked_shadow (%d) must equal total_supply (%d) for custom tokens",
-			ti.LockedShadow, ti.TotalSupply)
+	// For custom tokens, validate staking (LockedShadow must equal the issued supply;
+	// collateralized tokens lock what their positions hold)
+	if ti.Unissued > ti.TotalSupply {
+		return fmt.Errorf("unissued (%d) exceeds total_supply (%d)", ti.Unissued, ti.TotalSupply)
+	}
+	if !ti.IsBaseToken() && ti.Collateral == nil && ti.LockedShadow != ti.Issued() {
+		return fmt.Errorf("locked_shadow (%d) must equal issued supply (%d) for custom tokens",
+			ti.LockedShadow, ti.Issued())
 	}
 
 	// Validate creation time
@@ -193,12 +184,12 @@ func (ti *TokenInfo) CalculateMeltValue(tokenAmount uint64) uint64 {
 		return 0 // Cannot melt SHADOW
 	}
 
-	if ti.TotalSupply == 0 {
+	if ti.Issued() == 0 {
 		return 0
 	}
 
-	// Return proportional SHADOW: (melted_amount / total_supply) * locked_shadow
-	return (tokenAmount * ti.LockedShadow) / ti.TotalSupply
+	// Return proportional SHADOW: (melted_amount / issued_supply) * locked_shadow
+	return (tokenAmount * ti.LockedShadow) / ti.Issued()
 }
 
 // CreateCustomToken creates a new custom token (token ID will be set when minting TX is created)
@@ -234,9 +225,15 @@ func CreateCustomToken(ticker, desc string, maxMint uint64, maxDecimals uint8, c
 	return tokenInfo, nil
 }
 
-// TokenRegistry represents a collection of token information
+// TokenRegistry represents a collection of token information. Each Blockchain owns one;
+// changes made through its methods are persisted when it has a store.
 type TokenRegistry struct {
-	Tokens map[string]*TokenInfo `json:"tokens"` // TokenID -> TokenInfo
+	Tokens map[string]*TokenInfo    `json:"tokens"`          // TokenID -> TokenInfo
+	Burns  map[string][]*BurnRecord `json:"burns,omitempty"` // TokenID -> burns, oldest first
+
+	Positions map[string]*CollateralPosition `json:"positions,omitempty"` // Position ID -> open position
+
+	store *UTXOStore // Persists every change; nil keeps tokens in memory only
 }
 
 // NewTokenRegistry creates a new token registry with genesis token
@@ -244,7 +241,9 @@ func NewTokenRegistry() *TokenRegistry {
 	genesis := GenesisTokenInfo()
 
 	registry := &TokenRegistry{
-		Tokens: make(map[string]*TokenInfo),
+		Tokens:    make(map[string]*TokenInfo),
+		Burns:     make(map[string][]*BurnRecord),
+		Positions: make(map[string]*CollateralPosition),
 	}
 
 	registry.Tokens[genesis.TokenID] = genesis
@@ -266,8 +265,18 @@ func (tr *TokenRegistry) RegisterToken(tokenInfo *TokenInfo) error {
 		return err
 	}
 
+	// A reissued ticker must carry the next mint version so its outputs can't be
+	// confused with the melted issue's
+	next, err := tr.NextMintVersion(tokenInfo.Ticker)
+	if err != nil {
+		return err
+	}
+	if tokenInfo.MintVersion != next {
+		return fmt.Errorf("token %s has mint version %d, expected %d", tokenInfo.Ticker, tokenInfo.MintVersion, next)
+	}
+
 	tr.Tokens[tokenInfo.TokenID] = tokenInfo
-	return nil
+	return tr.persist(tokenInfo.TokenID)
 }
 
 // UpdateToken updates an existing token's information (e.g., supply changes)
@@ -278,7 +287,7 @@ func (tr *TokenRegistry) UpdateToken(tokenInfo *TokenInfo) error {
 
 	// Update the token (validation skipped since it's an update, not initial registration)
 	tr.Tokens[tokenInfo.TokenID] = tokenInfo
-	return nil
+	return tr.persist(tokenInfo.TokenID)
 }
 
 // CheckTickerAvailable returns error if ticker is in use by an active token
@@ -291,19 +300,37 @@ func (tr *TokenRegistry) CheckTickerAvailable(ticker string) error {
 	return nil
 }
 
+// NextMintVersion returns the mint version a new token with ticker gets: one past the
+// latest issue of the ticker, or 0 if it was never used
+func (tr *TokenRegistry) NextMintVersion(ticker string) (uint8, error) {
+	next := 0
+	for _, token := range tr.Tokens {
+		if token.Ticker == ticker && int(token.MintVersion) >= next {
+			next = int(token.MintVersion) + 1
+		}
+	}
+	if next > math.MaxUint8 {
+		return 0, fmt.Errorf("ticker %s has been reissued the maximum number of times", ticker)
+	}
+	return uint8(next), nil
+}
+
 // RecordMelt updates the total melted amount for a token
 func (tr *TokenRegistry) RecordMelt(tokenID string, amount uint64) error {
 	token, exists := tr.Tokens[tokenID]
 	if !exists {
 		return fmt.Errorf("token %s not found", tokenID)
 	}
+	if token.Collateral != nil {
+		return fmt.Errorf("%s is collateralized: melt it against a position", token.Ticker)
+	}
 
 	token.TotalMelted += amount
-	if token.TotalMelted > token.TotalSupply {
-		return fmt.Errorf("total melted (%d) exceeds total supply (%d)", token.TotalMelted, token.TotalSupply)
+	if token.TotalMelted > token.Issued() {
+		return fmt.Errorf("total melted (%d) exceeds issued supply (%d)", token.TotalMelted, token.Issued())
 	}
 
-	return nil
+	return tr.persist(tokenID)
 }
 
 // GetToken retrieves token info by ID
@@ -312,6 +339,26 @@ func (tr *TokenRegistry) GetToken(tokenID string) (*TokenInfo, bool) {
 	return token, exists
 }
 
+// MintVersion returns the mint version of a registered token, 0 if it is unknown or the
+// registry is nil
+func (tr *TokenRegistry) MintVersion(tokenID string) uint8 {
+	if tr == nil {
+		return 0
+	}
+	if token, ok := tr.Tokens[tokenID]; ok {
+		return token.MintVersion
+	}
+	return 0
+}
+
+// NewTokenOutput creates a token output stamped with the token's mint version in this
+// registry
+func (tr *TokenRegistry) NewTokenOutput(address Address, amount uint64, tokenID, tokenType string, data []byte) *TxOutput {
+	output := CreateTokenOutput(address, amount, tokenID, tokenType, data)
+	output.MintVersion = tr.MintVersion(tokenID)
+	return output
+}
+
 // GetGenesisTokenID returns the genesis SHADOW token ID
 func (tr *TokenRegistry) GetGenesisTokenID() string {
 	genesis := GenesisTokenInfo()
@@ -358,29 +405,7 @@ func isASCII(s string) bool {
 	return true
 }
 
-// Global token registry instance
-var globalTokenRegistry *TokenRegistry
-
-// InitializeTokenRegistry initializes the global token registry
-func InitializeTokenRegistry() {
-	globalTokenRegistry = NewTokenRegistry()
-}
-
-// GetGlobalTokenRegistry returns the global token registry
-func GetGlobalTokenRegistry() *TokenRegistry {
-	if globalTokenRegistry == nil {
-		InitializeTokenRegistry()
-	}
-	return globalTokenRegistry
-}
-
 // GetGenesisToken returns the genesis SHADOW token info
 func GetGenesisToken() *TokenInfo {
 	return GenesisTokenInfo()
 }
-
-// IsValidTokenID checks if a token ID is valid (exists in global registry)
-func IsValidTokenID(tokenID string) bool {
-	registry := GetGlobalTokenRegistry()
-	return registry.ValidateTokenID(tokenID)
-}
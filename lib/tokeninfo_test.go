@@ -395,12 +395,6 @@ func TestTokenRegistry(t *testing.T) {
 		t.Error("Should fail to register duplicate token")
 	}
 
-	// Test global registry functions
-	globalRegistry := GetGlobalTokenRegistry()
-	if globalRegistry == nil {
-		t.Error("Global registry should not be nil")
-	}
-
 	genesisFromGlobal := GetGenesisToken()
 	if genesisFromGlobal.TokenID != genesisTokenID {
 		t.Error("Global genesis token should match registry genesis")
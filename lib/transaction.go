@@ -14,8 +14,12 @@ type Transaction struct {
 	Version    uint32 `json:"version"`               // Transaction version
 	Timestamp  int64  `json:"timestamp"`             // Transaction timestamp
 	LockTime   uint32 `json:"lock_time"`             // Lock time (0 = immediate)
-	MempoolTTL uint32 `json:"mempool_ttl,omitempty"` // Block epoch to discard tx if no match
+	MempoolTTL uint32 `json:"mempool_ttl,omitempty"` // Last block height that may include the tx (0 = never expires)
 	TokenID    string `json:"token_id"`              // Hash of token being operated on
+
+	// Optional fee paid in a custom token instead of SHADOW (send only)
+	TokenFee *TokenFee `json:"token_fee,omitempty"`
+
 	// UTXO inputs and outputs
 	Inputs  []*TxInput  `json:"inputs"`  // Transaction inputs (UTXOs being spent)
 	Outputs []*TxOutput `json:"outputs"` // Transaction outputs (new UTXOs being created)
@@ -35,22 +39,46 @@ type Transaction struct {
 	Nonce  *uint64  `json:"nonce,omitempty"`  // Deprecated: not needed in UTXO model
 }
 
+// Transaction versions. Version 1 transactions hash Go's json.Marshal output, which
+// depends on struct field order; they stay valid so stored transactions keep their IDs.
+// Version 2 transactions hash the canonical JSON encoding (see CanonicalJSON), bind their
+// signature to the chain ID (see SigningHash) and are what the builder produces.
+const (
+	LegacyTxVersion     = 1
+	CanonicalTxVersion  = 2
+	ChainBoundTxVersion = 2 // Signatures cover the chain ID from this version on
+	MaxTxVersion        = CanonicalTxVersion
+)
+
+// CheckTxVersion refuses a new transaction whose signature doesn't cover the chain ID.
+// Version 1 transactions already in blocks still validate; only admission refuses them,
+// so one signed for another network can't be replayed here.
+func CheckTxVersion(tx *Transaction) error {
+	if tx.Version < ChainBoundTxVersion {
+		return fmt.Errorf("transaction version %d is not bound to a chain, version %d or later is required", tx.Version, ChainBoundTxVersion)
+	}
+	return nil
+}
+
 // TxBuilder helps construct UTXO-based transactions
 type TxBuilder struct {
 	txType    TxType
 	version   uint32
 	timestamp int64
 	lockTime  uint32
+	ttl       uint32
+	tokenFee  *TokenFee
 	inputs    []*TxInput
 	outputs   []*TxOutput
 	data      []byte
+	registry  *TokenRegistry // Stamps token outputs with their mint version
 }
 
 // NewTxBuilder creates a new transaction builder
 func NewTxBuilder(txType TxType) *TxBuilder {
 	return &TxBuilder{
 		txType:    txType,
-		version:   1,
+		version:   CanonicalTxVersion,
 		timestamp: time.Now().Unix(),
 		lockTime:  0,
 		inputs:    make([]*TxInput, 0),
@@ -73,12 +101,19 @@ func (tb *TxBuilder) AddOutput(address Address, amount uint64, tokenID string) *
 	if tokenID == "" || tokenID == "SHADOW" || tokenID == genesisTokenID {
 		output = CreateShadowOutput(address, amount)
 	} else {
-		output = CreateTokenOutput(address, amount, tokenID, "custom", nil)
+		output = tb.registry.NewTokenOutput(address, amount, tokenID, "custom", nil)
 	}
 	tb.outputs = append(tb.outputs, output)
 	return tb
 }
 
+// SetRegistry stamps token outputs added by name with their mint version in registry.
+// Without one they carry mint version 0, the first issue of their ticker.
+func (tb *TxBuilder) SetRegistry(registry *TokenRegistry) *TxBuilder {
+	tb.registry = registry
+	return tb
+}
+
 // AddCustomOutput adds a custom output with full control
 func (tb *TxBuilder) AddCustomOutput(output *TxOutput) *TxBuilder {
 	tb.outputs = append(tb.outputs, output)
@@ -106,15 +141,30 @@ func (tb *TxBuilder) SetLockTime(lockTime uint32) *TxBuilder {
 	return tb
 }
 
+// SetMempoolTTL sets the last block height that may include the transaction
+func (tb *TxBuilder) SetMempoolTTL(height uint32) *TxBuilder {
+	tb.ttl = height
+	return tb
+}
+
+// SetTokenFee pays the fee in a custom token: amount of tokenID is left unspent and
+// converted to SHADOW for the block winner through the token's SHADOW pool
+func (tb *TxBuilder) SetTokenFee(tokenID string, amount uint64) *TxBuilder {
+	tb.tokenFee = &TokenFee{TokenID: tokenID, Amount: amount}
+	return tb
+}
+
 // Build creates an unsigned transaction
 func (tb *TxBuilder) Build() *Transaction {
 	tx := &Transaction{
-		TxType:    tb.txType,
-		Version:   tb.version,
-		Timestamp: tb.timestamp,
-		LockTime:  tb.lockTime,
-		Inputs:    make([]*TxInput, len(tb.inputs)),
-		Outputs:   make([]*TxOutput, len(tb.outputs)),
+		TxType:     tb.txType,
+		Version:    tb.version,
+		Timestamp:  tb.timestamp,
+		LockTime:   tb.lockTime,
+		MempoolTTL: tb.ttl,
+		TokenFee:   tb.tokenFee,
+		Inputs:     make([]*TxInput, len(tb.inputs)),
+		Outputs:    make([]*TxOutput, len(tb.outputs)),
 	}
 
 	// Deep copy inputs and outputs
@@ -133,17 +183,25 @@ func (tb *TxBuilder) Build() *Transaction {
 func (tx *Transaction) Hash() ([]byte, error) {
 	// Create a copy without signature fields for hashing
 	unsignedTx := &Transaction{
-		TxType:    tx.TxType,
-		Version:   tx.Version,
-		Timestamp: tx.Timestamp,
-		LockTime:  tx.LockTime,
-		Inputs:    tx.Inputs,
-		Outputs:   tx.Outputs,
-		Data:      tx.Data,
+		TxType:     tx.TxType,
+		Version:    tx.Version,
+		Timestamp:  tx.Timestamp,
+		LockTime:   tx.LockTime,
+		MempoolTTL: tx.MempoolTTL, // Omitted when unset, so hashes of txs without a TTL are unchanged
+		TokenFee:   tx.TokenFee,   // Likewise omitted when unset
+		Inputs:     tx.Inputs,
+		Outputs:    tx.Outputs,
+		Data:       tx.Data,
 		// Exclude signature fields from hash
 	}
 
-	bytes, err := json.Marshal(unsignedTx)
+	var bytes []byte
+	var err error
+	if tx.Version >= CanonicalTxVersion {
+		bytes, err = CanonicalJSON(unsignedTx)
+	} else {
+		bytes, err = json.Marshal(unsignedTx)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal transaction: %w", err)
 	}
@@ -152,22 +210,46 @@ func (tx *Transaction) Hash() ([]byte, error) {
 	return hash[:], nil
 }
 
+// SigningHash is the payload signed by the transaction's key: the transaction hash bound
+// to the chain ID, so a signature is only valid on the network it was made for. Version 1
+// transactions were signed over the bare Hash before chain binding and are still checked
+// against it, so blocks already on chain keep validating. The transaction ID still
+// derives from Hash.
+func (tx *Transaction) SigningHash() ([]byte, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if tx.Version < ChainBoundTxVersion {
+		return hash, nil
+	}
+
+	payload := append([]byte(ActiveGenesis().ChainID+":"), hash...)
+	signingHash := blake2b.Sum256(payload)
+	return signingHash[:], nil
+}
+
 // Sign signs the transaction with the given key pair (simplified signing)
 func (tx *Transaction) Sign(kp *KeyPair) error {
+	return tx.SignWith(NewLocalSigner(kp))
+}
+
+// SignWith signs the transaction using any Signer (local key pair or remote keystore)
+func (tx *Transaction) SignWith(signer Signer) error {
 	// Get the transaction hash
-	hash, err := tx.Hash()
+	hash, err := tx.SigningHash()
 	if err != nil {
 		return fmt.Errorf("failed to compute transaction hash: %w", err)
 	}
 
 	// Sign the hash
-	signature, err := kp.Sign(hash)
+	signature, err := signer.Sign(hash)
 	if err != nil {
 		return fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
 	// Serialize the public key
-	pkBytes, err := PublicKeyToBytes(kp.PublicKey)
+	pkBytes, err := PublicKeyToBytes(signer.PublicKey())
 	if err != nil {
 		return fmt.Errorf("failed to serialize public key: %w", err)
 	}
@@ -179,6 +261,11 @@ func (tx *Transaction) Sign(kp *KeyPair) error {
 	return nil
 }
 
+// ExpiredAt reports whether the transaction's TTL forbids including it in a block at height
+func (tx *Transaction) ExpiredAt(height uint64) bool {
+	return tx.MempoolTTL != 0 && height > uint64(tx.MempoolTTL)
+}
+
 // ValidateTransaction validates a complete UTXO transaction
 func ValidateTransaction(tx *Transaction) error {
 	if tx == nil {
@@ -186,10 +273,34 @@ func ValidateTransaction(tx *Transaction) error {
 	}
 
 	// Validate transaction type
-	if tx.TxType < TxTypeCoinbase || tx.TxType > TxTypeSwap {
+	if tx.TxType < TxTypeCoinbase || tx.TxType > MaxTxType {
 		return fmt.Errorf("invalid transaction type: %d", int(tx.TxType))
 	}
 
+	// A newer version may hash differently, so its signature can't be checked here
+	if tx.Version > MaxTxVersion {
+		return fmt.Errorf("unsupported transaction version: %d", tx.Version)
+	}
+
+	if err := validateTokenFee(tx); err != nil {
+		return err
+	}
+	if err := validateBurnOutputs(tx); err != nil {
+		return err
+	}
+	if err := validateVestingOutputs(tx); err != nil {
+		return err
+	}
+	if err := validateOutputConditions(tx); err != nil {
+		return err
+	}
+	if err := validateOutputAddressTypes(tx); err != nil {
+		return err
+	}
+	if err := validateInputSignatures(tx); err != nil {
+		return err
+	}
+
 	// Type-specific validation
 	switch tx.TxType {
 	case TxTypeCoinbase:
@@ -216,6 +327,18 @@ func ValidateTransaction(tx *Transaction) error {
 		return validateRemoveLiquidityTransaction(tx)
 	case TxTypeSwap:
 		return validateSwapTransaction(tx)
+	case TxTypeBurn:
+		return validateBurnTransaction(tx)
+	case TxTypeMatchOffers:
+		return validateMatchOffersTransaction(tx)
+	case TxTypeHTLCLock:
+		return validateHTLCLockTransaction(tx)
+	case TxTypeHTLCClaim, TxTypeHTLCRefund:
+		return validateHTLCSpendTransaction(tx)
+	case TxTypeDelegateMint:
+		return validateDelegateMintTransaction(tx)
+	case TxTypeCollateralIssue, TxTypeCollateralMelt, TxTypeLiquidate:
+		return validateCollateralTransaction(tx)
 	default:
 		return fmt.Errorf("unsupported transaction type: %s", tx.TxType.String())
 	}
@@ -273,6 +396,12 @@ func validateSendTransaction(tx *Transaction) error {
 		return fmt.Errorf("send transaction must have outputs")
 	}
 
+	// Input signatures were verified by validateInputSignatures; witnesses are checked
+	// against the conditions they satisfy
+	if tx.HasInputSignatures() || tx.fullyWitnessed() {
+		return nil
+	}
+
 	// Validate signature (simplified validation)
 	if len(tx.PublicKey) == 0 {
 		return fmt.Errorf("send transaction must include public key")
@@ -282,18 +411,8 @@ func validateSendTransaction(tx *Transaction) error {
 	}
 
 	// Verify signature
-	publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-	if err != nil {
-		return fmt.Errorf("invalid public key: %w", err)
-	}
-
-	hash, err := tx.Hash()
-	if err != nil {
-		return fmt.Errorf("failed to compute transaction hash: %w", err)
-	}
-
-	if !VerifySignature(hash, tx.Signature, publicKey) {
-		return fmt.Errorf("invalid transaction signature")
+	if err := tx.verifySignature(); err != nil {
+		return err
 	}
 
 	return nil
@@ -311,7 +430,15 @@ func validateMintTokenTransaction(tx *Transaction) error {
 		return fmt.Errorf("mint transaction must have token metadata in Data field")
 	}
 
-	// At least one output should be a custom token
+	// At least one output should be a custom token, unless the mint registers a
+	// collateralized token, which is issued later against positions
+	var mintData TokenMintData
+	if json.Unmarshal(tx.Data, &mintData) == nil && mintData.Collateral != nil {
+		if len(tx.Signature) == 0 {
+			return fmt.Errorf("mint token transaction must be signed")
+		}
+		return nil
+	}
 	hasCustomToken := false
 	for _, output := range tx.Outputs {
 		if output.IsTokenOutput() {
@@ -395,6 +522,12 @@ func (tx *Transaction) ID() (string, error) {
 		return "", fmt.Errorf("failed to compute hash for ID: %w", err)
 	}
 
+	// Version 2 IDs hash the raw bytes, leaving nothing to the encoder
+	if tx.Version >= CanonicalTxVersion {
+		idHash := blake2b.Sum256(append(hash, tx.Signature...))
+		return fmt.Sprintf("%x", idHash), nil
+	}
+
 	idData.Hash = hash
 	idData.Signature = tx.Signature
 
@@ -520,18 +653,8 @@ func validateOfferTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -562,18 +685,8 @@ func validateAcceptOfferTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -604,18 +717,8 @@ func validateCancelOfferTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -634,6 +737,16 @@ func validateCreatePoolTransaction(tx *Transaction) error {
 		return fmt.Errorf("create pool transaction must have pool metadata in Data field")
 	}
 
+	// Only the creation fee may be burned, in SHADOW
+	for i, output := range tx.Outputs {
+		if !output.IsBurn() {
+			continue
+		}
+		if output.TokenID != GetGenesisToken().TokenID || output.Address != BurnAddress || output.Amount == 0 {
+			return fmt.Errorf("burn output %d must burn a positive amount of SHADOW", i)
+		}
+	}
+
 	// Must be signed
 	if len(tx.Signature) == 0 {
 		return fmt.Errorf("create pool transaction must be signed")
@@ -641,18 +754,8 @@ func validateCreatePoolTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -678,18 +781,8 @@ func validateAddLiquidityTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -715,18 +808,8 @@ func validateRemoveLiquidityTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -752,18 +835,8 @@ func validateSwapTransaction(tx *Transaction) error {
 
 	// Validate signature
 	if len(tx.PublicKey) > 0 {
-		publicKey, err := PublicKeyFromBytes(tx.PublicKey)
-		if err != nil {
-			return fmt.Errorf("invalid public key: %w", err)
-		}
-
-		hash, err := tx.Hash()
-		if err != nil {
-			return fmt.Errorf("failed to compute transaction hash: %w", err)
-		}
-
-		if !VerifySignature(hash, tx.Signature, publicKey) {
-			return fmt.Errorf("invalid transaction signature")
+		if err := tx.verifySignature(); err != nil {
+			return err
 		}
 	}
 
@@ -162,8 +162,9 @@ func CreateMeltTransaction(inputUTXOs []*UTXO, meltReason string) *Transaction {
 	return builder.Build()
 }
 
-// CreatePartialMeltTransaction melts some tokens but returns change
-func CreatePartialMeltTransaction(inputUTXOs []*UTXO, meltAmount uint64, changeAddress Address, meltReason string) (*Transaction, error) {
+// CreatePartialMeltTransaction melts some tokens but returns change, stamped with the
+// token's mint version in registry
+func CreatePartialMeltTransaction(registry *TokenRegistry, inputUTXOs []*UTXO, meltAmount uint64, changeAddress Address, meltReason string) (*Transaction, error) {
 	builder := NewTxBuilder(TxTypeMelt)
 
 	totalToMelt := uint64(0)
@@ -188,14 +189,7 @@ func CreatePartialMeltTransaction(inputUTXOs []*UTXO, meltAmount uint64, changeA
 	// Return change if any
 	change := totalToMelt - meltAmount
 	if change > 0 {
-		changeOutput := &TxOutput{
-			Amount:       change,
-			Address:      changeAddress,
-			TokenID:      tokenID,
-			TokenType:    "custom",
-			ScriptPubKey: CreateP2PKHScript(changeAddress),
-		}
-		builder.AddCustomOutput(changeOutput)
+		builder.AddCustomOutput(registry.NewTokenOutput(changeAddress, change, tokenID, "custom", nil))
 	}
 
 	// Add melt data
@@ -340,6 +334,13 @@ func GetTransactionSummary(tx *Transaction) string {
 		return fmt.Sprintf("Melt: Destroyed tokens (%d inputs → %d outputs)",
 			len(tx.Inputs), len(tx.Outputs))
 
+	case TxTypeBurn:
+		return fmt.Sprintf("Burn: Provably destroyed tokens (%d inputs → %d outputs)",
+			len(tx.Inputs), len(tx.Outputs))
+
+	case TxTypeMatchOffers:
+		return fmt.Sprintf("Match: Settled two offers (%d outputs)", len(tx.Outputs))
+
 	default:
 		return fmt.Sprintf("Unknown transaction type: %s", tx.TxType.String())
 	}
@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Each pool accumulates its price over blocks: before its reserves first change in a
+// block, the price they held is added once for every block since the last change. An
+// observation of the cumulative prices and the reserves after the block is stored, so
+// the cumulative can be found at any height and a time-weighted average price over a
+// window is the difference at its ends divided by its length. Moving a TWAP means
+// holding a manipulated price for many blocks, not just within one.
+
+const (
+	PriceScale        = 1_000_000 // Prices are reserve ratios times this
+	PoolOraclePrefix  = "twap:"   // twap:{pool_id}:{height} -> priceObservation
+	DefaultTWAPWindow = 30        // Blocks averaged when no window is given
+	MaxTWAPWindow     = 100_000   // Longest window a query may ask for
+)
+
+// priceObservation is a pool's cumulative prices and reserves after a block that changed it
+type priceObservation struct {
+	Height      uint64 `json:"height"`
+	CumulativeA uint64 `json:"cumulative_a"`
+	CumulativeB uint64 `json:"cumulative_b"`
+	ReserveA    uint64 `json:"reserve_a"`
+	ReserveB    uint64 `json:"reserve_b"`
+}
+
+// spotPrices returns the price of A in B and of B in A, times PriceScale
+func spotPrices(reserveA, reserveB uint64) (uint64, uint64) {
+	if reserveA == 0 || reserveB == 0 {
+		return 0, 0
+	}
+	return mulDiv(reserveB, PriceScale, reserveA), mulDiv(reserveA, PriceScale, reserveB)
+}
+
+// cumulativeAt extends the observation's cumulative prices to height. Sums wrap around;
+// only differences between two cumulatives are meaningful.
+func (o *priceObservation) cumulativeAt(height uint64) (uint64, uint64) {
+	priceA, priceB := spotPrices(o.ReserveA, o.ReserveB)
+	elapsed := height - o.Height
+	return o.CumulativeA + priceA*elapsed, o.CumulativeB + priceB*elapsed
+}
+
+// observePrice accumulates the pool's current price up to height. Call before changing
+// its reserves; later changes in the same block add nothing.
+func (pool *LiquidityPool) observePrice(height uint64) {
+	last := pool.PriceObservedAt
+	if last == 0 {
+		last = pool.CreatedAt
+	}
+	if height <= last {
+		return
+	}
+	priceA, priceB := spotPrices(pool.ReserveA, pool.ReserveB)
+	pool.PriceCumulativeA += priceA * (height - last)
+	pool.PriceCumulativeB += priceB * (height - last)
+	pool.PriceObservedAt = height
+}
+
+// recordPriceObservation stores the pool's cumulative prices and reserves after height
+func (store *UTXOStore) recordPriceObservation(pool *LiquidityPool, height uint64) error {
+	data, err := json.Marshal(priceObservation{
+		Height:      height,
+		CumulativeA: pool.PriceCumulativeA,
+		CumulativeB: pool.PriceCumulativeB,
+		ReserveA:    pool.ReserveA,
+		ReserveB:    pool.ReserveB,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal price observation: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	key := fmt.Sprintf("%s%s:%020d", PoolOraclePrefix, pool.PoolID, height)
+	if err := store.db.Set([]byte(key), data); err != nil {
+		return fmt.Errorf("failed to store price observation: %w", err)
+	}
+	return nil
+}
+
+// observationsAt returns the latest observation at or before each of heights (ascending)
+// in one pass over the pool's history; nil where there is none
+func (store *UTXOStore) observationsAt(poolID string, heights []uint64) ([]*priceObservation, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	iterator, err := store.db.Iterator([]byte(PoolOraclePrefix+poolID+":"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	found := make([]*priceObservation, len(heights))
+	var current *priceObservation
+	i := 0
+	for ; iterator.Valid() && i < len(heights); iterator.Next() {
+		var observation priceObservation
+		if err := json.Unmarshal(iterator.Value(), &observation); err != nil {
+			return nil, fmt.Errorf("corrupt price observation %s", iterator.Key())
+		}
+		for i < len(heights) && heights[i] < observation.Height {
+			found[i] = current
+			i++
+		}
+		current = &observation
+	}
+	for ; i < len(heights); i++ {
+		found[i] = current
+	}
+	return found, nil
+}
+
+// TWAP is a pool's time-weighted average prices over a window of blocks
+type TWAP struct {
+	PoolID     string  `json:"pool_id"`
+	TokenA     string  `json:"token_a"`
+	TokenB     string  `json:"token_b"`
+	FromHeight uint64  `json:"from_height"`
+	ToHeight   uint64  `json:"to_height"`
+	Window     uint64  `json:"window"`       // Blocks averaged, shorter than asked if the pool is younger
+	PriceAInB  float64 `json:"price_a_in_b"` // Average units of B per unit of A
+	PriceBInA  float64 `json:"price_b_in_a"`
+	SpotAInB   float64 `json:"spot_a_in_b"` // Current reserve ratio, for comparison
+	SpotBInA   float64 `json:"spot_b_in_a"`
+}
+
+// PoolTWAP averages a pool's prices over the window blocks ending at the tip
+func (bc *Blockchain) PoolTWAP(poolID string, window uint64) (*TWAP, error) {
+	if window == 0 || window > MaxTWAPWindow {
+		return nil, fmt.Errorf("window must be between 1 and %d blocks", MaxTWAPWindow)
+	}
+	pool, err := bc.poolRegistry.GetPool(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	tip := bc.GetHeight() - 1
+	from := pool.CreatedAt
+	if tip > window && tip-window > from {
+		from = tip - window
+	}
+	if from >= tip {
+		return nil, fmt.Errorf("pool %s has no price history yet", poolID[:16])
+	}
+
+	observations, err := bc.utxoStore.observationsAt(poolID, []uint64{from, tip})
+	if err != nil {
+		return nil, err
+	}
+	if observations[0] == nil || observations[1] == nil {
+		return nil, fmt.Errorf("pool %s has no price observations (run with --reindex to record them)", poolID[:16])
+	}
+	startA, startB := observations[0].cumulativeAt(from)
+	endA, endB := observations[1].cumulativeAt(tip)
+
+	spotA, spotB := spotPrices(pool.ReserveA, pool.ReserveB)
+	elapsed := tip - from
+	return &TWAP{
+		PoolID:     poolID,
+		TokenA:     pool.TokenA,
+		TokenB:     pool.TokenB,
+		FromHeight: from,
+		ToHeight:   tip,
+		Window:     elapsed,
+		PriceAInB:  float64(endA-startA) / float64(elapsed) / PriceScale,
+		PriceBInA:  float64(endB-startB) / float64(elapsed) / PriceScale,
+		SpotAInB:   float64(spotA) / PriceScale,
+		SpotBInA:   float64(spotB) / PriceScale,
+	}, nil
+}
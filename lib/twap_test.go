@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPoolTWAP(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	addBlocks := func(count int) {
+		for i := 0; i < count; i++ {
+			if err := bc.AddBlock(bc.ProposeBlock(nil, "twap-test-proposer", nil), nil); err != nil {
+				t.Fatalf("Failed to add block: %v", err)
+			}
+		}
+	}
+
+	poolID := strings.Repeat("fa", 32)
+	pools := bc.GetPoolRegistry()
+	store := bc.GetUTXOStore()
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: strings.Repeat("ab", 32), TokenB: GetGenesisToken().TokenID,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+	pool, _ := pools.GetPool(poolID)
+	if err := store.recordPriceObservation(pool, 1); err != nil {
+		t.Fatalf("Failed to record observation: %v", err)
+	}
+
+	// The price of A is 2 for blocks 1-4, then a trade at block 5 moves it to 4
+	addBlocks(5)
+	pool.observePrice(5)
+	pool.ReserveB = 4000
+	if err := pools.UpdatePool(pool); err != nil {
+		t.Fatalf("Failed to update pool: %v", err)
+	}
+	if err := store.recordPriceObservation(pool, 5); err != nil {
+		t.Fatalf("Failed to record observation: %v", err)
+	}
+	addBlocks(4)
+
+	twap, err := bc.PoolTWAP(poolID, 8)
+	if err != nil {
+		t.Fatalf("TWAP failed: %v", err)
+	}
+	if twap.FromHeight != 1 || twap.ToHeight != 9 || twap.PriceAInB != 3 || twap.SpotAInB != 4 {
+		t.Errorf("Expected an average of 3 over blocks 1-9 against a spot of 4, got %+v", twap)
+	}
+	if math.Abs(twap.PriceBInA-0.375) > 1e-6 {
+		t.Errorf("Expected B priced at 0.375 A, got %f", twap.PriceBInA)
+	}
+
+	// A window inside the second price sees only that price
+	twap, _ = bc.PoolTWAP(poolID, 3)
+	if twap.FromHeight != 6 || twap.PriceAInB != 4 {
+		t.Errorf("Expected 4 over the last 3 blocks, got %+v", twap)
+	}
+
+	if _, err := bc.PoolTWAP(poolID, 0); err == nil {
+		t.Error("A zero window should be rejected")
+	}
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// Remote transaction building: external wallets hold their own keys but not a view of
+// the UTXO set. The node selects coins from the wallet's address and returns an
+// unsigned send together with the hash to sign, which the wallet signs locally and
+// submits through /api/tx/submit, or through /api/tx/submit_raw in its raw form.
+
+const (
+	MinBuildFee      = 11500 // Smallest fee an estimated build pays, matching node wallet sends
+	BuildFeePerInput = 1150  // Estimated fee per input
+)
+
+// BuiltTransaction is an unsigned transaction ready for the client to sign
+type BuiltTransaction struct {
+	Transaction *Transaction `json:"transaction"`
+	SigningHash string       `json:"signing_hash"` // Hex hash the client signs
+	Fee         uint64       `json:"fee"`          // SHADOW paid as fee
+	Inputs      []*UTXO      `json:"inputs"`       // Outputs being spent, for the client to check
+	Raw         string       `json:"raw"`          // Hex raw form of the unsigned transaction, for offline signers
+}
+
+// EstimateBuildFee returns the fee for a send with inputCount inputs, at least minFee
+func EstimateBuildFee(inputCount int, minFee uint64) uint64 {
+	fee := uint64(inputCount) * BuildFeePerInput
+	if fee < MinBuildFee {
+		fee = MinBuildFee
+	}
+	if fee < minFee {
+		fee = minFee
+	}
+	return fee
+}
+
+// BuildSendTransaction selects coins from utxos to pay outputs and a SHADOW fee, sending
+// change to changeAddress. A fee of 0 is estimated from the number of inputs (at least
+// minFee). Spent and vesting UTXOs are never selected. Token change carries the token's
+// mint version in registry.
+func BuildSendTransaction(registry *TokenRegistry, utxos []*UTXO, outputs []*TxOutput, changeAddress Address, fee, minFee uint64) (*BuiltTransaction, error) {
+	genesisTokenID := GetGenesisToken().TokenID
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("at least one output is required")
+	}
+
+	need := make(map[string]uint64)
+	var tokenOrder []string
+	for i, output := range outputs {
+		if output.Amount == 0 {
+			return nil, fmt.Errorf("output %d has zero amount", i)
+		}
+		if _, seen := need[output.TokenID]; !seen && output.TokenID != genesisTokenID {
+			tokenOrder = append(tokenOrder, output.TokenID)
+		}
+		need[output.TokenID] += output.Amount
+	}
+
+	feeFor := func(inputCount int) uint64 {
+		if fee > 0 {
+			return fee
+		}
+		return EstimateBuildFee(inputCount, minFee)
+	}
+
+	var selected []*UTXO
+	have := make(map[string]uint64)
+	spendable := func(utxo *UTXO, tokenID string) bool {
+		return !utxo.IsSpent && utxo.Output.Vesting == nil && utxo.Output.TokenID == tokenID
+	}
+
+	// Custom tokens first, then SHADOW for SHADOW outputs plus a fee that grows with inputs
+	for _, tokenID := range tokenOrder {
+		for _, utxo := range utxos {
+			if have[tokenID] >= need[tokenID] {
+				break
+			}
+			if spendable(utxo, tokenID) {
+				selected = append(selected, utxo)
+				have[tokenID] += utxo.Output.Amount
+			}
+		}
+		if have[tokenID] < need[tokenID] {
+			return nil, fmt.Errorf("insufficient balance of %s: have %d, need %d", tokenID, have[tokenID], need[tokenID])
+		}
+	}
+	for _, utxo := range utxos {
+		if have[genesisTokenID] >= need[genesisTokenID]+feeFor(len(selected)) {
+			break
+		}
+		if spendable(utxo, genesisTokenID) {
+			selected = append(selected, utxo)
+			have[genesisTokenID] += utxo.Output.Amount
+		}
+	}
+	paidFee := feeFor(len(selected))
+	if have[genesisTokenID] < need[genesisTokenID]+paidFee {
+		return nil, fmt.Errorf("insufficient SHADOW: have %d, need %d (including %d fee)",
+			have[genesisTokenID], need[genesisTokenID]+paidFee, paidFee)
+	}
+
+	builder := NewTxBuilder(TxTypeSend).SetRegistry(registry)
+	for _, utxo := range selected {
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+	}
+	for _, output := range outputs {
+		builder.AddCustomOutput(output)
+	}
+	for _, tokenID := range tokenOrder {
+		if change := have[tokenID] - need[tokenID]; change > 0 {
+			builder.AddOutput(changeAddress, change, tokenID)
+		}
+	}
+	change := have[genesisTokenID] - need[genesisTokenID] - paidFee
+	if change > 0 && change < ActiveGenesis().BlockSizeLimits().DustThreshold {
+		paidFee += change // Too little to be an output; the proposer gets it instead
+		change = 0
+	}
+	if change > 0 {
+		builder.AddOutput(changeAddress, change, genesisTokenID)
+	}
+
+	return &BuiltTransaction{
+		Transaction: builder.Build(),
+		Fee:         paidFee,
+		Inputs:      selected,
+	}, nil
+}
+
+// Finish fills in the signing hash once the transaction is final. The transaction ID
+// covers the signature, so it is only known after signing.
+func (bt *BuiltTransaction) Finish() error {
+	hash, err := bt.Transaction.SigningHash()
+	if err != nil {
+		return fmt.Errorf("failed to compute signing hash: %w", err)
+	}
+	bt.SigningHash = fmt.Sprintf("%x", hash)
+
+	raw, err := EncodeRawTransaction(bt.Transaction)
+	if err != nil {
+		return err
+	}
+	bt.Raw = fmt.Sprintf("%x", raw)
+	return nil
+}
+
+// PendingSpends returns the outpoints ("txid:index") spent by transactions in the mempool
+func (mp *Mempool) PendingSpends() map[string]bool {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+
+	spends := make(map[string]bool)
+	for _, entry := range mp.entries {
+		for _, input := range entry.Tx.Inputs {
+			spends[fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)] = true
+		}
+	}
+	return spends
+}
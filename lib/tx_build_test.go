@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuildSendTransaction(t *testing.T) {
+	owner, _ := GenerateKeyPair()
+	recipient, _ := GenerateKeyPair()
+	tokenID := "f6e5d4c3b2a1a9b8c7d6e5f4a3b2c1d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6"
+	utxos := []*UTXO{
+		{TxID: "a", OutputIndex: 0, Output: CreateShadowOutput(owner.Address(), 5_000)},
+		{TxID: "b", OutputIndex: 0, Output: CreateShadowOutput(owner.Address(), 50_000), IsSpent: true},
+		{TxID: "c", OutputIndex: 0, Output: CreateVestingOutput(nil, owner.Address(), 90_000, "SHADOW", VestingSchedule{StartHeight: 0, EndHeight: 10})},
+		{TxID: "d", OutputIndex: 0, Output: CreateShadowOutput(owner.Address(), 20_000)},
+		{TxID: "e", OutputIndex: 1, Output: CreateTokenOutput(owner.Address(), 700, tokenID, "custom", nil)},
+	}
+
+	outputs := []*TxOutput{CreateTokenOutput(recipient.Address(), 500, tokenID, "custom", nil)}
+	built, err := BuildSendTransaction(nil, utxos, outputs, owner.Address(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	if err := built.Finish(); err != nil {
+		t.Fatalf("Failed to finish: %v", err)
+	}
+	if built.Fee != MinBuildFee || len(built.Inputs) != 3 {
+		t.Fatalf("Expected the token UTXO plus two SHADOW UTXOs at the minimum fee, got %d inputs, fee %d", len(built.Inputs), built.Fee)
+	}
+	for _, utxo := range built.Inputs {
+		if utxo.IsSpent || utxo.Output.Vesting != nil {
+			t.Errorf("Selected unusable UTXO %s", utxo.TxID)
+		}
+	}
+
+	// Token change and SHADOW change go back to the owner
+	tx := built.Transaction
+	var tokenChange, shadowChange uint64
+	for _, output := range tx.Outputs[1:] {
+		if output.Address != owner.Address() {
+			t.Errorf("Change paid to the wrong address")
+		}
+		if output.TokenID == tokenID {
+			tokenChange += output.Amount
+		} else {
+			shadowChange += output.Amount
+		}
+	}
+	if tokenChange != 200 || shadowChange != 25_000-MinBuildFee {
+		t.Errorf("Expected 200 token and %d SHADOW change, got %d and %d", 25_000-MinBuildFee, tokenChange, shadowChange)
+	}
+
+	// Signing the returned hash produces a valid transaction
+	if err := tx.Sign(owner); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if hash, _ := tx.SigningHash(); fmt.Sprintf("%x", hash) != built.SigningHash {
+		t.Error("Signing should not change the signing hash")
+	}
+	if err := ValidateTransaction(tx); err != nil {
+		t.Errorf("Signed transaction failed validation: %v", err)
+	}
+
+	big := []*TxOutput{CreateShadowOutput(recipient.Address(), 20_000)}
+	if _, err := BuildSendTransaction(nil, utxos, big, owner.Address(), 0, 0); err == nil {
+		t.Error("Spending more than the unlocked balance should fail")
+	}
+}
@@ -0,0 +1,199 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Each transaction applied in a block is indexed with the height and hash of that block.
+// A transaction's status is worked out from the index against the current chain: it is
+// confirmed while its block is still at that height, finalized once buried FinalityDepth
+// blocks deep, and orphaned if a reorg replaced its block and it wasn't included again
+// (re-inclusion overwrites the index). The orphan watcher follows the recent blocks and
+// publishes a tx_orphaned event for each transaction a reorg takes out of the chain.
+
+const TxConfirmPrefix = "txconf:" // txconf:{txid} -> TxConfirmation
+
+const (
+	FinalityDepth       = MinUTXOPruneDepth // Confirmations after which a transaction is final
+	OrphanCheckInterval = 2 * time.Second   // How often the orphan watcher checks the chain
+)
+
+// TxConfirmation records the block a transaction was applied in
+type TxConfirmation struct {
+	Height    uint64 `json:"height"`
+	BlockHash string `json:"block_hash"`
+}
+
+// TxChainStatus is where a confirmed (or once confirmed) transaction stands
+type TxChainStatus struct {
+	Status        string `json:"status"` // TxStatusConfirmed, TxStatusFinalized or TxStatusOrphaned
+	Confirmations uint64 `json:"confirmations"`
+	Height        uint64 `json:"height"`     // Block the transaction was applied in
+	BlockHash     string `json:"block_hash"` // For orphans, the block that left the chain
+}
+
+// TxOrphaned is published when a reorg takes a confirmed transaction out of the chain
+type TxOrphaned struct {
+	TxID      string `json:"tx_id"`
+	Height    uint64 `json:"height"`
+	BlockHash string `json:"block_hash"`
+	InMempool bool   `json:"in_mempool"` // Back in the mempool, so it may confirm again
+}
+
+// recordTxConfirmation indexes the block a transaction was applied in
+func (store *UTXOStore) recordTxConfirmation(txID string, height uint64, blockHash string) error {
+	data, err := json.Marshal(TxConfirmation{Height: height, BlockHash: blockHash})
+	if err != nil {
+		return fmt.Errorf("failed to marshal tx confirmation: %w", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(TxConfirmPrefix+txID), data); err != nil {
+		return fmt.Errorf("failed to store tx confirmation: %w", err)
+	}
+	return nil
+}
+
+// GetTxConfirmation returns the block a transaction was last applied in, or nil if none
+func (store *UTXOStore) GetTxConfirmation(txID string) (*TxConfirmation, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(TxConfirmPrefix + txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tx confirmation: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var conf TxConfirmation
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("corrupt tx confirmation %s", txID)
+	}
+	return &conf, nil
+}
+
+// TxChainStatus returns a transaction's status against the current chain, or nil if it
+// was never applied in a block
+func (bc *Blockchain) TxChainStatus(txID string) (*TxChainStatus, error) {
+	conf, err := bc.utxoStore.GetTxConfirmation(txID)
+	if err != nil || conf == nil {
+		return nil, err
+	}
+
+	status := &TxChainStatus{Status: TxStatusOrphaned, Height: conf.Height, BlockHash: conf.BlockHash}
+	if block := bc.GetBlock(conf.Height); block != nil && block.Hash == conf.BlockHash {
+		status.Confirmations = bc.GetHeight() - conf.Height
+		status.Status = TxStatusConfirmed
+		if status.Confirmations >= FinalityDepth {
+			status.Status = TxStatusFinalized
+		}
+	}
+	return status, nil
+}
+
+// blockTxIDs returns the IDs of a block's transactions, coinbase first
+func blockTxIDs(block *Block) []string {
+	txIDs := make([]string, 0, len(block.Transactions)+1)
+	if block.Coinbase != nil {
+		if coinbaseID, err := block.Coinbase.ID(); err == nil {
+			txIDs = append(txIDs, coinbaseID)
+		}
+	}
+	return append(txIDs, block.Transactions...)
+}
+
+// watchedBlock is a recent block as the orphan watcher last saw it
+type watchedBlock struct {
+	index uint64
+	hash  string
+	txIDs []string
+}
+
+// OrphanWatcher publishes tx_orphaned events for transactions whose block a reorg replaced
+type OrphanWatcher struct {
+	chain   *Blockchain
+	mempool *Mempool
+	events  *EventHub
+	recent  []watchedBlock // The last FinalityDepth blocks, oldest first
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewOrphanWatcher starts following the chain from its current tip
+func NewOrphanWatcher(chain *Blockchain, mempool *Mempool, events *EventHub) *OrphanWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &OrphanWatcher{chain: chain, mempool: mempool, events: events, ctx: ctx, cancel: cancel}
+	w.check()
+	go w.loop()
+	return w
+}
+
+// loop checks the chain until the watcher is closed
+func (w *OrphanWatcher) loop() {
+	ticker := time.NewTicker(OrphanCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check finds watched blocks no longer in the chain, reports their transactions that
+// weren't included again, and catches up with new blocks
+func (w *OrphanWatcher) check() {
+	var kept []watchedBlock
+	var orphaned []watchedBlock
+	for _, wb := range w.recent {
+		if block := w.chain.GetBlock(wb.index); block != nil && block.Hash == wb.hash && len(orphaned) == 0 {
+			kept = append(kept, wb)
+		} else {
+			orphaned = append(orphaned, wb) // Everything above a replaced block was replaced too
+		}
+	}
+
+	tip := w.chain.GetHeight()
+	next := uint64(0)
+	if tip > FinalityDepth {
+		next = tip - FinalityDepth
+	}
+	if len(kept) > 0 {
+		next = max(next, kept[len(kept)-1].index+1)
+	}
+	for ; next < tip; next++ {
+		if block := w.chain.GetBlock(next); block != nil {
+			kept = append(kept, watchedBlock{index: block.Index, hash: block.Hash, txIDs: blockTxIDs(block)})
+		}
+	}
+	if len(kept) > FinalityDepth {
+		kept = kept[len(kept)-FinalityDepth:]
+	}
+	w.recent = kept
+
+	for _, wb := range orphaned {
+		for _, txID := range wb.txIDs {
+			if status, err := w.chain.TxChainStatus(txID); err != nil || status == nil || status.Status != TxStatusOrphaned {
+				continue // Included again by the new chain
+			}
+			orphan := TxOrphaned{TxID: txID, Height: wb.index, BlockHash: wb.hash}
+			if w.mempool != nil {
+				orphan.InMempool = w.mempool.HasTransaction(txID)
+			}
+			fmt.Printf("[Chain] ⚠️  Transaction %s orphaned: block %d (%s) left the chain\n", txID[:16], wb.index, wb.hash[:16])
+			w.events.Publish(EventTxOrphaned, orphan)
+		}
+	}
+}
+
+// Close stops the watcher
+func (w *OrphanWatcher) Close() {
+	w.cancel()
+}
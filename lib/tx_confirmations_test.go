@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTxConfirmationsAcrossReorg(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	alice, _ := GenerateKeyPair()
+	addBlock := func(txIDs []string) *Block {
+		prev := bc.GetLatestBlock()
+		block := &Block{Index: prev.Index + 1, Timestamp: prev.Timestamp + 1, Transactions: txIDs, PreviousHash: prev.Hash, Proposer: "reorg-test-proposer"}
+		block.Hash = bc.calculateBlockHash(block)
+		if err := bc.AddBlock(block, nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+		return block
+	}
+
+	send := NewTxBuilder(TxTypeSend).AddInput("reorg-test-funding", 0).
+		AddCustomOutput(CreateShadowOutput(alice.Address(), 25)).Build()
+	send.Sign(alice)
+	funding := &UTXO{TxID: "reorg-test-funding", OutputIndex: 0, Output: CreateShadowOutput(alice.Address(), 25)}
+	if err := bc.GetUTXOStore().AddUTXO(funding); err != nil {
+		t.Fatalf("Failed to add funding: %v", err)
+	}
+	if err := bc.GetUTXOStore().StoreTransaction(send, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	sendID, _ := send.ID()
+
+	if status, err := bc.TxChainStatus(sendID); err != nil || status != nil {
+		t.Fatalf("Expected no status before the transaction confirms, got %+v (%v)", status, err)
+	}
+	included := addBlock([]string{sendID})
+	addBlock(nil)
+
+	status, err := bc.TxChainStatus(sendID)
+	if err != nil || status == nil {
+		t.Fatalf("Expected a status once confirmed, got %v", err)
+	}
+	if status.Status != TxStatusConfirmed || status.Confirmations != 2 || status.Height != 1 || status.BlockHash != included.Hash {
+		t.Fatalf("Unexpected confirmed status: %+v", status)
+	}
+
+	hub := NewEventHub()
+	events, cancel := hub.Subscribe([]string{EventTxOrphaned})
+	defer cancel()
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay()}
+	w := &OrphanWatcher{chain: bc, mempool: mp, events: hub}
+	w.check()
+	if len(w.recent) != 3 {
+		t.Fatalf("Expected the watcher to follow 3 blocks, got %d", len(w.recent))
+	}
+
+	// A reorg replaces blocks 1 and 2 with a branch that leaves the transaction out
+	bc.chainLock.Lock()
+	for _, block := range bc.blocks[1:] {
+		delete(bc.hashIndex, block.Hash)
+	}
+	bc.blocks = bc.blocks[:1]
+	for i := 1; i <= 2; i++ {
+		prev := bc.blocks[len(bc.blocks)-1]
+		block := &Block{Index: prev.Index + 1, Timestamp: prev.Timestamp + 2, PreviousHash: prev.Hash, Proposer: "reorg-test-rival"}
+		block.Hash = bc.calculateBlockHash(block)
+		bc.appendBlockLocked(block)
+	}
+	bc.chainLock.Unlock()
+	mp.entries[sendID] = &MempoolEntry{Tx: send}
+	unspent := &UTXO{TxID: "reorg-test-funding", OutputIndex: 0, Output: CreateShadowOutput(alice.Address(), 25)}
+	if err := bc.GetUTXOStore().AddUTXO(unspent); err != nil { // The rival branch never spent it
+		t.Fatalf("Failed to restore funding: %v", err)
+	}
+
+	if status, _ := bc.TxChainStatus(sendID); status.Status != TxStatusOrphaned || status.Confirmations != 0 || status.BlockHash != included.Hash {
+		t.Fatalf("Expected the transaction to be orphaned, got %+v", status)
+	}
+	w.check()
+	w.check()
+	if len(events) != 1 {
+		t.Fatalf("Expected one tx_orphaned event, got %d", len(events))
+	}
+	if orphan := (<-events).Data.(TxOrphaned); orphan.TxID != sendID || orphan.Height != 1 || orphan.BlockHash != included.Hash || !orphan.InMempool {
+		t.Fatalf("Unexpected orphan event: %+v", orphan)
+	}
+
+	// Included again by the new chain, it confirms from its new block and is final once buried
+	delete(mp.entries, sendID)
+	reincluded := addBlock([]string{sendID})
+	for i := 0; i < FinalityDepth-2; i++ {
+		addBlock(nil)
+	}
+	if status, _ := bc.TxChainStatus(sendID); status.Status != TxStatusConfirmed || status.Height != reincluded.Index {
+		t.Fatalf("Expected the transaction confirmed again, got %+v", status)
+	}
+	addBlock(nil)
+	if status, _ := bc.TxChainStatus(sendID); status.Status != TxStatusFinalized || status.Confirmations != FinalityDepth {
+		t.Fatalf("Expected the transaction finalized, got %+v", status)
+	}
+	w.check()
+	if len(events) != 0 || len(w.recent) != FinalityDepth {
+		t.Fatalf("Expected no more events and %d watched blocks, got %d and %d", FinalityDepth, len(events), len(w.recent))
+	}
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Token and pool transactions change registry state in ways their outputs don't show,
+// such as the amount a swap actually paid out. While a transaction is applied it emits
+// events describing what it did; they are stored under the transaction ID as its
+// receipt, so clients can learn execution results after confirmation.
+
+const TxEventPrefix = "txevents:" // txevents:{txid} -> []TxEvent
+
+// Event types
+const (
+	TxEventTokenMinted        = "token_minted"
+	TxEventTokenMelted        = "token_melted"
+	TxEventTokenBurned        = "token_burned"
+	TxEventMintDelegated      = "mint_delegated"
+	TxEventMintRevoked        = "mint_revoked"
+	TxEventCollateralIssued   = "collateral_issued"
+	TxEventCollateralMelted   = "collateral_melted"
+	TxEventPositionLiquidated = "position_liquidated"
+	TxEventOfferOpened        = "offer_opened"
+	TxEventOfferAccepted      = "offer_accepted"
+	TxEventOfferCancelled     = "offer_cancelled"
+	TxEventOffersMatched      = "offers_matched"
+	TxEventPoolCreated        = "pool_created"
+	TxEventLiquidityAdded     = "liquidity_added"
+	TxEventLiquidityRemoved   = "liquidity_removed"
+	TxEventSwapExecuted       = "swap_executed"
+	TxEventSwapFailed         = "swap_failed"
+	TxEventHTLCLocked         = "htlc_locked"
+	TxEventHTLCClaimed        = "htlc_claimed"
+	TxEventHTLCRefunded       = "htlc_refunded"
+)
+
+// TxEvent is one effect of applying a transaction. Fields that don't apply to the
+// event type are omitted.
+type TxEvent struct {
+	Type      string `json:"type"`
+	Height    uint64 `json:"height"`
+	Address   string `json:"address,omitempty"` // Account the event credits
+	TokenID   string `json:"token_id,omitempty"`
+	PoolID    string `json:"pool_id,omitempty"`
+	OfferTxID string `json:"offer_tx_id,omitempty"`
+	Amount    uint64 `json:"amount,omitempty"` // Minted, melted, burned or LP tokens
+
+	// Pool movements
+	TokenIn   string `json:"token_in,omitempty"`
+	AmountIn  uint64 `json:"amount_in,omitempty"`
+	TokenOut  string `json:"token_out,omitempty"`
+	AmountOut uint64 `json:"amount_out,omitempty"`
+	AmountA   uint64 `json:"amount_a,omitempty"`
+	AmountB   uint64 `json:"amount_b,omitempty"`
+
+	// Collateral positions; AmountIn is SHADOW locked, AmountOut SHADOW released
+	PositionID string `json:"position_id,omitempty"`
+
+	// HTLCs
+	HTLCID   string `json:"htlc_id,omitempty"`
+	Preimage string `json:"preimage,omitempty"` // Secret revealed by a claim, hex
+
+	Reason string `json:"reason,omitempty"` // Why a failed event failed
+}
+
+// recordTxEvents stores the events emitted while applying a transaction
+func (store *UTXOStore) recordTxEvents(txID string, events []TxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tx events: %w", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(TxEventPrefix+txID), data); err != nil {
+		return fmt.Errorf("failed to store tx events: %w", err)
+	}
+	return nil
+}
+
+// GetTxEvents returns the events a confirmed transaction emitted, or nil if none
+func (store *UTXOStore) GetTxEvents(txID string) ([]TxEvent, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(TxEventPrefix + txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tx events: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var events []TxEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("corrupt tx events %s", txID)
+	}
+	return events, nil
+}
+
+// burnEvents describes the burn outputs of a transaction
+func burnEvents(tx *Transaction, height uint64) []TxEvent {
+	var events []TxEvent
+	for _, output := range tx.Outputs {
+		if output.IsBurn() {
+			events = append(events, TxEvent{Type: TxEventTokenBurned, Height: height, TokenID: output.TokenID, Amount: output.Amount})
+		}
+	}
+	return events
+}
+
+// offerEvent describes a change to an offer. In and out are from the offer's side: it
+// gives out its have tokens and takes in its want tokens.
+func offerEvent(eventType, offerTxID string, offer OfferData, height uint64) TxEvent {
+	return TxEvent{
+		Type:      eventType,
+		Height:    height,
+		Address:   offer.OfferAddress.Display(),
+		OfferTxID: offerTxID,
+		TokenIn:   offer.WantTokenID,
+		AmountIn:  offer.WantAmount,
+		TokenOut:  offer.HaveTokenID,
+		AmountOut: offer.HaveAmount,
+	}
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPoolTransactionEvents(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+	pools := bc.GetPoolRegistry()
+	tokens := NewTokenRegistry()
+
+	tokenX := strings.Repeat("ab", 32)
+	poolID := strings.Repeat("fa", 32)
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: tokenX, TokenB: GetGenesisToken().TokenID,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+	if err := tokens.RegisterToken(&TokenInfo{TokenID: poolID, Ticker: "LPX", MaxMint: 10, MaxDecimals: 2, TotalSupply: 1000, LockedShadow: 1000, CreationTime: 1}); err != nil {
+		t.Fatalf("Failed to register LP token: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	apply := func(txType TxType, payload interface{}, height int64) string {
+		data, _ := json.Marshal(payload)
+		tx := NewTxBuilder(txType).AddOutput(kp.Address(), 5, tokenX).SetData(data).Build()
+		if err := store.ProcessTokenTransaction(tx, tokens, pools, height); err != nil {
+			t.Fatalf("Failed to apply %v: %v", txType, err)
+		}
+		txID, _ := tx.ID()
+		events, err := store.GetTxEvents(txID)
+		if err != nil || len(events) != 1 {
+			t.Fatalf("Expected one event for %v, got %+v (%v)", txType, events, err)
+		}
+		return txID
+	}
+
+	// The receipt records what the swap actually paid out
+	txID := apply(TxTypeSwap, SwapData{PoolID: poolID, TokenIn: tokenX, AmountIn: 100, MinAmountOut: 100}, 7)
+	events, _ := store.GetTxEvents(txID)
+	if e := events[0]; e.Type != TxEventSwapExecuted || e.AmountOut != 181 || e.TokenOut != GetGenesisToken().TokenID ||
+		e.PoolID != poolID || e.Height != 7 || e.Address != kp.Address().Display() {
+		t.Errorf("Unexpected swap event %+v", e)
+	}
+
+	txID = apply(TxTypeSwap, SwapData{PoolID: poolID, TokenIn: tokenX, AmountIn: 100, MinAmountOut: 1000}, 8)
+	events, _ = store.GetTxEvents(txID)
+	if e := events[0]; e.Type != TxEventSwapFailed || e.AmountOut != 0 || !strings.Contains(e.Reason, "minimum 1000") {
+		t.Errorf("Unexpected failed swap event %+v", e)
+	}
+
+	txID = apply(TxTypeAddLiquidity, AddLiquidityData{PoolID: poolID, AmountA: 110, AmountB: 181}, 9)
+	events, _ = store.GetTxEvents(txID)
+	if e := events[0]; e.Type != TxEventLiquidityAdded || e.Amount != 99 || e.AmountA != 110 || e.TokenID != poolID {
+		t.Errorf("Unexpected add liquidity event %+v", e)
+	}
+
+	// Plain sends emit nothing
+	send := NewTxBuilder(TxTypeSend).AddOutput(kp.Address(), 5, tokenX).Build()
+	sendID, _ := send.ID()
+	if err := store.ProcessTokenTransaction(send, tokens, pools, 10); err != nil {
+		t.Fatalf("Failed to apply send: %v", err)
+	}
+	if events, _ := store.GetTxEvents(sendID); events != nil {
+		t.Errorf("Expected no events for a send, got %+v", events)
+	}
+}
@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Gossip alone leaves a transaction submitted to a non-leader node waiting for the
+// announcement to reach the leader and the leader to fetch it, which can take most of a
+// block interval, and if the mesh is split between them it never arrives. So a
+// transaction submitted through this node is also pushed, body included, straight to
+// the current leader over the relay protocol. The leader admits pushed transactions
+// through the same checks as gossiped ones; any other node drops pushes. Transactions received from peers aren't
+// forwarded: they are already spreading through the mesh, and every node pushing every
+// transaction would multiply the leader's inbound traffic by its peer count.
+
+// SetLeaderSource makes the mempool push new local transactions to the peer leader
+// returns. leader may return "" while no leader is known.
+func (mp *Mempool) SetLeaderSource(leader func() peer.ID) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.leader = leader
+}
+
+// forwardToLeader pushes transactions to the current leader, unless this node is the
+// leader, no leader is known, or the leader is known to have them already
+func (mp *Mempool) forwardToLeader(txs ...*Transaction) {
+	leader := mp.leaderPeer()
+	if leader == "" {
+		return
+	}
+
+	byID := make(map[string]*Transaction, len(txs))
+	ids := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		if txID, err := tx.ID(); err == nil {
+			byID[txID] = tx
+			ids = append(ids, txID)
+		}
+	}
+	ids = mp.relay.unknownTo(leader, ids)
+	if len(ids) > MaxInvTxIDs {
+		ids = ids[:MaxInvTxIDs]
+	}
+	if len(ids) == 0 {
+		return
+	}
+	push := make([]*Transaction, 0, len(ids))
+	for _, id := range ids {
+		push = append(push, byID[id])
+	}
+
+	if err := mp.sendPush(leader, "push", push); err != nil {
+		// Gossip still carries them; the leader fetches them on announcement
+		fmt.Printf("[Mempool] Failed to forward %d transactions to leader %s: %v\n", len(push), leader.String()[:16], err)
+		return
+	}
+	mp.relay.markKnown(leader, ids...)
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.forwarded += uint64(len(push))
+	mp.relay.mu.Unlock()
+}
+
+// forwardPackageToLeader pushes a package to the current leader as a unit, so it is
+// checked and added whole there too
+func (mp *Mempool) forwardPackageToLeader(txs []*Transaction) {
+	leader := mp.leaderPeer()
+	if leader == "" {
+		return
+	}
+	if err := mp.sendPush(leader, "package", txs); err != nil {
+		fmt.Printf("[Mempool] Failed to forward package to leader %s: %v\n", leader.String()[:16], err)
+		return
+	}
+	for _, tx := range txs {
+		if txID, err := tx.ID(); err == nil {
+			mp.relay.markKnown(leader, txID)
+		}
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.forwarded += uint64(len(txs))
+	mp.relay.mu.Unlock()
+}
+
+// leaderPeer returns the leader to push transactions to, or "" when this node is the
+// leader or no leader is known
+func (mp *Mempool) leaderPeer() peer.ID {
+	mp.txLock.RLock()
+	leaderOf := mp.leader
+	mp.txLock.RUnlock()
+	if leaderOf == nil || mp.host == nil {
+		return ""
+	}
+	if leader := leaderOf(); leader != mp.host.ID() {
+		return leader
+	}
+	return ""
+}
+
+// isLeader reports whether this node is the current leader, which alone accepts pushes
+func (mp *Mempool) isLeader() bool {
+	mp.txLock.RLock()
+	leaderOf := mp.leader
+	mp.txLock.RUnlock()
+	if leaderOf == nil || mp.host == nil {
+		return false
+	}
+	leader := leaderOf()
+	return leader != "" && leader == mp.host.ID()
+}
+
+// sendPush sends transaction bodies directly to one peer, as a "push" of separate
+// transactions or a "package" to be added as a unit
+func (mp *Mempool) sendPush(p peer.ID, kind string, txs []*Transaction) error {
+	ctx, cancel := context.WithTimeout(mp.ctx, TxFetchTimeout)
+	defer cancel()
+
+	s, err := mp.host.NewStream(ctx, p, TxRelayProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+
+	return json.NewEncoder(s).Encode(TxRequest{Type: kind, Transactions: txs})
+}
+
+// handlePush admits transactions a peer forwarded to us as leader. They go through the
+// same dedupe, size checks and verification queue as gossiped transactions.
+func (mp *Mempool) handlePush(from peer.ID, txs []*Transaction) {
+	if len(txs) > MaxInvTxIDs {
+		txs = txs[:MaxInvTxIDs]
+	}
+
+	pushed := 0
+	for _, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		txID, err := tx.ID()
+		if err != nil {
+			continue
+		}
+		mp.relay.markKnown(from, txID)
+		mp.addGossipTransaction(tx, from)
+		pushed++
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.pushed += uint64(pushed)
+	mp.relay.mu.Unlock()
+}
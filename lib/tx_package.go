@@ -0,0 +1,504 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Some flows need several transactions that depend on each other, such as an offer and
+// the acceptance closing it, or a parent paying too little and a child paying for both.
+// Submitted one at a time they only work if each lands before the next. A package is
+// such a set submitted together: the mempool orders it by its dependencies, checks every
+// member against the chain plus the members before it, holds the package as a whole to
+// the relay fee floor (so a child can pay for its parent) and adds all of it or none.
+// Packages travel to the leader and to peers fetching announced transactions as a unit,
+// and proposers include a package's members together or not at all.
+
+const MaxPackageTxs = 25 // Transactions in one package
+
+// PackageResult reports a package the mempool accepted
+type PackageResult struct {
+	PackageID string   `json:"package_id"`        // ID of the package's last transaction
+	TxIDs     []string `json:"tx_ids"`            // Members in dependency order
+	Pending   []string `json:"pending,omitempty"` // Members that were already in the mempool
+	Fee       uint64   `json:"fee"`               // Fees paid by the members added
+	Weight    int      `json:"weight"`            // Their combined weight
+}
+
+// txReferences returns the transactions tx names in its data and needs applied before
+// it: the offer an acceptance or cancellation closes
+func txReferences(tx *Transaction) []string {
+	switch tx.TxType {
+	case TxTypeAcceptOffer, TxTypeCancelOffer:
+		var data AcceptOfferData // Cancel data has the same shape
+		if err := json.Unmarshal(tx.Data, &data); err == nil && data.OfferTxID != "" {
+			return []string{data.OfferTxID}
+		}
+	}
+	return nil
+}
+
+// txDependencies returns the transactions tx spends outputs of or references, once each
+func txDependencies(tx *Transaction) []string {
+	seen := make(map[string]bool)
+	var deps []string
+	for _, input := range tx.Inputs {
+		if !seen[input.PrevTxID] {
+			seen[input.PrevTxID] = true
+			deps = append(deps, input.PrevTxID)
+		}
+	}
+	for _, ref := range txReferences(tx) {
+		if !seen[ref] {
+			seen[ref] = true
+			deps = append(deps, ref)
+		}
+	}
+	return deps
+}
+
+// OrderPackage returns a package's transactions and their IDs, each after the members it
+// depends on, otherwise in submitted order. Rejects an empty or oversized package, one
+// with duplicates, members spending the same output, a dependency cycle, or members that
+// don't depend on each other.
+func OrderPackage(txs []*Transaction) ([]*Transaction, []string, error) {
+	if len(txs) == 0 {
+		return nil, nil, fmt.Errorf("package is empty")
+	}
+	if len(txs) > MaxPackageTxs {
+		return nil, nil, fmt.Errorf("package has %d transactions, limit is %d", len(txs), MaxPackageTxs)
+	}
+
+	ids := make([]string, len(txs))
+	index := make(map[string]int, len(txs))
+	spent := make(map[string]string)
+	for i, tx := range txs {
+		if tx == nil {
+			return nil, nil, fmt.Errorf("package transaction %d is empty", i)
+		}
+		txID, err := tx.ID()
+		if err != nil {
+			return nil, nil, fmt.Errorf("package transaction %d: %w", i, err)
+		}
+		if _, dup := index[txID]; dup {
+			return nil, nil, fmt.Errorf("transaction %s is in the package twice", txID[:16])
+		}
+		for _, input := range tx.Inputs {
+			outpoint := fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)
+			if other, ok := spent[outpoint]; ok {
+				return nil, nil, fmt.Errorf("transactions %s and %s both spend %s", other[:16], txID[:16], outpoint)
+			}
+			spent[outpoint] = txID
+		}
+		ids[i], index[txID] = txID, i
+	}
+
+	// Dependencies within the package, and which members are connected by them
+	waiting := make([]int, len(txs))
+	children := make([][]int, len(txs))
+	group := make([]int, len(txs))
+	for i := range group {
+		group[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		for group[i] != i {
+			group[i] = group[group[i]]
+			i = group[i]
+		}
+		return i
+	}
+	for i, tx := range txs {
+		for _, dep := range txDependencies(tx) {
+			if j, ok := index[dep]; ok && j != i {
+				waiting[i]++
+				children[j] = append(children[j], i)
+				group[root(i)] = root(j)
+			}
+		}
+	}
+	for i := range txs {
+		if root(i) != root(0) {
+			return nil, nil, fmt.Errorf("transaction %s doesn't depend on the rest of the package", ids[i][:16])
+		}
+	}
+
+	// Place the first submitted member whose dependencies are placed, repeatedly
+	ordered := make([]*Transaction, 0, len(txs))
+	orderedIDs := make([]string, 0, len(txs))
+	placed := make([]bool, len(txs))
+	for len(ordered) < len(txs) {
+		next := -1
+		for i := range txs {
+			if !placed[i] && waiting[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, nil, fmt.Errorf("package transactions depend on each other in a cycle")
+		}
+		placed[next] = true
+		ordered = append(ordered, txs[next])
+		orderedIDs = append(orderedIDs, ids[next])
+		for _, child := range children[next] {
+			waiting[child]--
+		}
+	}
+	return ordered, orderedIDs, nil
+}
+
+// SubmitPackage checks a package of dependent transactions submitted through this node
+// and adds all of it to the mempool, or none of it. Signatures are verified on the
+// caller's goroutine. Members already pending are left as they are.
+func (mp *Mempool) SubmitPackage(txs []*Transaction) (*PackageResult, error) {
+	return mp.acceptPackage(txs, true, "")
+}
+
+// acceptPackage checks and adds a package; local packages are tracked for rebroadcast,
+// and every accepted package is announced and forwarded to the leader
+func (mp *Mempool) acceptPackage(txs []*Transaction, local bool, from peer.ID) (*PackageResult, error) {
+	ordered, ids, err := OrderPackage(txs)
+	if err != nil {
+		return nil, err
+	}
+
+	mp.txLock.RLock()
+	utxoStore, minRelayFee, minRelayFeeRate := mp.utxoStore, mp.minRelayFee, mp.minRelayFeeRate
+	mp.txLock.RUnlock()
+
+	// Members resolve each other's outputs, then pending and confirmed ones
+	members := make(map[string]*Transaction, len(ordered))
+	for i, tx := range ordered {
+		members[ids[i]] = tx
+	}
+	lookup := func(txID string, index uint32) *TxOutput {
+		if member, ok := members[txID]; ok {
+			if int(index) < len(member.Outputs) {
+				return member.Outputs[index]
+			}
+			return nil
+		}
+		if utxoStore == nil {
+			return nil
+		}
+		return mp.pendingLookup(utxoStore)(txID, index)
+	}
+
+	result := &PackageResult{PackageID: ids[len(ids)-1], TxIDs: ids}
+	var fresh []*Transaction
+	var freshIDs []string
+	var fees []uint64
+	for i, tx := range ordered {
+		txID := ids[i]
+		if mp.HasTransaction(txID) {
+			result.Pending = append(result.Pending, txID)
+			continue
+		}
+		if err := mp.checkPackageMember(tx, txID, lookup, members); err != nil {
+			return nil, fmt.Errorf("package transaction %s: %w", txID[:16], err)
+		}
+		fee := paidFee(tx, lookup) + mp.tokenFeeValue(tx)
+		fresh = append(fresh, tx)
+		freshIDs = append(freshIDs, txID)
+		fees = append(fees, fee)
+		result.Fee += fee
+		result.Weight += TxWeight(tx)
+	}
+	if len(fresh) == 0 {
+		return nil, fmt.Errorf("package already in mempool")
+	}
+
+	// The relay floor applies to the package as a whole, so a child can pay for its parent
+	floor := minRelayFee * uint64(len(fresh))
+	if rateFloor := relayFeeForWeight(minRelayFeeRate, result.Weight); rateFloor > floor {
+		floor = rateFloor
+	}
+	if floor > 0 && result.Fee < floor {
+		mp.relay.mu.Lock()
+		mp.relay.stats.belowFee++
+		mp.relay.mu.Unlock()
+		return nil, fmt.Errorf("package fee %d below relay floor %d for %d transactions", result.Fee, floor, len(fresh))
+	}
+
+	for i, tx := range fresh {
+		if err := mp.verifyTransaction(tx); err != nil {
+			return nil, fmt.Errorf("package transaction %s: %w", freshIDs[i][:16], err)
+		}
+	}
+
+	if err := mp.insertPackage(fresh, freshIDs, fees, result.PackageID, local); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[Mempool] Added package %s: %d transactions, fee %d\n", result.PackageID[:16], len(fresh), result.Fee)
+
+	for i, tx := range fresh {
+		if from != "" {
+			mp.relay.markKnown(from, freshIDs[i])
+		}
+		mp.relay.markSeen(freshIDs[i])
+		if local && mp.walletTxs != nil {
+			if err := mp.walletTxs.Track(tx); err != nil {
+				fmt.Printf("[Mempool] Warning: failed to track local transaction %s: %v\n", freshIDs[i], err)
+			}
+		}
+	}
+
+	// Peers fetch announced bodies in one request and admit them as a package; the leader
+	// gets the package directly
+	if local {
+		go mp.forwardPackageToLeader(ordered)
+		if err := mp.announce(ids); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// checkPackageMember runs the admission checks on a package member, resolving inputs and
+// referenced offers from the members placed before it as well as the chain
+func (mp *Mempool) checkPackageMember(tx *Transaction, txID string, lookup func(txID string, index uint32) *TxOutput, members map[string]*Transaction) error {
+	if err := CheckTxVersion(tx); err != nil {
+		return err
+	}
+	if err := CheckTxSize(tx); err != nil {
+		return err
+	}
+	if err := CheckTxWeight(tx); err != nil {
+		return err
+	}
+	if err := mp.checkDust(tx); err != nil {
+		return err
+	}
+	if err := mp.checkTTL(tx); err != nil {
+		return err
+	}
+	if err := mp.checkTokenVersions(tx); err != nil {
+		return err
+	}
+	if err := mp.checkTokenMint(tx); err != nil {
+		return err
+	}
+	if err := mp.checkCollateral(tx); err != nil {
+		return err
+	}
+	if err := mp.checkPoolCreation(tx); err != nil {
+		return err
+	}
+
+	mp.txLock.RLock()
+	height, utxoStore, tokenRegistry, poolRegistry := mp.currentHeight+1, mp.utxoStore, mp.tokenRegistry, mp.poolRegistry
+	mp.txLock.RUnlock()
+	if utxoStore == nil {
+		return nil
+	}
+
+	for _, check := range []spendCheck{CheckVestingSpends, CheckInputSignatures, CheckSpendConditions, CheckHTLCSpends} {
+		if err := check(tx, height, lookup); err != nil {
+			return err
+		}
+	}
+	pending := func(txID string) *Transaction {
+		if member, ok := members[txID]; ok {
+			return member
+		}
+		tx, _ := mp.GetTransaction(txID)
+		return tx
+	}
+	offer := func(offerTxID string) *Transaction {
+		if offerTx := pending(offerTxID); offerTx != nil {
+			return offerTx
+		}
+		offerTx, _ := utxoStore.GetTransaction(offerTxID)
+		return offerTx
+	}
+	if err := checkInputValues(tx, lookup, offer); err != nil {
+		return err
+	}
+	if err := CheckWeightFee(tx, lookup, poolRegistry); err != nil {
+		return err
+	}
+	if tokenRegistry == nil {
+		return nil
+	}
+	_, _, err := simulateTokenEffects(tx, txID, utxoStore, tokenRegistry, poolRegistry, height, pending)
+	return err
+}
+
+// insertPackage adds checked package members, all or none. Members may not conflict
+// with pending transactions: a package can't replace by fee.
+func (mp *Mempool) insertPackage(txs []*Transaction, ids []string, fees []uint64, packageID string, local bool) error {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+
+	for i, tx := range txs {
+		if _, exists := mp.entries[ids[i]]; exists {
+			return fmt.Errorf("transaction %s already in mempool", ids[i][:16])
+		}
+		if conflicts := mp.conflictsLocked(tx); len(conflicts) > 0 {
+			return fmt.Errorf("package transaction %s double-spends pending transaction %s", ids[i][:16], conflicts[0][:16])
+		}
+	}
+
+	now := time.Now()
+	for i, tx := range txs {
+		mp.entries[ids[i]] = &MempoolEntry{
+			Tx:             tx,
+			AddedAtBlock:   mp.currentHeight,
+			AddedTimestamp: now,
+			SizeBytes:      mp.estimateTxSize(tx),
+			Fee:            fees[i],
+			Local:          local,
+		}
+	}
+
+	// Over the size cap the package stays only if all of it does
+	err := mp.enforceMemoryLimitLocked(packageID)
+	for _, txID := range ids {
+		if _, ok := mp.entries[txID]; !ok && err == nil {
+			err = fmt.Errorf("%w: package evicted on arrival", ErrMempoolFull)
+		}
+	}
+	if err != nil {
+		for _, txID := range ids {
+			delete(mp.entries, txID)
+		}
+		return err
+	}
+
+	if mp.packages == nil {
+		mp.packages = make(map[string]string)
+	}
+	if len(ids) > 1 {
+		for _, txID := range ids {
+			mp.packages[txID] = packageID
+		}
+	}
+	return nil
+}
+
+// Packages returns the package of each pending package member, keyed by transaction ID.
+// Members no longer pending are forgotten.
+func (mp *Mempool) Packages() map[string]string {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+
+	packages := make(map[string]string, len(mp.packages))
+	for txID, packageID := range mp.packages {
+		if _, ok := mp.entries[txID]; !ok {
+			delete(mp.packages, txID)
+			continue
+		}
+		packages[txID] = packageID
+	}
+	return packages
+}
+
+// filterMissingReferences drops candidates closing an offer that is neither confirmed
+// nor another candidate, which would fail when the block is applied. Candidates were
+// simulated against pending offers, so one whose offer was filtered out must go too.
+func filterMissingReferences(candidates []*Transaction, utxoStore *UTXOStore) []*Transaction {
+	included := make(map[string]bool, len(candidates))
+	for _, tx := range candidates {
+		if txID, err := tx.ID(); err == nil {
+			included[txID] = true
+		}
+	}
+	kept := make([]*Transaction, 0, len(candidates))
+	for _, tx := range candidates {
+		missing := false
+		for _, ref := range txReferences(tx) {
+			if included[ref] {
+				continue
+			}
+			if confirmed, err := utxoStore.GetTransaction(ref); err != nil || confirmed == nil {
+				missing = true
+			}
+		}
+		if !missing {
+			kept = append(kept, tx)
+		}
+	}
+	return kept
+}
+
+// admitFetched admits transactions fetched from peer from. Those depending on each
+// other are admitted as a package, so a child isn't checked before its parent; the rest
+// go through the verification queue one by one.
+func (mp *Mempool) admitFetched(txs []*Transaction, from peer.ID) {
+	for _, group := range dependentGroups(txs) {
+		if len(group) == 1 || len(group) > MaxPackageTxs {
+			for _, tx := range group {
+				mp.addGossipTransaction(tx, from)
+			}
+			continue
+		}
+		if _, err := mp.acceptPackage(group, false, from); err != nil {
+			fmt.Printf("[Mempool] Rejected package from %s: %v\n", shortPeerID(from), err)
+			for _, tx := range group {
+				mp.recordRejected(tx, from, err)
+			}
+		}
+	}
+}
+
+// dependentGroups splits txs into groups connected by dependencies, in first-seen order
+func dependentGroups(txs []*Transaction) [][]*Transaction {
+	index := make(map[string]int, len(txs))
+	for i, tx := range txs {
+		if txID, err := tx.ID(); err == nil {
+			index[txID] = i
+		}
+	}
+	group := make([]int, len(txs))
+	for i := range group {
+		group[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		for group[i] != i {
+			group[i] = group[group[i]]
+			i = group[i]
+		}
+		return i
+	}
+	for i, tx := range txs {
+		for _, dep := range txDependencies(tx) {
+			if j, ok := index[dep]; ok {
+				group[root(i)] = root(j)
+			}
+		}
+	}
+
+	var groups [][]*Transaction
+	slot := make(map[int]int)
+	for i, tx := range txs {
+		r := root(i)
+		if at, ok := slot[r]; ok {
+			groups[at] = append(groups[at], tx)
+			continue
+		}
+		slot[r] = len(groups)
+		groups = append(groups, []*Transaction{tx})
+	}
+	return groups
+}
+
+// handlePackagePush admits a package a peer forwarded to us as leader
+func (mp *Mempool) handlePackagePush(from peer.ID, txs []*Transaction) {
+	if len(txs) > MaxPackageTxs {
+		fmt.Printf("[Mempool] Dropped package of %d transactions from %s\n", len(txs), shortPeerID(from))
+		return
+	}
+	if _, err := mp.acceptPackage(txs, false, from); err != nil {
+		fmt.Printf("[Mempool] Rejected package from %s: %v\n", shortPeerID(from), err)
+		return
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.pushed += uint64(len(txs))
+	mp.relay.mu.Unlock()
+}
@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// offerAndAccept builds an offer and an acceptance closing it
+func offerAndAccept(t *testing.T) (offer, accept *Transaction, offerID, acceptID string) {
+	kp, _ := GenerateKeyPair()
+	offer = NewTxBuilder(TxTypeOffer).AddInput("funding-offer", 0).AddOutput(kp.Address(), 100, "SHADOW").Build()
+	offerID, _ = offer.ID()
+	data, _ := json.Marshal(AcceptOfferData{OfferTxID: offerID})
+	accept = NewTxBuilder(TxTypeAcceptOffer).AddInput("funding-accept", 0).AddOutput(kp.Address(), 50, "SHADOW").Build()
+	accept.Data = data
+	acceptID, _ = accept.ID()
+	return offer, accept, offerID, acceptID
+}
+
+func TestOrderPackage(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	parent := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddOutput(kp.Address(), 990, "SHADOW").Build()
+	parentID, _ := parent.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddOutput(kp.Address(), 900, "SHADOW").Build()
+	childID, _ := child.ID()
+
+	// Children go after their parents, however they were submitted
+	ordered, ids, err := OrderPackage([]*Transaction{child, parent})
+	if err != nil {
+		t.Fatalf("Expected the package ordered, got %v", err)
+	}
+	if ordered[0] != parent || ids[0] != parentID || ids[1] != childID {
+		t.Errorf("Expected the parent first, got %s then %s", ids[0][:16], ids[1][:16])
+	}
+
+	// An acceptance depends on the offer it names
+	offer, accept, offerID, _ := offerAndAccept(t)
+	if _, ids, err := OrderPackage([]*Transaction{accept, offer}); err != nil || ids[0] != offerID {
+		t.Errorf("Expected the offer first, got %v (%v)", ids, err)
+	}
+
+	unrelated := NewTxBuilder(TxTypeSend).AddInput("other", 0).AddOutput(kp.Address(), 10, "SHADOW").Build()
+	doubleSpend := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddOutput(kp.Address(), 980, "SHADOW").Build()
+	for name, txs := range map[string][]*Transaction{
+		"empty":       nil,
+		"duplicate":   {parent, parent},
+		"unconnected": {parent, child, unrelated},
+		"doubleSpend": {parent, doubleSpend},
+	} {
+		if _, _, err := OrderPackage(txs); err == nil {
+			t.Errorf("Expected the %s package rejected", name)
+		}
+	}
+}
+
+func TestAcceptPackageChildPaysForParent(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "funding", Output: CreateShadowOutput(kp.Address(), 10_000)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	parent := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddOutput(kp.Address(), 9_999, "SHADOW").Build()
+	if err := parent.Sign(kp); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	parentID, _ := parent.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddOutput(kp.Address(), 8_999, "SHADOW").Build()
+	if err := child.Sign(kp); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	childID, _ := child.ID()
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store, minRelayFee: 100}
+
+	// Alone the parent pays less than the floor
+	if _, err := mp.acceptPackage([]*Transaction{parent}, false, ""); err == nil {
+		t.Fatal("Expected the parent rejected alone")
+	}
+
+	result, err := mp.acceptPackage([]*Transaction{child, parent}, false, "")
+	if err != nil {
+		t.Fatalf("Expected the child to pay for its parent, got %v", err)
+	}
+	if result.PackageID != childID || result.Fee != 1_001 || mp.Count() != 2 {
+		t.Errorf("Unexpected package result %+v with %d pending", result, mp.Count())
+	}
+	if packages := mp.Packages(); packages[parentID] != childID || packages[childID] != childID {
+		t.Errorf("Expected both members recorded in the package, got %v", packages)
+	}
+
+	// Resubmitting adds nothing
+	if _, err := mp.acceptPackage([]*Transaction{parent, child}, false, ""); err == nil || !strings.Contains(err.Error(), "already") {
+		t.Errorf("Expected a resubmitted package rejected, got %v", err)
+	}
+}
+
+func TestAcceptPackageAllOrNothing(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "funding", Output: CreateShadowOutput(kp.Address(), 10_000)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	parent := NewTxBuilder(TxTypeSend).AddInput("funding", 0).AddOutput(kp.Address(), 9_000, "SHADOW").Build()
+	if err := parent.Sign(kp); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	parentID, _ := parent.ID()
+	unsignedChild := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddOutput(kp.Address(), 8_000, "SHADOW").Build()
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	if _, err := mp.acceptPackage([]*Transaction{parent, unsignedChild}, false, ""); err == nil {
+		t.Fatal("Expected a package with an unsigned member rejected")
+	}
+	if mp.Count() != 0 {
+		t.Errorf("Expected nothing added from a rejected package, %d pending", mp.Count())
+	}
+}
+
+func TestCanonicalTxOrderReferences(t *testing.T) {
+	offer, accept, offerID, acceptID := offerAndAccept(t)
+	confirmed := func(txID string, index uint32) *TxOutput {
+		return CreateShadowOutput(Address{}, 1_000_000) // Whatever their fees, the offer goes first
+	}
+	ordered := CanonicalTxOrder([]*Transaction{accept, offer}, confirmed)
+	if id, _ := ordered[0].ID(); id != offerID {
+		t.Errorf("Expected the offer before acceptance %s, got %s first", acceptID[:16], id[:16])
+	}
+}
+
+func TestSelectBlockTransactionsHoldsPackages(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	confirmed := map[string]*TxOutput{
+		"funding-a:0": CreateShadowOutput(kp.Address(), 10_000),
+		"funding-b:0": CreateShadowOutput(kp.Address(), 10_000),
+	}
+	lookup := func(txID string, index uint32) *TxOutput { return confirmed[txID+":0"] }
+
+	parent := NewTxBuilder(TxTypeSend).AddInput("funding-a", 0).AddCustomOutput(CreateShadowOutput(kp.Address(), 9_000)).Build()
+	parentID, _ := parent.ID()
+	child := NewTxBuilder(TxTypeSend).AddInput(parentID, 0).AddCustomOutput(CreateShadowOutput(kp.Address(), 8_999)).Build()
+	childID, _ := child.ID()
+	independent := NewTxBuilder(TxTypeSend).AddInput("funding-b", 0).AddCustomOutput(CreateShadowOutput(kp.Address(), 9_500)).Build()
+	independentID, _ := independent.ID()
+
+	// Without the package the high-fee parent fits alone; as a package it needs room for both
+	room := TxSize(parent) + TxSize(independent)
+	if template := selectBlockTransactions([]*Transaction{parent, child, independent}, lookup, nil, room, 1<<30); len(template.TxIDs) != 2 {
+		t.Fatalf("Expected the parent and the independent transaction, got %d", len(template.TxIDs))
+	}
+	packages := map[string]string{parentID: childID, childID: childID}
+	template := selectBlockTransactions([]*Transaction{parent, child, independent}, lookup, packages, room, 1<<30)
+	if len(template.TxIDs) != 1 || template.TxIDs[0] != independentID {
+		t.Errorf("Expected the package left out whole, got %d transactions", len(template.TxIDs))
+	}
+	template = selectBlockTransactions([]*Transaction{parent, child, independent}, lookup, packages, 1<<20, 1<<30)
+	if len(template.TxIDs) != 3 {
+		t.Errorf("Expected everything to fit, got %d", len(template.TxIDs))
+	}
+}
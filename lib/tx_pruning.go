@@ -0,0 +1,192 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Disk-constrained nodes can prune transaction bodies. With a transaction prune depth,
+// the bodies of transactions in blocks more than that many blocks deep are deleted once
+// nothing reads them again: every output they created is spent, and they aren't offer
+// transactions, which later accepts, cancels and matches look up. Blocks keep their
+// headers and transaction IDs, which the block hash commits to, and the UTXO set,
+// registries and confirmation index are kept, so the node validates and serves new
+// blocks as before. Each pruned transaction leaves a marker with its height so the APIs
+// report it as pruned rather than unknown. Pruning can't be undone: a pruned chain can't
+// be reindexed, or exported from below the horizon.
+
+const (
+	TxPrunedPrefix    = "txpruned:"          // txpruned:{txid} -> height of its block
+	TxRetainedPrefix  = "txretain:"          // txretain:{txid} -> height; below the horizon but still read
+	TxPruneHorizonKey = "prunemeta:txbefore" // Height below which transaction bodies have been considered
+
+	TxStatusPruned = "pruned" // Applied in a block, body no longer stored
+)
+
+// SetTxPruneDepth configures transaction body pruning (0 = disabled)
+func (bc *Blockchain) SetTxPruneDepth(depth int) {
+	bc.chainLock.Lock()
+	defer bc.chainLock.Unlock()
+	if depth > 0 && depth < MinUTXOPruneDepth {
+		fmt.Printf("[Chain] Transaction prune depth %d below reorg safety margin, using %d\n", depth, MinUTXOPruneDepth)
+		depth = MinUTXOPruneDepth
+	}
+	bc.txPruneDepth = depth
+	if depth == 0 {
+		fmt.Printf("[Chain] Transaction pruning disabled (keeping all transaction bodies)\n")
+	} else {
+		fmt.Printf("[Chain] Transaction pruning enabled: deleting spent transaction bodies older than %d blocks\n", depth)
+	}
+}
+
+// PruneTransactions deletes transaction bodies older than txPruneDepth that nothing reads
+func (bc *Blockchain) PruneTransactions() error {
+	bc.chainLock.RLock()
+	depth := bc.txPruneDepth
+	currentHeight := uint64(len(bc.blocks))
+	bc.chainLock.RUnlock()
+
+	if depth == 0 || currentHeight <= uint64(depth) {
+		return nil
+	}
+	before := currentHeight - uint64(depth)
+
+	// Blocks already considered left their remaining transactions in the retained set
+	candidates := make(map[string]uint64)
+	for height := bc.utxoStore.TxPruneHorizon(); height < before; height++ {
+		block := bc.GetBlock(height)
+		if block == nil {
+			continue
+		}
+		for _, txID := range blockTxIDs(block) {
+			candidates[txID] = block.Index
+		}
+	}
+
+	pruned, err := bc.utxoStore.PruneTransactions(candidates, before)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		fmt.Printf("[Chain] Pruned %d transaction bodies (kept last %d blocks)\n", pruned, depth)
+	}
+	return nil
+}
+
+// PruneTransactions deletes the bodies of candidate transactions (ID -> block height) and
+// of previously retained ones that are no longer read, leaving a pruned marker for each,
+// and records beforeHeight as the new horizon. Returns the number pruned.
+func (store *UTXOStore) PruneTransactions(candidates map[string]uint64, beforeHeight uint64) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Collect retained transactions first - bolt can't write while a read cursor is open
+	iterator, err := store.db.Iterator([]byte(TxRetainedPrefix), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	retained := make(map[string]bool)
+	for ; iterator.Valid(); iterator.Next() {
+		txID := strings.TrimPrefix(string(iterator.Key()), TxRetainedPrefix)
+		if height, err := strconv.ParseUint(string(iterator.Value()), 10, 64); err == nil {
+			candidates[txID] = height
+			retained[txID] = true
+		}
+	}
+	iterator.Close()
+
+	var remove [][]byte
+	pruned := 0
+	for txID, height := range candidates {
+		data, err := store.db.Get([]byte(TxPrefix + txID))
+		if err != nil {
+			return pruned, fmt.Errorf("failed to get transaction: %w", err)
+		}
+		var tx Transaction
+		if data == nil || json.Unmarshal(data, &tx) != nil {
+			if retained[txID] {
+				remove = append(remove, []byte(TxRetainedPrefix+txID))
+			}
+			continue // Never stored (skipped when applied) or already pruned
+		}
+
+		keep, err := store.txStillRead(&tx, txID)
+		if err != nil {
+			return pruned, err
+		}
+		value := []byte(strconv.FormatUint(height, 10))
+		if keep {
+			if !retained[txID] {
+				if err := store.db.Set([]byte(TxRetainedPrefix+txID), value); err != nil {
+					return pruned, fmt.Errorf("failed to retain transaction: %w", err)
+				}
+			}
+			continue
+		}
+
+		if err := store.db.Set([]byte(TxPrunedPrefix+txID), value); err != nil {
+			return pruned, fmt.Errorf("failed to mark transaction pruned: %w", err)
+		}
+		remove = append(remove, []byte(TxPrefix+txID))
+		if retained[txID] {
+			remove = append(remove, []byte(TxRetainedPrefix+txID))
+		}
+		pruned++
+	}
+
+	if len(remove) > 0 {
+		if err := store.db.DeleteBatch(remove); err != nil {
+			return pruned, fmt.Errorf("failed to delete pruned transactions: %w", err)
+		}
+	}
+	if horizon, _ := store.readCounter(TxPruneHorizonKey); beforeHeight > horizon {
+		if err := store.db.Set([]byte(TxPruneHorizonKey), []byte(strconv.FormatUint(beforeHeight, 10))); err != nil {
+			return pruned, fmt.Errorf("failed to store transaction prune horizon: %w", err)
+		}
+	}
+	return pruned, nil
+}
+
+// txStillRead reports whether a transaction's body is still needed: it's an offer or
+// closes one, or one of its outputs is unspent (caller holds store.mutex)
+func (store *UTXOStore) txStillRead(tx *Transaction, txID string) (bool, error) {
+	switch tx.TxType {
+	case TxTypeOffer, TxTypeAcceptOffer, TxTypeCancelOffer, TxTypeMatchOffers:
+		return true, nil
+	}
+	for i := range tx.Outputs {
+		data, err := store.db.Get([]byte(fmt.Sprintf("%s%s:%d", UTXOPrefix, txID, i)))
+		if err != nil {
+			return false, fmt.Errorf("failed to get UTXO: %w", err)
+		}
+		var utxo UTXO
+		if data != nil && json.Unmarshal(data, &utxo) == nil && !utxo.IsSpent {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TxPruneHorizon returns the height below which transaction bodies may have been pruned
+// (0 = none)
+func (store *UTXOStore) TxPruneHorizon() uint64 {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	horizon, _ := store.readCounter(TxPruneHorizonKey)
+	return horizon
+}
+
+// TxPrunedAt returns the height of the block a pruned transaction was in, or false if
+// its body wasn't pruned
+func (store *UTXOStore) TxPrunedAt(txID string) (uint64, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(TxPrunedPrefix + txID))
+	if err != nil || data == nil {
+		return 0, false
+	}
+	height, err := strconv.ParseUint(string(data), 10, 64)
+	return height, err == nil
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneTransactionBodies(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	stored := func(txType TxType, amount uint64) string {
+		tx := NewTxBuilder(txType).AddInput("prune-test-funding", uint32(amount)).AddOutput(kp.Address(), amount, "").Build()
+		txID, _ := tx.ID()
+		if err := store.StoreTransaction(tx, 5); err != nil {
+			t.Fatalf("Failed to store transaction: %v", err)
+		}
+		if err := store.AddUTXO(&UTXO{TxID: txID, OutputIndex: 0, Output: tx.Outputs[0], BlockHeight: 5}); err != nil {
+			t.Fatalf("Failed to add output: %v", err)
+		}
+		return txID
+	}
+	spent, unspent, offer := stored(TxTypeSend, 1), stored(TxTypeSend, 2), stored(TxTypeOffer, 3)
+	for _, txID := range []string{spent, offer} {
+		if err := store.SpendUTXO(txID, 0, 6); err != nil {
+			t.Fatalf("Failed to spend output: %v", err)
+		}
+	}
+
+	pruned, err := store.PruneTransactions(map[string]uint64{spent: 5, unspent: 5, offer: 5}, 10)
+	if err != nil || pruned != 1 {
+		t.Fatalf("Expected only the spent send to be pruned, got %d (%v)", pruned, err)
+	}
+	if tx, _ := store.GetTransaction(spent); tx != nil {
+		t.Error("Expected the spent send's body to be deleted")
+	}
+	if height, ok := store.TxPrunedAt(spent); !ok || height != 5 {
+		t.Errorf("Expected the spent send to be reported pruned at height 5, got %d %v", height, ok)
+	}
+	for _, txID := range []string{unspent, offer} {
+		if tx, _ := store.GetTransaction(txID); tx == nil {
+			t.Errorf("Expected %s to be kept", txID[:16])
+		}
+	}
+	if store.TxPruneHorizon() != 10 {
+		t.Errorf("Expected the horizon at 10, got %d", store.TxPruneHorizon())
+	}
+
+	// Retained transactions are pruned once their outputs are spent
+	if err := store.SpendUTXO(unspent, 0, 12); err != nil {
+		t.Fatalf("Failed to spend output: %v", err)
+	}
+	if pruned, err := store.PruneTransactions(map[string]uint64{}, 20); err != nil || pruned != 1 {
+		t.Fatalf("Expected the retained send to be pruned, got %d (%v)", pruned, err)
+	}
+	if _, ok := store.TxPrunedAt(unspent); !ok {
+		t.Error("Expected the retained send to be reported pruned")
+	}
+
+	// History below the horizon can't be replayed or exported
+	if err := bc.Reindex(); err == nil {
+		t.Error("Expected reindex to be refused on a pruned chain")
+	}
+	if _, err := bc.ExportArchive(filepath.Join(t.TempDir(), "chain.gz"), 0, 0, false); err == nil {
+		t.Error("Expected an export from below the horizon to be refused")
+	}
+}
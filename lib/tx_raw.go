@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Raw transactions: the canonical binary form of a transaction for offline signing and
+// submission. An air-gapped signer receives a built transaction as raw hex, signs it and
+// hands back raw hex for /api/tx/submit_raw, without either side agreeing on a JSON
+// schema. The encoding is
+//
+//	"SHTX" | format (1 byte) | chain ID length (uvarint) | chain ID | canonical JSON
+//
+// The chain ID makes a transaction signed for one network fail to decode on another
+// with a clear error, before its signature (also bound to the chain ID) is checked. The
+// body must be exactly the canonical JSON encoding of the transaction, so one
+// transaction has one raw form.
+
+const (
+	RawTxMagic      = "SHTX"
+	RawTxFormat     = 1                      // Encoding version this node writes and reads
+	MaxRawTxBytes   = MaxTransactionSize     // Largest decoded raw transaction
+	MaxRawTxEncoded = 2*MaxRawTxBytes + 1024 // Largest hex or base64 submission, with room for a JSON wrapper
+)
+
+var (
+	ErrRawTxTooLarge   = fmt.Errorf("raw transaction exceeds %d bytes", MaxRawTxBytes)
+	ErrRawTxWrongChain = errors.New("raw transaction is for a different chain")
+)
+
+// EncodeRawTransaction returns the raw form of tx for the active chain
+func EncodeRawTransaction(tx *Transaction) ([]byte, error) {
+	body, err := CanonicalJSON(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	chainID := ActiveGenesis().ChainID
+
+	raw := make([]byte, 0, len(RawTxMagic)+1+binary.MaxVarintLen64+len(chainID)+len(body))
+	raw = append(raw, RawTxMagic...)
+	raw = append(raw, RawTxFormat)
+	raw = binary.AppendUvarint(raw, uint64(len(chainID)))
+	raw = append(raw, chainID...)
+	raw = append(raw, body...)
+	if len(raw) > MaxRawTxBytes {
+		return nil, ErrRawTxTooLarge
+	}
+	return raw, nil
+}
+
+// DecodeRawTransaction decodes a raw transaction for the active chain. Every way raw can
+// be malformed is a distinct error naming what is wrong.
+func DecodeRawTransaction(raw []byte) (*Transaction, error) {
+	if len(raw) > MaxRawTxBytes {
+		return nil, ErrRawTxTooLarge
+	}
+	if len(raw) < len(RawTxMagic)+1 || string(raw[:len(RawTxMagic)]) != RawTxMagic {
+		return nil, fmt.Errorf("not a raw transaction: missing %q prefix", RawTxMagic)
+	}
+	rest := raw[len(RawTxMagic):]
+	if format := rest[0]; format != RawTxFormat {
+		return nil, fmt.Errorf("unsupported raw transaction format %d (this node reads %d)", format, RawTxFormat)
+	}
+	rest = rest[1:]
+
+	length, n := binary.Uvarint(rest)
+	if n <= 0 || length > uint64(len(rest)-n) {
+		return nil, errors.New("raw transaction header is truncated")
+	}
+	chainID := string(rest[n : n+int(length)])
+	if active := ActiveGenesis().ChainID; chainID != active {
+		return nil, fmt.Errorf("%w: signed for %q, this node is on %q", ErrRawTxWrongChain, chainID, active)
+	}
+	body := rest[n+int(length):]
+	if len(body) == 0 {
+		return nil, errors.New("raw transaction has no body")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	var tx Transaction
+	if err := decoder.Decode(&tx); err != nil {
+		return nil, fmt.Errorf("invalid raw transaction body: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid raw transaction body: data after the transaction")
+	}
+
+	// A second encoding of the same transaction would be a second raw form of it
+	canonical, err := CanonicalJSON(&tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction body: %w", err)
+	}
+	if !bytes.Equal(canonical, body) {
+		return nil, errors.New("raw transaction body is not in canonical JSON form")
+	}
+	return &tx, nil
+}
+
+// ParseRawTransaction decodes a raw transaction given as hex (optionally 0x-prefixed) or
+// base64 (standard or URL alphabet, padded or not)
+func ParseRawTransaction(encoded string) (*Transaction, error) {
+	raw, err := DecodeRawText(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRawTransaction(raw)
+}
+
+// DecodeRawText returns the bytes of a hex or base64 raw transaction. Hex is tried first:
+// a raw transaction in hex begins with the magic's hex, which is never valid base64 of it.
+func DecodeRawText(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, errors.New("raw transaction is empty")
+	}
+	if len(encoded) > MaxRawTxEncoded {
+		return nil, ErrRawTxTooLarge
+	}
+
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(encoded, "0x"), "0X")
+	if raw, err := hex.DecodeString(trimmed); err == nil {
+		return raw, nil
+	} else if trimmed != encoded || strings.HasPrefix(strings.ToLower(encoded), hex.EncodeToString([]byte(RawTxMagic))) {
+		return nil, fmt.Errorf("invalid hex raw transaction: %w", err)
+	}
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := encoding.DecodeString(encoded); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, errors.New("raw transaction is neither hex nor base64")
+}
+
+// ParseRawTxBody decodes a /api/tx/submit_raw request body: the raw bytes as
+// application/octet-stream, {"raw": "<hex or base64>"} as application/json, or bare hex
+// or base64 as anything else
+func ParseRawTxBody(contentType string, body []byte) (*Transaction, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/octet-stream":
+		return DecodeRawTransaction(body)
+	case "application/json":
+		var req struct {
+			Raw string `json:"raw"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		return ParseRawTransaction(req.Raw)
+	default:
+		return ParseRawTransaction(string(body))
+	}
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newRawTestTransaction(t *testing.T) *Transaction {
+	owner, _ := GenerateKeyPair()
+	recipient, _ := GenerateKeyPair()
+	utxos := []*UTXO{{TxID: "a", OutputIndex: 0, Output: CreateShadowOutput(owner.Address(), 50_000)}}
+	built, err := BuildSendTransaction(nil, utxos, []*TxOutput{CreateShadowOutput(recipient.Address(), 1_000)}, owner.Address(), 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	if err := built.Transaction.Sign(owner); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	return built.Transaction
+}
+
+func TestRawTransactionRoundTrip(t *testing.T) {
+	tx := newRawTestTransaction(t)
+	raw, err := EncodeRawTransaction(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	wantID, _ := tx.ID()
+
+	encodings := map[string]string{
+		"bytes":      string(raw),
+		"hex":        hex.EncodeToString(raw),
+		"0x hex":     "0x" + hex.EncodeToString(raw) + "\n",
+		"base64":     base64.StdEncoding.EncodeToString(raw),
+		"raw base64": base64.RawURLEncoding.EncodeToString(raw),
+	}
+	for name, encoded := range encodings {
+		var decoded *Transaction
+		if name == "bytes" {
+			decoded, err = DecodeRawTransaction([]byte(encoded))
+		} else {
+			decoded, err = ParseRawTransaction(encoded)
+		}
+		if err != nil {
+			t.Errorf("%s: failed to decode: %v", name, err)
+			continue
+		}
+		if id, _ := decoded.ID(); id != wantID {
+			t.Errorf("%s: expected tx %s, got %s", name, wantID, id)
+		}
+		if again, _ := EncodeRawTransaction(decoded); !bytes.Equal(again, raw) {
+			t.Errorf("%s: re-encoding changed the raw form", name)
+		}
+	}
+}
+
+func TestRawTransactionWrongChain(t *testing.T) {
+	raw, err := EncodeRawTransaction(newRawTestTransaction(t))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	genesis := DefaultChainGenesis()
+	genesis.ChainID = "shadowy-mainnet-1"
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	_, err = DecodeRawTransaction(raw)
+	if !errors.Is(err, ErrRawTxWrongChain) {
+		t.Fatalf("Expected a wrong-chain error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), DefaultChainID) {
+		t.Errorf("Expected the error to name the transaction's chain, got %q", err)
+	}
+}
+
+func TestRawTransactionRejectsMalformed(t *testing.T) {
+	raw, err := EncodeRawTransaction(newRawTestTransaction(t))
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	start := bytes.IndexByte(raw, '{')
+	prefix, body := raw[:start], raw[start:]
+	with := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	cases := map[string][]byte{
+		"bad magic":     with([]byte("SHTY"), raw[4:]),
+		"bad format":    with(raw[:4], []byte{2}, raw[5:]),
+		"truncated":     raw[:6],
+		"no body":       prefix,
+		"not canonical": with(prefix, []byte(" "), body),
+		"unknown field": with(prefix, []byte(`{"bogus":1,`), body[1:]),
+		"trailing data": with(raw, []byte("{}")),
+		"not json":      with(prefix, []byte("garbage")),
+	}
+	for name, bad := range cases {
+		if _, err := DecodeRawTransaction(bad); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := DecodeRawTransaction(with(raw, make([]byte, MaxRawTxBytes))); !errors.Is(err, ErrRawTxTooLarge) {
+		t.Errorf("Expected ErrRawTxTooLarge, got %v", err)
+	}
+
+	for _, text := range []string{"", "0xzz", hex.EncodeToString(raw)[:11], "not*base64"} {
+		if _, err := ParseRawTransaction(text); err == nil {
+			t.Errorf("Expected %q rejected", text)
+		}
+	}
+}
+
+func TestParseRawTxBody(t *testing.T) {
+	tx := newRawTestTransaction(t)
+	raw, err := EncodeRawTransaction(tx)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	wantID, _ := tx.ID()
+
+	bodies := map[string][]byte{
+		"application/octet-stream":        raw,
+		"application/json; charset=utf-8": []byte(`{"raw":"` + base64.StdEncoding.EncodeToString(raw) + `"}`),
+		"text/plain":                      []byte(hex.EncodeToString(raw)),
+		"":                                []byte(hex.EncodeToString(raw)),
+	}
+	for contentType, body := range bodies {
+		decoded, err := ParseRawTxBody(contentType, body)
+		if err != nil {
+			t.Errorf("%q: failed to decode: %v", contentType, err)
+			continue
+		}
+		if id, _ := decoded.ID(); id != wantID {
+			t.Errorf("%q: expected tx %s, got %s", contentType, wantID, id)
+		}
+	}
+	if _, err := ParseRawTxBody("application/json", []byte(hex.EncodeToString(raw))); err == nil {
+		t.Error("Expected bare hex rejected as JSON")
+	}
+}
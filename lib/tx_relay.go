@@ -0,0 +1,531 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	TxRelayProtocolID = "/shadowy/txrelay/1.0.0"
+	MaxInvTxIDs       = 1000             // Maximum tx IDs per announcement or request
+	KnownTxFilterSize = 5000             // Tx IDs remembered per peer
+	SeenTxCacheSize   = 100000           // Tx IDs remembered across mempool removal
+	TxFetchTimeout    = 10 * time.Second // Deadline for fetching announced bodies
+
+	// Largest relay request read from a stream: a push of MaxInvTxIDs transactions
+	MaxTxRequestBytes = MaxInvTxIDs * MaxTransactionSize
+)
+
+// TxRequest asks a peer for transaction bodies ("get"), announces IDs directly ("inv"),
+// or forwards bodies to the leader ("push", or "package" for dependent transactions)
+type TxRequest struct {
+	Type         string         `json:"type"` // "get", "inv", "push" or "package"
+	TxIDs        []string       `json:"tx_ids"`
+	Transactions []*Transaction `json:"transactions,omitempty"` // "push" and "package" only
+}
+
+// TxResponse carries the requested transactions the peer still has
+type TxResponse struct {
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// knownTxSet is a bounded set of tx IDs that forgets the oldest entries first
+type knownTxSet struct {
+	ids   map[string]struct{}
+	order []string
+	next  int
+}
+
+// newKnownTxSet creates a set holding at most capacity IDs
+func newKnownTxSet(capacity int) *knownTxSet {
+	return &knownTxSet{
+		ids:   make(map[string]struct{}, capacity),
+		order: make([]string, capacity),
+	}
+}
+
+// Add records id, evicting the oldest entry if the set is full
+func (k *knownTxSet) Add(id string) {
+	if _, ok := k.ids[id]; ok {
+		return
+	}
+	if old := k.order[k.next]; old != "" {
+		delete(k.ids, old)
+	}
+	k.order[k.next] = id
+	k.next = (k.next + 1) % len(k.order)
+	k.ids[id] = struct{}{}
+}
+
+// Has reports whether id is in the set
+func (k *knownTxSet) Has(id string) bool {
+	_, ok := k.ids[id]
+	return ok
+}
+
+// txRelay tracks which peers know which transactions and which bodies are being fetched
+type txRelay struct {
+	mu       sync.Mutex
+	seen     *knownTxSet             // Every tx ID we've handled, even after it left the mempool
+	known    map[peer.ID]*knownTxSet // Per-peer filters of tx IDs the peer has or was sent
+	inflight map[string]struct{}     // Tx IDs currently being fetched
+	stats    struct{ announced, fetched, served, belowFee, replaced, forwarded, pushed uint64 }
+}
+
+// newTxRelay creates empty relay state
+func newTxRelay() *txRelay {
+	return &txRelay{
+		seen:     newKnownTxSet(SeenTxCacheSize),
+		known:    make(map[peer.ID]*knownTxSet),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// markKnown records that p has the given transactions
+func (r *txRelay) markKnown(p peer.ID, txIDs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter, ok := r.known[p]
+	if !ok {
+		filter = newKnownTxSet(KnownTxFilterSize)
+		r.known[p] = filter
+	}
+	for _, id := range txIDs {
+		filter.Add(id)
+	}
+}
+
+// unknownTo returns the IDs p is not known to have
+func (r *txRelay) unknownTo(p peer.ID, txIDs []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter, ok := r.known[p]
+	if !ok {
+		return txIDs
+	}
+	var unknown []string
+	for _, id := range txIDs {
+		if !filter.Has(id) {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
+
+// markSeen records a tx ID as handled; returns false if it was already seen
+func (r *txRelay) markSeen(txID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seen.Has(txID) {
+		return false
+	}
+	r.seen.Add(txID)
+	return true
+}
+
+// unmarkSeen forgets a tx ID that was marked seen but could not be handled
+func (r *txRelay) unmarkSeen(txID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.seen.ids, txID)
+}
+
+// claimUnseen returns the IDs that are neither seen nor in flight, marking them in flight
+func (r *txRelay) claimUnseen(txIDs []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var wanted []string
+	for _, id := range txIDs {
+		if r.seen.Has(id) {
+			continue
+		}
+		if _, busy := r.inflight[id]; busy {
+			continue
+		}
+		r.inflight[id] = struct{}{}
+		wanted = append(wanted, id)
+	}
+	return wanted
+}
+
+// release clears the in-flight marks for txIDs
+func (r *txRelay) release(txIDs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range txIDs {
+		delete(r.inflight, id)
+	}
+}
+
+// dropPeer forgets a disconnected peer's filter
+func (r *txRelay) dropPeer(p peer.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.known, p)
+}
+
+// RelayPolicy is what a node requires of transactions it accepts and relays, on top of
+// the network's minimum fee rate and dust threshold. Amounts are base units of the
+// genesis token.
+type RelayPolicy struct {
+	MinFee        uint64 `json:"min_relay_fee"`        // Fee every transaction pays, 0 = no floor
+	MinFeeRate    uint64 `json:"min_relay_fee_rate"`   // Fee per 1000 weight, 0 = no floor
+	DustThreshold uint64 `json:"relay_dust_threshold"` // Smallest SHADOW output, 0 = the network's
+}
+
+// SetRelayPolicy sets what a transaction must meet to be accepted and relayed. The UTXO
+// store is used to price inputs.
+func (mp *Mempool) SetRelayPolicy(policy RelayPolicy, utxoStore *UTXOStore) {
+	mp.txLock.Lock()
+	defer mp.txLock.Unlock()
+	mp.minRelayFee = policy.MinFee
+	mp.minRelayFeeRate = policy.MinFeeRate
+	mp.relayDust = policy.DustThreshold
+	mp.utxoStore = utxoStore
+	if policy.MinFee > 0 {
+		fmt.Printf("[Mempool] Relay fee floor: %d\n", policy.MinFee)
+	}
+	if policy.MinFeeRate > 0 {
+		fmt.Printf("[Mempool] Relay fee rate floor: %d per 1000 weight\n", policy.MinFeeRate)
+	}
+	if policy.DustThreshold > 0 {
+		fmt.Printf("[Mempool] Relay dust threshold: %d\n", policy.DustThreshold)
+	}
+}
+
+// RelayPolicy returns what a transaction must meet to be accepted and relayed
+func (mp *Mempool) RelayPolicy() RelayPolicy {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+	return RelayPolicy{MinFee: mp.minRelayFee, MinFeeRate: mp.minRelayFeeRate, DustThreshold: mp.relayDust}
+}
+
+// MinRelayFee returns the relay fee floor
+func (mp *Mempool) MinRelayFee() uint64 {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+	return mp.minRelayFee
+}
+
+// relayFeeForWeight returns the fee rate floor for a weight, rounded up
+func relayFeeForWeight(rate uint64, weight int) uint64 {
+	return BlockLimits{MinFeePerKWeight: rate}.MinFee(weight)
+}
+
+// PaidFee returns inputs minus outputs in the genesis token, looking up inputs in the
+// UTXO store. Inputs that can't be found count as zero.
+func PaidFee(tx *Transaction, utxoStore *UTXOStore) uint64 {
+	return paidFee(tx, utxoLookup(utxoStore))
+}
+
+// paidFee returns inputs minus outputs in the genesis token, resolving inputs with
+// lookup. Inputs it can't resolve count as zero.
+func paidFee(tx *Transaction, lookup func(txID string, index uint32) *TxOutput) uint64 {
+	genesisTokenID := GetGenesisToken().TokenID
+
+	var in uint64
+	for _, input := range tx.Inputs {
+		output := lookup(input.PrevTxID, input.OutputIndex)
+		if output != nil && output.TokenID == genesisTokenID {
+			in += output.Amount
+		}
+	}
+
+	out := tx.GetTotalOutputAmount() + collateralLocked(tx) // Collateral is held, not paid
+	if out >= in {
+		return 0
+	}
+	return in - out
+}
+
+// utxoLookup resolves outputs from the UTXO store, spent ones included
+func utxoLookup(utxoStore *UTXOStore) func(txID string, index uint32) *TxOutput {
+	return func(txID string, index uint32) *TxOutput {
+		if utxo, err := utxoStore.GetUTXO(txID, index); err == nil && utxo != nil {
+			return utxo.Output
+		}
+		return nil
+	}
+}
+
+// pendingLookup resolves outputs from the UTXO store, then from pending transactions, so
+// a child spending a pending parent is priced by what it really pays
+func (mp *Mempool) pendingLookup(utxoStore *UTXOStore) func(txID string, index uint32) *TxOutput {
+	confirmed := utxoLookup(utxoStore)
+	return func(txID string, index uint32) *TxOutput {
+		if output := confirmed(txID, index); output != nil {
+			return output
+		}
+		mp.txLock.RLock()
+		defer mp.txLock.RUnlock()
+		if entry, ok := mp.entries[txID]; ok && int(index) < len(entry.Tx.Outputs) {
+			return entry.Tx.Outputs[index]
+		}
+		return nil
+	}
+}
+
+// meetsRelayFee checks a transaction against the relay fee floor and fee rate floor
+func (mp *Mempool) meetsRelayFee(tx *Transaction) error {
+	mp.txLock.RLock()
+	minFee, minFeeRate, utxoStore := mp.minRelayFee, mp.minRelayFeeRate, mp.utxoStore
+	mp.txLock.RUnlock()
+
+	if (minFee == 0 && minFeeRate == 0) || utxoStore == nil || tx.TxType == TxTypeCoinbase {
+		return nil
+	}
+	fee := paidFee(tx, mp.pendingLookup(utxoStore)) + mp.tokenFeeValue(tx)
+	if fee < minFee {
+		return fmt.Errorf("fee %d below relay floor %d", fee, minFee)
+	}
+	if weight := TxWeight(tx); fee < relayFeeForWeight(minFeeRate, weight) {
+		return fmt.Errorf("fee %d below relay floor %d for weight %d", fee, relayFeeForWeight(minFeeRate, weight), weight)
+	}
+	return nil
+}
+
+// announce gossips tx IDs instead of full bodies; peers fetch what they're missing
+func (mp *Mempool) announce(txIDs []string) error {
+	msg := MempoolMessage{
+		Type:      "inv",
+		TxIDs:     txIDs,
+		ChainID:   ActiveGenesis().ChainID,
+		Timestamp: time.Now().Unix(),
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal announcement: %w", err)
+	}
+
+	if err := mp.topic.Publish(mp.ctx, data); err != nil {
+		return fmt.Errorf("failed to publish announcement: %w", err)
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.announced += uint64(len(txIDs))
+	mp.relay.mu.Unlock()
+	return nil
+}
+
+// handleInv fetches announced transactions we haven't seen from the announcing peers
+func (mp *Mempool) handleInv(txIDs []string, candidates []peer.ID) {
+	if len(txIDs) > MaxInvTxIDs {
+		txIDs = txIDs[:MaxInvTxIDs]
+	}
+
+	for _, p := range candidates {
+		mp.relay.markKnown(p, txIDs...)
+	}
+
+	wanted := mp.relay.claimUnseen(txIDs)
+	if len(wanted) == 0 {
+		return
+	}
+	defer mp.relay.release(wanted)
+
+	for _, p := range candidates {
+		if p == mp.host.ID() {
+			continue
+		}
+
+		txs, err := mp.requestTransactions(p, wanted)
+		if err != nil {
+			fmt.Printf("[Mempool] Failed to fetch %d announced txs from %s: %v\n", len(wanted), p.String()[:16], err)
+			continue
+		}
+
+		mp.admitFetched(txs, p)
+
+		mp.relay.mu.Lock()
+		mp.relay.stats.fetched += uint64(len(txs))
+		mp.relay.mu.Unlock()
+		return
+	}
+}
+
+// requestTransactions fetches transaction bodies from a peer
+func (mp *Mempool) requestTransactions(p peer.ID, txIDs []string) ([]*Transaction, error) {
+	ctx, cancel := context.WithTimeout(mp.ctx, TxFetchTimeout)
+	defer cancel()
+
+	s, err := mp.host.NewStream(ctx, p, TxRelayProtocolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(TxFetchTimeout))
+
+	if err := json.NewEncoder(s).Encode(TxRequest{Type: "get", TxIDs: txIDs}); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp TxResponse
+	if err := json.NewDecoder(s).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Only accept what we asked for
+	requested := make(map[string]bool, len(txIDs))
+	for _, id := range txIDs {
+		requested[id] = true
+	}
+	txs := make([]*Transaction, 0, len(resp.Transactions))
+	for _, tx := range resp.Transactions {
+		if tx == nil {
+			continue
+		}
+		if id, err := tx.ID(); err == nil && requested[id] {
+			txs = append(txs, tx)
+		}
+	}
+	return txs, nil
+}
+
+// handleTxRequest serves transaction bodies from the mempool, or accepts a direct announcement
+func (mp *Mempool) handleTxRequest(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(TxFetchTimeout))
+
+	var req TxRequest
+	if err := json.NewDecoder(io.LimitReader(s, MaxTxRequestBytes)).Decode(&req); err != nil {
+		fmt.Printf("[Mempool] Failed to decode tx request: %v\n", err)
+		return
+	}
+	if len(req.TxIDs) > MaxInvTxIDs {
+		req.TxIDs = req.TxIDs[:MaxInvTxIDs]
+	}
+
+	if req.Type == "inv" {
+		go mp.handleInv(req.TxIDs, []peer.ID{s.Conn().RemotePeer()})
+		return
+	}
+	// Bodies are only pushed to the leader; anyone else gets them by gossip
+	if (req.Type == "push" || req.Type == "package") && !mp.isLeader() {
+		fmt.Printf("[Mempool] Dropped %s of %d transactions from %s: not the leader\n",
+			req.Type, len(req.Transactions), shortPeerID(s.Conn().RemotePeer()))
+		return
+	}
+	if req.Type == "push" {
+		mp.handlePush(s.Conn().RemotePeer(), req.Transactions)
+		return
+	}
+	if req.Type == "package" {
+		mp.handlePackagePush(s.Conn().RemotePeer(), req.Transactions)
+		return
+	}
+
+	var resp TxResponse
+	var served []string
+	for _, id := range req.TxIDs {
+		if tx, ok := mp.GetTransaction(id); ok {
+			resp.Transactions = append(resp.Transactions, tx)
+			served = append(served, id)
+		}
+	}
+
+	// The requester has these now
+	mp.relay.markKnown(s.Conn().RemotePeer(), served...)
+
+	if err := json.NewEncoder(s).Encode(resp); err != nil {
+		fmt.Printf("[Mempool] Failed to send tx response: %v\n", err)
+		return
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.served += uint64(len(served))
+	mp.relay.mu.Unlock()
+}
+
+// announceToPeer sends a newly connected peer the mempool tx IDs it doesn't know about
+func (mp *Mempool) announceToPeer(p peer.ID) {
+	txIDs := mp.relay.unknownTo(p, mp.txIDs())
+	for len(txIDs) > 0 {
+		batch := txIDs
+		if len(batch) > MaxInvTxIDs {
+			batch = batch[:MaxInvTxIDs]
+		}
+		txIDs = txIDs[len(batch):]
+
+		if err := mp.sendInv(p, batch); err != nil {
+			fmt.Printf("[Mempool] Failed to announce mempool to %s: %v\n", p.String()[:16], err)
+			return
+		}
+		mp.relay.markKnown(p, batch...)
+	}
+}
+
+// AnnounceAll re-announces every mempool transaction to the network. Returns the number
+// announced.
+func (mp *Mempool) AnnounceAll() (int, error) {
+	txIDs := mp.txIDs()
+	announced := 0
+	for len(txIDs) > 0 {
+		batch := txIDs
+		if len(batch) > MaxInvTxIDs {
+			batch = batch[:MaxInvTxIDs]
+		}
+		txIDs = txIDs[len(batch):]
+
+		if err := mp.announce(batch); err != nil {
+			return announced, err
+		}
+		announced += len(batch)
+	}
+	return announced, nil
+}
+
+// sendInv announces tx IDs directly to one peer
+func (mp *Mempool) sendInv(p peer.ID, txIDs []string) error {
+	ctx, cancel := context.WithTimeout(mp.ctx, TxFetchTimeout)
+	defer cancel()
+
+	s, err := mp.host.NewStream(ctx, p, TxRelayProtocolID)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer s.Close()
+
+	return json.NewEncoder(s).Encode(TxRequest{Type: "inv", TxIDs: txIDs})
+}
+
+// txIDs returns the IDs of all mempool transactions
+func (mp *Mempool) txIDs() []string {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+
+	ids := make([]string, 0, len(mp.entries))
+	for id := range mp.entries {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// RelayStats returns transaction relay counters
+func (mp *Mempool) RelayStats() map[string]interface{} {
+	mp.relay.mu.Lock()
+	defer mp.relay.mu.Unlock()
+
+	return map[string]interface{}{
+		"announced":     mp.relay.stats.announced,
+		"fetched":       mp.relay.stats.fetched,
+		"served":        mp.relay.stats.served,
+		"below_fee":     mp.relay.stats.belowFee,
+		"replaced":      mp.relay.stats.replaced,
+		"forwarded":     mp.relay.stats.forwarded,
+		"pushed":        mp.relay.stats.pushed,
+		"tracked_peers": len(mp.relay.known),
+		"in_flight":     len(mp.relay.inflight),
+	}
+}
@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestKnownTxSetEvictsOldest(t *testing.T) {
+	set := newKnownTxSet(2)
+	set.Add("a")
+	set.Add("b")
+	set.Add("a") // Already present, must not take a slot
+	set.Add("c")
+
+	if set.Has("a") {
+		t.Fatal("Oldest entry should have been evicted")
+	}
+	if !set.Has("b") || !set.Has("c") {
+		t.Fatal("Newest entries should be kept")
+	}
+}
+
+func TestTxRelayDedupe(t *testing.T) {
+	r := newTxRelay()
+	p := peer.ID("peer-a")
+
+	if got := r.claimUnseen([]string{"tx1", "tx2"}); len(got) != 2 {
+		t.Fatalf("Expected both txs to be claimed, got %v", got)
+	}
+	// In flight: a second announcement must not trigger another fetch
+	if got := r.claimUnseen([]string{"tx1"}); len(got) != 0 {
+		t.Fatalf("Expected in-flight tx to be skipped, got %v", got)
+	}
+
+	r.markSeen("tx1")
+	r.release([]string{"tx1", "tx2"})
+
+	// tx1 stays deduped after release; tx2 failed and can be retried
+	if got := r.claimUnseen([]string{"tx1", "tx2"}); len(got) != 1 || got[0] != "tx2" {
+		t.Fatalf("Expected only tx2 to be claimable, got %v", got)
+	}
+
+	r.markKnown(p, "tx1")
+	if got := r.unknownTo(p, []string{"tx1", "tx3"}); len(got) != 1 || got[0] != "tx3" {
+		t.Fatalf("Expected only tx3 unknown to peer, got %v", got)
+	}
+	r.dropPeer(p)
+	if got := r.unknownTo(p, []string{"tx1"}); len(got) != 1 {
+		t.Fatal("Dropped peer should have no known txs")
+	}
+}
+
+func TestPaidFee(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	if err := store.AddUTXO(&UTXO{TxID: "aa", Output: CreateShadowOutput(kp.Address(), 10000)}); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+
+	tx := &Transaction{
+		TxType:  TxTypeSend,
+		Inputs:  []*TxInput{NewTxInput("aa", 0), NewTxInput("missing", 0)},
+		Outputs: []*TxOutput{CreateShadowOutput(kp.Address(), 9000)},
+	}
+	if fee := PaidFee(tx, store); fee != 1000 {
+		t.Fatalf("Expected fee 1000, got %d", fee)
+	}
+
+	tx.Outputs[0].Amount = 20000
+	if fee := PaidFee(tx, store); fee != 0 {
+		t.Fatalf("Expected overspend to report zero fee, got %d", fee)
+	}
+}
+
+func TestLeaderPush(t *testing.T) {
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), admission: newAdmissionQueue(10)}
+	kp, _ := GenerateKeyPair()
+	tx := NewTxBuilder(TxTypeSend).AddInput("push-test-funding", 0).AddOutput(kp.Address(), 1, "").Build()
+	txID, _ := tx.ID()
+	from := peer.ID("peer-a")
+
+	// A pushed transaction is verified like a gossiped one, once
+	mp.handlePush(from, []*Transaction{tx, tx, nil})
+	if len(mp.admission.jobs) != 1 {
+		t.Fatalf("Expected the pushed transaction to be queued once, got %d", len(mp.admission.jobs))
+	}
+	if got := mp.relay.unknownTo(from, []string{txID}); len(got) != 0 {
+		t.Error("Expected the pushing peer to be known to have the transaction")
+	}
+
+	// Without a known leader nothing is forwarded
+	mp.SetLeaderSource(func() peer.ID { return "" })
+	mp.forwardToLeader(tx)
+	if mp.relay.stats.forwarded != 0 {
+		t.Errorf("Expected nothing forwarded without a leader, got %d", mp.relay.stats.forwarded)
+	}
+
+	// Only the leader takes pushes
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		t.Fatalf("Failed to create host: %v", err)
+	}
+	defer h.Close()
+	mp.host = h
+	if mp.isLeader() {
+		t.Error("Expected no leadership without a known leader")
+	}
+	mp.SetLeaderSource(func() peer.ID { return from })
+	if mp.isLeader() {
+		t.Error("Expected no leadership while another peer leads")
+	}
+	mp.SetLeaderSource(h.ID)
+	if !mp.isLeader() {
+		t.Error("Expected leadership once this node leads")
+	}
+}
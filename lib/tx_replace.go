@@ -0,0 +1,185 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Replace-by-fee: a pending transaction is cancelled network-wide by broadcasting a
+// replacement that spends at least one of the same inputs and pays more. Every node
+// resolves the conflict the same way, so the higher-fee spender is the one that gets
+// mined and the replaced transaction is dropped everywhere.
+
+// feeOf prices a transaction for conflict resolution
+func (mp *Mempool) feeOf(tx *Transaction) uint64 {
+	mp.txLock.RLock()
+	utxoStore := mp.utxoStore
+	mp.txLock.RUnlock()
+
+	if utxoStore == nil || tx.TxType == TxTypeCoinbase {
+		return 0
+	}
+	return paidFee(tx, mp.pendingLookup(utxoStore)) + mp.tokenFeeValue(tx)
+}
+
+// conflictsLocked returns the pending transactions spending any input of tx
+// Must be called with txLock held
+func (mp *Mempool) conflictsLocked(tx *Transaction) []string {
+	spends := make(map[string]bool, len(tx.Inputs))
+	for _, input := range tx.Inputs {
+		spends[fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)] = true
+	}
+
+	var conflicts []string
+	for txID, entry := range mp.entries {
+		for _, input := range entry.Tx.Inputs {
+			if spends[fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)] {
+				conflicts = append(conflicts, txID)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// withDescendantsLocked returns txIDs followed by every pending transaction spending
+// their outputs, directly or through other pending transactions
+// Must be called with txLock held
+func (mp *Mempool) withDescendantsLocked(txIDs []string) []string {
+	included := make(map[string]bool, len(txIDs))
+	for _, txID := range txIDs {
+		included[txID] = true
+	}
+	all := append([]string(nil), txIDs...)
+	for i := 0; i < len(all); i++ {
+		for txID, entry := range mp.entries {
+			if included[txID] {
+				continue
+			}
+			for _, input := range entry.Tx.Inputs {
+				if input.PrevTxID == all[i] {
+					included[txID] = true
+					all = append(all, txID)
+					break
+				}
+			}
+		}
+	}
+	return all
+}
+
+// DescendantFees returns the fees of the pending transactions spending txID's outputs,
+// directly or through others, which a replacement of txID must also outbid
+func (mp *Mempool) DescendantFees(txID string) uint64 {
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+
+	var fees uint64
+	for _, descendantID := range mp.withDescendantsLocked([]string{txID})[1:] {
+		fees += mp.entries[descendantID].Fee
+	}
+	return fees
+}
+
+// replaceConflictsLocked evicts pending transactions that spend the same inputs as tx,
+// together with their descendants, which would be left spending outputs that no longer
+// exist. tx must pay more than all of them together plus the relay fee floor, and can't
+// spend an output of any of them. Returns the replaced transaction IDs, or an error if tx
+// loses the conflict.
+// Must be called with txLock held
+func (mp *Mempool) replaceConflictsLocked(tx *Transaction, fee uint64) ([]string, error) {
+	conflicts := mp.conflictsLocked(tx)
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	replaced := mp.withDescendantsLocked(conflicts)
+
+	evicted := make(map[string]bool, len(replaced))
+	var replacedFees uint64
+	for _, txID := range replaced {
+		evicted[txID] = true
+		replacedFees += mp.entries[txID].Fee
+	}
+	for _, input := range tx.Inputs {
+		if evicted[input.PrevTxID] {
+			return nil, fmt.Errorf("double-spend: spends an output of %s, which it would replace", input.PrevTxID[:16])
+		}
+	}
+	if fee <= replacedFees+mp.minRelayFee {
+		return nil, fmt.Errorf("double-spend: conflicts with %d pending tx (first %s) and %d descendants, fee %d must exceed %d",
+			len(conflicts), conflicts[0][:16], len(replaced)-len(conflicts), fee, replacedFees+mp.minRelayFee)
+	}
+
+	for _, txID := range replaced {
+		delete(mp.entries, txID)
+	}
+	return replaced, nil
+}
+
+// recordReplaced counts, logs and marks conflicted the transactions evicted by a
+// higher-fee spender
+func (mp *Mempool) recordReplaced(txID string, replaced []string) {
+	if len(replaced) == 0 {
+		return
+	}
+
+	mp.relay.mu.Lock()
+	mp.relay.stats.replaced += uint64(len(replaced))
+	mp.relay.mu.Unlock()
+
+	mp.txLock.RLock()
+	defer mp.txLock.RUnlock()
+	for _, oldID := range replaced {
+		fmt.Printf("[Mempool] ♻️  Replaced %s with higher-fee spender %s\n", oldID[:16], txID[:16])
+		mp.markConflictedLocked(&TxConflict{TxID: oldID, Cause: ConflictReplaced, ConflictingTxID: txID})
+	}
+}
+
+// BuildCancelReplacement builds an unsigned transaction that cancels tx by spending all
+// of its inputs back to owner, paying the smallest fee that wins the conflict: more than
+// tx's fee plus extraFee (the relay fee floor and the fees of tx's descendants). Every
+// input must belong to owner, and the genesis token inputs must cover the fee. Token
+// outputs carry the token's mint version in registry.
+func BuildCancelReplacement(tx *Transaction, owner Address, utxoStore *UTXOStore, registry *TokenRegistry, extraFee uint64) (*Transaction, error) {
+	if len(tx.Inputs) == 0 {
+		return nil, fmt.Errorf("transaction has no inputs to reclaim")
+	}
+
+	totals := make(map[string]uint64)
+	builder := NewTxBuilder(TxTypeSend).SetRegistry(registry)
+	for _, input := range tx.Inputs {
+		utxo, err := utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex)
+		if err != nil || utxo == nil || utxo.IsSpent {
+			return nil, fmt.Errorf("input %s:%d is no longer spendable", input.PrevTxID, input.OutputIndex)
+		}
+		if utxo.Output.Address != owner {
+			return nil, fmt.Errorf("input %s:%d belongs to %s", input.PrevTxID, input.OutputIndex, utxo.Output.Address.String())
+		}
+		totals[utxo.Output.TokenID] += utxo.Output.Amount
+		builder.AddInput(input.PrevTxID, input.OutputIndex)
+	}
+
+	genesisTokenID := GetGenesisToken().TokenID
+	fee := PaidFee(tx, utxoStore) + extraFee + 1
+	if totals[genesisTokenID] < fee {
+		return nil, fmt.Errorf("inputs hold %d %s, replacement fee is %d", totals[genesisTokenID], GetGenesisToken().Ticker, fee)
+	}
+	totals[genesisTokenID] -= fee
+
+	// Deterministic output order: base token first, then token IDs
+	tokenIDs := make([]string, 0, len(totals))
+	for tokenID, amount := range totals {
+		if tokenID != genesisTokenID && amount > 0 {
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+	}
+	sort.Strings(tokenIDs)
+	if totals[genesisTokenID] > 0 {
+		tokenIDs = append([]string{genesisTokenID}, tokenIDs...)
+	}
+	for _, tokenID := range tokenIDs {
+		builder.AddOutput(owner, totals[tokenID], tokenID)
+	}
+
+	return builder.Build(), nil
+}
@@ -0,0 +1,400 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Simulation dry-runs a transaction: it runs the mempool's admission checks, checks its
+// inputs against the UTXO set, and works out what applying it in the next block would
+// do, without adding it to the mempool or touching chain state. Pool results are quoted
+// at the current reserves, so a later block may see different ones.
+
+// Sources of simulated outputs
+const (
+	SimOutputTransaction = "transaction" // Carried by the transaction itself
+	SimOutputNode        = "node"        // Created when the block is applied (LP tokens, swap proceeds)
+	SimOutputRefund      = "refund"      // Returns the inputs of a transaction that would fail
+)
+
+// SimulatedOutput is an output applying the transaction would create
+type SimulatedOutput struct {
+	Index  uint32    `json:"index"`
+	Source string    `json:"source"`
+	Output *TxOutput `json:"output"`
+}
+
+// TxSimulation is the result of dry-running a transaction
+type TxSimulation struct {
+	TxID    string            `json:"tx_id"`
+	Valid   bool              `json:"valid"`
+	Error   string            `json:"error,omitempty"`
+	Height  uint64            `json:"height"` // Block the simulation assumes it lands in
+	Fee     uint64            `json:"fee"`    // Paid fee, token fees at their pool price if accepted
+	Outputs []SimulatedOutput `json:"outputs,omitempty"`
+	Events  []TxEvent         `json:"events,omitempty"`
+}
+
+// Simulate dry-runs tx as if it were included in the next block
+func (mp *Mempool) Simulate(tx *Transaction) *TxSimulation {
+	mp.txLock.RLock()
+	height, utxoStore, tokenRegistry, poolRegistry := mp.currentHeight+1, mp.utxoStore, mp.tokenRegistry, mp.poolRegistry
+	mp.txLock.RUnlock()
+
+	sim := &TxSimulation{Height: height}
+	fail := func(err error) *TxSimulation {
+		sim.Error = err.Error()
+		return sim
+	}
+
+	txID, err := mp.sanityCheck(tx)
+	sim.TxID = txID
+	if err != nil {
+		return fail(err)
+	}
+	if err := mp.verifyTransaction(tx); err != nil {
+		return fail(err)
+	}
+
+	if utxoStore == nil || tokenRegistry == nil {
+		return fail(fmt.Errorf("chain state unavailable"))
+	}
+
+	offer := func(offerTxID string) *Transaction {
+		offerTx, _ := utxoStore.GetTransaction(offerTxID)
+		return offerTx
+	}
+	if err := checkInputValues(tx, mp.pendingLookup(utxoStore), offer); err != nil {
+		return fail(err)
+	}
+	switch tx.TxType {
+	case TxTypeMintToken:
+		err = ValidateTokenMintTransaction(tx, tokenRegistry)
+	case TxTypeMelt:
+		err = ValidateTokenMeltTransaction(tx, utxoStore)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	sim.Fee = mp.feeOf(tx)
+
+	for i, output := range tx.Outputs {
+		if output.IsBurn() {
+			continue
+		}
+		if output.TokenID == "PENDING" {
+			minted := *output
+			minted.TokenID = txID // Mints take the transaction ID
+			output = &minted
+		}
+		sim.Outputs = append(sim.Outputs, SimulatedOutput{Index: uint32(i), Source: SimOutputTransaction, Output: output})
+	}
+
+	created, events, err := simulateTokenEffects(tx, txID, utxoStore, tokenRegistry, poolRegistry, height, nil)
+	if err != nil {
+		return fail(err)
+	}
+	sim.Outputs = append(sim.Outputs, created...)
+	sim.Events = events
+	sim.Valid = true
+	return sim
+}
+
+// checkInputValues checks that every input exists and that no token is paid out beyond
+// what the inputs carry. Mints and collateral issues create their token and melts release
+// locked SHADOW, so those are exempt; accepts and cancels pay out what their offer locked,
+// which offer resolves.
+func checkInputValues(tx *Transaction, lookup func(txID string, index uint32) *TxOutput, offer func(txID string) *Transaction) error {
+	in := make(map[string]uint64)
+	for _, input := range tx.Inputs {
+		output := lookup(input.PrevTxID, input.OutputIndex)
+		if output == nil {
+			return fmt.Errorf("UTXO not found for input %s:%d", input.PrevTxID, input.OutputIndex)
+		}
+		in[output.TokenID] += output.Amount
+	}
+	if tx.TxType == TxTypeAcceptOffer || tx.TxType == TxTypeCancelOffer {
+		var ref AcceptOfferData // Cancels reference their offer the same way
+		json.Unmarshal(tx.Data, &ref)
+		var offerData OfferData
+		if offerTx := offer(ref.OfferTxID); offerTx != nil && json.Unmarshal(offerTx.Data, &offerData) == nil {
+			in[offerData.HaveTokenID] += offerData.HaveAmount
+		}
+	}
+
+	out := make(map[string]uint64)
+	for _, output := range tx.Outputs {
+		if out[output.TokenID]+output.Amount < out[output.TokenID] {
+			return fmt.Errorf("outputs of token %s overflow", output.TokenID)
+		}
+		out[output.TokenID] += output.Amount
+	}
+	mintData, isSupplyMint := supplyMint(tx)
+	var issue CollateralIssueData
+	if tx.TxType == TxTypeCollateralIssue {
+		json.Unmarshal(tx.Data, &issue)
+	}
+	for tokenID, amount := range out {
+		if tokenID == "PENDING" || (isSupplyMint && tokenID == mintData.TokenID) || (issue.TokenID != "" && tokenID == issue.TokenID) ||
+			(tx.TxType == TxTypeMelt && tokenID == GetGenesisToken().TokenID) {
+			continue
+		}
+		if amount > in[tokenID] {
+			return fmt.Errorf("outputs of %d exceed inputs of %d for token %s", amount, in[tokenID], tokenID)
+		}
+	}
+	return nil
+}
+
+// simulateTokenEffects mirrors ProcessTokenTransaction without changing state, returning
+// the outputs the node would create and the events the transaction would emit. pending,
+// if set, resolves offers that will be applied earlier in the same block.
+func simulateTokenEffects(tx *Transaction, txID string, utxoStore *UTXOStore, tokenRegistry *TokenRegistry,
+	poolRegistry *PoolRegistry, height uint64, pending func(txID string) *Transaction) ([]SimulatedOutput, []TxEvent, error) {
+	nextIndex := uint32(len(tx.Outputs))
+	node := func(index uint32, output *TxOutput) SimulatedOutput {
+		return SimulatedOutput{Index: index, Source: SimOutputNode, Output: output}
+	}
+	getPool := func(poolID string) (*LiquidityPool, error) {
+		if poolRegistry == nil {
+			return nil, fmt.Errorf("pool registry unavailable")
+		}
+		return poolRegistry.GetPool(poolID)
+	}
+	recipient := func() (Address, error) {
+		if len(tx.Outputs) == 0 {
+			return Address{}, fmt.Errorf("no outputs found for %s", tx.TxType.String())
+		}
+		return tx.Outputs[0].Address, nil
+	}
+	getOffer := func(offerTxID string) (OfferData, error) {
+		var offerData OfferData
+		offerTx, err := utxoStore.GetTransaction(offerTxID)
+		if (err != nil || offerTx == nil) && pending != nil {
+			offerTx, err = pending(offerTxID), nil
+		}
+		if err != nil || offerTx == nil {
+			return offerData, fmt.Errorf("offer %s not found", offerTxID)
+		}
+		if err := json.Unmarshal(offerTx.Data, &offerData); err != nil {
+			return offerData, fmt.Errorf("failed to parse offer data: %w", err)
+		}
+		return offerData, nil
+	}
+
+	switch tx.TxType {
+	case TxTypeMintToken:
+		var mintData TokenMintData
+		if err := json.Unmarshal(tx.Data, &mintData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse mint data: %w", err)
+		}
+		if mintData.IsSupplyMint() {
+			if err := tokenRegistry.CheckMintRights(tx, mintData, height, nil); err != nil {
+				return nil, nil, err
+			}
+			signer, _ := txSigner(tx)
+			return nil, []TxEvent{{Type: TxEventTokenMinted, Height: height,
+				Address: signer.Display(), TokenID: mintData.TokenID, Amount: mintData.Amount}}, nil
+		}
+		tokenInfo, err := CreateCustomToken(mintData.Ticker, mintData.Desc, mintData.MaxMint, mintData.MaxDecimals, tx.Outputs[0].Address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create token info: %w", err)
+		}
+		minted := tokenInfo.TotalSupply
+		if mintData.InitialSupply > 0 {
+			minted = mintData.InitialSupply
+		}
+		if mintData.Collateral != nil {
+			minted = 0 // Issued later against positions
+		}
+		return nil, []TxEvent{{Type: TxEventTokenMinted, Height: height,
+			Address: tokenInfo.CreatorAddress.Display(), TokenID: txID, Amount: minted}}, nil
+
+	case TxTypeCollateralIssue, TxTypeCollateralMelt, TxTypeLiquidate:
+		price := func(token *TokenInfo) uint64 { return CollateralPrice(utxoStore, poolRegistry, token, height) }
+		effect, err := tokenRegistry.collateralEffect(tx, txID, height, price, utxoLookup(utxoStore), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var created []SimulatedOutput
+		if output := effect.releaseOutput(); output != nil {
+			created = append(created, node(nextIndex, output))
+		}
+		return created, []TxEvent{effect.event}, nil
+
+	case TxTypeDelegateMint:
+		data, err := tokenRegistry.CheckMintDelegation(tx)
+		if err != nil {
+			return nil, nil, err
+		}
+		event := TxEvent{Type: TxEventMintDelegated, Height: height, Address: data.Delegate.Display(), TokenID: data.TokenID}
+		if data.Revoke {
+			event.Type = TxEventMintRevoked
+		}
+		return nil, []TxEvent{event}, nil
+
+	case TxTypeMelt:
+		if len(tx.Inputs) == 0 {
+			return nil, nil, nil
+		}
+		first, err := utxoStore.GetUTXO(tx.Inputs[0].PrevTxID, tx.Inputs[0].OutputIndex)
+		if err != nil || first == nil {
+			return nil, nil, fmt.Errorf("melted token input not found")
+		}
+		tokenID := first.Output.TokenID
+		var melted uint64
+		for _, input := range tx.Inputs {
+			if utxo, err := utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex); err == nil && utxo != nil && utxo.Output.TokenID == tokenID {
+				melted += utxo.Output.Amount
+			}
+		}
+		for _, output := range tx.Outputs {
+			if output.TokenID == tokenID {
+				melted -= output.Amount
+			}
+		}
+		return nil, []TxEvent{{Type: TxEventTokenMelted, Height: height,
+			Address: first.Output.Address.Display(), TokenID: tokenID, Amount: melted}}, nil
+
+	case TxTypeBurn:
+		return nil, burnEvents(tx, height), nil
+
+	case TxTypeOffer:
+		var offerData OfferData
+		if err := json.Unmarshal(tx.Data, &offerData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse offer data: %w", err)
+		}
+		return nil, []TxEvent{offerEvent(TxEventOfferOpened, txID, offerData, height)}, nil
+
+	case TxTypeAcceptOffer, TxTypeCancelOffer:
+		var data AcceptOfferData // Cancel data has the same shape
+		if err := json.Unmarshal(tx.Data, &data); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse offer reference: %w", err)
+		}
+		offerData, err := getOffer(data.OfferTxID)
+		if err != nil {
+			return nil, nil, err
+		}
+		eventType := TxEventOfferAccepted
+		if tx.TxType == TxTypeCancelOffer {
+			eventType = TxEventOfferCancelled
+		}
+		return nil, []TxEvent{offerEvent(eventType, data.OfferTxID, offerData, height)}, nil
+
+	case TxTypeMatchOffers:
+		var matchData MatchOffersData
+		if err := json.Unmarshal(tx.Data, &matchData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse match data: %w", err)
+		}
+		var events []TxEvent
+		for _, offerTxID := range []string{matchData.MakerOfferTxID, matchData.TakerOfferTxID} {
+			offerData, err := getOffer(offerTxID)
+			if err != nil {
+				return nil, nil, err
+			}
+			events = append(events, offerEvent(TxEventOffersMatched, offerTxID, offerData, height))
+		}
+		return nil, events, nil
+
+	case TxTypeCreatePool:
+		var poolData CreatePoolData
+		if err := json.Unmarshal(tx.Data, &poolData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse pool data: %w", err)
+		}
+		for _, tokenID := range []string{poolData.TokenA, poolData.TokenB} {
+			if _, exists := tokenRegistry.GetToken(tokenID); !exists {
+				return nil, nil, fmt.Errorf("token not found: %s", tokenID)
+			}
+		}
+		if CalculateLPTokens(poolData.AmountA, poolData.AmountB) == 0 {
+			return nil, nil, fmt.Errorf("LP token amount cannot be zero")
+		}
+		supply, _ := lpTokenSupply(poolData.AmountA, poolData.AmountB)
+		events := append(burnEvents(tx, height), TxEvent{Type: TxEventPoolCreated, Height: height, Address: poolData.PoolAddress.Display(),
+			PoolID: txID, TokenID: txID, Amount: supply, AmountA: poolData.AmountA, AmountB: poolData.AmountB})
+		lpOutput := tokenRegistry.NewTokenOutput(poolData.PoolAddress, supply, txID, "liquidity_pool", nil)
+		return []SimulatedOutput{node(nextIndex, lpOutput)}, events, nil
+
+	case TxTypeAddLiquidity:
+		var addData AddLiquidityData
+		if err := json.Unmarshal(tx.Data, &addData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse add liquidity data: %w", err)
+		}
+		pool, err := getPool(addData.PoolID)
+		if err != nil {
+			return nil, nil, err
+		}
+		lpTokens := quoteAddLiquidity(pool, addData.AmountA, addData.AmountB)
+		if lpTokens < addData.MinLPTokens {
+			return nil, nil, fmt.Errorf("insufficient LP tokens: would receive %d, minimum %d", lpTokens, addData.MinLPTokens)
+		}
+		provider, err := recipient()
+		if err != nil {
+			return nil, nil, err
+		}
+		return []SimulatedOutput{node(nextIndex, tokenRegistry.NewTokenOutput(provider, lpTokens, pool.LPTokenID, "liquidity_pool", nil))},
+			[]TxEvent{{Type: TxEventLiquidityAdded, Height: height, Address: provider.Display(), PoolID: addData.PoolID,
+				TokenID: pool.LPTokenID, Amount: lpTokens, AmountA: addData.AmountA, AmountB: addData.AmountB}}, nil
+
+	case TxTypeRemoveLiquidity:
+		var removeData RemoveLiquidityData
+		if err := json.Unmarshal(tx.Data, &removeData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse remove liquidity data: %w", err)
+		}
+		pool, err := getPool(removeData.PoolID)
+		if err != nil {
+			return nil, nil, err
+		}
+		amountA, amountB := quoteRemoveLiquidity(pool, removeData.LPTokens)
+		if amountA < removeData.MinAmountA {
+			return nil, nil, fmt.Errorf("insufficient token A: would receive %d, minimum %d", amountA, removeData.MinAmountA)
+		}
+		if amountB < removeData.MinAmountB {
+			return nil, nil, fmt.Errorf("insufficient token B: would receive %d, minimum %d", amountB, removeData.MinAmountB)
+		}
+		provider, err := recipient()
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs := []SimulatedOutput{
+			node(nextIndex, tokenRegistry.NewTokenOutput(provider, amountA, pool.TokenA, "liquidity_pool", nil)),
+			node(nextIndex+1, tokenRegistry.NewTokenOutput(provider, amountB, pool.TokenB, "liquidity_pool", nil)),
+		}
+		return outputs, []TxEvent{{Type: TxEventLiquidityRemoved, Height: height, Address: provider.Display(), PoolID: removeData.PoolID,
+			TokenID: pool.LPTokenID, Amount: removeData.LPTokens, AmountA: amountA, AmountB: amountB}}, nil
+
+	case TxTypeSwap:
+		var swapData SwapData
+		if err := json.Unmarshal(tx.Data, &swapData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse swap data: %w", err)
+		}
+		pool, err := getPool(swapData.PoolID)
+		if err != nil {
+			return nil, nil, err
+		}
+		tokenOut, amountOut, err := quoteSwap(pool, swapData.TokenIn, swapData.AmountIn)
+		if err != nil {
+			return nil, nil, err
+		}
+		swapper, err := recipient()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Below its minimum the swap is still included: it fails and is refunded
+		if amountOut < swapData.MinAmountOut {
+			reason := fmt.Sprintf("insufficient output: would receive %d, minimum %d", amountOut, swapData.MinAmountOut)
+			refund := tokenRegistry.NewTokenOutput(swapper, swapData.AmountIn, swapData.TokenIn, "refund", nil)
+			return []SimulatedOutput{{Index: nextIndex, Source: SimOutputRefund, Output: refund}},
+				[]TxEvent{{Type: TxEventSwapFailed, Height: height, Address: swapper.Display(), PoolID: swapData.PoolID,
+					TokenIn: swapData.TokenIn, AmountIn: swapData.AmountIn, TokenOut: tokenOut, Reason: reason}}, nil
+		}
+		return []SimulatedOutput{node(nextIndex, tokenRegistry.NewTokenOutput(swapper, amountOut, tokenOut, "swap", nil))},
+			[]TxEvent{{Type: TxEventSwapExecuted, Height: height, Address: swapper.Display(), PoolID: swapData.PoolID,
+				TokenIn: swapData.TokenIn, AmountIn: swapData.AmountIn, TokenOut: tokenOut, AmountOut: amountOut}}, nil
+
+	case TxTypeHTLCLock, TxTypeHTLCClaim, TxTypeHTLCRefund:
+		return nil, htlcEvents(tx, txID, height, utxoStore.lookupOutput), nil
+	}
+	return nil, nil, nil
+}
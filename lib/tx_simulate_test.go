@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSimulateSwap(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+	pools := bc.GetPoolRegistry()
+
+	tokenX := strings.Repeat("ab", 32)
+	poolID := strings.Repeat("fa", 32)
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: tokenX, TokenB: GetGenesisToken().TokenID,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	for _, utxo := range []*UTXO{
+		{TxID: "simulate-test-token-x", Output: CreateTokenOutput(kp.Address(), 100, tokenX, "custom", nil)},
+		{TxID: "simulate-test-shadow", Output: CreateShadowOutput(kp.Address(), 1000)},
+	} {
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+
+	mp := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store,
+		poolRegistry: pools, tokenRegistry: bc.TokenRegistry(), currentHeight: 41}
+	swap := func(minOut, change uint64, sign bool) *Transaction {
+		data, _ := json.Marshal(SwapData{PoolID: poolID, TokenIn: tokenX, AmountIn: 100, MinAmountOut: minOut})
+		tx := NewTxBuilder(TxTypeSwap).AddInput("simulate-test-token-x", 0).AddInput("simulate-test-shadow", 0).
+			AddOutput(kp.Address(), change, "").SetData(data).Build()
+		if sign {
+			if err := tx.Sign(kp); err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+		}
+		return tx
+	}
+
+	sim := mp.Simulate(swap(100, 900, true))
+	if !sim.Valid || sim.Fee != 100 || sim.Height != 42 {
+		t.Fatalf("Expected a valid swap paying 100 at height 42, got %+v", sim)
+	}
+	if len(sim.Outputs) != 2 || sim.Outputs[1].Source != SimOutputNode || sim.Outputs[1].Index != 1 || sim.Outputs[1].Output.Amount != 181 {
+		t.Fatalf("Expected the change and 181 SHADOW swap proceeds, got %+v", sim.Outputs)
+	}
+	if len(sim.Events) != 1 || sim.Events[0].Type != TxEventSwapExecuted || sim.Events[0].AmountOut != 181 {
+		t.Fatalf("Expected a swap_executed event, got %+v", sim.Events)
+	}
+
+	// Nothing changed: the pool, the mempool and the UTXO set are untouched
+	if pool, _ := pools.GetPool(poolID); pool.ReserveA != 1000 || pool.ReserveB != 2000 {
+		t.Errorf("Simulation moved reserves to %d/%d", pool.ReserveA, pool.ReserveB)
+	}
+	if mp.Count() != 0 {
+		t.Errorf("Simulation added %d transactions to the mempool", mp.Count())
+	}
+	if utxo, _ := store.GetUTXO(sim.TxID, 1); utxo != nil {
+		t.Error("Simulation created a UTXO")
+	}
+
+	// Below its minimum the swap would be included and refunded
+	sim = mp.Simulate(swap(500, 900, true))
+	if !sim.Valid || sim.Outputs[1].Source != SimOutputRefund || sim.Outputs[1].Output.Amount != 100 ||
+		sim.Events[0].Type != TxEventSwapFailed {
+		t.Fatalf("Expected a refunded swap_failed, got %+v", sim)
+	}
+
+	for name, tx := range map[string]*Transaction{
+		"unsigned":  swap(100, 900, false),
+		"overspend": swap(100, 5000, true),
+	} {
+		if sim := mp.Simulate(tx); sim.Valid || sim.Error == "" {
+			t.Errorf("Expected the %s transaction to be invalid, got %+v", name, sim)
+		}
+	}
+	missing := NewTxBuilder(TxTypeSwap).AddInput("simulate-test-missing", 0).AddOutput(kp.Address(), 1, "").
+		SetData([]byte("{}")).Build()
+	missing.Sign(kp)
+	if sim := mp.Simulate(missing); sim.Valid || !strings.Contains(sim.Error, "not found") {
+		t.Errorf("Expected a missing input to be reported, got %+v", sim)
+	}
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Some transactions can be included in a block and still fail when they are applied,
+// such as a swap whose minimum output is no longer met at the reserves of its block.
+// The transaction still pays its fee, its locked inputs are refunded by an output the
+// node creates at the transaction's next output index, and the failure is recorded so
+// the transaction can be reported as failed rather than confirmed.
+
+const TxStatusPrefix = "txstatus:" // txstatus:{txid} -> TxFailure
+
+const (
+	TxStatusPending   = "pending"   // In the mempool
+	TxStatusConfirmed = "confirmed" // In a block
+	TxStatusFinalized = "finalized" // At least FinalityDepth blocks deep
+	TxStatusOrphaned  = "orphaned"  // Its block was replaced by a reorg
+	TxStatusFailed    = "failed"
+)
+
+// TxFailure records why a transaction included in a block failed
+type TxFailure struct {
+	Reason string    `json:"reason"`
+	Height uint64    `json:"height"`
+	Refund *TxOutput `json:"refund,omitempty"` // Output returning the locked inputs
+}
+
+// recordTxFailure marks a transaction as failed at height
+func (store *UTXOStore) recordTxFailure(txID string, failure *TxFailure) error {
+	data, err := json.Marshal(failure)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tx failure: %w", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(TxStatusPrefix+txID), data); err != nil {
+		return fmt.Errorf("failed to store tx failure: %w", err)
+	}
+	return nil
+}
+
+// GetTxFailure returns why a transaction failed, or nil if it didn't
+func (store *UTXOStore) GetTxFailure(txID string) (*TxFailure, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	data, err := store.db.Get([]byte(TxStatusPrefix + txID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tx status: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var failure TxFailure
+	if err := json.Unmarshal(data, &failure); err != nil {
+		return nil, fmt.Errorf("corrupt tx status %s", txID)
+	}
+	return &failure, nil
+}
+
+// refundFailedTx returns amount of tokenID locked by a failed transaction to address and
+// records the failure
+func (store *UTXOStore) refundFailedTx(tokenRegistry *TokenRegistry, tx *Transaction, txID string, address Address, amount uint64, tokenID string, reason string, height uint64) error {
+	refund := tokenRegistry.NewTokenOutput(address, amount, tokenID, "refund", nil)
+	if err := store.AddUTXO(&UTXO{
+		TxID:        txID,
+		OutputIndex: uint32(len(tx.Outputs)),
+		Output:      refund,
+		BlockHeight: height,
+		IsSpent:     false,
+	}); err != nil {
+		return fmt.Errorf("failed to create refund UTXO: %w", err)
+	}
+	return store.recordTxFailure(txID, &TxFailure{Reason: reason, Height: height, Refund: refund})
+}
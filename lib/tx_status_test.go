@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSwapSlippageRefund(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+	pools := bc.GetPoolRegistry()
+
+	tokenX := strings.Repeat("ab", 32)
+	poolID := strings.Repeat("fa", 32)
+	if err := pools.RegisterPool(&LiquidityPool{
+		PoolID: poolID, TokenA: tokenX, TokenB: GetGenesisToken().TokenID,
+		ReserveA: 1000, ReserveB: 2000, LPTokenID: poolID, LPTokenSupply: 1000, FeePercent: 30, CreatedAt: 1,
+	}); err != nil {
+		t.Fatalf("Failed to register pool: %v", err)
+	}
+
+	kp, _ := GenerateKeyPair()
+	swap := func(minOut uint64) (*Transaction, string) {
+		data, _ := json.Marshal(SwapData{PoolID: poolID, TokenIn: tokenX, AmountIn: 100, MinAmountOut: minOut})
+		tx := NewTxBuilder(TxTypeSwap).AddOutput(kp.Address(), 5, tokenX).SetData(data).Build()
+		txID, _ := tx.ID()
+		return tx, txID
+	}
+
+	// 100 X buys 181 SHADOW at these reserves, short of the 500 asked for
+	tx, txID := swap(500)
+	if err := store.ProcessTokenTransaction(tx, NewTokenRegistry(), pools, 7); err != nil {
+		t.Fatalf("A failed slippage check should not fail block application: %v", err)
+	}
+	refund, err := store.GetUTXO(txID, 1)
+	if err != nil || refund == nil || refund.Output.Amount != 100 || refund.Output.TokenID != tokenX || refund.Output.Address != kp.Address() {
+		t.Fatalf("Expected 100 X refunded at output 1, got %+v (%v)", refund, err)
+	}
+	failure, _ := store.GetTxFailure(txID)
+	if failure == nil || failure.Height != 7 || !strings.Contains(failure.Reason, "minimum 500") {
+		t.Errorf("Expected the failure to be recorded, got %+v", failure)
+	}
+	if pool, _ := pools.GetPool(poolID); pool.ReserveA != 1000 || pool.ReserveB != 2000 {
+		t.Errorf("Failed swap should leave reserves alone, got %d/%d", pool.ReserveA, pool.ReserveB)
+	}
+
+	tx, txID = swap(100)
+	if err := store.ProcessTokenTransaction(tx, NewTokenRegistry(), pools, 8); err != nil {
+		t.Fatalf("Swap failed: %v", err)
+	}
+	if failure, _ := store.GetTxFailure(txID); failure != nil {
+		t.Errorf("Successful swap should have no failure, got %+v", failure)
+	}
+	if out, _ := store.GetUTXO(txID, 1); out == nil || out.Output.Amount != 181 {
+		t.Errorf("Expected 181 SHADOW out, got %+v", out)
+	}
+}
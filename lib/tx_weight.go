@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// Transactions cost validators very different amounts of work: a send looks up and
+// spends its inputs, a swap also reprices a pool, a mint creates a registry entry. A
+// transaction's weight is its size, plus a cost for each input and output, plus a fixed
+// cost for the state its type changes. Blocks are limited by total weight as well as by
+// size, proposers rank transactions by fee per weight, and a network can require a
+// minimum fee per 1000 weight units, which the mempool checks on admission and
+// validators check for every transaction in a block.
+
+const (
+	WeightPerInput  = 1000 // Looking up, checking and spending a UTXO
+	WeightPerOutput = 250  // Writing a UTXO and its address indices
+
+	DefaultMaxBlockWeight = 8 * 1024 * 1024 // A full block of sends is roughly 4.5M
+)
+
+// TxTypeWeight returns the fixed weight of a transaction type, on top of its size,
+// inputs and outputs
+func TxTypeWeight(txType TxType) int {
+	switch txType {
+	case TxTypeMintToken, TxTypeCreatePool:
+		return 50000 // New registry entries
+	case TxTypeAddLiquidity, TxTypeRemoveLiquidity, TxTypeSwap, TxTypeAcceptOffer, TxTypeMatchOffers,
+		TxTypeCollateralIssue, TxTypeCollateralMelt, TxTypeLiquidate:
+		return 20000 // Pool or offer state read, priced and rewritten
+	case TxTypeMelt, TxTypeRegisterValidator, TxTypeDelegateMint:
+		return 10000
+	case TxTypeOffer, TxTypeCancelOffer, TxTypeBurn, TxTypeHTLCLock, TxTypeHTLCClaim, TxTypeHTLCRefund:
+		return 5000
+	default:
+		return 0 // Sends and the coinbase cost only what they spend and create
+	}
+}
+
+// TxWeight returns the weight of a transaction as counted against the block weight limit
+func TxWeight(tx *Transaction) int {
+	return TxSize(tx) + len(tx.Inputs)*WeightPerInput + len(tx.Outputs)*WeightPerOutput + TxTypeWeight(tx.TxType)
+}
+
+// MinFee returns the smallest fee a transaction of the given weight must pay, rounded up
+func (l BlockLimits) MinFee(weight int) uint64 {
+	if l.MinFeePerKWeight == 0 || weight <= 0 {
+		return 0
+	}
+	return (uint64(weight)*l.MinFeePerKWeight + 999) / 1000
+}
+
+// CheckTxWeight rejects a transaction too heavy to fit in a block
+func CheckTxWeight(tx *Transaction) error {
+	maxWeight := ActiveGenesis().BlockSizeLimits().MaxBlockWeight
+	if weight := TxWeight(tx); weight > maxWeight {
+		return fmt.Errorf("transaction weighs %d, block limit is %d", weight, maxWeight)
+	}
+	return nil
+}
+
+// CheckWeightFee rejects a transaction paying less than the network's minimum fee for
+// its weight. SHADOW inputs are resolved with lookup, and a token fee counts at its pool
+// price. Node-made transactions pay no fee.
+func CheckWeightFee(tx *Transaction, lookup func(txID string, index uint32) *TxOutput, poolRegistry *PoolRegistry) error {
+	if tx.TxType == TxTypeCoinbase || tx.TxType == TxTypeMatchOffers {
+		return nil
+	}
+	weight := TxWeight(tx)
+	minFee := ActiveGenesis().BlockSizeLimits().MinFee(weight)
+	if minFee == 0 {
+		return nil
+	}
+
+	fee := paidFee(tx, lookup)
+	if tx.TokenFee != nil && validateTokenFee(tx) == nil && tokenSurplus(tx, lookup, tx.TokenFee.TokenID) >= tx.TokenFee.Amount {
+		if shadow, _, err := QuoteTokenFee(tx.TokenFee, poolRegistry); err == nil {
+			fee += shadow
+		}
+	}
+	if fee < minFee {
+		return fmt.Errorf("fee %d below network minimum %d for weight %d", fee, minFee, weight)
+	}
+	return nil
+}
+
+// ValidateBlockWeight rejects a block over the network's weight limit or holding a
+// transaction that pays less than the minimum fee for its weight. Blocks below the
+// rule's activation height are only held to the size limits; the mempool applies the
+// weight rules to new transactions either way.
+func (bc *Blockchain) ValidateBlockWeight(block *Block, mempool *Mempool) error {
+	if block.Index < ActiveGenesis().RuleActivationHeights().BlockWeight {
+		return nil
+	}
+	maxWeight := ActiveGenesis().BlockSizeLimits().MaxBlockWeight
+	total := 0
+	for _, tx := range bc.blockTxs(block, mempool) {
+		total += TxWeight(tx)
+	}
+	if total > maxWeight {
+		return fmt.Errorf("block weighs %d, limit is %d", total, maxWeight)
+	}
+
+	return bc.checkBlockSpends(block, mempool, func(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+		return CheckWeightFee(tx, lookup, bc.poolRegistry)
+	})
+}
+
+// checkWeightFee rejects a transaction below the network's minimum fee for its weight,
+// resolving inputs from the chain and pending transactions
+func (mp *Mempool) checkWeightFee(tx *Transaction) error {
+	mp.txLock.RLock()
+	utxoStore, poolRegistry := mp.utxoStore, mp.poolRegistry
+	mp.txLock.RUnlock()
+
+	if utxoStore == nil {
+		return nil
+	}
+	return CheckWeightFee(tx, mp.pendingLookup(utxoStore), poolRegistry)
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockWeightLimits(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	for i := uint32(0); i < 2; i++ {
+		if err := store.AddUTXO(&UTXO{TxID: "weight-test-funding", OutputIndex: i, Output: CreateShadowOutput(kp.Address(), 1_000_000)}); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+	}
+	send := func(index uint32, fee uint64) *Transaction {
+		tx := NewTxBuilder(TxTypeSend).AddInput("weight-test-funding", index).AddOutput(kp.Address(), 1_000_000-fee, "").Build()
+		tx.Sign(kp)
+		return tx
+	}
+
+	// Past their sizes, a swap weighs its type's cost more than a send of the same shape
+	swap := NewTxBuilder(TxTypeSwap).AddInput("weight-test-funding", 0).AddOutput(kp.Address(), 1, "").Build()
+	shape := send(0, 999_999)
+	if TxWeight(swap)-TxSize(swap) != TxWeight(shape)-TxSize(shape)+TxTypeWeight(TxTypeSwap) {
+		t.Errorf("Expected the swap to weigh %d over its size, got %d", TxWeight(shape)-TxSize(shape)+TxTypeWeight(TxTypeSwap), TxWeight(swap)-TxSize(swap))
+	}
+
+	// One base unit per weight unit
+	genesis := DefaultChainGenesis()
+	genesis.BlockLimits = &BlockLimits{MinFeePerKWeight: 1000}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	cheap := send(0, 10)
+	minFee := uint64(TxWeight(cheap))
+	paying := send(1, minFee)
+	lookup := utxoLookup(store)
+	if err := CheckWeightFee(cheap, lookup, nil); err == nil || !strings.Contains(err.Error(), "network minimum") {
+		t.Errorf("Expected a fee under the weight minimum to be rejected, got %v", err)
+	}
+	if err := CheckWeightFee(paying, lookup, nil); err != nil {
+		t.Errorf("Expected a fee at the weight minimum to pass, got %v", err)
+	}
+
+	mempool := &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), utxoStore: store}
+	if err := mempool.checkWeightFee(cheap); err == nil {
+		t.Error("Expected the mempool to refuse a fee under the weight minimum")
+	}
+
+	height := bc.GetHeight()
+	block := func(txs ...*Transaction) *Block {
+		ids := []string{}
+		for _, tx := range txs {
+			id, _ := tx.ID()
+			mempool.entries[id] = &MempoolEntry{Tx: tx}
+			ids = append(ids, id)
+		}
+		return bc.ProposeBlock(ids, "weight-test-proposer", nil)
+	}
+	if err := bc.AddBlock(block(cheap), mempool); !errors.Is(err, ErrBlockInvalid) {
+		t.Fatalf("Expected a block with an underpaying transaction to be rejected, got %v", err)
+	}
+
+	// Both fit on their own, but not together
+	genesis.BlockLimits = &BlockLimits{MaxBlockWeight: TxWeight(paying) + 10}
+	if err := bc.ValidateBlockWeight(block(paying, send(0, 10)), mempool); err == nil || !strings.Contains(err.Error(), "block weighs") {
+		t.Errorf("Expected a block over max_block_weight to be rejected, got %v", err)
+	}
+	genesis.Activations = &RuleActivations{BlockWeight: height + 100}
+	if err := bc.ValidateBlockWeight(block(paying, send(0, 10)), mempool); err != nil {
+		t.Errorf("Expected the weight limit not to apply before its activation height, got %v", err)
+	}
+	genesis.Activations = nil
+	if err := bc.AddBlock(block(paying), mempool); err != nil {
+		t.Fatalf("Failed to add block within the weight limit: %v", err)
+	}
+	if bc.GetHeight() != height+1 {
+		t.Fatalf("Expected the block to be added, height %d", bc.GetHeight())
+	}
+}
@@ -44,8 +44,46 @@ const (
 
 	// TxTypeSwap swaps tokens through a liquidity pool
 	TxTypeSwap TxType = 11
+
+	// TxTypeBurn provably destroys tokens without unlocking anything
+	TxTypeBurn TxType = 12
+
+	// TxTypeMatchOffers settles two crossing auto-match offers (generated by the block proposer)
+	TxTypeMatchOffers TxType = 13
+
+	// TxTypeHTLCLock locks tokens in a hashed timelock contract
+	TxTypeHTLCLock TxType = 14
+
+	// TxTypeHTLCClaim claims an HTLC by revealing the preimage of its hash
+	TxTypeHTLCClaim TxType = 15
+
+	// TxTypeHTLCRefund returns an HTLC to its refund address after the timeout
+	TxTypeHTLCRefund TxType = 16
+
+	// TxTypeDelegateMint grants or revokes another address's right to mint a token
+	TxTypeDelegateMint TxType = 17
+
+	// TxTypeCollateralIssue locks SHADOW in a collateral position and issues a
+	// collateralized token against it
+	TxTypeCollateralIssue TxType = 18
+
+	// TxTypeCollateralMelt melts a collateralized token back into its position and
+	// releases SHADOW to the owner
+	TxTypeCollateralMelt TxType = 19
+
+	// TxTypeLiquidate repays an undercollateralized position and takes its collateral
+	TxTypeLiquidate TxType = 20
+
+	// MaxTxType is the highest transaction type
+	MaxTxType = TxTypeLiquidate
 )
 
+// NodeOnly reports whether transactions of the type are created by block proposers, not
+// clients
+func (tt TxType) NodeOnly() bool {
+	return tt == TxTypeCoinbase || tt == TxTypeMatchOffers
+}
+
 // String returns the string representation of a transaction type
 func (tt TxType) String() string {
 	switch tt {
@@ -73,6 +111,24 @@ func (tt TxType) String() string {
 		return "remove_liquidity"
 	case TxTypeSwap:
 		return "swap"
+	case TxTypeBurn:
+		return "burn"
+	case TxTypeMatchOffers:
+		return "match_offers"
+	case TxTypeHTLCLock:
+		return "htlc_lock"
+	case TxTypeHTLCClaim:
+		return "htlc_claim"
+	case TxTypeHTLCRefund:
+		return "htlc_refund"
+	case TxTypeDelegateMint:
+		return "delegate_mint"
+	case TxTypeCollateralIssue:
+		return "collateral_issue"
+	case TxTypeCollateralMelt:
+		return "collateral_melt"
+	case TxTypeLiquidate:
+		return "liquidate"
 	default:
 		return fmt.Sprintf("unknown(%d)", int(tt))
 	}
@@ -87,6 +143,14 @@ type TxInput struct {
 	// Spending authorization
 	ScriptSig []byte `json:"script_sig"` // Script signature (for future smart contracts)
 	Sequence  uint32 `json:"sequence"`   // Sequence number (for time locks, etc.)
+
+	// Per-input signature (see SignInput); empty when the transaction is signed as a whole
+	SigHash   SigHashType `json:"sighash,omitempty"`    // What the signature commits to
+	PublicKey []byte      `json:"public_key,omitempty"` // Key owning the spent output
+	Signature []byte      `json:"signature,omitempty"`
+
+	// Satisfies the condition of a conditioned output (see SpendWitness)
+	Witness *SpendWitness `json:"witness,omitempty"`
 }
 
 // TxOutput represents an output of a transaction (creating a UTXO)
@@ -99,6 +163,9 @@ type TxOutput struct {
 	TokenID   string `json:"token_id"`   // Token identifier (genesis hash for SHADOW, TX ID for custom tokens)
 	TokenType string `json:"token_type"` // Token type descriptor
 
+	// Issue of the token's ticker (see TokenInfo.MintVersion); must match the registry
+	MintVersion uint8 `json:"mint_version,omitempty"`
+
 	// Token staking (for custom tokens only)
 	LockedShadow uint64 `json:"locked_shadow,omitempty"` // Proportional SHADOW locked to this token UTXO
 
@@ -107,6 +174,15 @@ type TxOutput struct {
 
 	// Additional metadata
 	Data []byte `json:"data,omitempty"` // Optional data payload
+
+	// Linear unlock schedule; locked funds must be carried forward when spent
+	Vesting *VestingSchedule `json:"vesting,omitempty"`
+
+	// Address type the output was sent to (empty = wallet, so older outputs hash unchanged)
+	AddressType AddressType `json:"address_type,omitempty"`
+
+	// Spend condition descriptor; Address is then the condition's address (see Condition)
+	Condition string `json:"condition,omitempty"`
 }
 
 // UTXO represents an Unspent Transaction Output
@@ -164,7 +240,8 @@ func CreateShadowOutput(address Address, amount uint64) *TxOutput {
 	}
 }
 
-// CreateTokenOutput creates a custom token output
+// CreateTokenOutput creates a custom token output of mint version 0; use
+// TokenRegistry.NewTokenOutput for a token whose ticker may have been reissued
 func CreateTokenOutput(address Address, amount uint64, tokenID, tokenType string, data []byte) *TxOutput {
 	return &TxOutput{
 		Amount:       amount,
@@ -176,6 +253,38 @@ func CreateTokenOutput(address Address, amount uint64, tokenID, tokenType string
 	}
 }
 
+// CreateBurnOutput creates an unspendable output that destroys amount of tokenID
+func CreateBurnOutput(amount uint64, tokenID string) *TxOutput {
+	if tokenID == "" || tokenID == "SHADOW" {
+		tokenID = GetGenesisToken().TokenID
+	}
+	return &TxOutput{
+		Amount:       amount,
+		Address:      BurnAddress,
+		TokenID:      tokenID,
+		TokenType:    BurnTokenType,
+		ScriptPubKey: []byte{OpBurn},
+	}
+}
+
+// Type returns the address type the output was sent to
+func (output *TxOutput) Type() AddressType {
+	if output.AddressType == 0 {
+		return AddressTypeWallet
+	}
+	return output.AddressType
+}
+
+// AddressString returns the output's address with its address type, in the display format
+func (output *TxOutput) AddressString() string {
+	return output.Address.DisplayWithType(output.Type())
+}
+
+// IsBurn returns true for burn outputs, which are never added to the UTXO set
+func (output *TxOutput) IsBurn() bool {
+	return output.TokenType == BurnTokenType
+}
+
 // CreateP2PKHScript creates a Pay-to-PubKey-Hash script
 func CreateP2PKHScript(address Address) []byte {
 	// Simple script: OP_DUP OP_HASH160 <address> OP_EQUALVERIFY OP_CHECKSIG
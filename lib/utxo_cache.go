@@ -0,0 +1,202 @@
+package lib
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// DefaultUTXOCacheSize is the default maximum number of cached UTXOs
+const DefaultUTXOCacheSize = 100000
+
+// utxoCacheEntryOverhead approximates the fixed memory cost of one cached UTXO
+// (list element, map slot, entry, UTXO and TxOutput structs) on top of its variable-length fields
+const utxoCacheEntryOverhead = int64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof(utxoCacheEntry{})+
+	unsafe.Sizeof(UTXO{})+unsafe.Sizeof(TxOutput{})) + 64
+
+// UTXOCache is a size-bounded LRU cache of UTXOs keyed by "utxo:{txid}:{index}".
+// It is bounded by entry count and, optionally, by an estimated memory budget in bytes.
+type UTXOCache struct {
+	mu       sync.Mutex
+	capacity int
+	maxBytes int64                    // Memory budget, 0 = bounded by entry count only
+	bytes    int64                    // Estimated memory held by cached entries
+	order    *list.List               // Front = most recently used
+	items    map[string]*list.Element // key -> element holding *utxoCacheEntry
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// utxoCacheEntry is the value stored in the LRU list
+type utxoCacheEntry struct {
+	key  string
+	utxo *UTXO
+	size int64 // Estimated memory cost, see utxoCacheEntrySize
+}
+
+// utxoCacheEntrySize estimates the memory held by a cached UTXO
+func utxoCacheEntrySize(key string, utxo *UTXO) int64 {
+	size := utxoCacheEntryOverhead + int64(len(key))
+	if utxo == nil {
+		return size
+	}
+	size += int64(len(utxo.TxID))
+	if out := utxo.Output; out != nil {
+		size += int64(len(out.TokenID) + len(out.TokenType) + len(out.ScriptPubKey) + len(out.Data) +
+			len(out.Condition))
+		if out.Vesting != nil {
+			size += int64(unsafe.Sizeof(*out.Vesting))
+		}
+	}
+	return size
+}
+
+// UTXOCacheStats reports cache effectiveness
+type UTXOCacheStats struct {
+	Entries   int    `json:"entries"`
+	Capacity  int    `json:"capacity"`
+	Bytes     int64  `json:"bytes"`     // Estimated memory held by cached entries
+	MaxBytes  int64  `json:"max_bytes"` // Memory budget, 0 = unlimited
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// NewUTXOCache creates an LRU cache holding at most capacity entries (<= 0 uses the default)
+func NewUTXOCache(capacity int) *UTXOCache {
+	if capacity <= 0 {
+		capacity = DefaultUTXOCacheSize
+	}
+	return &UTXOCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Load returns a cached UTXO and marks it recently used
+func (c *UTXOCache) Load(key string) (*UTXO, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	c.order.MoveToFront(elem)
+	return elem.Value.(*utxoCacheEntry).utxo, true
+}
+
+// Store adds or replaces a cached UTXO, evicting the least recently used entries if full
+func (c *UTXOCache) Store(key string, utxo *UTXO) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := utxoCacheEntrySize(key, utxo)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*utxoCacheEntry)
+		c.bytes += size - entry.size
+		entry.utxo = utxo
+		entry.size = size
+		c.order.MoveToFront(elem)
+	} else {
+		c.items[key] = c.order.PushFront(&utxoCacheEntry{key: key, utxo: utxo, size: size})
+		c.bytes += size
+	}
+
+	c.evictOverBudgetLocked()
+}
+
+// Delete removes a key from the cache
+func (c *UTXOCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		c.bytes -= elem.Value.(*utxoCacheEntry).size
+	}
+}
+
+// Clear empties the cache (counters are kept)
+func (c *UTXOCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Resize changes the capacity, evicting entries if the cache is now over budget
+func (c *UTXOCache) Resize(capacity int) {
+	if capacity <= 0 {
+		capacity = DefaultUTXOCacheSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evictOverBudgetLocked()
+}
+
+// SetMaxBytes sets the cache's memory budget, evicting entries if it is now over budget (<= 0 = unlimited)
+func (c *UTXOCache) SetMaxBytes(maxBytes int64) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.evictOverBudgetLocked()
+}
+
+// Stats returns the current cache statistics
+func (c *UTXOCache) Stats() UTXOCacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	capacity := c.capacity
+	bytes, maxBytes := c.bytes, c.maxBytes
+	c.mu.Unlock()
+
+	return UTXOCacheStats{
+		Entries:   entries,
+		Capacity:  capacity,
+		Bytes:     bytes,
+		MaxBytes:  maxBytes,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// evictOverBudgetLocked evicts least recently used entries until the cache is within
+// both its entry capacity and its memory budget (caller holds mu)
+func (c *UTXOCache) evictOverBudgetLocked() {
+	for c.order.Len() > c.capacity || (c.maxBytes > 0 && c.bytes > c.maxBytes && c.order.Len() > 0) {
+		c.evictOldestLocked()
+	}
+}
+
+// evictOldestLocked drops the least recently used entry (caller holds mu)
+func (c *UTXOCache) evictOldestLocked() {
+	oldest := c.order.Back()
+	if oldest == nil {
+		return
+	}
+	entry := oldest.Value.(*utxoCacheEntry)
+	c.order.Remove(oldest)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+	c.evictions.Add(1)
+}
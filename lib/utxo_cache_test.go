@@ -0,0 +1,74 @@
+package lib
+
+import "testing"
+
+func TestUTXOCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewUTXOCache(2)
+
+	cache.Store("a", &UTXO{TxID: "a"})
+	cache.Store("b", &UTXO{TxID: "b"})
+
+	// Touch "a" so "b" becomes the eviction candidate
+	if _, ok := cache.Load("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	cache.Store("c", &UTXO{TxID: "c"})
+
+	if _, ok := cache.Load("b"); ok {
+		t.Fatal("Expected b to be evicted")
+	}
+	if _, ok := cache.Load("a"); !ok {
+		t.Fatal("Expected a to survive eviction")
+	}
+	if _, ok := cache.Load("c"); !ok {
+		t.Fatal("Expected c to be cached")
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if stats.Hits != 3 || stats.Misses != 1 {
+		t.Fatalf("Unexpected hit/miss counts: %+v", stats)
+	}
+
+	cache.Resize(1)
+	if cache.Stats().Entries != 1 {
+		t.Fatal("Resize should evict down to the new capacity")
+	}
+}
+
+func TestUTXOCacheEvictsOverMemoryBudget(t *testing.T) {
+	cache := NewUTXOCache(100)
+
+	utxo := func(id string) *UTXO {
+		return &UTXO{TxID: id, Output: &TxOutput{TokenID: "SHADOW", Data: make([]byte, 256)}}
+	}
+	entrySize := utxoCacheEntrySize("a", utxo("a"))
+	cache.SetMaxBytes(2 * entrySize)
+
+	cache.Store("a", utxo("a"))
+	cache.Store("b", utxo("b"))
+	cache.Store("c", utxo("c"))
+
+	if _, ok := cache.Load("a"); ok {
+		t.Fatal("Expected a to be evicted once over the memory budget")
+	}
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Bytes != 2*entrySize || stats.MaxBytes != 2*entrySize {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+
+	cache.Delete("b")
+	if got := cache.Stats().Bytes; got != entrySize {
+		t.Fatalf("Expected %d bytes after delete, got %d", entrySize, got)
+	}
+
+	cache.SetMaxBytes(0)
+	for _, key := range []string{"d", "e", "f"} {
+		cache.Store(key, utxo(key))
+	}
+	if cache.Stats().Entries != 4 {
+		t.Fatal("Removing the memory budget should bound the cache by entry count only")
+	}
+}
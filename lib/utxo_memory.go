@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// With the memory set enabled, every unspent output is held in a map. The database
+// stays the source of truth: writes go through to both, so lookups of unspent outputs
+// during validation and block application never touch the database. A miss means the
+// output is spent or unknown, which is only read from disk when asked for.
+
+// UTXOMemorySet holds the full unspent set keyed by "utxo:{txid}:{index}"
+type UTXOMemorySet struct {
+	mu    sync.RWMutex
+	utxos map[string]*UTXO
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// UTXOMemoryStats reports the memory set's size and effectiveness
+type UTXOMemoryStats struct {
+	Enabled bool   `json:"enabled"`
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// get returns an unspent output without counting the lookup
+func (m *UTXOMemorySet) get(key string) (*UTXO, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	utxo, ok := m.utxos[key]
+	return utxo, ok
+}
+
+// Load returns an unspent output
+func (m *UTXOMemorySet) Load(key string) (*UTXO, bool) {
+	utxo, ok := m.get(key)
+	if ok {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+	return utxo, ok
+}
+
+// Put records an output written to the store: unspent outputs are kept, spent ones dropped
+func (m *UTXOMemorySet) Put(key string, utxo *UTXO) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if utxo.IsSpent {
+		delete(m.utxos, key)
+	} else {
+		m.utxos[key] = utxo
+	}
+}
+
+// Delete drops an output that has been spent
+func (m *UTXOMemorySet) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.utxos, key)
+}
+
+// replace swaps in a freshly loaded set (counters are kept)
+func (m *UTXOMemorySet) replace(utxos map[string]*UTXO) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.utxos = utxos
+}
+
+// Stats returns the current memory set statistics
+func (m *UTXOMemorySet) Stats() UTXOMemoryStats {
+	m.mu.RLock()
+	entries := len(m.utxos)
+	m.mu.RUnlock()
+
+	return UTXOMemoryStats{
+		Enabled: true,
+		Entries: entries,
+		Hits:    m.hits.Load(),
+		Misses:  m.misses.Load(),
+	}
+}
+
+// EnableMemorySet loads every unspent output into memory and keeps it there, written
+// through on every change. Returns the number of outputs loaded.
+func (store *UTXOStore) EnableMemorySet() (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	started := time.Now()
+	utxos, err := store.loadUnspent()
+	if err != nil {
+		return 0, err
+	}
+	if store.memory == nil {
+		store.memory = &UTXOMemorySet{}
+	}
+	store.memory.replace(utxos)
+
+	fmt.Printf("[UTXO] 🧠 Loaded %d unspent outputs into memory in %s\n", len(utxos), time.Since(started).Round(time.Millisecond))
+	return len(utxos), nil
+}
+
+// MemorySetStats returns the memory set statistics (Enabled is false when it is off)
+func (store *UTXOStore) MemorySetStats() UTXOMemoryStats {
+	store.mutex.RLock()
+	memory := store.memory
+	store.mutex.RUnlock()
+
+	if memory == nil {
+		return UTXOMemoryStats{}
+	}
+	return memory.Stats()
+}
+
+// reloadMemorySet reloads the memory set from the database, dropping changes that were
+// never committed
+func (store *UTXOStore) reloadMemorySet() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.memory == nil {
+		return
+	}
+	utxos, err := store.loadUnspent()
+	if err != nil {
+		fmt.Printf("[UTXO] ⚠️  Failed to reload memory set: %v\n", err)
+		store.memory = nil // Fall back to the database rather than serve stale outputs
+		return
+	}
+	store.memory.replace(utxos)
+}
+
+// loadUnspent reads every unspent output from the database (caller holds store.mutex)
+func (store *UTXOStore) loadUnspent() (map[string]*UTXO, error) {
+	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	utxos := make(map[string]*UTXO)
+	for ; iterator.Valid(); iterator.Next() {
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal UTXO %s: %w", iterator.Key(), err)
+		}
+		if !utxo.IsSpent {
+			utxos[string(iterator.Key())] = &utxo
+		}
+	}
+	return utxos, nil
+}
+
+// cachedUTXO looks a UTXO up in memory: the memory set first, then the LRU cache
+func (store *UTXOStore) cachedUTXO(key string) (*UTXO, bool) {
+	if store.memory != nil {
+		if utxo, ok := store.memory.Load(key); ok {
+			return utxo, true
+		}
+	}
+	return store.cache.Load(key)
+}
+
+// storedUnspent returns the unspent output stored at key, if any (caller holds
+// store.mutex). The memory set holds every unspent output, so it answers without a read.
+func (store *UTXOStore) storedUnspent(key string) *UTXO {
+	if store.memory != nil {
+		utxo, _ := store.memory.get(key)
+		return utxo
+	}
+
+	data, err := store.db.Get([]byte(key))
+	if err != nil || data == nil {
+		return nil
+	}
+	var utxo UTXO
+	if err := json.Unmarshal(data, &utxo); err != nil || utxo.IsSpent {
+		return nil
+	}
+	return &utxo
+}
@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestUTXOMemorySet(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	shadowID := GetGenesisToken().TokenID
+	add := func(txID string, amount uint64) {
+		if err := store.AddUTXO(&UTXO{TxID: txID, Output: CreateShadowOutput(kp.Address(), amount)}); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	add("a1", 100)
+	add("a2", 200)
+	if err := store.SpendUTXO("a2", 0, 1); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+
+	if stats := store.MemorySetStats(); stats.Enabled {
+		t.Fatalf("Expected the memory set to be off by default, got %+v", stats)
+	}
+	if loaded, err := store.EnableMemorySet(); err != nil || loaded != 1 {
+		t.Fatalf("Expected 1 unspent output loaded, got %d (%v)", loaded, err)
+	}
+
+	// Writes go through to the set; re-adding an output replaces it in the balance index
+	add("b1", 50)
+	add("b1", 50)
+	if err := store.SpendUTXO("a1", 0, 2); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	if stats := store.MemorySetStats(); stats.Entries != 1 {
+		t.Fatalf("Expected only b1 in memory, got %+v", stats)
+	}
+	if balance, _ := store.GetBalance(kp.Address()); balance[shadowID] != 50 {
+		t.Errorf("Expected a balance of 50, got %d", balance[shadowID])
+	}
+
+	// Spent outputs are still read from disk
+	if utxo, _ := store.GetUTXO("a1", 0); utxo == nil || !utxo.IsSpent {
+		t.Errorf("Expected a1 to read back as spent, got %+v", utxo)
+	}
+
+	before := store.MemorySetStats()
+	spend := NewTxBuilder(TxTypeSend).AddInput("b1", 0).AddOutput(kp.Address(), 40, "SHADOW").Build()
+	if err := store.ValidateTransaction(spend); err != nil {
+		t.Errorf("Expected a spend of b1 to validate, got %v", err)
+	}
+	if after := store.MemorySetStats(); after.Hits != before.Hits+1 || after.Misses != before.Misses {
+		t.Errorf("Expected validation to be served from memory, got %+v then %+v", before, after)
+	}
+
+	// Discarded writes leave the set
+	if err := store.BeginBatch(); err != nil {
+		t.Fatalf("Failed to begin batch: %v", err)
+	}
+	add("c1", 75)
+	store.DiscardBatch()
+	if utxo, _ := store.GetUTXO("c1", 0); utxo != nil {
+		t.Errorf("Expected the discarded output to be gone, got %+v", utxo)
+	}
+	if stats := store.MemorySetStats(); stats.Entries != 1 {
+		t.Errorf("Expected the set to be reloaded from disk, got %+v", stats)
+	}
+}
+
+func BenchmarkValidateTransaction(b *testing.B) {
+	for _, inMemory := range []bool{false, true} {
+		b.Run(fmt.Sprintf("in_memory=%v", inMemory), func(b *testing.B) {
+			store, err := NewUTXOStore(filepath.Join(b.TempDir(), "utxo.db"))
+			if err != nil {
+				b.Fatalf("Failed to open UTXO store: %v", err)
+			}
+			defer store.Close()
+			store.SetCacheSize(1) // Leave the LRU cache out of the comparison
+
+			kp, _ := GenerateKeyPair()
+			builder := NewTxBuilder(TxTypeSend)
+			for i := 0; i < 100; i++ {
+				txID := fmt.Sprintf("bench%d", i)
+				if err := store.AddUTXO(&UTXO{TxID: txID, Output: CreateShadowOutput(kp.Address(), 10)}); err != nil {
+					b.Fatalf("Failed to add UTXO: %v", err)
+				}
+				builder.AddInput(txID, 0)
+			}
+			tx := builder.AddOutput(kp.Address(), 1000, "SHADOW").Build()
+			if inMemory {
+				store.EnableMemorySet()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.ValidateTransaction(tx); err != nil {
+					b.Fatalf("Validation failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Each block's UTXO changes are committed in one database transaction, and the height
+// of the last applied block is written with them. A snapshot is a read transaction, so
+// everything read through it - including that height - is the state after one block,
+// however many blocks are applied while it is open.
+
+const AppliedHeightKey = "utxometa:height" // Height of the last block applied to the store
+
+// kvReader reads committed data: the database itself or a snapshot of it
+type kvReader interface {
+	Get(key []byte) ([]byte, error)
+	Iterator(start, end []byte) (Iterator, error)
+}
+
+// setAppliedHeight records the last block applied to the store and the state hash after it
+func (store *UTXOStore) setAppliedHeight(height uint64) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.db.Set([]byte(AppliedHeightKey), []byte(strconv.FormatUint(height, 10))); err != nil {
+		return fmt.Errorf("failed to store applied height: %w", err)
+	}
+	return store.recordStateHashLocked(height)
+}
+
+// UTXOSnapshot is a consistent view of the UTXO store as of one block
+type UTXOSnapshot struct {
+	snap   *BoltSnapshot
+	Height uint64 // Last block applied to the view
+	Known  bool   // False if the store predates height tracking and no block has been applied since
+}
+
+// Snapshot opens a consistent view of the store. Must be closed.
+func (store *UTXOStore) Snapshot() (*UTXOSnapshot, error) {
+	snap, err := store.db.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+
+	view := &UTXOSnapshot{snap: snap}
+	data, err := snap.Get([]byte(AppliedHeightKey))
+	if err == nil && data != nil {
+		if view.Height, err = strconv.ParseUint(string(data), 10, 64); err == nil {
+			view.Known = true
+		}
+	}
+	return view, nil
+}
+
+// Close releases the snapshot
+func (view *UTXOSnapshot) Close() error {
+	return view.snap.Close()
+}
+
+// GetUTXOsByAddress returns the address's unspent outputs as of the snapshot
+func (view *UTXOSnapshot) GetUTXOsByAddress(address Address) ([]*UTXO, error) {
+	prefix := fmt.Sprintf("%s%s:", AddressPrefix, address.String())
+	iterator, err := view.snap.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iterator.Close()
+
+	var utxos []*UTXO
+	for ; iterator.Valid(); iterator.Next() {
+		outpoint := string(iterator.Key()[len(prefix):])
+		data, err := view.snap.Get([]byte(UTXOPrefix + outpoint))
+		if err != nil || data == nil {
+			continue
+		}
+		var utxo UTXO
+		if err := json.Unmarshal(data, &utxo); err != nil || utxo.IsSpent {
+			continue
+		}
+		utxos = append(utxos, &utxo)
+	}
+	return utxos, nil
+}
+
+// PruneHorizon returns the height below which spent UTXOs had been pruned (0 = none)
+func (view *UTXOSnapshot) PruneHorizon() uint64 {
+	data, err := view.snap.Get([]byte(PruneHorizonKey))
+	if err != nil || data == nil {
+		return 0
+	}
+	horizon, _ := strconv.ParseUint(string(data), 10, 64)
+	return horizon
+}
+
+// GetUTXOsAtHeight returns the outputs the address held as of a block at or below the
+// snapshot's height
+func (view *UTXOSnapshot) GetUTXOsAtHeight(address Address, height uint64) ([]*UTXO, error) {
+	if horizon := view.PruneHorizon(); height < horizon {
+		return nil, fmt.Errorf("history below height %d has been pruned", horizon)
+	}
+	return utxosAtHeight(view.snap, address, height)
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUTXOSnapshot(t *testing.T) {
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	store := bc.GetUTXOStore()
+
+	kp, _ := GenerateKeyPair()
+	coinbase := newCoinbase(kp.Address(), 5000)
+	coinbaseID, _ := coinbase.ID()
+	if err := bc.AddBlock(bc.ProposeBlock([]string{coinbaseID}, "snapshot-test-proposer", coinbase), nil); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	if !snapshot.Known || snapshot.Height != 1 {
+		t.Fatalf("Expected a snapshot at height 1, got %d (known %v)", snapshot.Height, snapshot.Known)
+	}
+
+	// Writes after the snapshot are not seen by it. They run on their own goroutine as
+	// they would for a node: a write may wait for open snapshots to close.
+	written := make(chan error, 1)
+	go func() {
+		if err := store.SpendUTXO(coinbaseID, 0, 2); err != nil {
+			written <- err
+			return
+		}
+		written <- store.AddUTXO(&UTXO{TxID: coinbaseID, OutputIndex: 1, Output: CreateShadowOutput(kp.Address(), 7), BlockHeight: 2})
+	}()
+
+	utxos, err := snapshot.GetUTXOsByAddress(kp.Address())
+	if err != nil || len(utxos) != 1 || utxos[0].Output.Amount != 5000 {
+		t.Fatalf("Expected the snapshot to hold the 5000 output only, got %d UTXOs (%v)", len(utxos), err)
+	}
+	if utxos, _ := snapshot.GetUTXOsAtHeight(kp.Address(), 0); len(utxos) != 0 {
+		t.Errorf("Expected no outputs at height 0, got %d", len(utxos))
+	}
+	snapshot.Close()
+
+	if err := <-written; err != nil {
+		t.Fatalf("Failed to write UTXOs: %v", err)
+	}
+	if live, _ := store.GetUTXOsByAddress(kp.Address()); len(live) != 1 || live[0].Output.Amount != 7 {
+		t.Errorf("Expected the store to hold the 7 output only, got %d UTXOs", len(live))
+	}
+}
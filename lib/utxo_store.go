@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -11,7 +13,12 @@ import (
 type UTXOStore struct {
 	db    *BoltDBAdapter
 	mutex sync.RWMutex
-	cache sync.Map // In-memory cache for performance (thread-safe)
+	cache *UTXOCache // Size-bounded LRU cache for performance (thread-safe)
+
+	memory      *UTXOMemorySet // Full unspent set, written through (nil unless enabled)
+	memoryStale bool           // Memory set holds rolled back writes; reloaded when the batch ends
+
+	memoIndex bool // Index send memos for search
 }
 
 // Prefixes for different data types in the database
@@ -19,11 +26,15 @@ const (
 	UTXOPrefix       = "utxo:"    // utxo:{txid}:{index} -> UTXO
 	AddressPrefix    = "addr:"    // addr:{address}:{txid}:{index} -> ""
 	HeightPrefix     = "height:"  // height:{height}:{txid}:{index} -> ""
-	SpentPrefix      = "spent:"   // spent:{txid}:{index} -> ""
+	SpentPrefix      = "spent:"   // spent:{txid}:{index} -> spent height
+	SpentAtPrefix    = "spentat:" // spentat:{spent_height}:{txid}:{index} -> "" (for pruning)
 	TxPrefix         = "tx:"      // tx:{txid} -> Transaction
 	AddrTxPrefix     = "addrtx:"  // addrtx:{address}:{height}:{txid} -> ""
 	AddrTxIndexCount = "atxcnt:"  // atxcnt:{address} -> count
 	ValidatorPrefix  = "val:"     // val:{proposer_address_hex} -> wallet_address
+
+	// PruneHorizonKey holds the height below which spent UTXOs have been pruned
+	PruneHorizonKey = "prunemeta:before"
 )
 
 // NewUTXOStore creates a new UTXO store with the given database path
@@ -34,8 +45,8 @@ func NewUTXOStore(dbPath string) (*UTXOStore, error) {
 	}
 
 	return &UTXOStore{
-		db: db,
-		// cache is sync.Map, no initialization needed
+		db:    db,
+		cache: NewUTXOCache(DefaultUTXOCacheSize),
 	}, nil
 }
 
@@ -46,9 +57,9 @@ func (store *UTXOStore) GetUTXO(txID string, outputIndex uint32) (*UTXO, error)
 
 	key := fmt.Sprintf("%s%s:%d", UTXOPrefix, txID, outputIndex)
 
-	// Check cache first (sync.Map is thread-safe)
-	if cached, exists := store.cache.Load(key); exists {
-		return cached.(*UTXO), nil
+	// Check memory first (the memory set and UTXOCache are thread-safe)
+	if cached, exists := store.cachedUTXO(key); exists {
+		return cached, nil
 	}
 
 	// Check database
@@ -65,7 +76,7 @@ func (store *UTXOStore) GetUTXO(txID string, outputIndex uint32) (*UTXO, error)
 		return nil, fmt.Errorf("failed to unmarshal UTXO: %w", err)
 	}
 
-	// Cache the UTXO (sync.Map handles concurrency)
+	// Cache the UTXO (UTXOCache handles concurrency and eviction)
 	store.cache.Store(key, &utxo)
 
 	return &utxo, nil
@@ -78,6 +89,13 @@ func (store *UTXOStore) AddUTXO(utxo *UTXO) error {
 
 	key := fmt.Sprintf("%s%s:%d", UTXOPrefix, utxo.TxID, utxo.OutputIndex)
 
+	// Replace any unspent output already stored at this key in the balance index
+	if prev := store.storedUnspent(key); prev != nil {
+		if err := store.indexOutput(prev, false); err != nil {
+			return err
+		}
+	}
+
 	// Serialize UTXO
 	data, err := json.Marshal(utxo)
 	if err != nil {
@@ -107,24 +125,33 @@ func (store *UTXOStore) AddUTXO(utxo *UTXO) error {
 		return fmt.Errorf("failed to store height index: %w", err)
 	}
 
+	if !utxo.IsSpent {
+		if err := store.indexOutput(utxo, true); err != nil {
+			return err
+		}
+	}
+
 	// Cache the UTXO
+	if store.memory != nil {
+		store.memory.Put(key, utxo)
+	}
 	store.cache.Store(key, utxo)
 
 	return nil
 }
 
-// SpendUTXO marks a UTXO as spent
-func (store *UTXOStore) SpendUTXO(txID string, outputIndex uint32) error {
+// SpendUTXO marks a UTXO as spent at the given block height
+func (store *UTXOStore) SpendUTXO(txID string, outputIndex uint32, spentHeight uint64) error {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
 	// Get the UTXO first (without acquiring lock since we already have it)
 	key := fmt.Sprintf("%s%s:%d", UTXOPrefix, txID, outputIndex)
 
-	// Check cache first
+	// Check memory first
 	var utxo *UTXO
-	if cached, exists := store.cache.Load(key); exists {
-		utxo = cached.(*UTXO)
+	if cached, exists := store.cachedUTXO(key); exists {
+		utxo = cached
 	} else {
 		// Check database
 		data, err := store.db.Get([]byte(key))
@@ -162,16 +189,197 @@ func (store *UTXOStore) SpendUTXO(txID string, outputIndex uint32) error {
 
 	// Add to spent index
 	spentKey := fmt.Sprintf("%s%s:%d", SpentPrefix, txID, outputIndex)
-	if err := store.db.Set([]byte(spentKey), []byte("")); err != nil {
+	if err := store.db.Set([]byte(spentKey), []byte(fmt.Sprintf("%d", spentHeight))); err != nil {
 		return fmt.Errorf("failed to store spent index: %w", err)
 	}
 
+	// Add to spent-height index so old spent records can be pruned in order
+	if err := store.db.Set([]byte(spentAtKey(spentHeight, txID, outputIndex)), []byte("")); err != nil {
+		return fmt.Errorf("failed to store spent height index: %w", err)
+	}
+
+	if err := store.indexOutput(utxo, false); err != nil {
+		return err
+	}
+
 	// Invalidate cache - force re-read from DB next time to ensure fresh data
 	store.cache.Delete(key)
+	if store.memory != nil {
+		store.memory.Delete(key)
+	}
 
 	return nil
 }
 
+// spentAtKey builds the spent-height index key (zero-padded so keys sort by height)
+func spentAtKey(spentHeight uint64, txID string, outputIndex uint32) string {
+	return fmt.Sprintf("%s%020d:%s:%d", SpentAtPrefix, spentHeight, txID, outputIndex)
+}
+
+// PruneSpentUTXOs deletes spent UTXO records (and their index keys) that were spent
+// below the given height. Returns the number of UTXOs removed.
+func (store *UTXOStore) PruneSpentUTXOs(beforeHeight uint64) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Collect spent-height index keys first - bolt can't write while a read cursor is open
+	start := []byte(SpentAtPrefix)
+	end := []byte(fmt.Sprintf("%s%020d:", SpentAtPrefix, beforeHeight))
+	iterator, err := store.db.Iterator(start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+
+	var spentAtKeys []string
+	for ; iterator.Valid(); iterator.Next() {
+		spentAtKeys = append(spentAtKeys, string(iterator.Key()))
+	}
+	iterator.Close()
+
+	var keys [][]byte
+	pruned := 0
+	for _, indexKey := range spentAtKeys {
+		// Key format: spentat:{height(20 digits)}:{txid}:{index}
+		if len(indexKey) <= len(SpentAtPrefix)+21 {
+			continue // Skip malformed keys
+		}
+		rest := indexKey[len(SpentAtPrefix)+21:]
+		lastColon := strings.LastIndex(rest, ":")
+		if lastColon == -1 {
+			continue
+		}
+		txID := rest[:lastColon]
+		var outputIndex uint32
+		fmt.Sscanf(rest[lastColon+1:], "%d", &outputIndex)
+
+		utxoKey := fmt.Sprintf("%s%s:%d", UTXOPrefix, txID, outputIndex)
+		keys = append(keys, []byte(indexKey), []byte(fmt.Sprintf("%s%s:%d", SpentPrefix, txID, outputIndex)))
+
+		data, err := store.db.Get([]byte(utxoKey))
+		if err == nil && data != nil {
+			var utxo UTXO
+			if err := json.Unmarshal(data, &utxo); err == nil {
+				keys = append(keys,
+					[]byte(utxoKey),
+					[]byte(fmt.Sprintf("%s%s:%s:%d", AddressPrefix, utxo.Output.Address.String(), txID, outputIndex)),
+					[]byte(fmt.Sprintf("%s%d:%s:%d", HeightPrefix, utxo.BlockHeight, txID, outputIndex)))
+				pruned++
+			}
+		}
+		store.cache.Delete(utxoKey)
+	}
+
+	// Remember the horizon so historical balance queries below it can be refused
+	if horizon, _ := store.readCounter(PruneHorizonKey); beforeHeight > horizon {
+		if err := store.db.Set([]byte(PruneHorizonKey), []byte(fmt.Sprintf("%d", beforeHeight))); err != nil {
+			return 0, fmt.Errorf("failed to store prune horizon: %w", err)
+		}
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	if err := store.db.DeleteBatch(keys); err != nil {
+		return 0, fmt.Errorf("failed to delete pruned UTXOs: %w", err)
+	}
+
+	return pruned, nil
+}
+
+// CacheStats returns UTXO cache hit and miss counts
+func (store *UTXOStore) CacheStats() (uint64, uint64) {
+	stats := store.cache.Stats()
+	return stats.Hits, stats.Misses
+}
+
+// UTXOCacheStats returns detailed UTXO cache statistics (size, capacity, evictions)
+func (store *UTXOStore) UTXOCacheStats() UTXOCacheStats {
+	return store.cache.Stats()
+}
+
+// SetCacheSize sets the maximum number of UTXOs held in memory
+func (store *UTXOStore) SetCacheSize(entries int) {
+	store.cache.Resize(entries)
+	fmt.Printf("[UTXO] Cache limited to %d entries\n", store.cache.Stats().Capacity)
+}
+
+// SetCacheMemoryLimit sets the memory budget of the UTXO cache in bytes (0 = bounded by entry count only)
+func (store *UTXOStore) SetCacheMemoryLimit(maxBytes int64) {
+	store.cache.SetMaxBytes(maxBytes)
+	if maxBytes > 0 {
+		fmt.Printf("[UTXO] Cache limited to %d MB\n", maxBytes/(1024*1024))
+	}
+}
+
+// DBStats returns on-disk statistics for the UTXO database
+func (store *UTXOStore) DBStats() (*DBStats, error) {
+	return store.db.Stats()
+}
+
+// Compact compacts the UTXO database file
+func (store *UTXOStore) Compact() (int64, int64, error) {
+	return store.db.Compact()
+}
+
+// BeginBatch buffers UTXO writes in memory until CommitBatch (used during sync)
+func (store *UTXOStore) BeginBatch() error {
+	return store.db.BeginBatch()
+}
+
+// InBatch reports whether UTXO writes are being buffered
+func (store *UTXOStore) InBatch() bool {
+	return store.db.InBatch()
+}
+
+// CommitBatch writes all buffered UTXO changes in one database transaction
+func (store *UTXOStore) CommitBatch() error {
+	err := store.db.CommitBatch()
+	if store.takeMemoryStale() {
+		store.reloadMemorySet()
+	}
+	return err
+}
+
+// DiscardBatch drops buffered UTXO changes and any cache or memory set entries they produced
+func (store *UTXOStore) DiscardBatch() {
+	store.db.DiscardBatch()
+	store.takeMemoryStale()
+	store.ClearCache()
+	store.reloadMemorySet()
+}
+
+// Savepoint marks the batch so RollbackToSavepoint can drop the UTXO writes after it
+func (store *UTXOStore) Savepoint() error {
+	return store.db.Savepoint()
+}
+
+// ReleaseSavepoint keeps the UTXO writes buffered since Savepoint
+func (store *UTXOStore) ReleaseSavepoint() {
+	store.db.ReleaseSavepoint()
+}
+
+// RollbackToSavepoint drops the UTXO writes buffered since Savepoint and the cache
+// entries they produced. The memory set can only be reloaded from committed data, so it
+// is reloaded when the batch ends.
+func (store *UTXOStore) RollbackToSavepoint() {
+	store.db.RollbackToSavepoint()
+	store.ClearCache()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.memoryStale = store.memory != nil
+}
+
+// takeMemoryStale reports and clears whether the memory set needs a reload
+func (store *UTXOStore) takeMemoryStale() bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	stale := store.memoryStale
+	store.memoryStale = false
+	return stale
+}
+
 // GetUTXOsByAddress returns all unspent UTXOs for a given address
 func (store *UTXOStore) GetUTXOsByAddress(address Address) ([]*UTXO, error) {
 	// Badger handles concurrency - no mutex needed!
@@ -248,6 +456,70 @@ func (store *UTXOStore) GetBalance(address Address) (map[string]uint64, error) {
 	return balances, nil
 }
 
+// PruneHorizon returns the height below which spent UTXOs have been pruned (0 = none)
+func (store *UTXOStore) PruneHorizon() uint64 {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	horizon, _ := store.readCounter(PruneHorizonKey)
+	return horizon
+}
+
+// GetUTXOsAtHeight returns the outputs an address held as of the given block height:
+// created at or below it and not spent until after it. Spent records pruned below the
+// prune horizon are gone, so heights below it are refused.
+func (store *UTXOStore) GetUTXOsAtHeight(address Address, height uint64) ([]*UTXO, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if horizon, _ := store.readCounter(PruneHorizonKey); height < horizon {
+		return nil, fmt.Errorf("history below height %d has been pruned", horizon)
+	}
+	return utxosAtHeight(store.db, address, height)
+}
+
+// utxosAtHeight reads the outputs an address held as of height from db
+func utxosAtHeight(db kvReader, address Address, height uint64) ([]*UTXO, error) {
+	// Collect outpoints first - the address index includes spent outputs
+	prefix := fmt.Sprintf("%s%s:", AddressPrefix, address.String())
+	iterator, err := db.Iterator([]byte(prefix), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	var outpoints []string
+	for ; iterator.Valid(); iterator.Next() {
+		outpoints = append(outpoints, string(iterator.Key()[len(prefix):]))
+	}
+	iterator.Close()
+
+	var utxos []*UTXO
+	for _, outpoint := range outpoints {
+		data, err := db.Get([]byte(UTXOPrefix + outpoint))
+		if err != nil || data == nil {
+			continue
+		}
+		var utxo UTXO
+		if err := json.Unmarshal(data, &utxo); err != nil || utxo.BlockHeight > height {
+			continue
+		}
+
+		if utxo.IsSpent {
+			spent, err := db.Get([]byte(SpentPrefix + outpoint))
+			if err != nil || spent == nil {
+				continue
+			}
+			var spentHeight uint64
+			fmt.Sscanf(string(spent), "%d", &spentHeight)
+			if spentHeight <= height {
+				continue
+			}
+			utxo.IsSpent = false // Still unspent as of height
+		}
+		utxos = append(utxos, &utxo)
+	}
+
+	return utxos, nil
+}
+
 // GetTotalUTXOs returns the total number of UTXOs in the store
 func (store *UTXOStore) GetTotalUTXOs() (int, error) {
 	store.mutex.RLock()
@@ -313,11 +585,7 @@ func (store *UTXOStore) ValidateTransaction(tx *Transaction) error {
 func (store *UTXOStore) ClearCache() {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
-	// Clear sync.Map by deleting all entries
-	store.cache.Range(func(key, value interface{}) bool {
-		store.cache.Delete(key)
-		return true
-	})
+	store.cache.Clear()
 }
 
 // Close closes the database connection
@@ -335,6 +603,8 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 	}
 
 	txID, _ := tx.ID()
+	height := uint64(blockHeight)
+	var events []TxEvent // Receipt of what applying the transaction did
 
 	switch tx.TxType {
 	case TxTypeMintToken:
@@ -343,6 +613,20 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		if err := json.Unmarshal(tx.Data, &mintData); err != nil {
 			return fmt.Errorf("failed to parse mint data: %w", err)
 		}
+		if err := CheckTokenMint(tx, height, utxoLookup(store)); err != nil {
+			return fmt.Errorf("invalid mint: %w", err)
+		}
+
+		if mintData.IsSupplyMint() {
+			if err := tokenRegistry.MintSupply(tx, mintData, height); err != nil {
+				return fmt.Errorf("invalid mint: %w", err)
+			}
+			signer, _ := txSigner(tx)
+			events = append(events, TxEvent{Type: TxEventTokenMinted, Height: height,
+				Address: signer.Display(), TokenID: mintData.TokenID, Amount: mintData.Amount})
+			fmt.Printf("[TokenRegistry] ✅ Minted %d more of %s\n", mintData.Amount, mintData.TokenID[:16])
+			break
+		}
 
 		// Create TokenInfo and register it
 		tokenInfo, err := CreateCustomToken(
@@ -358,6 +642,17 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 
 		// Set token ID to this TX ID
 		tokenInfo.SetTokenID(txID)
+		tokenInfo.MintVersion = mintData.MintVersion
+		if mintData.InitialSupply > 0 {
+			// The rest stays unissued until the creator or a delegate mints it
+			tokenInfo.Unissued = tokenInfo.TotalSupply - mintData.InitialSupply
+			tokenInfo.LockedShadow = mintData.InitialSupply
+		}
+		if mintData.Collateral != nil {
+			// Nothing is issued until positions lock collateral for it
+			tokenInfo.Collateral = mintData.Collateral
+			tokenInfo.Unissued, tokenInfo.LockedShadow = tokenInfo.TotalSupply, 0
+		}
 
 		// Update the token output to have the correct token ID
 		// The output was created with "PENDING" placeholder, now set it to actual TX ID
@@ -373,8 +668,23 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to register token: %w", err)
 		}
 
+		events = append(events, TxEvent{Type: TxEventTokenMinted, Height: height,
+			Address: tokenInfo.CreatorAddress.Display(), TokenID: txID, Amount: tokenInfo.Issued()})
+
 		fmt.Printf("[TokenRegistry] ✅ Registered token: %s (ID: %s, Supply: %d)\n",
-			mintData.Ticker, txID[:16], tokenInfo.TotalSupply)
+			mintData.Ticker, txID[:16], tokenInfo.Issued())
+
+	case TxTypeDelegateMint:
+		data, err := tokenRegistry.RecordMintDelegation(tx, height)
+		if err != nil {
+			return fmt.Errorf("delegation invalid: %w", err)
+		}
+		event := TxEvent{Type: TxEventMintDelegated, Height: height, Address: data.Delegate.Display(), TokenID: data.TokenID}
+		if data.Revoke {
+			event.Type = TxEventMintRevoked
+		}
+		events = append(events, event)
+		fmt.Printf("[TokenRegistry] Minting of %s delegated to %s (revoke=%v)\n", data.TokenID[:16], data.Delegate.Display(), data.Revoke)
 
 	case TxTypeMelt:
 		fmt.Printf("[TokenRegistry] Processing melt transaction: %s\n", txID[:16])
@@ -408,6 +718,8 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 							fmt.Printf("[TokenRegistry] ❌ Failed to record melt: %v\n", err)
 							return fmt.Errorf("melt transaction invalid: %w", err)
 						}
+						events = append(events, TxEvent{Type: TxEventTokenMelted, Height: height,
+							Address: inputUTXO.Output.Address.Display(), TokenID: tokenID, Amount: meltedAmount})
 						fmt.Printf("[TokenRegistry] ✅ Melted %d tokens (ID: %s)\n", meltedAmount, tokenID[:16])
 					} else {
 						fmt.Printf("[TokenRegistry] ⚠️  Could not find input UTXO for melt tx\n")
@@ -417,11 +729,26 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			}
 		}
 
+	case TxTypeBurn:
+		if err := tokenRegistry.RecordBurns(tx, uint64(blockHeight)); err != nil {
+			return fmt.Errorf("burn transaction invalid: %w", err)
+		}
+		events = append(events, burnEvents(tx, height)...)
+		fmt.Printf("[TokenRegistry] 🔥 Recorded burn %s\n", txID[:16])
+
 	case TxTypeOffer:
 		fmt.Printf("[SwapOffer] Processing offer transaction: %s\n", txID[:16])
 		// Offer transactions lock tokens - no special validation needed here
 		// The tokens are locked by not creating outputs for them
 		// Validation happens in CreateOfferTransaction
+		store.trackOffer(tx, true)
+		if err := store.bookOffer(tx, txID, uint64(blockHeight)); err != nil {
+			return err
+		}
+		var offerData OfferData
+		if err := json.Unmarshal(tx.Data, &offerData); err == nil {
+			events = append(events, offerEvent(TxEventOfferOpened, txID, offerData, height))
+		}
 
 	case TxTypeAcceptOffer:
 		fmt.Printf("[SwapOffer] Processing accept offer transaction: %s\n", txID[:16])
@@ -443,17 +770,24 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to parse offer data: %w", err)
 		}
 
+		store.trackOffer(offerTx, false)
+		if err := store.unbookOffer(acceptData.OfferTxID); err != nil {
+			return err
+		}
+
 		// Mark the offer as consumed by setting its locked UTXOs as spent
 		for _, input := range offerTx.Inputs {
 			// Only spend the token inputs (not SHADOW fee inputs)
 			utxo, err := store.GetUTXO(input.PrevTxID, input.OutputIndex)
 			if err == nil && utxo != nil && utxo.Output.TokenID == offerData.HaveTokenID {
-				if err := store.SpendUTXO(input.PrevTxID, input.OutputIndex); err != nil {
+				if err := store.SpendUTXO(input.PrevTxID, input.OutputIndex, uint64(blockHeight)); err != nil {
 					fmt.Printf("[SwapOffer] Warning: Failed to spend offer UTXO: %v\n", err)
 				}
 			}
 		}
 
+		events = append(events, offerEvent(TxEventOfferAccepted, acceptData.OfferTxID, offerData, height))
+
 		fmt.Printf("[SwapOffer] ✅ Accepted offer %s: swapped %d %s for %d %s\n",
 			acceptData.OfferTxID[:16], offerData.HaveAmount, offerData.HaveTokenID[:8],
 			offerData.WantAmount, offerData.WantTokenID[:8])
@@ -478,19 +812,50 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to parse offer data: %w", err)
 		}
 
+		store.trackOffer(offerTx, false)
+		if err := store.unbookOffer(cancelData.OfferTxID); err != nil {
+			return err
+		}
+
 		// Mark the offer as consumed by spending its locked UTXOs
 		for _, input := range offerTx.Inputs {
 			// Only spend the token inputs (not SHADOW fee inputs)
 			utxo, err := store.GetUTXO(input.PrevTxID, input.OutputIndex)
 			if err == nil && utxo != nil && utxo.Output.TokenID == offerData.HaveTokenID {
-				if err := store.SpendUTXO(input.PrevTxID, input.OutputIndex); err != nil {
+				if err := store.SpendUTXO(input.PrevTxID, input.OutputIndex, uint64(blockHeight)); err != nil {
 					fmt.Printf("[SwapOffer] Warning: Failed to spend offer UTXO: %v\n", err)
 				}
 			}
 		}
 
+		events = append(events, offerEvent(TxEventOfferCancelled, cancelData.OfferTxID, offerData, height))
+
 		fmt.Printf("[SwapOffer] ✅ Cancelled offer %s\n", cancelData.OfferTxID[:16])
 
+	case TxTypeMatchOffers:
+		// Settlement outputs pay out the tokens both offers locked; close the offers
+		var matchData MatchOffersData
+		if err := json.Unmarshal(tx.Data, &matchData); err != nil {
+			return fmt.Errorf("failed to parse match data: %w", err)
+		}
+		for _, offerTxID := range []string{matchData.MakerOfferTxID, matchData.TakerOfferTxID} {
+			offerTx, err := store.GetTransaction(offerTxID)
+			if err != nil || offerTx == nil {
+				return fmt.Errorf("failed to get offer transaction %s: %v", offerTxID, err)
+			}
+			store.trackOffer(offerTx, false)
+			if err := store.unbookOffer(offerTxID); err != nil {
+				return err
+			}
+			var offerData OfferData
+			if err := json.Unmarshal(offerTx.Data, &offerData); err == nil {
+				events = append(events, offerEvent(TxEventOffersMatched, offerTxID, offerData, height))
+			}
+		}
+
+		fmt.Printf("[SwapOffer] ✅ Matched offer %s with %s\n",
+			matchData.MakerOfferTxID[:16], matchData.TakerOfferTxID[:16])
+
 	case TxTypeCreatePool:
 		fmt.Printf("[LiquidityPool] ⏳ START processing create pool transaction: %s\n", txID[:16])
 		// Parse pool creation data
@@ -499,6 +864,12 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to parse pool data: %w", err)
 		}
 
+		// The creation fee is burned whether or not the pool can be created
+		if err := tokenRegistry.RecordBurns(tx, uint64(blockHeight)); err != nil {
+			return fmt.Errorf("failed to record pool creation fee: %w", err)
+		}
+		events = append(events, burnEvents(tx, height)...)
+
 		// Validate tokens exist in registry
 		tokenA, existsA := tokenRegistry.GetToken(poolData.TokenA)
 		if !existsA {
@@ -510,30 +881,16 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		}
 
 		// Calculate LP tokens to mint
-		lpTokenAmount := CalculateLPTokens(poolData.AmountA, poolData.AmountB)
-		if lpTokenAmount == 0 {
+		if CalculateLPTokens(poolData.AmountA, poolData.AmountB) == 0 {
 			return fmt.Errorf("LP token amount cannot be zero")
 		}
 
 		// Create LP token ticker with pool ID to ensure uniqueness
 		lpTokenTicker := GetLPTokenName(tokenA.Ticker, tokenB.Ticker, txID)
 
-		// Calculate MaxMint to satisfy validation: TotalSupply == MaxMint * 10^MaxDecimals
-		// For 8 decimals: MaxMint = TotalSupply / 10^8
+		// Supply in whole tokens to satisfy validation: TotalSupply == MaxMint * 10^MaxDecimals
 		lpMaxDecimals := uint8(8)
-		divisor := uint64(1)
-		for i := uint8(0); i < lpMaxDecimals; i++ {
-			divisor *= 10
-		}
-		lpMaxMint := lpTokenAmount / divisor
-		if lpMaxMint == 0 {
-			lpMaxMint = 1 // Minimum 1
-		}
-		// Ensure TotalSupply matches exactly
-		expectedSupply := lpMaxMint
-		for i := uint8(0); i < lpMaxDecimals; i++ {
-			expectedSupply *= 10
-		}
+		expectedSupply, lpMaxMint := lpTokenSupply(poolData.AmountA, poolData.AmountB)
 
 		// Create LP token info
 		lpTokenInfo := &TokenInfo{
@@ -574,20 +931,27 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			if err := poolRegistry.RegisterPool(pool); err != nil {
 				return fmt.Errorf("failed to register pool: %w", err)
 			}
+			if err := store.recordPriceObservation(pool, uint64(blockHeight)); err != nil {
+				return err
+			}
 		}
 
 		// Create UTXO for LP tokens to pool creator (use expectedSupply)
-		lpTokenOutput := CreateTokenOutput(poolData.PoolAddress, expectedSupply, txID, "liquidity_pool", nil)
+		lpTokenOutput := tokenRegistry.NewTokenOutput(poolData.PoolAddress, expectedSupply, txID, "liquidity_pool", nil)
 		lpUTXO := &UTXO{
 			TxID:        txID,
 			OutputIndex: uint32(len(tx.Outputs)), // Add as next output
 			Output:      lpTokenOutput,
+			BlockHeight: uint64(blockHeight), // Fees are earned from this block on
 			IsSpent:     false,
 		}
 		if err := store.AddUTXO(lpUTXO); err != nil {
 			return fmt.Errorf("failed to create LP token UTXO: %w", err)
 		}
 
+		events = append(events, TxEvent{Type: TxEventPoolCreated, Height: height, Address: poolData.PoolAddress.Display(),
+			PoolID: txID, TokenID: txID, Amount: expectedSupply, AmountA: poolData.AmountA, AmountB: poolData.AmountB})
+
 		fmt.Printf("[LiquidityPool] ✅ Created pool %s: %s/%s (reserves: %d/%d, LP tokens: %d)\n",
 			txID[:16], tokenA.Ticker, tokenB.Ticker, poolData.AmountA, poolData.AmountB, expectedSupply)
 
@@ -608,16 +972,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 
 		// Calculate LP tokens to mint based on proportional contribution
 		// LP tokens = min(amountA/reserveA, amountB/reserveB) * lpTokenSupply
-		var lpTokensToMint uint64
-		ratioA := (addData.AmountA * pool.LPTokenSupply) / pool.ReserveA
-		ratioB := (addData.AmountB * pool.LPTokenSupply) / pool.ReserveB
-
-		// Use the smaller ratio to ensure pool ratio is maintained
-		if ratioA < ratioB {
-			lpTokensToMint = ratioA
-		} else {
-			lpTokensToMint = ratioB
-		}
+		lpTokensToMint := quoteAddLiquidity(pool, addData.AmountA, addData.AmountB)
 
 		// Check minimum LP tokens (slippage protection)
 		if lpTokensToMint < addData.MinLPTokens {
@@ -625,6 +980,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		}
 
 		// Update pool reserves
+		pool.observePrice(uint64(blockHeight))
 		pool.ReserveA += addData.AmountA
 		pool.ReserveB += addData.AmountB
 		pool.LPTokenSupply += lpTokensToMint
@@ -634,6 +990,9 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		if err := poolRegistry.UpdatePool(pool); err != nil {
 			return fmt.Errorf("failed to update pool: %w", err)
 		}
+		if err := store.recordPriceObservation(pool, uint64(blockHeight)); err != nil {
+			return err
+		}
 
 		// Update LP token total supply in token registry
 		lpToken, exists := tokenRegistry.GetToken(pool.LPTokenID)
@@ -655,17 +1014,21 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		}
 
 		// Create UTXO for LP tokens to liquidity provider
-		lpTokenOutput := CreateTokenOutput(providerAddress, lpTokensToMint, pool.LPTokenID, "liquidity_pool", nil)
+		lpTokenOutput := tokenRegistry.NewTokenOutput(providerAddress, lpTokensToMint, pool.LPTokenID, "liquidity_pool", nil)
 		lpUTXO := &UTXO{
 			TxID:        txID,
 			OutputIndex: uint32(len(tx.Outputs)), // Add as next output
 			Output:      lpTokenOutput,
+			BlockHeight: uint64(blockHeight), // Fees are earned from this block on
 			IsSpent:     false,
 		}
 		if err := store.AddUTXO(lpUTXO); err != nil {
 			return fmt.Errorf("failed to create LP token UTXO: %w", err)
 		}
 
+		events = append(events, TxEvent{Type: TxEventLiquidityAdded, Height: height, Address: providerAddress.Display(),
+			PoolID: addData.PoolID, TokenID: pool.LPTokenID, Amount: lpTokensToMint, AmountA: addData.AmountA, AmountB: addData.AmountB})
+
 		fmt.Printf("[LiquidityPool] ✅ Added liquidity to pool %s: +%d/%d tokens, minted %d LP tokens\n",
 			addData.PoolID[:16], addData.AmountA, addData.AmountB, lpTokensToMint)
 
@@ -687,8 +1050,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		// Calculate tokens to return based on LP tokens being burned
 		// amountA = (lpTokens / lpTokenSupply) * reserveA
 		// amountB = (lpTokens / lpTokenSupply) * reserveB
-		amountAToReturn := (removeData.LPTokens * pool.ReserveA) / pool.LPTokenSupply
-		amountBToReturn := (removeData.LPTokens * pool.ReserveB) / pool.LPTokenSupply
+		amountAToReturn, amountBToReturn := quoteRemoveLiquidity(pool, removeData.LPTokens)
 
 		// Check minimum amounts (slippage protection)
 		if amountAToReturn < removeData.MinAmountA {
@@ -699,6 +1061,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		}
 
 		// Update pool reserves
+		pool.observePrice(uint64(blockHeight))
 		pool.ReserveA -= amountAToReturn
 		pool.ReserveB -= amountBToReturn
 		pool.LPTokenSupply -= removeData.LPTokens
@@ -708,6 +1071,9 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		if err := poolRegistry.UpdatePool(pool); err != nil {
 			return fmt.Errorf("failed to update pool: %w", err)
 		}
+		if err := store.recordPriceObservation(pool, uint64(blockHeight)); err != nil {
+			return err
+		}
 
 		// Update LP token total supply in token registry (burn tokens)
 		lpToken, exists := tokenRegistry.GetToken(pool.LPTokenID)
@@ -729,7 +1095,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 		}
 
 		// Create UTXOs for returned tokens A and B
-		tokenAOutput := CreateTokenOutput(providerAddress, amountAToReturn, pool.TokenA, "liquidity_pool", nil)
+		tokenAOutput := tokenRegistry.NewTokenOutput(providerAddress, amountAToReturn, pool.TokenA, "liquidity_pool", nil)
 		tokenAUTXO := &UTXO{
 			TxID:        txID,
 			OutputIndex: uint32(len(tx.Outputs)),
@@ -740,7 +1106,7 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to create token A UTXO: %w", err)
 		}
 
-		tokenBOutput := CreateTokenOutput(providerAddress, amountBToReturn, pool.TokenB, "liquidity_pool", nil)
+		tokenBOutput := tokenRegistry.NewTokenOutput(providerAddress, amountBToReturn, pool.TokenB, "liquidity_pool", nil)
 		tokenBUTXO := &UTXO{
 			TxID:        txID,
 			OutputIndex: uint32(len(tx.Outputs) + 1),
@@ -751,6 +1117,9 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to create token B UTXO: %w", err)
 		}
 
+		events = append(events, TxEvent{Type: TxEventLiquidityRemoved, Height: height, Address: providerAddress.Display(),
+			PoolID: removeData.PoolID, TokenID: pool.LPTokenID, Amount: removeData.LPTokens, AmountA: amountAToReturn, AmountB: amountBToReturn})
+
 		fmt.Printf("[LiquidityPool] ✅ Removed liquidity from pool %s: burned %d LP tokens, returned %d/%d tokens\n",
 			removeData.PoolID[:16], removeData.LPTokens, amountAToReturn, amountBToReturn)
 
@@ -769,35 +1138,35 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("pool not found: %s", swapData.PoolID[:16])
 		}
 
-		// Determine which token is being swapped
-		var tokenOut string
-		var reserveIn, reserveOut uint64
+		// Calculate output amount using constant product formula with fees
+		tokenOut, amountOut, err := quoteSwap(pool, swapData.TokenIn, swapData.AmountIn)
+		if err != nil {
+			return err
+		}
 
-		if swapData.TokenIn == pool.TokenA {
-			tokenOut = pool.TokenB
-			reserveIn = pool.ReserveA
-			reserveOut = pool.ReserveB
-		} else if swapData.TokenIn == pool.TokenB {
-			tokenOut = pool.TokenA
-			reserveIn = pool.ReserveB
-			reserveOut = pool.ReserveA
+		// Get swapper address from first output
+		var swapperAddress Address
+		if len(tx.Outputs) > 0 {
+			swapperAddress = tx.Outputs[0].Address
 		} else {
-			return fmt.Errorf("token %s not in pool", swapData.TokenIn[:8])
+			return fmt.Errorf("no outputs found for swap")
 		}
 
-		// Calculate output amount using constant product formula with fees
-		// amountOut = (amountIn * (10000 - fee) * reserveOut) / ((reserveIn * 10000) + (amountIn * (10000 - fee)))
-		feeMultiplier := uint64(10000 - pool.FeePercent) // e.g., 9970 for 0.3% fee
-		numerator := swapData.AmountIn * feeMultiplier * reserveOut
-		denominator := (reserveIn * 10000) + (swapData.AmountIn * feeMultiplier)
-		amountOut := numerator / denominator
-
-		// Check minimum output (slippage protection)
+		// Check minimum output (slippage protection) against this block's reserves. The
+		// swap still pays its fee, but the input tokens go back to the swapper.
 		if amountOut < swapData.MinAmountOut {
-			return fmt.Errorf("insufficient output: would receive %d, minimum %d", amountOut, swapData.MinAmountOut)
+			reason := fmt.Sprintf("insufficient output: would receive %d, minimum %d", amountOut, swapData.MinAmountOut)
+			if err := store.refundFailedTx(tokenRegistry, tx, txID, swapperAddress, swapData.AmountIn, swapData.TokenIn, reason, uint64(blockHeight)); err != nil {
+				return err
+			}
+			fmt.Printf("[LiquidityPool] ↩️  Swap %s failed (%s), refunded %d %s\n",
+				txID[:16], reason, swapData.AmountIn, swapData.TokenIn[:8])
+			return store.recordTxEvents(txID, []TxEvent{{Type: TxEventSwapFailed, Height: height, Address: swapperAddress.Display(),
+				PoolID: swapData.PoolID, TokenIn: swapData.TokenIn, AmountIn: swapData.AmountIn, TokenOut: tokenOut, Reason: reason}})
 		}
 
 		// Update pool reserves
+		pool.observePrice(uint64(blockHeight))
 		if swapData.TokenIn == pool.TokenA {
 			pool.ReserveA += swapData.AmountIn
 			pool.ReserveB -= amountOut
@@ -806,22 +1175,21 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			pool.ReserveA -= amountOut
 		}
 		pool.K = CalculateK(pool.ReserveA, pool.ReserveB)
+		pool.accrueSwapFee(swapData.TokenIn, swapData.AmountIn)
 
 		// Update pool in registry
 		if err := poolRegistry.UpdatePool(pool); err != nil {
 			return fmt.Errorf("failed to update pool: %w", err)
 		}
-
-		// Get swapper address from first output
-		var swapperAddress Address
-		if len(tx.Outputs) > 0 {
-			swapperAddress = tx.Outputs[0].Address
-		} else {
-			return fmt.Errorf("no outputs found for swap")
+		if err := store.recordFeeGrowth(pool, uint64(blockHeight)); err != nil {
+			return err
+		}
+		if err := store.recordPriceObservation(pool, uint64(blockHeight)); err != nil {
+			return err
 		}
 
 		// Create UTXO for output tokens
-		outputTokenOutput := CreateTokenOutput(swapperAddress, amountOut, tokenOut, "swap", nil)
+		outputTokenOutput := tokenRegistry.NewTokenOutput(swapperAddress, amountOut, tokenOut, "swap", nil)
 		outputUTXO := &UTXO{
 			TxID:        txID,
 			OutputIndex: uint32(len(tx.Outputs)),
@@ -832,11 +1200,27 @@ func (store *UTXOStore) ProcessTokenTransaction(tx *Transaction, tokenRegistry *
 			return fmt.Errorf("failed to create output UTXO: %w", err)
 		}
 
+		events = append(events, TxEvent{Type: TxEventSwapExecuted, Height: height, Address: swapperAddress.Display(),
+			PoolID: swapData.PoolID, TokenIn: swapData.TokenIn, AmountIn: swapData.AmountIn, TokenOut: tokenOut, AmountOut: amountOut})
+
 		fmt.Printf("[LiquidityPool] ✅ Swapped in pool %s: %d %s -> %d %s\n",
 			swapData.PoolID[:16], swapData.AmountIn, swapData.TokenIn[:8], amountOut, tokenOut[:8])
+
+	case TxTypeCollateralIssue, TxTypeCollateralMelt, TxTypeLiquidate:
+		collateralEvents, err := store.applyCollateral(tx, txID, tokenRegistry, poolRegistry, height)
+		if err != nil {
+			return fmt.Errorf("%s invalid: %w", tx.TxType, err)
+		}
+		events = append(events, collateralEvents...)
+
+	case TxTypeHTLCLock, TxTypeHTLCClaim, TxTypeHTLCRefund:
+		events = append(events, htlcEvents(tx, txID, height, store.lookupOutput)...)
+		if err := store.applyHTLC(tx, txID, height); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return store.recordTxEvents(txID, events)
 }
 
 // StoreTransaction stores a transaction and indexes it by addresses involved
@@ -860,12 +1244,14 @@ func (store *UTXOStore) StoreTransaction(tx *Transaction, height int64) error {
 		return fmt.Errorf("failed to store transaction: %w", err)
 	}
 
-	// Index by addresses involved (both inputs and outputs)
+	// Index by addresses and tokens involved (both inputs and outputs)
 	addressMap := make(map[string]bool)
+	tokenMap := make(map[string]bool)
 
 	// Collect addresses from outputs
 	for _, output := range tx.Outputs {
 		addressMap[output.Address.String()] = true
+		tokenMap[output.TokenID] = true
 	}
 
 	// Collect addresses from inputs (via UTXOs)
@@ -873,10 +1259,10 @@ func (store *UTXOStore) StoreTransaction(tx *Transaction, height int64) error {
 		// Get UTXO directly from cache/database (we already hold the write lock)
 		key := fmt.Sprintf("%s%s:%d", UTXOPrefix, input.PrevTxID, input.OutputIndex)
 
-		// Check cache first
+		// Check memory first
 		var utxo *UTXO
-		if cached, exists := store.cache.Load(key); exists {
-			utxo = cached.(*UTXO)
+		if cached, exists := store.cachedUTXO(key); exists {
+			utxo = cached
 		} else {
 			// Check database directly (no nested lock)
 			data, err := store.db.Get([]byte(key))
@@ -891,6 +1277,7 @@ func (store *UTXOStore) StoreTransaction(tx *Transaction, height int64) error {
 
 		if utxo != nil {
 			addressMap[utxo.Output.Address.String()] = true
+			tokenMap[utxo.Output.TokenID] = true
 		}
 	}
 
@@ -904,7 +1291,10 @@ func (store *UTXOStore) StoreTransaction(tx *Transaction, height int64) error {
 		}
 	}
 
-	return nil
+	if err := store.indexTokenTxLocked(tokenMap, txID, height); err != nil {
+		return err
+	}
+	return store.indexMemoLocked(tx, txID, height)
 }
 
 // GetTransaction retrieves a transaction by its ID
@@ -1080,21 +1470,20 @@ func (store *UTXOStore) GetValidatorWallet(proposerAddr []byte) (*Address, error
 }
 
 // MigrateCoinbaseTransactions creates transaction records for existing coinbase UTXOs
-// This is a migration function to backfill transaction history from existing UTXO data
+// This is a migration function to backfill transaction history from existing UTXO data.
+// It runs once, as the UTXO store's schema version 1 migration.
 func (store *UTXOStore) MigrateCoinbaseTransactions() error {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
-	// Iterate through all UTXOs
+	// Collect UTXOs without a transaction record first - bolt can't write while a read
+	// cursor is open
 	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
 	if err != nil {
 		return fmt.Errorf("failed to create iterator: %w", err)
 	}
-	defer iterator.Close()
-
 	txSeen := make(map[string]bool)
-	migrated := 0
-
+	var missing []UTXO
 	for ; iterator.Valid(); iterator.Next() {
 		// Get UTXO data
 		data := iterator.Value()
@@ -1112,13 +1501,21 @@ func (store *UTXOStore) MigrateCoinbaseTransactions() error {
 			continue
 		}
 		txSeen[utxo.TxID] = true
+		missing = append(missing, utxo)
+	}
+	iterator.Close()
 
-		// Check if transaction already exists
+	migrated := 0
+	for _, utxo := range missing {
+		// Check if transaction already exists, or was pruned on purpose
 		txKey := fmt.Sprintf("%s%s", TxPrefix, utxo.TxID)
 		existing, _ := store.db.Get([]byte(txKey))
 		if existing != nil {
 			continue // Already have this transaction
 		}
+		if pruned, _ := store.db.Get([]byte(TxPrunedPrefix + utxo.TxID)); pruned != nil {
+			continue
+		}
 
 		// Reconstruct a coinbase transaction from the UTXO
 		// We can only reconstruct coinbase transactions (no inputs)
@@ -1139,13 +1536,13 @@ func (store *UTXOStore) MigrateCoinbaseTransactions() error {
 		}
 
 		if err := store.db.Set([]byte(txKey), txData); err != nil {
-			continue
+			return fmt.Errorf("failed to store transaction %s: %w", utxo.TxID, err)
 		}
 
 		// Create address-tx index
 		addrTxKey := fmt.Sprintf("%s%s:%020d:%s", AddrTxPrefix, utxo.Output.Address.String(), int64(999999999999999999)-int64(utxo.BlockHeight), utxo.TxID)
 		if err := store.db.Set([]byte(addrTxKey), []byte("")); err != nil {
-			continue
+			return fmt.Errorf("failed to index transaction %s: %w", utxo.TxID, err)
 		}
 
 		migrated++
@@ -1157,3 +1554,119 @@ func (store *UTXOStore) MigrateCoinbaseTransactions() error {
 
 	return nil
 }
+
+// MigrateSpentHeightIndex adds the spent-height index PruneSpentUTXOs scans for outputs
+// spent before it existed. Without it those records would never be pruned. Older nodes
+// recorded spends without a height, so each one is dated by the block of the transaction
+// that spent it (the height of that transaction's outputs), or failing that by the store's
+// highest block, which only delays pruning. The height is written back to the spent index.
+func (store *UTXOStore) MigrateSpentHeightIndex() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Collect spends first - bolt can't write while a read cursor is open
+	iterator, err := store.db.Iterator([]byte(SpentPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	spends := make(map[string]uint64)
+	undated := make(map[string]bool)
+	for ; iterator.Valid(); iterator.Next() {
+		outpoint := strings.TrimPrefix(string(iterator.Key()), SpentPrefix)
+		if height, err := strconv.ParseUint(string(iterator.Value()), 10, 64); err == nil {
+			spends[outpoint] = height
+		} else {
+			undated[outpoint] = true
+		}
+	}
+	iterator.Close()
+
+	if len(undated) > 0 {
+		if err := store.dateSpendsLocked(undated, spends); err != nil {
+			return err
+		}
+	}
+
+	indexed := 0
+	for outpoint, spentHeight := range spends {
+		lastColon := strings.LastIndex(outpoint, ":")
+		if lastColon == -1 {
+			continue
+		}
+		outputIndex, err := strconv.ParseUint(outpoint[lastColon+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		if undated[outpoint] {
+			if err := store.db.Set([]byte(SpentPrefix+outpoint), []byte(strconv.FormatUint(spentHeight, 10))); err != nil {
+				return fmt.Errorf("failed to record spent height of %s: %w", outpoint, err)
+			}
+		}
+		key := []byte(spentAtKey(spentHeight, outpoint[:lastColon], uint32(outputIndex)))
+		if existing, _ := store.db.Get(key); existing != nil {
+			continue
+		}
+		if err := store.db.Set(key, []byte("")); err != nil {
+			return fmt.Errorf("failed to index spent output %s: %w", outpoint, err)
+		}
+		indexed++
+	}
+
+	if indexed > 0 {
+		log.Printf("✅ Indexed %d spent outputs by spent height (%d dated from their spending transactions)", indexed, len(undated))
+	}
+	return nil
+}
+
+// dateSpendsLocked finds the spent height of each undated outpoint and adds it to spends.
+// A transaction's block is the height of the outputs it created; spends whose transaction
+// isn't stored (or created no outputs) get the highest block height in the store.
+// Must be called with mutex held.
+func (store *UTXOStore) dateSpendsLocked(undated map[string]bool, spends map[string]uint64) error {
+	iterator, err := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	txHeights := make(map[string]uint64)
+	var tip uint64
+	for ; iterator.Valid(); iterator.Next() {
+		var utxo UTXO
+		if err := json.Unmarshal(iterator.Value(), &utxo); err != nil {
+			continue
+		}
+		txHeights[utxo.TxID] = utxo.BlockHeight
+		if utxo.BlockHeight > tip {
+			tip = utxo.BlockHeight
+		}
+	}
+	iterator.Close()
+
+	iterator, err = store.db.Iterator([]byte(TxPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	for ; iterator.Valid(); iterator.Next() {
+		height, ok := txHeights[strings.TrimPrefix(string(iterator.Key()), TxPrefix)]
+		if !ok {
+			continue
+		}
+		var tx Transaction
+		if err := json.Unmarshal(iterator.Value(), &tx); err != nil {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			outpoint := fmt.Sprintf("%s:%d", input.PrevTxID, input.OutputIndex)
+			if undated[outpoint] {
+				spends[outpoint] = height
+			}
+		}
+	}
+	iterator.Close()
+
+	for outpoint := range undated {
+		if _, dated := spends[outpoint]; !dated {
+			spends[outpoint] = tip
+		}
+	}
+	return nil
+}
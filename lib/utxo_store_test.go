@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneSpentUTXOs(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	addr := kp.Address()
+
+	// Three UTXOs: one spent early, one spent recently, one unspent
+	for i, txID := range []string{"aa", "bb", "cc"} {
+		utxo := &UTXO{
+			TxID:        txID,
+			OutputIndex: 0,
+			Output:      CreateShadowOutput(addr, uint64(100*(i+1))),
+			BlockHeight: uint64(i),
+		}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	if err := store.SpendUTXO("aa", 0, 10); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	if err := store.SpendUTXO("bb", 0, 500); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+
+	pruned, err := store.PruneSpentUTXOs(400)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if pruned != 1 {
+		t.Fatalf("Expected 1 pruned UTXO, got %d", pruned)
+	}
+
+	if utxo, _ := store.GetUTXO("aa", 0); utxo != nil {
+		t.Fatal("Old spent UTXO should have been pruned")
+	}
+	if utxo, _ := store.GetUTXO("bb", 0); utxo == nil || !utxo.IsSpent {
+		t.Fatal("Recently spent UTXO should be kept")
+	}
+
+	utxos, err := store.GetUTXOsByAddress(addr)
+	if err != nil {
+		t.Fatalf("Failed to query address: %v", err)
+	}
+	if len(utxos) != 1 || utxos[0].TxID != "cc" {
+		t.Fatalf("Expected only the unspent UTXO, got %d", len(utxos))
+	}
+
+	// Pruning again is a no-op
+	if pruned, _ := store.PruneSpentUTXOs(400); pruned != 0 {
+		t.Fatalf("Expected nothing left to prune, got %d", pruned)
+	}
+}
+
+func TestUTXOStoreCompactAndStats(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	utxo := &UTXO{TxID: "aa", OutputIndex: 0, Output: CreateShadowOutput(kp.Address(), 100)}
+	if err := store.AddUTXO(utxo); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+
+	// An open iterator must block compaction
+	iter, _ := store.db.Iterator([]byte(UTXOPrefix), nil)
+	if _, _, err := store.Compact(); err == nil {
+		t.Fatal("Expected compaction to fail while an iterator is open")
+	}
+	iter.Close()
+
+	if _, _, err := store.Compact(); err != nil {
+		t.Fatalf("Compaction failed: %v", err)
+	}
+
+	// Data survives the file swap
+	store.ClearCache()
+	got, err := store.GetUTXO("aa", 0)
+	if err != nil || got == nil {
+		t.Fatalf("UTXO missing after compaction: %v", err)
+	}
+
+	stats, err := store.DBStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.KeyCounts[UTXOPrefix] != 1 || stats.KeyCounts[AddressPrefix] != 1 {
+		t.Fatalf("Unexpected key counts: %v", stats.KeyCounts)
+	}
+	if stats.LastCompaction == 0 {
+		t.Fatal("Expected last compaction time to be recorded")
+	}
+}
+
+func TestBalanceIndexAndRichList(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	shadowID := GetGenesisToken().TokenID
+	alice, _ := GenerateKeyPair()
+	bob, _ := GenerateKeyPair()
+
+	add := func(txID string, addr Address, amount uint64) {
+		utxo := &UTXO{TxID: txID, Output: CreateShadowOutput(addr, amount)}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	add("a1", alice.Address(), 300)
+	add("a2", alice.Address(), 200)
+	add("b1", bob.Address(), 400)
+
+	// Re-adding the same output must not double count
+	add("b1", bob.Address(), 400)
+
+	if err := store.SpendUTXO("a2", 0, 5); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+
+	if bal, _ := store.GetIndexedBalance(alice.Address(), shadowID); bal != 300 {
+		t.Fatalf("Expected alice balance 300, got %d", bal)
+	}
+	if supply, _ := store.CirculatingSupply(shadowID); supply != 700 {
+		t.Fatalf("Expected supply 700, got %d", supply)
+	}
+
+	list, err := store.RichList(shadowID, 10)
+	if err != nil {
+		t.Fatalf("Rich list failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Address != bob.Address().String() || list[0].Balance != 400 {
+		t.Fatalf("Unexpected rich list: %+v", list)
+	}
+	if list, _ := store.RichList(shadowID, 1); len(list) != 1 {
+		t.Fatalf("Expected limit to apply, got %d entries", len(list))
+	}
+
+	// Batch lookups read every token an address holds, or only the ones asked for
+	token := &UTXO{TxID: "t1", Output: CreateTokenOutput(alice.Address(), 50, "tokenX", "", nil)}
+	if err := store.AddUTXO(token); err != nil {
+		t.Fatalf("Failed to add UTXO: %v", err)
+	}
+	snapshot, err := store.Snapshot()
+	if err != nil {
+		t.Fatalf("Failed to open snapshot: %v", err)
+	}
+	all, err := snapshot.GetIndexedBalances(alice.Address(), nil)
+	if err != nil || len(all) != 2 || all[shadowID] != 300 || all["tokenX"] != 50 {
+		t.Fatalf("Expected alice's SHADOW and tokenX balances, got %v (%v)", all, err)
+	}
+	filtered, _ := snapshot.GetIndexedBalances(alice.Address(), []string{"tokenX", "tokenY"})
+	if len(filtered) != 1 || filtered["tokenX"] != 50 {
+		t.Fatalf("Expected only the tokenX balance, got %v", filtered)
+	}
+	if none, _ := snapshot.GetIndexedBalances(bob.Address(), []string{"tokenX"}); len(none) != 0 {
+		t.Fatalf("Expected no tokenX balance for bob, got %v", none)
+	}
+	snapshot.Close()
+
+	// A rebuild from the UTXO set matches the incremental index
+	if err := store.RebuildBalanceIndex(); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if !store.HasBalanceIndex() {
+		t.Fatal("Expected balance index version after rebuild")
+	}
+	if supply, _ := store.CirculatingSupply(shadowID); supply != 700 {
+		t.Fatalf("Expected supply 700 after rebuild, got %d", supply)
+	}
+	if bal, _ := store.GetIndexedBalance(bob.Address(), shadowID); bal != 400 {
+		t.Fatalf("Expected bob balance 400 after rebuild, got %d", bal)
+	}
+}
+
+func TestUTXOsAtHeight(t *testing.T) {
+	store, err := NewUTXOStore(filepath.Join(t.TempDir(), "utxo.db"))
+	if err != nil {
+		t.Fatalf("Failed to open UTXO store: %v", err)
+	}
+	defer store.Close()
+
+	kp, _ := GenerateKeyPair()
+	addr := kp.Address()
+
+	// Received 100 at height 5 and 50 at height 20; the first was spent at height 10
+	for _, u := range []struct {
+		txID   string
+		amount uint64
+		height uint64
+	}{{"aa", 100, 5}, {"bb", 50, 20}} {
+		utxo := &UTXO{TxID: u.txID, Output: CreateShadowOutput(addr, u.amount), BlockHeight: u.height}
+		if err := store.AddUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+	}
+	if err := store.SpendUTXO("aa", 0, 10); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+
+	balanceAt := func(height uint64) uint64 {
+		utxos, err := store.GetUTXOsAtHeight(addr, height)
+		if err != nil {
+			t.Fatalf("Query at height %d failed: %v", height, err)
+		}
+		var total uint64
+		for _, utxo := range utxos {
+			total += utxo.Output.Amount
+		}
+		return total
+	}
+
+	for height, want := range map[uint64]uint64{4: 0, 5: 100, 9: 100, 10: 0, 20: 50} {
+		if got := balanceAt(height); got != want {
+			t.Fatalf("Balance at height %d: expected %d, got %d", height, want, got)
+		}
+	}
+
+	// Heights below the prune horizon are refused
+	if _, err := store.PruneSpentUTXOs(15); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if store.PruneHorizon() != 15 {
+		t.Fatalf("Expected prune horizon 15, got %d", store.PruneHorizon())
+	}
+	if _, err := store.GetUTXOsAtHeight(addr, 9); err == nil {
+		t.Fatal("Expected query below prune horizon to fail")
+	}
+	if got := balanceAt(20); got != 50 {
+		t.Fatalf("Expected balance 50 at height 20 after prune, got %d", got)
+	}
+}
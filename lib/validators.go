@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Block votes are weighted by stake when the genesis registers validators. Peer IDs cost
+// nothing to create, so counting one vote per peer lets anyone pass quorum with Sybil
+// nodes. With a validator set only votes from registered validators count, each by its
+// bonded stake, and a block commits once validators holding more than two thirds of the
+// total stake have voted yes. Networks without validators keep counting one vote per peer.
+//
+// Stake is bonded at genesis: it counts against the token supply along with the
+// allocations and is never paid out, so it can't be spent while it carries votes.
+
+// ValidatorStake is a validator registered in the genesis
+type ValidatorStake struct {
+	NodeID  string `json:"node_id"` // libp2p peer ID the validator signs consensus messages with
+	Address string `json:"address"` // Owner of the bonded stake
+	Stake   uint64 `json:"stake"`   // Bonded base units
+}
+
+// ValidatorSet is the stake each registered validator votes with
+type ValidatorSet struct {
+	stakes map[string]uint64 // Node ID -> stake
+	total  uint64
+}
+
+// ValidatorSet returns the network's validators, or nil if votes are counted per peer
+func (g *ChainGenesis) ValidatorSet() *ValidatorSet {
+	if len(g.Validators) == 0 {
+		return nil
+	}
+	set := &ValidatorSet{stakes: make(map[string]uint64, len(g.Validators))}
+	for _, v := range g.Validators {
+		set.stakes[v.NodeID] += v.Stake
+		set.total += v.Stake
+	}
+	return set
+}
+
+// Stake returns a node's bonded stake, 0 if it isn't a validator
+func (vs *ValidatorSet) Stake(nodeID string) uint64 {
+	return vs.stakes[nodeID]
+}
+
+// TotalStake returns the stake of every validator together
+func (vs *ValidatorSet) TotalStake() uint64 {
+	return vs.total
+}
+
+// Tally returns the stake behind yes votes and behind all votes cast, ignoring voters
+// that aren't validators
+func (vs *ValidatorSet) Tally(votes map[string]bool) (yesStake, castStake uint64) {
+	for voter, approve := range votes {
+		stake := vs.stakes[voter]
+		castStake += stake
+		if approve {
+			yesStake += stake
+		}
+	}
+	return yesStake, castStake
+}
+
+// Approved reports whether yes votes holding yesStake commit a block: more than two
+// thirds of the total stake
+func (vs *ValidatorSet) Approved(yesStake uint64) bool {
+	return yesStake*3 > vs.total*2
+}
+
+// validateValidators checks the validator set and returns the stake it bonds
+func (g *ChainGenesis) validateValidators() (uint64, error) {
+	var bonded uint64
+	seen := make(map[string]bool, len(g.Validators))
+	for i, v := range g.Validators {
+		if _, err := peer.Decode(v.NodeID); err != nil {
+			return 0, fmt.Errorf("validator %d has invalid node_id: %w", i, err)
+		}
+		if seen[v.NodeID] {
+			return 0, fmt.Errorf("validator %d: node %s is registered twice", i, v.NodeID)
+		}
+		seen[v.NodeID] = true
+		if _, _, err := ParseAddress(v.Address); err != nil {
+			return 0, fmt.Errorf("validator %d has invalid address: %w", i, err)
+		}
+		if v.Stake == 0 {
+			return 0, fmt.Errorf("validator %d has zero stake", i)
+		}
+		if bonded+v.Stake < bonded {
+			return 0, fmt.Errorf("validator stakes overflow")
+		}
+		bonded += v.Stake
+	}
+	return bonded, nil
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// countingTransport drops published messages and reports a fixed peer count
+type countingTransport struct {
+	peers int
+}
+
+func (c *countingTransport) Publish(data []byte) error { return nil }
+func (c *countingTransport) PeerCount() int            { return c.peers }
+
+// newNodeID returns the node ID of a fresh libp2p key
+func newNodeID(t *testing.T) string {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+	return id.String()
+}
+
+func TestValidatorGenesis(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	node := newNodeID(t)
+	valid := func() *ChainGenesis {
+		g := DefaultChainGenesis()
+		g.Validators = []ValidatorStake{{NodeID: node, Address: kp.Address().String(), Stake: 1000}}
+		return g
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected the validator set to be valid: %v", err)
+	}
+	if DefaultChainGenesis().ValidatorSet() != nil {
+		t.Fatal("Expected no validator set on the built-in network")
+	}
+	if valid().Fingerprint() == DefaultChainGenesis().Fingerprint() {
+		t.Error("Validators should change the genesis fingerprint")
+	}
+
+	cases := map[string]func(g *ChainGenesis){
+		"bad node id":       func(g *ChainGenesis) { g.Validators[0].NodeID = "node-1" },
+		"bad address":       func(g *ChainGenesis) { g.Validators[0].Address = "nope" },
+		"zero stake":        func(g *ChainGenesis) { g.Validators[0].Stake = 0 },
+		"registered twice":  func(g *ChainGenesis) { g.Validators = append(g.Validators, g.Validators[0]) },
+		"stake over supply": func(g *ChainGenesis) { g.Validators[0].Stake = g.TokenInfo().TotalSupply + 1 },
+	}
+	for name, mutate := range cases {
+		g := valid()
+		mutate(g)
+		if err := g.Validate(); err == nil {
+			t.Errorf("%s: expected the genesis to be refused", name)
+		}
+	}
+}
+
+func TestStakeWeightedVotes(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	big, small, smaller := newNodeID(t), newNodeID(t), newNodeID(t)
+	genesis := DefaultChainGenesis()
+	genesis.ChainID = "stake-votes-net"
+	genesis.Validators = []ValidatorStake{
+		{NodeID: big, Address: kp.Address().String(), Stake: 400},
+		{NodeID: small, Address: kp.Address().String(), Stake: 300},
+		{NodeID: smaller, Address: kp.Address().String(), Stake: 300},
+	}
+	SetActiveGenesis(genesis)
+	defer SetActiveGenesis(DefaultChainGenesis())
+
+	validators := genesis.ValidatorSet()
+	if validators.TotalStake() != 1000 || validators.Stake(big) != 400 || validators.Stake("stranger") != 0 {
+		t.Fatalf("Unexpected stakes: total %d, big %d", validators.TotalStake(), validators.Stake(big))
+	}
+	if validators.Approved(666) || !validators.Approved(667) {
+		t.Error("Expected approval to need more than two thirds of the stake")
+	}
+
+	bc, err := NewBlockchain(filepath.Join(t.TempDir(), "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ce := &ConsensusEngine{
+		chain:         bc,
+		mempool:       &Mempool{entries: make(map[string]*MempoolEntry), relay: newTxRelay(), ctx: ctx, cancel: cancel},
+		nodeID:        "stake-votes-observer",
+		proposalVotes: make(map[string]bool),
+		transport:     &countingTransport{peers: 50}, // Sybils don't help
+		now:           time.Now,
+	}
+	height := bc.GetHeight()
+	block := bc.proposeBlockAt(nil, big, nil, time.Now().Unix())
+	ce.pendingProposal = block
+	vote := func(voter string) {
+		ce.handleBlockVote(&BlockVote{BlockHash: block.Hash, BlockIndex: block.Index, Voter: voter, Vote: true})
+	}
+
+	// Any number of unregistered peers can't commit a block
+	for i := 0; i < 10; i++ {
+		vote(newNodeID(t))
+	}
+	vote(big)
+	if bc.GetHeight() != height || len(ce.proposalVotes) != 1 {
+		t.Fatalf("Expected only the validator's vote to count, height %d with %d votes", bc.GetHeight(), len(ce.proposalVotes))
+	}
+
+	// 400 + 300 of 1000 is over two thirds
+	vote(small)
+	if bc.GetHeight() != height+1 {
+		t.Fatalf("Expected the block to commit with 70%% of the stake, height %d", bc.GetHeight())
+	}
+}
@@ -0,0 +1,278 @@
+package lib
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Vesting outputs lock an amount that unlocks linearly between a start and end height,
+// optionally nothing before a cliff. The beneficiary may spend a vesting output at any
+// time, but the transaction must carry the still-locked part forward in a vesting
+// output of its own (same address and token, a schedule that unlocks no sooner), so only
+// the vested part ever leaves the schedule.
+
+// VestingSchedule describes when a vesting output unlocks
+type VestingSchedule struct {
+	StartHeight uint64 `json:"start_height"`           // Vesting starts accruing here
+	CliffHeight uint64 `json:"cliff_height,omitempty"` // Nothing unlocks before this height (0 = no cliff)
+	EndHeight   uint64 `json:"end_height"`             // Fully unlocked from here on
+}
+
+// Validate checks the schedule is well formed
+func (vs *VestingSchedule) Validate() error {
+	if vs.EndHeight <= vs.StartHeight {
+		return fmt.Errorf("vesting end height %d must be after start height %d", vs.EndHeight, vs.StartHeight)
+	}
+	if vs.CliffHeight != 0 && (vs.CliffHeight < vs.StartHeight || vs.CliffHeight > vs.EndHeight) {
+		return fmt.Errorf("vesting cliff height %d must be between start %d and end %d",
+			vs.CliffHeight, vs.StartHeight, vs.EndHeight)
+	}
+	return nil
+}
+
+// VestedAmount returns how much of amount has unlocked at height
+func (vs *VestingSchedule) VestedAmount(amount, height uint64) uint64 {
+	if height < vs.StartHeight || height < vs.CliffHeight {
+		return 0
+	}
+	if height >= vs.EndHeight {
+		return amount
+	}
+	// amount * elapsed / duration without overflowing; the quotient is below amount
+	hi, lo := bits.Mul64(amount, height-vs.StartHeight)
+	vested, _ := bits.Div64(hi, lo, vs.EndHeight-vs.StartHeight)
+	return vested
+}
+
+// LockedAmount returns how much of amount is still locked at height
+func (vs *VestingSchedule) LockedAmount(amount, height uint64) uint64 {
+	return amount - vs.VestedAmount(amount, height)
+}
+
+// CreateVestingOutput creates an output of amount of tokenID vesting to address, stamped
+// with the token's mint version in registry
+func CreateVestingOutput(registry *TokenRegistry, address Address, amount uint64, tokenID string, schedule VestingSchedule) *TxOutput {
+	var output *TxOutput
+	if tokenID == "" || tokenID == "SHADOW" || tokenID == GetGenesisToken().TokenID {
+		output = CreateShadowOutput(address, amount)
+	} else {
+		output = registry.NewTokenOutput(address, amount, tokenID, "custom", nil)
+	}
+	output.Vesting = &schedule
+	return output
+}
+
+// AddVestingOutput adds an output vesting to address on the given schedule
+func (tb *TxBuilder) AddVestingOutput(address Address, amount uint64, tokenID string, schedule VestingSchedule) *TxBuilder {
+	tb.outputs = append(tb.outputs, CreateVestingOutput(tb.registry, address, amount, tokenID, schedule))
+	return tb
+}
+
+// VestingRemainder returns the output that carries a vesting UTXO's locked part forward
+// when it is spent in a block at or after height, or nil if it is fully vested by then
+func VestingRemainder(utxo *UTXO, height uint64) *TxOutput {
+	schedule := utxo.Output.Vesting
+	locked := schedule.LockedAmount(utxo.Output.Amount, height)
+	if locked == 0 {
+		return nil
+	}
+
+	// Restarting at height with the same end keeps the remainder unlocking at the
+	// original rate
+	remainder := VestingSchedule{StartHeight: height, CliffHeight: schedule.CliffHeight, EndHeight: schedule.EndHeight}
+	if remainder.CliffHeight != 0 && remainder.CliffHeight < height {
+		remainder.CliffHeight = 0
+	}
+	output := CreateVestingOutput(nil, utxo.Output.Address, locked, utxo.Output.TokenID, remainder)
+	output.MintVersion = utxo.Output.MintVersion // Same issue as the output it continues
+	return output
+}
+
+// validateVestingOutputs checks vesting schedules; only sends may create vesting outputs
+func validateVestingOutputs(tx *Transaction) error {
+	for i, output := range tx.Outputs {
+		if output.Vesting == nil {
+			continue
+		}
+		if tx.TxType != TxTypeSend {
+			return fmt.Errorf("output %d: vesting outputs can only be created by send transactions", i)
+		}
+		if err := output.Vesting.Validate(); err != nil {
+			return fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// carriesVesting reports whether output carries forward the locked part of a vesting
+// output spent at height: same address and token, at least the amount locked at the
+// remainder's start, and a schedule that unlocks no sooner than the original
+func carriesVesting(output, spent *TxOutput, height uint64) bool {
+	rem, orig := output.Vesting, spent.Vesting
+	if rem == nil || output.Address != spent.Address || output.TokenID != spent.TokenID {
+		return false
+	}
+	if rem.StartHeight > height || rem.StartHeight < orig.StartHeight || rem.EndHeight < orig.EndHeight {
+		return false
+	}
+	if orig.CliffHeight > rem.StartHeight && rem.CliffHeight < orig.CliffHeight {
+		return false
+	}
+	return output.Amount >= orig.LockedAmount(spent.Amount, rem.StartHeight)
+}
+
+// CheckVestingSpends verifies that a transaction included at height carries the locked
+// part of every vesting output it spends forward. lookup resolves spent outputs; inputs
+// it can't resolve are left to other validation.
+func CheckVestingSpends(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error {
+	used := make(map[int]bool)
+	for _, input := range tx.Inputs {
+		spent := lookup(input.PrevTxID, input.OutputIndex)
+		if spent == nil || spent.Vesting == nil {
+			continue
+		}
+		locked := spent.Vesting.LockedAmount(spent.Amount, height)
+		if locked == 0 {
+			continue
+		}
+
+		carried := false
+		for i, output := range tx.Outputs {
+			if !used[i] && carriesVesting(output, spent, height) {
+				used[i], carried = true, true
+				break
+			}
+		}
+		if !carried {
+			return fmt.Errorf("input %s:%d has %d still vesting at block %d that is not carried forward",
+				input.PrevTxID, input.OutputIndex, locked, height)
+		}
+	}
+	return nil
+}
+
+// ValidateVestingSpends rejects a block with a transaction spending locked vesting funds
+func (bc *Blockchain) ValidateVestingSpends(block *Block, mempool *Mempool) error {
+	return bc.checkBlockSpends(block, mempool, CheckVestingSpends)
+}
+
+// checkBlockSpends runs a spend check on every transaction of a block. Spent outputs are
+// looked up in the UTXO store, then among earlier transactions of the same block;
+// transactions are found the same way ValidateTransactionExpiry does.
+func (bc *Blockchain) checkBlockSpends(block *Block, mempool *Mempool, check spendCheck) error {
+	created := make(map[string]*TxOutput)
+	lookup := func(txID string, index uint32) *TxOutput {
+		if output, ok := created[fmt.Sprintf("%s:%d", txID, index)]; ok {
+			return output
+		}
+		if utxo, err := bc.utxoStore.GetUTXO(txID, index); err == nil && utxo != nil {
+			return utxo.Output
+		}
+		return nil
+	}
+
+	for _, txID := range block.Transactions {
+		var tx *Transaction
+		if mempool != nil {
+			tx, _ = mempool.GetTransaction(txID)
+		}
+		if tx == nil {
+			tx, _ = bc.utxoStore.GetTransaction(txID)
+		}
+		if tx == nil {
+			continue
+		}
+		if err := check(tx, block.Index, lookup); err != nil {
+			return fmt.Errorf("transaction %s: %w", txID, err)
+		}
+		for i, output := range tx.Outputs {
+			created[fmt.Sprintf("%s:%d", txID, i)] = output
+		}
+	}
+	return nil
+}
+
+// spendCheck validates a transaction's spends at height; lookup resolves spent outputs
+type spendCheck func(tx *Transaction, height uint64, lookup func(txID string, index uint32) *TxOutput) error
+
+// checkVesting rejects a transaction that would spend locked vesting funds in the next block
+func (mp *Mempool) checkVesting(tx *Transaction) error {
+	return mp.checkSpends(tx, CheckVestingSpends)
+}
+
+// checkSpends runs a spend check on a transaction for the next block
+func (mp *Mempool) checkSpends(tx *Transaction, check spendCheck) error {
+	mp.txLock.RLock()
+	nextHeight, utxoStore := mp.currentHeight+1, mp.utxoStore
+	mp.txLock.RUnlock()
+
+	if utxoStore == nil {
+		return nil
+	}
+	return check(tx, nextHeight, func(txID string, index uint32) *TxOutput {
+		if utxo, err := utxoStore.GetUTXO(txID, index); err == nil && utxo != nil {
+			return utxo.Output
+		}
+		return nil
+	})
+}
+
+// CreateVestingClaimTransaction builds an unsigned send that moves everything vested of
+// tokenID by height out of the given vesting UTXOs to address, carrying the locked parts
+// forward. The fee is paid in SHADOW: from the claim itself when claiming SHADOW,
+// otherwise from the given plain SHADOW UTXOs. Token outputs carry the token's mint
+// version in registry.
+func CreateVestingClaimTransaction(registry *TokenRegistry, utxos []*UTXO, tokenID string, height uint64, address Address, fee uint64) (*Transaction, uint64, error) {
+	genesisTokenID := GetGenesisToken().TokenID
+	if tokenID == "" || tokenID == "SHADOW" {
+		tokenID = genesisTokenID
+	}
+
+	builder := NewTxBuilder(TxTypeSend).SetRegistry(registry)
+	var claimed uint64
+	for _, utxo := range utxos {
+		if utxo.IsSpent || utxo.Output.Vesting == nil || utxo.Output.TokenID != tokenID {
+			continue
+		}
+		vested := utxo.Output.Vesting.VestedAmount(utxo.Output.Amount, height)
+		if vested == 0 {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		if remainder := VestingRemainder(utxo, height); remainder != nil {
+			builder.AddCustomOutput(remainder)
+		}
+		claimed += vested
+	}
+	if claimed == 0 {
+		return nil, 0, fmt.Errorf("nothing has vested yet")
+	}
+
+	if tokenID == genesisTokenID {
+		if claimed <= fee {
+			return nil, 0, fmt.Errorf("vested amount %d does not cover the fee of %d", claimed, fee)
+		}
+		builder.AddOutput(address, claimed-fee, tokenID)
+		return builder.Build(), claimed - fee, nil
+	}
+
+	var shadow uint64
+	for _, utxo := range utxos {
+		if shadow >= fee {
+			break
+		}
+		if utxo.IsSpent || utxo.Output.Vesting != nil || utxo.Output.TokenID != genesisTokenID {
+			continue
+		}
+		builder.AddInput(utxo.TxID, utxo.OutputIndex)
+		shadow += utxo.Output.Amount
+	}
+	if shadow < fee {
+		return nil, 0, fmt.Errorf("insufficient SHADOW for fee: have %d, need %d", shadow, fee)
+	}
+	builder.AddOutput(address, claimed, tokenID)
+	if change := shadow - fee; change > 0 {
+		builder.AddOutput(address, change, genesisTokenID)
+	}
+	return builder.Build(), claimed, nil
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestVestingSchedule(t *testing.T) {
+	schedule := VestingSchedule{StartHeight: 100, CliffHeight: 150, EndHeight: 200}
+	if err := schedule.Validate(); err != nil {
+		t.Fatalf("Valid schedule rejected: %v", err)
+	}
+	for _, c := range []struct{ height, vested uint64 }{{50, 0}, {149, 0}, {150, 500}, {175, 750}, {200, 1000}, {500, 1000}} {
+		if got := schedule.VestedAmount(1000, c.height); got != c.vested {
+			t.Errorf("At height %d expected %d vested, got %d", c.height, c.vested, got)
+		}
+	}
+	if got := schedule.VestedAmount(1<<63, 150); got != 1<<62 {
+		t.Errorf("Large amounts should not overflow, got %d", got)
+	}
+
+	bad := VestingSchedule{StartHeight: 100, CliffHeight: 250, EndHeight: 200}
+	if err := bad.Validate(); err == nil {
+		t.Error("Cliff after end should be rejected")
+	}
+}
+
+func TestVestingSpends(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	vesting := &UTXO{
+		TxID:        "grant",
+		OutputIndex: 0,
+		Output:      CreateVestingOutput(nil, kp.Address(), 1000, "SHADOW", VestingSchedule{StartHeight: 100, EndHeight: 200}),
+	}
+	lookup := func(txID string, index uint32) *TxOutput {
+		if txID == vesting.TxID && index == vesting.OutputIndex {
+			return vesting.Output
+		}
+		return nil
+	}
+
+	// Spending the whole output halfway through leaks locked funds
+	theft := NewTxBuilder(TxTypeSend).AddInput("grant", 0).AddOutput(kp.Address(), 990, "SHADOW").Build()
+	if err := CheckVestingSpends(theft, 150, lookup); err == nil {
+		t.Error("Spend that drops the locked part should be rejected")
+	}
+
+	tx, claimed, err := CreateVestingClaimTransaction(nil, []*UTXO{vesting}, "SHADOW", 150, kp.Address(), 10)
+	if err != nil {
+		t.Fatalf("Failed to build claim: %v", err)
+	}
+	if claimed != 490 {
+		t.Errorf("Expected 490 claimed after fee, got %d", claimed)
+	}
+	if err := validateVestingOutputs(tx); err != nil {
+		t.Fatalf("Claim outputs rejected: %v", err)
+	}
+	if err := CheckVestingSpends(tx, 150, lookup); err != nil {
+		t.Fatalf("Claim rejected: %v", err)
+	}
+	if err := CheckVestingSpends(tx, 120, lookup); err == nil {
+		t.Error("Claim mined before the remainder starts should be rejected")
+	}
+
+	// The remainder keeps unlocking at the original rate
+	remainder := tx.Outputs[0]
+	if remainder.Amount != 500 || remainder.Vesting.VestedAmount(remainder.Amount, 175) != 250 {
+		t.Errorf("Remainder should vest 250 of 500 by height 175, got %+v", remainder.Vesting)
+	}
+
+	if _, _, err := CreateVestingClaimTransaction(nil, []*UTXO{vesting}, "SHADOW", 100, kp.Address(), 10); err == nil {
+		t.Error("Claim before anything vests should fail")
+	}
+}
@@ -35,13 +35,24 @@ type NodeWallet struct {
 	KeyPair *KeyPair
 	Address Address
 	Path    string // File path where wallet is stored
+	Signer  Signer // Optional external signer; nil = sign with KeyPair
 }
 
 // Global node wallet instance
 var globalNodeWallet *NodeWallet
 
-// DefaultWalletPath returns the default wallet path ~/.sn/default.json
+// DefaultWalletPath returns the default wallet path ~/.sn/default.json, or
+// wallet/default.json under the data dir if one is set
 func DefaultWalletPath() (string, error) {
+	return walletPathIn(dataDir)
+}
+
+// walletPathIn returns the wallet path under dir, or ~/.sn/default.json if dir is empty
+func walletPathIn(dir string) (string, error) {
+	if dir != "" {
+		return filepath.Join(dir, "wallet", "default.json"), nil
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user home directory: %w", err)
@@ -131,15 +142,25 @@ func CreateWalletData(passphrase string) (*WalletData, *KeyPair, error) {
 		return nil, nil, fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
+	walletData, err := NewWalletData(keyPair, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return walletData, keyPair, nil
+}
+
+// NewWalletData creates the wallet data structure for an existing key pair, encrypted
+// (version 2) if passphrase is non-empty
+func NewWalletData(keyPair *KeyPair, passphrase string) (*WalletData, error) {
 	// Serialize keys to base64
 	publicKeyBytes, err := PublicKeyToBytes(keyPair.PublicKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to serialize public key: %w", err)
+		return nil, fmt.Errorf("failed to serialize public key: %w", err)
 	}
 
 	privateKeyBytes, err := keyPair.PrivateKey.MarshalBinary()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to serialize private key: %w", err)
+		return nil, fmt.Errorf("failed to serialize private key: %w", err)
 	}
 
 	walletData := &WalletData{
@@ -152,7 +173,7 @@ func CreateWalletData(passphrase string) (*WalletData, *KeyPair, error) {
 	if passphrase != "" {
 		ciphertext, salt, nonce, err := encryptPrivateKey(privateKeyBytes, passphrase)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to encrypt private key: %w", err)
+			return nil, fmt.Errorf("failed to encrypt private key: %w", err)
 		}
 
 		walletData.PrivateKey = base64.StdEncoding.EncodeToString(ciphertext)
@@ -166,7 +187,7 @@ func CreateWalletData(passphrase string) (*WalletData, *KeyPair, error) {
 		walletData.Version = 1
 	}
 
-	return walletData, keyPair, nil
+	return walletData, nil
 }
 
 // LoadWalletData loads wallet data from a JSON file
@@ -295,7 +316,12 @@ func SaveWalletData(walletData *WalletData, path string) error {
 // LoadOrCreateNodeWallet loads wallet from ~/.sn/default.json or creates it if missing
 // passphrase is used for encrypted wallets (v2). Empty string = plaintext wallet (v1)
 func LoadOrCreateNodeWallet(passphrase string) (*NodeWallet, error) {
-	walletPath, err := DefaultWalletPath()
+	return loadOrCreateNodeWalletIn(dataDir, passphrase)
+}
+
+// loadOrCreateNodeWalletIn loads or creates the wallet under the data dir dir
+func loadOrCreateNodeWalletIn(dir, passphrase string) (*NodeWallet, error) {
+	walletPath, err := walletPathIn(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine wallet path: %w", err)
 	}
@@ -393,9 +419,23 @@ func (nw *NodeWallet) GetPrivateKeyBytes() []byte {
 	return privateKeyBytes
 }
 
-// SignTransaction signs a transaction with the node's key pair
+// GetSigner returns the signer used for this wallet (external signer if configured, else the local key pair)
+func (nw *NodeWallet) GetSigner() Signer {
+	if nw.Signer != nil {
+		return nw.Signer
+	}
+	return NewLocalSigner(nw.KeyPair)
+}
+
+// UseSigner switches the wallet to an external signer; the wallet address follows the signer's key
+func (nw *NodeWallet) UseSigner(signer Signer) {
+	nw.Signer = signer
+	nw.Address = DeriveAddress(signer.PublicKey())
+}
+
+// SignTransaction signs a transaction with the node's signer
 func (nw *NodeWallet) SignTransaction(tx *Transaction) error {
-	return tx.Sign(nw.KeyPair)
+	return tx.SignWith(nw.GetSigner())
 }
 
 // CreateTransaction creates a new transaction from this node wallet (legacy - simplified UTXO)
@@ -519,8 +559,8 @@ func (nw *NodeWallet) GetWalletInfo() map[string]interface{} {
 	data, err := os.ReadFile(nw.Path)
 	if err != nil {
 		return map[string]interface{}{
-			"address":       nw.Address.String(),
-			"address_short": nw.Address.String()[:16] + "...",
+			"address":       nw.Address.Display(),
+			"address_short": nw.Address.Display()[:16] + "...",
 			"path":          nw.Path,
 			"created":       int64(0),
 			"version":       1,
@@ -532,12 +572,13 @@ func (nw *NodeWallet) GetWalletInfo() map[string]interface{} {
 	json.Unmarshal(data, &walletData) // Ignore error, use defaults
 
 	return map[string]interface{}{
-		"address":       nw.Address.String(),
-		"address_short": nw.Address.String()[:16] + "...",
+		"address":       nw.Address.Display(),
+		"address_short": nw.Address.Display()[:16] + "...",
 		"path":          nw.Path,
 		"created":       walletData.Created,
 		"version":       walletData.Version,
 		"encrypted":     walletData.Encrypted,
+		"remote_signer": nw.Signer != nil,
 	}
 }
 
@@ -0,0 +1,318 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+)
+
+// Receiving every payment at the node address links all of them on chain. Receive
+// addresses are derived from the wallet key one index at a time, so each payer can be
+// handed a fresh one: the wallet key alone recovers them all. Blocks are scanned for
+// outputs paying them; an address is marked used on its first receipt, and a second
+// receipt is logged as address reuse. No more than gap_limit addresses are handed out
+// unused, so a wallet restored from its key finds every funded address by deriving
+// until gap_limit unused ones in a row.
+
+const (
+	DefaultReceiveAddressesPath = "wallet_addresses.json" // Issued receive addresses
+	DefaultAddressGapLimit      = 20                      // Unused receive addresses handed out at most
+	receiveDerivationDomain     = "shadowy/receive/v1"    // Domain separation for derived key seeds
+)
+
+// ErrGapLimitReached is returned when gap_limit issued addresses are still unused
+var ErrGapLimitReached = errors.New("gap limit reached: issued receive addresses are still unused")
+
+// DeriveReceiveKeyPair derives the key pair of receive address index from the wallet
+// key. Wallets signing through a remote signer hold no key to derive from.
+func (nw *NodeWallet) DeriveReceiveKeyPair(index uint32) (*KeyPair, error) {
+	privateKeyBytes := nw.GetPrivateKeyBytes()
+	if nw.Signer != nil || privateKeyBytes == nil {
+		return nil, fmt.Errorf("wallet has no local key to derive receive addresses from")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(receiveDerivationDomain))
+	h.Write(privateKeyBytes)
+	binary.Write(h, binary.BigEndian, index)
+
+	var seed [mldsa87.SeedSize]byte
+	copy(seed[:], h.Sum(nil))
+	return GenerateKeyPairFromSeed(seed), nil
+}
+
+// ReceiveAddress is a derived address handed out for receiving
+type ReceiveAddress struct {
+	Index           uint32 `json:"index"`
+	Address         string `json:"address"`
+	IssuedHeight    uint64 `json:"issued_height"`
+	FirstUsedHeight uint64 `json:"first_used_height,omitempty"`
+	Receipts        int    `json:"receipts"` // Transactions that paid it; more than one is reuse
+
+	addr Address
+}
+
+// Used reports whether the address has received anything
+func (a *ReceiveAddress) Used() bool {
+	return a.Receipts > 0
+}
+
+// receiveAddressState is the persisted pool state
+type receiveAddressState struct {
+	ScannedHeight uint64            `json:"scanned_height"` // Last block checked for receipts
+	Addresses     []*ReceiveAddress `json:"addresses"`      // By index
+}
+
+// ReceiveAddressPool hands out fresh derived receive addresses and marks them used as
+// payments to them are committed
+type ReceiveAddressPool struct {
+	chain    *Blockchain
+	wallet   *NodeWallet
+	gapLimit int
+	path     string
+
+	mu     sync.Mutex
+	state  receiveAddressState
+	lookup map[Address]*ReceiveAddress
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewReceiveAddressPool loads issued receive addresses from path and starts scanning new
+// blocks for payments to them
+func NewReceiveAddressPool(chain *Blockchain, wallet *NodeWallet, gapLimit int, path string) (*ReceiveAddressPool, error) {
+	p, err := newReceiveAddressPool(chain, wallet, gapLimit, path)
+	if err != nil {
+		return nil, err
+	}
+
+	go p.loop()
+
+	fmt.Printf("[Wallet] 📬 %d receive addresses issued, gap limit %d\n", len(p.state.Addresses), gapLimit)
+	return p, nil
+}
+
+// newReceiveAddressPool loads the pool state without starting the scan loop
+func newReceiveAddressPool(chain *Blockchain, wallet *NodeWallet, gapLimit int, path string) (*ReceiveAddressPool, error) {
+	if _, err := wallet.DeriveReceiveKeyPair(0); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ReceiveAddressPool{
+		chain:    chain,
+		wallet:   wallet,
+		gapLimit: gapLimit,
+		path:     path,
+		lookup:   make(map[Address]*ReceiveAddress),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		// No address was issued before this block, so nothing before it can pay one
+		p.state.ScannedHeight = chain.GetHeight() - 1
+	case err != nil:
+		cancel()
+		return nil, fmt.Errorf("failed to read receive addresses: %w", err)
+	default:
+		if err := json.Unmarshal(data, &p.state); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse receive addresses: %w", err)
+		}
+	}
+
+	// Re-derive rather than parse, so the file can't point the scan at foreign addresses
+	for _, issued := range p.state.Addresses {
+		keyPair, err := wallet.DeriveReceiveKeyPair(issued.Index)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		issued.addr = keyPair.Address()
+		p.lookup[issued.addr] = issued
+	}
+	return p, nil
+}
+
+// NewAddress derives and issues the next receive address. It fails with
+// ErrGapLimitReached while gap_limit issued addresses are still unused.
+func (p *ReceiveAddressPool) NewAddress() (*ReceiveAddress, error) {
+	// Catch up first so addresses paid since the last scan count as used
+	if err := p.update(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if unused := p.unusedLocked(); unused >= p.gapLimit {
+		return nil, fmt.Errorf("%w (%d of %d)", ErrGapLimitReached, unused, p.gapLimit)
+	}
+
+	index := uint32(len(p.state.Addresses))
+	keyPair, err := p.wallet.DeriveReceiveKeyPair(index)
+	if err != nil {
+		return nil, err
+	}
+	issued := &ReceiveAddress{
+		Index:        index,
+		Address:      keyPair.Address().String(),
+		IssuedHeight: p.chain.GetHeight() - 1,
+		addr:         keyPair.Address(),
+	}
+	p.state.Addresses = append(p.state.Addresses, issued)
+	p.lookup[issued.addr] = issued
+	if err := p.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	copied := *issued
+	return &copied, nil
+}
+
+// Display returns the address in the configured display format
+func (a *ReceiveAddress) Display() string {
+	return a.addr.Display()
+}
+
+// List returns the issued receive addresses by index
+func (p *ReceiveAddressPool) List() []*ReceiveAddress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	addresses := make([]*ReceiveAddress, len(p.state.Addresses))
+	for i, issued := range p.state.Addresses {
+		copied := *issued
+		addresses[i] = &copied
+	}
+	return addresses
+}
+
+// Unused returns how many issued addresses haven't received anything
+func (p *ReceiveAddressPool) Unused() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.unusedLocked()
+}
+
+// GapLimit returns the most unused addresses the pool hands out
+func (p *ReceiveAddressPool) GapLimit() int {
+	return p.gapLimit
+}
+
+func (p *ReceiveAddressPool) unusedLocked() int {
+	unused := 0
+	for _, issued := range p.state.Addresses {
+		if !issued.Used() {
+			unused++
+		}
+	}
+	return unused
+}
+
+// KeyPair returns the key pair controlling an issued receive address
+func (p *ReceiveAddressPool) KeyPair(addr Address) (*KeyPair, error) {
+	p.mu.Lock()
+	issued, ok := p.lookup[addr]
+	p.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("address %s is not an issued receive address", addr.String())
+	}
+	return p.wallet.DeriveReceiveKeyPair(issued.Index)
+}
+
+// loop checks new blocks for payments to issued addresses
+func (p *ReceiveAddressPool) loop() {
+	ticker := time.NewTicker(WalletTxCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.update(); err != nil {
+				fmt.Printf("[Wallet] ⚠️  Receive address scan failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// update marks issued addresses paid in blocks since the last scan as used, warning when
+// one that was already used is paid again
+func (p *ReceiveAddressPool) update() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tip := p.chain.GetHeight() - 1
+	if tip <= p.state.ScannedHeight {
+		return nil
+	}
+	for height := p.state.ScannedHeight + 1; height <= tip; height++ {
+		block := p.chain.GetBlock(height)
+		if block == nil {
+			break
+		}
+		for _, txID := range block.Transactions {
+			tx, err := p.chain.GetUTXOStore().GetTransaction(txID)
+			if err != nil || tx == nil {
+				continue
+			}
+			p.recordReceiptsLocked(txID, tx, height)
+		}
+		p.state.ScannedHeight = height
+	}
+	return p.saveLocked()
+}
+
+// recordReceiptsLocked counts one receipt per issued address a transaction pays
+func (p *ReceiveAddressPool) recordReceiptsLocked(txID string, tx *Transaction, height uint64) {
+	paid := make(map[*ReceiveAddress]bool)
+	for _, output := range tx.Outputs {
+		if issued, ok := p.lookup[output.Address]; ok {
+			paid[issued] = true
+		}
+	}
+	for issued := range paid {
+		if !issued.Used() {
+			issued.FirstUsedHeight = height
+		} else {
+			fmt.Printf("[Wallet] ⚠️  Address reuse: receive address %d (%s...) paid again by %s at block %d\n",
+				issued.Index, issued.Address[:16], txID[:16], height)
+		}
+		issued.Receipts++
+	}
+}
+
+// saveLocked writes the pool state to disk
+func (p *ReceiveAddressPool) saveLocked() error {
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal receive addresses: %w", err)
+	}
+	if err := os.WriteFile(p.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write receive addresses: %w", err)
+	}
+	return nil
+}
+
+// Close stops the scan loop and saves the issued addresses
+func (p *ReceiveAddressPool) Close() error {
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.saveLocked()
+}
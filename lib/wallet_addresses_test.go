@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReceiveAddressPool(t *testing.T) {
+	dir := t.TempDir()
+	bc, err := NewBlockchain(filepath.Join(dir, "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	wallet, err := GenerateDeterministicWallet(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	path := filepath.Join(dir, DefaultReceiveAddressesPath)
+	pool, err := newReceiveAddressPool(bc, wallet, 2, path)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+
+	issue := func() *ReceiveAddress {
+		issued, err := pool.NewAddress()
+		if err != nil {
+			t.Fatalf("Failed to issue address: %v", err)
+		}
+		return issued
+	}
+	first, second := issue(), issue()
+	if first.Address == second.Address || first.Address == wallet.Address.String() {
+		t.Fatal("Expected fresh addresses distinct from the node address")
+	}
+	if _, err := pool.NewAddress(); !errors.Is(err, ErrGapLimitReached) {
+		t.Fatalf("Expected the gap limit to stop a third unused address, got %v", err)
+	}
+
+	// A committed payment marks the address used and frees a slot
+	store := bc.GetUTXOStore()
+	pay := func(prevTxID string) {
+		funding := &UTXO{TxID: prevTxID, OutputIndex: 0, Output: CreateShadowOutput(wallet.Address, 500)}
+		if err := store.AddUTXO(funding); err != nil {
+			t.Fatalf("Failed to add funding: %v", err)
+		}
+		tx := NewTxBuilder(TxTypeSend).AddInput(prevTxID, 0).AddOutput(first.addr, 500, "").Build()
+		tx.Sign(wallet.KeyPair)
+		txID, _ := tx.ID()
+		if err := store.StoreTransaction(tx, int64(bc.GetHeight())); err != nil {
+			t.Fatalf("Failed to store transaction: %v", err)
+		}
+		if err := bc.AddBlock(bc.ProposeBlock([]string{txID}, "receive-test-proposer", nil), nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	pay(strings.Repeat("a", 64))
+	if third := issue(); third.Index != 2 {
+		t.Errorf("Expected index 2, got %d", third.Index)
+	}
+
+	// Paying a used address again is counted as reuse, and survives a reload
+	pay(strings.Repeat("b", 64))
+	if err := pool.update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	pool, err = newReceiveAddressPool(bc, wallet, 2, path)
+	if err != nil {
+		t.Fatalf("Failed to reload pool: %v", err)
+	}
+	addresses := pool.List()
+	if len(addresses) != 3 || addresses[0].Receipts != 2 || addresses[0].FirstUsedHeight != 1 {
+		t.Fatalf("Expected the first address paid twice from block 1, got %+v", addresses[0])
+	}
+	if pool.Unused() != 2 {
+		t.Errorf("Expected 2 unused addresses, got %d", pool.Unused())
+	}
+
+	// The wallet key re-derives the key controlling each address
+	keyPair, err := pool.KeyPair(first.addr)
+	if err != nil || keyPair.Address() != first.addr {
+		t.Fatalf("Expected the derived key to control the address, got %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+package lib
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A keystore is a portable, versioned JSON export of a wallet key, encrypted with a
+// passphrase of its own so it can move between machines and be read by other tools. The
+// private key is sealed with AES-256-GCM under a key stretched by argon2id (default) or
+// scrypt, with the address as associated data so a keystore can't be relabelled. Raw
+// private keys can also be exported and imported, but only with --unsafe: anyone who
+// sees one owns the wallet.
+
+const (
+	KeystoreVersion = 1
+	KeystoreCipher  = "aes-256-gcm"
+	KDFArgon2id     = "argon2id"
+	KDFScrypt       = "scrypt"
+
+	// Ceilings on KDF costs read from a keystore, so a crafted file can't exhaust memory
+	maxKeystoreArgon2Memory = 4 * 1024 * 1024 // KiB (4 GiB)
+	maxKeystoreScryptN      = 1 << 22
+)
+
+// KeystoreKDFParams are the key derivation settings stored with a keystore. Fields not
+// used by the KDF are omitted.
+type KeystoreKDFParams struct {
+	Salt  string `json:"salt"` // Hex
+	DKLen int    `json:"dklen"`
+
+	// argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"` // KiB
+	Threads uint8  `json:"threads,omitempty"`
+
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// KeystoreCrypto is the encrypted key and how to decrypt it
+type KeystoreCrypto struct {
+	Cipher     string            `json:"cipher"`
+	Ciphertext string            `json:"ciphertext"` // Hex
+	Nonce      string            `json:"nonce"`      // Hex
+	KDF        string            `json:"kdf"`
+	KDFParams  KeystoreKDFParams `json:"kdfparams"`
+}
+
+// Keystore is an encrypted, portable wallet export
+type Keystore struct {
+	Version   int            `json:"version"`
+	Address   string         `json:"address"`
+	PublicKey string         `json:"public_key"` // Hex
+	Crypto    KeystoreCrypto `json:"crypto"`
+	Created   int64          `json:"created"`
+}
+
+// defaultKDFParams returns the settings new keystores are written with
+func defaultKDFParams(kdf string) (KeystoreKDFParams, error) {
+	switch kdf {
+	case "", KDFArgon2id:
+		return KeystoreKDFParams{DKLen: 32, Time: 3, Memory: 64 * 1024, Threads: 4}, nil
+	case KDFScrypt:
+		return KeystoreKDFParams{DKLen: 32, N: 1 << 18, R: 8, P: 1}, nil
+	default:
+		return KeystoreKDFParams{}, fmt.Errorf("unknown kdf %q (use %s or %s)", kdf, KDFArgon2id, KDFScrypt)
+	}
+}
+
+// deriveKeystoreKey stretches passphrase with the keystore's KDF
+func deriveKeystoreKey(kdf string, params KeystoreKDFParams, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) < 16 {
+		return nil, fmt.Errorf("keystore salt must be at least 16 hex-encoded bytes")
+	}
+	if params.DKLen != 32 {
+		return nil, fmt.Errorf("keystore dklen must be 32, got %d", params.DKLen)
+	}
+
+	switch kdf {
+	case KDFArgon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, fmt.Errorf("argon2id time, memory and threads must be positive")
+		}
+		if params.Memory > maxKeystoreArgon2Memory {
+			return nil, fmt.Errorf("argon2id memory %d KiB exceeds %d KiB", params.Memory, maxKeystoreArgon2Memory)
+		}
+		return argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, uint32(params.DKLen)), nil
+	case KDFScrypt:
+		if params.N > maxKeystoreScryptN {
+			return nil, fmt.Errorf("scrypt n %d exceeds %d", params.N, maxKeystoreScryptN)
+		}
+		key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("scrypt: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported keystore kdf %q", kdf)
+	}
+}
+
+// EncryptKeystore exports a key pair as a keystore encrypted with passphrase, stretched
+// by kdf (argon2id if empty)
+func EncryptKeystore(keyPair *KeyPair, passphrase, kdf string) (*Keystore, error) {
+	if kdf == "" {
+		kdf = KDFArgon2id
+	}
+	params, err := defaultKDFParams(kdf)
+	if err != nil {
+		return nil, err
+	}
+	return encryptKeystore(keyPair, passphrase, kdf, params)
+}
+
+// encryptKeystore exports a key pair with the given KDF settings; the salt is generated
+func encryptKeystore(keyPair *KeyPair, passphrase, kdf string, params KeystoreKDFParams) (*Keystore, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("a keystore passphrase is required")
+	}
+
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	key, err := deriveKeystoreKey(kdf, params, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := keystoreGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	privateKeyBytes, err := keyPair.PrivateKey.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize private key: %w", err)
+	}
+	publicKeyBytes, err := PublicKeyToBytes(keyPair.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize public key: %w", err)
+	}
+
+	address := keyPair.Address().String()
+	return &Keystore{
+		Version:   KeystoreVersion,
+		Address:   address,
+		PublicKey: hex.EncodeToString(publicKeyBytes),
+		Crypto: KeystoreCrypto{
+			Cipher:     KeystoreCipher,
+			Ciphertext: hex.EncodeToString(gcm.Seal(nil, nonce, privateKeyBytes, []byte(address))),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        kdf,
+			KDFParams:  params,
+		},
+		Created: time.Now().Unix(),
+	}, nil
+}
+
+// DecryptKeystore recovers the key pair in a keystore, checking it against the address
+func DecryptKeystore(data []byte, passphrase string) (*KeyPair, error) {
+	var ks Keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("failed to parse keystore: %w", err)
+	}
+	if ks.Version != KeystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version: %d", ks.Version)
+	}
+	if ks.Crypto.Cipher != KeystoreCipher {
+		return nil, fmt.Errorf("unsupported keystore cipher %q", ks.Crypto.Cipher)
+	}
+
+	key, err := deriveKeystoreKey(ks.Crypto.KDF, ks.Crypto.KDFParams, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := keystoreGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(ks.Crypto.Nonce)
+	if err != nil || len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid keystore nonce")
+	}
+	ciphertext, err := hex.DecodeString(ks.Crypto.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore ciphertext")
+	}
+	privateKeyBytes, err := gcm.Open(nil, nonce, ciphertext, []byte(ks.Address))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong passphrase?): %w", err)
+	}
+
+	keyPair, err := KeyPairFromPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	storedAddress, _, err := ParseAddress(ks.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore address: %w", err)
+	}
+	if keyPair.Address() != storedAddress {
+		return nil, fmt.Errorf("keystore corruption: address mismatch")
+	}
+	return keyPair, nil
+}
+
+// keystoreGCM creates the AES-256-GCM cipher for a derived key
+func keystoreGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// KeyPairFromPrivateKey rebuilds a key pair from a packed ML-DSA87 private key
+func KeyPairFromPrivateKey(privateKeyBytes []byte) (*KeyPair, error) {
+	if len(privateKeyBytes) != mldsa87.PrivateKeySize {
+		return nil, fmt.Errorf("ML-DSA87 private key must be %d bytes, got %d", mldsa87.PrivateKeySize, len(privateKeyBytes))
+	}
+	var privateKey mldsa87.PrivateKey
+	if err := privateKey.UnmarshalBinary(privateKeyBytes); err != nil {
+		return nil, fmt.Errorf("failed to reconstruct private key: %w", err)
+	}
+	publicKey, ok := privateKey.Public().(*mldsa87.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to derive public key")
+	}
+	return &KeyPair{PublicKey: publicKey, PrivateKey: &privateKey}, nil
+}
+
+// installWalletKey saves keyPair as the node wallet, encrypted with walletPassphrase if
+// set. An existing wallet is only replaced with force, and is backed up first.
+func installWalletKey(keyPair *KeyPair, walletPassphrase string, force bool) (string, error) {
+	walletPath, err := DefaultWalletPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine wallet path: %w", err)
+	}
+	if _, err := os.Stat(walletPath); err == nil {
+		if !force {
+			return "", fmt.Errorf("a wallet already exists at %s (use --force to replace it; it is backed up first)", walletPath)
+		}
+		existing := &NodeWallet{Path: walletPath}
+		backupPath := fmt.Sprintf("%s.%d.bak", walletPath, time.Now().Unix())
+		if err := existing.BackupWallet(backupPath); err != nil {
+			return "", err
+		}
+		fmt.Printf("📦 Existing wallet backed up to %s\n", backupPath)
+	}
+
+	walletData, err := NewWalletData(keyPair, walletPassphrase)
+	if err != nil {
+		return "", err
+	}
+	if err := SaveWalletData(walletData, walletPath); err != nil {
+		return "", err
+	}
+	return walletPath, nil
+}
+
+// RunWalletCommand runs a "wallet" subcommand:
+//
+//	wallet export-keystore <file> [--kdf argon2id|scrypt]
+//	wallet import-keystore <file> [--force]
+//	wallet export-key --unsafe
+//	wallet import-key <hex> --unsafe [--force]
+//
+// The keystore passphrase comes from --keystore-password or SHADOWY_KEYSTORE_PASSWORD;
+// walletPassphrase unlocks (and, on import, encrypts) the node wallet.
+func RunWalletCommand(args []string, walletPassphrase string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: wallet <export-keystore|import-keystore|export-key|import-key> ...")
+	}
+
+	fs := flag.NewFlagSet("wallet "+args[0], flag.ContinueOnError)
+	kdf := fs.String("kdf", KDFArgon2id, "Keystore key derivation: argon2id or scrypt")
+	force := fs.Bool("force", false, "Replace an existing wallet (it is backed up first)")
+	unsafe := fs.Bool("unsafe", false, "Acknowledge that a raw private key gives full control of the wallet")
+	keystorePassword := fs.String("keystore-password", "", "Keystore passphrase (or set SHADOWY_KEYSTORE_PASSWORD)")
+
+	// Flags may come before or after the positional argument
+	var positional []string
+	rest := args[1:]
+	for {
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		rest = fs.Args()[1:]
+	}
+	if *keystorePassword == "" {
+		*keystorePassword = os.Getenv("SHADOWY_KEYSTORE_PASSWORD")
+	}
+	argument := func(name string) (string, error) {
+		if len(positional) != 1 {
+			return "", fmt.Errorf("usage: wallet %s <%s>", args[0], name)
+		}
+		return positional[0], nil
+	}
+	loadWallet := func() (*KeyPair, error) {
+		walletPath, err := DefaultWalletPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine wallet path: %w", err)
+		}
+		_, keyPair, err := LoadWalletData(walletPath, walletPassphrase)
+		return keyPair, err
+	}
+
+	switch args[0] {
+	case "export-keystore":
+		path, err := argument("file")
+		if err != nil {
+			return err
+		}
+		keyPair, err := loadWallet()
+		if err != nil {
+			return err
+		}
+		ks, err := EncryptKeystore(keyPair, *keystorePassword, *kdf)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(ks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal keystore: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write keystore: %w", err)
+		}
+		fmt.Printf("🔐 Exported %s to keystore %s (%s)\n", ks.Address, path, ks.Crypto.KDF)
+		return nil
+
+	case "import-keystore":
+		path, err := argument("file")
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read keystore: %w", err)
+		}
+		keyPair, err := DecryptKeystore(data, *keystorePassword)
+		if err != nil {
+			return err
+		}
+		walletPath, err := installWalletKey(keyPair, walletPassphrase, *force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Imported %s into %s\n", keyPair.Address().String(), walletPath)
+		return nil
+
+	case "export-key":
+		if !*unsafe {
+			return fmt.Errorf("refusing to print a raw private key without --unsafe: anyone who sees it controls the wallet")
+		}
+		keyPair, err := loadWallet()
+		if err != nil {
+			return err
+		}
+		privateKeyBytes, err := keyPair.PrivateKey.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("failed to serialize private key: %w", err)
+		}
+		fmt.Println(hex.EncodeToString(privateKeyBytes))
+		return nil
+
+	case "import-key":
+		if !*unsafe {
+			return fmt.Errorf("refusing to import a raw private key without --unsafe: prefer import-keystore")
+		}
+		keyHex, err := argument("hex private key")
+		if err != nil {
+			return err
+		}
+		privateKeyBytes, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(keyHex), "0x"))
+		if err != nil {
+			return fmt.Errorf("private key must be hex: %w", err)
+		}
+		keyPair, err := KeyPairFromPrivateKey(privateKeyBytes)
+		if err != nil {
+			return err
+		}
+		walletPath, err := installWalletKey(keyPair, walletPassphrase, *force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔑 Imported %s into %s\n", keyPair.Address().String(), walletPath)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown wallet command %q", args[0])
+	}
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	keyPair, _ := GenerateKeyPair()
+
+	// Cheap KDF settings keep the test fast; the format is the same
+	light := map[string]KeystoreKDFParams{
+		KDFArgon2id: {DKLen: 32, Time: 1, Memory: 1024, Threads: 1},
+		KDFScrypt:   {DKLen: 32, N: 1 << 10, R: 8, P: 1},
+	}
+	for kdf, params := range light {
+		ks, err := encryptKeystore(keyPair, "correct horse", kdf, params)
+		if err != nil {
+			t.Fatalf("%s: failed to export: %v", kdf, err)
+		}
+		data, _ := json.Marshal(ks)
+
+		restored, err := DecryptKeystore(data, "correct horse")
+		if err != nil {
+			t.Fatalf("%s: failed to import: %v", kdf, err)
+		}
+		if restored.Address() != keyPair.Address() {
+			t.Fatalf("%s: imported a different key", kdf)
+		}
+		if _, err := DecryptKeystore(data, "wrong horse"); err == nil {
+			t.Errorf("%s: expected a wrong passphrase to fail", kdf)
+		}
+
+		// The address is bound to the ciphertext
+		other, _ := GenerateKeyPair()
+		relabelled := *ks
+		relabelled.Address = other.Address().String()
+		data, _ = json.Marshal(&relabelled)
+		if _, err := DecryptKeystore(data, "correct horse"); err == nil {
+			t.Errorf("%s: expected a relabelled keystore to fail", kdf)
+		}
+	}
+
+	if _, err := EncryptKeystore(keyPair, "", ""); err == nil {
+		t.Error("Expected an empty keystore passphrase to be rejected")
+	}
+	if _, err := EncryptKeystore(keyPair, "pass", "md5"); err == nil {
+		t.Error("Expected an unknown kdf to be rejected")
+	}
+}
+
+func TestWalletRawKeyImportRequiresUnsafe(t *testing.T) {
+	if err := SetDataDir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to set data dir: %v", err)
+	}
+	defer SetDataDir("")
+
+	keyPair, _ := GenerateKeyPair()
+	keyBytes, _ := keyPair.PrivateKey.MarshalBinary()
+	keyHex := hex.EncodeToString(keyBytes)
+
+	if err := RunWalletCommand([]string{"import-key", keyHex}, ""); err == nil {
+		t.Fatal("Expected a raw key import without --unsafe to be refused")
+	}
+	if err := RunWalletCommand([]string{"export-key"}, ""); err == nil {
+		t.Fatal("Expected a raw key export without --unsafe to be refused")
+	}
+	if err := RunWalletCommand([]string{"import-key", keyHex, "--unsafe"}, "wallet pass"); err != nil {
+		t.Fatalf("Failed to import raw key: %v", err)
+	}
+
+	walletPath, _ := DefaultWalletPath()
+	walletData, loaded, err := LoadWalletData(walletPath, "wallet pass")
+	if err != nil {
+		t.Fatalf("Failed to load imported wallet: %v", err)
+	}
+	if !walletData.Encrypted || loaded.Address() != keyPair.Address() {
+		t.Fatal("Expected the imported key saved encrypted with the wallet passphrase")
+	}
+
+	// An existing wallet is only replaced with --force, after a backup
+	other, _ := GenerateKeyPair()
+	otherData, _ := other.PrivateKey.MarshalBinary()
+	if err := RunWalletCommand([]string{"import-key", "--unsafe", hex.EncodeToString(otherData)}, ""); err == nil {
+		t.Fatal("Expected importing over an existing wallet without --force to fail")
+	}
+	if err := RunWalletCommand([]string{"import-key", "--unsafe", "--force", hex.EncodeToString(otherData)}, ""); err != nil {
+		t.Fatalf("Failed to replace wallet: %v", err)
+	}
+	backups, _ := filepath.Glob(walletPath + ".*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("Expected one wallet backup, found %d", len(backups))
+	}
+	if _, err := os.Stat(walletPath); err != nil {
+		t.Fatalf("Wallet missing after replacement: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transactions this node submits are tracked until they confirm: gossip is fire-and-forget,
+// so a transaction announced just before a restart, or evicted from every mempool, would
+// otherwise vanish. The tracker persists them, re-announces unconfirmed ones every
+// rebroadcast_blocks blocks (re-adding them to the mempool if they fell out), and reports
+// each one as pending, confirmed or expired.
+
+const (
+	DefaultWalletTxsPath     = "wallet_txs.json" // Tracked local transactions
+	DefaultRebroadcastBlocks = 10                // Blocks between rebroadcasts of an unconfirmed tx
+	WalletTxCheckInterval    = 15 * time.Second  // How often the tracker checks for new blocks
+	walletTxRetentionBlocks  = 1000              // Blocks a confirmed or expired tx stays listed
+)
+
+// WalletTxStatus is where a tracked transaction stands
+type WalletTxStatus string
+
+const (
+	WalletTxPending   WalletTxStatus = "pending"   // Not in a block yet
+	WalletTxConfirmed WalletTxStatus = "confirmed" // Included in a block
+	WalletTxExpired   WalletTxStatus = "expired"   // Can never be included: TTL passed or an input was spent elsewhere
+)
+
+// TrackedTx is a locally submitted transaction and its delivery status
+type TrackedTx struct {
+	TxID            string         `json:"tx_id"`
+	Tx              *Transaction   `json:"transaction"`
+	Status          WalletTxStatus `json:"status"`
+	SubmittedHeight uint64         `json:"submitted_height"`
+	BroadcastHeight uint64         `json:"broadcast_height"` // Tip when last announced
+	Broadcasts      int            `json:"broadcasts"`
+	ConfirmedHeight uint64         `json:"confirmed_height,omitempty"`
+	ExpiredHeight   uint64         `json:"expired_height,omitempty"`
+	Reason          string         `json:"reason,omitempty"`     // Why it expired
+	LastError       string         `json:"last_error,omitempty"` // Last failed rebroadcast
+}
+
+// resolvedHeight returns the height a transaction confirmed or expired at, 0 while pending
+func (t *TrackedTx) resolvedHeight() uint64 {
+	if t.Status == WalletTxConfirmed {
+		return t.ConfirmedHeight
+	}
+	return t.ExpiredHeight
+}
+
+// walletTxState is the persisted tracker state
+type walletTxState struct {
+	ScannedHeight uint64                `json:"scanned_height"` // Last block checked for confirmations
+	Txs           map[string]*TrackedTx `json:"txs"`
+}
+
+// WalletTxTracker persists locally originated transactions and rebroadcasts them until
+// they confirm or expire
+type WalletTxTracker struct {
+	chain             *Blockchain
+	rebroadcastBlocks uint64                   // 0 tracks status without rebroadcasting
+	broadcast         func(*Transaction) error // Re-announces a transaction
+	path              string
+
+	mu    sync.Mutex
+	state walletTxState
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWalletTxTracker loads tracked transactions from path and starts the rebroadcast loop
+func NewWalletTxTracker(chain *Blockchain, mempool *Mempool, rebroadcastBlocks int, path string) (*WalletTxTracker, error) {
+	t, err := newWalletTxTracker(chain, rebroadcastBlocks, path)
+	if err != nil {
+		return nil, err
+	}
+	t.broadcast = mempool.Rebroadcast
+	mempool.SetWalletTxTracker(t)
+
+	go t.loop()
+
+	fmt.Printf("[Wallet] 📡 Tracking %d local transactions, rebroadcast every %d blocks\n",
+		len(t.state.Txs), rebroadcastBlocks)
+	return t, nil
+}
+
+// newWalletTxTracker loads the tracker state without starting the loop
+func newWalletTxTracker(chain *Blockchain, rebroadcastBlocks int, path string) (*WalletTxTracker, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &WalletTxTracker{
+		chain:             chain,
+		rebroadcastBlocks: uint64(rebroadcastBlocks),
+		path:              path,
+		state:             walletTxState{Txs: make(map[string]*TrackedTx)},
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		// Nothing submitted before this block can be ours
+		t.state.ScannedHeight = chain.GetHeight() - 1
+	case err != nil:
+		cancel()
+		return nil, fmt.Errorf("failed to read wallet transactions: %w", err)
+	default:
+		if err := json.Unmarshal(data, &t.state); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to parse wallet transactions: %w", err)
+		}
+		if t.state.Txs == nil {
+			t.state.Txs = make(map[string]*TrackedTx)
+		}
+	}
+	return t, nil
+}
+
+// Track starts tracking a transaction submitted by this node. Resubmitting a tracked
+// transaction is a no-op.
+func (t *WalletTxTracker) Track(tx *Transaction) error {
+	txID, err := tx.ID()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction ID: %w", err)
+	}
+	height := t.chain.GetHeight() - 1
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.state.Txs[txID]; exists {
+		return nil
+	}
+	t.state.Txs[txID] = &TrackedTx{
+		TxID:            txID,
+		Tx:              tx,
+		Status:          WalletTxPending,
+		SubmittedHeight: height,
+		BroadcastHeight: height,
+		Broadcasts:      1,
+	}
+	return t.saveLocked()
+}
+
+// List returns tracked transactions, newest first, optionally only those with status
+func (t *WalletTxTracker) List(status WalletTxStatus) []*TrackedTx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	txs := make([]*TrackedTx, 0, len(t.state.Txs))
+	for _, tracked := range t.state.Txs {
+		if status == "" || tracked.Status == status {
+			copied := *tracked
+			txs = append(txs, &copied)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].SubmittedHeight != txs[j].SubmittedHeight {
+			return txs[i].SubmittedHeight > txs[j].SubmittedHeight
+		}
+		return txs[i].TxID < txs[j].TxID
+	})
+	return txs
+}
+
+// loop checks tracked transactions against each new block
+func (t *WalletTxTracker) loop() {
+	ticker := time.NewTicker(WalletTxCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.update(); err != nil {
+				fmt.Printf("[Wallet] ⚠️  Transaction tracker update failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// update marks transactions included in new blocks as confirmed, expires those that can
+// no longer be mined, and rebroadcasts pending ones that are due
+func (t *WalletTxTracker) update() error {
+	return t.check(false)
+}
+
+// RebroadcastNow runs an update that rebroadcasts every pending transaction, due or not
+func (t *WalletTxTracker) RebroadcastNow() error {
+	return t.check(true)
+}
+
+// check is update, rebroadcasting all pending transactions if force
+func (t *WalletTxTracker) check(force bool) error {
+	tip, blocks, conflicts := t.snapshot()
+
+	t.mu.Lock()
+	for _, block := range blocks {
+		for _, txID := range block.Transactions {
+			if tracked, ok := t.state.Txs[txID]; ok && tracked.Status != WalletTxConfirmed {
+				tracked.Status = WalletTxConfirmed
+				tracked.ConfirmedHeight = block.Index
+				tracked.ExpiredHeight = 0
+				tracked.Reason = ""
+				fmt.Printf("[Wallet] ✅ Transaction %s confirmed at block %d\n", txID[:16], block.Index)
+			}
+		}
+	}
+	if tip > t.state.ScannedHeight {
+		t.state.ScannedHeight = tip
+	}
+
+	var due []*Transaction
+	for txID, tracked := range t.state.Txs {
+		if tracked.Status != WalletTxPending {
+			if tip >= tracked.resolvedHeight()+walletTxRetentionBlocks {
+				delete(t.state.Txs, txID)
+			}
+			continue
+		}
+
+		reason := conflicts[txID]
+		if tracked.Tx.ExpiredAt(tip + 1) {
+			reason = fmt.Sprintf("TTL passed at block %d", tracked.Tx.MempoolTTL)
+		}
+		if reason != "" {
+			tracked.Status = WalletTxExpired
+			tracked.ExpiredHeight = tip
+			tracked.Reason = reason
+			fmt.Printf("[Wallet] ⌛ Transaction %s expired: %s\n", txID[:16], reason)
+			continue
+		}
+
+		if force || (t.rebroadcastBlocks > 0 && tip >= tracked.BroadcastHeight+t.rebroadcastBlocks) {
+			tracked.BroadcastHeight = tip
+			due = append(due, tracked.Tx)
+		}
+	}
+	t.mu.Unlock()
+
+	// Broadcast without the lock: re-adding to the mempool calls back into Track
+	results := make(map[string]error, len(due))
+	for _, tx := range due {
+		txID, _ := tx.ID()
+		results[txID] = t.broadcast(tx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for txID, err := range results {
+		tracked, ok := t.state.Txs[txID]
+		if !ok {
+			continue
+		}
+		if err != nil {
+			tracked.LastError = err.Error()
+			fmt.Printf("[Wallet] ⚠️  Failed to rebroadcast %s: %v\n", txID[:16], err)
+			continue
+		}
+		tracked.Broadcasts++
+		tracked.LastError = ""
+		fmt.Printf("[Wallet] 📡 Rebroadcast unconfirmed transaction %s (attempt %d)\n", txID[:16], tracked.Broadcasts)
+	}
+	return t.saveLocked()
+}
+
+// snapshot returns the tip, the blocks not yet scanned, and the pending transactions whose
+// inputs are already spent, all read under the chain lock so the UTXO set matches the tip
+func (t *WalletTxTracker) snapshot() (uint64, []*Block, map[string]string) {
+	t.mu.Lock()
+	scanned := t.state.ScannedHeight
+	var pending []*TrackedTx
+	for _, tracked := range t.state.Txs {
+		if tracked.Status == WalletTxPending {
+			pending = append(pending, tracked)
+		}
+	}
+	t.mu.Unlock()
+
+	bc := t.chain
+	bc.chainLock.RLock()
+	defer bc.chainLock.RUnlock()
+
+	tip := uint64(len(bc.blocks)) - 1
+	var blocks []*Block
+	if scanned < tip {
+		blocks = append(blocks, bc.blocks[scanned+1:]...)
+	}
+
+	// An input spent at or below the tip by a transaction other than ours means ours can
+	// never confirm; the block scan above tells the two apart
+	conflicts := make(map[string]string)
+	for _, tracked := range pending {
+		for _, input := range tracked.Tx.Inputs {
+			utxo, err := bc.utxoStore.GetUTXO(input.PrevTxID, input.OutputIndex)
+			if err == nil && (utxo == nil || utxo.IsSpent) {
+				conflicts[tracked.TxID] = fmt.Sprintf("input %s:%d was spent by another transaction",
+					input.PrevTxID, input.OutputIndex)
+				break
+			}
+		}
+	}
+	return tip, blocks, conflicts
+}
+
+// saveLocked writes the tracker state to disk
+func (t *WalletTxTracker) saveLocked() error {
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal wallet transactions: %w", err)
+	}
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write wallet transactions: %w", err)
+	}
+	return nil
+}
+
+// SetWalletTxTracker makes the mempool hand locally added transactions to tracker
+func (mp *Mempool) SetWalletTxTracker(tracker *WalletTxTracker) {
+	mp.walletTxs = tracker
+}
+
+// Rebroadcast re-announces a local transaction, re-adding it first if it was evicted or
+// lost in a restart
+func (mp *Mempool) Rebroadcast(tx *Transaction) error {
+	txID, err := tx.ID()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction ID: %w", err)
+	}
+	if !mp.HasTransaction(txID) {
+		return mp.AddTransaction(tx)
+	}
+	go mp.forwardToLeader(tx)
+	return mp.announce([]string{txID})
+}
+
+// Close stops the rebroadcast loop and saves the tracked transactions
+func (t *WalletTxTracker) Close() error {
+	t.cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.saveLocked()
+}
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletTxTracker(t *testing.T) {
+	dir := t.TempDir()
+	bc, err := NewBlockchain(filepath.Join(dir, "chain"))
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	defer bc.Close()
+
+	kp, _ := GenerateKeyPair()
+	store := bc.GetUTXOStore()
+	spend := func(prevTxID string, ttl uint32) (*Transaction, string) {
+		if err := store.AddUTXO(&UTXO{TxID: prevTxID, Output: CreateShadowOutput(kp.Address(), 1000)}); err != nil {
+			t.Fatalf("Failed to add UTXO: %v", err)
+		}
+		tx := NewTxBuilder(TxTypeSend).AddInput(prevTxID, 0).AddOutput(kp.Address(), 900, "").SetMempoolTTL(ttl).Build()
+		tx.Sign(kp)
+		txID, _ := tx.ID()
+		return tx, txID
+	}
+	addBlock := func(txIDs ...string) {
+		if err := bc.AddBlock(bc.ProposeBlock(txIDs, "tracker-test-proposer", nil), nil); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	path := filepath.Join(dir, DefaultWalletTxsPath)
+	tracker, err := newWalletTxTracker(bc, 2, path)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	var rebroadcast []string
+	tracker.broadcast = func(tx *Transaction) error {
+		txID, _ := tx.ID()
+		rebroadcast = append(rebroadcast, txID)
+		return nil
+	}
+
+	confirmTx, confirmID := spend("aa", 0)
+	ttlTx, ttlID := spend("bb", 2)
+	stuckTx, stuckID := spend("cc", 0)
+	conflictTx, conflictID := spend("dd", 0)
+	for _, tx := range []*Transaction{confirmTx, ttlTx, stuckTx, conflictTx} {
+		if err := tracker.Track(tx); err != nil {
+			t.Fatalf("Failed to track: %v", err)
+		}
+	}
+
+	// Block 1 confirms one, another transaction spends dd, and block 2 passes the TTL
+	if err := store.StoreTransaction(confirmTx, 0); err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+	addBlock(confirmID)
+	if err := store.SpendUTXO("dd", 0, 1); err != nil {
+		t.Fatalf("Failed to spend UTXO: %v", err)
+	}
+	addBlock()
+	if err := tracker.update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// Reload from disk to check the status survives a restart
+	tracker, err = newWalletTxTracker(bc, 2, path)
+	if err != nil {
+		t.Fatalf("Failed to reload tracker: %v", err)
+	}
+	statuses := make(map[string]*TrackedTx)
+	for _, tracked := range tracker.List("") {
+		statuses[tracked.TxID] = tracked
+	}
+	if got := statuses[confirmID]; got.Status != WalletTxConfirmed || got.ConfirmedHeight != 1 {
+		t.Errorf("Expected confirmed at block 1, got %s at %d", got.Status, got.ConfirmedHeight)
+	}
+	if got := statuses[ttlID]; got.Status != WalletTxExpired {
+		t.Errorf("Transaction past its TTL should expire, got %s", got.Status)
+	}
+	if got := statuses[conflictID]; got.Status != WalletTxExpired {
+		t.Errorf("Transaction with a spent input should expire, got %s", got.Status)
+	}
+	if got := statuses[stuckID]; got.Status != WalletTxPending || got.Broadcasts != 2 {
+		t.Errorf("Expected pending after one rebroadcast, got %s with %d broadcasts", got.Status, got.Broadcasts)
+	}
+	if len(rebroadcast) != 1 || rebroadcast[0] != stuckID {
+		t.Errorf("Only the pending transaction should be rebroadcast, got %v", rebroadcast)
+	}
+	if pending := tracker.List(WalletTxPending); len(pending) != 1 {
+		t.Errorf("Expected 1 pending transaction, got %d", len(pending))
+	}
+}
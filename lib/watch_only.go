@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch-only entries let a node monitor addresses whose keys it doesn't hold, such as
+// cold storage. Balances and history come from the indexes every address has; pending
+// transactions are found in the mempool. The node can build unsigned transactions for
+// them, but never signs: signing endpoints answer NOT_SIGNABLE.
+
+// WatchOnlyPrefix is the key prefix for watch-only entries
+const WatchOnlyPrefix = "watch:" // watch:{address} -> WatchOnlyEntry
+
+// NotSignableCode is the error code signing endpoints return for watch-only addresses
+const NotSignableCode = "NOT_SIGNABLE"
+
+// ErrNotSignable is returned when asked to sign for a watch-only address
+var ErrNotSignable = errors.New("address is watch-only: the node holds no key to sign for it")
+
+// WatchOnlyEntry is an imported address the node tracks but can't sign for
+type WatchOnlyEntry struct {
+	Address   Address `json:"address"`
+	PublicKey string  `json:"public_key,omitempty"` // Hex, when imported by public key
+	Label     string  `json:"label,omitempty"`
+	Created   int64   `json:"created"`
+}
+
+// ToJSON returns the entry with the address in its string form for API responses
+func (e *WatchOnlyEntry) ToJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"address":    e.Address.Display(),
+		"public_key": e.PublicKey,
+		"label":      e.Label,
+		"created":    e.Created,
+		"signable":   false,
+	}
+}
+
+// WatchOnlyWallet is the persisted set of watch-only addresses
+type WatchOnlyWallet struct {
+	db      *BoltDBAdapter
+	mutex   sync.RWMutex
+	entries map[Address]*WatchOnlyEntry
+}
+
+// NewWatchOnlyWallet opens (or creates) the watch-only database
+func NewWatchOnlyWallet(dbPath string) (*WatchOnlyWallet, error) {
+	db, err := NewBoltDBAdapter(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch-only database: %w", err)
+	}
+
+	ww := &WatchOnlyWallet{
+		db:      db,
+		entries: make(map[Address]*WatchOnlyEntry),
+	}
+
+	if err := ww.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return ww, nil
+}
+
+// load reads all entries from the database into memory
+func (ww *WatchOnlyWallet) load() error {
+	iter, err := ww.db.Iterator([]byte(WatchOnlyPrefix), nil)
+	if err != nil {
+		return fmt.Errorf("failed to iterate watch-only entries: %w", err)
+	}
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
+		var entry WatchOnlyEntry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return fmt.Errorf("failed to unmarshal watch-only entry: %w", err)
+		}
+		ww.entries[entry.Address] = &entry
+	}
+
+	return nil
+}
+
+// ParseWatchOnlyKey resolves an address or a hex ML-DSA87 public key to the address to
+// watch. The public key is returned in hex when one was given.
+func ParseWatchOnlyKey(s string) (Address, string, error) {
+	s = strings.TrimSpace(s)
+	if addr, _, err := ParseAPIAddress(s); err == nil {
+		return addr, "", nil
+	}
+
+	keyBytes, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return Address{}, "", fmt.Errorf("not a valid address or hex public key")
+	}
+	publicKey, err := PublicKeyFromBytes(keyBytes)
+	if err != nil {
+		return Address{}, "", err
+	}
+	return DeriveAddress(publicKey), hex.EncodeToString(keyBytes), nil
+}
+
+// Import adds an address, or the address of a public key, as a watch-only entry. Importing
+// an address again updates its label and keeps a public key learned earlier.
+func (ww *WatchOnlyWallet) Import(addressOrPublicKey, label string) (*WatchOnlyEntry, error) {
+	addr, publicKey, err := ParseWatchOnlyKey(addressOrPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if label != "" {
+		if err := ValidateLabel(label); err != nil {
+			return nil, err
+		}
+	}
+
+	ww.mutex.Lock()
+	defer ww.mutex.Unlock()
+
+	entry := &WatchOnlyEntry{
+		Address:   addr,
+		PublicKey: publicKey,
+		Label:     label,
+		Created:   time.Now().Unix(),
+	}
+	if existing, ok := ww.entries[addr]; ok {
+		entry.Created = existing.Created
+		if entry.PublicKey == "" {
+			entry.PublicKey = existing.PublicKey
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal watch-only entry: %w", err)
+	}
+	if err := ww.db.Set([]byte(WatchOnlyPrefix+addr.String()), data); err != nil {
+		return nil, fmt.Errorf("failed to store watch-only entry: %w", err)
+	}
+
+	ww.entries[addr] = entry
+	return entry, nil
+}
+
+// Remove stops watching an address
+func (ww *WatchOnlyWallet) Remove(addr Address) error {
+	ww.mutex.Lock()
+	defer ww.mutex.Unlock()
+
+	if _, ok := ww.entries[addr]; !ok {
+		return fmt.Errorf("address %s is not watched", addr.Display())
+	}
+	if err := ww.db.Delete([]byte(WatchOnlyPrefix + addr.String())); err != nil {
+		return fmt.Errorf("failed to delete watch-only entry: %w", err)
+	}
+
+	delete(ww.entries, addr)
+	return nil
+}
+
+// Get returns the entry for an address
+func (ww *WatchOnlyWallet) Get(addr Address) (*WatchOnlyEntry, bool) {
+	ww.mutex.RLock()
+	defer ww.mutex.RUnlock()
+	entry, ok := ww.entries[addr]
+	return entry, ok
+}
+
+// IsWatchOnly reports whether addr is a watch-only entry
+func (ww *WatchOnlyWallet) IsWatchOnly(addr Address) bool {
+	_, ok := ww.Get(addr)
+	return ok
+}
+
+// List returns all entries, oldest first
+func (ww *WatchOnlyWallet) List() []*WatchOnlyEntry {
+	ww.mutex.RLock()
+	defer ww.mutex.RUnlock()
+
+	entries := make([]*WatchOnlyEntry, 0, len(ww.entries))
+	for _, entry := range ww.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Created != entries[j].Created {
+			return entries[i].Created < entries[j].Created
+		}
+		return entries[i].Address.String() < entries[j].Address.String()
+	})
+	return entries
+}
+
+// Close closes the watch-only database
+func (ww *WatchOnlyWallet) Close() error {
+	return ww.db.Close()
+}
+
+// WatchPendingTx is an unconfirmed transaction paying or spending a watched address
+type WatchPendingTx struct {
+	TxID        string `json:"tx_id"`
+	Incoming    bool   `json:"incoming"`               // Pays the address
+	Outgoing    bool   `json:"outgoing"`               // Spends the address's outputs
+	MissingMemo bool   `json:"missing_memo,omitempty"` // Pays without the memo the address requires
+
+	memo bool // Carries a memo
+}
+
+// PendingForAddress returns the transactions in txs that pay addr or spend its outputs,
+// resolving inputs with lookup
+func PendingForAddress(txs []*Transaction, addr Address, lookup func(txID string, index uint32) *TxOutput) []WatchPendingTx {
+	pending := []WatchPendingTx{}
+	for _, tx := range txs {
+		var entry WatchPendingTx
+		for _, output := range tx.Outputs {
+			if output.Address == addr {
+				entry.Incoming = true
+				break
+			}
+		}
+		for _, input := range tx.Inputs {
+			if spent := lookup(input.PrevTxID, input.OutputIndex); spent != nil && spent.Address == addr {
+				entry.Outgoing = true
+				break
+			}
+		}
+		if entry.Incoming || entry.Outgoing {
+			entry.TxID, _ = tx.ID()
+			entry.memo = txMemo(tx) != nil
+			pending = append(pending, entry)
+		}
+	}
+	return pending
+}
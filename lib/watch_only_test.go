@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchOnlyWallet(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "watchonly.db")
+	cold, _ := GenerateKeyPair()
+	other, _ := GenerateKeyPair()
+
+	ww, err := NewWatchOnlyWallet(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open watch-only wallet: %v", err)
+	}
+	if _, err := ww.Import(other.Address().Display(), "savings"); err != nil {
+		t.Fatalf("Failed to import address: %v", err)
+	}
+
+	// A public key imports the address derived from it
+	publicKey, _ := PublicKeyToBytes(cold.PublicKey)
+	entry, err := ww.Import(hex.EncodeToString(publicKey), "cold-storage")
+	if err != nil {
+		t.Fatalf("Failed to import public key: %v", err)
+	}
+	if entry.Address != cold.Address() || entry.PublicKey == "" {
+		t.Fatalf("Expected the public key's address %s, got %s", cold.Address().Display(), entry.Address.Display())
+	}
+	if _, err := ww.Import("not-a-key", ""); err == nil {
+		t.Error("Expected a value that is neither an address nor a public key to be rejected")
+	}
+	ww.Close()
+
+	// Entries survive a restart; reimporting by address keeps the known public key
+	ww, err = NewWatchOnlyWallet(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen watch-only wallet: %v", err)
+	}
+	defer ww.Close()
+	if len(ww.List()) != 2 || !ww.IsWatchOnly(cold.Address()) {
+		t.Fatalf("Expected both entries after reopening, got %d", len(ww.List()))
+	}
+	if entry, _ := ww.Import(cold.Address().Display(), "vault"); entry.PublicKey == "" || entry.Label != "vault" {
+		t.Errorf("Expected the relabelled entry to keep its public key, got %+v", entry)
+	}
+	if err := ww.Remove(other.Address()); err != nil || ww.IsWatchOnly(other.Address()) {
+		t.Errorf("Failed to stop watching: %v", err)
+	}
+
+	// Pending transactions are found by what they pay and what they spend
+	funding := CreateShadowOutput(cold.Address(), 100)
+	lookup := func(txID string, index uint32) *TxOutput {
+		if txID == "watch-only-test-funding" {
+			return funding
+		}
+		return nil
+	}
+	incoming := NewTxBuilder(TxTypeSend).AddInput("watch-only-test-other", 0).
+		AddCustomOutput(CreateShadowOutput(cold.Address(), 5)).Build()
+	outgoing := NewTxBuilder(TxTypeSend).AddInput("watch-only-test-funding", 0).
+		AddCustomOutput(CreateShadowOutput(other.Address(), 90)).Build()
+	unrelated := NewTxBuilder(TxTypeSend).AddInput("watch-only-test-other", 1).
+		AddCustomOutput(CreateShadowOutput(other.Address(), 1)).Build()
+
+	pending := PendingForAddress([]*Transaction{incoming, outgoing, unrelated}, cold.Address(), lookup)
+	if len(pending) != 2 || !pending[0].Incoming || !pending[1].Outgoing {
+		t.Fatalf("Expected one incoming and one outgoing pending transaction, got %+v", pending)
+	}
+}
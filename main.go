@@ -60,6 +60,59 @@ func main() {
 		os.Exit(1)
 	}
 
+	// All node state lives under the data dir if one is configured
+	if err := lib.SetDataDir(config.DataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Switch to a custom network before anything touches chain state
+	if config.GenesisFile != "" {
+		genesis, err := lib.LoadChainGenesis(config.GenesisFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Genesis error: %v\n", err)
+			os.Exit(1)
+		}
+		lib.SetActiveGenesis(genesis)
+		fmt.Printf("🌐 Chain %s (genesis %s)\n", genesis.ChainID, genesis.Fingerprint()[:16])
+	}
+
+	// Check chain invariants and exit
+	if config.AuditMode {
+		if err := lib.RunAudit(); err != nil {
+			fmt.Fprintf(os.Stderr, "Audit: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Export or import the wallet key and exit
+	if config.WalletMode {
+		if err := lib.RunWalletCommand(config.WalletArgs, config.WalletPassword); err != nil {
+			fmt.Fprintf(os.Stderr, "Wallet: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Export or import a chain backup and exit
+	if config.ArchiveMode {
+		if err := lib.RunArchiveCommand(config.ArchiveArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Archive: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Inspect the stored chain read-only and exit
+	if config.InspectMode {
+		if err := lib.RunInspectCommand(config.InspectArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Inspect: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Default to node mode (start blockchain node)
 	// Use --demo flag to run the old demo code instead
 	if !config.NodeMode {
@@ -106,8 +159,7 @@ func main() {
 	if !config.Quiet {
 		fmt.Println("1. Initializing token system...")
 	}
-	lib.InitializeTokenRegistry()
-	tokenRegistry := lib.GetGlobalTokenRegistry()
+	tokenRegistry := lib.NewTokenRegistry()
 
 	genesisToken := lib.GetGenesisToken()
 	if !config.Quiet {